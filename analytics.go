@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/tokens"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Analytics summarizes a session's conversation turn by turn.
+type Analytics struct {
+	// Turns are the session's turns, in order. A turn starts with a user
+	// message and includes the responses and tool calls it led to.
+	Turns []TurnAnalytics `json:"turns"`
+	// Tokens sums the turns' token breakdowns.
+	Tokens TokenBreakdown `json:"tokens"`
+	// Latency summarizes how long the turns took.
+	Latency LatencyPercentiles `json:"latency"`
+	// ToolCalls counts the calls made to each tool, by name.
+	ToolCalls map[string]int `json:"toolCalls,omitzero"`
+}
+
+// TokenBreakdown divides a turn's tokens by what they were spent on.
+type TokenBreakdown struct {
+	// Prompt is the input of the turn's first request, including the
+	// conversation so far.
+	Prompt int `json:"prompt"`
+	// Completion is the text and tool calls the model generated.
+	Completion int `json:"completion"`
+	// Tools is the input added by tool results in later requests.
+	Tools int `json:"tools"`
+	// Thinking is the part of the model's output spent reasoning. Providers
+	// count it as output but don't report it separately, so it is
+	// estimated from the length of the thinking text, and is 0 when the
+	// thinking wasn't returned.
+	Thinking int `json:"thinking"`
+}
+
+// Total returns the sum of the breakdown's parts.
+func (b TokenBreakdown) Total() int {
+	return b.Prompt + b.Completion + b.Tools + b.Thinking
+}
+
+func (b *TokenBreakdown) add(other TokenBreakdown) {
+	b.Prompt += other.Prompt
+	b.Completion += other.Completion
+	b.Tools += other.Tools
+	b.Thinking += other.Thinking
+}
+
+// TurnAnalytics describes one turn.
+type TurnAnalytics struct {
+	TurnID string         `json:"turnID,omitzero"`
+	Start  time.Time      `json:"start"`
+	Tokens TokenBreakdown `json:"tokens"`
+	// Latency is the time spent waiting on the LLM, from the requests the
+	// chat reported, or else the time from the user's message to the last
+	// record of the turn.
+	Latency time.Duration `json:"latency"`
+	// Rounds is the number of responses from the model.
+	Rounds int `json:"rounds"`
+	// ToolCalls is the number of tools the model called.
+	ToolCalls int `json:"toolCalls"`
+	// Failed is set if the turn ended in an error.
+	Failed bool `json:"failed,omitzero"`
+}
+
+// LatencyPercentiles summarizes a set of latencies.
+type LatencyPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Analytics implements Session.
+func (s *session) Analytics() Analytics {
+	return AnalyzeRecords(s.TotalRecords())
+}
+
+// AnalyzeRecords computes the analytics of a session from its records, in
+// the order they were added, such as those returned by
+// persistence.Store.GetAllRecords. Records no longer in the context window
+// are included, so turns that were compacted away still count.
+func AnalyzeRecords(records []persistence.Record) Analytics {
+	var a Analytics
+	var latencies []time.Duration
+	var turn *TurnAnalytics
+	var last time.Time // the last record of turn
+	var requested bool // whether turn's latency came from requests
+	finish := func() {
+		if turn == nil {
+			return
+		}
+		if !requested && !last.IsZero() {
+			turn.Latency = last.Sub(turn.Start)
+		}
+		a.Turns = append(a.Turns, *turn)
+		a.Tokens.add(turn.Tokens)
+		latencies = append(latencies, turn.Latency)
+		turn = nil
+	}
+
+	for _, r := range records {
+		if r.Role == "system" || isSummaryRecord(r) {
+			continue
+		}
+		if r.Role == chat.UserRole {
+			finish()
+			turn = &TurnAnalytics{TurnID: r.TurnID, Start: r.Timestamp}
+			last, requested = time.Time{}, false
+		}
+		if turn == nil {
+			// Records before the first user message, such as initial
+			// assistant messages, aren't part of a turn
+			continue
+		}
+		if !r.Timestamp.IsZero() {
+			last = r.Timestamp
+		}
+		for _, req := range r.Requests {
+			if !requested {
+				turn.Latency, requested = 0, true
+			}
+			turn.Latency += req.Latency
+		}
+		if r.Status == persistence.RecordStatusFailed {
+			turn.Failed = true
+		}
+
+		switch r.Role {
+		case chat.UserRole:
+			turn.Tokens.Prompt += r.InputTokens
+		case chat.ToolRole:
+			turn.Tokens.Tools += r.InputTokens
+		case chat.AssistantRole:
+			turn.Rounds++
+			thinking := 0
+			for _, c := range r.Contents {
+				if c.Thinking != nil {
+					thinking += tokens.FromChars(len(c.Thinking.Text))
+				}
+				if c.ToolCall != nil {
+					turn.ToolCalls++
+					if a.ToolCalls == nil {
+						a.ToolCalls = make(map[string]int)
+					}
+					a.ToolCalls[c.ToolCall.Name]++
+				}
+			}
+			thinking = min(thinking, r.OutputTokens)
+			turn.Tokens.Thinking += thinking
+			turn.Tokens.Completion += r.OutputTokens - thinking
+			// Tool results folded into an assistant record, as some chats
+			// report them, are counted as tool input
+			turn.Tokens.Tools += r.InputTokens
+		}
+	}
+	finish()
+
+	a.Latency = percentiles(latencies)
+	return a
+}
+
+// isSummaryRecord reports whether r was added by compaction to stand in for
+// earlier turns.
+func isSummaryRecord(r persistence.Record) bool {
+	return r.Role == chat.AssistantRole && strings.HasPrefix(r.GetText(), summaryPrefix)
+}
+
+// percentiles returns the nearest-rank percentiles of latencies.
+func percentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := slices.Sorted(slices.Values(latencies))
+	at := func(p int) time.Duration {
+		// The smallest value at least p percent of the values are at or below
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank, 1)-1]
+	}
+	return LatencyPercentiles{P50: at(50), P90: at(90), P99: at(99), Max: sorted[len(sorted)-1]}
+}
+
+// Summary describes the analytics in a few lines of text, for status
+// displays.
+func (a Analytics) Summary() []string {
+	failed := 0
+	for _, turn := range a.Turns {
+		if turn.Failed {
+			failed++
+		}
+	}
+	turns := fmt.Sprintf("Turns: %d", len(a.Turns))
+	if failed > 0 {
+		turns += fmt.Sprintf(" (%d failed)", failed)
+	}
+	lines := []string{
+		turns,
+		fmt.Sprintf("Tokens: %d prompt, %d completion, %d tool results, %d thinking",
+			a.Tokens.Prompt, a.Tokens.Completion, a.Tokens.Tools, a.Tokens.Thinking),
+	}
+	if len(a.Turns) > 0 {
+		lines = append(lines, fmt.Sprintf("Turn latency: p50 %s, p90 %s, p99 %s, max %s",
+			roundLatency(a.Latency.P50), roundLatency(a.Latency.P90), roundLatency(a.Latency.P99), roundLatency(a.Latency.Max)))
+	}
+	if len(a.ToolCalls) > 0 {
+		// Most called first, then by name
+		names := slices.SortedFunc(maps.Keys(a.ToolCalls), func(x, y string) int {
+			if d := a.ToolCalls[y] - a.ToolCalls[x]; d != 0 {
+				return d
+			}
+			return strings.Compare(x, y)
+		})
+		calls := make([]string, len(names))
+		for i, name := range names {
+			calls[i] = fmt.Sprintf("%s %d", name, a.ToolCalls[name])
+		}
+		lines = append(lines, "Tool calls: "+strings.Join(calls, ", "))
+	}
+	return lines
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
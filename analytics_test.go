@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestAnalyzeRecords(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	call := func(name string) chat.Content {
+		return chat.Content{ToolCall: &chat.ToolCall{ID: name, Name: name, Arguments: json.RawMessage(`{}`)}}
+	}
+	records := []persistence.Record{
+		{Role: chat.AssistantRole, Contents: []chat.Content{{Text: summaryPrefix + "earlier"}}, OutputTokens: 50},
+		// A turn with two rounds and two tool calls, timed by its requests
+		{Role: chat.UserRole, TurnID: "t1", Contents: []chat.Content{{Text: "Fix it"}}, InputTokens: 100, Timestamp: start},
+		{
+			Role:         chat.AssistantRole,
+			Contents:     []chat.Content{{Thinking: &chat.ThinkingContent{Text: strings.Repeat("x", 40)}}, call("ReadFile"), call("Grep")},
+			OutputTokens: 30,
+			Requests:     []chat.RequestInfo{{Latency: 2 * time.Second}},
+			Timestamp:    start.Add(2 * time.Second),
+		},
+		{Role: chat.ToolRole, Contents: []chat.Content{{Text: "results"}}, InputTokens: 200, Timestamp: start.Add(3 * time.Second)},
+		{
+			Role:         chat.AssistantRole,
+			Contents:     []chat.Content{{Text: "Done"}},
+			OutputTokens: 5,
+			Requests:     []chat.RequestInfo{{Latency: time.Second}},
+			Timestamp:    start.Add(time.Minute),
+		},
+		// A turn without requests, timed by its records
+		{Role: chat.UserRole, TurnID: "t2", Contents: []chat.Content{{Text: "Again"}}, InputTokens: 400, Timestamp: start.Add(2 * time.Minute)},
+		{Role: chat.AssistantRole, Contents: []chat.Content{call("ReadFile")}, OutputTokens: 10, Timestamp: start.Add(2*time.Minute + 5*time.Second)},
+		// A failed turn
+		{Role: chat.UserRole, TurnID: "t3", Status: persistence.RecordStatusFailed, Requests: []chat.RequestInfo{{Latency: 10 * time.Second}}, Timestamp: start.Add(3 * time.Minute)},
+	}
+
+	a := AnalyzeRecords(records)
+	require.Len(t, a.Turns, 3)
+	assert.Equal(t, TurnAnalytics{
+		TurnID:    "t1",
+		Start:     start,
+		Tokens:    TokenBreakdown{Prompt: 100, Completion: 25, Tools: 200, Thinking: 10},
+		Latency:   3 * time.Second,
+		Rounds:    2,
+		ToolCalls: 2,
+	}, a.Turns[0])
+	assert.Equal(t, 5*time.Second, a.Turns[1].Latency)
+	assert.Equal(t, 1, a.Turns[1].ToolCalls)
+	assert.True(t, a.Turns[2].Failed)
+	assert.Equal(t, 10*time.Second, a.Turns[2].Latency)
+
+	assert.Equal(t, TokenBreakdown{Prompt: 500, Completion: 35, Tools: 200, Thinking: 10}, a.Tokens)
+	assert.Equal(t, 745, a.Tokens.Total())
+	assert.Equal(t, LatencyPercentiles{P50: 5 * time.Second, P90: 10 * time.Second, P99: 10 * time.Second, Max: 10 * time.Second}, a.Latency)
+	assert.Equal(t, map[string]int{"ReadFile": 2, "Grep": 1}, a.ToolCalls)
+
+	assert.Equal(t, []string{
+		"Turns: 3 (1 failed)",
+		"Tokens: 500 prompt, 35 completion, 200 tool results, 10 thinking",
+		"Turn latency: p50 5s, p90 10s, p99 10s, max 10s",
+		"Tool calls: ReadFile 2, Grep 1",
+	}, a.Summary())
+}
+
+func TestPercentiles(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, LatencyPercentiles{}, percentiles(nil))
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		// Out of order, to check they're sorted
+		latencies[i] = time.Duration(100-i) * time.Millisecond
+	}
+	assert.Equal(t, LatencyPercentiles{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, percentiles(latencies))
+}
+
+func TestSessionAnalytics(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSession(&mockClient{}, "System")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Turns: 0", "Tokens: 0 prompt, 0 completion, 0 tool results, 0 thinking"}, s.Analytics().Summary())
+
+	for range 2 {
+		_, err = s.Message(context.Background(), chat.UserMessage("Hello"))
+		require.NoError(t, err)
+	}
+	a := s.Analytics()
+	require.Len(t, a.Turns, 2)
+	assert.Equal(t, 1, a.Turns[0].Rounds)
+	assert.Empty(t, a.ToolCalls)
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ApprovalRule reports whether calls to a tool need approval, given the
+// tool's name and annotations.
+type ApprovalRule func(name string, annotations chat.ToolAnnotations) bool
+
+// DestructiveToolsNeedApproval is the default ApprovalRule: calls to tools
+// annotated as destructive need approval.
+func DestructiveToolsNeedApproval(name string, annotations chat.ToolAnnotations) bool {
+	return annotations.Destructive()
+}
+
+// WithToolApprover asks approver to approve each call to a tool that needs
+// approval, as decided by WithApprovalRule, after the session's policy
+// evaluators have allowed it. Approvers, such as transport.Approvals, can
+// find the tool's annotations with ToolAnnotationsFromContext. Without an
+// approver, calls that need approval are denied.
+func WithToolApprover(approver PolicyEvaluator) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.toolApprover = approver
+	}
+}
+
+// WithApprovalRule replaces DestructiveToolsNeedApproval as the rule for
+// which tools need approval. A nil rule means no tool does.
+func WithApprovalRule(rule ApprovalRule) SessionOption {
+	return func(opts *sessionOptions) {
+		if rule == nil {
+			rule = func(string, chat.ToolAnnotations) bool { return false }
+		}
+		opts.approvalRule = rule
+	}
+}
+
+// annotationsKey is the context key under which guardedTool passes the
+// tool's annotations to evaluators.
+type annotationsKey struct{}
+
+// ToolAnnotationsFromContext returns the annotations of the tool whose
+// call a PolicyEvaluator is evaluating.
+func ToolAnnotationsFromContext(ctx context.Context) (chat.ToolAnnotations, bool) {
+	annotations, ok := ctx.Value(annotationsKey{}).(chat.ToolAnnotations)
+	return annotations, ok
+}
+
+// noApprover denies the calls that need approval in sessions without an
+// approver.
+type noApprover struct{}
+
+func (noApprover) EvaluateToolCall(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+	return PolicyDecision{Action: ToolDecisionDeny, Reason: "it needs approval, and there's no one to approve it"}, nil
+}
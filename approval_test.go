@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const deleteFileSchema = `{"name": "DeleteFile", "inputSchema": {"type": "object"}, "annotations": {"title": "Delete file", "destructiveHint": true}}`
+
+func TestSessionApproval(t *testing.T) {
+	t.Parallel()
+
+	var seen []chat.ToolAnnotations
+	approver := PolicyEvaluatorFunc(func(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+		annotations, ok := ToolAnnotationsFromContext(ctx)
+		require.True(t, ok)
+		seen = append(seen, annotations)
+		if string(call.Arguments) == `{"fileName": "keep"}` {
+			return PolicyDecision{Action: ToolDecisionDeny, Reason: "the user said no"}, nil
+		}
+		return PolicyDecision{Action: ToolDecisionAllow}, nil
+	})
+
+	tests := []struct {
+		name    string
+		opts    []SessionOption
+		results []string // for removing "tmp", then "keep"
+	}{
+		{
+			name:    "no approver",
+			results: []string{"policy_denied", "policy_denied"},
+		},
+		{
+			name:    "approver",
+			opts:    []SessionOption{WithToolApprover(approver)},
+			results: []string{"deleted", "policy_denied"},
+		},
+		{
+			name:    "no approval needed",
+			opts:    []SessionOption{WithApprovalRule(nil)},
+			results: []string{"deleted", "deleted"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockClient{}
+			session, err := NewSession(client, "System", tt.opts...)
+			require.NoError(t, err)
+			require.NoError(t, session.RegisterTool(&mockTool{
+				name:   "DeleteFile",
+				schema: deleteFileSchema,
+				callFn: func(ctx context.Context, input string) string {
+					return `{"status": "deleted"}`
+				},
+			}))
+			require.NoError(t, session.RegisterTool(&mockTool{
+				name:   "ReadFile",
+				schema: `{"name": "ReadFile", "inputSchema": {"type": "object"}}`,
+				callFn: func(ctx context.Context, input string) string {
+					return `{"status": "read"}`
+				},
+			}))
+
+			_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+			require.NoError(t, err)
+			tools := client.chats[len(client.chats)-1].tools
+
+			// Tools that aren't destructive never need approval
+			assert.Equal(t, `{"status": "read"}`, tools["ReadFile"](context.Background(), `{}`))
+
+			var results []string
+			for _, name := range []string{"tmp", "keep"} {
+				var result map[string]any
+				require.NoError(t, json.Unmarshal([]byte(tools["DeleteFile"](context.Background(), `{"fileName": "`+name+`"}`)), &result))
+				if code, ok := result["errorCode"].(string); ok {
+					results = append(results, code)
+				} else {
+					results = append(results, result["status"].(string))
+				}
+			}
+			assert.Equal(t, tt.results, results)
+		})
+	}
+
+	require.Len(t, seen, 2)
+	assert.Equal(t, "Delete file", seen[0].Title)
+	assert.True(t, seen[0].Destructive())
+}
+
+func TestSessionApprovalRule(t *testing.T) {
+	t.Parallel()
+
+	var approved []string
+	client := &mockClient{}
+	session, err := NewSession(client, "System",
+		WithToolApprover(PolicyEvaluatorFunc(func(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+			approved = append(approved, call.Name)
+			return PolicyDecision{Action: ToolDecisionAllow}, nil
+		})),
+		WithApprovalRule(func(name string, annotations chat.ToolAnnotations) bool {
+			return !annotations.ReadOnlyHint
+		}),
+	)
+	require.NoError(t, err)
+	noop := func(ctx context.Context, input string) string { return `{}` }
+	for _, tool := range []chat.Tool{
+		&mockTool{name: "ReadFile", schema: `{"name": "ReadFile", "inputSchema": {"type": "object"}, "annotations": {"readOnlyHint": true}}`, callFn: noop},
+		&mockTool{name: "Fetch", schema: `{"name": "Fetch", "inputSchema": {"type": "object"}}`, callFn: noop},
+	} {
+		require.NoError(t, session.RegisterTool(tool))
+	}
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+	tools := client.chats[len(client.chats)-1].tools
+	tools["ReadFile"](context.Background(), `{}`)
+	tools["Fetch"](context.Background(), `{}`)
+	assert.Equal(t, []string{"Fetch"}, approved)
+}
+
+func TestSessionToolAnnotations(t *testing.T) {
+	t.Parallel()
+
+	session, err := NewSession(&mockClient{}, "System")
+	require.NoError(t, err)
+	require.NoError(t, session.RegisterTool(&mockTool{name: "DeleteFile", schema: deleteFileSchema}))
+	require.NoError(t, session.RegisterTool(chat.Annotated(
+		&mockTool{name: "ReadFile", schema: `{"name": "ReadFile", "inputSchema": {"type": "object"}}`},
+		chat.ToolAnnotations{ReadOnlyHint: true},
+	)))
+
+	annotations, ok := session.ToolAnnotations("DeleteFile")
+	require.True(t, ok)
+	assert.True(t, annotations.Destructive())
+	assert.Equal(t, "Delete file", annotations.Title)
+
+	annotations, ok = session.ToolAnnotations("ReadFile")
+	require.True(t, ok)
+	assert.Equal(t, chat.ToolAnnotations{ReadOnlyHint: true}, annotations)
+
+	_, ok = session.ToolAnnotations("missing")
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// ReadArtifactToolName is the name of the built-in tool the LLM uses to read
+// tool results that were truncated by WithMaxToolResultSize.
+const ReadArtifactToolName = "read_artifact"
+
+// truncatingTool stores results larger than limit bytes as artifacts, and
+// returns a truncated result with a handle for read_artifact instead.
+type truncatingTool struct {
+	chat.Tool
+	store     persistence.Store
+	sessionID string
+	limit     int
+}
+
+func (t *truncatingTool) Call(ctx context.Context, input string) string {
+	return t.truncate(ctx, t.Tool.Call(ctx, input))
+}
+
+// CallWithImages truncates the text of the result, passing the wrapped
+// tool's images through.
+func (t *truncatingTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	it, ok := t.Tool.(chat.ImageTool)
+	if !ok {
+		return t.Call(ctx, input), nil
+	}
+	output, images := it.CallWithImages(ctx, input)
+	return t.truncate(ctx, output), images
+}
+
+func (t *truncatingTool) truncate(ctx context.Context, output string) string {
+	if len(output) <= t.limit {
+		return output
+	}
+
+	id, err := t.store.SaveArtifact(t.sessionID, output)
+	if err != nil {
+		// Better to use up context than to lose the result
+		logger.WarnContext(ctx, "failed to save tool result artifact", "tool", t.Name(), "error", err)
+		return output
+	}
+
+	truncated := truncateUTF8(output, t.limit)
+	return fmt.Sprintf("%s\n\n[Output truncated: showing %d of %d bytes. Call %s with {\"handle\": %d, \"offset\": %d} to read more.]",
+		truncated, len(truncated), len(output), ReadArtifactToolName, id, len(truncated))
+}
+
+// truncateUTF8 returns the longest prefix of s that is at most n bytes and
+// doesn't split a multi-byte character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// readArtifactTool reads back artifacts saved by truncatingTool.
+type readArtifactTool struct {
+	store     persistence.Store
+	sessionID string
+	limit     int
+}
+
+type readArtifactInput struct {
+	Handle int64 `json:"handle"`
+	Offset int   `json:"offset"`
+}
+
+type readArtifactOutput struct {
+	Content    string  `json:"content,omitzero"`
+	TotalSize  int     `json:"totalSize,omitzero"`
+	NextOffset int     `json:"nextOffset,omitzero"`
+	Error      *string `json:"error,omitzero"`
+}
+
+func (t *readArtifactTool) Name() string {
+	return ReadArtifactToolName
+}
+
+func (t *readArtifactTool) Description() string {
+	return "Read more of a tool result that was too large to return in full. Pass the handle and offset from the truncation notice."
+}
+
+func (t *readArtifactTool) MCPJsonSchema() string {
+	return `{"name":"read_artifact","description":"Read more of a tool result that was too large to return in full.","inputSchema":{"type":"object","properties":{"handle":{"type":"integer","description":"Handle from the truncation notice"},"offset":{"type":"integer","description":"Byte offset to start reading from"}},"required":["handle","offset"]},"annotations":{"readOnlyHint":true}}`
+}
+
+func (t *readArtifactTool) Call(ctx context.Context, input string) string {
+	var out readArtifactOutput
+	var args readArtifactInput
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		errStr := "failed to parse input: " + err.Error()
+		out.Error = &errStr
+		return marshalReadArtifactOutput(out)
+	}
+
+	content, err := t.store.GetArtifact(t.sessionID, args.Handle)
+	if err != nil {
+		errStr := err.Error()
+		out.Error = &errStr
+		return marshalReadArtifactOutput(out)
+	}
+	if args.Offset < 0 || args.Offset > len(content) {
+		errStr := fmt.Sprintf("offset %d out of range (size %d)", args.Offset, len(content))
+		out.Error = &errStr
+		return marshalReadArtifactOutput(out)
+	}
+
+	out.Content = truncateUTF8(content[args.Offset:], t.limit)
+	out.TotalSize = len(content)
+	if end := args.Offset + len(out.Content); end < len(content) {
+		out.NextOffset = end
+	}
+	return marshalReadArtifactOutput(out)
+}
+
+func marshalReadArtifactOutput(out readArtifactOutput) string {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ToolDecision is the decision that let a tool call run, or stopped it.
+type ToolDecision string
+
+// ToolDecisionAllow means the tool call was permitted and ran.
+const ToolDecisionAllow ToolDecision = "allow"
+
+// ToolAuditEntry records one tool call for security review. Arguments and
+// results are recorded as SHA-256 hashes rather than in full, so the audit
+// log can be kept apart from the conversation without copying what may be
+// sensitive data into it.
+type ToolAuditEntry struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"sessionId"`
+	// Owner is the session's owner, set with WithOwner
+	Owner  string `json:"owner,omitzero"`
+	TurnID string `json:"turnId,omitzero"`
+	Tool   string `json:"tool"`
+	// ArgsHash and ResultHash are hex-encoded SHA-256 hashes of the JSON
+	// input, as the model sent it, and the output of the tool, before any
+	// truncation by WithMaxToolResultSize.
+	ArgsHash   string        `json:"argsHash"`
+	ResultHash string        `json:"resultHash"`
+	ResultSize int           `json:"resultSize"`
+	Duration   time.Duration `json:"durationNs"`
+	Decision   ToolDecision  `json:"decision"`
+}
+
+// ToolAuditor receives an entry for every tool call a session makes.
+// Implementations must be safe for concurrent use.
+type ToolAuditor interface {
+	AuditToolCall(ctx context.Context, entry ToolAuditEntry) error
+}
+
+// WithToolAuditor records every call to the session's tools, including
+// the built-in read_artifact tool, with auditor. Errors from the auditor
+// are logged, and don't stop the tool's result reaching the LLM.
+func WithToolAuditor(auditor ToolAuditor) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.toolAuditor = auditor
+	}
+}
+
+// JSONAuditor is a ToolAuditor that writes each entry to w as a line of
+// JSON, so the log can be queried with tools like jq.
+type JSONAuditor struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONAuditor returns a JSONAuditor that writes to w.
+func NewJSONAuditor(w io.Writer) *JSONAuditor {
+	return &JSONAuditor{w: w}
+}
+
+// AuditToolCall implements ToolAuditor.
+func (a *JSONAuditor) AuditToolCall(ctx context.Context, entry ToolAuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(data); err != nil {
+		return fmt.Errorf("write audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditedTool reports each call of the tool it wraps to an auditor.
+type auditedTool struct {
+	chat.Tool
+	auditor   ToolAuditor
+	clock     chat.Clock
+	sessionID string
+	owner     string
+}
+
+func (t *auditedTool) Call(ctx context.Context, input string) string {
+	decision := ToolDecisionAllow
+	ctx = context.WithValue(ctx, decisionKey{}, &decision)
+	start := t.clock.Now()
+	output := t.Tool.Call(ctx, input)
+	t.audit(ctx, start, input, output, decision)
+	return output
+}
+
+// CallWithImages audits the text of the result, passing the wrapped tool's
+// images through.
+func (t *auditedTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	it, ok := t.Tool.(chat.ImageTool)
+	if !ok {
+		return t.Call(ctx, input), nil
+	}
+	decision := ToolDecisionAllow
+	ctx = context.WithValue(ctx, decisionKey{}, &decision)
+	start := t.clock.Now()
+	output, images := it.CallWithImages(ctx, input)
+	t.audit(ctx, start, input, output, decision)
+	return output, images
+}
+
+// audit reports a call that started at start to the auditor.
+func (t *auditedTool) audit(ctx context.Context, start time.Time, input, output string, decision ToolDecision) {
+	entry := ToolAuditEntry{
+		Time:       start,
+		SessionID:  t.sessionID,
+		Owner:      t.owner,
+		TurnID:     chat.GetTurnID(ctx),
+		Tool:       t.Name(),
+		ArgsHash:   hashString(input),
+		ResultHash: hashString(output),
+		ResultSize: len(output),
+		Duration:   t.clock.Now().Sub(start),
+		Decision:   decision,
+	}
+	if err := t.auditor.AuditToolCall(ctx, entry); err != nil {
+		logger.WarnContext(ctx, "failed to audit tool call", "tool", t.Name(), "error", err)
+	}
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
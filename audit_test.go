@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// steppingClock advances by a second every time it is read.
+type steppingClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func readAuditEntries(t *testing.T, log string) []ToolAuditEntry {
+	t.Helper()
+	var entries []ToolAuditEntry
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	for scanner.Scan() {
+		var entry ToolAuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestSessionToolAuditor(t *testing.T) {
+	var log strings.Builder
+	client := &mockClient{}
+	clock := &steppingClock{now: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)}
+	session, err := NewSession(client, "You are a helpful assistant",
+		WithClock(clock),
+		WithOwner("alice"),
+		WithMaxToolResultSize(10),
+		WithToolAuditor(NewJSONAuditor(&log)),
+	)
+	require.NoError(t, err)
+
+	output := strings.Repeat("0123456789", 3)
+	require.NoError(t, session.RegisterTool(&mockTool{
+		name:   "big",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			return output
+		},
+	}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+
+	tempChat := client.chats[len(client.chats)-1]
+	ctx := chat.WithTurnID(context.Background(), "turn-1")
+	result := tempChat.tools["big"](ctx, `{"q": 1}`)
+	assert.True(t, strings.HasPrefix(result, "0123456789\n\n[Output truncated"))
+	artifact := tempChat.tools[ReadArtifactToolName](ctx, `{"handle": 1, "offset": 10}`)
+
+	entries := readAuditEntries(t, log.String())
+	require.Len(t, entries, 2)
+
+	big := entries[0]
+	assert.False(t, big.Time.IsZero())
+	assert.Equal(t, session.SessionID(), big.SessionID)
+	assert.Equal(t, "alice", big.Owner)
+	assert.Equal(t, "turn-1", big.TurnID)
+	assert.Equal(t, "big", big.Tool)
+	assert.Equal(t, sha256Hex(`{"q": 1}`), big.ArgsHash)
+	// The result is audited before it is truncated
+	assert.Equal(t, sha256Hex(output), big.ResultHash)
+	assert.Equal(t, len(output), big.ResultSize)
+	assert.Equal(t, time.Second, big.Duration)
+	assert.Equal(t, ToolDecisionAllow, big.Decision)
+
+	// Reading back the artifact is audited too
+	assert.Equal(t, ReadArtifactToolName, entries[1].Tool)
+	assert.Equal(t, sha256Hex(artifact), entries[1].ResultHash)
+
+	// The log holds hashes, not arguments or results
+	assert.NotContains(t, log.String(), "0123456789")
+}
+
+type failingAuditor struct{}
+
+func (failingAuditor) AuditToolCall(ctx context.Context, entry ToolAuditEntry) error {
+	return errors.New("disk full")
+}
+
+func TestAuditedTool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("auditor errors don't affect the result", func(t *testing.T) {
+		t.Parallel()
+		tool := &auditedTool{
+			Tool:    &mockTool{name: "echo", callFn: func(ctx context.Context, input string) string { return input }},
+			auditor: failingAuditor{},
+			clock:   chat.ClockFunc(time.Now),
+		}
+		assert.Equal(t, `{"a": 1}`, tool.Call(context.Background(), `{"a": 1}`))
+	})
+
+	t.Run("images pass through", func(t *testing.T) {
+		t.Parallel()
+		var log strings.Builder
+		img := chat.ImageContent{MediaType: "image/png", Data: []byte("png")}
+		tool := &auditedTool{
+			Tool:    &mockImageTool{mockTool: mockTool{name: "screenshot"}, output: `{"mediaType": "image/png"}`, image: img},
+			auditor: NewJSONAuditor(&log),
+			clock:   chat.ClockFunc(time.Now),
+		}
+		output, images := tool.CallWithImages(context.Background(), "{}")
+		assert.Equal(t, `{"mediaType": "image/png"}`, output)
+		assert.Equal(t, []chat.ImageContent{img}, images)
+
+		entries := readAuditEntries(t, log.String())
+		require.Len(t, entries, 1)
+		assert.Equal(t, sha256Hex(output), entries[0].ResultHash)
+	})
+}
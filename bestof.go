@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Scorer rates a candidate response to msg for BestOf; higher scores are
+// better. A candidate whose scoring fails is dropped.
+type Scorer func(ctx context.Context, msg, response chat.Message) (float64, error)
+
+// BestOf generates n independent responses to msg in parallel, each from its
+// own chat on the session's client and live history, and scores them with
+// score. The highest-scoring response is recorded in the session as if it
+// came from Message and returned, with the other successful candidates in
+// its Candidates, from best to worst. The tokens used by every candidate
+// count towards the session's usage.
+//
+// Responses aren't streamed, as the candidates would interleave. Each
+// candidate runs its own tool calls, so tools with side effects run up to n
+// times. BestOf fails only if every candidate fails, returning their errors
+// joined.
+func BestOf(ctx context.Context, s Session, msg chat.Message, n int, score Scorer, opts ...chat.Option) (chat.Message, error) {
+	if n < 1 {
+		return chat.Message{}, fmt.Errorf("BestOf: n must be at least 1, got %d", n)
+	}
+	if score == nil {
+		return chat.Message{}, errors.New("BestOf: score is nil")
+	}
+	bs, ok := s.(interface {
+		bestOf(ctx context.Context, msg chat.Message, n int, score Scorer, opts ...chat.Option) (chat.Message, error)
+	})
+	if !ok {
+		return chat.Message{}, fmt.Errorf("BestOf: unsupported session type %T", s)
+	}
+	return bs.bestOf(ctx, msg, n, score, opts...)
+}
+
+// candidate is one of BestOf's responses.
+type candidate struct {
+	chat     chat.Chat
+	response chat.Message
+	score    float64
+	err      error
+}
+
+func (s *session) bestOf(ctx context.Context, msg chat.Message, n int, score Scorer, opts ...chat.Option) (chat.Message, error) {
+	endTurn, err := s.beginTurn(ctx, opts)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	defer endTurn()
+
+	ctx = s.withTurnID(ctx)
+	inputModeration, err := s.moderate(ctx, ModerationInput, msg)
+	if err != nil {
+		return chat.Message{}, err
+	}
+
+	candidates := make([]candidate, n)
+	for i := range candidates {
+		if candidates[i].chat, err = s.prepareForMessage(ctx, msg); err != nil {
+			return chat.Message{}, err
+		}
+	}
+
+	opts = append(s.defaultOptions, opts...)
+	opts = append(opts, chat.WithStreamingCb(nil))
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *candidate) {
+			defer wg.Done()
+			if c.response, c.err = c.chat.Message(ctx, msg, opts...); c.err != nil {
+				return
+			}
+			if c.score, c.err = score(ctx, msg, c.response); c.err != nil {
+				c.err = fmt.Errorf("scoring: %w", c.err)
+			}
+		}(&candidates[i])
+	}
+	wg.Wait()
+
+	var errs []error
+	ranked := make([]*candidate, 0, n)
+	for i := range candidates {
+		if candidates[i].err != nil {
+			errs = append(errs, candidates[i].err)
+			continue
+		}
+		ranked = append(ranked, &candidates[i])
+	}
+	if len(ranked) == 0 {
+		s.chargeDiscarded(candidates)
+		return chat.Message{}, fmt.Errorf("all %d candidates failed: %w", n, errors.Join(errs...))
+	}
+	if len(errs) > 0 {
+		logger.WarnContext(ctx, "some BestOf candidates failed", "session", s.sessionID, "failed", len(errs), "error", errors.Join(errs...))
+	}
+	slices.SortStableFunc(ranked, func(a, b *candidate) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	best := ranked[0]
+	discarded := make([]candidate, 0, n-1)
+	for i := range candidates {
+		if &candidates[i] != best {
+			discarded = append(discarded, candidates[i])
+		}
+	}
+	s.chargeDiscarded(discarded)
+
+	outputModeration, err := s.moderate(ctx, ModerationOutput, best.response)
+	if err != nil {
+		return chat.Message{}, err
+	}
+
+	reqOpts := chat.ApplyOptions(opts...)
+	s.trackResponse(ctx, best.chat, best.response, exchange{
+		user:             reqOpts.User,
+		options:          requestOptions(best.chat, reqOpts),
+		inputModeration:  inputModeration,
+		outputModeration: outputModeration,
+	})
+
+	response := best.response
+	response.Candidates = nil
+	for _, c := range ranked[1:] {
+		response.Candidates = append(response.Candidates, c.response)
+	}
+	return response, nil
+}
+
+// chargeDiscarded counts the tokens used by candidates that weren't
+// recorded towards the session's usage.
+func (s *session) chargeDiscarded(candidates []candidate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range candidates {
+		usage, err := c.chat.TokenUsage()
+		if err != nil {
+			logger.Warn("failed to get token usage from LLM", "error", err)
+			continue
+		}
+		rounds := usage.Rounds
+		if len(rounds) == 0 && usage.LastMessage.TotalTokens > 0 {
+			rounds = []chat.TokenUsageDetails{usage.LastMessage}
+		}
+		for _, round := range rounds {
+			s.cumulativeTokens += round.TotalTokens
+		}
+		s.addUsageLocked(rounds)
+	}
+	s.saveMetricsLocked()
+}
+
+// NewJudge returns a Scorer that asks an LLM on client to rate each response
+// from 0 to 10 against criteria, such as "correctness and concision". A
+// cheaper model than the session's is usually good enough.
+func NewJudge(client chat.Client, criteria string) Scorer {
+	return func(ctx context.Context, msg, response chat.Message) (float64, error) {
+		prompt := fmt.Sprintf(judgePrompt, criteria, msg.GetText(), response.GetText())
+		judgeChat := client.NewChat("You are an impartial judge of the quality of AI assistant responses.")
+		verdict, err := judgeChat.Message(ctx, chat.UserMessage(prompt))
+		if err != nil {
+			return 0, fmt.Errorf("judge failed: %w", err)
+		}
+		return parseJudgeScore(verdict.GetText())
+	}
+}
+
+const judgePrompt = `Rate the following response to the user's message from 0 to 10, judging it on: %s
+
+User's message:
+%s
+
+Response:
+%s
+
+Reply with only the rating, as a number.`
+
+var judgeScoreRE = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// parseJudgeScore extracts the rating from a judge's reply, tolerating text
+// around the number.
+func parseJudgeScore(reply string) (float64, error) {
+	match := judgeScoreRE.FindString(reply)
+	if match == "" {
+		return 0, fmt.Errorf("judge reply has no rating: %q", strings.TrimSpace(reply))
+	}
+	return strconv.ParseFloat(match, 64)
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// scriptedChat replies with the next of its client's replies.
+type scriptedChat struct {
+	mockChat
+	client *scriptedClient
+}
+
+func (c *scriptedChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.lastOptions = chat.ApplyOptions(opts...)
+	response := chat.AssistantMessage(c.client.next())
+	c.messages = append(c.messages, msg, response)
+	c.tokenUsage.LastMessage = chat.TokenUsageDetails{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}
+	return response, nil
+}
+
+type scriptedClient struct {
+	mu      sync.Mutex
+	replies []string
+	chats   []*scriptedChat
+}
+
+func (c *scriptedClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc := &scriptedChat{client: c}
+	sc.systemPrompt = systemPrompt
+	sc.messages = append([]chat.Message{}, initialMsgs...)
+	sc.tools = make(map[string]func(context.Context, string) string)
+	c.chats = append(c.chats, sc)
+	return sc
+}
+
+func (c *scriptedClient) next() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return reply
+}
+
+func scoreByLength(ctx context.Context, msg, response chat.Message) (float64, error) {
+	return float64(len(response.GetText())), nil
+}
+
+func TestBestOf(t *testing.T) {
+	client := &scriptedClient{replies: []string{"a", "bbb", "cc"}}
+	session, err := NewSession(client, "You are helpful.")
+	require.NoError(t, err)
+
+	var streamed bool
+	resp, err := BestOf(context.Background(), session, chat.UserMessage("Hello"), 3, scoreByLength,
+		chat.WithStreamingCb(func(chat.StreamEvent) error {
+			streamed = true
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "bbb", resp.GetText())
+	assert.Equal(t, []chat.Message{chat.AssistantMessage("cc"), chat.AssistantMessage("a")}, resp.Candidates)
+	assert.False(t, streamed)
+	for _, sc := range client.chats {
+		assert.Nil(t, sc.lastOptions.StreamingCb)
+	}
+
+	_, history := session.History()
+	require.Len(t, history, 2)
+	assert.Equal(t, "Hello", history[0].GetText())
+	assert.Equal(t, "bbb", history[1].GetText())
+	assert.Equal(t, 45, session.Metrics().CumulativeTokens)
+}
+
+func TestBestOfManagedSession(t *testing.T) {
+	client := &scriptedClient{replies: []string{"a", "bb"}}
+	m := NewManager(persistence.NewMemoryStore(), func(string) (chat.Client, error) {
+		return client, nil
+	}, "")
+
+	session, err := m.NewSession("alice")
+	require.NoError(t, err)
+
+	resp, err := BestOf(context.Background(), session, chat.UserMessage("Hello"), 2, scoreByLength)
+	require.NoError(t, err)
+	assert.Equal(t, "bb", resp.GetText())
+	for _, sc := range client.chats[len(client.chats)-2:] {
+		assert.Equal(t, "alice", sc.lastOptions.User)
+	}
+}
+
+func TestBestOfFailures(t *testing.T) {
+	errScore := errors.New("unscorable")
+	scoreAllButA := func(ctx context.Context, msg, response chat.Message) (float64, error) {
+		if response.GetText() == "a" {
+			return 0, errScore
+		}
+		return 1, nil
+	}
+
+	t.Run("SomeFail", func(t *testing.T) {
+		client := &scriptedClient{replies: []string{"a", "b"}}
+		session, err := NewSession(client, "")
+		require.NoError(t, err)
+
+		resp, err := BestOf(context.Background(), session, chat.UserMessage("Hello"), 2, scoreAllButA)
+		require.NoError(t, err)
+		assert.Equal(t, "b", resp.GetText())
+		assert.Empty(t, resp.Candidates)
+	})
+
+	t.Run("AllFail", func(t *testing.T) {
+		client := &scriptedClient{replies: []string{"a", "a"}}
+		session, err := NewSession(client, "")
+		require.NoError(t, err)
+
+		_, err = BestOf(context.Background(), session, chat.UserMessage("Hello"), 2, scoreAllButA)
+		require.ErrorIs(t, err, errScore)
+
+		_, history := session.History()
+		assert.Empty(t, history)
+		assert.Equal(t, 30, session.Metrics().CumulativeTokens)
+	})
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		session, err := NewSession(&scriptedClient{}, "")
+		require.NoError(t, err)
+
+		_, err = BestOf(context.Background(), session, chat.UserMessage("Hello"), 0, scoreByLength)
+		require.Error(t, err)
+		_, err = BestOf(context.Background(), session, chat.UserMessage("Hello"), 2, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestNewJudge(t *testing.T) {
+	client := &scriptedClient{replies: []string{"Rating: 7.5/10"}}
+	judge := NewJudge(client, "helpfulness")
+
+	score, err := judge(context.Background(), chat.UserMessage("Hello"), chat.AssistantMessage("Hi there"))
+	require.NoError(t, err)
+	assert.Equal(t, 7.5, score)
+
+	require.Len(t, client.chats, 1)
+	prompt := client.chats[0].messages[0].GetText()
+	assert.Contains(t, prompt, "helpfulness")
+	assert.Contains(t, prompt, "Hi there")
+}
+
+func TestParseJudgeScore(t *testing.T) {
+	for reply, want := range map[string]float64{
+		"8":             8,
+		" 3.5\n":        3.5,
+		"I'd give it 9": 9,
+	} {
+		score, err := parseJudgeScore(reply)
+		require.NoError(t, err)
+		assert.Equal(t, want, score)
+	}
+
+	_, err := parseJudgeScore("excellent")
+	require.Error(t, err)
+}
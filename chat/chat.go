@@ -2,8 +2,12 @@ package chat
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/bpowers/go-agent/schema"
 )
@@ -30,6 +34,15 @@ type ToolResult struct {
 	DisplayContent string `json:"displayContent,omitzero"`
 	// Error indicates if the tool execution failed.
 	Error string `json:"error,omitzero"`
+	// ErrorCode is an optional machine-readable classification of Error,
+	// such as "not_found" or "invalid_argument".
+	ErrorCode string `json:"errorCode,omitzero"`
+	// Retryable indicates whether calling the tool again with different
+	// arguments might succeed. It is only meaningful when ErrorCode is set.
+	Retryable bool `json:"retryable,omitzero"`
+	// Images are sent to the model with Content, from tools that implement
+	// ImageTool.
+	Images []ImageContent `json:"images,omitzero"`
 }
 
 // StreamEventType represents the type of content in a streaming event.
@@ -46,6 +59,11 @@ const (
 	StreamEventTypeRedactedThinking StreamEventType = "redacted_thinking"
 	// StreamEventTypeToolCall indicates a tool is being invoked.
 	StreamEventTypeToolCall StreamEventType = "tool_call"
+	// StreamEventTypeToolCallDelta carries a fragment of a tool call's arguments as
+	// they are generated. ToolCalls holds the ID and name of the call, without
+	// arguments, and Content holds the next piece of (not yet valid) JSON.
+	// A StreamEventTypeToolCall event with the complete arguments follows.
+	StreamEventTypeToolCallDelta StreamEventType = "tool_call_delta"
 	// StreamEventTypeToolResult indicates the result of a tool execution.
 	StreamEventTypeToolResult StreamEventType = "tool_result"
 	// StreamEventTypeServerToolUse indicates a server-side tool invocation.
@@ -56,8 +74,50 @@ const (
 	StreamEventTypeCitation StreamEventType = "citation"
 	// StreamEventTypeDone indicates the stream has completed.
 	StreamEventTypeDone StreamEventType = "done"
+	// StreamEventTypeRoundStart indicates a new request to the LLM is starting.
+	// A single Message call makes one request per round of tool calls.
+	StreamEventTypeRoundStart StreamEventType = "round_start"
+	// StreamEventTypeRoundEnd indicates the LLM finished responding to a round's request.
+	// It is not sent if the request fails.
+	StreamEventTypeRoundEnd StreamEventType = "round_end"
+	// StreamEventTypeProgress reports that a Message call is still running after a period
+	// without any other event; see WithProgressEvents. Progress holds the details.
+	StreamEventTypeProgress StreamEventType = "progress"
 )
 
+// RoundReason explains why a round started or ended.
+type RoundReason string
+
+const (
+	// RoundReasonUserMessage starts the first round, which sends the user's message.
+	RoundReasonUserMessage RoundReason = "user_message"
+	// RoundReasonToolResults starts a round that sends tool results back to the LLM.
+	RoundReasonToolResults RoundReason = "tool_results"
+	// RoundReasonToolCalls ends a round in which the LLM called tools.
+	RoundReasonToolCalls RoundReason = "tool_calls"
+	// RoundReasonComplete ends the final round, in which the LLM responded without calling tools.
+	RoundReasonComplete RoundReason = "complete"
+)
+
+// RoundStatus identifies the round a round start or end event belongs to.
+type RoundStatus struct {
+	// Index is the zero-based round number within a Message call.
+	Index int `json:"index"`
+	// Reason is why the round started or ended.
+	Reason RoundReason `json:"reason"`
+}
+
+// ProgressStatus describes a Message call that has been silent for a while.
+type ProgressStatus struct {
+	// Elapsed is the time since the Message call started.
+	Elapsed time.Duration `json:"elapsed"`
+	// Idle is the time since the last event other than a progress event.
+	Idle time.Duration `json:"idle"`
+	// Tokens estimates the tokens streamed so far, from the length of the
+	// content, thinking, and tool call argument deltas.
+	Tokens int `json:"tokens"`
+}
+
 // StreamEvent represents a chunk of data in a streaming response.
 type StreamEvent struct {
 	// Type indicates what kind of event this is.
@@ -72,6 +132,13 @@ type StreamEvent struct {
 	ToolResults []ToolResult `json:"toolResults,omitzero"`
 	// FinishReason indicates why the stream ended (if applicable).
 	FinishReason string `json:"finishReason,omitzero"`
+	// Round contains the round index and reason for round start and end events.
+	Round *RoundStatus `json:"round,omitzero"`
+	// Progress contains the elapsed and idle time for progress events.
+	Progress *ProgressStatus `json:"progress,omitzero"`
+	// TurnID is the ID of the turn the event belongs to, if one was attached to the
+	// context with WithTurnID.
+	TurnID string `json:"turnID,omitzero"`
 }
 
 // ThinkingStatus represents the status of model reasoning/thinking.
@@ -90,6 +157,12 @@ type ThinkingContent struct {
 	Text string `json:"text,omitzero"`
 	// Signature contains the encrypted signature for thinking block verification.
 	Signature string `json:"signature,omitzero"`
+	// RedactedData contains encrypted thinking content when flagged by safety.
+	RedactedData string `json:"redactedData,omitzero"`
+	// Provider identifies the LLM provider that produced this thinking (e.g. "claude").
+	// Signatures are only meaningful to the provider that issued them, so providers
+	// only send thinking back to the model when it originated with them.
+	Provider string `json:"provider,omitzero"`
 }
 
 // StreamCallback is called for each streaming event.
@@ -130,6 +203,10 @@ type TokenUsage struct {
 	LastMessage TokenUsageDetails `json:"lastMessage"`
 	// Cumulative contains total token counts for the entire conversation
 	Cumulative TokenUsageDetails `json:"cumulative"`
+	// Rounds contains token counts for each request made to the LLM during the
+	// most recent message exchange, in order. There is more than one round
+	// when the LLM called tools.
+	Rounds []TokenUsageDetails `json:"rounds,omitzero"`
 }
 
 // TokenLimits represents the token limits for a given model
@@ -162,10 +239,24 @@ type Tool interface {
 	Call(ctx context.Context, input string) string
 }
 
+// ImageTool is optionally implemented by Tools whose results include images,
+// such as screenshots, which are sent to the model as image content rather
+// than encoded in the result's text.
+type ImageTool interface {
+	Tool
+	// CallWithImages is called instead of Call. It returns the result Call
+	// would, along with the images to send with it.
+	CallWithImages(ctx context.Context, input string) (string, []ImageContent)
+}
+
 // Chat is the stateful interface used to interact with an LLM in a turn-based way (including single-turn use).
 type Chat interface {
 	// Message sends a new message, as well as all previous messages, to an LLM returning the result.
 	// Use WithStreamingCb option to receive streaming events during the call.
+	//
+	// Message is safe to call concurrently, but calls on the same Chat are serialized: each call
+	// sees the history produced by the calls before it. A call waits in line until earlier calls
+	// finish, its context is done, or the timeout set by WithQueueTimeout expires (ErrBusy).
 	Message(ctx context.Context, msg Message, opts ...Option) (Message, error)
 	// History extracts the system prompt and history up to this point for a chat for storage and later Chat object re-initialization.
 	History() (systemPrompt string, msgs []Message)
@@ -197,6 +288,58 @@ type Chat interface {
 	ListTools() []string
 }
 
+// ContextLimiter is optionally implemented by Chats that know the size of
+// their model's context window. MaxTokens reports the output limit, which is
+// not a useful measure of how full a conversation is.
+type ContextLimiter interface {
+	// ContextLimit returns the maximum number of tokens in the model's context window,
+	// or 0 if it is unknown.
+	ContextLimit() int
+}
+
+// RequestInfo describes one HTTP request made to an LLM provider, for
+// correlating slow or failed turns with the provider's logs and status pages.
+type RequestInfo struct {
+	// RequestID is the provider's ID for the request, from its response
+	// headers, which its support staff can look up.
+	RequestID string `json:"requestId,omitzero"`
+	// ResponseID is the ID of the response in the provider's API, such as
+	// a chat completion or message ID.
+	ResponseID string `json:"responseId,omitzero"`
+	// Model is the model that served the request, as reported in the
+	// response. It can be a specific version of the model requested, such
+	// as a dated snapshot of an alias.
+	Model string `json:"model,omitzero"`
+	// StatusCode is the HTTP status code, or 0 if no response was received.
+	StatusCode int `json:"statusCode,omitzero"`
+	// Latency is how long the request took, including reading a streamed
+	// response.
+	Latency time.Duration `json:"latency,omitzero"`
+}
+
+// RequestReporter is optionally implemented by Chats that record the HTTP
+// requests they make.
+type RequestReporter interface {
+	// LastRequests returns the requests made by the most recent call to
+	// Message, in order, including requests that were retried.
+	LastRequests() []RequestInfo
+}
+
+// SystemPromptSetter is optionally implemented by Chats whose system prompt
+// can be changed mid-conversation.
+type SystemPromptSetter interface {
+	// SetSystemPrompt replaces the system prompt. It takes effect on the next call to Message;
+	// the conversation history is kept.
+	SetSystemPrompt(ctx context.Context, prompt string) error
+}
+
+// ModelReporter is optionally implemented by Chats that know the name of the
+// model they send requests to.
+type ModelReporter interface {
+	// Model returns the name of the model the next request will be sent to.
+	Model() string
+}
+
 // Client is used to create new chats that talk to a specific LLM hosted on a particular service (like Ollama, Anthropic, OpenAI, etc).
 type Client interface {
 	// NewChat returns a Chat instance configured for the current LLM with a given system prompt and initial messages.
@@ -220,14 +363,44 @@ type Content struct {
 
 	// System reminder content (ephemeral context added by tooling, filtered when replaying history)
 	SystemReminder string `json:"systemReminder,omitzero"`
+
+	// Image content, in user messages
+	Image *ImageContent `json:"image,omitzero"`
+}
+
+// ImageContent is an image sent to the model.
+type ImageContent struct {
+	// MediaType is the image's MIME type, such as "image/png".
+	MediaType string `json:"mediaType"`
+	// Data is the encoded image, in the format MediaType names.
+	Data []byte `json:"data"`
+}
+
+// DataURL returns the image as a base64 data URL.
+func (c ImageContent) DataURL() string {
+	return "data:" + c.MediaType + ";base64," + base64.StdEncoding.EncodeToString(c.Data)
 }
 
 // Message represents a message to or from an LLM.
 type Message struct {
 	Role     Role      `json:"role,omitzero"`
 	Contents []Content `json:"contents,omitzero"`
+
+	// Candidates are the alternative responses requested with WithCandidates, in the
+	// order the provider returned them. They are only set on messages returned by
+	// Chat.Message, and aren't kept in the history.
+	Candidates []Message `json:"candidates,omitzero"`
+
+	// Model is the model the provider reports producing the message, which
+	// can be a specific version of the model requested. Like Candidates,
+	// it is only set on messages returned by Chat.Message.
+	Model string `json:"model,omitzero"`
 }
 
+// ErrBusy is returned (wrapped) by Message when the call couldn't start because another
+// Message call on the same Chat was still in progress when WithQueueTimeout expired.
+var ErrBusy = errors.New("chat is busy with another message")
+
 // requestOpts is private so that Option can only be implemented by _this_ package.
 type requestOpts struct {
 	temperature     *float64
@@ -235,6 +408,21 @@ type requestOpts struct {
 	reasoningEffort string
 	responseFormat  *JsonSchema
 	streamingCb     StreamCallback
+	systemPrompt    string
+	queueTimeout    time.Duration
+	coalescing      time.Duration
+	user            string
+	validators      []func(Message) error
+	retries         *int
+	jsonMode        bool
+	assistantPrefix string
+	candidates      int
+	prediction      string
+	contextCache    *ContextCache
+	dryRun          bool
+	streamResumes   int
+	progressAfter   time.Duration
+	textTools       bool
 }
 
 // Options shouldn't be used directly, but is public so that LLM implementations can reference it.
@@ -243,7 +431,45 @@ type Options struct {
 	MaxTokens       int
 	ReasoningEffort string
 	ResponseFormat  *JsonSchema
-	StreamingCb     StreamCallback
+	// JSONMode requests a response that is a JSON object, without a schema.
+	JSONMode bool
+	// AssistantPrefix is text the response should begin with.
+	AssistantPrefix string
+	// Candidates is the number of responses to generate; 0 or 1 means one.
+	Candidates int
+	// Prediction is text the response is expected to largely repeat; see
+	// WithPrediction.
+	Prediction string
+	// ContextCache, if non-nil, is the cached context the request begins
+	// with; see WithContextCache.
+	ContextCache *ContextCache
+	// DryRun returns the built request as a *DryRunRequest instead of
+	// sending it; see WithDryRun.
+	DryRun bool
+	// StreamResumes is how many times a response whose stream fails partway
+	// through is resumed; see WithStreamResume.
+	StreamResumes int
+	// StreamingCb receives streaming events. If WithStreamCoalescing was
+	// given, it coalesces content and thinking deltas before passing them
+	// to the user's callback.
+	StreamingCb StreamCallback
+	// ProgressAfter, if positive, is how long the stream may be silent
+	// before a progress event is sent; see WithProgressEvents.
+	ProgressAfter time.Duration
+	// SystemPromptOverride, if non-empty, replaces the chat's system prompt for this request only.
+	SystemPromptOverride string
+	// QueueTimeout, if positive, limits how long Message waits for an in-progress call to finish.
+	QueueTimeout time.Duration
+	// User, if non-empty, identifies the end user the request is made on behalf of.
+	User string
+	// Validator, if non-nil, checks the final response; see WithValidator.
+	Validator func(Message) error
+	// ValidationRetries is how many times to re-ask the model when Validator fails.
+	ValidationRetries int
+	// TextToolProtocol describes tools in the system prompt and parses calls
+	// from the response text instead of using native function calling; see
+	// WithTextToolProtocol.
+	TextToolProtocol bool
 }
 
 // JsonSchema represents a requested schema that an LLM's response should conform to.
@@ -290,6 +516,106 @@ func WithResponseFormat(name string, strict bool, schema *schema.JSON) Option {
 	}
 }
 
+// WithJSONMode asks for a response that is a single JSON object, without enforcing a schema;
+// use WithResponseFormat when the response must match a schema. It maps to OpenAI's json_object
+// response format and Gemini's JSON response MIME type (when no tools are registered, as Gemini
+// can't combine the two). Every provider also gets an instruction in the system prompt, which
+// is all Claude, having no JSON mode, relies on.
+func WithJSONMode() Option {
+	return func(opts *requestOpts) {
+		opts.jsonMode = true
+	}
+}
+
+// WithAssistantPrefix steers the response by having it begin with text, such as "```json" to
+// force a fenced JSON block or "{" to force a bare JSON object. Claude is sent text as the start
+// of its turn (prefilling) and continues from it; the returned message includes text followed by
+// the continuation. Other providers are instructed in the system prompt to begin their response
+// with text, which they usually but not always follow. Claude rejects prefixes ending in
+// whitespace, so trailing whitespace is trimmed before it is sent.
+func WithAssistantPrefix(text string) Option {
+	return func(opts *requestOpts) {
+		opts.assistantPrefix = text
+	}
+}
+
+// WithCandidates asks the model for n independent responses, for self-consistency checks or
+// best-of-n selection. The first response is returned, streamed, and kept in the history; the
+// other n-1 are in the returned message's Candidates. It maps to OpenAI's n (using the Chat
+// Completions API, as the Responses API has no equivalent) and Gemini's candidateCount. Claude
+// has no equivalent and returns a single response. If the first response calls tools, the
+// other candidates are discarded and the tool call rounds generate a single response.
+func WithCandidates(n int) Option {
+	return func(opts *requestOpts) {
+		opts.candidates = n
+	}
+}
+
+// WithPrediction speeds up responses that largely repeat known text, such as a file resent
+// with a small edit, by giving the model text as a prediction of its response: the parts of the
+// response that match it are generated much faster. It maps to OpenAI's predicted outputs (using
+// the Chat Completions API, as the Responses API has no equivalent), which can't be combined
+// with tools, so the prediction is ignored when tools are registered. Other providers have no
+// equivalent and ignore it. Predicted tokens the response doesn't use are billed as output.
+func WithPrediction(text string) Option {
+	return func(opts *requestOpts) {
+		opts.prediction = text
+	}
+}
+
+// WithContextCache sends a request with cache's system prompt, which replaces the chat's own
+// and any WithSystemPromptOverride, read from the provider's cache rather than processed again.
+// On Gemini the request uses the cached content, which holds the tools, so the chat's tools
+// should match those the cache was created with. On OpenAI the request is routed with the
+// cache's prompt cache key, improving the odds that its prefix is cached. Other providers
+// just use the system prompt.
+func WithContextCache(cache *ContextCache) Option {
+	return func(opts *requestOpts) {
+		opts.contextCache = cache
+	}
+}
+
+// WithStreamResume resumes a response whose stream fails partway through, such as from a
+// dropped connection, up to attempts times. Rather than starting over, the request is retried
+// with the text received so far as an assistant prefix (see WithAssistantPrefix): Claude
+// continues from it without generating it again, while other providers are instructed to begin
+// with it. Text the streaming callback has already received isn't sent to it again. Streams
+// that fail before any text arrives, or after the model called tools, aren't resumed, nor are
+// failures caused by the context or the streaming callback.
+func WithStreamResume(attempts int) Option {
+	return func(opts *requestOpts) {
+		opts.streamResumes = attempts
+	}
+}
+
+// WithProgressEvents sends a StreamEventTypeProgress event to the streaming callback when a
+// Message call has gone after without any other event, and again each time after passes while
+// the silence lasts. UIs can use them to show that a request is still waiting, which is
+// different from the model thinking, and operators can alert on stalled requests. Progress
+// events come from another goroutine, but the callback is never called concurrently, nor after
+// Message returns. If the callback returns an error for a progress event, no more are sent
+// and the Message call fails with the error when the next event arrives.
+func WithProgressEvents(after time.Duration) Option {
+	return func(opts *requestOpts) {
+		opts.progressAfter = after
+	}
+}
+
+// WithTextToolProtocol calls tools through the text of the conversation rather than the
+// provider's function calling: the registered tools are described in the system prompt, the
+// model calls them by writing <tool_call> blocks holding a JSON object with the tool's name and
+// arguments, and the results are sent back as a user message of <tool_result> blocks. It works
+// with any model that follows instructions, which helps with providers whose function calling
+// is broken or missing, and for comparing the two approaches. The blocks stay in the chat's
+// history and in the streamed content, and tool call and result events are streamed as with
+// native calls. It can't be combined with WithContextCache, whose system prompt can't be
+// extended.
+func WithTextToolProtocol() Option {
+	return func(opts *requestOpts) {
+		opts.textTools = true
+	}
+}
+
 // WithStreamingCb specifies a callback to receive streaming events during message processing.
 func WithStreamingCb(callback StreamCallback) Option {
 	return func(opts *requestOpts) {
@@ -297,19 +623,139 @@ func WithStreamingCb(callback StreamCallback) Option {
 	}
 }
 
+// WithSystemPromptOverride uses text as the system prompt for a single message, such as a
+// specialized extraction turn inside a general-purpose chat. The chat's own system prompt
+// is unchanged and is used again for later messages.
+func WithSystemPromptOverride(text string) Option {
+	return func(opts *requestOpts) {
+		opts.systemPrompt = text
+	}
+}
+
+// WithQueueTimeout limits how long Message waits for another Message call on the same Chat
+// to finish before starting. If the timeout expires, Message returns an error wrapping
+// ErrBusy. By default Message waits until its context is done.
+func WithQueueTimeout(timeout time.Duration) Option {
+	return func(opts *requestOpts) {
+		opts.queueTimeout = timeout
+	}
+}
+
+// WithStreamCoalescing batches content and thinking deltas passed to the streaming callback,
+// so it is called at most about once per interval per run of deltas rather than once per token.
+// This reduces callback overhead and websocket frame spam for server frontends. The first delta
+// of each run is delivered immediately, and pending deltas are delivered before any other event,
+// such as a tool call or the end of a round, so perceived latency stays low.
+func WithStreamCoalescing(interval time.Duration) Option {
+	return func(opts *requestOpts) {
+		opts.coalescing = interval
+	}
+}
+
+// WithUser identifies the end user a request is made on behalf of, for the provider's abuse
+// monitoring. It is sent as OpenAI's user field and Anthropic's metadata.user_id; Gemini has
+// no equivalent. id should be opaque, such as a hash or UUID, rather than a name or email
+// address. Sessions also record it on the records of the exchange for auditing.
+func WithUser(id string) Option {
+	return func(opts *requestOpts) {
+		opts.user = id
+	}
+}
+
+// DefaultValidationRetries is how many times the model is re-asked for a valid response
+// unless WithValidationRetries says otherwise.
+const DefaultValidationRetries = 2
+
+// WithValidator checks the final response to a message with validate, for requirements a
+// response format can't express, such as "must include a citation". If validate returns an
+// error, the model is sent a follow-up user message describing the error and asked to try
+// again, up to WithValidationRetries times (DefaultValidationRetries by default). The rejected
+// responses and follow-ups stay in the chat's history. If no attempt passes, Message returns
+// the last response along with a *ValidationError. Multiple validators must all pass.
+func WithValidator(validate func(Message) error) Option {
+	return func(opts *requestOpts) {
+		opts.validators = append(opts.validators, validate)
+	}
+}
+
+// WithValidationRetries sets how many times the model is re-asked when a WithValidator
+// validator rejects its response. Zero means the first response is only checked.
+func WithValidationRetries(n int) Option {
+	return func(opts *requestOpts) {
+		opts.retries = &n
+	}
+}
+
+// ValidationError is returned by Message when the response still fails a WithValidator
+// validator after all retries.
+type ValidationError struct {
+	// Attempts is the number of responses the model gave.
+	Attempts int
+	// Response is the last, rejected response.
+	Response Message
+	// Err is the validator's error for the last response.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("response failed validation after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 // ApplyOptions is for use by LLM implementations, not users of the library.
 func ApplyOptions(opts ...Option) Options {
 	var options requestOpts
 	for _, opt := range opts {
 		opt(&options)
 	}
+	if options.streamingCb != nil && options.coalescing > 0 {
+		options.streamingCb = coalesceStream(options.streamingCb, options.coalescing, time.Now)
+	}
 
-	return Options{
+	result := Options{
 		Temperature:     options.temperature,
 		MaxTokens:       options.maxTokens,
 		ReasoningEffort: options.reasoningEffort,
 		ResponseFormat:  options.responseFormat,
+		JSONMode:        options.jsonMode,
+		AssistantPrefix: options.assistantPrefix,
+		Candidates:      options.candidates,
+		Prediction:      options.prediction,
+		ContextCache:    options.contextCache,
+		DryRun:          options.dryRun,
+		StreamResumes:   max(0, options.streamResumes),
 		StreamingCb:     options.streamingCb,
+		ProgressAfter:   options.progressAfter,
+
+		SystemPromptOverride: options.systemPrompt,
+		QueueTimeout:         options.queueTimeout,
+		User:                 options.user,
+		Validator:            joinValidators(options.validators),
+		ValidationRetries:    DefaultValidationRetries,
+		TextToolProtocol:     options.textTools,
+	}
+	if options.retries != nil {
+		result.ValidationRetries = max(0, *options.retries)
+	}
+	return result
+}
+
+// joinValidators returns a validator that runs each of validators in turn, or nil if there
+// are none.
+func joinValidators(validators []func(Message) error) func(Message) error {
+	if len(validators) == 0 {
+		return nil
+	}
+	return func(msg Message) error {
+		for _, validate := range validators {
+			if err := validate(msg); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 }
 
@@ -377,6 +823,12 @@ func (m *Message) AddToolResult(tr ToolResult) *Message {
 	return m
 }
 
+// AddImage adds image content to the message.
+func (m *Message) AddImage(img ImageContent) *Message {
+	m.Contents = append(m.Contents, Content{Image: &img})
+	return m
+}
+
 // AddThinking adds thinking/reasoning content to the message.
 func (m *Message) AddThinking(text, signature string) *Message {
 	m.Contents = append(m.Contents, Content{
@@ -428,6 +880,18 @@ func (m Message) GetToolResults() []ToolResult {
 	return results
 }
 
+// GetImages returns all images in the message, not including those in tool
+// results.
+func (m Message) GetImages() []ImageContent {
+	var images []ImageContent
+	for _, c := range m.Contents {
+		if c.Image != nil {
+			images = append(images, *c.Image)
+		}
+	}
+	return images
+}
+
 // IsEmpty returns true if the message has no content.
 func (m Message) IsEmpty() bool {
 	return len(m.Contents) == 0
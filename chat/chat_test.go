@@ -2,10 +2,13 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStreamEventTypes(t *testing.T) {
@@ -171,6 +174,24 @@ func TestMessage(t *testing.T) {
 	}
 }
 
+func TestMessageImages(t *testing.T) {
+	t.Parallel()
+
+	img := ImageContent{MediaType: "image/png", Data: []byte("png")}
+	msg := UserMessage("What is in this screenshot?")
+	msg.AddImage(img)
+	assert.Equal(t, "What is in this screenshot?", msg.GetText())
+	assert.Equal(t, []ImageContent{img}, msg.GetImages())
+	assert.Equal(t, "data:image/png;base64,cG5n", img.DataURL())
+
+	// Images survive persistence as JSON
+	data, err := json.Marshal(msg)
+	require.NoError(t, err)
+	var decoded Message
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, msg, decoded)
+}
+
 func TestOptions(t *testing.T) {
 	t.Parallel()
 	t.Run("WithTemperature", func(t *testing.T) {
@@ -192,6 +213,75 @@ func TestOptions(t *testing.T) {
 		assert.Equal(t, "high", opts.ReasoningEffort)
 	})
 
+	t.Run("WithSystemPromptOverride", func(t *testing.T) {
+		t.Parallel()
+		opts := ApplyOptions(WithSystemPromptOverride("Extract the dates"))
+		assert.Equal(t, "Extract the dates", opts.SystemPromptOverride)
+	})
+
+	t.Run("WithQueueTimeout", func(t *testing.T) {
+		t.Parallel()
+		opts := ApplyOptions(WithQueueTimeout(5 * time.Second))
+		assert.Equal(t, 5*time.Second, opts.QueueTimeout)
+	})
+
+	t.Run("WithUser", func(t *testing.T) {
+		t.Parallel()
+		opts := ApplyOptions(WithUser("user-1234"))
+		assert.Equal(t, "user-1234", opts.User)
+	})
+
+	t.Run("WithJSONMode", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, ApplyOptions(WithJSONMode()).JSONMode)
+		assert.False(t, ApplyOptions().JSONMode)
+	})
+
+	t.Run("WithAssistantPrefix", func(t *testing.T) {
+		t.Parallel()
+		opts := ApplyOptions(WithAssistantPrefix("```json"))
+		assert.Equal(t, "```json", opts.AssistantPrefix)
+	})
+
+	t.Run("WithCandidates", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 3, ApplyOptions(WithCandidates(3)).Candidates)
+	})
+
+	t.Run("WithPrediction", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "x := 1", ApplyOptions(WithPrediction("x := 1")).Prediction)
+	})
+
+	t.Run("WithContextCache", func(t *testing.T) {
+		t.Parallel()
+		cache := &ContextCache{Provider: "gemini", Name: "cachedContents/abc"}
+		assert.Same(t, cache, ApplyOptions(WithContextCache(cache)).ContextCache)
+	})
+
+	t.Run("WithValidator", func(t *testing.T) {
+		t.Parallel()
+		errFirst := errors.New("first")
+		errSecond := errors.New("second")
+		opts := ApplyOptions(
+			WithValidator(func(msg Message) error {
+				if msg.GetText() == "a" {
+					return errFirst
+				}
+				return nil
+			}),
+			WithValidator(func(msg Message) error { return errSecond }),
+		)
+		require.NotNil(t, opts.Validator)
+		assert.ErrorIs(t, opts.Validator(AssistantMessage("a")), errFirst)
+		assert.ErrorIs(t, opts.Validator(AssistantMessage("b")), errSecond)
+		assert.Equal(t, DefaultValidationRetries, opts.ValidationRetries)
+
+		opts = ApplyOptions(WithValidationRetries(5))
+		assert.Nil(t, opts.Validator)
+		assert.Equal(t, 5, opts.ValidationRetries)
+	})
+
 	t.Run("Multiple options", func(t *testing.T) {
 		t.Parallel()
 		opts := ApplyOptions(
@@ -297,6 +387,11 @@ func (m *MockChat) MaxTokens() int {
 	return 4096
 }
 
+func (m *MockChat) SetSystemPrompt(ctx context.Context, prompt string) error {
+	m.systemPrompt = prompt
+	return nil
+}
+
 // RegisterTool registers a mock tool
 func (m *MockChat) RegisterTool(tool Tool) error {
 	return nil
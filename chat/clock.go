@@ -0,0 +1,32 @@
+package chat
+
+import "time"
+
+// Clock tells the time. Sessions and providers that timestamp records or
+// build IDs from the time accept one, so tests can be deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock, such as time.Now or a function
+// returning a fixed time in tests.
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// IDGenerator generates unique IDs, such as session IDs or the tool call IDs
+// providers assign when the model doesn't.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to an IDGenerator.
+type IDGeneratorFunc func() string
+
+// NewID implements IDGenerator.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
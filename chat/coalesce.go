@@ -0,0 +1,72 @@
+package chat
+
+import "time"
+
+// streamCoalescer merges runs of content or thinking deltas for
+// WithStreamCoalescing.
+type streamCoalescer struct {
+	callback StreamCallback
+	interval time.Duration
+	now      func() time.Time
+
+	// pending holds deltas not yet passed to callback
+	pending *StreamEvent
+	// run is the type of the deltas in the current run, or "" between runs
+	run StreamEventType
+	// lastFlush is when deltas were last passed to callback
+	lastFlush time.Time
+}
+
+// coalesceStream returns a callback that passes events to callback,
+// merging content and thinking deltas that arrive within interval of the
+// last delivered delta. Pending deltas are delivered before any other event.
+// The stream's final deltas are delivered with the round end event that
+// providers send, so they are dropped if Message fails first.
+func coalesceStream(callback StreamCallback, interval time.Duration, now func() time.Time) StreamCallback {
+	c := &streamCoalescer{callback: callback, interval: interval, now: now}
+	return c.handle
+}
+
+func (c *streamCoalescer) handle(event StreamEvent) error {
+	if event.Type != StreamEventTypeContent && event.Type != StreamEventTypeThinking {
+		if err := c.flush(); err != nil {
+			return err
+		}
+		c.run = ""
+		return c.callback(event)
+	}
+
+	if event.Type != c.run {
+		// Deliver the start of a run right away
+		if err := c.flush(); err != nil {
+			return err
+		}
+		c.run = event.Type
+		return c.deliver(event)
+	}
+	if c.pending != nil {
+		c.pending.Content += event.Content
+	} else {
+		c.pending = &event
+	}
+
+	if c.now().Sub(c.lastFlush) >= c.interval {
+		return c.flush()
+	}
+	return nil
+}
+
+// flush delivers any pending deltas.
+func (c *streamCoalescer) flush() error {
+	if c.pending == nil {
+		return nil
+	}
+	event := *c.pending
+	c.pending = nil
+	return c.deliver(event)
+}
+
+func (c *streamCoalescer) deliver(event StreamEvent) error {
+	c.lastFlush = c.now()
+	return c.callback(event)
+}
@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a time source advanced by tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestStreamCoalescing(t *testing.T) {
+	t.Parallel()
+
+	var got []StreamEvent
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	cb := coalesceStream(func(event StreamEvent) error {
+		got = append(got, event)
+		return nil
+	}, 50*time.Millisecond, clock.now)
+
+	send := func(event StreamEvent, advance time.Duration) {
+		clock.t = clock.t.Add(advance)
+		require.NoError(t, cb(event))
+	}
+	content := func(s string) StreamEvent { return StreamEvent{Type: StreamEventTypeContent, Content: s} }
+
+	send(StreamEvent{Type: StreamEventTypeThinking, Content: "hmm"}, 0)
+	send(StreamEvent{Type: StreamEventTypeThinking, Content: "..."}, 10*time.Millisecond)
+	send(content("Hel"), 10*time.Millisecond)
+	send(content("lo"), 10*time.Millisecond)
+	send(content(", "), 10*time.Millisecond)
+	send(content("wor"), 40*time.Millisecond)
+	send(content("ld"), 10*time.Millisecond)
+	send(StreamEvent{Type: StreamEventTypeRoundEnd}, 0)
+	send(content("Next"), 0)
+
+	assert.Equal(t, []StreamEvent{
+		{Type: StreamEventTypeThinking, Content: "hmm"},
+		{Type: StreamEventTypeThinking, Content: "..."},
+		content("Hel"),
+		content("lo, wor"),
+		content("ld"),
+		{Type: StreamEventTypeRoundEnd},
+		content("Next"),
+	}, got)
+}
+
+func TestStreamCoalescingOption(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	opts := ApplyOptions(
+		WithStreamingCb(func(event StreamEvent) error {
+			got = append(got, event.Content)
+			return nil
+		}),
+		WithStreamCoalescing(time.Hour),
+	)
+	for _, s := range []string{"a", "b", "c"} {
+		require.NoError(t, opts.StreamingCb(StreamEvent{Type: StreamEventTypeContent, Content: s}))
+	}
+	require.NoError(t, opts.StreamingCb(StreamEvent{Type: StreamEventTypeRoundEnd}))
+	assert.Equal(t, []string{"a", "bc", ""}, got)
+
+	// Without a callback, there's nothing to coalesce
+	assert.Nil(t, ApplyOptions(WithStreamCoalescing(time.Hour)).StreamingCb)
+}
+
+func TestStreamCoalescingError(t *testing.T) {
+	t.Parallel()
+
+	errStop := errors.New("stop")
+	cb := coalesceStream(func(event StreamEvent) error {
+		if event.Content == "bc" {
+			return errStop
+		}
+		return nil
+	}, time.Hour, time.Now)
+
+	require.NoError(t, cb(StreamEvent{Type: StreamEventTypeContent, Content: "a"}))
+	require.NoError(t, cb(StreamEvent{Type: StreamEventTypeContent, Content: "b"}))
+	require.NoError(t, cb(StreamEvent{Type: StreamEventTypeContent, Content: "c"}))
+	assert.ErrorIs(t, cb(StreamEvent{Type: StreamEventTypeToolCall}), errStop)
+}
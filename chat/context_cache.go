@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"context"
+	"time"
+)
+
+// ContextCache is a handle to a long system prompt, such as one carrying a
+// large corpus, cached by a provider so that requests beginning with it are
+// cheaper and faster. Create one with a ContextCacher, and attach it to
+// requests with WithContextCache. Tokens read from the cache are reported
+// in TokenUsageDetails.CachedTokens.
+type ContextCache struct {
+	// Provider is the provider that created the cache, such as "openai" or
+	// "gemini". Other providers don't use the cache, but still use its
+	// system prompt.
+	Provider string `json:"provider"`
+	// Name is the provider's name for the cache: Gemini's cached content
+	// resource name, or OpenAI's prompt cache key.
+	Name string `json:"name"`
+	// SystemPrompt is the cached system prompt.
+	SystemPrompt string `json:"systemPrompt"`
+	// ExpireTime is when the provider discards the cache, if it has a set
+	// lifetime.
+	ExpireTime time.Time `json:"expireTime,omitzero"`
+}
+
+// ContextCacheConfig describes the context to cache.
+type ContextCacheConfig struct {
+	// SystemPrompt is the system prompt to cache.
+	SystemPrompt string
+	// Tools are the tools that chats using the cache register. Gemini
+	// can't combine a cache with tools sent in the request, so they must be
+	// cached with the system prompt.
+	Tools []ToolDef
+	// TTL is how long the provider should keep the cache, if it lets the
+	// caller choose; zero uses the provider's default.
+	TTL time.Duration
+}
+
+// ContextCacher is optionally implemented by Clients whose provider can
+// cache a long system prompt explicitly.
+type ContextCacher interface {
+	// CreateContextCache caches config's context for the client's model.
+	CreateContextCache(ctx context.Context, config ContextCacheConfig) (*ContextCache, error)
+}
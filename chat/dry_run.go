@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DryRunRequest is the provider request a Message call given WithDryRun built but didn't
+// send. Message returns it as its error.
+type DryRunRequest struct {
+	// Method and URL are the HTTP method and URL the request would have been sent to.
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body is the request body, in the provider's JSON wire format. It carries no
+	// credentials, which are sent in headers.
+	Body json.RawMessage `json:"body"`
+}
+
+func (r *DryRunRequest) Error() string {
+	return fmt.Sprintf("dry run: %s %s not sent", r.Method, r.URL)
+}
+
+// WithDryRun builds the provider request for the message, then returns it as a
+// *DryRunRequest error instead of sending it, for debugging prompts and testing how requests
+// are built. The chat's history is left unchanged. See DryRun.
+func WithDryRun() Option {
+	return func(opts *requestOpts) {
+		opts.dryRun = true
+	}
+}
+
+// DryRun returns the request c would send the provider for msg and opts, without sending it.
+func DryRun(ctx context.Context, c Chat, msg Message, opts ...Option) (*DryRunRequest, error) {
+	_, err := c.Message(ctx, msg, append(opts, WithDryRun())...)
+	var req *DryRunRequest
+	if errors.As(err, &req) {
+		return req, nil
+	}
+	if err == nil {
+		return nil, errors.New("dry run: chat sent the message, as it doesn't support dry runs")
+	}
+	return nil, err
+}
@@ -0,0 +1,20 @@
+package chat
+
+import "context"
+
+// Embedder turns text into embedding vectors, whose cosine similarity
+// measures how alike the texts are in meaning, usually by calling a
+// provider's embeddings API.
+type Embedder interface {
+	// Embed returns the embedding of each of texts, in order.
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// EmbedderFunc adapts a function to an Embedder, for custom or self-hosted
+// embedding models.
+type EmbedderFunc func(ctx context.Context, texts []string) ([][]float64, error)
+
+// Embed implements Embedder.
+func (f EmbedderFunc) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	return f(ctx, texts)
+}
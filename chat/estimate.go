@@ -0,0 +1,26 @@
+package chat
+
+import "context"
+
+// RequestEstimate describes the size of the request a message would be sent in, so
+// applications can warn before very large sends, such as a huge pasted log.
+type RequestEstimate struct {
+	// Bytes is the size of the request body.
+	Bytes int `json:"bytes"`
+	// Messages is the number of messages in the request: the chat's history plus the new
+	// message. The system prompt isn't counted.
+	Messages int `json:"messages"`
+	// ToolBytes is the size of the tool definitions in the request body.
+	ToolBytes int `json:"toolBytes"`
+	// EstimatedTokens roughly estimates the request's input tokens from its size. It
+	// overestimates somewhat, as it counts the request's JSON syntax.
+	EstimatedTokens int `json:"estimatedTokens"`
+}
+
+// RequestEstimator is optionally implemented by Chats that can describe the next request
+// without sending it.
+type RequestEstimator interface {
+	// EstimateRequest returns the size of the request that Message would send for msg and
+	// opts. Nothing is sent, and the chat is unchanged.
+	EstimateRequest(ctx context.Context, msg Message, opts ...Option) (RequestEstimate, error)
+}
@@ -0,0 +1,67 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// MessageExporter converts messages to the JSON messages array of a
+// provider's request body.
+type MessageExporter func(msgs []Message) (json.RawMessage, error)
+
+var (
+	exportersMu sync.Mutex
+	exporters   = make(map[string]MessageExporter)
+)
+
+// RegisterMessageExporter makes ExportMessages support provider. Provider
+// packages register themselves when imported; registering the same provider
+// twice panics, as with database/sql drivers.
+func RegisterMessageExporter(provider string, export MessageExporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	if _, dup := exporters[provider]; dup {
+		panic(fmt.Sprintf("chat: RegisterMessageExporter called twice for provider %q", provider))
+	}
+	exporters[provider] = export
+}
+
+// MessageExporters returns the names of the providers ExportMessages supports.
+func MessageExporters() []string {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ExportMessages returns msgs as the messages array that provider's client
+// builds for its requests, which is useful for debugging, building
+// fine-tuning datasets, and support tickets. The system prompt isn't
+// included for providers that take it separately.
+//
+// Providers are named as in llm.Config: "openai" (Chat Completions),
+// "anthropic", and "google". The provider's package registers its exporter,
+// so it must be imported (importing llm imports them all).
+func ExportMessages(provider string, msgs []Message) (json.RawMessage, error) {
+	export, ok := messageExporter(provider)
+	if !ok {
+		return nil, fmt.Errorf("no message exporter for provider %q (is its package imported?)", provider)
+	}
+	return export(msgs)
+}
+
+func messageExporter(provider string) (MessageExporter, bool) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	export, ok := exporters[provider]
+	return export, ok
+}
@@ -0,0 +1,31 @@
+package chat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMessages(t *testing.T) {
+	RegisterMessageExporter("test-export", func(msgs []Message) (json.RawMessage, error) {
+		texts := make([]string, len(msgs))
+		for i, msg := range msgs {
+			texts[i] = msg.GetText()
+		}
+		return json.Marshal(texts)
+	})
+	assert.Contains(t, MessageExporters(), "test-export")
+
+	got, err := ExportMessages("test-export", []Message{UserMessage("hi"), AssistantMessage("hello")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["hi", "hello"]`, string(got))
+
+	_, err = ExportMessages("unknown", nil)
+	assert.EqualError(t, err, `no message exporter for provider "unknown" (is its package imported?)`)
+
+	assert.Panics(t, func() {
+		RegisterMessageExporter("test-export", func([]Message) (json.RawMessage, error) { return nil, nil })
+	})
+}
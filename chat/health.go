@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"context"
+	"time"
+)
+
+// HealthState classifies the outcome of a health check.
+type HealthState string
+
+const (
+	// HealthOK means the provider accepted the client's credentials and
+	// serves its model.
+	HealthOK HealthState = "ok"
+	// HealthUnauthorized means the provider rejected the client's
+	// credentials.
+	HealthUnauthorized HealthState = "unauthorized"
+	// HealthModelNotFound means the provider doesn't serve the client's
+	// model, or the endpoint isn't the provider's API.
+	HealthModelNotFound HealthState = "model_not_found"
+	// HealthRateLimited means the provider is rejecting requests until the
+	// client sends fewer.
+	HealthRateLimited HealthState = "rate_limited"
+	// HealthUnavailable means the provider answered with a server error.
+	HealthUnavailable HealthState = "unavailable"
+	// HealthUnreachable means the endpoint didn't answer at all, because
+	// it couldn't be resolved or connected to, or didn't respond in time.
+	HealthUnreachable HealthState = "unreachable"
+	// HealthFailed means the check failed in some other way, such as a
+	// malformed request.
+	HealthFailed HealthState = "failed"
+)
+
+// HealthStatus is the result of a health check.
+type HealthStatus struct {
+	// Provider is the provider checked, such as "claude" or "openai".
+	Provider string `json:"provider"`
+	// Model is the model the client sends requests to.
+	Model string      `json:"model"`
+	State HealthState `json:"state"`
+	// StatusCode is the HTTP status of the check's response, or 0 if there
+	// was none.
+	StatusCode int `json:"statusCode,omitzero"`
+	// Latency is how long the check took.
+	Latency time.Duration `json:"latency"`
+	// Error describes why the check failed, if it did.
+	Error string `json:"error,omitzero"`
+}
+
+// Healthy reports whether the check succeeded.
+func (s HealthStatus) Healthy() bool {
+	return s.State == HealthOK
+}
+
+// Retryable reports whether the check failed for a reason that may pass
+// on its own, rather than a misconfiguration: the provider is rate
+// limiting, having an outage, or couldn't be reached.
+func (s HealthStatus) Retryable() bool {
+	switch s.State {
+	case HealthRateLimited, HealthUnavailable, HealthUnreachable:
+		return true
+	}
+	return false
+}
+
+// Pinger is optionally implemented by Clients that can check their
+// provider is reachable and accepts their credentials and model, without
+// starting a chat. Services call Ping at startup to fail fast on a
+// misconfiguration instead of on the first user message.
+type Pinger interface {
+	// Ping makes the cheapest request the provider offers that exercises
+	// the client's endpoint, credentials, and model, such as looking up
+	// the model. The returned status is filled in even when the check
+	// fails, in which case the error describes why.
+	Ping(ctx context.Context) (HealthStatus, error)
+}
@@ -0,0 +1,62 @@
+package chat
+
+import "strings"
+
+// knownModels is the shared registry of token limits for models supported by
+// the LLM providers in this module.
+var knownModels = []ModelTokenLimits{
+	// Anthropic
+	{Model: "claude-opus-4-6", TokenLimits: TokenLimits{Context: 200000, Output: 128000}},
+	{Model: "claude-opus-4-5", TokenLimits: TokenLimits{Context: 200000, Output: 64000}},
+	{Model: "claude-opus-4-1", TokenLimits: TokenLimits{Context: 200000, Output: 32000}},
+	{Model: "claude-opus-4", TokenLimits: TokenLimits{Context: 200000, Output: 32000}},
+	{Model: "claude-sonnet-4-5", TokenLimits: TokenLimits{Context: 200000, Output: 64000}},
+	{Model: "claude-sonnet-4", TokenLimits: TokenLimits{Context: 200000, Output: 64000}},
+	{Model: "claude-haiku-4-5", TokenLimits: TokenLimits{Context: 200000, Output: 64000}},
+	{Model: "claude-3-7-sonnet", TokenLimits: TokenLimits{Context: 200000, Output: 64000}},
+	{Model: "claude-3-5-haiku", TokenLimits: TokenLimits{Context: 200000, Output: 8192}},
+	{Model: "claude-3-haiku", TokenLimits: TokenLimits{Context: 200000, Output: 4096}},
+
+	// OpenAI
+	{Model: "gpt-5-mini", TokenLimits: TokenLimits{Context: 400000, Output: 128000}},
+	{Model: "gpt-5-nano", TokenLimits: TokenLimits{Context: 400000, Output: 128000}},
+	{Model: "gpt-5", TokenLimits: TokenLimits{Context: 400000, Output: 128000}},
+	{Model: "gpt-4.5-preview", TokenLimits: TokenLimits{Context: 128000, Output: 16384}},
+	{Model: "gpt-4.1-mini", TokenLimits: TokenLimits{Context: 1000000, Output: 32768}},
+	{Model: "gpt-4.1", TokenLimits: TokenLimits{Context: 1000000, Output: 32768}},
+	{Model: "gpt-4o-mini", TokenLimits: TokenLimits{Context: 128000, Output: 16384}},
+	{Model: "gpt-4o", TokenLimits: TokenLimits{Context: 128000, Output: 16384}},
+	{Model: "gpt-4-turbo", TokenLimits: TokenLimits{Context: 128000, Output: 4096}},
+	{Model: "gpt-4", TokenLimits: TokenLimits{Context: 8192, Output: 8192}},
+	{Model: "o4-mini", TokenLimits: TokenLimits{Context: 200000, Output: 100000}},
+	{Model: "o3-mini", TokenLimits: TokenLimits{Context: 200000, Output: 100000}},
+	{Model: "o3", TokenLimits: TokenLimits{Context: 200000, Output: 100000}},
+	{Model: "gpt-3.5-turbo", TokenLimits: TokenLimits{Context: 16385, Output: 4096}},
+
+	// Google
+	{Model: "gemini-2.5-pro", TokenLimits: TokenLimits{Context: 1048576, Output: 65536}},
+	{Model: "gemini-2.5-flash", TokenLimits: TokenLimits{Context: 1048576, Output: 65536}},
+	{Model: "gemini-2.5-flash-lite", TokenLimits: TokenLimits{Context: 1048576, Output: 65536}},
+	{Model: "gemini-2.0-flash", TokenLimits: TokenLimits{Context: 1048576, Output: 8192}},
+	{Model: "gemini-2.0-flash-lite", TokenLimits: TokenLimits{Context: 1048576, Output: 8192}},
+	{Model: "gemini-1.5-pro", TokenLimits: TokenLimits{Context: 2097152, Output: 8192}},
+	{Model: "gemini-1.5-flash", TokenLimits: TokenLimits{Context: 1048576, Output: 8192}},
+	{Model: "gemini-1.5-flash-8b", TokenLimits: TokenLimits{Context: 1048576, Output: 8192}},
+}
+
+// LookupModel returns the token limits for a model. Model names are matched
+// case-insensitively against the longest known prefix, so dated or otherwise
+// suffixed names (like "claude-sonnet-4-5-20250929") resolve to their family.
+func LookupModel(model string) (ModelTokenLimits, bool) {
+	modelLower := strings.ToLower(model)
+
+	var best ModelTokenLimits
+	found := false
+	for _, m := range knownModels {
+		if strings.HasPrefix(modelLower, m.Model) && len(m.Model) > len(best.Model) {
+			best = m
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,31 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		model   string
+		want    string
+		context int
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5", 200000},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini", 128000},
+		{"o3-mini", "o3-mini", 200000},
+		{"GEMINI-2.5-FLASH-LITE", "gemini-2.5-flash-lite", 1048576},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			m, ok := LookupModel(tt.model)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, m.Model)
+			assert.Equal(t, tt.context, m.Context)
+		})
+	}
+
+	_, ok := LookupModel("unknown-model")
+	assert.False(t, ok)
+}
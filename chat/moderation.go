@@ -0,0 +1,30 @@
+package chat
+
+import "context"
+
+// ModerationResult is a moderation model's verdict on a piece of content.
+type ModerationResult struct {
+	// Flagged is true if the content violates any category's policy.
+	Flagged bool `json:"flagged"`
+	// Categories are the categories the content was flagged for, such as
+	// "harassment" or "self-harm/intent", in the moderator's own naming.
+	Categories []string `json:"categories,omitzero"`
+	// Scores maps each category the moderator checked to its confidence
+	// that the content falls in that category, from 0 to 1.
+	Scores map[string]float64 `json:"scores,omitzero"`
+}
+
+// Moderator screens text for content policy violations, usually by calling a
+// provider's moderation API.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (ModerationResult, error)
+}
+
+// ModeratorFunc adapts a function to a Moderator, for custom moderation such
+// as keyword filters or a self-hosted classifier.
+type ModeratorFunc func(ctx context.Context, text string) (ModerationResult, error)
+
+// Moderate implements Moderator.
+func (f ModeratorFunc) Moderate(ctx context.Context, text string) (ModerationResult, error) {
+	return f(ctx, text)
+}
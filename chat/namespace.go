@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NamespaceSeparator separates a tool's namespace from its name, as in
+// "github__create_issue". Provider tool names are limited to letters, digits,
+// underscores and hyphens, which rules out more conventional separators.
+const NamespaceSeparator = "__"
+
+// ToolCollisionError is returned by RegisterNamespaced when a tool's name is
+// already in use.
+type ToolCollisionError struct {
+	// Name is the (namespaced) tool name that collided.
+	Name string
+}
+
+func (e *ToolCollisionError) Error() string {
+	return fmt.Sprintf("tool %q is already registered", e.Name)
+}
+
+// namespacedTool exposes a tool under a prefixed name.
+type namespacedTool struct {
+	namespace string
+	tool      Tool
+}
+
+// Namespaced returns tool exposed to the LLM as namespace+NamespaceSeparator+tool.Name().
+// Calls are passed through to tool unchanged, so the underlying handler never sees the prefix.
+// An empty namespace returns tool as is.
+func Namespaced(namespace string, tool Tool) Tool {
+	if namespace == "" {
+		return tool
+	}
+	return &namespacedTool{namespace: namespace, tool: tool}
+}
+
+func (t *namespacedTool) Name() string {
+	return t.namespace + NamespaceSeparator + t.tool.Name()
+}
+
+func (t *namespacedTool) Description() string {
+	return t.tool.Description()
+}
+
+func (t *namespacedTool) MCPJsonSchema() string {
+	return t.tool.MCPJsonSchema()
+}
+
+func (t *namespacedTool) Call(ctx context.Context, input string) string {
+	return t.tool.Call(ctx, input)
+}
+
+// CallWithImages passes the underlying tool's images through.
+func (t *namespacedTool) CallWithImages(ctx context.Context, input string) (string, []ImageContent) {
+	if it, ok := t.tool.(ImageTool); ok {
+		return it.CallWithImages(ctx, input)
+	}
+	return t.tool.Call(ctx, input), nil
+}
+
+// Unwrap returns the tool without its namespace.
+func (t *namespacedTool) Unwrap() Tool {
+	return t.tool
+}
+
+// SplitToolName splits a namespaced tool name into its namespace and the
+// name the underlying tool registered with. Names without a namespace are
+// returned with an empty namespace.
+func SplitToolName(name string) (namespace, toolName string) {
+	namespace, toolName, ok := strings.Cut(name, NamespaceSeparator)
+	if !ok {
+		return "", name
+	}
+	return namespace, toolName
+}
+
+// RegisterNamespaced registers tools with c under the given namespace, for
+// combining toolsets (local tools, MCP servers, subagents) whose names may
+// overlap. Unlike RegisterTool, which replaces a tool of the same name, it
+// returns a *ToolCollisionError if any name is already registered or repeated
+// in tools. Nothing is registered when an error is returned for a collision.
+func RegisterNamespaced(c Chat, namespace string, tools ...Tool) error {
+	taken := make(map[string]bool)
+	for _, name := range c.ListTools() {
+		taken[name] = true
+	}
+
+	wrapped := make([]Tool, len(tools))
+	for i, tool := range tools {
+		wrapped[i] = Namespaced(namespace, tool)
+		name := wrapped[i].Name()
+		if taken[name] {
+			return &ToolCollisionError{Name: name}
+		}
+		taken[name] = true
+	}
+
+	for _, tool := range wrapped {
+		if err := c.RegisterTool(tool); err != nil {
+			return fmt.Errorf("registering tool %q: %w", tool.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoTool struct {
+	name string
+}
+
+func (t *echoTool) Name() string          { return t.name }
+func (t *echoTool) Description() string   { return "Echoes its input" }
+func (t *echoTool) MCPJsonSchema() string { return `{"type":"object"}` }
+func (t *echoTool) Call(ctx context.Context, input string) string {
+	return t.name + ":" + input
+}
+
+// imageEchoTool is an echoTool whose results include an image.
+type imageEchoTool struct {
+	echoTool
+}
+
+func (t *imageEchoTool) CallWithImages(ctx context.Context, input string) (string, []ImageContent) {
+	return t.Call(ctx, input), []ImageContent{{MediaType: "image/png", Data: []byte(input)}}
+}
+
+// toolListChat is a MockChat that keeps track of registered tools.
+type toolListChat struct {
+	MockChat
+	tools map[string]Tool
+	order []string
+}
+
+func (c *toolListChat) RegisterTool(tool Tool) error {
+	if c.tools == nil {
+		c.tools = make(map[string]Tool)
+	}
+	if _, ok := c.tools[tool.Name()]; !ok {
+		c.order = append(c.order, tool.Name())
+	}
+	c.tools[tool.Name()] = tool
+	return nil
+}
+
+func (c *toolListChat) ListTools() []string {
+	return c.order
+}
+
+func TestNamespaced(t *testing.T) {
+	t.Parallel()
+
+	inner := &echoTool{name: "search"}
+	tool := Namespaced("github", inner)
+	assert.Equal(t, "github__search", tool.Name())
+	assert.Equal(t, inner.Description(), tool.Description())
+	assert.Equal(t, inner.MCPJsonSchema(), tool.MCPJsonSchema())
+
+	// The handler is invoked as the underlying tool
+	assert.Equal(t, "search:{}", tool.Call(context.Background(), "{}"))
+
+	unwrapper, ok := tool.(interface{ Unwrap() Tool })
+	require.True(t, ok)
+	assert.Same(t, inner, unwrapper.Unwrap())
+
+	assert.Same(t, inner, Namespaced("", inner))
+}
+
+func TestNamespacedImageTool(t *testing.T) {
+	t.Parallel()
+
+	tool, ok := Namespaced("fs", &imageEchoTool{echoTool{name: "read_image"}}).(ImageTool)
+	require.True(t, ok)
+	output, images := tool.CallWithImages(context.Background(), "png")
+	assert.Equal(t, "read_image:png", output)
+	assert.Equal(t, []ImageContent{{MediaType: "image/png", Data: []byte("png")}}, images)
+
+	// Tools without images are called as usual
+	tool = Namespaced("fs", &echoTool{name: "read_file"}).(ImageTool)
+	output, images = tool.CallWithImages(context.Background(), "{}")
+	assert.Equal(t, "read_file:{}", output)
+	assert.Empty(t, images)
+}
+
+func TestSplitToolName(t *testing.T) {
+	t.Parallel()
+
+	namespace, name := SplitToolName("github__search")
+	assert.Equal(t, "github", namespace)
+	assert.Equal(t, "search", name)
+
+	namespace, name = SplitToolName("search")
+	assert.Equal(t, "", namespace)
+	assert.Equal(t, "search", name)
+}
+
+func TestRegisterNamespaced(t *testing.T) {
+	t.Parallel()
+
+	c := &toolListChat{}
+	require.NoError(t, c.RegisterTool(&echoTool{name: "search"}))
+	require.NoError(t, RegisterNamespaced(c, "github", &echoTool{name: "search"}, &echoTool{name: "create_issue"}))
+	assert.Equal(t, []string{"search", "github__search", "github__create_issue"}, c.ListTools())
+
+	err := RegisterNamespaced(c, "jira", &echoTool{name: "create_issue"}, &echoTool{name: "search"}, &echoTool{name: "search"})
+	var collision *ToolCollisionError
+	require.True(t, errors.As(err, &collision))
+	assert.Equal(t, "jira__search", collision.Name)
+	// Nothing is registered when there is a collision
+	assert.Len(t, c.ListTools(), 3)
+
+	err = RegisterNamespaced(c, "github", &echoTool{name: "search"})
+	require.True(t, errors.As(err, &collision))
+	assert.Equal(t, "github__search", collision.Name)
+}
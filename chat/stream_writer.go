@@ -0,0 +1,243 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamFormat selects how StreamToWriter renders stream events.
+type StreamFormat int
+
+const (
+	// StreamFormatPlain renders events as plain text, suitable for logs and
+	// terminals without color support.
+	StreamFormatPlain StreamFormat = iota
+	// StreamFormatMarkdown renders thinking as block quotes and tool calls
+	// as fenced code blocks, for UIs that display markdown.
+	StreamFormatMarkdown
+	// StreamFormatANSI renders events for color terminals: thinking is
+	// dimmed, tool calls are highlighted, and errors are shown in red.
+	StreamFormatANSI
+)
+
+// StreamWriterOptions configures StreamToWriter.
+type StreamWriterOptions struct {
+	// Format selects the rendering; the default is StreamFormatPlain.
+	Format StreamFormat
+	// HideThinking omits thinking content and summaries.
+	HideThinking bool
+	// Verbose includes tool call arguments and successful tool results.
+	// Tool errors are always shown.
+	Verbose bool
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// streamWriter holds the rendering state for StreamToWriter.
+type streamWriter struct {
+	w    io.Writer
+	opts StreamWriterOptions
+
+	// thinking is true while a thinking block is open
+	thinking bool
+	// thoughts is true if thinking content was shown this turn
+	thoughts bool
+}
+
+// StreamToWriter returns a StreamCallback that renders content, thinking,
+// and tool activity to w as it streams, so CLIs and servers don't each need
+// their own switch over event types. Rendering stops with the write error if
+// writing to w fails.
+func StreamToWriter(w io.Writer, opts StreamWriterOptions) StreamCallback {
+	sw := &streamWriter{w: w, opts: opts}
+	return sw.handle
+}
+
+func (sw *streamWriter) handle(event StreamEvent) error {
+	switch event.Type {
+	case StreamEventTypeThinking:
+		if sw.opts.HideThinking || event.Content == "" {
+			return nil
+		}
+		if err := sw.beginThinking(); err != nil {
+			return err
+		}
+		sw.thoughts = true
+		return sw.writeThinking(event.Content)
+	case StreamEventTypeThinkingSummary:
+		if sw.opts.HideThinking {
+			return nil
+		}
+		// Some providers only report a summary of their thinking
+		if !sw.thoughts && event.ThinkingStatus != nil && event.ThinkingStatus.Summary != "" {
+			if err := sw.beginThinking(); err != nil {
+				return err
+			}
+			if err := sw.writeThinking(event.ThinkingStatus.Summary); err != nil {
+				return err
+			}
+		}
+		return sw.endThinking()
+	case StreamEventTypeContent:
+		if err := sw.endThinking(); err != nil {
+			return err
+		}
+		return sw.write(event.Content)
+	case StreamEventTypeToolCall:
+		if err := sw.endThinking(); err != nil {
+			return err
+		}
+		for _, tc := range event.ToolCalls {
+			if err := sw.writeToolCall(tc); err != nil {
+				return err
+			}
+		}
+	case StreamEventTypeToolResult:
+		for _, tr := range event.ToolResults {
+			if err := sw.writeToolResult(tr); err != nil {
+				return err
+			}
+		}
+	case StreamEventTypeDone:
+		if err := sw.endThinking(); err != nil {
+			return err
+		}
+		sw.thoughts = false
+	}
+	return nil
+}
+
+func (sw *streamWriter) write(s string) error {
+	if s == "" {
+		return nil
+	}
+	_, err := io.WriteString(sw.w, s)
+	return err
+}
+
+func (sw *streamWriter) beginThinking() error {
+	if sw.thinking {
+		return nil
+	}
+	sw.thinking = true
+	switch sw.opts.Format {
+	case StreamFormatMarkdown:
+		return sw.write("\n> **Thinking**\n>\n> ")
+	case StreamFormatANSI:
+		return sw.write("\n" + ansiDim + "Thinking...\n")
+	default:
+		return sw.write("\nThinking...\n")
+	}
+}
+
+func (sw *streamWriter) writeThinking(s string) error {
+	if sw.opts.Format == StreamFormatMarkdown {
+		// Keep multi-line thinking inside the block quote
+		s = strings.ReplaceAll(s, "\n", "\n> ")
+	}
+	return sw.write(s)
+}
+
+func (sw *streamWriter) endThinking() error {
+	if !sw.thinking {
+		return nil
+	}
+	sw.thinking = false
+	switch sw.opts.Format {
+	case StreamFormatMarkdown:
+		return sw.write("\n\n")
+	case StreamFormatANSI:
+		return sw.write(ansiReset + "\n\n")
+	default:
+		return sw.write("\n\n")
+	}
+}
+
+func (sw *streamWriter) writeToolCall(tc ToolCall) error {
+	args := ""
+	if sw.opts.Verbose && len(tc.Arguments) > 0 {
+		args = string(tc.Arguments)
+	}
+
+	switch sw.opts.Format {
+	case StreamFormatMarkdown:
+		if err := sw.write(fmt.Sprintf("\n\n**Tool call:** `%s`\n", tc.Name)); err != nil {
+			return err
+		}
+		if args != "" {
+			return sw.write("\n```json\n" + args + "\n```\n")
+		}
+		return nil
+	case StreamFormatANSI:
+		if err := sw.write(fmt.Sprintf("\n%s%s→ %s%s\n", ansiBold, ansiCyan, tc.Name, ansiReset)); err != nil {
+			return err
+		}
+		if args != "" {
+			return sw.write(ansiDim + "  " + args + ansiReset + "\n")
+		}
+		return nil
+	default:
+		if err := sw.write(fmt.Sprintf("\n[tool call] %s\n", tc.Name)); err != nil {
+			return err
+		}
+		if args != "" {
+			return sw.write("  arguments: " + args + "\n")
+		}
+		return nil
+	}
+}
+
+func (sw *streamWriter) writeToolResult(tr ToolResult) error {
+	name := tr.Name
+	if name == "" {
+		name = tr.ToolCallID
+	}
+	content := ""
+	if sw.opts.Verbose {
+		content = tr.Content
+	}
+
+	switch sw.opts.Format {
+	case StreamFormatMarkdown:
+		if tr.Error != "" {
+			return sw.write(fmt.Sprintf("\n**Tool error:** `%s`: %s\n\n", name, tr.Error))
+		}
+		if err := sw.write(fmt.Sprintf("\n**Tool result:** `%s`\n", name)); err != nil {
+			return err
+		}
+		if content != "" {
+			return sw.write("\n```\n" + content + "\n```\n\n")
+		}
+		return sw.write("\n")
+	case StreamFormatANSI:
+		if tr.Error != "" {
+			return sw.write(fmt.Sprintf("%s✗ %s: %s%s\n", ansiRed, name, tr.Error, ansiReset))
+		}
+		if err := sw.write(fmt.Sprintf("%s✓ %s%s\n", ansiGreen, name, ansiReset)); err != nil {
+			return err
+		}
+		if content != "" {
+			return sw.write(ansiDim + "  " + content + ansiReset + "\n")
+		}
+		return nil
+	default:
+		if tr.Error != "" {
+			return sw.write(fmt.Sprintf("[tool error] %s: %s\n", name, tr.Error))
+		}
+		if err := sw.write(fmt.Sprintf("[tool result] %s\n", name)); err != nil {
+			return err
+		}
+		if content != "" {
+			return sw.write("  result: " + content + "\n")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolTurn is the stream of a turn that thinks, calls a tool, and answers.
+var toolTurn = []StreamEvent{
+	{Type: StreamEventTypeThinking, Content: "Need to\nlook it up"},
+	{Type: StreamEventTypeThinkingSummary, ThinkingStatus: &ThinkingStatus{}},
+	{Type: StreamEventTypeToolCall, ToolCalls: []ToolCall{{ID: "1", Name: "search", Arguments: json.RawMessage(`{"q":"go"}`)}}},
+	{Type: StreamEventTypeToolResult, ToolResults: []ToolResult{{ToolCallID: "1", Name: "search", Content: "found"}}},
+	{Type: StreamEventTypeToolResult, ToolResults: []ToolResult{{ToolCallID: "2", Error: "timed out"}}},
+	{Type: StreamEventTypeContent, Content: "Go is "},
+	{Type: StreamEventTypeContent, Content: "great."},
+	{Type: StreamEventTypeDone},
+}
+
+func renderStream(t *testing.T, opts StreamWriterOptions, events []StreamEvent) string {
+	var b strings.Builder
+	cb := StreamToWriter(&b, opts)
+	for _, event := range events {
+		require.NoError(t, cb(event))
+	}
+	return b.String()
+}
+
+func TestStreamToWriterPlain(t *testing.T) {
+	t.Parallel()
+
+	got := renderStream(t, StreamWriterOptions{}, toolTurn)
+	assert.Equal(t, "\nThinking...\nNeed to\nlook it up\n\n"+
+		"\n[tool call] search\n"+
+		"[tool result] search\n"+
+		"[tool error] 2: timed out\n"+
+		"Go is great.", got)
+
+	got = renderStream(t, StreamWriterOptions{Verbose: true, HideThinking: true}, toolTurn)
+	assert.Equal(t, "\n[tool call] search\n"+
+		"  arguments: {\"q\":\"go\"}\n"+
+		"[tool result] search\n"+
+		"  result: found\n"+
+		"[tool error] 2: timed out\n"+
+		"Go is great.", got)
+}
+
+func TestStreamToWriterMarkdown(t *testing.T) {
+	t.Parallel()
+
+	got := renderStream(t, StreamWriterOptions{Format: StreamFormatMarkdown, Verbose: true}, toolTurn)
+	assert.Equal(t, "\n> **Thinking**\n>\n> Need to\n> look it up\n\n"+
+		"\n\n**Tool call:** `search`\n\n```json\n{\"q\":\"go\"}\n```\n"+
+		"\n**Tool result:** `search`\n\n```\nfound\n```\n\n"+
+		"\n**Tool error:** `2`: timed out\n\n"+
+		"Go is great.", got)
+}
+
+func TestStreamToWriterANSI(t *testing.T) {
+	t.Parallel()
+
+	got := renderStream(t, StreamWriterOptions{Format: StreamFormatANSI}, toolTurn)
+	assert.Contains(t, got, ansiDim+"Thinking...\nNeed to\nlook it up"+ansiReset)
+	assert.Contains(t, got, ansiCyan+"→ search"+ansiReset)
+	assert.Contains(t, got, ansiRed+"✗ 2: timed out"+ansiReset)
+	assert.True(t, strings.HasSuffix(got, "Go is great."))
+}
+
+func TestStreamToWriterThinkingSummaryOnly(t *testing.T) {
+	t.Parallel()
+
+	got := renderStream(t, StreamWriterOptions{}, []StreamEvent{
+		{Type: StreamEventTypeThinkingSummary, ThinkingStatus: &ThinkingStatus{Summary: "Considered options"}},
+		{Type: StreamEventTypeContent, Content: "Answer"},
+	})
+	assert.Equal(t, "\nThinking...\nConsidered options\n\nAnswer", got)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestStreamToWriterError(t *testing.T) {
+	t.Parallel()
+
+	cb := StreamToWriter(failingWriter{}, StreamWriterOptions{})
+	assert.EqualError(t, cb(StreamEvent{Type: StreamEventTypeContent, Content: "hi"}), "broken pipe")
+}
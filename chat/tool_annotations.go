@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ToolAnnotations are hints about a tool's behavior, mirroring MCP's tool
+// annotations, for deciding which calls need approval and for showing
+// tools in UIs. They're carried in the "annotations" field of a tool's MCP
+// JSON schema, so they pass through wrappers, subprocesses and MCP
+// servers unchanged. Hints come from the tool's author, so they shouldn't
+// be trusted for tools from untrusted sources.
+type ToolAnnotations struct {
+	// Title is a human-readable name for the tool.
+	Title string `json:"title,omitzero"`
+	// ReadOnlyHint means the tool doesn't modify its environment.
+	ReadOnlyHint bool `json:"readOnlyHint,omitzero"`
+	// DestructiveHint means the tool may delete or overwrite data, rather
+	// than only add to it. It's ignored for read-only tools.
+	DestructiveHint *bool `json:"destructiveHint,omitzero"`
+	// IdempotentHint means calling the tool again with the same arguments
+	// has no further effect. It's ignored for read-only tools.
+	IdempotentHint bool `json:"idempotentHint,omitzero"`
+	// OpenWorldHint means the tool interacts with external entities, like
+	// a web search, rather than a closed domain, like a memory store.
+	OpenWorldHint *bool `json:"openWorldHint,omitzero"`
+}
+
+// Destructive reports whether the annotations mark the tool destructive:
+// DestructiveHint is set to true and the tool isn't read-only. Unlike MCP,
+// which assumes tools without annotations are destructive, tools are only
+// destructive if they say so, as most tools predate annotations.
+func (a ToolAnnotations) Destructive() bool {
+	return !a.ReadOnlyHint && a.DestructiveHint != nil && *a.DestructiveHint
+}
+
+// Idempotent reports whether calling the tool again with the same
+// arguments has no further effect, as for read-only tools.
+func (a ToolAnnotations) Idempotent() bool {
+	return a.ReadOnlyHint || a.IdempotentHint
+}
+
+// ToolAnnotationsOf returns the annotations in def's MCP JSON schema. A
+// title at the top level of the schema is used if the annotations don't
+// have one. Tools without annotations, or whose schema doesn't parse,
+// return the zero value.
+func ToolAnnotationsOf(def ToolDef) ToolAnnotations {
+	var mcp struct {
+		Title       string          `json:"title"`
+		Annotations ToolAnnotations `json:"annotations"`
+	}
+	if err := json.Unmarshal([]byte(def.MCPJsonSchema()), &mcp); err != nil {
+		return ToolAnnotations{}
+	}
+	if mcp.Annotations.Title == "" {
+		mcp.Annotations.Title = mcp.Title
+	}
+	return mcp.Annotations
+}
+
+// annotatedTool is a tool with annotations added to its MCP JSON schema.
+type annotatedTool struct {
+	Tool
+	schema string
+}
+
+// Annotated returns tool with its annotations replaced by annotations, for
+// annotating tools that weren't generated with them, such as those from
+// third-party packages. If tool's MCP JSON schema doesn't parse, tool is
+// returned as is, to be rejected when it's registered.
+func Annotated(tool Tool, annotations ToolAnnotations) Tool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(tool.MCPJsonSchema()), &fields); err != nil || fields == nil {
+		return tool
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return tool
+	}
+	fields["annotations"] = data
+	if annotations.Title != "" {
+		title, _ := json.Marshal(annotations.Title)
+		fields["title"] = title
+	}
+	schema, err := json.Marshal(fields)
+	if err != nil {
+		return tool
+	}
+	return &annotatedTool{Tool: tool, schema: string(schema)}
+}
+
+func (t *annotatedTool) MCPJsonSchema() string {
+	return t.schema
+}
+
+// CallWithImages passes the wrapped tool's images through.
+func (t *annotatedTool) CallWithImages(ctx context.Context, input string) (string, []ImageContent) {
+	if it, ok := t.Tool.(ImageTool); ok {
+		return it.CallWithImages(ctx, input)
+	}
+	return t.Tool.Call(ctx, input), nil
+}
+
+// Unwrap returns the tool without the added annotations.
+func (t *annotatedTool) Unwrap() Tool {
+	return t.Tool
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaTool is an echoTool with the given MCP JSON schema.
+type schemaTool struct {
+	echoTool
+	schema string
+}
+
+func (t *schemaTool) MCPJsonSchema() string { return t.schema }
+
+func TestToolAnnotationsOf(t *testing.T) {
+	t.Parallel()
+
+	yes, no := true, false
+	tests := []struct {
+		name   string
+		schema string
+		want   ToolAnnotations
+	}{
+		{name: "none", schema: `{"name": "t", "inputSchema": {"type": "object"}}`},
+		{name: "not JSON", schema: `{`},
+		{
+			name:   "annotations",
+			schema: `{"name": "t", "annotations": {"title": "Delete", "destructiveHint": true, "idempotentHint": true, "openWorldHint": false}}`,
+			want:   ToolAnnotations{Title: "Delete", DestructiveHint: &yes, IdempotentHint: true, OpenWorldHint: &no},
+		},
+		{
+			name:   "top-level title",
+			schema: `{"name": "t", "title": "Read", "annotations": {"readOnlyHint": true}}`,
+			want:   ToolAnnotations{Title: "Read", ReadOnlyHint: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, ToolAnnotationsOf(&schemaTool{schema: tt.schema}))
+		})
+	}
+}
+
+func TestToolAnnotationsHints(t *testing.T) {
+	t.Parallel()
+
+	yes, no := true, false
+	assert.False(t, ToolAnnotations{}.Destructive(), "tools aren't destructive unless they say so")
+	assert.False(t, ToolAnnotations{DestructiveHint: &no}.Destructive())
+	assert.True(t, ToolAnnotations{DestructiveHint: &yes}.Destructive())
+	assert.False(t, ToolAnnotations{ReadOnlyHint: true, DestructiveHint: &yes}.Destructive())
+
+	assert.False(t, ToolAnnotations{}.Idempotent())
+	assert.True(t, ToolAnnotations{IdempotentHint: true}.Idempotent())
+	assert.True(t, ToolAnnotations{ReadOnlyHint: true}.Idempotent())
+}
+
+func TestAnnotated(t *testing.T) {
+	t.Parallel()
+
+	yes := true
+	base := &schemaTool{echoTool: echoTool{name: "rm"}, schema: `{"name": "rm", "inputSchema": {"type": "object"}, "annotations": {"readOnlyHint": true}}`}
+	annotations := ToolAnnotations{Title: "Remove", DestructiveHint: &yes}
+	tool := Annotated(base, annotations)
+
+	assert.Equal(t, annotations, ToolAnnotationsOf(tool))
+	assert.JSONEq(t, `{"name": "rm", "title": "Remove", "inputSchema": {"type": "object"}, "annotations": {"title": "Remove", "destructiveHint": true}}`, tool.MCPJsonSchema())
+	assert.NoError(t, ValidateToolDef(tool))
+	assert.Equal(t, "rm:x", tool.Call(context.Background(), "x"))
+
+	// Annotations survive namespacing, which passes the schema through
+	assert.Equal(t, annotations, ToolAnnotationsOf(Namespaced("fs", tool)))
+
+	unwrapper, ok := tool.(interface{ Unwrap() Tool })
+	require.True(t, ok)
+	assert.Same(t, Tool(base), unwrapper.Unwrap())
+
+	// Tools whose schemas don't parse are left to fail registration
+	broken := &schemaTool{echoTool: echoTool{name: "broken"}, schema: `{`}
+	assert.Same(t, Tool(broken), Annotated(broken, annotations))
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxToolNameLength is the longest tool name every provider accepts.
+	MaxToolNameLength = 64
+	// MaxToolDescriptionLength is the longest tool description every
+	// provider accepts; OpenAI rejects longer ones.
+	MaxToolDescriptionLength = 1024
+)
+
+// toolNamePattern matches the tool names every provider accepts: Gemini
+// requires a leading letter or underscore, and the others allow only
+// letters, digits, underscores, and hyphens.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// ValidateToolDef checks that def can be sent to every provider: its name
+// is at most MaxToolNameLength characters of letters, digits, underscores,
+// and hyphens, starting with a letter or underscore; its description is
+// at most MaxToolDescriptionLength characters; and its MCP JSON schema
+// parses and has an inputSchema describing an object. All the problems
+// found are returned together.
+func ValidateToolDef(def ToolDef) error {
+	var problems []error
+	name := def.Name()
+	switch {
+	case name == "":
+		problems = append(problems, errors.New("missing name"))
+	case len(name) > MaxToolNameLength:
+		problems = append(problems, fmt.Errorf("the name is %d characters long; the limit is %d", len(name), MaxToolNameLength))
+	case !toolNamePattern.MatchString(name):
+		problems = append(problems, errors.New("the name must start with a letter or underscore and contain only letters, digits, underscores, and hyphens"))
+	}
+	if n := len(def.Description()); n > MaxToolDescriptionLength {
+		problems = append(problems, fmt.Errorf("the description is %d characters long; the limit is %d", n, MaxToolDescriptionLength))
+	}
+
+	var mcp struct {
+		InputSchema json.RawMessage `json:"inputSchema"`
+	}
+	if err := json.Unmarshal([]byte(def.MCPJsonSchema()), &mcp); err != nil {
+		problems = append(problems, fmt.Errorf("the MCP JSON schema doesn't parse: %w", err))
+	} else if len(mcp.InputSchema) == 0 || string(mcp.InputSchema) == "null" {
+		problems = append(problems, errors.New("the MCP JSON schema has no inputSchema"))
+	} else {
+		var input struct {
+			Type any `json:"type"`
+		}
+		if err := json.Unmarshal(mcp.InputSchema, &input); err != nil {
+			problems = append(problems, fmt.Errorf("the inputSchema isn't a JSON object: %w", err))
+		} else if input.Type != "object" {
+			problems = append(problems, fmt.Errorf(`the inputSchema's type is %s, not "object"`, formatSchemaType(input.Type)))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &InvalidToolError{Name: name, Problems: problems}
+}
+
+// InvalidToolError is returned by ValidateToolDef, and when registering a
+// tool that fails it.
+type InvalidToolError struct {
+	Name     string
+	Problems []error
+}
+
+func (e *InvalidToolError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("invalid tool %q: %s", e.Name, strings.Join(msgs, "; "))
+}
+
+func (e *InvalidToolError) Unwrap() []error {
+	return e.Problems
+}
+
+func formatSchemaType(t any) string {
+	if t == nil {
+		return "missing"
+	}
+	data, _ := json.Marshal(t)
+	return string(data)
+}
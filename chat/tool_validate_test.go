@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// defOnly is a ToolDef with fixed fields.
+type defOnly struct {
+	name, description, schema string
+}
+
+func (d defOnly) Name() string          { return d.name }
+func (d defOnly) Description() string   { return d.description }
+func (d defOnly) MCPJsonSchema() string { return d.schema }
+
+func TestValidateToolDef(t *testing.T) {
+	t.Parallel()
+
+	valid := `{"name":"read_file","inputSchema":{"type":"object","properties":{"path":{"type":"string"}}}}`
+	assert.NoError(t, ValidateToolDef(defOnly{name: "read_file", description: "Reads a file", schema: valid}))
+	assert.NoError(t, ValidateToolDef(defOnly{name: "_private-2", schema: valid}))
+	assert.NoError(t, ValidateToolDef(defOnly{name: strings.Repeat("a", MaxToolNameLength), schema: valid}))
+
+	tests := []struct {
+		name    string
+		def     defOnly
+		wantErr string
+	}{
+		{"missing name", defOnly{schema: valid}, `invalid tool "": missing name`},
+		{"long name", defOnly{name: strings.Repeat("a", 65), schema: valid}, "the name is 65 characters long; the limit is 64"},
+		{"name characters", defOnly{name: "read.file", schema: valid}, "the name must start with a letter or underscore"},
+		{"leading digit", defOnly{name: "2read", schema: valid}, "the name must start with a letter or underscore"},
+		{"long description", defOnly{name: "read", description: strings.Repeat("x", 1025), schema: valid}, "the description is 1025 characters long; the limit is 1024"},
+		{"unparseable", defOnly{name: "read", schema: `{"inputSchema":`}, "the MCP JSON schema doesn't parse"},
+		{"no inputSchema", defOnly{name: "read", schema: `{"name":"read"}`}, "the MCP JSON schema has no inputSchema"},
+		{"not an object", defOnly{name: "read", schema: `{"inputSchema":{"type":"string"}}`}, `the inputSchema's type is "string", not "object"`},
+		{"no type", defOnly{name: "read", schema: `{"inputSchema":{}}`}, `the inputSchema's type is missing, not "object"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.ErrorContains(t, ValidateToolDef(tt.def), tt.wantErr)
+		})
+	}
+
+	// All the problems are reported
+	err := ValidateToolDef(defOnly{name: "read.file", description: strings.Repeat("x", 2000), schema: `{}`})
+	var invalid *InvalidToolError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, "read.file", invalid.Name)
+	assert.Len(t, invalid.Problems, 3)
+}
@@ -0,0 +1,23 @@
+package chat
+
+import "context"
+
+// turnIDKey is the context key for turn IDs
+type turnIDKey struct{}
+
+// WithTurnID attaches id to the context as the ID of the turn it is used for, so a single
+// user action can be traced across provider calls, tool executions, and store writes. The
+// providers stamp it on stream events and include it in their logs, and sessions save it on
+// the records of the exchange. Sessions generate an ID for turns whose context has none.
+func WithTurnID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, turnIDKey{}, id)
+}
+
+// GetTurnID returns the turn ID attached to the context by WithTurnID, or "" if there is none.
+func GetTurnID(ctx context.Context) string {
+	id, _ := ctx.Value(turnIDKey{}).(string)
+	return id
+}
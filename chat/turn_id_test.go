@@ -0,0 +1,22 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTurnID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.Empty(t, GetTurnID(ctx))
+
+	ctx = WithTurnID(ctx, "turn-1")
+	assert.Equal(t, "turn-1", GetTurnID(ctx))
+
+	// An empty ID leaves the context's ID in place
+	assert.Equal(t, "turn-1", GetTurnID(WithTurnID(ctx, "")))
+	assert.Equal(t, "turn-2", GetTurnID(WithTurnID(ctx, "turn-2")))
+}
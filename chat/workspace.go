@@ -0,0 +1,42 @@
+package chat
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Workspace is the storage that file tools work in, such as a local
+// directory, memory, or a remote store, so the same tools run against any
+// of them. Names are slash-separated paths relative to the workspace's
+// root, as with fs.FS. Implementations must be safe for concurrent use.
+type Workspace interface {
+	// Open opens the named file for reading.
+	Open(ctx context.Context, name string) (fs.File, error)
+	// WriteFile writes data to the named file, creating the file and its
+	// parent directories as needed. Where the storage allows, existing
+	// files are replaced atomically.
+	WriteFile(ctx context.Context, name string, data []byte) error
+	// ReadDir lists the named directory, sorted by name.
+	ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error)
+	// Stat describes the named file or directory.
+	Stat(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+// workspaceKey is the context key for workspaces
+type workspaceKey struct{}
+
+// WithWorkspace attaches the workspace that tools called with the context
+// should use.
+func WithWorkspace(ctx context.Context, ws Workspace) context.Context {
+	if ws == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, workspaceKey{}, ws)
+}
+
+// GetWorkspace returns the workspace attached to the context by
+// WithWorkspace, or nil if there is none.
+func GetWorkspace(ctx context.Context) Workspace {
+	ws, _ := ctx.Value(workspaceKey{}).(Workspace)
+	return ws
+}
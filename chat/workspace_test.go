@@ -0,0 +1,29 @@
+package chat
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWorkspace struct{}
+
+func (nopWorkspace) Open(context.Context, string) (fs.File, error)          { return nil, fs.ErrNotExist }
+func (nopWorkspace) WriteFile(context.Context, string, []byte) error        { return nil }
+func (nopWorkspace) ReadDir(context.Context, string) ([]fs.DirEntry, error) { return nil, nil }
+func (nopWorkspace) Stat(context.Context, string) (fs.FileInfo, error)      { return nil, fs.ErrNotExist }
+
+func TestWithWorkspace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.Nil(t, GetWorkspace(ctx))
+
+	ctx = WithWorkspace(ctx, nopWorkspace{})
+	assert.Equal(t, nopWorkspace{}, GetWorkspace(ctx))
+
+	// A nil workspace leaves the context's workspace in place
+	assert.Equal(t, nopWorkspace{}, GetWorkspace(WithWorkspace(ctx, nil)))
+}
@@ -11,9 +11,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/bpowers/go-agent/chat"
 	"github.com/bpowers/go-agent/schema"
 	"mvdan.cc/gofumpt/format"
 )
@@ -25,12 +28,12 @@ var (
 
 // MCPTool represents an MCP Tool definition
 type MCPTool struct {
-	Name         string                 `json:"name"`
-	Title        string                 `json:"title,omitzero"`
-	Description  string                 `json:"description"`
-	InputSchema  *schema.JSON           `json:"inputSchema"`
-	OutputSchema *schema.JSON           `json:"outputSchema,omitzero"`
-	Annotations  map[string]interface{} `json:"annotations,omitzero"`
+	Name         string                `json:"name"`
+	Title        string                `json:"title,omitzero"`
+	Description  string                `json:"description"`
+	InputSchema  *schema.JSON          `json:"inputSchema"`
+	OutputSchema *schema.JSON          `json:"outputSchema,omitzero"`
+	Annotations  *chat.ToolAnnotations `json:"annotations,omitzero"`
 }
 
 func main() {
@@ -182,12 +185,19 @@ func run() error {
 	// Extract description from godoc comments directly from AST
 	description := extractDescriptionFromAST(targetFunc)
 
+	title, annotations, err := parseDirectives(targetFunc.Doc)
+	if err != nil {
+		return fmt.Errorf("function %s: %w", *funcName, err)
+	}
+
 	// Create the MCP tool definition
 	tool := &MCPTool{
 		Name:         *funcName,
+		Title:        title,
 		Description:  description,
 		InputSchema:  inputSchema,
 		OutputSchema: outputSchema,
+		Annotations:  annotations,
 	}
 
 	// Extract parameter and return type names for the wrapper function
@@ -254,12 +264,12 @@ func generateOutputSchema(results *ast.FieldList, files []*ast.File, docPkg *doc
 		return nil, fmt.Errorf("function must return either (ResultType, error) or error")
 	}
 
-	// Base schema always includes the error field
+	// Base schema always includes the error field. It isn't required, as
+	// the wrapper leaves it out of results that aren't errors.
 	outputSchema := &schema.JSON{
 		Schema:               schema.URL,
 		Type:                 schema.Object,
 		Properties:           make(map[string]*schema.JSON),
-		Required:             []string{"error"},
 		AdditionalProperties: boolPtr(false),
 	}
 	outputSchema.Properties["error"] = &schema.JSON{
@@ -289,7 +299,7 @@ func generateOutputSchema(results *ast.FieldList, files []*ast.File, docPkg *doc
 		outputSchema.Properties[name] = prop
 	}
 
-	outputSchema.Required = append(append([]string{}, resultSchema.Required...), outputSchema.Required...)
+	outputSchema.Required = slices.Clone(resultSchema.Required)
 
 	return outputSchema, nil
 }
@@ -504,12 +514,9 @@ func generateStructTypeSchema(structType *ast.StructType, files []*ast.File, doc
 				return nil, false, err
 			}
 
-			// Check for enum tag
-			if field.Tag != nil {
-				enumValues := parseEnumTag(field.Tag)
-				if len(enumValues) > 0 && fieldSchema.Type == schema.String {
-					fieldSchema.Enum = enumValues
-				}
+			// Apply enum and const tags
+			if err := applyValueTags(fieldSchema, field.Tag); err != nil {
+				return nil, false, fmt.Errorf("field %s: %w", fieldName, err)
 			}
 
 			// Extract field documentation from doc.Package if available
@@ -679,39 +686,120 @@ func parseJSONTag(tag *ast.BasicLit) (name string, omitempty bool) {
 }
 
 func parseEnumTag(tag *ast.BasicLit) []string {
-	if tag == nil {
+	enumTag, _ := parseTag(tag, "enum")
+	if enumTag == "" {
 		return nil
 	}
 
-	// Remove quotes and backticks
-	tagValue := tag.Value
-	if len(tagValue) >= 2 {
-		tagValue = tagValue[1 : len(tagValue)-1]
+	values := strings.Split(enumTag, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
 	}
 
-	// Find enum tag
-	const enumPrefix = "enum:\""
-	idx := strings.Index(tagValue, enumPrefix)
-	if idx == -1 {
-		return nil
+	return values
+}
+
+// parseTag returns the value of the struct tag key, and whether it's present.
+func parseTag(tag *ast.BasicLit, key string) (string, bool) {
+	if tag == nil {
+		return "", false
 	}
+	tagValue, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return "", false
+	}
+	return reflect.StructTag(tagValue).Lookup(key)
+}
 
-	enumTag := tagValue[idx+len(enumPrefix):]
-	endIdx := strings.Index(enumTag, "\"")
-	if endIdx != -1 {
-		enumTag = enumTag[:endIdx]
+// applyValueTags sets a field schema's enum and const from the field's
+// enum:"a,b" and const:"v" tags, parsing the values as the field's type:
+// strings, integers, numbers, or (for const) booleans. Tags on a slice
+// field apply to its items, and a nullable field's enum also allows null.
+func applyValueTags(fieldSchema *schema.JSON, tag *ast.BasicLit) error {
+	target := fieldSchema
+	if baseType(target) == string(schema.Array) && target.Items != nil {
+		target = target.Items
 	}
+	typ := baseType(target)
 
-	if enumTag == "" {
-		return nil
+	if values := parseEnumTag(tag); len(values) > 0 {
+		if typ != string(schema.String) && typ != string(schema.Integer) && typ != string(schema.Number) {
+			return fmt.Errorf("enum tag on a field of type %s; only strings, integers, and numbers are supported", typ)
+		}
+		enum := make([]any, 0, len(values)+1)
+		for _, raw := range values {
+			v, err := parseTagValue(typ, raw)
+			if err != nil {
+				return fmt.Errorf("enum tag: %w", err)
+			}
+			enum = append(enum, v)
+		}
+		if isNullable(target) {
+			enum = append(enum, nil)
+		}
+		target.Enum = enum
 	}
 
-	values := strings.Split(enumTag, ",")
-	for i := range values {
-		values[i] = strings.TrimSpace(values[i])
+	if raw, ok := parseTag(tag, "const"); ok {
+		v, err := parseTagValue(typ, raw)
+		if err != nil {
+			return fmt.Errorf("const tag: %w", err)
+		}
+		target.Const = v
 	}
 
-	return values
+	return nil
+}
+
+// parseTagValue parses a value from a struct tag as a JSON value of type typ.
+func parseTagValue(typ, raw string) (any, error) {
+	switch typ {
+	case string(schema.String):
+		return raw, nil
+	case string(schema.Integer):
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't an integer", raw)
+		}
+		return v, nil
+	case string(schema.Number):
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", raw)
+		}
+		return v, nil
+	case string(schema.Boolean):
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a boolean", raw)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("values of type %s aren't supported", typ)
+	}
+}
+
+// baseType returns the name of the type s describes, ignoring "null" in
+// nullable types.
+func baseType(s *schema.JSON) string {
+	switch t := s.Type.(type) {
+	case schema.Type:
+		return string(t)
+	case string:
+		return t
+	case []interface{}:
+		for _, name := range t {
+			if name, ok := name.(string); ok && name != string(schema.Null) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func isNullable(s *schema.JSON) bool {
+	types, ok := s.Type.([]interface{})
+	return ok && slices.Contains(types, interface{}(string(schema.Null)))
 }
 
 func generateToolDefFile(tool *MCPTool, funcName, paramTypeName, returnTypeName string, hasResultType bool, inputFile, packageName string) error {
@@ -1012,9 +1100,12 @@ func extractDescriptionFromAST(fn *ast.FuncDecl) string {
 		return fmt.Sprintf("Function %s", fn.Name.Name)
 	}
 
-	// Get the whole doc comment
+	// Get the whole doc comment, without go-agent directives
 	var docLines []string
 	for _, comment := range fn.Doc.List {
+		if _, _, ok := directive(comment.Text); ok {
+			continue
+		}
 		text := comment.Text
 		// Remove the comment prefix (// or /*)
 		if strings.HasPrefix(text, "//") {
@@ -1050,6 +1141,84 @@ func extractDescriptionFromAST(fn *ast.FuncDecl) string {
 	return fullDoc
 }
 
+// directivePrefix starts the directives funcschema reads from a function's
+// doc comment, as in "//go-agent:destructive". gofumpt rewrites them as
+// "// go-agent:destructive", which works too.
+const directivePrefix = "go-agent:"
+
+// directive returns the name and argument of the go-agent directive in the
+// comment text, if it is one.
+func directive(text string) (name, arg string, ok bool) {
+	text, ok = strings.CutPrefix(text, "//")
+	if !ok {
+		return "", "", false
+	}
+	text, ok = strings.CutPrefix(strings.TrimLeft(text, " \t"), directivePrefix)
+	if !ok {
+		return "", "", false
+	}
+	name, arg, _ = strings.Cut(strings.TrimSpace(text), " ")
+	return name, strings.TrimSpace(arg), true
+}
+
+// parseDirectives reads the tool's title and annotations from the
+// go-agent directives in a function's doc comment:
+//
+//	//go-agent:title Pretty Name
+//	//go-agent:readonly
+//	//go-agent:destructive
+//	//go-agent:idempotent
+//	//go-agent:openworld false
+//
+// The hints are true unless followed by false. Annotations are nil if
+// there are no hints.
+func parseDirectives(doc *ast.CommentGroup) (string, *chat.ToolAnnotations, error) {
+	if doc == nil {
+		return "", nil, nil
+	}
+	var title string
+	var annotations chat.ToolAnnotations
+	hints := false
+	for _, comment := range doc.List {
+		name, arg, ok := directive(comment.Text)
+		if !ok {
+			continue
+		}
+		if name == "title" {
+			if arg == "" {
+				return "", nil, fmt.Errorf("%stitle needs a title", directivePrefix)
+			}
+			title = arg
+			continue
+		}
+
+		value := true
+		if arg != "" {
+			var err error
+			if value, err = strconv.ParseBool(arg); err != nil {
+				return "", nil, fmt.Errorf("%s%s takes true or false, not %q", directivePrefix, name, arg)
+			}
+		}
+		switch name {
+		case "readonly":
+			annotations.ReadOnlyHint = value
+		case "destructive":
+			annotations.DestructiveHint = boolPtr(value)
+		case "idempotent":
+			annotations.IdempotentHint = value
+		case "openworld":
+			annotations.OpenWorldHint = boolPtr(value)
+		default:
+			return "", nil, fmt.Errorf("unknown directive %s%s", directivePrefix, name)
+		}
+		hints = true
+	}
+	if !hints {
+		return title, nil, nil
+	}
+	return title, &annotations, nil
+}
+
 func extractFieldDescription(commentGroup *ast.CommentGroup) string {
 	if commentGroup == nil || len(commentGroup.List) == 0 {
 		return ""
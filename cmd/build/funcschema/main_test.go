@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bpowers/go-agent/chat"
 	"github.com/bpowers/go-agent/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -658,9 +659,10 @@ func SimpleFunc(req EmptyRequest) (SimpleResult, error) { return SimpleResult{Va
 				} else if len(typeArr) != 2 || typeArr[0] != "string" || typeArr[1] != "null" {
 					t.Errorf("expected [\"string\", \"null\"] for error, got %v", typeArr)
 				}
-				// All fields should be required for OpenAI compatibility
-				if len(s.Required) != 2 {
-					t.Errorf("expected 2 required fields, got %v", s.Required)
+				// The error field isn't required, as results that aren't
+				// errors leave it out
+				if len(s.Required) != 1 || s.Required[0] != "Value" {
+					t.Errorf("expected only Value to be required, got %v", s.Required)
 				}
 			},
 		},
@@ -1317,8 +1319,8 @@ func DeleteFile(ctx context.Context, req DeleteRequest) error {
 	if len(outputSchema.Properties) != 1 || outputSchema.Properties["error"] == nil {
 		t.Fatalf("expected only error property in output schema, got %+v", outputSchema.Properties)
 	}
-	if len(outputSchema.Required) != 1 || outputSchema.Required[0] != "error" {
-		t.Fatalf("expected only error to be required, got %v", outputSchema.Required)
+	if len(outputSchema.Required) != 0 {
+		t.Fatalf("expected nothing to be required, got %v", outputSchema.Required)
 	}
 
 	if len(inputSchema.Properties) != 1 || inputSchema.Properties["Path"] == nil {
@@ -1473,7 +1475,7 @@ func SetStatus(ctx context.Context, req StatusRequest) StatusResult {
 
 	levelSchema := inputSchema.Properties["level"]
 	assert.Equal(t, schema.String, levelSchema.Type)
-	assert.Equal(t, []string{"info", "warning", "error"}, levelSchema.Enum)
+	assert.Equal(t, []any{"info", "warning", "error"}, levelSchema.Enum)
 }
 
 func TestEnumInNestedStruct(t *testing.T) {
@@ -1543,7 +1545,66 @@ func SubmitCausalMap(ctx context.Context, m Map) (MapResult, error) {
 
 	polaritySchema := relProps["polarity"]
 	assert.Equal(t, schema.String, polaritySchema.Type)
-	assert.Equal(t, []string{"+", "-"}, polaritySchema.Enum)
+	assert.Equal(t, []any{"+", "-"}, polaritySchema.Enum)
+}
+
+func TestTypedEnumAndConst(t *testing.T) {
+	t.Parallel()
+
+	inputSchema := func(fields string) (*schema.JSON, error) {
+		code := `package test
+import "context"
+
+type Request struct {
+` + fields + `
+}
+
+func Run(ctx context.Context, req Request) error {
+	return nil
+}`
+		fset := token.NewFileSet()
+		node, err := parser.ParseFile(fset, "test.go", code, parser.ParseComments)
+		require.NoError(t, err)
+		var targetFunc *ast.FuncDecl
+		for _, decl := range node.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				targetFunc = fn
+			}
+		}
+		docPkg, err := doc.NewFromFiles(fset, []*ast.File{node}, "", doc.AllDecls)
+		require.NoError(t, err)
+		return generateInputSchema(targetFunc.Type.Params, []*ast.File{node}, docPkg)
+	}
+
+	s, err := inputSchema("\tPriority int `json:\"priority\" enum:\"1, 2, 3\"`\n" +
+		"\tRatio float64 `json:\"ratio\" enum:\"0.5,1.5\"`\n" +
+		"\tKind string `json:\"kind\" const:\"event\"`\n" +
+		"\tVersion int `json:\"version\" const:\"2\"`\n" +
+		"\tStrict bool `json:\"strict\" const:\"true\"`\n" +
+		"\tLevels []string `json:\"levels\" enum:\"info,error\"`\n" +
+		"\tMode *string `json:\"mode\" enum:\"fast,slow\"`")
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(1), int64(2), int64(3)}, s.Properties["priority"].Enum)
+	assert.Equal(t, []any{0.5, 1.5}, s.Properties["ratio"].Enum)
+	assert.Equal(t, "event", s.Properties["kind"].Const)
+	assert.Equal(t, int64(2), s.Properties["version"].Const)
+	assert.Equal(t, true, s.Properties["strict"].Const)
+	assert.Nil(t, s.Properties["levels"].Enum)
+	assert.Equal(t, []any{"info", "error"}, s.Properties["levels"].Items.Enum)
+	assert.Equal(t, []any{"fast", "slow", nil}, s.Properties["mode"].Enum, "nullable fields also allow null")
+
+	data, err := json.Marshal(s.Properties["priority"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "integer", "enum": [1, 2, 3]}`, string(data))
+
+	// The generated schema validates arguments
+	assert.NoError(t, s.Validate([]byte(`{"priority": 2, "ratio": 0.5, "kind": "event", "version": 2, "strict": true, "levels": ["info"], "mode": null}`)))
+	assert.ErrorContains(t, s.Validate([]byte(`{"priority": 4, "ratio": 0.5, "kind": "event", "version": 2, "strict": true, "levels": [], "mode": "fast"}`)), "$.priority: 4 is not one of 1, 2, 3")
+
+	_, err = inputSchema("\tPriority int `json:\"priority\" enum:\"1,high\"`")
+	assert.ErrorContains(t, err, `field Priority: enum tag: "high" isn't an integer`)
+	_, err = inputSchema("\tOn bool `json:\"on\" enum:\"true\"`")
+	assert.ErrorContains(t, err, "only strings, integers, and numbers are supported")
 }
 
 func TestCrossFileTypeReferences(t *testing.T) {
@@ -1856,3 +1917,112 @@ func InlineFunc(ctx context.Context, req struct {
 		t.Fatalf("expected error about named struct type, got: %v", err)
 	}
 }
+
+func TestDirectives(t *testing.T) {
+	dir := t.TempDir()
+	source := `package test
+
+import "context"
+
+type DeleteRequest struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// DeleteFile deletes a file.
+//
+//go-agent:title Delete file
+//go-agent:destructive
+// go-agent:idempotent
+//go-agent:openworld false
+func DeleteFile(ctx context.Context, req DeleteRequest) error {
+	return nil
+}
+
+// Bad has a typo in its directive.
+//
+//go-agent:destrutive
+func Bad(ctx context.Context) error {
+	return nil
+}
+`
+	inputPath := filepath.Join(dir, "delete.go")
+	require.NoError(t, os.WriteFile(inputPath, []byte(source), 0o644))
+
+	origFuncName := *funcName
+	origInputFile := *inputFile
+	t.Cleanup(func() {
+		*funcName = origFuncName
+		*inputFile = origInputFile
+	})
+	*inputFile = inputPath
+
+	*funcName = "DeleteFile"
+	require.NoError(t, run())
+	generated, err := os.ReadFile(filepath.Join(dir, "deletefile_tool.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), `"title":"Delete file","description":"Deletes a file."`)
+	assert.Contains(t, string(generated), `"annotations":{"destructiveHint":true,"idempotentHint":true,"openWorldHint":false}`)
+
+	*funcName = "Bad"
+	assert.ErrorContains(t, run(), "unknown directive go-agent:destrutive")
+}
+
+func TestParseDirectives(t *testing.T) {
+	t.Parallel()
+
+	comments := func(lines ...string) *ast.CommentGroup {
+		group := &ast.CommentGroup{}
+		for _, line := range lines {
+			group.List = append(group.List, &ast.Comment{Text: line})
+		}
+		return group
+	}
+
+	title, annotations, err := parseDirectives(comments("// ReadFile reads a file.", "//go-agent:readonly", "//go-agent:idempotent false"))
+	require.NoError(t, err)
+	assert.Empty(t, title)
+	assert.Equal(t, &chat.ToolAnnotations{ReadOnlyHint: true}, annotations)
+
+	title, annotations, err = parseDirectives(comments("// F does things.", "//go-agent:title  Things "))
+	require.NoError(t, err)
+	assert.Equal(t, "Things", title)
+	assert.Nil(t, annotations, "titles alone don't need annotations")
+
+	_, _, err = parseDirectives(comments("//go-agent:title"))
+	assert.ErrorContains(t, err, "needs a title")
+	_, _, err = parseDirectives(comments("//go-agent:readonly maybe"))
+	assert.ErrorContains(t, err, `takes true or false, not "maybe"`)
+
+	// Look-alikes aren't directives
+	_, annotations, err = parseDirectives(comments("// See go-agent.dev for more.", "/* go-agent:readonly */"))
+	require.NoError(t, err)
+	assert.Nil(t, annotations)
+}
+
+// BenchmarkGenerateSchemas measures generating the input and output schemas
+// of DatasetGet, in testdata.go, from its parsed package.
+func BenchmarkGenerateSchemas(b *testing.B) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "testdata.go", nil, parser.ParseComments)
+	require.NoError(b, err)
+	files := []*ast.File{node}
+	docPkg, err := doc.NewFromFiles(fset, files, "", doc.AllDecls|doc.PreserveAST)
+	require.NoError(b, err)
+	var fn *ast.FuncDecl
+	for _, decl := range node.Decls {
+		if f, ok := decl.(*ast.FuncDecl); ok && f.Name.Name == "DatasetGet" {
+			fn = f
+		}
+	}
+	require.NotNil(b, fn)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := generateInputSchema(fn.Type.Params, files, docPkg); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := generateOutputSchema(fn.Type.Results, files, docPkg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -231,13 +231,13 @@ func generateIdentSchema(typeName string) (*schema.JSON, error) {
 		// Custom enum type from sdjson
 		return &schema.JSON{
 			Type: schema.String,
-			Enum: []string{"variable", "stock", "flow"},
+			Enum: []any{"variable", "stock", "flow"},
 		}, nil
 	case "Polarity":
 		// Custom enum type from sdjson
 		return &schema.JSON{
 			Type: schema.String,
-			Enum: []string{"+", "-"},
+			Enum: []any{"+", "-"},
 		}, nil
 	case "Variable", "Relationship", "Specs", "Point", "GraphicalFunction":
 		// These are other types in the sdjson package
@@ -126,7 +126,7 @@ func TestGenerateIdentSchema(t *testing.T) {
 			typeName: "VariableType",
 			expected: &schema.JSON{
 				Type: schema.String,
-				Enum: []string{"variable", "stock", "flow"},
+				Enum: []any{"variable", "stock", "flow"},
 			},
 		},
 		{
@@ -134,7 +134,7 @@ func TestGenerateIdentSchema(t *testing.T) {
 			typeName: "Polarity",
 			expected: &schema.JSON{
 				Type: schema.String,
-				Enum: []string{"+", "-"},
+				Enum: []any{"+", "-"},
 			},
 		},
 		{
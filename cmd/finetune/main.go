@@ -0,0 +1,84 @@
+// Command finetune converts sessions stored in SQLite into a fine-tuning
+// dataset.
+//
+// Usage:
+//
+//	finetune --db path/to/sessions.db [--format openai|anthropic] [--session ID,...]
+//	         [--only-successful] [--strip-tools] [--anonymize] [--out dataset.jsonl]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bpowers/go-agent/persistence/finetune"
+	"github.com/bpowers/go-agent/persistence/sqlitestore"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("finetune", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := fs.String("db", "", "path to SQLite database")
+	format := fs.String("format", "openai", "dataset format: openai or anthropic")
+	sessions := fs.String("session", "", "comma-separated session IDs to export (default: all sessions)")
+	onlySuccessful := fs.Bool("only-successful", false, "skip sessions with failures, tool errors, or no final response")
+	stripTools := fs.Bool("strip-tools", false, "remove tool calls and results, keeping only the conversation's text")
+	anonymize := fs.Bool("anonymize", false, "redact email addresses, IP addresses, phone numbers, and API keys")
+	outPath := fs.String("out", "", "output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+
+	opts := finetune.Options{
+		Format:         finetune.Format(*format),
+		OnlySuccessful: *onlySuccessful,
+		StripTools:     *stripTools,
+		Anonymize:      *anonymize,
+	}
+	if *sessions != "" {
+		opts.SessionIDs = strings.Split(*sessions, ",")
+	}
+
+	store, err := sqlitestore.New(*dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer store.Close()
+
+	out := stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	stats, err := finetune.Write(out, store, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stderr, "wrote %d examples\n", stats.Written)
+	for _, reason := range slices.Sorted(maps.Keys(stats.Skipped)) {
+		fmt.Fprintf(stderr, "skipped %d sessions: %s\n", stats.Skipped[reason], reason)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+	"github.com/bpowers/go-agent/persistence/sqlitestore"
+)
+
+func createSessions(t *testing.T) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "sessions.db")
+	store, err := sqlitestore.New(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now()
+	add := func(sessionID string, role chat.Role, text string) {
+		now = now.Add(time.Millisecond)
+		_, err := store.AddRecord(sessionID, persistence.Record{
+			Role:      role,
+			Contents:  []chat.Content{{Text: text}},
+			Live:      true,
+			Status:    persistence.RecordStatusSuccess,
+			Timestamp: now,
+		})
+		require.NoError(t, err)
+	}
+	add("complete", chat.UserRole, "Hi, I'm at 555-123-4567")
+	add("complete", chat.AssistantRole, "Hello!")
+	add("unanswered", chat.UserRole, "Anyone there?")
+	return dbPath
+}
+
+func TestRun(t *testing.T) {
+	dbPath := createSessions(t)
+
+	var stdout, stderr strings.Builder
+	require.NoError(t, run([]string{"--db", dbPath, "--only-successful", "--anonymize"}, &stdout, &stderr))
+	assert.JSONEq(t, `{"messages": [
+		{"role": "user", "content": "Hi, I'm at [PHONE]"},
+		{"role": "assistant", "content": "Hello!"}
+	]}`, stdout.String())
+	assert.Equal(t, "wrote 1 examples\nskipped 1 sessions: incomplete\n", stderr.String())
+}
+
+func TestRunOutputFile(t *testing.T) {
+	dbPath := createSessions(t)
+	outPath := filepath.Join(t.TempDir(), "dataset.jsonl")
+
+	var stdout, stderr strings.Builder
+	require.NoError(t, run([]string{"--db", dbPath, "--format", "anthropic", "--session", "complete,unanswered", "--out", outPath}, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+
+	data, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+}
+
+func TestRunErrors(t *testing.T) {
+	var stdout, stderr strings.Builder
+	assert.EqualError(t, run(nil, &stdout, &stderr), "--db is required")
+
+	dbPath := createSessions(t)
+	err := run([]string{"--db", dbPath, "--format", "csv"}, &stdout, &stderr)
+	assert.ErrorContains(t, err, `unknown format "csv"`)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// errLintProblems is returned by runLintTools when it reported problems.
+var errLintProblems = errors.New("tool definitions have problems")
+
+var knownProviders = []string{"openai", "claude", "gemini"}
+
+// geminiKeywords are the JSON Schema keywords the Gemini client converts or
+// can safely ignore. The constraints of others are dropped.
+var geminiKeywords = map[string]bool{
+	"type": true, "description": true, "properties": true, "required": true, "items": true,
+	"enum": true, "const": true, "anyOf": true, "format": true, "pattern": true,
+	"minimum": true, "maximum": true, "minLength": true, "maxLength": true, "minItems": true, "maxItems": true,
+	"$schema": true, "title": true, "default": true, "examples": true,
+}
+
+// lintProblem is a problem with a generated tool definition.
+type lintProblem struct {
+	Pos     token.Position
+	Tool    string
+	Message string
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Pos, p.Tool, p.Message)
+}
+
+func runLintTools(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("lint-tools", flag.ExitOnError)
+	providers := fs.String("providers", strings.Join(knownProviders, ","), "comma-separated providers to check for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var gemini bool
+	for _, provider := range strings.Split(*providers, ",") {
+		provider = strings.TrimSpace(provider)
+		if !slices.Contains(knownProviders, provider) {
+			return fmt.Errorf("unknown provider %q; known providers are %s", provider, strings.Join(knownProviders, ", "))
+		}
+		gemini = gemini || provider == "gemini"
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	files, err := toolFiles(patterns)
+	if err != nil {
+		return err
+	}
+
+	var problems []lintProblem
+	for _, path := range files {
+		fileProblems, err := lintToolFile(path, gemini)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, fileProblems...)
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return errLintProblems
+	}
+	return nil
+}
+
+// toolFiles returns the *_tool.go files matched by patterns: files,
+// directories, or directory trees written as dir/....
+func toolFiles(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		if root, ok := strings.CutSuffix(pattern, "..."); ok {
+			root = filepath.Clean(strings.TrimSuffix(root, "/"))
+			if root == "" {
+				root = "."
+			}
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					name := d.Name()
+					if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if strings.HasSuffix(path, "_tool.go") {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+			}
+			continue
+		}
+
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(pattern, "*_tool.go"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// staticToolDef is a tool definition read from a generated file.
+type staticToolDef struct {
+	Def         string `json:"-"`
+	ToolName    string `json:"name"`
+	Desc        string `json:"description"`
+	InputSchema any    `json:"inputSchema"`
+}
+
+func (d staticToolDef) MCPJsonSchema() string { return d.Def }
+func (d staticToolDef) Name() string          { return d.ToolName }
+func (d staticToolDef) Description() string   { return d.Desc }
+
+// lintToolFile checks the tool definition in a file generated by
+// funcschema. Other files are skipped.
+func lintToolFile(path string, gemini bool) ([]lintProblem, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if !ast.IsGenerated(file) || !strings.Contains(file.Comments[0].Text(), "funcschema") {
+		return nil, nil
+	}
+
+	var problems []lintProblem
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != "MCPJsonSchema" || fn.Body == nil || len(fn.Body.List) != 1 {
+			continue
+		}
+		ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			continue
+		}
+		lit, ok := ret.Results[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+		pos := fset.Position(lit.Pos())
+		def, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to read the tool definition: %w", pos, err)
+		}
+
+		tool := staticToolDef{Def: def}
+		_ = json.Unmarshal([]byte(def), &tool)
+		name := tool.ToolName
+		if name == "" {
+			name = "(unnamed)"
+		}
+		for _, msg := range lintToolDef(tool, gemini) {
+			problems = append(problems, lintProblem{Pos: pos, Tool: name, Message: msg})
+		}
+	}
+	return problems, nil
+}
+
+// lintToolDef returns the problems with a tool definition.
+func lintToolDef(tool staticToolDef, gemini bool) []string {
+	var msgs []string
+	if err := chat.ValidateToolDef(tool); err != nil {
+		var invalid *chat.InvalidToolError
+		if errors.As(err, &invalid) {
+			for _, p := range invalid.Problems {
+				msgs = append(msgs, p.Error())
+			}
+		} else {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if schema, ok := tool.InputSchema.(map[string]any); ok && gemini {
+		msgs = append(msgs, geminiSchemaProblems(schema, "$")...)
+	}
+	return msgs
+}
+
+// geminiSchemaProblems returns the constraints in schema that the Gemini
+// client drops because Gemini doesn't support them.
+func geminiSchemaProblems(schema map[string]any, path string) []string {
+	var msgs []string
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value := schema[key]
+		switch {
+		case key == "additionalProperties":
+			if value != false {
+				msgs = append(msgs, fmt.Sprintf("%s: Gemini doesn't support additionalProperties, so the model sees an object without properties", path))
+			}
+		case !geminiKeywords[key]:
+			msgs = append(msgs, fmt.Sprintf("%s: Gemini doesn't support %q, so it's dropped", path, key))
+		}
+	}
+
+	if props, ok := schema["properties"].(map[string]any); ok {
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if prop, ok := props[name].(map[string]any); ok {
+				msgs = append(msgs, geminiSchemaProblems(prop, path+"."+name)...)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		msgs = append(msgs, geminiSchemaProblems(items, path+"[]")...)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for i, v := range anyOf {
+			if variant, ok := v.(map[string]any); ok {
+				msgs = append(msgs, geminiSchemaProblems(variant, fmt.Sprintf("%s.anyOf[%d]", path, i))...)
+			}
+		}
+	}
+	return msgs
+}
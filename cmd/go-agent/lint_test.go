@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const generatedHeader = "// Code generated by funcschema. DO NOT EDIT.\n\npackage tools\n\n"
+
+func writeToolFile(t *testing.T, dir, name, def string) {
+	t.Helper()
+	src := generatedHeader + "type tool struct{}\n\nfunc (tool) MCPJsonSchema() string {\n\treturn `" + def + "`\n}\n"
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+}
+
+func TestRunLintTools(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeToolFile(t, dir, "good_tool.go", `{"name":"Good","description":"Fine","inputSchema":{"type":"object","properties":{"path":{"type":"string","minLength":1}},"additionalProperties":false}}`)
+	writeToolFile(t, filepath.Join(dir, "sub"), "bad_tool.go",
+		`{"name":"ThisToolNameIsFarTooLongForOpenAIWhichLimitsFunctionNamesToSixtyFourChars","description":"Bad","inputSchema":{"type":"object","properties":{"labels":{"type":"object","additionalProperties":true},"n":{"type":"integer","multipleOf":2}}}}`)
+	// Hand-written files and other files are skipped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manual_tool.go"), []byte("package tools\n\nfunc (tool) MCPJsonSchema() string { return `{}` }\n"), 0o644))
+	writeToolFile(t, filepath.Join(dir, "testdata"), "skipped_tool.go", `{}`)
+
+	var out bytes.Buffer
+	err := runLintTools([]string{dir + "/..."}, &out)
+	assert.ErrorIs(t, err, errLintProblems)
+	bad := filepath.Join(dir, "sub", "bad_tool.go") + ":8:9: ThisToolNameIsFarTooLongForOpenAIWhichLimitsFunctionNamesToSixtyFourChars: "
+	assert.Equal(t, bad+"the name is 73 characters long; the limit is 64\n"+
+		bad+"$.labels: Gemini doesn't support additionalProperties, so the model sees an object without properties\n"+
+		bad+"$.n: Gemini doesn't support \"multipleOf\", so it's dropped\n", out.String())
+
+	// Without Gemini, only the name is a problem
+	out.Reset()
+	err = runLintTools([]string{"--providers", "openai,claude", filepath.Join(dir, "sub")}, &out)
+	assert.ErrorIs(t, err, errLintProblems)
+	assert.Equal(t, bad+"the name is 73 characters long; the limit is 64\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runLintTools([]string{filepath.Join(dir, "good_tool.go")}, &out))
+	assert.Empty(t, out.String())
+
+	assert.ErrorContains(t, runLintTools([]string{"--providers", "mistral", dir}, &out), `unknown provider "mistral"`)
+}
+
+func TestRunLintToolsRepo(t *testing.T) {
+	t.Parallel()
+
+	// The repository's generated tools pass
+	var out bytes.Buffer
+	require.NoError(t, runLintTools([]string{"../../..."}, &out), out.String())
+}
@@ -0,0 +1,58 @@
+// Command go-agent holds developer tools for applications built with
+// go-agent.
+//
+// Usage:
+//
+//	go-agent lint-tools [--providers openai,claude,gemini] [packages]
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	cmd := os.Args[1]
+	switch cmd {
+	case "lint-tools":
+		if err := runLintTools(os.Args[2:], os.Stdout); err != nil {
+			if !errors.Is(err, errLintProblems) {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "-h", "--help", "help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, `go-agent - developer tools for go-agent applications
+
+Usage:
+  go-agent lint-tools [--providers <list>] [packages]
+      Check the tool definitions funcschema generated (*_tool.go files)
+      against the constraints of each provider: name length and
+      characters, description length, a parseable schema with an object
+      inputSchema, and, for Gemini, the subset of JSON Schema it
+      supports. Packages are directories, or trees like ./... (the
+      default). Exits with status 1 if there are problems.
+
+      --providers  comma-separated providers to check for
+                   (default openai,claude,gemini)
+
+Examples:
+  go-agent lint-tools ./...
+  go-agent lint-tools --providers openai ./tools
+`)
+}
@@ -3,15 +3,24 @@
 // Usage:
 //
 //	sessionview list --db path/to/sessions.db
-//	sessionview show --db path/to/sessions.db --session SESSION_ID [--format json|jsonl]
+//	sessionview show --db path/to/sessions.db --session SESSION_ID [--format json|jsonl] [--follow]
+//	sessionview stats --db path/to/sessions.db [--session SESSION_ID]
+//	sessionview compress --db path/to/sessions.db [--min-size BYTES]
+//	sessionview migrate --db path/to/sessions.db [--dry-run]
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"time"
 
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/persistence"
 	"github.com/bpowers/go-agent/persistence/sqlitestore"
 )
 
@@ -33,6 +42,21 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "stats":
+		if err := runStats(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "compress":
+		if err := runCompress(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "-h", "--help", "help":
 		printUsage()
 	default:
@@ -49,8 +73,24 @@ Usage:
   sessionview list --db <path>
       List all session IDs in the database
 
-  sessionview show --db <path> --session <id> [--format json|jsonl]
-      Show records for a session (default format: json)
+  sessionview show --db <path> --session <id> [--format json|jsonl] [--follow]
+      Show records for a session (default format: json). With --follow,
+      keep printing new records as they are written, as JSON Lines,
+      until interrupted
+
+  sessionview stats --db <path> [--session <id>]
+      Show how much space record contents take, and how much
+      compression saves. With --session, show the session's token
+      use, turn latency, and tool calls instead
+
+  sessionview compress --db <path> [--min-size <bytes>]
+      Compress the contents of existing records of at least min-size
+      bytes (default 1024)
+
+  sessionview migrate --db <path> [--dry-run]
+      Upgrade the database schema to the latest version. Other commands
+      do this as they open the database; with --dry-run, only list the
+      migrations that would be applied
 
 Formats:
   json   - Output as a JSON array (default)
@@ -60,6 +100,10 @@ Examples:
   sessionview list --db ./sessions.db
   sessionview show --db ./sessions.db --session abc123
   sessionview show --db ./sessions.db --session abc123 --format jsonl | jq .
+  sessionview show --db ./sessions.db --session abc123 --follow
+  sessionview stats --db ./sessions.db --session abc123
+  sessionview compress --db ./sessions.db
+  sessionview migrate --db ./sessions.db --dry-run
 `)
 }
 
@@ -92,11 +136,129 @@ func runList(args []string) error {
 	return nil
 }
 
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to SQLite database")
+	sessionID := fs.String("session", "", "session ID to analyze")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+
+	store, err := sqlitestore.New(*dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer store.Close()
+
+	if *sessionID != "" {
+		records, err := store.GetAllRecords(*sessionID)
+		if err != nil {
+			return fmt.Errorf("get records: %w", err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("no records found for session: %s", *sessionID)
+		}
+		for _, line := range agent.AnalyzeRecords(records).Summary() {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	stats, err := store.CompressionStats()
+	if err != nil {
+		return fmt.Errorf("compression stats: %w", err)
+	}
+
+	fmt.Printf("records:            %d (%d compressed)\n", stats.Records, stats.Compressed)
+	fmt.Printf("contents stored:    %d bytes\n", stats.StoredBytes)
+	fmt.Printf("contents expanded:  %d bytes\n", stats.UncompressedBytes)
+	fmt.Printf("saved:              %d bytes (%.1f%%)\n", stats.UncompressedBytes-stats.StoredBytes, 100*stats.Savings())
+
+	return nil
+}
+
+func runCompress(args []string) error {
+	fs := flag.NewFlagSet("compress", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to SQLite database")
+	minSize := fs.Int("min-size", 1024, "compress records whose contents are at least this many bytes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+	if *minSize <= 0 {
+		return fmt.Errorf("--min-size must be positive")
+	}
+
+	store, err := sqlitestore.New(*dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer store.Close()
+
+	n, err := store.CompressRecords(*minSize)
+	if err != nil {
+		return fmt.Errorf("compress records: %w", err)
+	}
+
+	fmt.Printf("compressed %d records\n", n)
+
+	return nil
+}
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to SQLite database")
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+
+	version, pending, err := sqlitestore.Pending(*dbPath)
+	if err != nil {
+		return fmt.Errorf("check migrations: %w", err)
+	}
+
+	fmt.Printf("schema version %d\n", version)
+	if len(pending) == 0 {
+		fmt.Println("up to date")
+		return nil
+	}
+
+	verb := "applied"
+	if *dryRun {
+		verb = "pending"
+	} else {
+		store, err := sqlitestore.New(*dbPath)
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer store.Close()
+	}
+	for _, m := range pending {
+		fmt.Printf("%s %d %s\n", verb, m.Version, m.Name)
+	}
+
+	return nil
+}
+
 func runShow(args []string) error {
 	fs := flag.NewFlagSet("show", flag.ExitOnError)
 	dbPath := fs.String("db", "", "path to SQLite database")
 	sessionID := fs.String("session", "", "session ID to display")
 	format := fs.String("format", "json", "output format: json or jsonl")
+	follow := fs.Bool("follow", false, "keep printing new records as they are written, as JSON Lines")
+	interval := fs.Duration("interval", 500*time.Millisecond, "how often to check for new records with --follow")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -110,6 +272,19 @@ func runShow(args []string) error {
 	if *format != "json" && *format != "jsonl" {
 		return fmt.Errorf("--format must be 'json' or 'jsonl'")
 	}
+	if *follow {
+		// A JSON array can't be printed before it is complete
+		formatSet := false
+		fs.Visit(func(f *flag.Flag) {
+			formatSet = formatSet || f.Name == "format"
+		})
+		if formatSet && *format != "jsonl" {
+			return fmt.Errorf("--follow requires --format jsonl")
+		}
+		if *interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+	}
 
 	store, err := sqlitestore.New(*dbPath)
 	if err != nil {
@@ -117,6 +292,12 @@ func runShow(args []string) error {
 	}
 	defer store.Close()
 
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return followRecords(ctx, store, *sessionID, *interval, os.Stdout)
+	}
+
 	records, err := store.GetAllRecords(*sessionID)
 	if err != nil {
 		return fmt.Errorf("get records: %w", err)
@@ -146,3 +327,40 @@ func runShow(args []string) error {
 
 	return nil
 }
+
+// followRecords prints the session's records as JSON Lines, then checks for
+// new ones every interval and prints them until ctx is done. Records are
+// printed once, when first seen; later changes to them, like being marked
+// dead by compaction, aren't shown.
+func followRecords(ctx context.Context, store persistence.Store, sessionID string, interval time.Duration, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastID int64
+	for {
+		records, err := store.GetAllRecords(sessionID)
+		if err != nil {
+			return fmt.Errorf("get records: %w", err)
+		}
+		// IDs increase as records are added, though records are ordered
+		// by timestamp
+		newLastID := lastID
+		for _, r := range records {
+			if r.ID <= lastID {
+				continue
+			}
+			if err := enc.Encode(r); err != nil {
+				return fmt.Errorf("encode jsonl: %w", err)
+			}
+			newLastID = max(newLastID, r.ID)
+		}
+		lastID = newLastID
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
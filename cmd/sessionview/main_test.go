@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -151,6 +153,80 @@ func TestRunList_NonexistentDB(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRunCompressAndStats(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	populateTestData(t, dbPath)
+
+	store, err := sqlitestore.New(dbPath)
+	require.NoError(t, err)
+	_, err = store.AddRecord("session-xyz789", persistence.Record{
+		Role:      chat.ToolRole,
+		Contents:  []chat.Content{{Text: strings.Repeat("line of output\n", 200)}},
+		Live:      true,
+		Timestamp: time.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	output := captureOutput(t, func() {
+		require.NoError(t, runCompress([]string{"--db", dbPath}))
+	})
+	assert.Equal(t, "compressed 1 records\n", output)
+
+	output = captureOutput(t, func() {
+		require.NoError(t, runStats([]string{"--db", dbPath}))
+	})
+	assert.Contains(t, output, "6 (1 compressed)")
+
+	// Compressed records are shown like any other
+	output = captureOutput(t, func() {
+		require.NoError(t, runShow([]string{"--db", dbPath, "--session", "session-xyz789"}))
+	})
+	assert.Contains(t, output, "line of output")
+}
+
+func TestRunStatsSession(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	populateTestData(t, dbPath)
+
+	output := captureOutput(t, func() {
+		require.NoError(t, runStats([]string{"--db", dbPath, "--session", "session-abc123"}))
+	})
+	assert.Contains(t, output, "Turns: 1\n")
+	assert.Contains(t, output, "Tool calls: calculator 1\n")
+
+	err := runStats([]string{"--db", dbPath, "--session", "missing"})
+	assert.ErrorContains(t, err, "no records found")
+}
+
+func TestRunCompress_InvalidMinSize(t *testing.T) {
+	err := runCompress([]string{"--db", "test.db", "--min-size", "0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--min-size must be positive")
+}
+
+func TestRunMigrate(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	output := captureOutput(t, func() {
+		require.NoError(t, runMigrate([]string{"--db", dbPath, "--dry-run"}))
+	})
+	assert.Equal(t, "schema version 0\npending 1 baseline\npending 2 turn_id\n", output)
+
+	output = captureOutput(t, func() {
+		require.NoError(t, runMigrate([]string{"--db", dbPath}))
+	})
+	assert.Equal(t, "schema version 0\napplied 1 baseline\napplied 2 turn_id\n", output)
+
+	output = captureOutput(t, func() {
+		require.NoError(t, runMigrate([]string{"--db", dbPath, "--dry-run"}))
+	})
+	assert.Equal(t, "schema version 2\nup to date\n", output)
+}
+
 func TestRunShow_JSON(t *testing.T) {
 	dbPath, cleanup := createTestDB(t)
 	defer cleanup()
@@ -250,6 +326,71 @@ func TestRunShow_InvalidFormat(t *testing.T) {
 	assert.Contains(t, err.Error(), "--format must be 'json' or 'jsonl'")
 }
 
+func TestRunShow_FollowRequiresJSONL(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	err := runShow([]string{"--db", dbPath, "--session", "abc", "--format", "json", "--follow"})
+	assert.EqualError(t, err, "--follow requires --format jsonl")
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func TestFollowRecords(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	populateTestData(t, dbPath)
+
+	store, err := sqlitestore.New(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out lockedBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- followRecords(ctx, store, "session-abc123", 10*time.Millisecond, &out)
+	}()
+
+	require.Eventually(t, func() bool { return len(out.lines()) == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	// Records written while following are printed once, even if they sort
+	// before records already printed
+	_, err = store.AddRecord("session-abc123", persistence.Record{
+		Role:      chat.UserRole,
+		Contents:  []chat.Content{{Text: "And 3+3?"}},
+		Live:      true,
+		Timestamp: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(out.lines()) == 5 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	lines := out.lines()
+	require.Len(t, lines, 5)
+	var last persistence.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[4]), &last))
+	assert.Equal(t, "And 3+3?", last.GetText())
+}
+
 func TestRunShow_EmptySession(t *testing.T) {
 	dbPath, cleanup := createTestDB(t)
 	defer cleanup()
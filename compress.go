@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const (
+	defaultCompressionRatio    = 0.5
+	defaultCompressionKeepLast = 4
+)
+
+// PromptCompression configures trimming of older messages' text before the
+// prompt is sent, in the spirit of LLMLingua but without a model: lines
+// repeated from earlier in the conversation are dropped, and each remaining
+// line keeps only its most informative words, those rarest in the
+// conversation, dropping filler and stop words first. It is cheaper than
+// compaction, which calls an LLM, and a lower threshold than the compaction
+// threshold puts off compaction by keeping the prompt smaller.
+//
+// Only what is sent is compressed; the session's records keep the original
+// text. Tool calls, tool results holding JSON, thinking, and fenced code
+// blocks are sent unchanged, as are the most recent messages.
+type PromptCompression struct {
+	// Threshold is how full the context window must be, from 0 to 1, for
+	// the prompt to be compressed. 0 compresses every prompt.
+	Threshold float64
+	// Ratio is the fraction of each line's words to keep, from 0 to 1.
+	// Defaults to 0.5.
+	Ratio float64
+	// KeepLast is the number of most recent messages sent unchanged.
+	// Defaults to 4.
+	KeepLast int
+}
+
+// WithPromptCompression compresses the text of older messages in prompts to
+// the LLM once the context window is fuller than pc.Threshold.
+func WithPromptCompression(pc PromptCompression) SessionOption {
+	return func(opts *sessionOptions) {
+		if pc.Ratio <= 0 || pc.Ratio > 1 {
+			pc.Ratio = defaultCompressionRatio
+		}
+		if pc.KeepLast <= 0 {
+			pc.KeepLast = defaultCompressionKeepLast
+		}
+		opts.compression = &pc
+	}
+}
+
+// compressHistory returns msgs with the text of all but the last keepLast
+// messages compressed. msgs is not modified.
+func compressHistory(msgs []chat.Message, keepLast int, ratio float64) []chat.Message {
+	n := len(msgs) - keepLast
+	if n <= 0 {
+		return msgs
+	}
+
+	// Words rare in the conversation carry the most information
+	freq := make(map[string]int)
+	total := 0
+	for _, msg := range msgs {
+		for _, c := range msg.Contents {
+			for _, word := range strings.Fields(compressibleText(c)) {
+				freq[normalizeWord(word)]++
+				total++
+			}
+		}
+	}
+	cc := &compressor{freq: freq, total: total, ratio: ratio, seen: make(map[string]bool)}
+
+	compressed := slices.Clone(msgs)
+	for i := range n {
+		contents := slices.Clone(msgs[i].Contents)
+		for j, c := range contents {
+			text := compressibleText(c)
+			if text == "" {
+				continue
+			}
+			// Providers reject empty content, so text that is entirely
+			// repeated is sent as is
+			short := cc.compress(text)
+			if strings.TrimSpace(short) == "" {
+				continue
+			}
+			if c.ToolResult != nil {
+				result := *c.ToolResult
+				result.Content = short
+				contents[j].ToolResult = &result
+			} else {
+				contents[j].Text = short
+			}
+		}
+		compressed[i] = chat.Message{Role: msgs[i].Role, Contents: contents}
+	}
+	return compressed
+}
+
+// compressibleText returns the text of c that may be compressed: its text,
+// or the content of a tool result that isn't JSON.
+func compressibleText(c chat.Content) string {
+	if c.Text != "" {
+		return c.Text
+	}
+	if c.ToolResult != nil && !json.Valid([]byte(c.ToolResult.Content)) {
+		return c.ToolResult.Content
+	}
+	return ""
+}
+
+// compressor trims text using word frequencies from the whole conversation.
+type compressor struct {
+	freq  map[string]int
+	total int
+	ratio float64
+	// seen holds the lines already sent, to drop repeats
+	seen map[string]bool
+}
+
+// compress drops repeated lines from text and the least informative words
+// from the rest, leaving fenced code blocks unchanged.
+func (cc *compressor) compress(text string) string {
+	var out []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			out = append(out, line)
+			continue
+		}
+		if inCode || trimmed == "" {
+			out = append(out, line)
+			continue
+		}
+		if cc.seen[trimmed] {
+			continue
+		}
+		cc.seen[trimmed] = true
+		if kept := cc.compressLine(trimmed); kept != "" {
+			out = append(out, kept)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// compressLine keeps the ratio of line's words with the most information,
+// in their original order.
+func (cc *compressor) compressLine(line string) string {
+	words := strings.Fields(line)
+	keep := int(math.Ceil(float64(len(words)) * cc.ratio))
+	if keep >= len(words) {
+		return line
+	}
+
+	order := make([]int, len(words))
+	for i := range order {
+		order[i] = i
+	}
+	info := make([]float64, len(words))
+	for i, word := range words {
+		info[i] = cc.information(word)
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(info[b], info[a])
+	})
+	kept := order[:keep]
+	slices.Sort(kept)
+
+	out := make([]string, len(kept))
+	for i, idx := range kept {
+		out[i] = words[idx]
+	}
+	return strings.Join(out, " ")
+}
+
+// information estimates how much word tells the model, as its
+// self-information in the conversation. Stop words carry none.
+func (cc *compressor) information(word string) float64 {
+	w := normalizeWord(word)
+	if w == "" || stopWords[w] {
+		return 0
+	}
+	return -math.Log(float64(cc.freq[w]) / float64(cc.total))
+}
+
+// normalizeWord lower-cases word and strips surrounding punctuation, so
+// "The" and "the," count as one word.
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,;:!?\"'()[]{}"))
+}
+
+// stopWords are common English words that carry little meaning on their own.
+// Negations are left out, as dropping them inverts the meaning.
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`a an the and or but if then so of to in on at by for with from as
+		is are was were be been being am do does did has have had it its this that these those
+		i you he she we they me him her us them my your his our their there here very really
+		just also too can could would should will shall may might must yes than such
+		which who whom what when where why how all any both each some about into over again
+		further once only own same few more most other up down out off`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestCompressHistory(t *testing.T) {
+	t.Parallel()
+
+	toolCall := chat.Content{ToolCall: &chat.ToolCall{ID: "1", Name: "search", Arguments: []byte(`{"q":"the weather in the city"}`)}}
+	jsonResult := chat.Content{ToolResult: &chat.ToolResult{ToolCallID: "1", Name: "search", Content: `{"forecast": "it will be sunny in the city"}`}}
+	textResult := chat.Content{ToolResult: &chat.ToolResult{ToolCallID: "2", Name: "search", Content: "the forecast says that it will be sunny"}}
+	msgs := []chat.Message{
+		chat.UserMessage("Please tell me what the weather will be like in Paris tomorrow\nThis line repeats"),
+		{Role: chat.AssistantRole, Contents: []chat.Content{toolCall}},
+		{Role: chat.ToolRole, Contents: []chat.Content{jsonResult, textResult}},
+		chat.AssistantMessage("This line repeats\n```\nthe code is kept as it is\n```\nIt is not going to rain in Paris"),
+		chat.UserMessage("This line repeats"),
+		chat.UserMessage("the most recent message is left alone"),
+	}
+	original := cloneMessages(msgs)
+
+	compressed := compressHistory(msgs, 1, 0.5)
+	require.Len(t, compressed, len(msgs))
+	assert.Equal(t, original, msgs)
+
+	assert.Equal(t, "Please tell weather like Paris tomorrow\nline repeats", compressed[0].GetText())
+	assert.Equal(t, msgs[1], compressed[1])
+	assert.Equal(t, jsonResult, compressed[2].Contents[0])
+	assert.Equal(t, "the forecast says sunny", compressed[2].Contents[1].ToolResult.Content)
+	assert.Equal(t, "```\nthe code is kept as it is\n```\nnot going rain Paris", compressed[3].GetText())
+	// Text that is entirely repeated is kept, as empty messages are rejected
+	assert.Equal(t, "This line repeats", compressed[4].GetText())
+	assert.Equal(t, msgs[5], compressed[5])
+
+	assert.Equal(t, msgs, compressHistory(msgs, len(msgs), 0.5))
+}
+
+func cloneMessages(msgs []chat.Message) []chat.Message {
+	out := make([]chat.Message, len(msgs))
+	for i, msg := range msgs {
+		out[i] = chat.Message{Role: msg.Role, Contents: append([]chat.Content(nil), msg.Contents...)}
+		for j, c := range out[i].Contents {
+			if c.ToolResult != nil {
+				result := *c.ToolResult
+				out[i].Contents[j].ToolResult = &result
+			}
+		}
+	}
+	return out
+}
+
+func TestSessionPromptCompression(t *testing.T) {
+	t.Parallel()
+
+	history := []chat.Message{
+		chat.UserMessage("Could you please explain how the garbage collector works in Go"),
+		chat.AssistantMessage("Go uses a concurrent tri-color mark and sweep garbage collector"),
+	}
+
+	for _, tt := range []struct {
+		name      string
+		threshold float64
+		want      string
+	}{
+		{name: "Compressed", threshold: 0, want: "please explain garbage collector works Go"},
+		{name: "BelowThreshold", threshold: 0.9, want: history[0].GetText()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockClient{}
+			session, err := NewSession(client, "", WithInitialMessages(history...),
+				WithPromptCompression(PromptCompression{Threshold: tt.threshold, KeepLast: 1}))
+			require.NoError(t, err)
+
+			_, err = session.Message(context.Background(), chat.UserMessage("Thanks"))
+			require.NoError(t, err)
+
+			sent := client.chats[len(client.chats)-1].messages
+			assert.Equal(t, tt.want, sent[0].GetText())
+			assert.Equal(t, history[1], sent[1])
+
+			// Only the prompt is compressed, not the records
+			records := session.LiveRecords()
+			require.Len(t, records, 4)
+			assert.Equal(t, history[0].GetText(), records[0].GetText())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Compactor decides how a session's context window is shrunk when it is
+// compacted, either automatically at the compaction threshold or by
+// CompactNow. The default compactor summarizes older records with the
+// session's Summarizer; SlidingWindow drops them instead.
+type Compactor interface {
+	// Compact returns how to shrink the context window holding records,
+	// the session's live records in order.
+	Compact(ctx context.Context, records []persistence.Record) (Compaction, error)
+}
+
+// Compaction is the result of a Compactor.
+type Compaction struct {
+	// Drop holds the IDs of the records to mark dead.
+	Drop []int64
+	// Summary, if set, is added to the context window after the remaining
+	// records, standing in for the dropped ones.
+	Summary string
+}
+
+// WithContextPolicy sets how the session's context window is compacted,
+// such as with a SlidingWindow in place of LLM summarization.
+func WithContextPolicy(c Compactor) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.contextPolicy = c
+	}
+}
+
+// summaryCompactor is the default Compactor: it summarizes all but the last
+// 2 records, never touching system records.
+type summaryCompactor struct {
+	summarizer Summarizer
+}
+
+// Compact implements Compactor.
+func (c summaryCompactor) Compact(ctx context.Context, records []persistence.Record) (Compaction, error) {
+	if len(records) < 3 { // Need at least a few messages to summarize
+		return Compaction{}, nil
+	}
+
+	// Keep last 2 messages, summarize the rest (but never touch system
+	// prompts, they must always stay live)
+	var toSummarize []persistence.Record
+	var drop []int64
+	for _, r := range records[:len(records)-2] {
+		if r.Role != "system" {
+			toSummarize = append(toSummarize, r)
+			drop = append(drop, r.ID)
+		}
+	}
+	if len(toSummarize) == 0 {
+		return Compaction{}, nil
+	}
+
+	summary, err := c.summarizer.Summarize(ctx, toSummarize)
+	if err != nil {
+		return Compaction{}, fmt.Errorf("summarization failed: %w", err)
+	}
+	return Compaction{Drop: drop, Summary: summary}, nil
+}
+
+// SlidingWindow is a Compactor that deterministically keeps only the most
+// recent turns, dropping older records without summarizing them. A turn
+// starts with a user message and includes the responses and tool calls
+// that follow it.
+type SlidingWindow struct {
+	// KeepSystem keeps system records regardless of their age.
+	KeepSystem bool
+	// KeepLastNTurns is the number of most recent turns to keep. Values
+	// below 1 keep only the last turn.
+	KeepLastNTurns int
+	// AlwaysKeepPinned keeps turns holding a pinned record regardless of
+	// their age (see Session.PinRecord).
+	AlwaysKeepPinned bool
+}
+
+// Compact implements Compactor.
+func (w SlidingWindow) Compact(ctx context.Context, records []persistence.Record) (Compaction, error) {
+	keepTurns := max(w.KeepLastNTurns, 1)
+	turn, turns := recordTurns(records)
+
+	pinned := make(map[int]bool)
+	if w.AlwaysKeepPinned {
+		for i, r := range records {
+			if r.Pinned {
+				pinned[turn[i]] = true
+			}
+		}
+	}
+
+	var c Compaction
+	for i, r := range records {
+		switch {
+		case turn[i] >= turns-keepTurns:
+		case w.KeepSystem && r.Role == "system":
+		case pinned[turn[i]]:
+		default:
+			c.Drop = append(c.Drop, r.ID)
+		}
+	}
+	return c, nil
+}
+
+// recordTurns returns the index of the turn holding each of records, and
+// the number of turns. A turn starts with a user message and includes the
+// responses and tool calls that follow it; records before the first user
+// message are in the first turn along with it.
+func recordTurns(records []persistence.Record) (turn []int, turns int) {
+	turn = make([]int, len(records))
+	current, started := 0, false
+	for i, r := range records {
+		// Tool results are sent with the user role by some providers, but
+		// they continue the turn rather than start one
+		if r.Role == chat.UserRole && !r.HasToolResults() {
+			if started {
+				current++
+			}
+			started = true
+		}
+		turn[i] = current
+	}
+	return turn, current + 1
+}
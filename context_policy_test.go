@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	t.Parallel()
+
+	toolResult := []chat.Content{{ToolResult: &chat.ToolResult{ToolCallID: "1", Name: "search", Content: "result"}}}
+	records := []persistence.Record{
+		{ID: 1, Role: "system"},
+		{ID: 2, Role: chat.UserRole},
+		{ID: 3, Role: chat.AssistantRole, Pinned: true},
+		{ID: 4, Role: chat.UserRole},
+		{ID: 5, Role: chat.AssistantRole},
+		{ID: 6, Role: chat.UserRole, Contents: toolResult},
+		{ID: 7, Role: chat.AssistantRole},
+		{ID: 8, Role: chat.UserRole},
+		{ID: 9, Role: chat.AssistantRole},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		window SlidingWindow
+		want   []int64
+	}{
+		{name: "LastTurn", window: SlidingWindow{}, want: []int64{1, 2, 3, 4, 5, 6, 7}},
+		// Tool results continue the turn they were called in
+		{name: "LastTwoTurns", window: SlidingWindow{KeepLastNTurns: 2}, want: []int64{1, 2, 3}},
+		{name: "KeepSystem", window: SlidingWindow{KeepSystem: true}, want: []int64{2, 3, 4, 5, 6, 7}},
+		{name: "KeepPinned", window: SlidingWindow{KeepSystem: true, AlwaysKeepPinned: true}, want: []int64{4, 5, 6, 7}},
+		{name: "AllTurns", window: SlidingWindow{KeepLastNTurns: 10}, want: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			compaction, err := tt.window.Compact(context.Background(), records)
+			require.NoError(t, err)
+			assert.Equal(t, Compaction{Drop: tt.want}, compaction)
+		})
+	}
+}
+
+func TestSessionContextPolicy(t *testing.T) {
+	t.Parallel()
+
+	client := &mockClient{}
+	session, err := NewSession(client, "System",
+		WithContextPolicy(SlidingWindow{KeepSystem: true, KeepLastNTurns: 1, AlwaysKeepPinned: true}))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := range 3 {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+	}
+
+	records := session.LiveRecords()
+	require.Len(t, records, 7)
+	require.NoError(t, session.PinRecord(records[1].ID, true))
+
+	require.NoError(t, session.CompactNow())
+	assert.Equal(t, 1, session.Metrics().CompactionCount)
+
+	// The system prompt, the pinned first turn and the last turn are kept,
+	// without a summary
+	live := session.LiveRecords()
+	require.Len(t, live, 5)
+	assert.Equal(t, []int64{records[0].ID, records[1].ID, records[2].ID, records[5].ID, records[6].ID},
+		[]int64{live[0].ID, live[1].ID, live[2].ID, live[3].ID, live[4].ID})
+	assert.True(t, live[1].Pinned)
+
+	// Compacting again has nothing left to drop
+	require.NoError(t, session.CompactNow())
+	assert.Equal(t, 1, session.Metrics().CompactionCount)
+
+	assert.Error(t, session.PinRecord(records[3].ID, true))
+}
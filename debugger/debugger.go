@@ -0,0 +1,287 @@
+// Package debugger steps through a stored session one record at a time,
+// reconstructing the provider request behind each step, for inspecting how
+// a conversation's requests were built and attaching them to bug reports:
+//
+//	d, err := debugger.Load(store, sessionID, debugger.Options{Client: client})
+//	if err != nil {
+//		return err
+//	}
+//	for ok := true; ok; ok = d.StepForward() {
+//		req, err := d.Request(ctx)
+//		if errors.Is(err, debugger.ErrNoRequest) {
+//			continue
+//		}
+//		...
+//	}
+//
+// Requests are rebuilt with the chat.WithDryRun option, so nothing is sent
+// to the provider.
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// summaryPrefix starts the assistant record that replaces compacted turns
+// in an agent.Session.
+const summaryPrefix = "[Previous conversation summary]"
+
+// ErrNoRequest is returned by Request for steps no request was sent for:
+// the system prompt and compaction summaries.
+var ErrNoRequest = errors.New("no request at this step")
+
+// Options configures a Debugger.
+type Options struct {
+	// Client builds the requests. It should be for the provider and model
+	// the session used; the model each turn was sent to is in its
+	// Step.Options.
+	Client chat.Client
+	// Tools are registered on the chats requests are built with, as
+	// sessions don't store their tools.
+	Tools []chat.Tool
+}
+
+// Step is the state of the conversation at a record.
+type Step struct {
+	// Index is the record's position in the session, from 0.
+	Index  int
+	Record persistence.Record
+	// SystemPrompt and History are the conversation before the step's
+	// request, as the session would have sent it.
+	SystemPrompt string
+	History      []chat.Message
+	// Message is the message the step's request sent: the record itself
+	// for user and tool records, and the one before it for assistant
+	// records, which are the request's response. It's empty for steps
+	// without a request.
+	Message chat.Message
+	// Options are the options of the turn the request was made in, if
+	// they were stored.
+	Options *persistence.RequestOptions
+}
+
+// Debugger steps through a session's records. It isn't safe for
+// concurrent use.
+type Debugger struct {
+	records []persistence.Record
+	opts    Options
+	pos     int
+}
+
+// Load returns a Debugger at the first of the records of the session with
+// ID sessionID in store, live or not.
+func Load(store persistence.Store, sessionID string, opts Options) (*Debugger, error) {
+	records, err := store.GetAllRecords(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", sessionID, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("session %s has no records", sessionID)
+	}
+	return New(records, opts), nil
+}
+
+// New returns a Debugger at the first of records, which are in the order
+// they were written.
+func New(records []persistence.Record, opts Options) *Debugger {
+	return &Debugger{records: records, opts: opts}
+}
+
+// Len returns the number of steps, one per record.
+func (d *Debugger) Len() int {
+	return len(d.records)
+}
+
+// Position returns the index of the current step.
+func (d *Debugger) Position() int {
+	return d.pos
+}
+
+// StepForward moves to the next record, reporting whether there was one.
+func (d *Debugger) StepForward() bool {
+	if d.pos+1 >= len(d.records) {
+		return false
+	}
+	d.pos++
+	return true
+}
+
+// StepBack moves to the previous record, reporting whether there was one.
+func (d *Debugger) StepBack() bool {
+	if d.pos == 0 {
+		return false
+	}
+	d.pos--
+	return true
+}
+
+// Seek moves to the step at index i.
+func (d *Debugger) Seek(i int) error {
+	if i < 0 || i >= len(d.records) {
+		return fmt.Errorf("step %d out of range [0, %d)", i, len(d.records))
+	}
+	d.pos = i
+	return nil
+}
+
+// Step returns the current step.
+//
+// The records compaction drops are marked dead without saying when, so
+// they're left out of the history of steps after the next compaction
+// summary. Records dropped without a summary, as by agent.SlidingWindow,
+// are kept in the history of every later step, so the requests of steps
+// after such a compaction hold more than was sent.
+func (d *Debugger) Step() Step {
+	step := Step{Index: d.pos, Record: d.records[d.pos]}
+	msgIdx := d.messageIndex()
+	if msgIdx < 0 {
+		step.SystemPrompt, step.History = d.history(d.pos)
+		return step
+	}
+
+	step.SystemPrompt, step.History = d.history(msgIdx)
+	// The message itself was sent with its system reminders, which aren't
+	// replayed in later requests
+	r := d.records[msgIdx]
+	step.Message = chat.Message{Role: r.Role, Contents: r.Contents}
+	for i := msgIdx; i >= 0; i-- {
+		if d.records[i].Role == chat.UserRole {
+			step.Options = d.records[i].Options
+			break
+		}
+	}
+	return step
+}
+
+// Request returns the provider request of the current step, built by
+// Options.Client, or ErrNoRequest if the step doesn't have one.
+func (d *Debugger) Request(ctx context.Context) (*chat.DryRunRequest, error) {
+	step := d.Step()
+	if len(step.Message.Contents) == 0 {
+		return nil, ErrNoRequest
+	}
+	if d.opts.Client == nil {
+		return nil, errors.New("no client to build requests with")
+	}
+
+	c := d.opts.Client.NewChat(step.SystemPrompt, step.History...)
+	for _, tool := range d.opts.Tools {
+		if err := c.RegisterTool(tool); err != nil {
+			return nil, fmt.Errorf("failed to register tool %s: %w", tool.Name(), err)
+		}
+	}
+	opts := requestOptions(step.Options)
+	if user := d.records[d.messageIndex()].User; user != "" {
+		opts = append(opts, chat.WithUser(user))
+	}
+	req, err := chat.DryRun(ctx, c, step.Message, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for record %d: %w", step.Record.ID, err)
+	}
+	return req, nil
+}
+
+// messageIndex returns the index of the record the current step's request
+// sent, or -1 if it doesn't have one.
+func (d *Debugger) messageIndex() int {
+	r := d.records[d.pos]
+	switch {
+	case r.Role == chat.UserRole || r.Role == chat.ToolRole:
+		return d.pos
+	case r.Role == chat.AssistantRole && !isSummary(r) && d.pos > 0:
+		prev := d.records[d.pos-1].Role
+		if prev == chat.UserRole || prev == chat.ToolRole {
+			return d.pos - 1
+		}
+	}
+	return -1
+}
+
+// history returns the system prompt and messages the session would have
+// sent ahead of the record at index end.
+func (d *Debugger) history(end int) (string, []chat.Message) {
+	var systemPrompt string
+	var msgs []chat.Message
+	for i, r := range d.records[:end] {
+		if r.Status != persistence.RecordStatusSuccess || (!r.Live && d.summarizedBefore(i, end)) {
+			continue
+		}
+		if r.Role == "system" {
+			if systemPrompt != "" {
+				systemPrompt += "\n\n"
+			}
+			systemPrompt += r.GetText()
+			continue
+		}
+
+		// System reminders are only sent with the message they're added to
+		var contents []chat.Content
+		for _, c := range r.Contents {
+			if c.SystemReminder == "" {
+				contents = append(contents, c)
+			}
+		}
+		if len(contents) == 0 {
+			continue
+		}
+		msgs = append(msgs, chat.Message{Role: r.Role, Contents: contents})
+	}
+	return systemPrompt, msgs
+}
+
+// summarizedBefore reports whether a compaction summary was written after
+// the record at index i and before the one at index end.
+func (d *Debugger) summarizedBefore(i, end int) bool {
+	for _, r := range d.records[i+1 : end] {
+		if isSummary(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSummary(r persistence.Record) bool {
+	return r.Role == chat.AssistantRole && strings.HasPrefix(r.GetText(), summaryPrefix)
+}
+
+// requestOptions returns the chat options ro was saved from.
+func requestOptions(ro *persistence.RequestOptions) []chat.Option {
+	if ro == nil {
+		return nil
+	}
+	var opts []chat.Option
+	if ro.Temperature != nil {
+		opts = append(opts, chat.WithTemperature(*ro.Temperature))
+	}
+	if ro.MaxTokens > 0 {
+		opts = append(opts, chat.WithMaxTokens(ro.MaxTokens))
+	}
+	if ro.ReasoningEffort != "" {
+		opts = append(opts, chat.WithReasoningEffort(ro.ReasoningEffort))
+	}
+	if ro.ResponseFormat != nil {
+		opts = append(opts, chat.WithResponseFormat(ro.ResponseFormat.Name, ro.ResponseFormat.Strict, ro.ResponseFormat.Schema))
+	}
+	if ro.JSONMode {
+		opts = append(opts, chat.WithJSONMode())
+	}
+	if ro.AssistantPrefix != "" {
+		opts = append(opts, chat.WithAssistantPrefix(ro.AssistantPrefix))
+	}
+	if ro.Candidates > 1 {
+		opts = append(opts, chat.WithCandidates(ro.Candidates))
+	}
+	if ro.Prediction != "" {
+		opts = append(opts, chat.WithPrediction(ro.Prediction))
+	}
+	if ro.SystemPromptOverride != "" {
+		opts = append(opts, chat.WithSystemPromptOverride(ro.SystemPromptOverride))
+	}
+	return opts
+}
@@ -0,0 +1,177 @@
+package debugger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/claude"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+type readFileTool struct{}
+
+func (readFileTool) Name() string        { return "read_file" }
+func (readFileTool) Description() string { return "Reads a file" }
+func (readFileTool) MCPJsonSchema() string {
+	return `{"name": "read_file", "description": "Reads a file", "inputSchema": {"type": "object", "properties": {"path": {"type": "string"}}}}`
+}
+func (readFileTool) Call(ctx context.Context, input string) string { return `{"content": "hi"}` }
+
+// compactedSession stores a session with a tool call, a failed turn, and a
+// compaction that dropped the first turn, returning its ID.
+func compactedSession(t *testing.T, store persistence.Store) string {
+	t.Helper()
+
+	ok := persistence.RecordStatusSuccess
+	temperature := 0.5
+	call := chat.Message{Role: chat.AssistantRole}
+	call.AddToolCall(chat.ToolCall{ID: "call_1", Name: "read_file", Arguments: json.RawMessage(`{"path": "a.txt"}`)})
+	result := chat.Message{Role: chat.ToolRole}
+	result.AddToolResult(chat.ToolResult{ToolCallID: "call_1", Name: "read_file", Content: `{"content": "hi"}`})
+	for _, r := range []persistence.Record{
+		{Role: "system", Contents: []chat.Content{{Text: "You are helpful."}}, Live: true, Status: ok},
+		{Role: chat.UserRole, Contents: []chat.Content{{Text: "What's in a.txt?"}, {SystemReminder: "The time is noon."}}, Status: ok, Options: &persistence.RequestOptions{Model: "claude-3-haiku", MaxTokens: 100}},
+		{Role: chat.AssistantRole, Contents: call.Contents, Status: ok},
+		{Role: chat.ToolRole, Contents: result.Contents, Status: ok},
+		{Role: chat.AssistantRole, Contents: []chat.Content{{Text: "It says hi."}}, Status: ok},
+		{Role: chat.UserRole, Contents: []chat.Content{{Text: "Bye"}}, Status: persistence.RecordStatusFailed},
+		{Role: chat.AssistantRole, Contents: []chat.Content{{Text: summaryPrefix + "\nThe user read a.txt."}}, Live: true, Status: ok},
+		{Role: chat.UserRole, Contents: []chat.Content{{Text: "Thanks"}}, Live: true, Status: ok, Options: &persistence.RequestOptions{Temperature: &temperature}},
+		{Role: chat.AssistantRole, Contents: []chat.Content{{Text: "You're welcome."}}, Live: true, Status: ok},
+	} {
+		_, err := store.AddRecord("s1", r)
+		require.NoError(t, err)
+	}
+	return "s1"
+}
+
+// claudeRequest is the part of a Claude Messages API request the tests
+// check.
+type claudeRequest struct {
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature *float64 `json:"temperature"`
+	Messages    []struct {
+		Role string `json:"role"`
+	} `json:"messages"`
+	Tools []struct {
+		Name string `json:"name"`
+	} `json:"tools"`
+}
+
+func TestDebugger(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client, err := claude.NewClient(server.URL, "test-key", claude.WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	store := persistence.NewMemoryStore()
+	d, err := Load(store, compactedSession(t, store), Options{Client: client, Tools: []chat.Tool{readFileTool{}}})
+	require.NoError(t, err)
+	require.Equal(t, 9, d.Len())
+
+	ctx := context.Background()
+	request := func() (claudeRequest, string) {
+		t.Helper()
+		req, err := d.Request(ctx)
+		require.NoError(t, err)
+		var body claudeRequest
+		require.NoError(t, json.Unmarshal(req.Body, &body))
+		return body, string(req.Body)
+	}
+	roles := func(body claudeRequest) []string {
+		var roles []string
+		for _, m := range body.Messages {
+			roles = append(roles, m.Role)
+		}
+		return roles
+	}
+
+	// The system prompt has no request
+	assert.False(t, d.StepBack())
+	_, err = d.Request(ctx)
+	assert.ErrorIs(t, err, ErrNoRequest)
+
+	// The first message is sent with its reminder and the turn's options
+	require.True(t, d.StepForward())
+	step := d.Step()
+	assert.Equal(t, "You are helpful.", step.SystemPrompt)
+	assert.Empty(t, step.History)
+	assert.Equal(t, 100, step.Options.MaxTokens)
+	body, raw := request()
+	assert.Equal(t, []string{"user"}, roles(body))
+	assert.Equal(t, 100, body.MaxTokens)
+	assert.Contains(t, raw, "The time is noon.")
+	require.Len(t, body.Tools, 1)
+	assert.Equal(t, "read_file", body.Tools[0].Name)
+
+	// An assistant record is the response to the request before it
+	require.True(t, d.StepForward())
+	assert.Equal(t, step.Message, d.Step().Message)
+	body, _ = request()
+	assert.Equal(t, []string{"user"}, roles(body))
+
+	// Tool results are sent after the call, without the earlier reminder
+	require.True(t, d.StepForward())
+	step = d.Step()
+	require.Len(t, step.History, 2)
+	assert.Equal(t, chat.ToolRole, step.Message.Role)
+	body, raw = request()
+	assert.Equal(t, []string{"user", "assistant", "user"}, roles(body))
+	assert.NotContains(t, raw, "The time is noon.")
+	assert.Contains(t, raw, "call_1")
+
+	// The failed turn's request was sent, but it's left out of later ones
+	require.NoError(t, d.Seek(5))
+	body, raw = request()
+	assert.Equal(t, []string{"user", "assistant", "user", "assistant", "user"}, roles(body))
+	assert.Contains(t, raw, "Bye")
+
+	require.True(t, d.StepForward())
+	_, err = d.Request(ctx)
+	assert.ErrorIs(t, err, ErrNoRequest, "compaction summaries have no request")
+
+	// After the compaction, only its summary is sent
+	require.True(t, d.StepForward())
+	step = d.Step()
+	require.Len(t, step.History, 1)
+	assert.Contains(t, step.History[0].GetText(), "The user read a.txt.")
+	body, raw = request()
+	require.NotNil(t, body.Temperature)
+	assert.Equal(t, 0.5, *body.Temperature)
+	assert.NotContains(t, raw, "What's in a.txt?")
+	assert.NotContains(t, raw, "Bye")
+	assert.Contains(t, raw, "Thanks")
+
+	require.True(t, d.StepForward())
+	assert.False(t, d.StepForward())
+	assert.Equal(t, 8, d.Position())
+	require.True(t, d.StepBack())
+	assert.Equal(t, 7, d.Position())
+	assert.Error(t, d.Seek(9))
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Load(persistence.NewMemoryStore(), "missing", Options{})
+	assert.EqualError(t, err, "session missing has no records")
+
+	store := persistence.NewMemoryStore()
+	d, err := Load(store, compactedSession(t, store), Options{})
+	require.NoError(t, err)
+	require.True(t, d.StepForward())
+	_, err = d.Request(context.Background())
+	assert.EqualError(t, err, "no client to build requests with")
+}
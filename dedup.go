@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/vecmath"
+)
+
+const (
+	defaultDedupSimilarity = 0.95
+	defaultDedupMinLength  = 500
+)
+
+// HistoryDedup configures replacing older copies of near-duplicate content
+// in prompts, such as a file read twice in a long coding session, with a
+// note that a later copy follows. Content is compared by the cosine
+// similarity of its embeddings, so copies with small differences, like a
+// file before and after a small edit, count as duplicates too, and only
+// the latest is sent.
+//
+// Only the text of user messages and the content of tool results are
+// compared. Like PromptCompression, only what is sent changes; the
+// session's records keep the original content.
+type HistoryDedup struct {
+	// Embedder embeds the content to compare, such as one returned by
+	// openai.NewEmbedder.
+	Embedder chat.Embedder
+	// Similarity is the cosine similarity, from 0 to 1, at or above which
+	// two pieces of content are duplicates. Defaults to 0.95.
+	Similarity float64
+	// MinLength is the length in bytes of the shortest content that is
+	// replaced, as replacing short content saves little. Defaults to 500.
+	MinLength int
+}
+
+// WithHistoryDedup replaces older copies of near-duplicate content in
+// prompts to the LLM with a note pointing to the latest copy, using
+// d.Embedder to find them. Embeddings are kept for the life of the
+// session, so each piece of content is embedded once. If the embedder
+// fails, a warning is logged and the prompt is sent without deduplication.
+func WithHistoryDedup(d HistoryDedup) SessionOption {
+	return func(opts *sessionOptions) {
+		if d.Similarity <= 0 || d.Similarity > 1 {
+			d.Similarity = defaultDedupSimilarity
+		}
+		if d.MinLength <= 0 {
+			d.MinLength = defaultDedupMinLength
+		}
+		opts.dedup = &d
+	}
+}
+
+// deduplicator replaces near-duplicate content in histories, remembering
+// the embeddings of content it has seen.
+type deduplicator struct {
+	HistoryDedup
+	embeddings map[string][]float64
+}
+
+func newDeduplicator(d HistoryDedup) *deduplicator {
+	return &deduplicator{HistoryDedup: d, embeddings: make(map[string][]float64)}
+}
+
+// dedupItem is a piece of content that may be replaced: the msg'th
+// message's content'th content, and its text.
+type dedupItem struct {
+	msg, content int
+	text         string
+}
+
+// dedup returns msgs with each piece of content that has a near-duplicate
+// later in msgs replaced by a note. msgs is not modified.
+func (d *deduplicator) dedup(ctx context.Context, msgs []chat.Message) ([]chat.Message, error) {
+	var items []dedupItem
+	for i, msg := range msgs {
+		for j, c := range msg.Contents {
+			text := dedupText(msg.Role, c)
+			if len(text) >= d.MinLength {
+				items = append(items, dedupItem{msg: i, content: j, text: text})
+			}
+		}
+	}
+	if len(items) < 2 {
+		return msgs, nil
+	}
+
+	if err := d.embed(ctx, items); err != nil {
+		return nil, err
+	}
+
+	deduped := slices.Clone(msgs)
+	for i, item := range items {
+		for _, later := range items[i+1:] {
+			if vecmath.CosineSimilarity(d.embeddings[item.text], d.embeddings[later.text]) < d.Similarity {
+				continue
+			}
+			contents := slices.Clone(deduped[item.msg].Contents)
+			contents[item.content] = dedupNote(contents[item.content], msgs[later.msg].Contents[later.content])
+			deduped[item.msg].Contents = contents
+			break
+		}
+	}
+	return deduped, nil
+}
+
+// embed embeds the items' texts that haven't been seen, and forgets the
+// embeddings of texts no longer in the history.
+func (d *deduplicator) embed(ctx context.Context, items []dedupItem) error {
+	current := make(map[string]bool, len(items))
+	var texts []string
+	for _, item := range items {
+		if _, ok := d.embeddings[item.text]; !ok && !current[item.text] {
+			texts = append(texts, item.text)
+		}
+		current[item.text] = true
+	}
+	for text := range d.embeddings {
+		if !current[text] {
+			delete(d.embeddings, text)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	embeddings, err := d.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("embedding history: %w", err)
+	}
+	if len(embeddings) != len(texts) {
+		return fmt.Errorf("embedder returned %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+	for i, text := range texts {
+		d.embeddings[text] = embeddings[i]
+	}
+	return nil
+}
+
+// dedupText returns the text of c that may be deduplicated: the text of a
+// user message, or the content of a tool result.
+func dedupText(role chat.Role, c chat.Content) string {
+	if c.ToolResult != nil {
+		return c.ToolResult.Content
+	}
+	if role == chat.UserRole {
+		return c.Text
+	}
+	return ""
+}
+
+// dedupNote returns c with its text replaced by a note pointing to later,
+// its near-duplicate.
+func dedupNote(c, later chat.Content) chat.Content {
+	note := "[Omitted: a near-duplicate of this message appears later in the conversation.]"
+	if later.ToolResult != nil {
+		note = fmt.Sprintf("[Omitted: a near-duplicate of this content appears later in the conversation, in the result of %s tool call %s.]", later.ToolResult.Name, later.ToolResult.ToolCallID)
+	}
+	if c.ToolResult != nil {
+		result := *c.ToolResult
+		result.Content = note
+		c.ToolResult = &result
+	} else {
+		c.Text = note
+	}
+	return c
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// topicEmbedder embeds each text as the direction of its first word, so
+// texts are near-duplicates when they start with the same word. It records
+// the texts it embeds.
+type topicEmbedder struct {
+	embedded []string
+}
+
+func (e *topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	e.embedded = append(e.embedded, texts...)
+	topics := map[string][]float64{"main.go": {1, 0, 0}, "README": {0, 1, 0}}
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		word, _, _ := strings.Cut(text, " ")
+		embeddings[i] = topics[word]
+		if embeddings[i] == nil {
+			embeddings[i] = []float64{0, 0, 1}
+		}
+	}
+	return embeddings, nil
+}
+
+func readResult(id, content string) chat.Message {
+	return chat.Message{Role: chat.ToolRole, Contents: []chat.Content{
+		{ToolResult: &chat.ToolResult{ToolCallID: id, Name: "read_file", Content: content}},
+	}}
+}
+
+func TestDeduplicator(t *testing.T) {
+	t.Parallel()
+
+	mainV1 := "main.go package main, version one"
+	mainV2 := "main.go package main, version two"
+	readme := "README describes the project"
+	msgs := []chat.Message{
+		chat.UserMessage(readme),
+		readResult("1", mainV1),
+		chat.AssistantMessage(mainV1),
+		readResult("2", readme),
+		chat.UserMessage("short"),
+		readResult("3", mainV2),
+	}
+	original := cloneMessages(msgs)
+
+	embedder := &topicEmbedder{}
+	d := newDeduplicator(HistoryDedup{Embedder: embedder, Similarity: 0.95, MinLength: 10})
+	deduped, err := d.dedup(context.Background(), msgs)
+	require.NoError(t, err)
+	assert.Equal(t, original, msgs)
+
+	require.Len(t, deduped, len(msgs))
+	assert.Equal(t, "[Omitted: a near-duplicate of this content appears later in the conversation, in the result of read_file tool call 2.]", deduped[0].GetText())
+	assert.Equal(t, "[Omitted: a near-duplicate of this content appears later in the conversation, in the result of read_file tool call 3.]", deduped[1].Contents[0].ToolResult.Content)
+	assert.Equal(t, "1", deduped[1].Contents[0].ToolResult.ToolCallID)
+	// Assistant text isn't deduplicated, and the latest copies are kept
+	assert.Equal(t, msgs[2:], deduped[2:])
+	assert.ElementsMatch(t, []string{readme, mainV1, mainV2}, embedder.embedded)
+
+	// Embeddings are reused, and only new content is embedded
+	embedder.embedded = nil
+	msgs = append(msgs, chat.UserMessage(mainV2))
+	deduped, err = d.dedup(context.Background(), msgs)
+	require.NoError(t, err)
+	assert.Empty(t, embedder.embedded)
+	assert.Equal(t, "[Omitted: a near-duplicate of this message appears later in the conversation.]", deduped[5].Contents[0].ToolResult.Content)
+}
+
+func TestSessionHistoryDedup(t *testing.T) {
+	t.Parallel()
+
+	history := []chat.Message{
+		readResult("1", "main.go package main, version one"),
+		chat.AssistantMessage("Read it"),
+		readResult("2", "main.go package main, version two"),
+		chat.AssistantMessage("Read it again"),
+	}
+
+	t.Run("Deduplicated", func(t *testing.T) {
+		t.Parallel()
+
+		client := &mockClient{}
+		session, err := NewSession(client, "", WithInitialMessages(history...),
+			WithHistoryDedup(HistoryDedup{Embedder: &topicEmbedder{}, MinLength: 10}))
+		require.NoError(t, err)
+
+		_, err = session.Message(context.Background(), chat.UserMessage("Thanks"))
+		require.NoError(t, err)
+
+		sent := client.chats[len(client.chats)-1].messages
+		assert.Contains(t, sent[0].Contents[0].ToolResult.Content, "[Omitted:")
+		assert.Equal(t, history[2], sent[2])
+
+		// Only the prompt is deduplicated, not the records
+		records := session.LiveRecords()
+		require.Len(t, records, 6)
+		assert.Equal(t, history[0].Contents, records[0].Contents)
+	})
+
+	t.Run("EmbedderFails", func(t *testing.T) {
+		t.Parallel()
+
+		client := &mockClient{}
+		failing := chat.EmbedderFunc(func(ctx context.Context, texts []string) ([][]float64, error) {
+			return nil, errors.New("embeddings unavailable")
+		})
+		session, err := NewSession(client, "", WithInitialMessages(history...),
+			WithHistoryDedup(HistoryDedup{Embedder: failing, MinLength: 10}))
+		require.NoError(t, err)
+
+		_, err = session.Message(context.Background(), chat.UserMessage("Thanks"))
+		require.NoError(t, err)
+		sent := client.chats[len(client.chats)-1].messages
+		assert.Equal(t, history[0], sent[0])
+	})
+}
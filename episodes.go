@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/vecmath"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+const (
+	defaultSegmentThreshold = 0.5
+	// maxSegmentTextLen is how much of a turn's text is embedded, keeping
+	// long turns within embedding models' input limits.
+	maxSegmentTextLen = 8000
+)
+
+// Segmenter groups a conversation's records into topical episodes, for
+// EpisodicCompaction.
+type Segmenter interface {
+	// Segment returns the index in records of the first record of each
+	// episode, in increasing order and starting with 0. Episodes should
+	// start at a user message, so tool calls stay with their results.
+	Segment(ctx context.Context, records []persistence.Record) ([]int, error)
+}
+
+// EmbeddingSegmenter is a Segmenter that embeds the text of each turn and
+// starts a new episode at each turn that is unlike the episode so far: its
+// cosine similarity to the average of the episode's turns is below
+// Threshold. A turn starts with a user message and includes the responses
+// and tool calls that follow it.
+type EmbeddingSegmenter struct {
+	// Embedder embeds the turns, such as one returned by
+	// openai.NewEmbedder.
+	Embedder chat.Embedder
+	// Threshold is the cosine similarity, from 0 to 1, below which a turn
+	// starts a new episode. Defaults to 0.5.
+	Threshold float64
+}
+
+// Segment implements Segmenter.
+func (s EmbeddingSegmenter) Segment(ctx context.Context, records []persistence.Record) ([]int, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	threshold := s.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultSegmentThreshold
+	}
+
+	// Turns without text, like ones only calling tools, can't be placed,
+	// so they stay in the current episode
+	turn, turns := recordTurns(records)
+	starts := make([]int, turns)
+	texts := make([]strings.Builder, turns)
+	for i := len(records) - 1; i >= 0; i-- {
+		starts[turn[i]] = i
+	}
+	for i, r := range records {
+		if text := r.GetText(); text != "" {
+			b := &texts[turn[i]]
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(text)
+		}
+	}
+	var embedTurns []int
+	var embedTexts []string
+	for t := range texts {
+		if text := texts[t].String(); text != "" {
+			embedTurns = append(embedTurns, t)
+			embedTexts = append(embedTexts, text[:min(len(text), maxSegmentTextLen)])
+		}
+	}
+	if len(embedTexts) == 0 {
+		return []int{0}, nil
+	}
+
+	embeddings, err := s.Embedder.Embed(ctx, embedTexts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding turns: %w", err)
+	}
+	if len(embeddings) != len(embedTexts) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d turns", len(embeddings), len(embedTexts))
+	}
+
+	episodes := []int{0}
+	var centroid []float64
+	for i, t := range embedTurns {
+		embedding := embeddings[i]
+		if centroid != nil && vecmath.CosineSimilarity(centroid, embedding) < threshold {
+			episodes = append(episodes, starts[t])
+			centroid = nil
+		}
+		centroid = addUnit(centroid, embedding)
+	}
+	return episodes, nil
+}
+
+// addUnit returns sum plus v scaled to unit length, so that the direction of
+// sum is the average direction of the vectors added to it.
+func addUnit(sum, v []float64) []float64 {
+	var squares float64
+	for _, x := range v {
+		squares += x * x
+	}
+	if squares == 0 {
+		return sum
+	}
+	if sum == nil {
+		sum = make([]float64, len(v))
+	} else if len(sum) != len(v) {
+		return sum
+	}
+	norm := math.Sqrt(squares)
+	for i := range v {
+		sum[i] += v[i] / norm
+	}
+	return sum
+}
+
+// EpisodicCompaction is a Compactor that groups records into topical
+// episodes with Segmenter and summarizes each older episode on its own with
+// Summarizer, so each summary covers one coherent topic rather than an
+// arbitrary run of the oldest records. The most recent KeepLastNEpisodes
+// episodes and system records are kept.
+type EpisodicCompaction struct {
+	Segmenter  Segmenter
+	Summarizer Summarizer
+	// KeepLastNEpisodes is the number of most recent episodes to keep.
+	// Values below 1 keep only the last episode.
+	KeepLastNEpisodes int
+}
+
+// Compact implements Compactor.
+func (c EpisodicCompaction) Compact(ctx context.Context, records []persistence.Record) (Compaction, error) {
+	var conversation []persistence.Record
+	for _, r := range records {
+		if r.Role != "system" {
+			conversation = append(conversation, r)
+		}
+	}
+	if len(conversation) == 0 {
+		return Compaction{}, nil
+	}
+
+	starts, err := c.Segmenter.Segment(ctx, conversation)
+	if err != nil {
+		return Compaction{}, fmt.Errorf("segmentation failed: %w", err)
+	}
+	for i, start := range starts {
+		if (i == 0 && start != 0) || (i > 0 && start <= starts[i-1]) || start >= len(conversation) {
+			return Compaction{}, fmt.Errorf("segmenter returned invalid episode starts %v for %d records", starts, len(conversation))
+		}
+	}
+	keep := max(c.KeepLastNEpisodes, 1)
+	if len(starts) <= keep {
+		return Compaction{}, nil
+	}
+
+	var compaction Compaction
+	var summaries []string
+	for i, start := range starts[:len(starts)-keep] {
+		episode := conversation[start:starts[i+1]]
+		summary, err := c.Summarizer.Summarize(ctx, episode)
+		if err != nil {
+			return Compaction{}, fmt.Errorf("summarization failed: %w", err)
+		}
+		summaries = append(summaries, fmt.Sprintf("Episode %d:\n%s", i+1, summary))
+		for _, r := range episode {
+			compaction.Drop = append(compaction.Drop, r.ID)
+		}
+	}
+	compaction.Summary = strings.Join(summaries, "\n\n")
+	return compaction, nil
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// recordIDSummarizer summarizes records as the range of their IDs.
+type recordIDSummarizer struct{}
+
+func (recordIDSummarizer) Summarize(ctx context.Context, records []persistence.Record) (string, error) {
+	return fmt.Sprintf("records %d-%d", records[0].ID, records[len(records)-1].ID), nil
+}
+
+func (recordIDSummarizer) SetPrompt(string) {}
+
+func textRecord(id int64, role chat.Role, text string) persistence.Record {
+	return persistence.Record{ID: id, Role: role, Contents: []chat.Content{{Text: text}}}
+}
+
+// episodeRecords has a system record and three episodes, according to
+// topicEmbedder: records 2-7 are about main.go, 8-9 the README, and
+// 10-11 something else. The second turn calls a tool, without text.
+var episodeRecords = []persistence.Record{
+	textRecord(1, "system", "You are a coding assistant"),
+	textRecord(2, chat.UserRole, "main.go has a bug"),
+	textRecord(3, chat.AssistantRole, "main.go is fixed"),
+	textRecord(4, chat.UserRole, "main.go tests pass?"),
+	{ID: 5, Role: chat.AssistantRole, Contents: []chat.Content{{ToolCall: &chat.ToolCall{ID: "1", Name: "run_tests"}}}},
+	{ID: 6, Role: chat.UserRole, Contents: []chat.Content{{ToolResult: &chat.ToolResult{ToolCallID: "1", Name: "run_tests", Content: "ok"}}}},
+	textRecord(7, chat.AssistantRole, "main.go tests pass"),
+	textRecord(8, chat.UserRole, "README needs updating"),
+	textRecord(9, chat.AssistantRole, "README is updated"),
+	textRecord(10, chat.UserRole, "What is the weather?"),
+	textRecord(11, chat.AssistantRole, "Sunny"),
+}
+
+func TestEmbeddingSegmenter(t *testing.T) {
+	t.Parallel()
+
+	embedder := &topicEmbedder{}
+	starts, err := EmbeddingSegmenter{Embedder: embedder}.Segment(context.Background(), episodeRecords[1:])
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 6, 8}, starts)
+	// Each turn with text is embedded once
+	assert.Len(t, embedder.embedded, 4)
+
+	starts, err = EmbeddingSegmenter{Embedder: embedder}.Segment(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, starts)
+}
+
+func TestEpisodicCompaction(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		keep int
+		want Compaction
+	}{
+		{
+			name: "LastEpisode",
+			want: Compaction{
+				Drop:    []int64{2, 3, 4, 5, 6, 7, 8, 9},
+				Summary: "Episode 1:\nrecords 2-7\n\nEpisode 2:\nrecords 8-9",
+			},
+		},
+		{
+			name: "LastTwoEpisodes",
+			keep: 2,
+			want: Compaction{Drop: []int64{2, 3, 4, 5, 6, 7}, Summary: "Episode 1:\nrecords 2-7"},
+		},
+		{name: "AllEpisodes", keep: 3, want: Compaction{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := EpisodicCompaction{
+				Segmenter:         EmbeddingSegmenter{Embedder: &topicEmbedder{}},
+				Summarizer:        recordIDSummarizer{},
+				KeepLastNEpisodes: tt.keep,
+			}
+			compaction, err := c.Compact(context.Background(), episodeRecords)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, compaction)
+		})
+	}
+}
+
+// segmenterFunc adapts a function to a Segmenter.
+type segmenterFunc func(ctx context.Context, records []persistence.Record) ([]int, error)
+
+func (f segmenterFunc) Segment(ctx context.Context, records []persistence.Record) ([]int, error) {
+	return f(ctx, records)
+}
+
+func TestEpisodicCompaction_InvalidStarts(t *testing.T) {
+	t.Parallel()
+
+	for _, starts := range [][]int{{1, 4}, {0, 4, 4}, {0, 20}} {
+		c := EpisodicCompaction{
+			Segmenter: segmenterFunc(func(context.Context, []persistence.Record) ([]int, error) {
+				return starts, nil
+			}),
+			Summarizer: recordIDSummarizer{},
+		}
+		_, err := c.Compact(context.Background(), episodeRecords)
+		assert.Error(t, err, "starts %v", starts)
+	}
+}
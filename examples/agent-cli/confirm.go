@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/examples/fstools"
+)
+
+// confirmWrites returns a tool approver that shows the user the diff of
+// each WriteFile call and lets them approve or decline it. Previews and
+// calls WriteFile will reject anyway pass through.
+func confirmWrites(reader *bufio.Reader, output io.Writer) agent.PolicyEvaluator {
+	var mu sync.Mutex
+	return agent.PolicyEvaluatorFunc(func(ctx context.Context, call chat.ToolCall) (agent.PolicyDecision, error) {
+		if call.Name != fstools.WriteFileTool.Name() {
+			return agent.PolicyDecision{}, nil
+		}
+		var req fstools.WriteFileRequest
+		if err := json.Unmarshal(call.Arguments, &req); err != nil || req.Preview {
+			return agent.PolicyDecision{}, nil
+		}
+		diff, err := fstools.DiffWrite(ctx, req)
+		if err != nil || diff == "" {
+			return agent.PolicyDecision{}, nil
+		}
+
+		// Tool calls may run concurrently, but prompts can't
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintf(output, "\n%s\nApply this change to %s? [y/N] ", diff, req.FileName)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return agent.PolicyDecision{}, fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return agent.PolicyDecision{}, nil
+		}
+		return agent.PolicyDecision{Action: agent.ToolDecisionDeny, Reason: "the user declined the change"}, nil
+	})
+}
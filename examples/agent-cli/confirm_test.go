@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/examples/fstools"
+)
+
+func TestConfirmWrites(t *testing.T) {
+	testFS := memfs.New()
+	require.NoError(t, testFS.WriteFile("a.txt", []byte("old\n"), 0o644))
+	ctx := fstools.WithFS(context.Background(), testFS)
+
+	var output strings.Builder
+	evaluator := confirmWrites(bufio.NewReader(strings.NewReader("y\nno\n")), &output)
+	evaluate := func(tool, args string) agent.ToolDecision {
+		t.Helper()
+		d, err := evaluator.EvaluateToolCall(ctx, chat.ToolCall{Name: tool, Arguments: []byte(args)})
+		require.NoError(t, err)
+		return d.Action
+	}
+
+	// Neither other tools nor previews ask
+	assert.Empty(t, evaluate("ReadFile", `{"fileName": "a.txt"}`))
+	assert.Empty(t, evaluate("WriteFile", `{"fileName": "a.txt", "content": "new\n", "preview": true}`))
+	assert.Empty(t, output.String())
+
+	assert.Empty(t, evaluate("WriteFile", `{"fileName": "a.txt", "content": "new\n"}`))
+	assert.Contains(t, output.String(), "-old\n+new\n")
+	assert.Contains(t, output.String(), "Apply this change to a.txt? [y/N]")
+
+	assert.Equal(t, agent.ToolDecisionDeny, evaluate("WriteFile", `{"fileName": "b.txt", "content": "new\n"}`))
+	assert.Contains(t, output.String(), "--- /dev/null\n+++ b/b.txt\n")
+
+	// Without an answer to read, the evaluator fails, which denies the call
+	_, err := evaluator.EvaluateToolCall(ctx, chat.ToolCall{Name: "WriteFile", Arguments: []byte(`{"fileName": "c.txt", "content": "x"}`)})
+	assert.Error(t, err)
+}
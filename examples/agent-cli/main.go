@@ -11,12 +11,14 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	agent "github.com/bpowers/go-agent"
 	"github.com/bpowers/go-agent/chat"
 	"github.com/bpowers/go-agent/examples/fstools"
 	"github.com/bpowers/go-agent/llm"
 	"github.com/bpowers/go-agent/persistence/sqlitestore"
+	"github.com/bpowers/go-agent/workspace"
 )
 
 const defaultModel = "claude-opus-4-1"
@@ -39,6 +41,20 @@ type Config struct {
 	PersistenceFile  string
 	CompactThreshold float64
 	SystemReminder   bool
+	Render           string
+	Prompt           string
+	OutputFormat     string
+	Continue         bool
+	Resume           string
+	ConfigFile       string
+	ReasoningEffort  string
+	ConfirmWrites    bool
+
+	// file is the loaded -config file, if any
+	file *llm.FileConfig
+	// setFlags records the flags given on the command line, which take
+	// precedence over the -config file
+	setFlags map[string]bool
 }
 
 // toolWrapper wraps a chat.Tool and calls a hook function before delegating to the wrapped tool
@@ -57,6 +73,77 @@ func (w *toolWrapper) Call(ctx context.Context, input string) string {
 	return w.tool.Call(ctx, input)
 }
 
+func (w *toolWrapper) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	it, ok := w.tool.(chat.ImageTool)
+	if !ok {
+		return w.Call(ctx, input), nil
+	}
+	if w.onCall != nil {
+		w.onCall()
+	}
+	return it.CallWithImages(ctx, input)
+}
+
+// messageOptions returns the default message options set by config.
+func messageOptions(config *Config) []chat.Option {
+	var opts []chat.Option
+	if config.Temperature >= 0 {
+		opts = append(opts, chat.WithTemperature(config.Temperature))
+	}
+	if config.MaxTokens > 0 {
+		opts = append(opts, chat.WithMaxTokens(config.MaxTokens))
+	}
+	if config.ReasoningEffort != "" {
+		opts = append(opts, chat.WithReasoningEffort(config.ReasoningEffort))
+	}
+	return opts
+}
+
+// printSessionStats writes a summary of session metrics to w.
+func printSessionStats(w io.Writer, metrics agent.SessionMetrics) {
+	_, _ = fmt.Fprintf(w, "\nSession Stats:\n")
+	_, _ = fmt.Fprintf(w, "  Total tokens used: %d\n", metrics.CumulativeTokens)
+	_, _ = fmt.Fprintf(w, "  Live context: %d/%d tokens (%.1f%% full)\n",
+		metrics.LiveTokens, metrics.MaxTokens, metrics.PercentFull*100)
+	_, _ = fmt.Fprintf(w, "  Records: %d live, %d total\n", metrics.RecordsLive, metrics.RecordsTotal)
+	if metrics.CompactionCount > 0 {
+		_, _ = fmt.Fprintf(w, "  Compactions: %d (last: %s)\n",
+			metrics.CompactionCount, metrics.LastCompaction.Format("15:04:05"))
+	}
+}
+
+// newStreamCallback returns a callback that renders a response as it streams,
+// according to config.Render.
+func newStreamCallback(config *Config, output io.Writer) chat.StreamCallback {
+	opts := chat.StreamWriterOptions{Verbose: config.Debug}
+	if config.Render != "markdown" {
+		if config.Render == "ansi" {
+			opts.Format = chat.StreamFormatANSI
+		}
+		return chat.StreamToWriter(output, opts)
+	}
+
+	// Render the response itself as markdown, and everything else (thinking,
+	// tool calls) with StreamToWriter's ANSI formatting
+	opts.Format = chat.StreamFormatANSI
+	render := chat.StreamToWriter(output, opts)
+	md := newMarkdownWriter(output)
+	return func(event chat.StreamEvent) error {
+		if event.Type == chat.StreamEventTypeContent {
+			// Let StreamToWriter close any open thinking block first
+			if err := render(chat.StreamEvent{Type: chat.StreamEventTypeContent}); err != nil {
+				return err
+			}
+			_, err := io.WriteString(md, event.Content)
+			return err
+		}
+		if err := md.Flush(); err != nil {
+			return err
+		}
+		return render(event)
+	}
+}
+
 func parseFlags() *Config {
 	return parseFlagsArgs(os.Args[1:])
 }
@@ -75,11 +162,50 @@ func parseFlagsArgs(args []string) *Config {
 	fs.StringVar(&config.PersistenceFile, "persist", "", "SQLite file for conversation persistence (empty for memory-only)")
 	fs.Float64Var(&config.CompactThreshold, "compact", 0.8, "Threshold for automatic context compaction (0.0-1.0)")
 	fs.BoolVar(&config.SystemReminder, "system-reminder", false, "Enable system reminders that track tool usage and context")
+	fs.StringVar(&config.Render, "render", "plain", "Output rendering: plain, ansi, or markdown (ANSI-styled markdown with syntax highlighting)")
+	fs.StringVar(&config.Prompt, "p", "", "Run a single prompt non-interactively and print the response (\"-\" reads the prompt from stdin)")
+	fs.StringVar(&config.OutputFormat, "output-format", "text", "Output format for -p: text or json")
+	fs.BoolVar(&config.Continue, "continue", false, "Continue the most recent session in the -persist file")
+	fs.Var(resumeFlag{&config.Resume}, "resume", "Resume a session from the -persist file: -resume=ID, or -resume to choose from recent sessions")
+	fs.BoolVar(&config.ConfirmWrites, "confirm-writes", false, "Show a diff of each file write and ask before making it")
+	fs.StringVar(&config.ConfigFile, "config", "", "YAML config file with model aliases, provider endpoints, default options, and tool allowlists")
 	_ = fs.Parse(args)
 
+	config.setFlags = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		config.setFlags[f.Name] = true
+	})
+
 	return &config
 }
 
+// loadConfigFile loads config.ConfigFile, using its default model and
+// options (including those of the model's alias) for any flags not given on
+// the command line.
+func loadConfigFile(config *Config) error {
+	file, err := llm.LoadConfig(config.ConfigFile)
+	if err != nil {
+		return err
+	}
+	config.file = file
+
+	if !config.setFlags["model"] && file.Model != "" {
+		config.Model = file.Model
+	}
+	defaults := file.ModelDefaults(config.Model)
+	if !config.setFlags["temperature"] && defaults.Temperature != nil {
+		config.Temperature = *defaults.Temperature
+	}
+	if !config.setFlags["max-tokens"] && defaults.MaxTokens > 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+	if !config.setFlags["system"] && defaults.SystemPrompt != "" {
+		config.SystemPrompt = defaults.SystemPrompt
+	}
+	config.ReasoningEffort = defaults.ReasoningEffort
+	return nil
+}
+
 // createClientFunc is a variable to allow mocking in tests
 var createClientFunc = func(config *Config) (chat.Client, error) {
 	// Enable debug logging if requested
@@ -96,18 +222,76 @@ var createClientFunc = func(config *Config) (chat.Client, error) {
 		SystemPrompt: config.SystemPrompt,
 		LogLevel:     -1, // Don't change log level from environment default (already set above if Debug)
 	}
+	if config.file != nil {
+		// Route aliases to their models, falling back as configured
+		return config.file.Router().NewClient(llmConfig)
+	}
 	return llm.NewClient(llmConfig)
 }
 
 func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer) error {
+	switch config.Render {
+	case "", "plain", "ansi", "markdown":
+	default:
+		return fmt.Errorf("unknown -render mode %q (want plain, ansi, or markdown)", config.Render)
+	}
+	switch config.OutputFormat {
+	case "", "text":
+	case "json":
+		if config.Prompt == "" {
+			return fmt.Errorf("-output-format=json requires -p")
+		}
+	default:
+		return fmt.Errorf("unknown -output-format %q (want text or json)", config.OutputFormat)
+	}
+
+	if (config.Continue || config.Resume != "") && config.PersistenceFile == "" {
+		return fmt.Errorf("-continue and -resume require -persist")
+	}
+	if config.Continue && config.Resume != "" {
+		return fmt.Errorf("-continue and -resume can't be used together")
+	}
+	if config.ConfigFile != "" {
+		if err := loadConfigFile(config); err != nil {
+			return err
+		}
+	}
+
+	// In print mode stdout is reserved for the response
+	info := output
+	if config.Prompt != "" {
+		info = errOutput
+	}
+
+	// Create a reader for user input
+	reader := bufio.NewReader(input)
+
 	// Create the appropriate client based on the model
 	client, err := createClientFunc(config)
 	if err != nil {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
 
+	// Fail now on a bad API key or model name, rather than on the first
+	// message; a provider that is only briefly unavailable may recover
+	if pinger, ok := client.(chat.Pinger); ok {
+		pingCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		status, err := pinger.Ping(pingCtx)
+		cancel()
+		if err != nil && !status.Retryable() {
+			return err
+		}
+	}
+
 	// Set up session options
-	var sessionOpts []agent.SessionOption
+	sessionOpts := []agent.SessionOption{agent.WithDefaultOptions(messageOptions(config)...)}
+	if config.ConfirmWrites {
+		sessionOpts = append(sessionOpts, agent.WithToolApprover(confirmWrites(reader, info)))
+	} else {
+		// Without -confirm-writes, destructive tools like WriteFile run
+		// without asking
+		sessionOpts = append(sessionOpts, agent.WithApprovalRule(nil))
+	}
 
 	// Set up persistence if requested
 	if config.PersistenceFile != "" {
@@ -126,7 +310,15 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 		defer store.Close()
 		sessionOpts = append(sessionOpts, agent.WithStore(store))
 
-		_, _ = fmt.Fprintf(output, "Using persistent session: %s\n", config.PersistenceFile)
+		_, _ = fmt.Fprintf(info, "Using persistent session: %s\n", config.PersistenceFile)
+
+		sessionID, err := resumeSessionID(config, store, reader, info)
+		if err != nil {
+			return err
+		}
+		if sessionID != "" {
+			sessionOpts = append(sessionOpts, agent.WithRestoreSession(sessionID))
+		}
 	}
 
 	// Create a session with automatic context management
@@ -135,6 +327,13 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	session.SetCompactionThreshold(config.CompactThreshold)
+	if config.PersistenceFile != "" {
+		if config.Continue || config.Resume != "" {
+			_, _ = fmt.Fprintf(info, "Resumed session %s (%d live records)\n", session.SessionID(), len(session.LiveRecords()))
+		} else {
+			_, _ = fmt.Fprintf(info, "Session ID: %s (resume with -resume=%s)\n", session.SessionID(), session.SessionID())
+		}
+	}
 
 	root, err := os.OpenRoot(".")
 	if err != nil {
@@ -142,7 +341,7 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 	}
 	defer root.Close()
 
-	ctx := fstools.WithFS(context.Background(), root.FS())
+	ctx := chat.WithWorkspace(context.Background(), workspace.NewRoot(root))
 
 	// Track tool usage if system reminders are enabled
 	var (
@@ -154,57 +353,99 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 	)
 
 	// Register filesystem tools (directly or with tracking wrappers)
+	tools := []chat.Tool{fstools.ReadDirTool, fstools.ReadFileTool, fstools.ReadImageTool, fstools.WriteFileTool}
 	if config.SystemReminder {
 		// Create tracking wrappers
-		readDirTool := &toolWrapper{
-			tool: fstools.ReadDirTool,
-			onCall: func() {
-				toolCallCount++
-				dirsListed++
-				lastToolCalled = "read_dir"
+		tools = []chat.Tool{
+			&toolWrapper{
+				tool: fstools.ReadDirTool,
+				onCall: func() {
+					toolCallCount++
+					dirsListed++
+					lastToolCalled = "read_dir"
+				},
 			},
-		}
-		readFileTool := &toolWrapper{
-			tool: fstools.ReadFileTool,
-			onCall: func() {
-				toolCallCount++
-				filesRead++
-				lastToolCalled = "read_file"
+			&toolWrapper{
+				tool: fstools.ReadFileTool,
+				onCall: func() {
+					toolCallCount++
+					filesRead++
+					lastToolCalled = "read_file"
+				},
 			},
-		}
-		writeFileTool := &toolWrapper{
-			tool: fstools.WriteFileTool,
-			onCall: func() {
-				toolCallCount++
-				filesWritten++
-				lastToolCalled = "write_file"
+			&toolWrapper{
+				tool: fstools.ReadImageTool,
+				onCall: func() {
+					toolCallCount++
+					filesRead++
+					lastToolCalled = "read_image"
+				},
+			},
+			&toolWrapper{
+				tool: fstools.WriteFileTool,
+				onCall: func() {
+					toolCallCount++
+					filesWritten++
+					lastToolCalled = "write_file"
+				},
 			},
 		}
-
-		if err := session.RegisterTool(readDirTool); err != nil {
-			return fmt.Errorf("failed to register ReadDirTool: %w", err)
-		}
-		if err = session.RegisterTool(readFileTool); err != nil {
-			return fmt.Errorf("failed to register ReadFileTool: %w", err)
-		}
-		if err = session.RegisterTool(writeFileTool); err != nil {
-			return fmt.Errorf("failed to register WriteFileTool: %w", err)
-		}
-	} else {
-		// Register tools directly without tracking
-		if err := session.RegisterTool(fstools.ReadDirTool); err != nil {
-			return fmt.Errorf("failed to register ReadDirTool: %w", err)
+	}
+	for _, tool := range tools {
+		// The -config file's tool policy can restrict which tools are available
+		if config.file != nil && !config.file.Tools.Allowed(tool.Name()) {
+			continue
 		}
-		if err = session.RegisterTool(fstools.ReadFileTool); err != nil {
-			return fmt.Errorf("failed to register ReadFileTool: %w", err)
+		if err := session.RegisterTool(tool); err != nil {
+			return fmt.Errorf("failed to register %s: %w", tool.Name(), err)
 		}
-		if err = session.RegisterTool(fstools.WriteFileTool); err != nil {
-			return fmt.Errorf("failed to register WriteFileTool: %w", err)
+	}
+
+	// withToolReminder adds a system reminder summarizing tool usage during
+	// a message, if enabled
+	withToolReminder := func(ctx context.Context) context.Context {
+		if !config.SystemReminder {
+			return ctx
 		}
+
+		// Reset tool counts for this message
+		prevToolCount := toolCallCount
+		prevFilesRead := filesRead
+		prevFilesWritten := filesWritten
+		prevDirsListed := dirsListed
+
+		return chat.WithSystemReminder(ctx, func() string {
+			// This function executes AFTER tools are called
+			if toolCallCount > prevToolCount {
+				var actions []string
+				if filesRead > prevFilesRead {
+					actions = append(actions, fmt.Sprintf("read %d file(s)", filesRead-prevFilesRead))
+				}
+				if filesWritten > prevFilesWritten {
+					actions = append(actions, fmt.Sprintf("wrote %d file(s)", filesWritten-prevFilesWritten))
+				}
+				if dirsListed > prevDirsListed {
+					actions = append(actions, fmt.Sprintf("listed %d director(ies)", dirsListed-prevDirsListed))
+				}
+
+				// Check context usage
+				metrics := session.Metrics()
+				contextInfo := fmt.Sprintf("Context: %.1f%% full", metrics.PercentFull*100)
+
+				if len(actions) > 0 {
+					return fmt.Sprintf("<system-reminder>Tools executed: %s. Last tool: %s. %s</system-reminder>",
+						strings.Join(actions, ", "), lastToolCalled, contextInfo)
+				}
+				return fmt.Sprintf("<system-reminder>Tool '%s' was called. %s</system-reminder>",
+					lastToolCalled, contextInfo)
+			}
+			return ""
+		})
 	}
 
-	// Create a reader for user input
-	reader := bufio.NewReader(input)
+	if config.Prompt != "" {
+		return runPrint(withToolReminder(ctx), config, session, reader, output, errOutput)
+	}
 
 	_, _ = fmt.Fprintln(output, "Chat started. Type 'exit' or 'quit' to end the conversation.")
 	_, _ = fmt.Fprintln(output, "Type your message and press Enter twice to send (or Ctrl+D on a new line).")
@@ -230,16 +471,7 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 				_, _ = fmt.Fprintln(output, "\nGoodbye!")
 
 				// Show session metrics
-				metrics := session.Metrics()
-				_, _ = fmt.Fprintf(output, "\nSession Stats:\n")
-				_, _ = fmt.Fprintf(output, "  Total tokens used: %d\n", metrics.CumulativeTokens)
-				_, _ = fmt.Fprintf(output, "  Live context: %d/%d tokens (%.1f%% full)\n",
-					metrics.LiveTokens, metrics.MaxTokens, metrics.PercentFull*100)
-				_, _ = fmt.Fprintf(output, "  Records: %d live, %d total\n", metrics.RecordsLive, metrics.RecordsTotal)
-				if metrics.CompactionCount > 0 {
-					_, _ = fmt.Fprintf(output, "  Compactions: %d (last: %s)\n",
-						metrics.CompactionCount, metrics.LastCompaction.Format("15:04:05"))
-				}
+				printSessionStats(output, session.Metrics())
 
 				return nil
 			}
@@ -266,6 +498,9 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 						_, _ = fmt.Fprintf(output, "  Compactions: %d (last: %s)\n",
 							metrics.CompactionCount, metrics.LastCompaction.Format("15:04:05"))
 					}
+					for _, line := range session.Analytics().Summary() {
+						_, _ = fmt.Fprintf(output, "  %s\n", line)
+					}
 					_, _ = fmt.Fprintln(output, "---")
 					continue
 				} else if line == "/help" {
@@ -300,127 +535,12 @@ func run(config *Config, input io.Reader, output io.Writer, errOutput io.Writer)
 		// Send message and get response
 		_, _ = fmt.Fprint(output, "\nAssistant: ")
 
-		// Build options
-		var opts []chat.Option
-		if config.Temperature >= 0 {
-			opts = append(opts, chat.WithTemperature(config.Temperature))
-		}
-		if config.MaxTokens > 0 {
-			opts = append(opts, chat.WithMaxTokens(config.MaxTokens))
-		}
-
-		// Track thinking state for better formatting
-		var isThinking bool
-		var hasShownThinkingHeader bool
-
 		// Use streaming to show output as it arrives
-		callback := func(event chat.StreamEvent) error {
-			switch event.Type {
-			case chat.StreamEventTypeThinking:
-				if !hasShownThinkingHeader {
-					// Show thinking header with clear visual indicator
-					_, _ = fmt.Fprint(output, "\n💭 Thinking...\n")
-					hasShownThinkingHeader = true
-					isThinking = true
-				}
-				// Stream the thinking content if available
-				if event.Content != "" {
-					_, _ = fmt.Fprint(output, event.Content)
-				}
-			case chat.StreamEventTypeThinkingSummary:
-				if event.ThinkingStatus != nil {
-					isThinking = false
-					if event.ThinkingStatus.Summary != "" {
-						// Show end of thinking with clear delineation
-						_, _ = fmt.Fprint(output, "\n\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n📝 Response:\n")
-					} else if hasShownThinkingHeader {
-						// Just show delineation if we had thinking but no summary
-						_, _ = fmt.Fprint(output, "\n\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n📝 Response:\n")
-					}
-				}
-			case chat.StreamEventTypeToolCall:
-				// Display tool invocation information
-				if len(event.ToolCalls) > 0 {
-					for _, tc := range event.ToolCalls {
-						_, _ = fmt.Fprintf(output, "\n🔧 Invoking tool: %s\n", tc.Name)
-						if config.Debug && len(tc.Arguments) > 0 {
-							_, _ = fmt.Fprintf(output, "   Arguments: %s\n", string(tc.Arguments))
-						}
-					}
-				}
-			case chat.StreamEventTypeToolResult:
-				// Display tool result information
-				if len(event.ToolResults) > 0 {
-					for _, tr := range event.ToolResults {
-						name := tr.Name
-						if name == "" {
-							name = tr.ToolCallID
-						}
-						_, _ = fmt.Fprintf(output, "✅ Tool result for %s:\n", name)
-						if tr.Error != "" {
-							_, _ = fmt.Fprintf(output, "   Error: %s\n", tr.Error)
-						} else if config.Debug && tr.Content != "" {
-							_, _ = fmt.Fprintf(output, "   Result: %s\n", tr.Content)
-						}
-					}
-				}
-			case chat.StreamEventTypeContent:
-				// If we were thinking and now getting content without a summary event,
-				// add delineation
-				if isThinking {
-					isThinking = false
-					_, _ = fmt.Fprint(output, "\n\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n📝 Response:\n")
-				}
-				_, _ = fmt.Fprint(output, event.Content)
-			case chat.StreamEventTypeDone:
-				// Stream is complete, nothing to display
-			}
-
-			return nil
-		}
-
-		// Add streaming callback to the options
-		opts = append(opts, chat.WithStreamingCb(callback))
-
-		// Add system reminder if enabled
-		messageCtx := ctx
-		if config.SystemReminder {
-			// Reset tool counts for this message
-			prevToolCount := toolCallCount
-			prevFilesRead := filesRead
-			prevFilesWritten := filesWritten
-			prevDirsListed := dirsListed
-
-			messageCtx = chat.WithSystemReminder(ctx, func() string {
-				// This function executes AFTER tools are called
-				if toolCallCount > prevToolCount {
-					var actions []string
-					if filesRead > prevFilesRead {
-						actions = append(actions, fmt.Sprintf("read %d file(s)", filesRead-prevFilesRead))
-					}
-					if filesWritten > prevFilesWritten {
-						actions = append(actions, fmt.Sprintf("wrote %d file(s)", filesWritten-prevFilesWritten))
-					}
-					if dirsListed > prevDirsListed {
-						actions = append(actions, fmt.Sprintf("listed %d director(ies)", dirsListed-prevDirsListed))
-					}
-
-					// Check context usage
-					metrics := session.Metrics()
-					contextInfo := fmt.Sprintf("Context: %.1f%% full", metrics.PercentFull*100)
+		callback := newStreamCallback(config, output)
 
-					if len(actions) > 0 {
-						return fmt.Sprintf("<system-reminder>Tools executed: %s. Last tool: %s. %s</system-reminder>",
-							strings.Join(actions, ", "), lastToolCalled, contextInfo)
-					}
-					return fmt.Sprintf("<system-reminder>Tool '%s' was called. %s</system-reminder>",
-						lastToolCalled, contextInfo)
-				}
-				return ""
-			})
-		}
+		messageCtx := withToolReminder(ctx)
 
-		_, err := session.Message(messageCtx, userMsg, opts...)
+		_, err := session.Message(messageCtx, userMsg, chat.WithStreamingCb(callback))
 		if err != nil {
 			_, _ = fmt.Fprintf(errOutput, "\nError: %v\n", err)
 			continue
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// fakeChat answers every message with a fixed reply, after calling any tool
+// named in toolCall.
+type fakeChat struct {
+	reply    string
+	err      error
+	toolCall string
+	tools    map[string]chat.Tool
+	prompts  []string
+	// initial holds the messages the chat was created with
+	initial []chat.Message
+	history []chat.Message
+}
+
+func (c *fakeChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.prompts = append(c.prompts, msg.GetText())
+	if c.err != nil {
+		return chat.Message{}, c.err
+	}
+	if cb := chat.ApplyOptions(opts...).StreamingCb; cb != nil && c.toolCall != "" {
+		if err := cb(chat.StreamEvent{Type: chat.StreamEventTypeToolCall, ToolCalls: []chat.ToolCall{{ID: "1", Name: c.toolCall}}}); err != nil {
+			return chat.Message{}, err
+		}
+	}
+	reply := chat.AssistantMessage(c.reply)
+	c.history = append(c.history, msg, reply)
+	return reply, nil
+}
+
+func (c *fakeChat) History() (string, []chat.Message) {
+	return "", append(append([]chat.Message(nil), c.initial...), c.history...)
+}
+func (c *fakeChat) TokenUsage() (chat.TokenUsage, error)                     { return chat.TokenUsage{}, nil }
+func (c *fakeChat) MaxTokens() int                                           { return 4096 }
+func (c *fakeChat) SetSystemPrompt(ctx context.Context, prompt string) error { return nil }
+func (c *fakeChat) RegisterTool(tool chat.Tool) error {
+	c.tools[tool.Name()] = tool
+	return nil
+}
+func (c *fakeChat) DeregisterTool(name string) { delete(c.tools, name) }
+func (c *fakeChat) ListTools() []string {
+	var names []string
+	for name := range c.tools {
+		names = append(names, name)
+	}
+	return names
+}
+
+type fakeClient struct {
+	chat *fakeChat
+}
+
+func (c *fakeClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	c.chat.tools = make(map[string]chat.Tool)
+	c.chat.initial = initialMsgs
+	c.chat.history = nil
+	return c.chat
+}
+
+// useFakeChat makes run use fc instead of a real LLM client.
+func useFakeChat(t *testing.T, fc *fakeChat) {
+	orig := createClientFunc
+	createClientFunc = func(config *Config) (chat.Client, error) {
+		return &fakeClient{chat: fc}, nil
+	}
+	t.Cleanup(func() { createClientFunc = orig })
+}
+
+func TestPrintMode(t *testing.T) {
+	fc := &fakeChat{reply: "The answer is 4.", toolCall: "ReadFile"}
+	useFakeChat(t, fc)
+
+	config := parseFlagsArgs([]string{"-p", "What is 2+2?"})
+	var stdout, stderr strings.Builder
+	require.NoError(t, run(config, strings.NewReader(""), &stdout, &stderr))
+
+	assert.Equal(t, "The answer is 4.\n", stdout.String())
+	assert.Contains(t, stderr.String(), "Session Stats:")
+	assert.Equal(t, []string{"What is 2+2?"}, fc.prompts)
+}
+
+func TestPrintModeJSON(t *testing.T) {
+	fc := &fakeChat{reply: "Done.", toolCall: "ReadFile"}
+	useFakeChat(t, fc)
+
+	config := parseFlagsArgs([]string{"-p", "-", "--output-format", "json"})
+	var stdout, stderr strings.Builder
+	require.NoError(t, run(config, strings.NewReader("Read the file"), &stdout, &stderr))
+
+	var result printResult
+	require.NoError(t, json.Unmarshal([]byte(stdout.String()), &result))
+	assert.Equal(t, "Done.", result.Result)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, []string{"ReadFile"}, result.ToolCalls)
+	assert.Equal(t, 4096, result.Metrics.MaxTokens)
+	assert.Equal(t, []string{"Read the file"}, fc.prompts)
+}
+
+func TestPrintModeError(t *testing.T) {
+	useFakeChat(t, &fakeChat{err: errors.New("rate limited")})
+
+	config := parseFlagsArgs([]string{"-p", "Hi", "-output-format=json"})
+	var stdout, stderr strings.Builder
+	err := run(config, strings.NewReader(""), &stdout, &stderr)
+	assert.ErrorContains(t, err, "rate limited")
+
+	var result printResult
+	require.NoError(t, json.Unmarshal([]byte(stdout.String()), &result))
+	assert.Contains(t, result.Error, "rate limited")
+}
+
+func TestOutputFormatValidation(t *testing.T) {
+	err := run(parseFlagsArgs([]string{"-output-format=json"}), strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, "requires -p")
+
+	err = run(parseFlagsArgs([]string{"-p", "hi", "-output-format=yaml"}), strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, `unknown -output-format "yaml"`)
+}
+
+func TestRenderFlag(t *testing.T) {
+	assert.Equal(t, "plain", parseFlagsArgs(nil).Render)
+	assert.Equal(t, "markdown", parseFlagsArgs([]string{"-render=markdown"}).Render)
+
+	err := run(&Config{Render: "html"}, strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, `unknown -render mode "html"`)
+}
+
+var sessionIDPattern = regexp.MustCompile(`Session ID: (\S+)`)
+
+// persistPrompt runs prompt in print mode against the session file db and
+// returns the ID of the session it used.
+func persistPrompt(t *testing.T, db, prompt string, args ...string) string {
+	t.Helper()
+	config := parseFlagsArgs(append([]string{"-persist", db, "-p", prompt}, args...))
+	var stdout, stderr strings.Builder
+	require.NoError(t, run(config, strings.NewReader(""), &stdout, &stderr))
+	if m := sessionIDPattern.FindStringSubmatch(stderr.String()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func TestContinueSession(t *testing.T) {
+	fc := &fakeChat{reply: "ok"}
+	useFakeChat(t, fc)
+	db := filepath.Join(t.TempDir(), "sessions.db")
+
+	persistPrompt(t, db, "first session")
+	require.Empty(t, fc.initial)
+	persistPrompt(t, db, "second session")
+
+	persistPrompt(t, db, "follow up", "-continue")
+	require.Len(t, fc.initial, 2)
+	assert.Equal(t, "second session", fc.initial[0].GetText())
+	assert.Equal(t, "ok", fc.initial[1].GetText())
+}
+
+func TestResumeSession(t *testing.T) {
+	fc := &fakeChat{reply: "ok"}
+	useFakeChat(t, fc)
+	db := filepath.Join(t.TempDir(), "sessions.db")
+
+	first := persistPrompt(t, db, "first session")
+	require.NotEmpty(t, first)
+	persistPrompt(t, db, "second session")
+
+	persistPrompt(t, db, "follow up", "-resume="+first)
+	require.NotEmpty(t, fc.initial)
+	assert.Equal(t, "first session", fc.initial[0].GetText())
+
+	config := parseFlagsArgs([]string{"-persist", db, "-p", "hi", "-resume=missing"})
+	err := run(config, strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, `session "missing" not found`)
+}
+
+func TestResumeSessionChooser(t *testing.T) {
+	fc := &fakeChat{reply: "ok"}
+	useFakeChat(t, fc)
+	db := filepath.Join(t.TempDir(), "sessions.db")
+
+	persistPrompt(t, db, "first session")
+	persistPrompt(t, db, "second session")
+
+	// Sessions are listed most recent first, and invalid choices are retried
+	config := parseFlagsArgs([]string{"-persist", db, "-resume"})
+	var stdout, stderr strings.Builder
+	require.NoError(t, run(config, strings.NewReader("9\n2\n/quit\n"), &stdout, &stderr))
+
+	out := stdout.String()
+	assert.Less(t, strings.Index(out, "second session"), strings.Index(out, "first session"))
+	assert.Contains(t, out, "Invalid choice.")
+	require.NotEmpty(t, fc.initial)
+	assert.Equal(t, "first session", fc.initial[0].GetText())
+}
+
+func TestResumeValidation(t *testing.T) {
+	err := run(parseFlagsArgs([]string{"-continue"}), strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, "require -persist")
+
+	db := filepath.Join(t.TempDir(), "sessions.db")
+	err = run(parseFlagsArgs([]string{"-persist", db, "-continue", "-resume=x"}), strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, "can't be used together")
+
+	useFakeChat(t, &fakeChat{reply: "ok"})
+	err = run(parseFlagsArgs([]string{"-persist", db, "-continue"}), strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorContains(t, err, "no sessions to resume")
+}
+
+func TestConfigFile(t *testing.T) {
+	fc := &fakeChat{reply: "ok"}
+	useFakeChat(t, fc)
+
+	configFile := filepath.Join(t.TempDir(), "agents.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+model: smart
+aliases:
+  smart:
+    model: claude-sonnet-4-5
+    options: {reasoning_effort: high}
+defaults:
+  temperature: 0.3
+  max_tokens: 2048
+tools:
+  allow: [Read*]
+`), 0o644))
+
+	config := parseFlagsArgs([]string{"-config", configFile, "-max-tokens", "100", "-p", "hi"})
+	require.NoError(t, run(config, strings.NewReader(""), &strings.Builder{}, &strings.Builder{}))
+
+	assert.Equal(t, "smart", config.Model)
+	assert.Equal(t, 0.3, config.Temperature)
+	assert.Equal(t, 100, config.MaxTokens)
+	assert.Equal(t, "high", config.ReasoningEffort)
+	assert.ElementsMatch(t, []string{"ReadDir", "ReadFile", "ReadImage"}, fc.ListTools())
+
+	config = parseFlagsArgs([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")})
+	err := run(config, strings.NewReader(""), &strings.Builder{}, &strings.Builder{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
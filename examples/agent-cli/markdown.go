@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	ansiReset       = "\x1b[0m"
+	ansiBold        = "\x1b[1m"
+	ansiDim         = "\x1b[2m"
+	ansiItalic      = "\x1b[3m"
+	ansiGreen       = "\x1b[32m"
+	ansiYellow      = "\x1b[33m"
+	ansiBlue        = "\x1b[34m"
+	ansiMagenta     = "\x1b[35m"
+	ansiCyan        = "\x1b[36m"
+	ansiBoldMagenta = "\x1b[1;35m"
+	ansiBoldCyan    = "\x1b[1;36m"
+)
+
+// lineKind is the markdown block a line belongs to.
+type lineKind int
+
+const (
+	lineUndecided lineKind = iota
+	lineParagraph
+	lineHeading
+	lineBullet
+	lineOrdered
+	lineQuote
+	lineRule
+	lineFence
+)
+
+// markdownWriter renders streamed markdown to a terminal with ANSI styling.
+// To keep streaming live it buffers as little as possible: only the start of
+// each line, until it is clear what kind of block the line begins, and whole
+// lines inside code fences, which are syntax highlighted.
+type markdownWriter struct {
+	w io.Writer
+
+	// prefix holds the start of the current line while its kind is undecided
+	prefix []byte
+	kind   lineKind
+
+	inFence   bool
+	fenceLang string
+	codeLine  []byte
+
+	// inline styles, which may span writes
+	inCode      bool
+	inBold      bool
+	pendingStar bool
+}
+
+func newMarkdownWriter(w io.Writer) *markdownWriter {
+	return &markdownWriter{w: w}
+}
+
+// Write renders p, which may end in the middle of a line or inline span.
+func (m *markdownWriter) Write(p []byte) (int, error) {
+	var out bytes.Buffer
+	for _, c := range p {
+		m.writeByte(&out, c)
+	}
+	if _, err := m.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line and resets styling, such as at the
+// end of a response or before other output is interleaved.
+func (m *markdownWriter) Flush() error {
+	var out bytes.Buffer
+	switch {
+	case m.inFence:
+		if len(m.codeLine) > 0 {
+			out.WriteString(highlightCode(m.fenceLang, string(m.codeLine)))
+			m.codeLine = m.codeLine[:0]
+		}
+	case m.kind == lineUndecided && len(m.prefix) > 0:
+		m.decide(&out, classifyLine(string(m.prefix), true))
+	}
+	if m.pendingStar {
+		out.WriteByte('*')
+		m.pendingStar = false
+	}
+	if m.kind != lineUndecided || m.inCode || m.inBold {
+		out.WriteString(ansiReset)
+		m.inCode, m.inBold = false, false
+	}
+	_, err := m.w.Write(out.Bytes())
+	return err
+}
+
+func (m *markdownWriter) writeByte(out *bytes.Buffer, c byte) {
+	if m.inFence {
+		if c != '\n' {
+			m.codeLine = append(m.codeLine, c)
+			return
+		}
+		line := string(m.codeLine)
+		m.codeLine = m.codeLine[:0]
+		if isFence(strings.TrimSpace(line)) {
+			m.inFence = false
+			out.WriteString(ansiDim + "└" + strings.Repeat("─", 39) + ansiReset + "\n")
+			return
+		}
+		out.WriteString(highlightCode(m.fenceLang, line))
+		out.WriteByte('\n')
+		return
+	}
+
+	if m.kind == lineUndecided {
+		if c == '\n' {
+			m.decide(out, classifyLine(string(m.prefix), true))
+			m.endLine(out)
+			return
+		}
+		m.prefix = append(m.prefix, c)
+		if kind := classifyLine(string(m.prefix), false); kind != lineUndecided {
+			m.decide(out, kind)
+		}
+		return
+	}
+
+	if c == '\n' {
+		m.endLine(out)
+		return
+	}
+	m.writeInline(out, c)
+}
+
+// decide renders the buffered start of the line as the given kind. The rest
+// of the line is rendered as it arrives.
+func (m *markdownWriter) decide(out *bytes.Buffer, kind lineKind) {
+	line := string(m.prefix)
+	m.prefix = m.prefix[:0]
+	m.kind = kind
+
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+
+	var rest string
+	switch kind {
+	case lineHeading:
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		rest = strings.TrimLeft(trimmed[level:], " ")
+		if level <= 2 {
+			out.WriteString(ansiBoldMagenta)
+		} else {
+			out.WriteString(ansiBoldCyan)
+		}
+	case lineBullet:
+		out.WriteString(indent + ansiYellow + "•" + ansiReset + " ")
+		rest = trimmed[2:]
+	case lineOrdered:
+		end := strings.IndexAny(trimmed, ".)") + 1
+		out.WriteString(indent + ansiYellow + trimmed[:end] + ansiReset + " ")
+		rest = strings.TrimLeft(trimmed[end:], " ")
+	case lineQuote:
+		out.WriteString(indent + ansiDim + "│" + ansiReset + " " + ansiItalic)
+		rest = strings.TrimPrefix(trimmed[1:], " ")
+	case lineRule:
+		out.WriteString(ansiDim + strings.Repeat("─", 40) + ansiReset)
+		return
+	case lineFence:
+		m.inFence = true
+		m.fenceLang = strings.ToLower(strings.TrimSpace(strings.TrimLeft(trimmed, "`~")))
+		label := m.fenceLang
+		if label == "" {
+			label = "code"
+		}
+		out.WriteString(ansiDim + "┌─ " + label + " " + strings.Repeat("─", max(0, 35-len(label))) + ansiReset)
+		return
+	default:
+		rest = line
+	}
+
+	for i := 0; i < len(rest); i++ {
+		m.writeInline(out, rest[i])
+	}
+}
+
+func (m *markdownWriter) endLine(out *bytes.Buffer) {
+	if m.pendingStar {
+		out.WriteByte('*')
+		m.pendingStar = false
+	}
+	switch m.kind {
+	case lineHeading, lineQuote:
+		out.WriteString(ansiReset)
+	}
+	if m.inCode || m.inBold {
+		// Inline spans don't continue across lines
+		out.WriteString(ansiReset)
+		m.inCode, m.inBold = false, false
+	}
+	out.WriteByte('\n')
+	m.kind = lineUndecided
+}
+
+// writeInline renders a character of inline text, handling `code` and
+// **bold** spans.
+func (m *markdownWriter) writeInline(out *bytes.Buffer, c byte) {
+	if m.pendingStar {
+		m.pendingStar = false
+		if c == '*' && !m.inCode {
+			m.inBold = !m.inBold
+			if m.inBold {
+				out.WriteString(ansiBold)
+			} else {
+				out.WriteString(ansiReset + m.lineStyle())
+			}
+			return
+		}
+		out.WriteByte('*')
+	}
+
+	switch {
+	case c == '`':
+		m.inCode = !m.inCode
+		if m.inCode {
+			out.WriteString(ansiCyan)
+		} else {
+			out.WriteString(ansiReset + m.lineStyle())
+			if m.inBold {
+				out.WriteString(ansiBold)
+			}
+		}
+	case c == '*' && !m.inCode:
+		m.pendingStar = true
+	default:
+		out.WriteByte(c)
+	}
+}
+
+// lineStyle is the style to restore after an inline span ends.
+func (m *markdownWriter) lineStyle() string {
+	switch m.kind {
+	case lineHeading:
+		return ansiBoldMagenta
+	case lineQuote:
+		return ansiItalic
+	}
+	return ""
+}
+
+func isFence(s string) bool {
+	return strings.HasPrefix(s, "```") || strings.HasPrefix(s, "~~~")
+}
+
+// classifyLine returns the kind of block that line (the start of a line, or
+// the whole line if complete) begins, or lineUndecided if more of the line
+// is needed to tell.
+func classifyLine(line string, complete bool) lineKind {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" {
+		if complete {
+			return lineParagraph
+		}
+		return lineUndecided
+	}
+
+	switch c := trimmed[0]; {
+	case c == '#':
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		if level == len(trimmed) && !complete {
+			return lineUndecided
+		}
+		if level <= 6 && level < len(trimmed) && trimmed[level] == ' ' {
+			return lineHeading
+		}
+		return lineParagraph
+	case c == '`' || c == '~':
+		if len(trimmed) < 3 && !complete && strings.Trim(trimmed, string(c)) == "" {
+			return lineUndecided
+		}
+		if isFence(trimmed) {
+			// The info string runs to the end of the line
+			if !complete {
+				return lineUndecided
+			}
+			return lineFence
+		}
+		return lineParagraph
+	case c == '-' || c == '*' || c == '+':
+		if len(trimmed) == 1 && !complete {
+			return lineUndecided
+		}
+		if len(trimmed) > 1 && trimmed[1] == ' ' && !isRuleStart(trimmed) {
+			return lineBullet
+		}
+		if c != '+' && isRuleStart(trimmed) {
+			if !complete {
+				return lineUndecided
+			}
+			if isRule(trimmed) {
+				return lineRule
+			}
+			if len(trimmed) > 1 && trimmed[1] == ' ' {
+				return lineBullet
+			}
+		}
+		return lineParagraph
+	case c >= '0' && c <= '9':
+		digits := len(trimmed) - len(strings.TrimLeftFunc(trimmed, unicode.IsDigit))
+		rest := trimmed[digits:]
+		switch {
+		case rest == "" || (len(rest) == 1 && (rest[0] == '.' || rest[0] == ')')):
+			if !complete {
+				return lineUndecided
+			}
+		case (rest[0] == '.' || rest[0] == ')') && rest[1] == ' ':
+			return lineOrdered
+		}
+		return lineParagraph
+	case c == '>':
+		// Wait for the space after the marker, so it isn't rendered
+		if len(trimmed) == 1 && !complete {
+			return lineUndecided
+		}
+		return lineQuote
+	}
+	return lineParagraph
+}
+
+// isRuleStart reports whether s could still become a thematic break like
+// "---" or "* * *" once the rest of the line arrives.
+func isRuleStart(s string) bool {
+	c := s[0]
+	for i := 0; i < len(s); i++ {
+		if s[i] != c && s[i] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func isRule(s string) bool {
+	s = strings.TrimSpace(s)
+	return isRuleStart(s) && strings.Count(s, s[:1]) >= 3
+}
+
+// languages holds keywords and comment syntax for the languages
+// highlightCode knows about.
+var languages = map[string]struct {
+	keywords      []string
+	lineComment   string
+	backtickQuote bool
+}{
+	"go": {
+		keywords: []string{
+			"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+			"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range",
+			"return", "select", "struct", "switch", "type", "var", "nil", "true", "false",
+		},
+		lineComment:   "//",
+		backtickQuote: true,
+	},
+	"python": {
+		keywords: []string{
+			"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del",
+			"elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+			"is", "lambda", "not", "or", "pass", "raise", "return", "try", "while", "with",
+			"yield", "None", "True", "False",
+		},
+		lineComment: "#",
+	},
+	"javascript": {
+		keywords: []string{
+			"async", "await", "break", "case", "catch", "class", "const", "continue", "default",
+			"delete", "do", "else", "export", "extends", "finally", "for", "function", "if",
+			"import", "in", "instanceof", "let", "new", "return", "switch", "this", "throw",
+			"try", "typeof", "var", "void", "while", "yield", "null", "undefined", "true", "false",
+			"interface", "type",
+		},
+		lineComment:   "//",
+		backtickQuote: true,
+	},
+	"rust": {
+		keywords: []string{
+			"as", "async", "await", "break", "const", "continue", "else", "enum", "fn", "for",
+			"if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref",
+			"return", "self", "Self", "static", "struct", "trait", "type", "use", "where",
+			"while", "true", "false",
+		},
+		lineComment: "//",
+	},
+	"shell": {
+		keywords: []string{
+			"case", "do", "done", "elif", "else", "esac", "export", "fi", "for", "function",
+			"if", "in", "local", "return", "then", "while",
+		},
+		lineComment: "#",
+	},
+	"json": {
+		keywords: []string{"true", "false", "null"},
+	},
+}
+
+var languageAliases = map[string]string{
+	"golang":     "go",
+	"py":         "python",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"ts":         "javascript",
+	"tsx":        "javascript",
+	"typescript": "javascript",
+	"rs":         "rust",
+	"sh":         "shell",
+	"bash":       "shell",
+	"zsh":        "shell",
+}
+
+// highlightCode applies ANSI syntax highlighting to a single line of code:
+// keywords, strings, numbers, and line comments. Unknown languages are only
+// highlighted for strings and numbers.
+func highlightCode(lang, line string) string {
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	spec := languages[lang]
+
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case spec.lineComment != "" && strings.HasPrefix(line[i:], spec.lineComment):
+			b.WriteString(ansiDim + line[i:] + ansiReset)
+			return b.String()
+		case c == '"' || c == '\'' || (c == '`' && spec.backtickQuote):
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			b.WriteString(ansiGreen + line[i:end] + ansiReset)
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			b.WriteString(ansiMagenta + line[i:end] + ansiReset)
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			if slices.Contains(spec.keywords, word) {
+				b.WriteString(ansiBlue + word + ansiReset)
+			} else {
+				b.WriteString(word)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
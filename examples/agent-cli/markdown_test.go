@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renderMarkdown renders chunks through a markdownWriter, as if streamed.
+func renderMarkdown(t *testing.T, chunks ...string) string {
+	var b strings.Builder
+	md := newMarkdownWriter(&b)
+	for _, chunk := range chunks {
+		_, err := md.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.NoError(t, md.Flush())
+	return b.String()
+}
+
+func TestMarkdownWriterBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"paragraph", "plain text\n", "plain text\n"},
+		{"heading", "## Title\n", ansiBoldMagenta + "Title" + ansiReset + "\n"},
+		{"subheading", "### Sub\n", ansiBoldCyan + "Sub" + ansiReset + "\n"},
+		{"hashtag", "#go\n", "#go\n"},
+		{"bullet", "- item\n", ansiYellow + "•" + ansiReset + " item\n"},
+		{"nested bullet", "  * item\n", "  " + ansiYellow + "•" + ansiReset + " item\n"},
+		{"ordered", "12. step\n", ansiYellow + "12." + ansiReset + " step\n"},
+		{"number", "2024 was\n", "2024 was\n"},
+		{"quote", "> wise\n", ansiDim + "│" + ansiReset + " " + ansiItalic + "wise" + ansiReset + "\n"},
+		{"rule", "---\n", ansiDim + strings.Repeat("─", 40) + ansiReset + "\n"},
+		{"inline code", "run `go test` now\n", "run " + ansiCyan + "go test" + ansiReset + " now\n"},
+		{"bold", "a **big** deal\n", "a " + ansiBold + "big" + ansiReset + " deal\n"},
+		{"lone star", "2 * 3\n", "2 * 3\n"},
+		{"bold line start", "**Note:** hi\n", ansiBold + "Note:" + ansiReset + " hi\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, renderMarkdown(t, tt.input))
+		})
+	}
+}
+
+func TestMarkdownWriterStreamsByCharacter(t *testing.T) {
+	input := "# Heading\nSome **bold** and `code`.\n- one\n- two\n"
+	chunks := strings.Split(input, "")
+	assert.Equal(t, renderMarkdown(t, input), renderMarkdown(t, chunks...))
+}
+
+func TestMarkdownWriterMinimalBuffering(t *testing.T) {
+	var b strings.Builder
+	md := newMarkdownWriter(&b)
+
+	// Paragraph text is written as soon as the line's kind is known
+	_, err := md.Write([]byte("Hello, wor"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, wor", b.String())
+
+	// An ambiguous line start is held back until it's resolved
+	b.Reset()
+	_, err = md.Write([]byte("\n-"))
+	require.NoError(t, err)
+	assert.Equal(t, "\n", b.String())
+	_, err = md.Write([]byte(" x"))
+	require.NoError(t, err)
+	assert.Equal(t, "\n"+ansiYellow+"•"+ansiReset+" x", b.String())
+}
+
+func TestMarkdownWriterCodeFence(t *testing.T) {
+	got := renderMarkdown(t, "```go\nfunc main() { // entry\n```\nafter\n")
+	assert.Contains(t, got, "┌─ go ")
+	assert.Contains(t, got, ansiBlue+"func"+ansiReset+" main() { "+ansiDim+"// entry"+ansiReset+"\n")
+	assert.Contains(t, got, "└")
+	assert.True(t, strings.HasSuffix(got, "after\n"))
+
+	// Markdown syntax inside a fence is left alone
+	got = renderMarkdown(t, "```\n# not a heading\n```\n")
+	assert.Contains(t, got, "# not a heading\n")
+	assert.NotContains(t, got, ansiBoldMagenta)
+}
+
+func TestHighlightCode(t *testing.T) {
+	assert.Equal(t,
+		ansiBlue+"def"+ansiReset+" f(): "+ansiBlue+"return"+ansiReset+" "+ansiGreen+`"hi"`+ansiReset+" "+ansiDim+"# done"+ansiReset,
+		highlightCode("py", `def f(): return "hi" # done`))
+	assert.Equal(t, ansiMagenta+"42"+ansiReset+" apples", highlightCode("unknown", "42 apples"))
+	assert.Equal(t, ansiGreen+`"a\"b"`+ansiReset, highlightCode("json", `"a\"b"`))
+}
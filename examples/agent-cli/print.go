@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+)
+
+// printResult is the output of -p with -output-format=json.
+type printResult struct {
+	Result    string               `json:"result"`
+	Error     string               `json:"error,omitzero"`
+	ToolCalls []string             `json:"toolCalls,omitzero"`
+	Metrics   agent.SessionMetrics `json:"metrics"`
+}
+
+// runPrint runs config.Prompt as a single turn (with tools) and writes the
+// assistant's final text to output, for scripting agent runs. Session stats
+// go to errOutput, as does streamed activity with -debug.
+func runPrint(ctx context.Context, config *Config, session agent.Session, input io.Reader, output, errOutput io.Writer) error {
+	prompt := config.Prompt
+	if prompt == "-" {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return fmt.Errorf("error reading prompt: %w", err)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("empty prompt")
+	}
+
+	var toolCalls []string
+	var progress chat.StreamCallback
+	if config.Debug {
+		progress = chat.StreamToWriter(errOutput, chat.StreamWriterOptions{Verbose: true})
+	}
+	streamingCb := chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		if event.Type == chat.StreamEventTypeToolCall {
+			for _, tc := range event.ToolCalls {
+				toolCalls = append(toolCalls, tc.Name)
+			}
+		}
+		if progress != nil {
+			return progress(event)
+		}
+		return nil
+	})
+
+	resp, msgErr := session.Message(ctx, chat.UserMessage(prompt), streamingCb)
+
+	if config.OutputFormat == "json" {
+		result := printResult{
+			Result:    resp.GetText(),
+			ToolCalls: toolCalls,
+			Metrics:   session.Metrics(),
+		}
+		if msgErr != nil {
+			result.Error = msgErr.Error()
+		}
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+		return msgErr
+	}
+
+	if msgErr != nil {
+		return msgErr
+	}
+	_, _ = fmt.Fprintln(output, resp.GetText())
+	printSessionStats(errOutput, session.Metrics())
+	return nil
+}
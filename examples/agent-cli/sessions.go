@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// resumeChoose is the value of -resume when given without a session ID.
+const resumeChoose = "true"
+
+// maxSessionChoices limits how many sessions -resume offers to choose from.
+const maxSessionChoices = 10
+
+// resumeFlag is a string flag that can also be given without a value:
+// -resume=ID resumes a specific session, and -resume lists recent sessions
+// to choose from.
+type resumeFlag struct {
+	value *string
+}
+
+func (f resumeFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f resumeFlag) Set(s string) error {
+	*f.value = s
+	return nil
+}
+
+func (f resumeFlag) IsBoolFlag() bool { return true }
+
+// sessionSummary describes a persisted session when choosing one to resume.
+type sessionSummary struct {
+	ID         string
+	LastActive time.Time
+	Messages   int
+	// Preview is the start of the session's first user message.
+	Preview string
+}
+
+// listSessions returns summaries of the sessions in store, most recently
+// active first.
+func listSessions(store persistence.Store) ([]sessionSummary, error) {
+	ids, err := store.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	summaries := make([]sessionSummary, 0, len(ids))
+	for _, id := range ids {
+		records, err := store.GetAllRecords(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session %s: %w", id, err)
+		}
+		summary := sessionSummary{ID: id}
+		for _, r := range records {
+			if r.Timestamp.After(summary.LastActive) {
+				summary.LastActive = r.Timestamp
+			}
+			if r.Role == chat.UserRole || r.Role == chat.AssistantRole {
+				summary.Messages++
+			}
+			if summary.Preview == "" && r.Role == chat.UserRole {
+				summary.Preview = previewText(r.GetText(), 60)
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	slices.SortStableFunc(summaries, func(a, b sessionSummary) int {
+		return cmp.Or(b.LastActive.Compare(a.LastActive), cmp.Compare(a.ID, b.ID))
+	})
+	return summaries, nil
+}
+
+// previewText returns the first line of s, truncated to n runes.
+func previewText(s string, n int) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
+
+// resumeSessionID returns the ID of the session to resume for -continue or
+// -resume, or "" to start a new session. For a bare -resume, the user
+// chooses from recent sessions listed on output.
+func resumeSessionID(config *Config, store persistence.Store, reader *bufio.Reader, output io.Writer) (string, error) {
+	if !config.Continue && config.Resume == "" {
+		return "", nil
+	}
+
+	sessions, err := listSessions(store)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "", fmt.Errorf("no sessions to resume in %s", config.PersistenceFile)
+	}
+
+	switch {
+	case config.Continue:
+		return sessions[0].ID, nil
+	case config.Resume != resumeChoose:
+		if !slices.ContainsFunc(sessions, func(s sessionSummary) bool { return s.ID == config.Resume }) {
+			return "", fmt.Errorf("session %q not found in %s", config.Resume, config.PersistenceFile)
+		}
+		return config.Resume, nil
+	}
+
+	sessions = sessions[:min(len(sessions), maxSessionChoices)]
+	_, _ = fmt.Fprintln(output, "Recent sessions:")
+	for i, s := range sessions {
+		_, _ = fmt.Fprintf(output, "  %2d. %s  %s  (%d messages)  %s\n",
+			i+1, s.LastActive.Local().Format("2006-01-02 15:04"), s.ID, s.Messages, s.Preview)
+	}
+
+	for {
+		_, _ = fmt.Fprintf(output, "Choose a session [1-%d]: ", len(sessions))
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("no session chosen: %w", err)
+		}
+		n, convErr := strconv.Atoi(strings.TrimSpace(line))
+		if convErr == nil && n >= 1 && n <= len(sessions) {
+			return sessions[n-1].ID, nil
+		}
+		if err == io.EOF {
+			return "", fmt.Errorf("invalid choice %q", strings.TrimSpace(line))
+		}
+		_, _ = fmt.Fprintln(output, "Invalid choice.")
+	}
+}
@@ -0,0 +1,82 @@
+package fstools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	lines := func(n int, edit map[int]string) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			if line, ok := edit[i]; ok {
+				b.WriteString(line)
+				continue
+			}
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{name: "unchanged", old: "a\nb\n", new: "a\nb\n", want: ""},
+		{
+			name: "replaced line",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "emptied",
+			old:  "a\nb\n",
+			new:  "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "missing final newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "separate hunks",
+			old:  lines(20, nil),
+			new:  lines(20, map[int]string{2: "two\n", 18: ""}),
+			want: "@@ -1,5 +1,5 @@\n line 1\n-line 2\n+two\n line 3\n line 4\n line 5\n" +
+				"@@ -15,6 +15,5 @@\n line 15\n line 16\n line 17\n-line 18\n line 19\n line 20\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			old:  lines(12, nil),
+			new:  lines(12, map[int]string{2: "two\n", 9: "nine\n"}),
+			want: "@@ -1,12 +1,12 @@\n line 1\n-line 2\n+two\n line 3\n line 4\n line 5\n line 6\n line 7\n line 8\n-line 9\n+nine\n line 10\n line 11\n line 12\n",
+		},
+		{
+			name: "interleaved",
+			old:  "a\nb\nc\na\nb\nb\na\n",
+			new:  "c\nb\na\nb\na\nc\n",
+			want: "@@ -1,7 +1,6 @@\n-a\n-b\n c\n+b\n a\n b\n-b\n a\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := unifiedDiff("f.txt", tt.old, tt.new, false)
+			if tt.want == "" {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, "--- a/f.txt\n+++ b/f.txt\n"+tt.want, got)
+		})
+	}
+}
@@ -20,7 +20,7 @@ type readDirResult struct {
 type readDirTool struct{}
 
 func (readDirTool) MCPJsonSchema() string {
-	return `{"name":"ReadDir","description":"Reads a directory from the test filesystem","inputSchema":{"type":"object","properties":{"path":{"type":"string","description":"Directory path to read (defaults to \".\" for root)"}},"additionalProperties":false},"outputSchema":{"type":"object","properties":{"error":{"type":["string","null"]},"files":{"type":"array","items":{"type":"object","properties":{"isDir":{"type":"boolean"},"name":{"type":"string"},"size":{"type":"integer"}},"required":["name","isDir","size"],"additionalProperties":false}}},"required":["files","error"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"}}`
+	return `{"name":"ReadDir","title":"Read directory","description":"Reads a directory from the test filesystem","inputSchema":{"type":"object","properties":{"path":{"type":"string","description":"Directory path to read (defaults to \".\" for root)"}},"additionalProperties":false},"outputSchema":{"type":"object","properties":{"error":{"type":["string","null"]},"files":{"type":"array","items":{"type":"object","properties":{"isDir":{"type":"boolean"},"name":{"type":"string"},"size":{"type":"integer"}},"required":["name","isDir","size"],"additionalProperties":false}}},"required":["files"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"},"annotations":{"readOnlyHint":true}}`
 }
 
 func (readDirTool) Name() string {
@@ -20,7 +20,7 @@ type readFileResult struct {
 type readFileTool struct{}
 
 func (readFileTool) MCPJsonSchema() string {
-	return `{"name":"ReadFile","description":"Reads a file from the test filesystem","inputSchema":{"type":"object","properties":{"fileName":{"type":"string"}},"required":["fileName"],"additionalProperties":false},"outputSchema":{"type":"object","properties":{"content":{"type":"string"},"error":{"type":["string","null"]}},"required":["content","error"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"}}`
+	return `{"name":"ReadFile","title":"Read file","description":"Reads a file from the test filesystem, at most 100 KiB at a time. Longer files end with a marker saying how to read the rest, and binary files are described rather than returned.","inputSchema":{"type":"object","properties":{"fileName":{"type":"string"},"limit":{"type":"integer","description":"Maximum bytes to return (defaults to and is capped at 100 KiB)"},"offset":{"type":"integer","description":"Byte offset to start reading at, for continuing a truncated read"}},"required":["fileName"],"additionalProperties":false},"outputSchema":{"type":"object","properties":{"binary":{"type":"boolean","description":"Whether the file isn't text, in which case content only describes it"},"content":{"type":"string"},"error":{"type":["string","null"]},"nextOffset":{"type":"integer","description":"Offset to continue reading at, if the content was truncated"},"size":{"type":"integer","description":"Size of the whole file in bytes"}},"required":["content","size"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"},"annotations":{"readOnlyHint":true}}`
 }
 
 func (readFileTool) Name() string {
@@ -28,7 +28,7 @@ func (readFileTool) Name() string {
 }
 
 func (readFileTool) Description() string {
-	return "Reads a file from the test filesystem"
+	return "Reads a file from the test filesystem, at most 100 KiB at a time. Longer files end with a marker saying how to read the rest, and binary files are described rather than returned."
 }
 
 func (readFileTool) Call(ctx context.Context, input string) string {
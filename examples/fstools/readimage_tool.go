@@ -0,0 +1,69 @@
+package fstools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// readImageResult is the internal result wrapper that adds error handling
+type readImageResult struct {
+	ReadImageResult
+
+	Error *string `json:"error,omitzero"`
+}
+
+// readImageTool implements chat.ImageTool for the ReadImage function. It is
+// written by hand, as funcschema only generates tools with text results.
+type readImageTool struct{}
+
+func (readImageTool) MCPJsonSchema() string {
+	return `{"name":"ReadImage","title":"Read image","description":"Reads an image, such as a screenshot or diagram, from the test filesystem so you can see it","inputSchema":{"type":"object","properties":{"fileName":{"type":"string","description":"Path of a PNG, JPEG, GIF, or WebP image"}},"required":["fileName"],"additionalProperties":false},"outputSchema":{"type":"object","properties":{"error":{"type":["string","null"]},"mediaType":{"type":"string"},"size":{"type":"integer"}},"required":["mediaType","size"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"},"annotations":{"readOnlyHint":true}}`
+}
+
+func (readImageTool) Name() string {
+	return "ReadImage"
+}
+
+func (readImageTool) Description() string {
+	return "Reads an image, such as a screenshot or diagram, from the test filesystem so you can see it"
+}
+
+// Call returns the result without the image, for callers that can't send
+// images to the model.
+func (t readImageTool) Call(ctx context.Context, input string) string {
+	result, _ := t.CallWithImages(ctx, input)
+	return result
+}
+
+func (readImageTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	// Parse the input JSON
+	var req ReadImageRequest
+	if err := json.Unmarshal([]byte(input), &req); err != nil {
+		errStr := "failed to parse input: " + err.Error()
+		errResp := readImageResult{Error: &errStr}
+		respBytes, _ := json.Marshal(errResp)
+		return string(respBytes), nil
+	}
+
+	result, image, err := ReadImage(ctx, req)
+	if err != nil {
+		errStr := err.Error()
+		respBytes, _ := json.Marshal(readImageResult{Error: &errStr})
+		return string(respBytes), nil
+	}
+
+	respBytes, marshalErr := json.Marshal(readImageResult{ReadImageResult: result})
+	if marshalErr != nil {
+		errStr := "failed to marshal response: " + marshalErr.Error()
+		errResp := readImageResult{Error: &errStr}
+		respBytes, _ := json.Marshal(errResp)
+		return string(respBytes), nil
+	}
+
+	return string(respBytes), []chat.ImageContent{image}
+}
+
+// ReadImageTool is the tool definition for the ReadImage function
+var ReadImageTool chat.ImageTool = readImageTool{}
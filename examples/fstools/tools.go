@@ -1,30 +1,41 @@
+// Package fstools provides tools for reading and writing files in the
+// workspace of a tool call's context (see chat.WithWorkspace).
+//
+// WriteFileTool is annotated as destructive, so agent sessions deny its
+// calls unless they have a tool approver (agent.WithToolApprover), or an
+// approval rule that doesn't require approval for it
+// (agent.WithApprovalRule).
 package fstools
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"os"
+	"net/http"
 	"path"
 	"strings"
-)
+	"unicode/utf8"
 
-// contextKey is a private type for context keys
-type contextKey struct{}
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/workspace"
+)
 
-// WithFS adds an fs.FS to the context for downstream tool calls.
-func WithFS(ctx context.Context, f fs.FS) context.Context {
-	return context.WithValue(ctx, contextKey{}, f)
+// WithFS makes fsys the workspace for downstream tool calls, as
+// chat.WithWorkspace(ctx, workspace.FromFS(fsys)) does.
+func WithFS(ctx context.Context, fsys fs.FS) context.Context {
+	return chat.WithWorkspace(ctx, workspace.FromFS(fsys))
 }
 
-// GetFS retrieves the filesystem from the context.
-func GetFS(ctx context.Context) (fs.FS, error) {
-	fs, ok := ctx.Value(contextKey{}).(fs.FS)
-	if !ok {
+// GetWorkspace retrieves the workspace from the context.
+func GetWorkspace(ctx context.Context) (chat.Workspace, error) {
+	ws := chat.GetWorkspace(ctx)
+	if ws == nil {
 		return nil, fmt.Errorf("no filesystem found in context")
 	}
-	return fs, nil
+	return ws, nil
 }
 
 // ReadDirRequest is the input for ReadDir
@@ -47,8 +58,11 @@ type FileInfo struct {
 //go:generate go run ../../cmd/build/funcschema/main.go -func ReadDir -input tools.go
 
 // ReadDir reads a directory from the test filesystem
+//
+// go-agent:title Read directory
+// go-agent:readonly
 func ReadDir(ctx context.Context, req ReadDirRequest) (ReadDirResult, error) {
-	fileSystem, err := GetFS(ctx)
+	ws, err := GetWorkspace(ctx)
 	if err != nil {
 		return ReadDirResult{}, err
 	}
@@ -64,7 +78,7 @@ func ReadDir(ctx context.Context, req ReadDirRequest) (ReadDirResult, error) {
 		}
 	}
 
-	entries, err := fs.ReadDir(fileSystem, dirPath)
+	entries, err := ws.ReadDir(ctx, dirPath)
 	if err != nil {
 		return ReadDirResult{}, fmt.Errorf("failed to read directory %s: %w", dirPath, err)
 	}
@@ -85,21 +99,39 @@ func ReadDir(ctx context.Context, req ReadDirRequest) (ReadDirResult, error) {
 	return ReadDirResult{Files: files}, nil
 }
 
+// MaxReadSize is the most bytes ReadFile returns in one call, so a single
+// read of a large file can't fill the model's context window.
+const MaxReadSize = 100 << 10
+
+// binarySniffSize is how much of a file ReadFile examines to decide whether
+// it is binary.
+const binarySniffSize = 8 << 10
+
 // ReadFileRequest is the input for ReadFile
 type ReadFileRequest struct {
 	FileName string `json:"fileName"`
+	Offset   int64  `json:"offset,omitzero"` // Byte offset to start reading at, for continuing a truncated read
+	Limit    int    `json:"limit,omitzero"`  // Maximum bytes to return (defaults to and is capped at 100 KiB)
 }
 
 // ReadFileResult is the output of ReadFile
 type ReadFileResult struct {
-	Content string `json:"content"`
+	Content    string `json:"content"`
+	Size       int64  `json:"size"`                // Size of the whole file in bytes
+	NextOffset int64  `json:"nextOffset,omitzero"` // Offset to continue reading at, if the content was truncated
+	Binary     bool   `json:"binary,omitzero"`     // Whether the file isn't text, in which case content only describes it
 }
 
 //go:generate go run ../../cmd/build/funcschema/main.go -func ReadFile -input tools.go
 
-// ReadFile reads a file from the test filesystem
+// ReadFile reads a file from the test filesystem, at most 100 KiB at a time.
+// Longer files end with a marker saying how to read the rest, and binary
+// files are described rather than returned.
+//
+// go-agent:title Read file
+// go-agent:readonly
 func ReadFile(ctx context.Context, req ReadFileRequest) (ReadFileResult, error) {
-	fileSystem, err := GetFS(ctx)
+	ws, err := GetWorkspace(ctx)
 	if err != nil {
 		return ReadFileResult{}, err
 	}
@@ -109,36 +141,156 @@ func ReadFile(ctx context.Context, req ReadFileRequest) (ReadFileResult, error)
 	fileName := path.Clean(req.FileName)
 	fileName = strings.TrimPrefix(fileName, "/")
 
-	file, err := fileSystem.Open(fileName)
+	limit := req.Limit
+	if limit <= 0 || limit > MaxReadSize {
+		limit = MaxReadSize
+	}
+	if req.Offset < 0 {
+		return ReadFileResult{}, fmt.Errorf("invalid offset %d", req.Offset)
+	}
+
+	file, err := ws.Open(ctx, fileName)
 	if err != nil {
 		return ReadFileResult{}, fmt.Errorf("failed to open file %s: %w", fileName, err)
 	}
 	defer file.Close()
 
-	content, err := io.ReadAll(file)
+	info, err := file.Stat()
+	if err != nil {
+		return ReadFileResult{}, fmt.Errorf("failed to stat file %s: %w", fileName, err)
+	}
+	size := info.Size()
+
+	// Sniff the start of the file, so a read from an offset into a binary
+	// file is caught too
+	head, err := io.ReadAll(io.LimitReader(file, binarySniffSize))
 	if err != nil {
 		return ReadFileResult{}, fmt.Errorf("failed to read file %s: %w", fileName, err)
 	}
+	if isBinary(head, len(head) == binarySniffSize) {
+		return ReadFileResult{
+			Content: fmt.Sprintf("[Binary file %s (%s, %d bytes) not shown]", fileName, http.DetectContentType(head), size),
+			Size:    size,
+			Binary:  true,
+		}, nil
+	}
+
+	if req.Offset > size {
+		return ReadFileResult{}, fmt.Errorf("offset %d is past the end of %s (%d bytes)", req.Offset, fileName, size)
+	}
+	// Read one byte past the limit to tell whether the file continues
+	chunk, err := readAt(file, head, req.Offset, limit+1)
+	if err != nil {
+		return ReadFileResult{}, fmt.Errorf("failed to read file %s: %w", fileName, err)
+	}
+	if len(chunk) <= limit {
+		return ReadFileResult{Content: string(chunk), Size: size}, nil
+	}
+
+	content := truncateUTF8(chunk, limit)
+	next := req.Offset + int64(len(content))
+	return ReadFileResult{
+		Content: fmt.Sprintf("%s\n\n[Output truncated: showing bytes %d-%d of %d. Call ReadFile with {\"fileName\": %q, \"offset\": %d} to read more.]",
+			content, req.Offset, next, size, fileName, next),
+		Size:       size,
+		NextOffset: next,
+	}, nil
+}
+
+// readAt reads up to n bytes of file starting at offset, where head holds
+// the bytes already read from the start of the file.
+func readAt(file fs.File, head []byte, offset int64, n int) ([]byte, error) {
+	var buf []byte
+	if offset < int64(len(head)) {
+		buf = append(buf, head[offset:min(int64(len(head)), offset+int64(n))]...)
+	} else if _, err := io.CopyN(io.Discard, file, offset-int64(len(head))); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(buf) == n || len(head) < binarySniffSize {
+		// Either n bytes are in head, or head is the whole file
+		return buf, nil
+	}
+	rest, err := io.ReadAll(io.LimitReader(file, int64(n-len(buf))))
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, rest...), nil
+}
+
+// isBinary reports whether data, the start of a file, looks like something
+// other than text: it has NUL bytes or isn't valid UTF-8. partial says
+// whether data was cut short of the end of the file.
+func isBinary(data []byte, partial bool) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	// Sniffed bytes may end partway through a character
+	if partial {
+		for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(data[i]) {
+				if !utf8.FullRune(data[i:]) {
+					data = data[:i]
+				}
+				break
+			}
+		}
+	}
+	return !utf8.Valid(data)
+}
 
-	return ReadFileResult{Content: string(content)}, nil
+// truncateUTF8 returns the longest prefix of b that is at most n bytes and
+// doesn't split a multi-byte character.
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return b[:n]
+}
+
+// WriteFile modes, which say whether a write may replace an existing file.
+const (
+	// WriteModeCreate only creates new files.
+	WriteModeCreate = "create"
+	// WriteModeReplace only replaces existing files.
+	WriteModeReplace = "replace"
+)
+
+// overwriteDeniedKey is the context key for WithOverwriteDenied.
+type overwriteDeniedKey struct{}
+
+// WithOverwriteDenied makes WriteFile refuse to replace existing files, as
+// if every call asked for WriteModeCreate.
+func WithOverwriteDenied(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overwriteDeniedKey{}, true)
 }
 
 // WriteFileRequest is the input for WriteFile
 type WriteFileRequest struct {
 	FileName string `json:"fileName"`
 	Content  string `json:"content"`
+	Mode     string `json:"mode,omitzero"`    // "create" to fail if the file exists, or "replace" to fail if it doesn't; by default either is fine
+	Preview  bool   `json:"preview,omitzero"` // Return a unified diff of the change without making it
 }
 
 // WriteFileResult is the output of WriteFile
 type WriteFileResult struct {
-	Success bool `json:"success"`
+	Success bool   `json:"success"`       // Whether the file was written, which previews never are
+	Diff    string `json:"diff,omitzero"` // The change a preview would make, as a unified diff
 }
 
 //go:generate go run ../../cmd/build/funcschema/main.go -func WriteFile -input tools.go
 
-// WriteFile writes a file to the test filesystem
+// WriteFile writes a file to the test filesystem, replacing it atomically
+// where the filesystem allows. Set preview to see the change as a diff
+// first.
+//
+// go-agent:title Write file
+// go-agent:destructive
 func WriteFile(ctx context.Context, req WriteFileRequest) (WriteFileResult, error) {
-	fileSystem, err := GetFS(ctx)
+	ws, err := GetWorkspace(ctx)
 	if err != nil {
 		return WriteFileResult{}, err
 	}
@@ -147,32 +299,128 @@ func WriteFile(ctx context.Context, req WriteFileRequest) (WriteFileResult, erro
 	fileName := path.Clean(req.FileName)
 	fileName = strings.TrimPrefix(fileName, "/")
 
-	// Create directory if needed
-	dir := path.Dir(fileName)
-	if dir != "." && dir != "/" {
-		type mkdirAller interface {
-			MkdirAll(path string, perm os.FileMode) error
-		}
-		if f, ok := fileSystem.(mkdirAller); ok {
-			err = f.MkdirAll(dir, 0o755)
-			if err != nil {
-				return WriteFileResult{}, fmt.Errorf("failed to create directory %s: %w", dir, err)
-			}
+	if req.Preview {
+		diff, err := DiffWrite(ctx, req)
+		if err != nil {
+			return WriteFileResult{}, err
 		}
+		return WriteFileResult{Diff: diff}, nil
 	}
 
-	// github.com/psanford/memfs.FS implements this
-	type writer interface {
-		WriteFile(path string, data []byte, perm os.FileMode) error
+	if _, _, err := checkWriteMode(ctx, ws, fileName, req.Mode); err != nil {
+		return WriteFileResult{}, err
 	}
-	if f, ok := fileSystem.(writer); ok {
-		err = f.WriteFile(fileName, []byte(req.Content), 0o644)
-		if err != nil {
-			return WriteFileResult{}, fmt.Errorf("failed to write file %s: %w", fileName, err)
-		}
-	} else {
-		return WriteFileResult{}, fmt.Errorf("read-only filesystem")
+	if err := ws.WriteFile(ctx, fileName, []byte(req.Content)); err != nil {
+		return WriteFileResult{}, fmt.Errorf("failed to write file %s: %w", fileName, err)
 	}
 
 	return WriteFileResult{Success: true}, nil
 }
+
+// DiffWrite returns the unified diff of the change WriteFile would make for
+// req, or "" if it wouldn't change the file. Tool approval hooks can show it
+// so users approve the exact change.
+func DiffWrite(ctx context.Context, req WriteFileRequest) (string, error) {
+	ws, err := GetWorkspace(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := path.Clean(req.FileName)
+	fileName = strings.TrimPrefix(fileName, "/")
+
+	old, exists, err := checkWriteMode(ctx, ws, fileName, req.Mode)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(fileName, string(old), req.Content, !exists), nil
+}
+
+// checkWriteMode returns the contents of the file a write would replace,
+// and whether it exists, or an error if the write's mode forbids it.
+func checkWriteMode(ctx context.Context, ws chat.Workspace, fileName, mode string) ([]byte, bool, error) {
+	if denied, _ := ctx.Value(overwriteDeniedKey{}).(bool); denied {
+		mode = WriteModeCreate
+	}
+	switch mode {
+	case "", WriteModeCreate, WriteModeReplace:
+	default:
+		return nil, false, fmt.Errorf("unknown mode %q (want %q or %q)", mode, WriteModeCreate, WriteModeReplace)
+	}
+
+	old, err := readAll(ctx, ws, fileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", fileName, err)
+	}
+	exists := err == nil
+	if exists && mode == WriteModeCreate {
+		return nil, false, fmt.Errorf("file %s already exists", fileName)
+	}
+	if !exists && mode == WriteModeReplace {
+		return nil, false, fmt.Errorf("file %s does not exist", fileName)
+	}
+	return old, exists, nil
+}
+
+// readAll reads the whole of the named file.
+func readAll(ctx context.Context, ws chat.Workspace, name string) ([]byte, error) {
+	f, err := ws.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// MaxImageSize is the largest image ReadImage reads, the smallest limit of
+// the providers that accept images.
+const MaxImageSize = 5 << 20
+
+// ReadImageRequest is the input for ReadImage
+type ReadImageRequest struct {
+	FileName string `json:"fileName"` // Path of a PNG, JPEG, GIF, or WebP image
+}
+
+// ReadImageResult is the output of ReadImage
+type ReadImageResult struct {
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+}
+
+// ReadImage reads an image, such as a screenshot or diagram, from the test
+// filesystem. The image is returned separately from the result, to be sent
+// to the model as image content.
+func ReadImage(ctx context.Context, req ReadImageRequest) (ReadImageResult, chat.ImageContent, error) {
+	ws, err := GetWorkspace(ctx)
+	if err != nil {
+		return ReadImageResult{}, chat.ImageContent{}, err
+	}
+
+	fileName := path.Clean(req.FileName)
+	fileName = strings.TrimPrefix(fileName, "/")
+
+	file, err := ws.Open(ctx, fileName)
+	if err != nil {
+		return ReadImageResult{}, chat.ImageContent{}, fmt.Errorf("failed to open file %s: %w", fileName, err)
+	}
+	defer file.Close()
+
+	// Read one byte past the limit to tell whether the file exceeds it
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return ReadImageResult{}, chat.ImageContent{}, fmt.Errorf("failed to read file %s: %w", fileName, err)
+	}
+	if len(data) > MaxImageSize {
+		return ReadImageResult{}, chat.ImageContent{}, fmt.Errorf("image %s is larger than %d bytes", fileName, MaxImageSize)
+	}
+
+	mediaType := http.DetectContentType(data)
+	switch mediaType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+	default:
+		return ReadImageResult{}, chat.ImageContent{}, fmt.Errorf("file %s is not a supported image (detected %s)", fileName, mediaType)
+	}
+
+	result := ReadImageResult{MediaType: mediaType, Size: int64(len(data))}
+	return result, chat.ImageContent{MediaType: mediaType, Data: data}, nil
+}
@@ -1,14 +1,27 @@
 package fstools
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/fs"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"unicode/utf8"
 
 	"github.com/psanford/memfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
 )
 
 func TestReadDirTool(t *testing.T) {
@@ -124,6 +137,79 @@ func TestReadFileTool(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to open file")
 }
 
+func TestReadFileLimits(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	// Large enough to span the binary sniff, with multi-byte characters
+	// that limits can fall in the middle of
+	text := strings.Repeat("héllo wörld\n", 2000)
+	require.NoError(t, testFS.WriteFile("big.txt", []byte(text), 0o644))
+	ctx := WithFS(context.Background(), testFS)
+
+	t.Run("reads in chunks", func(t *testing.T) {
+		t.Parallel()
+		var content strings.Builder
+		var offset int64
+		for {
+			result, err := ReadFile(ctx, ReadFileRequest{FileName: "big.txt", Offset: offset, Limit: 1000})
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(text)), result.Size)
+			if result.NextOffset == 0 {
+				content.WriteString(result.Content)
+				break
+			}
+			chunk, marker, ok := strings.Cut(result.Content, "\n\n[Output truncated")
+			require.True(t, ok, "truncated content ends with a marker")
+			assert.LessOrEqual(t, len(chunk), 1000)
+			assert.True(t, utf8.ValidString(chunk))
+			assert.Contains(t, marker, fmt.Sprintf(`"offset": %d`, result.NextOffset))
+			assert.Equal(t, offset+int64(len(chunk)), result.NextOffset)
+			content.WriteString(chunk)
+			offset = result.NextOffset
+		}
+		assert.Equal(t, text, content.String())
+	})
+
+	t.Run("caps the limit", func(t *testing.T) {
+		t.Parallel()
+		huge := strings.Repeat("x", MaxReadSize*2)
+		require.NoError(t, testFS.WriteFile("huge.txt", []byte(huge), 0o644))
+		result, err := ReadFile(ctx, ReadFileRequest{FileName: "huge.txt", Limit: MaxReadSize * 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(MaxReadSize), result.NextOffset)
+		assert.True(t, strings.HasPrefix(result.Content, huge[:MaxReadSize]+"\n\n[Output truncated"))
+	})
+
+	t.Run("offset past the end", func(t *testing.T) {
+		t.Parallel()
+		result, err := ReadFile(ctx, ReadFileRequest{FileName: "big.txt", Offset: int64(len(text))})
+		require.NoError(t, err)
+		assert.Empty(t, result.Content)
+		_, err = ReadFile(ctx, ReadFileRequest{FileName: "big.txt", Offset: int64(len(text)) + 1})
+		assert.ErrorContains(t, err, "past the end")
+	})
+}
+
+func TestReadFileBinary(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	require.NoError(t, testFS.WriteFile("image.png", testPNG(t), 0o644))
+	require.NoError(t, testFS.WriteFile("data.bin", []byte{'a', 0, 'b'}, 0o644))
+	require.NoError(t, testFS.WriteFile("latin1.txt", []byte("caf\xe9"), 0o644))
+	ctx := WithFS(context.Background(), testFS)
+
+	for _, name := range []string{"image.png", "data.bin", "latin1.txt"} {
+		result, err := ReadFile(ctx, ReadFileRequest{FileName: name})
+		require.NoError(t, err)
+		assert.True(t, result.Binary, name)
+		assert.True(t, strings.HasPrefix(result.Content, "[Binary file "+name), result.Content)
+	}
+
+	result, err := ReadFile(ctx, ReadFileRequest{FileName: "image.png"})
+	require.NoError(t, err)
+	assert.Contains(t, result.Content, "image/png")
+}
+
 func TestReadFileToolWrapper(t *testing.T) {
 	t.Parallel()
 	// Create in-memory filesystem
@@ -150,6 +236,69 @@ func TestReadFileToolWrapper(t *testing.T) {
 	assert.Equal(t, testContent, result.Content)
 }
 
+// testPNG returns a 1x1 PNG image.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestReadImageTool(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	data := testPNG(t)
+	require.NoError(t, testFS.WriteFile("screenshot.png", data, 0o644))
+	require.NoError(t, testFS.WriteFile("notes.txt", []byte("not an image"), 0o644))
+
+	ctx := WithFS(context.Background(), testFS)
+
+	result, img, err := ReadImage(ctx, ReadImageRequest{FileName: "/screenshot.png"})
+	require.NoError(t, err)
+	assert.Equal(t, ReadImageResult{MediaType: "image/png", Size: int64(len(data))}, result)
+	assert.Equal(t, chat.ImageContent{MediaType: "image/png", Data: data}, img)
+
+	_, _, err = ReadImage(ctx, ReadImageRequest{FileName: "notes.txt"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a supported image")
+
+	_, _, err = ReadImage(ctx, ReadImageRequest{FileName: "missing.png"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open file")
+}
+
+func TestReadImageToolWrapper(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	data := testPNG(t)
+	require.NoError(t, testFS.WriteFile("diagram.png", data, 0o644))
+
+	ctx := WithFS(context.Background(), testFS)
+
+	output, images := ReadImageTool.CallWithImages(ctx, `{"fileName": "diagram.png"}`)
+	var result struct {
+		ReadImageResult
+		Error *string `json:"error,omitzero"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	require.Nil(t, result.Error)
+	assert.Equal(t, "image/png", result.MediaType)
+	// The image is sent as image content, not encoded in the result
+	assert.NotContains(t, output, base64.StdEncoding.EncodeToString(data))
+	require.Len(t, images, 1)
+	assert.Equal(t, data, images[0].Data)
+
+	// Call, for callers that can't send images, returns the same result
+	assert.Equal(t, output, ReadImageTool.Call(ctx, `{"fileName": "diagram.png"}`))
+
+	output, images = ReadImageTool.CallWithImages(ctx, `{"fileName": "missing.png"}`)
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	require.NotNil(t, result.Error)
+	assert.Empty(t, images)
+}
+
 func TestWriteFileTool(t *testing.T) {
 	t.Parallel()
 	// Create in-memory filesystem
@@ -185,6 +334,135 @@ func TestWriteFileTool(t *testing.T) {
 	assert.Equal(t, "nested content", string(data))
 }
 
+// renameFS is a writable fstest.MapFS that can rename files, recording
+// the writes and renames made to it.
+type renameFS struct {
+	mu  sync.Mutex
+	m   fstest.MapFS
+	ops []string
+}
+
+func (f *renameFS) Open(name string) (fs.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.m.Open(name)
+}
+
+func (f *renameFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops = append(f.ops, "write "+name)
+	f.m[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func (f *renameFS) Rename(oldpath, newpath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops = append(f.ops, "rename "+oldpath+" "+newpath)
+	file, ok := f.m[oldpath]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	delete(f.m, oldpath)
+	f.m[newpath] = file
+	return nil
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+	testFS := &renameFS{m: fstest.MapFS{"dir/a.txt": {Data: []byte("old")}}}
+	ctx := WithFS(context.Background(), testFS)
+
+	result, err := WriteFile(ctx, WriteFileRequest{FileName: "dir/a.txt", Content: "new"})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	data, err := fs.ReadFile(testFS, "dir/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	// The content went to a temporary file in the same directory, which
+	// was renamed over the original
+	require.Len(t, testFS.ops, 2)
+	tmpName, ok := strings.CutPrefix(testFS.ops[0], "write ")
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(tmpName, "dir/.a.txt.tmp-"), tmpName)
+	assert.Equal(t, "rename "+tmpName+" dir/a.txt", testFS.ops[1])
+	entries, err := fs.ReadDir(testFS, "dir")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteFileModes(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	require.NoError(t, testFS.WriteFile("exists.txt", []byte("old"), 0o644))
+	ctx := WithFS(context.Background(), testFS)
+
+	_, err := WriteFile(ctx, WriteFileRequest{FileName: "exists.txt", Content: "new", Mode: WriteModeCreate})
+	assert.ErrorContains(t, err, "already exists")
+	_, err = WriteFile(ctx, WriteFileRequest{FileName: "missing.txt", Content: "new", Mode: WriteModeReplace})
+	assert.ErrorContains(t, err, "does not exist")
+	_, err = WriteFile(ctx, WriteFileRequest{FileName: "exists.txt", Content: "new", Mode: "append"})
+	assert.ErrorContains(t, err, "unknown mode")
+
+	_, err = WriteFile(ctx, WriteFileRequest{FileName: "new.txt", Content: "new", Mode: WriteModeCreate})
+	require.NoError(t, err)
+	_, err = WriteFile(ctx, WriteFileRequest{FileName: "exists.txt", Content: "new", Mode: WriteModeReplace})
+	require.NoError(t, err)
+	data, err := fs.ReadFile(testFS, "exists.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	// Denying overwrites makes every write create-only
+	denied := WithOverwriteDenied(ctx)
+	_, err = WriteFile(denied, WriteFileRequest{FileName: "exists.txt", Content: "newer"})
+	assert.ErrorContains(t, err, "already exists")
+	_, err = WriteFile(denied, WriteFileRequest{FileName: "exists.txt", Content: "newer", Mode: WriteModeReplace})
+	assert.ErrorContains(t, err, "already exists")
+	_, err = WriteFile(denied, WriteFileRequest{FileName: "another.txt", Content: "new"})
+	require.NoError(t, err)
+}
+
+func TestWriteFilePreview(t *testing.T) {
+	t.Parallel()
+	testFS := memfs.New()
+	require.NoError(t, testFS.WriteFile("main.go", []byte("package main\n\nfunc main() {\n}\n"), 0o644))
+	ctx := WithFS(context.Background(), testFS)
+
+	req := WriteFileRequest{FileName: "main.go", Content: "package main\n\nfunc main() {\n\tprintln(1)\n}\n", Preview: true}
+	result, err := WriteFile(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, `--- a/main.go
++++ b/main.go
+@@ -1,4 +1,5 @@
+ package main
+ 
+ func main() {
++	println(1)
+ }
+`, result.Diff)
+
+	// Previews don't write, and match what approval hooks see
+	data, err := fs.ReadFile(testFS, "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n}\n", string(data))
+	diff, err := DiffWrite(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, result.Diff, diff)
+
+	// New files are diffed from /dev/null
+	diff, err = DiffWrite(ctx, WriteFileRequest{FileName: "/new.txt", Content: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hi\n\\ No newline at end of file\n", diff)
+
+	// Previews respect the mode
+	_, err = DiffWrite(ctx, WriteFileRequest{FileName: "main.go", Content: "x", Mode: WriteModeCreate})
+	assert.ErrorContains(t, err, "already exists")
+}
+
 func TestWriteFileToolWrapper(t *testing.T) {
 	t.Parallel()
 	// Create in-memory filesystem
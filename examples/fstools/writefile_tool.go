@@ -20,7 +20,7 @@ type writeFileResult struct {
 type writeFileTool struct{}
 
 func (writeFileTool) MCPJsonSchema() string {
-	return `{"name":"WriteFile","description":"Writes a file to the test filesystem","inputSchema":{"type":"object","properties":{"content":{"type":"string"},"fileName":{"type":"string"}},"required":["fileName","content"],"additionalProperties":false},"outputSchema":{"type":"object","properties":{"error":{"type":["string","null"]},"success":{"type":"boolean"}},"required":["success","error"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"}}`
+	return `{"name":"WriteFile","title":"Write file","description":"Writes a file to the test filesystem, replacing it atomically where the filesystem allows. Set preview to see the change as a diff first.","inputSchema":{"type":"object","properties":{"content":{"type":"string"},"fileName":{"type":"string"},"mode":{"type":"string","description":"\"create\" to fail if the file exists, or \"replace\" to fail if it doesn't; by default either is fine"},"preview":{"type":"boolean","description":"Return a unified diff of the change without making it"}},"required":["fileName","content"],"additionalProperties":false},"outputSchema":{"type":"object","properties":{"diff":{"type":"string","description":"The change a preview would make, as a unified diff"},"error":{"type":["string","null"]},"success":{"type":"boolean","description":"Whether the file was written, which previews never are"}},"required":["success"],"additionalProperties":false,"$schema":"http://json-schema.org/draft-07/schema#"},"annotations":{"destructiveHint":true}}`
 }
 
 func (writeFileTool) Name() string {
@@ -28,7 +28,7 @@ func (writeFileTool) Name() string {
 }
 
 func (writeFileTool) Description() string {
-	return "Writes a file to the test filesystem"
+	return "Writes a file to the test filesystem, replacing it atomically where the filesystem allows. Set preview to see the change as a diff first."
 }
 
 func (writeFileTool) Call(ctx context.Context, input string) string {
@@ -0,0 +1,130 @@
+// Package fewshot defines packs of example exchanges for few-shot
+// prompting: user messages and the assistant's answers, optionally with
+// the tool calls made along the way, showing a model how to respond. A
+// session sends them ahead of the conversation in every request, without
+// storing them, so they're never compacted away:
+//
+//	pack, err := fewshot.Load(os.DirFS("prompts"), "triage.json")
+//	if err != nil {
+//		return err
+//	}
+//	session, err := agent.NewSession(client, prompt, agent.WithExamples(fewshot.Messages(pack)...))
+//
+// To store the examples with the conversation instead, pass the messages
+// to agent.WithInitialMessages.
+package fewshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Pack is a named set of examples, such as those showing the format of a
+// task's answers.
+type Pack struct {
+	Name      string     `json:"name"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is one example: a user message, the tool calls the assistant
+// makes answering it, and its answer.
+type Exchange struct {
+	User      string     `json:"user"`
+	ToolCalls []ToolCall `json:"toolCalls,omitzero"`
+	Assistant string     `json:"assistant"`
+}
+
+// ToolCall is a tool call in an example, with the result it got. It
+// should call a tool the session has, as some providers reject calls to
+// tools the request doesn't offer.
+type ToolCall struct {
+	Name string `json:"name"`
+	// Arguments are the call's arguments, as a JSON object. If empty, the
+	// call has no arguments.
+	Arguments json.RawMessage `json:"arguments,omitzero"`
+	Result    string          `json:"result"`
+}
+
+// Load reads a pack from a JSON file in fsys, and validates it. The pack's
+// name defaults to the file's name.
+func Load(fsys fs.FS, name string) (Pack, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Pack{}, fmt.Errorf("failed to read pack: %w", err)
+	}
+	var p Pack
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Pack{}, fmt.Errorf("failed to parse pack %s: %w", name, err)
+	}
+	if p.Name == "" {
+		p.Name = name
+	}
+	if err := p.Validate(); err != nil {
+		return Pack{}, err
+	}
+	return p, nil
+}
+
+// Validate checks that every exchange has a user message and an answer,
+// and that every tool call has a name and arguments that are a JSON
+// object.
+func (p Pack) Validate() error {
+	var problems []error
+	for i, ex := range p.Exchanges {
+		if ex.User == "" {
+			problems = append(problems, fmt.Errorf("exchange %d has no user message", i+1))
+		}
+		if ex.Assistant == "" {
+			problems = append(problems, fmt.Errorf("exchange %d has no answer", i+1))
+		}
+		for j, call := range ex.ToolCalls {
+			if call.Name == "" {
+				problems = append(problems, fmt.Errorf("exchange %d: tool call %d has no name", i+1, j+1))
+			}
+			var args map[string]any
+			if len(call.Arguments) > 0 && json.Unmarshal(call.Arguments, &args) != nil {
+				problems = append(problems, fmt.Errorf("exchange %d: the arguments of tool call %d aren't a JSON object", i+1, j+1))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid pack %q: %w", p.Name, errors.Join(problems...))
+	}
+	return nil
+}
+
+// Messages returns the exchanges in packs as messages, in order: each
+// exchange's user message, then, if it calls tools, an assistant message
+// with the calls and a tool message with their results, and then the
+// answer. Tool calls get IDs unique among the packs, starting with
+// "example_".
+func Messages(packs ...Pack) []chat.Message {
+	var msgs []chat.Message
+	var calls int
+	for _, p := range packs {
+		for _, ex := range p.Exchanges {
+			msgs = append(msgs, chat.UserMessage(ex.User))
+			if len(ex.ToolCalls) > 0 {
+				callMsg := chat.Message{Role: chat.AssistantRole}
+				resultMsg := chat.Message{Role: chat.ToolRole}
+				for _, call := range ex.ToolCalls {
+					calls++
+					id := fmt.Sprintf("example_%d", calls)
+					args := call.Arguments
+					if len(args) == 0 {
+						args = json.RawMessage("{}")
+					}
+					callMsg.AddToolCall(chat.ToolCall{ID: id, Name: call.Name, Arguments: args})
+					resultMsg.AddToolResult(chat.ToolResult{ToolCallID: id, Name: call.Name, Content: call.Result})
+				}
+				msgs = append(msgs, callMsg, resultMsg)
+			}
+			msgs = append(msgs, chat.AssistantMessage(ex.Assistant))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,81 @@
+package fewshot
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	weather := Pack{Name: "weather", Exchanges: []Exchange{
+		{User: "Is it raining in Paris?", ToolCalls: []ToolCall{
+			{Name: "get_weather", Arguments: json.RawMessage(`{"city": "Paris"}`), Result: `{"conditions": "rain"}`},
+			{Name: "get_time", Result: `{"time": "09:00"}`},
+		}, Assistant: "Yes, it's raining in Paris this morning."},
+	}}
+	tone := Pack{Name: "tone", Exchanges: []Exchange{
+		{User: "Hi", Assistant: "Hello! What can I help with?"},
+		{User: "And in Oslo?", ToolCalls: []ToolCall{{Name: "get_weather", Arguments: json.RawMessage(`{"city": "Oslo"}`), Result: `{"conditions": "snow"}`}}, Assistant: "It's snowing."},
+	}}
+
+	calls := chat.Message{Role: chat.AssistantRole}
+	calls.AddToolCall(chat.ToolCall{ID: "example_1", Name: "get_weather", Arguments: json.RawMessage(`{"city": "Paris"}`)})
+	calls.AddToolCall(chat.ToolCall{ID: "example_2", Name: "get_time", Arguments: json.RawMessage(`{}`)})
+	results := chat.Message{Role: chat.ToolRole}
+	results.AddToolResult(chat.ToolResult{ToolCallID: "example_1", Name: "get_weather", Content: `{"conditions": "rain"}`})
+	results.AddToolResult(chat.ToolResult{ToolCallID: "example_2", Name: "get_time", Content: `{"time": "09:00"}`})
+	osloCall := chat.Message{Role: chat.AssistantRole}
+	osloCall.AddToolCall(chat.ToolCall{ID: "example_3", Name: "get_weather", Arguments: json.RawMessage(`{"city": "Oslo"}`)})
+	osloResult := chat.Message{Role: chat.ToolRole}
+	osloResult.AddToolResult(chat.ToolResult{ToolCallID: "example_3", Name: "get_weather", Content: `{"conditions": "snow"}`})
+
+	assert.Equal(t, []chat.Message{
+		chat.UserMessage("Is it raining in Paris?"),
+		calls,
+		results,
+		chat.AssistantMessage("Yes, it's raining in Paris this morning."),
+		chat.UserMessage("Hi"),
+		chat.AssistantMessage("Hello! What can I help with?"),
+		chat.UserMessage("And in Oslo?"),
+		osloCall,
+		osloResult,
+		chat.AssistantMessage("It's snowing."),
+	}, Messages(weather, tone))
+	assert.Empty(t, Messages())
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"greetings.json": {Data: []byte(`{"exchanges": [{"user": "Hi", "assistant": "Hello!"}]}`)},
+		"named.json":     {Data: []byte(`{"name": "tone", "exchanges": []}`)},
+		"broken.json":    {Data: []byte(`{"exchanges": [{"user": "Hi", "toolCalls": [{"arguments": [1]}]}]}`)},
+		"bad.json":       {Data: []byte(`{`)},
+	}
+
+	p, err := Load(fsys, "greetings.json")
+	require.NoError(t, err)
+	assert.Equal(t, Pack{Name: "greetings.json", Exchanges: []Exchange{{User: "Hi", Assistant: "Hello!"}}}, p)
+
+	p, err = Load(fsys, "named.json")
+	require.NoError(t, err)
+	assert.Equal(t, "tone", p.Name)
+
+	_, err = Load(fsys, "broken.json")
+	assert.EqualError(t, err, "invalid pack \"broken.json\": exchange 1 has no answer\n"+
+		"exchange 1: tool call 1 has no name\n"+
+		"exchange 1: the arguments of tool call 1 aren't a JSON object")
+
+	_, err = Load(fsys, "bad.json")
+	assert.ErrorContains(t, err, "failed to parse pack bad.json")
+	_, err = Load(fsys, "missing.json")
+	assert.ErrorContains(t, err, "failed to read pack")
+}
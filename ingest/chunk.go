@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Default chunking parameters for Document.Chunks, in bytes: chunks of
+// about a thousand tokens, each repeating the end of the one before it.
+const (
+	DefaultChunkSize    = 4000
+	DefaultChunkOverlap = 400
+)
+
+// Chunk is a piece of a Document's text.
+type Chunk struct {
+	// Source is the Source of the chunk's document.
+	Source string
+	// Index is the chunk's position among its document's chunks, from 0.
+	Index int
+	// Offset is the byte offset of Text in the document's text.
+	Offset int
+	// Text is the chunk's text.
+	Text string
+}
+
+// Chunks splits the document's text into chunks of at most size bytes, each
+// beginning with about the last overlap bytes of the one before it, so text
+// cut at a boundary appears whole in one chunk or the other. Chunks end at
+// a paragraph, line, or word break where one falls in the chunk's second
+// half. A size of 0 or less uses DefaultChunkSize, and an overlap outside
+// [0, size/2] uses DefaultChunkOverlap, capped at size/2.
+func (d *Document) Chunks(size, overlap int) []Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap > size/2 {
+		overlap = min(DefaultChunkOverlap, size/2)
+	}
+
+	text := d.Text
+	var chunks []Chunk
+	for start := 0; start < len(text); {
+		end := len(text)
+		if end-start > size {
+			end = chunkEnd(text, start, start+size)
+		}
+		chunks = append(chunks, Chunk{Source: d.Source, Index: len(chunks), Offset: start, Text: text[start:end]})
+		if end == len(text) {
+			break
+		}
+		start = max(overlapStart(text, end, end-overlap), start+1)
+		for start < end && !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	return chunks
+}
+
+// chunkEnd returns where a chunk of text starting at start and ending by
+// limit should end: after the last paragraph, line, or word break in its
+// second half, or at limit if there is none.
+func chunkEnd(text string, start, limit int) int {
+	for limit > start && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	half := start + (limit-start)/2
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(text[half:limit], sep); i >= 0 {
+			return half + i + len(sep)
+		}
+	}
+	return limit
+}
+
+// overlapStart returns where the chunk after one ending at end should
+// start: at the first word break at or after from, so the overlap doesn't
+// begin mid-word, or at from if there is none before end.
+func overlapStart(text string, end, from int) int {
+	if from >= end {
+		return end
+	}
+	if i := strings.IndexAny(text[from:end], " \n"); i >= 0 && from+i+1 < end {
+		return from + i + 1
+	}
+	return from
+}
+
+// Message returns a user message holding chunks, each in its own content
+// marked with its document and position, for sending documents in the
+// prompt. Add the user's request to it with AddText.
+func Message(chunks []Chunk) chat.Message {
+	msg := chat.Message{Role: chat.UserRole}
+	for _, c := range chunks {
+		msg.AddText(fmt.Sprintf("<document source=%q part=\"%d\">\n%s\n</document>", c.Source, c.Index+1, c.Text))
+	}
+	return msg
+}
+
+// SaveArtifacts stores each chunk as an artifact of the session, returning
+// their IDs. Sessions with WithMaxToolResultSize give the LLM a tool that
+// reads artifacts by ID, so a prompt can list the IDs rather than carry the
+// text.
+func SaveArtifacts(store persistence.Store, sessionID string, chunks []Chunk) ([]int64, error) {
+	ids := make([]int64, 0, len(chunks))
+	for _, c := range chunks {
+		id, err := store.SaveArtifact(sessionID, c.Text)
+		if err != nil {
+			return ids, fmt.Errorf("saving chunk %d of %s: %w", c.Index, c.Source, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,92 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestChunks(t *testing.T) {
+	t.Parallel()
+
+	var paragraphs []string
+	for i := range 30 {
+		paragraphs = append(paragraphs, strings.Repeat("word ", 10+i%7)+"end.")
+	}
+	doc := &Document{Source: "doc.txt", Text: strings.Join(paragraphs, "\n\n")}
+
+	chunks := doc.Chunks(200, 40)
+	require.Greater(t, len(chunks), 5)
+	for i, c := range chunks {
+		assert.Equal(t, i, c.Index)
+		assert.Equal(t, "doc.txt", c.Source)
+		assert.LessOrEqual(t, len(c.Text), 200)
+		assert.Equal(t, doc.Text[c.Offset:c.Offset+len(c.Text)], c.Text)
+		if i > 0 {
+			prev := chunks[i-1]
+			// Each chunk overlaps the one before it, starting at a word
+			assert.Less(t, c.Offset, prev.Offset+len(prev.Text))
+			assert.Greater(t, c.Offset, prev.Offset)
+			assert.Contains(t, " \n", string(doc.Text[c.Offset-1]))
+		}
+		if i < len(chunks)-1 {
+			// Chunks end at a break
+			assert.Contains(t, " \n", c.Text[len(c.Text)-1:])
+		}
+	}
+	last := chunks[len(chunks)-1]
+	assert.Equal(t, len(doc.Text), last.Offset+len(last.Text))
+
+	short := &Document{Source: "s", Text: "short"}
+	assert.Equal(t, []Chunk{{Source: "s", Text: "short"}}, short.Chunks(0, 0))
+	assert.Empty(t, (&Document{}).Chunks(10, 2))
+}
+
+func TestChunksWithoutBreaks(t *testing.T) {
+	t.Parallel()
+
+	// Multi-byte characters are never split
+	doc := &Document{Text: strings.Repeat("日本語", 100)}
+	chunks := doc.Chunks(100, 10)
+	covered := 0
+	for _, c := range chunks {
+		require.True(t, utf8.ValidString(c.Text))
+		assert.LessOrEqual(t, len(c.Text), 100)
+		assert.LessOrEqual(t, c.Offset, covered, "chunks leave no gaps")
+		covered = c.Offset + len(c.Text)
+	}
+	assert.Equal(t, len(doc.Text), covered)
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	msg := Message([]Chunk{
+		{Source: "a.txt", Index: 0, Text: "first"},
+		{Source: "a.txt", Index: 1, Text: "second"},
+	})
+	assert.Equal(t, chat.UserRole, msg.Role)
+	require.Len(t, msg.Contents, 2)
+	assert.Equal(t, "<document source=\"a.txt\" part=\"1\">\nfirst\n</document>", msg.Contents[0].Text)
+	assert.Equal(t, "<document source=\"a.txt\" part=\"2\">\nsecond\n</document>", msg.Contents[1].Text)
+}
+
+func TestSaveArtifacts(t *testing.T) {
+	t.Parallel()
+
+	store := persistence.NewMemoryStore()
+	ids, err := SaveArtifacts(store, "session", []Chunk{{Text: "one"}, {Text: "two"}})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	for i, want := range []string{"one", "two"} {
+		got, err := store.GetArtifact("session", ids[i])
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
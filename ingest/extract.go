@@ -0,0 +1,211 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// extractText returns plain text as is, dropping a byte order mark.
+func extractText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", errors.New("text is not valid UTF-8")
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
+
+// skippedElements hold no text a reader sees.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+}
+
+// blockElements start on a new line.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+// extractHTML returns the visible text of an HTML page, with a line for
+// each block, like a paragraph or list item.
+func extractHTML(data []byte) (string, error) {
+	var w lineWriter
+	z := html.NewTokenizer(bytes.NewReader(data))
+	skipping := 0
+	pre := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return "", err
+			}
+			return w.String(), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			if skippedElements[a] && tt == html.StartTagToken {
+				skipping++
+			}
+			if a == atom.Pre {
+				pre++
+			}
+			if blockElements[a] {
+				w.endLine()
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			if skippedElements[a] && skipping > 0 {
+				skipping--
+			}
+			if a == atom.Pre && pre > 0 {
+				pre--
+			}
+			if blockElements[a] {
+				w.endLine()
+			}
+		case html.TextToken:
+			if skipping == 0 {
+				if pre > 0 {
+					w.writePre(string(z.Text()))
+				} else {
+					w.write(string(z.Text()))
+				}
+			}
+		}
+	}
+}
+
+// lineWriter builds text from runs of words, collapsing whitespace within
+// lines and dropping blank lines.
+type lineWriter struct {
+	b    strings.Builder
+	line strings.Builder
+}
+
+// write adds text to the current line, collapsing its whitespace.
+func (w *lineWriter) write(text string) {
+	if text == "" {
+		return
+	}
+	if startsWithSpace(text) && w.line.Len() > 0 {
+		w.line.WriteByte(' ')
+	}
+	for i, word := range strings.Fields(text) {
+		if i > 0 {
+			w.line.WriteByte(' ')
+		}
+		w.line.WriteString(word)
+	}
+	if endsWithSpace(text) && w.line.Len() > 0 {
+		w.line.WriteByte(' ')
+	}
+}
+
+// writePre adds preformatted text, keeping its whitespace.
+func (w *lineWriter) writePre(text string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			w.endLine()
+		}
+		w.line.WriteString(line)
+	}
+}
+
+// endLine ends the current line, if it has any text.
+func (w *lineWriter) endLine() {
+	line := strings.TrimSpace(w.line.String())
+	w.line.Reset()
+	if line == "" {
+		return
+	}
+	if w.b.Len() > 0 {
+		w.b.WriteByte('\n')
+	}
+	w.b.WriteString(line)
+}
+
+func (w *lineWriter) String() string {
+	w.endLine()
+	return w.b.String()
+}
+
+func startsWithSpace(s string) bool {
+	return strings.TrimLeft(s, " \t\r\n\f") != s
+}
+
+func endsWithSpace(s string) bool {
+	return strings.TrimRight(s, " \t\r\n\f") != s
+}
+
+// extractDOCX returns the text of a Word document's body, with a line for
+// each paragraph.
+func extractDOCX(data []byte) (string, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("opening docx: %w", err)
+	}
+	f, err := r.Open("word/document.xml")
+	if err != nil {
+		return "", fmt.Errorf("opening docx body: %w", err)
+	}
+	defer f.Close()
+
+	var b, para strings.Builder
+	inText := false
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("parsing docx body: %w", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteByte('\t')
+			case "br", "cr":
+				para.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if b.Len() > 0 {
+					b.WriteByte('\n')
+				}
+				b.WriteString(para.String())
+				para.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(tok)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractHTML(t *testing.T) {
+	t.Parallel()
+
+	page := `<!DOCTYPE html>
+<html><head><title>Ignored</title><style>p { color: red }</style></head>
+<body>
+  <nav><ul><li>Home</li><li>About</li></ul></nav>
+  <p>Fish &amp; chips,
+     served   <em>hot</em>.</p>
+  <pre>x := 1
+  y := 2</pre>
+  <noscript>Enable JavaScript</noscript>
+  <p>Line one<br>Line two</p>
+</body></html>`
+	text, err := extractHTML([]byte(page))
+	require.NoError(t, err)
+	assert.Equal(t, "Home\nAbout\nFish & chips, served hot.\nx := 1\ny := 2\nLine one\nLine two", text)
+}
+
+func TestExtractDOCX(t *testing.T) {
+	t.Parallel()
+
+	body := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+<w:p><w:r><w:t>Revenue</w:t><w:tab/><w:t>up 5%</w:t></w:r></w:p>
+<w:p><w:r><w:t>A &amp; B</w:t></w:r></w:p>
+</w:body>
+</w:document>`
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	assert.Equal(t, MediaTypeDOCX, detect("", "", buf.Bytes()))
+	text, err := extractDOCX(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "Quarterly report\nRevenue\tup 5%\nA & B", text)
+
+	_, err = extractDOCX([]byte("not a zip"))
+	assert.Error(t, err)
+}
+
+// testPDF returns a minimal PDF with a compressed page content stream, an
+// uncompressed one, and an image stream.
+func testPDF(t *testing.T) []byte {
+	t.Helper()
+
+	page1 := `BT /F1 12 Tf 72 720 Td (Hello, \(PDF\) world!) Tj 0 -14 Td [(Second) -250 (line)] TJ ET`
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write([]byte(page1))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	page2 := `BT 72 720 Td <4361666E> Tj T* (caf\351) Tj ET`
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	fmt.Fprintf(&b, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	b.Write(compressed.Bytes())
+	b.WriteString("\nendstream\nendobj\n")
+	b.WriteString("5 0 obj\n<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /Length 3 >>\nstream\n(x) Tj\nendstream\nendobj\n")
+	fmt.Fprintf(&b, "6 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(page2), page2)
+	b.WriteString("trailer\n<< /Root 2 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	t.Parallel()
+
+	text, err := extractPDF(testPDF(t))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, (PDF) world!\nSecond line\n\nCafn\ncafé", text)
+
+	_, err = extractPDF([]byte("%PDF-1.4\n%%EOF\n"))
+	assert.ErrorContains(t, err, "no text found")
+	_, err = extractPDF([]byte("hello"))
+	assert.Error(t, err)
+}
+
+func TestExtractText(t *testing.T) {
+	t.Parallel()
+
+	text, err := extractText([]byte("\ufeffhello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", text)
+
+	_, err = extractText([]byte{0xff, 0xfe, 0x00})
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package ingest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/vecmath"
+)
+
+// indexBatchSize is how many chunks Index embeds per request.
+const indexBatchSize = 64
+
+// VectorStore stores embedded chunks for retrieval by similarity.
+type VectorStore interface {
+	// Add stores chunks with their embeddings; embeddings[i] is the
+	// embedding of chunks[i].
+	Add(ctx context.Context, chunks []Chunk, embeddings [][]float64) error
+}
+
+// Index embeds chunks with embedder and adds them to store, in batches.
+func Index(ctx context.Context, store VectorStore, embedder chat.Embedder, chunks []Chunk) error {
+	for batch := range slices.Chunk(chunks, indexBatchSize) {
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Text
+		}
+		embeddings, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding chunks: %w", err)
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("embedder returned %d embeddings for %d chunks", len(embeddings), len(batch))
+		}
+		if err := store.Add(ctx, batch, embeddings); err != nil {
+			return fmt.Errorf("adding chunks to vector store: %w", err)
+		}
+	}
+	return nil
+}
+
+// MemoryVectorStore is a VectorStore held in memory, searched exhaustively.
+// It suits the documents of a single session or small corpora; larger ones
+// call for a dedicated vector database behind the VectorStore interface.
+type MemoryVectorStore struct {
+	mu      sync.Mutex
+	entries []vectorEntry
+}
+
+type vectorEntry struct {
+	chunk     Chunk
+	embedding []float64
+}
+
+// ScoredChunk is a chunk found by a search, with its cosine similarity to
+// the query, from -1 to 1.
+type ScoredChunk struct {
+	Chunk
+	Score float64
+}
+
+// NewMemoryVectorStore returns an empty MemoryVectorStore.
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{}
+}
+
+// Add implements VectorStore.
+func (s *MemoryVectorStore) Add(ctx context.Context, chunks []Chunk, embeddings [][]float64) error {
+	if len(chunks) != len(embeddings) {
+		return fmt.Errorf("%d embeddings for %d chunks", len(embeddings), len(chunks))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range chunks {
+		s.entries = append(s.entries, vectorEntry{chunk: c, embedding: embeddings[i]})
+	}
+	return nil
+}
+
+// Search returns the k chunks most similar to query, embedded with
+// embedder, most similar first.
+func (s *MemoryVectorStore) Search(ctx context.Context, embedder chat.Embedder, query string, k int) ([]ScoredChunk, error) {
+	embeddings, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	if len(embeddings) != 1 {
+		return nil, errors.New("embedder returned no embedding for the query")
+	}
+	return s.Nearest(embeddings[0], k), nil
+}
+
+// Nearest returns the k chunks whose embeddings are most similar to
+// embedding, most similar first.
+func (s *MemoryVectorStore) Nearest(embedding []float64, k int) []ScoredChunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scored := make([]ScoredChunk, len(s.entries))
+	for i, e := range s.entries {
+		scored[i] = ScoredChunk{Chunk: e.chunk, Score: vecmath.CosineSimilarity(embedding, e.embedding)}
+	}
+	slices.SortStableFunc(scored, func(a, b ScoredChunk) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return scored[:min(max(k, 0), len(scored))]
+}
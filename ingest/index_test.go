@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// keywordEmbedder embeds texts by which of a few keywords they mention.
+func keywordEmbedder(calls *int) chat.EmbedderFunc {
+	keywords := []string{"cat", "dog", "fish"}
+	return func(ctx context.Context, texts []string) ([][]float64, error) {
+		*calls++
+		embeddings := make([][]float64, len(texts))
+		for i, text := range texts {
+			embeddings[i] = make([]float64, len(keywords))
+			for j, kw := range keywords {
+				if strings.Contains(text, kw) {
+					embeddings[i][j] = 1
+				}
+			}
+		}
+		return embeddings, nil
+	}
+}
+
+func TestIndexAndSearch(t *testing.T) {
+	t.Parallel()
+
+	var chunks []Chunk
+	for i := range 100 {
+		text := "a dog"
+		if i == 42 {
+			text = "a cat"
+		}
+		chunks = append(chunks, Chunk{Source: "pets.txt", Index: i, Text: text})
+	}
+
+	var calls int
+	embedder := keywordEmbedder(&calls)
+	store := NewMemoryVectorStore()
+	require.NoError(t, Index(context.Background(), store, embedder, chunks))
+	assert.Equal(t, 2, calls, "chunks are embedded in batches")
+
+	results, err := store.Search(context.Background(), embedder, "my cat", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 42, results[0].Index)
+	assert.InDelta(t, 1, results[0].Score, 1e-9)
+	assert.InDelta(t, 0, results[1].Score, 1e-9)
+
+	assert.Len(t, store.Nearest([]float64{0, 1, 0}, 1000), 100)
+	assert.Empty(t, store.Nearest([]float64{0, 1, 0}, -1))
+}
+
+func TestIndexErrors(t *testing.T) {
+	t.Parallel()
+
+	failing := chat.EmbedderFunc(func(ctx context.Context, texts []string) ([][]float64, error) {
+		return nil, errors.New("quota exceeded")
+	})
+	err := Index(context.Background(), NewMemoryVectorStore(), failing, []Chunk{{Text: "x"}})
+	assert.ErrorContains(t, err, "quota exceeded")
+
+	short := chat.EmbedderFunc(func(ctx context.Context, texts []string) ([][]float64, error) {
+		return [][]float64{{1}}, nil
+	})
+	err = Index(context.Background(), NewMemoryVectorStore(), short, []Chunk{{Text: "x"}, {Text: "y"}})
+	assert.ErrorContains(t, err, "1 embeddings for 2 chunks")
+}
@@ -0,0 +1,232 @@
+// Package ingest turns files and web pages a user hands an agent into text
+// the LLM can use: Load fetches a local file or URL, detects its type, and
+// extracts its text (from PDF, HTML, DOCX, source code and other plain
+// text), and Document.Chunks splits the text into overlapping chunks. The
+// chunks can then be sent in the prompt with Message, stored as session
+// artifacts with SaveArtifacts, or embedded into a VectorStore with Index:
+//
+//	doc, err := ingest.Load(ctx, "report.pdf")
+//	if err != nil {
+//		return err
+//	}
+//	chunks := doc.Chunks(ingest.DefaultChunkSize, ingest.DefaultChunkOverlap)
+//	msg := ingest.Message(chunks)
+//	msg.AddText("What are the report's main findings?")
+//	resp, err := session.Message(ctx, msg)
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Media types Load detects and extracts text from by default. Other text
+// types, like source code, are detected as MediaTypeText.
+const (
+	MediaTypePDF  = "application/pdf"
+	MediaTypeHTML = "text/html"
+	MediaTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MediaTypeText = "text/plain"
+)
+
+// DefaultMaxSize is the default limit on the size of a file or response Load
+// reads.
+const DefaultMaxSize = 32 << 20
+
+// Document is the text extracted from a file or URL.
+type Document struct {
+	// Source is the path or URL the document was loaded from.
+	Source string
+	// MediaType is the document's detected media type, such as
+	// MediaTypePDF.
+	MediaType string
+	// Text is the document's extracted text.
+	Text string
+}
+
+// Extractor extracts the text of a document from its contents.
+type Extractor func(data []byte) (string, error)
+
+// Option configures Load.
+type Option func(*options)
+
+type options struct {
+	client     *http.Client
+	maxSize    int64
+	extractors map[string]Extractor
+}
+
+// WithHTTPClient sets the client URLs are fetched with, instead of
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *options) {
+		opts.client = client
+	}
+}
+
+// WithMaxSize limits the size of the file or response Load reads, in bytes,
+// instead of DefaultMaxSize. Larger documents are an error.
+func WithMaxSize(bytes int64) Option {
+	return func(opts *options) {
+		opts.maxSize = bytes
+	}
+}
+
+// WithExtractor extracts the text of documents of mediaType with extract,
+// replacing the built-in extractor for that type, if any. It can add types
+// Load doesn't support, or replace the built-in PDF extractor, which only
+// handles PDFs with simple text encodings.
+func WithExtractor(mediaType string, extract Extractor) Option {
+	return func(opts *options) {
+		if opts.extractors == nil {
+			opts.extractors = make(map[string]Extractor)
+		}
+		opts.extractors[mediaType] = extract
+	}
+}
+
+// builtinExtractors are the extractors Load uses unless WithExtractor
+// replaces them.
+var builtinExtractors = map[string]Extractor{
+	MediaTypePDF:  extractPDF,
+	MediaTypeHTML: extractHTML,
+	MediaTypeDOCX: extractDOCX,
+	MediaTypeText: extractText,
+}
+
+// Load reads the document at source, a local path or an http or https URL,
+// and extracts its text. The document's type is detected from its file
+// extension, the Content-Type a server sent it with, or its contents, in
+// that order.
+func Load(ctx context.Context, source string, opts ...Option) (*Document, error) {
+	o := options{client: http.DefaultClient, maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var data []byte
+	var contentType string
+	var err error
+	name := source
+	if u, parseErr := url.Parse(source); parseErr == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		data, contentType, err = fetch(ctx, o, source)
+		name = u.Path
+	} else {
+		data, err = readFile(source, o.maxSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType := detect(name, contentType, data)
+	extract := o.extractors[mediaType]
+	if extract == nil {
+		extract = builtinExtractors[mediaType]
+	}
+	if extract == nil {
+		return nil, fmt.Errorf("%s: unsupported document type %s", source, mediaType)
+	}
+	text, err := extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: extracting text: %w", source, err)
+	}
+	return &Document{Source: source, MediaType: mediaType, Text: text}, nil
+}
+
+func readFile(name string, maxSize int64) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, name, maxSize)
+}
+
+func fetch(ctx context.Context, o options, source string) (data []byte, contentType string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s: %s", source, resp.Status)
+	}
+	data, err = readLimited(resp.Body, source, o.maxSize)
+	return data, resp.Header.Get("Content-Type"), err
+}
+
+// readLimited reads r, failing if it holds more than maxSize bytes.
+func readLimited(r io.Reader, source string, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", source, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", source, maxSize)
+	}
+	return data, nil
+}
+
+// extensionTypes maps the file extensions of documents, and of text files
+// http.DetectContentType can't be trusted to recognize, to media types.
+var extensionTypes = map[string]string{
+	".pdf":  MediaTypePDF,
+	".html": MediaTypeHTML,
+	".htm":  MediaTypeHTML,
+	".docx": MediaTypeDOCX,
+}
+
+// detect returns the media type of the document named name, with the given
+// Content-Type header (if fetched) and contents.
+func detect(name, contentType string, data []byte) string {
+	if mediaType, ok := extensionTypes[strings.ToLower(path.Ext(filepath.ToSlash(name)))]; ok {
+		return mediaType
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" {
+		return normalizeType(mediaType)
+	}
+
+	switch mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data)); {
+	case mediaType == "application/zip" && bytes.Contains(data, []byte("word/document.xml")):
+		return MediaTypeDOCX
+	case mediaType == "application/octet-stream" && utf8.Valid(data):
+		// Text that doesn't start like a known format
+		return MediaTypeText
+	default:
+		return normalizeType(mediaType)
+	}
+}
+
+// normalizeType maps media types of plain text, such as source code, JSON,
+// or Markdown, to MediaTypeText.
+func normalizeType(mediaType string) string {
+	switch {
+	case mediaType == "application/xhtml+xml":
+		return MediaTypeHTML
+	case mediaType == MediaTypeHTML:
+		return mediaType
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/javascript",
+		mediaType == "application/x-sh",
+		mediaType == "application/yaml",
+		mediaType == "application/toml":
+		return MediaTypeText
+	}
+	return mediaType
+}
@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0o644))
+	htmlFile := filepath.Join(dir, "page.HTML")
+	require.NoError(t, os.WriteFile(htmlFile, []byte("<p>Hello <b>there</b></p><script>x()</script>"), 0o644))
+
+	doc, err := Load(context.Background(), goFile)
+	require.NoError(t, err)
+	assert.Equal(t, &Document{Source: goFile, MediaType: MediaTypeText, Text: "package main\n\nfunc main() {}\n"}, doc)
+
+	doc, err = Load(context.Background(), htmlFile)
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeHTML, doc.MediaType)
+	assert.Equal(t, "Hello there", doc.Text)
+
+	_, err = Load(context.Background(), goFile, WithMaxSize(10))
+	assert.ErrorContains(t, err, "larger than 10 bytes")
+
+	_, err = Load(context.Background(), filepath.Join(dir, "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadURL(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><head><title>T</title></head><body><h1>Title</h1><p>Body text.</p></body></html>"))
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"a":1}`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	doc, err := Load(context.Background(), server.URL+"/article", WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeHTML, doc.MediaType)
+	assert.Equal(t, "Title\nBody text.", doc.Text)
+
+	doc, err = Load(context.Background(), server.URL+"/data")
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeText, doc.MediaType)
+	assert.Equal(t, `{"a":1}`, doc.Text)
+
+	_, err = Load(context.Background(), server.URL+"/image")
+	assert.ErrorContains(t, err, "unsupported document type image/png")
+
+	doc, err = Load(context.Background(), server.URL+"/image", WithExtractor("image/png", func(data []byte) (string, error) {
+		return "a picture", nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "a picture", doc.Text)
+
+	_, err = Load(context.Background(), server.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, contentType string
+		data              string
+		want              string
+	}{
+		{"report.pdf", "", "%PDF-1.4", MediaTypePDF},
+		{"notes.md", "", "# Notes", MediaTypeText},
+		{"script.py", "", "import os\n", MediaTypeText},
+		{"", "", "%PDF-1.7\n", MediaTypePDF},
+		{"", "", "<!DOCTYPE html><html></html>", MediaTypeHTML},
+		{"", "", "PK\x03\x04....word/document.xml", MediaTypeDOCX},
+		{"/download", "application/pdf", "%PDF-1.4", MediaTypePDF},
+		{"/download", "application/octet-stream", "plain words", MediaTypeText},
+		{"", "", "\x00\x01\x02\xff", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, detect(tt.name, tt.contentType, []byte(tt.data)), "%s %q", tt.name, tt.data)
+	}
+}
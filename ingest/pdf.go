@@ -0,0 +1,325 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPDFStreamSize limits how large a decompressed content stream may grow,
+// guarding against decompression bombs.
+const maxPDFStreamSize = 64 << 20
+
+// pdfStreamStart matches the end of a stream object's dictionary and the
+// start of its data.
+var pdfStreamStart = regexp.MustCompile(`>>\s*stream\r?\n`)
+
+// extractPDF returns the text shown by a PDF's page content streams. It
+// handles uncompressed and Flate-compressed streams whose fonts use a
+// single-byte encoding close to Latin-1, which covers many PDFs produced by
+// office software and report generators, but not ones that encode text
+// with embedded font mappings. Use WithExtractor with a full PDF library
+// for those.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+
+	var pages []string
+	for _, m := range pdfStreamStart.FindAllIndex(data, -1) {
+		// The dictionary runs from the object's start to the match
+		objStart := max(bytes.LastIndex(data[:m[0]], []byte("obj")), 0)
+		dict := data[objStart:m[0]]
+		if isNonContentStream(dict) {
+			continue
+		}
+		start := m[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		content := data[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			var err error
+			if content, err = inflate(content); err != nil {
+				continue
+			}
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Other filters compress images, which hold no text
+			continue
+		}
+		if text := pdfContentText(content); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	if len(pages) == 0 {
+		return "", errors.New("no text found; the PDF may be scanned, or encode its text in a way the built-in extractor doesn't support")
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// isNonContentStream reports whether a stream's dictionary marks it as
+// something other than page content, like an image or embedded font.
+func isNonContentStream(dict []byte) bool {
+	for _, marker := range []string{"/Subtype/Image", "/Subtype /Image", "/Length1", "/FontFile", "/Type/XRef", "/Type /XRef", "/Type/ObjStm", "/Type /ObjStm", "/Type/Metadata", "/Type /Metadata"} {
+		if bytes.Contains(dict, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pdfContentText returns the text shown by the text operators of a content
+// stream, with a line for each line of text.
+func pdfContentText(content []byte) string {
+	var w lineWriter
+	var operands []pdfOperand
+	inText := false
+	lex := pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.text {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			w.endLine()
+		case "T*":
+			w.endLine()
+		case "Td", "TD":
+			if len(operands) == 2 && operands[1].number() != 0 {
+				w.endLine()
+			}
+		case "Tj":
+			if inText {
+				w.writeRaw(stringOperands(operands, false))
+			}
+		case "'", `"`:
+			if inText {
+				w.endLine()
+				w.writeRaw(stringOperands(operands, false))
+			}
+		case "TJ":
+			if inText {
+				w.writeRaw(stringOperands(operands, true))
+			}
+		}
+		operands = operands[:0]
+	}
+	return w.String()
+}
+
+// stringOperands joins the text of the string operands. For TJ arrays, large
+// negative adjustments between strings become spaces, as that is how many
+// PDFs space words.
+func stringOperands(operands []pdfOperand, spaced bool) string {
+	var b strings.Builder
+	for _, op := range operands {
+		switch op.kind {
+		case pdfString:
+			b.WriteString(op.text)
+		case pdfNumber:
+			if spaced && op.number() < -200 {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeRaw adds text to the current line as is.
+func (w *lineWriter) writeRaw(text string) {
+	w.line.WriteString(text)
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfOther
+)
+
+type pdfOperand struct {
+	kind pdfTokenKind
+	text string
+}
+
+func (op pdfOperand) number() float64 {
+	n, _ := strconv.ParseFloat(op.text, 64)
+	return n
+}
+
+// pdfLexer splits a content stream into tokens. Array brackets are
+// skipped, so a TJ array's elements are its operator's operands.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfOperand, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c) || c == '[' || c == ']':
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfOperand{kind: pdfString, text: l.literalString()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return pdfOperand{kind: pdfOther, text: "<<"}, true
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfOperand{kind: pdfOther, text: ">>"}, true
+		case c == '<':
+			return pdfOperand{kind: pdfString, text: l.hexString()}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.word()
+			return pdfOperand{kind: pdfOther, text: string(l.data[start:l.pos])}, true
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			start := l.pos
+			l.word()
+			return pdfOperand{kind: pdfNumber, text: string(l.data[start:l.pos])}, true
+		default:
+			start := l.pos
+			l.word()
+			if l.pos == start {
+				// A stray delimiter
+				l.pos++
+				continue
+			}
+			return pdfOperand{kind: pdfOperator, text: string(l.data[start:l.pos])}, true
+		}
+	}
+	return pdfOperand{}, false
+}
+
+// word advances past a run of regular characters.
+func (l *pdfLexer) word() {
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+// literalString reads a (string) with balanced parentheses and escapes,
+// decoding its bytes as Latin-1.
+func (l *pdfLexer) literalString() string {
+	var b strings.Builder
+	depth := 0
+	l.pos++ // (
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return b.String()
+			}
+			depth--
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b.String()
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A line continuation
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
+
+// hexString reads a <hex string>, decoding its bytes as Latin-1.
+func (l *pdfLexer) hexString() string {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var b strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		b.WriteRune(rune(n))
+	}
+	return b.String()
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -11,11 +11,17 @@
 //  2. llm.SetLogLevel() function for programmatic control
 //
 // All logging is global and affects all LLM providers in the process.
+//
+// Records logged with a context that carries a turn ID (see chat.WithTurnID)
+// include it as the turn_id attribute.
 package logging
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/bpowers/go-agent/chat"
 )
 
 var (
@@ -30,7 +36,27 @@ func init() {
 	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: logLevel,
 	})
-	logger = slog.New(handler)
+	logger = slog.New(turnHandler{handler})
+}
+
+// turnHandler adds the turn ID from a record's context to the record.
+type turnHandler struct {
+	slog.Handler
+}
+
+func (h turnHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := chat.GetTurnID(ctx); id != "" {
+		r.AddAttrs(slog.String("turn_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h turnHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return turnHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h turnHandler) WithGroup(name string) slog.Handler {
+	return turnHandler{h.Handler.WithGroup(name)}
 }
 
 // Logger returns the global logger instance.
@@ -38,6 +64,13 @@ func Logger() *slog.Logger {
 	return logger
 }
 
+// New returns a logger that writes to handler rather than the global
+// logger, adding turn IDs to records like the global logger does. Its level
+// is up to handler, independent of SetLogLevel.
+func New(handler slog.Handler) *slog.Logger {
+	return slog.New(turnHandler{handler})
+}
+
 // SetLogLevel sets the global log level for the entire go-agent library.
 // This is a process-wide setting that affects all LLM providers (OpenAI, Claude, Gemini).
 //
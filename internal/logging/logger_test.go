@@ -1,11 +1,16 @@
 package logging
 
 import (
+	"bytes"
+	"context"
 	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
 )
 
 func TestParseLogLevel(t *testing.T) {
@@ -63,3 +68,16 @@ func TestLogger(t *testing.T) {
 	logger2 := Logger()
 	assert.Equal(t, logger1, logger2)
 }
+
+func TestTurnHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(turnHandler{slog.NewTextHandler(&buf, nil)}).With("component", "test")
+
+	l.InfoContext(chat.WithTurnID(context.Background(), "turn-1"), "sending")
+	l.Info("no context")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "component=test turn_id=turn-1")
+	assert.NotContains(t, lines[1], "turn_id")
+}
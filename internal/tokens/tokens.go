@@ -0,0 +1,12 @@
+// Package tokens provides the rough token estimates used where a provider's
+// tokenizer isn't available, such as for compaction and progress events.
+package tokens
+
+// CharsPerToken approximates how many characters of English text or code
+// make up one token across the tokenizers of the supported providers.
+const CharsPerToken = 4
+
+// FromChars estimates the number of tokens in n characters, rounding up.
+func FromChars(n int) int {
+	return (n + CharsPerToken - 1) / CharsPerToken
+}
@@ -0,0 +1,16 @@
+package tokens
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromChars(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, FromChars(0))
+	assert.Equal(t, 1, FromChars(1))
+	assert.Equal(t, 1, FromChars(CharsPerToken))
+	assert.Equal(t, 2, FromChars(CharsPerToken+1))
+}
@@ -0,0 +1,23 @@
+// Package vecmath provides the vector math shared by the packages that
+// compare embeddings.
+package vecmath
+
+import "math"
+
+// CosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// they differ in length or either is zero.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,16 @@
+package vecmath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	t.Parallel()
+
+	assert.InDelta(t, 1, CosineSimilarity([]float64{1, 2}, []float64{2, 4}), 1e-9)
+	assert.InDelta(t, 0, CosineSimilarity([]float64{1, 0}, []float64{0, 1}), 1e-9)
+	assert.Zero(t, CosineSimilarity([]float64{1, 0}, []float64{1, 0, 0}))
+	assert.Zero(t, CosineSimilarity([]float64{0, 0}, []float64{1, 0}))
+}
@@ -2,10 +2,13 @@ package claude
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -21,22 +24,34 @@ import (
 //   - Debug: Stream events, tool calls, token updates, raw API data
 //   - Warn: Missing token usage, unknown models, fallback behavior
 //   - Error: Should never occur (indicates bugs)
-var logger = logging.Logger().With("provider", "claude")
+var logger = logging.Logger().With("provider", providerName)
 
 const (
 	AnthropicURL = "https://api.anthropic.com/v1"
 )
 
+// providerName identifies this provider in logs and in chat.ThinkingContent.
+const providerName = "claude"
+
 type client struct {
 	anthropicClient anthropic.Client
 	modelName       string
 	baseURL         string            // Store base URL for testing
 	headers         map[string]string // Custom HTTP headers
+	betas           []string          // Beta features sent in the anthropic-beta header
+	repairToolArgs  bool              // Repair malformed tool call arguments
+	rawEventTap     common.RawEventTap
 	logger          *slog.Logger
 }
 
 var _ chat.Client = &client{}
 
+func init() {
+	chat.RegisterMessageExporter("anthropic", func(msgs []chat.Message) (json.RawMessage, error) {
+		return common.ExportMessages(msgs, historyParam)
+	})
+}
+
 type Option func(*client)
 
 func WithModel(modelName string) Option {
@@ -51,6 +66,47 @@ func WithHeaders(headers map[string]string) Option {
 	}
 }
 
+// WithFineGrainedToolStreaming enables Claude's fine-grained tool streaming
+// and interleaved thinking betas. Tool call arguments are then streamed
+// token-by-token as chat.StreamEventTypeToolCallDelta events rather than
+// arriving in large buffered chunks, and the model can think between tool
+// calls.
+func WithFineGrainedToolStreaming() Option {
+	return func(c *client) {
+		c.betas = append(c.betas, "fine-grained-tool-streaming-2025-05-14", "interleaved-thinking-2025-05-14")
+	}
+}
+
+// WithToolArgumentRepair enables a lenient repair pass over tool call
+// arguments that aren't valid JSON, fixing trailing commas and unescaped
+// newlines before the arguments are passed to the tool. How often arguments
+// were malformed is reported by llm.ToolArgumentMetrics either way.
+func WithToolArgumentRepair() Option {
+	return func(c *client) {
+		c.repairToolArgs = true
+	}
+}
+
+// WithLogger sends the client's logs to handler instead of the library's
+// global logger, so an application can route them into its own structured
+// logging, at a level independent of llm.SetLogLevel.
+func WithLogger(handler slog.Handler) Option {
+	return func(c *client) {
+		c.logger = logging.New(handler).With("provider", providerName)
+	}
+}
+
+// WithRawEventTap passes every raw server-sent event the client streams to
+// tap, with the provider's name and the event's type and data, so
+// applications can record or inspect the provider's behavior without
+// enabling debug logging. tap is called as each event is read, from the
+// goroutine streaming the response, and may keep the payload.
+func WithRawEventTap(tap func(provider, eventType string, payload []byte)) Option {
+	return func(c *client) {
+		c.rawEventTap = tap
+	}
+}
+
 // NewClient returns a chat client that can begin chat sessions with Claude's Messages API.
 func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, error) {
 	c := &client{
@@ -78,6 +134,10 @@ func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, erro
 	// Build Anthropic client options
 	clientOpts := []option.RequestOption{
 		option.WithAPIKey(apiKey),
+		option.WithMiddleware(common.RequestMiddleware),
+	}
+	if c.rawEventTap != nil {
+		clientOpts = append(clientOpts, option.WithMiddleware(common.RawEventMiddleware(providerName, c.rawEventTap)))
 	}
 
 	if apiBase != "" && apiBase != AnthropicURL {
@@ -89,6 +149,10 @@ func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, erro
 		clientOpts = append(clientOpts, option.WithHeader(key, value))
 	}
 
+	if len(c.betas) > 0 {
+		clientOpts = append(clientOpts, option.WithHeaderAdd("anthropic-beta", strings.Join(c.betas, ",")))
+	}
+
 	c.anthropicClient = anthropic.NewClient(clientOpts...)
 
 	return c, nil
@@ -106,16 +170,28 @@ func (c *client) Headers() map[string]string {
 	return c.headers
 }
 
+var _ chat.Pinger = &client{}
+
+// Ping implements chat.Pinger by looking up the client's model, which
+// checks the API key and model without generating anything.
+func (c *client) Ping(ctx context.Context) (chat.HealthStatus, error) {
+	return common.Ping(ctx, providerName, c.modelName, func(ctx context.Context) error {
+		_, err := c.anthropicClient.Models.Get(ctx, c.modelName, anthropic.ModelGetParams{}, option.WithMaxRetries(0))
+		return err
+	})
+}
+
 // NewChat returns a chat instance.
 func (c client) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
 	// Determine max tokens based on model
 	maxTokens := getModelMaxTokens(c.modelName)
 
 	return &chatClient{
-		client:    c,
-		state:     common.NewState(systemPrompt, initialMsgs),
-		tools:     common.NewTools(),
-		maxTokens: maxTokens,
+		client:       c,
+		state:        common.NewState(systemPrompt, initialMsgs),
+		tools:        common.NewTools(),
+		maxTokens:    maxTokens,
+		contextLimit: getModelContextLimit(c.modelName),
 	}
 }
 
@@ -132,7 +208,7 @@ var modelMaxOutputTokens = map[string]int64{
 	"claude-3-haiku":    4096,
 }
 
-func getMaxOutputTokens(modelName string) int64 {
+func getMaxOutputTokens(logger *slog.Logger, modelName string) int64 {
 	t, ok := modelMaxOutputTokens[modelName]
 	if !ok {
 		logger.Warn("model not found in model library, using default", "model", modelName, "default", 4096)
@@ -199,71 +275,84 @@ func supportsThinking(modelName string) bool {
 	})
 }
 
-var modelLimits = []chat.ModelTokenLimits{
-	{Model: "claude-opus-4-6", TokenLimits: chat.TokenLimits{Context: 200000, Output: 128000}},
-	{Model: "claude-opus-4-5", TokenLimits: chat.TokenLimits{Context: 200000, Output: 64000}},
-	{Model: "claude-opus-4-1", TokenLimits: chat.TokenLimits{Context: 200000, Output: 32000}},
-	{Model: "claude-opus-4", TokenLimits: chat.TokenLimits{Context: 200000, Output: 32000}},
-	{Model: "claude-sonnet-4-5", TokenLimits: chat.TokenLimits{Context: 200000, Output: 64000}},
-	{Model: "claude-sonnet-4", TokenLimits: chat.TokenLimits{Context: 200000, Output: 64000}},
-	{Model: "claude-haiku-4-5", TokenLimits: chat.TokenLimits{Context: 200000, Output: 64000}},
-	{Model: "claude-3-7-sonnet", TokenLimits: chat.TokenLimits{Context: 200000, Output: 64000}},
-	{Model: "claude-3-5-haiku", TokenLimits: chat.TokenLimits{Context: 200000, Output: 8192}},
-	{Model: "claude-3-haiku", TokenLimits: chat.TokenLimits{Context: 200000, Output: 4096}},
-}
-
 // getModelMaxTokens returns the maximum token limit for known models
 func getModelMaxTokens(model string) int {
-	modelLower := strings.ToLower(model)
-
-	for _, m := range modelLimits {
-		if strings.HasPrefix(modelLower, m.Model) {
-			return m.TokenLimits.Output
-		}
+	if m, ok := chat.LookupModel(model); ok {
+		return m.TokenLimits.Output
 	}
 
 	panic(fmt.Errorf("unknown model %q", model))
 }
 
-// getSystemReminderText retrieves and executes system reminder function if present
-func getSystemReminderText(ctx context.Context) string {
-	if reminderFunc := chat.GetSystemReminder(ctx); reminderFunc != nil {
-		return reminderFunc()
-	}
-	return ""
+// getModelContextLimit returns the context window size for known models, or 0
+func getModelContextLimit(model string) int {
+	m, _ := chat.LookupModel(model)
+	return m.TokenLimits.Context
 }
 
 type chatClient struct {
 	client
-	state     *common.State
-	tools     *common.Tools
-	maxTokens int
+	state        *common.State
+	history      common.ConversionCache[anthropic.MessageParam]
+	tools        *common.Tools
+	maxTokens    int
+	contextLimit int
 }
 
 func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
-	// Apply options to get callback if provided
-	reqMsg := msg
 	reqOpts := chat.ApplyOptions(opts...)
+	ctx, finishDryRun := common.StartDryRun(ctx, reqOpts)
+	endTurn, err := c.state.BeginTurn(ctx, reqOpts.QueueTimeout)
+	if err != nil {
+		return chat.Message{}, finishDryRun(err)
+	}
+	defer endTurn()
+	ctx = c.state.TrackRequests(ctx)
+	common.StreamTurnID(ctx, &reqOpts)
+	stopProgress := common.StreamProgress(&reqOpts)
+	defer stopProgress()
+
+	if reqOpts.Candidates > 1 {
+		c.logger.WarnContext(ctx, "multiple candidates not supported, generating one response", "candidates", reqOpts.Candidates)
+	}
+
+	resp, err := common.SendValidated(ctx, reqOpts, msg, func(ctx context.Context, msg chat.Message) (chat.Message, error) {
+		return common.SendTextTools(ctx, c.state, c.tools, reqOpts, msg, func(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+			return common.SendResumable(ctx, reqOpts, func(ctx context.Context, reqOpts chat.Options) (chat.Message, error) {
+				return c.message(ctx, msg, reqOpts)
+			})
+		})
+	})
+	if err == nil {
+		resp.Model = c.state.ResponseModel()
+	}
+	return resp, finishDryRun(err)
+}
+
+// message sends msg and handles any tool calls in the response. The caller
+// must hold the chat's turn.
+func (c *chatClient) message(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+	reqMsg := msg
 	callback := reqOpts.StreamingCb
 
 	// Build message list for Claude
 	var msgs []anthropic.MessageParam
 
+	// Claude prefills the assistant prefix natively rather than following
+	// an instruction to start with it
+	prefix := strings.TrimRightFunc(reqOpts.AssistantPrefix, unicode.IsSpace)
+	snapshotOpts := reqOpts
+	snapshotOpts.AssistantPrefix = ""
+
 	// Snapshot history with minimal lock
-	systemPrompt, history := c.state.Snapshot()
+	systemPrompt, history := c.state.RequestSnapshot(snapshotOpts)
 
 	// Add history using the proper conversion function
-	for _, m := range history {
-		// Skip system messages as Claude handles them separately
-		if m.Role == "system" {
-			continue
-		}
-		param, err := messageParam(m)
-		if err != nil {
-			return chat.Message{}, fmt.Errorf("converting history message to param: %w", err)
-		}
-		msgs = append(msgs, param)
+	historyParams, err := c.history.Convert(history, historyParam)
+	if err != nil {
+		return chat.Message{}, fmt.Errorf("converting history message to param: %w", err)
 	}
+	msgs = append(msgs, historyParams...)
 
 	// Add current message using the proper conversion function
 	currentParam, err := messageParam(msg)
@@ -271,16 +360,19 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		return chat.Message{}, fmt.Errorf("converting current message to param: %w", err)
 	}
 	msgs = append(msgs, currentParam)
+	if prefix != "" {
+		msgs = append(msgs, anthropic.NewAssistantMessage(anthropic.NewTextBlock(prefix)))
+	}
 
 	// Build request parameters
 	params := anthropic.MessageNewParams{
 		Messages:  msgs,
 		Model:     anthropic.Model(c.modelName),
-		MaxTokens: getMaxOutputTokens(c.modelName), // Claude requires this
+		MaxTokens: getMaxOutputTokens(c.logger, c.modelName), // Claude requires this
 	}
 
 	// Add tools if registered
-	allTools := c.tools.GetAll()
+	allTools := c.tools.ForRequest(reqOpts)
 	if len(allTools) > 0 {
 		tools := make([]anthropic.ToolUnionParam, 0, len(allTools))
 		for _, tool := range allTools {
@@ -311,6 +403,10 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		params.MaxTokens = int64(reqOpts.MaxTokens)
 	}
 
+	if reqOpts.User != "" {
+		params.Metadata.UserID = anthropic.String(reqOpts.User)
+	}
+
 	// Handle response format if provided
 	// Claude doesn't have a direct equivalent to OpenAI's response_format
 	// but we can append instructions to the system prompt
@@ -328,23 +424,37 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		}
 	}
 
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+		return chat.Message{}, err
+	}
+	if prefix != "" && callback != nil {
+		if err := callback(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: prefix}); err != nil {
+			return chat.Message{}, err
+		}
+	}
+
 	// Streaming implementation
 	stream := c.anthropicClient.Messages.NewStreaming(ctx, params)
 
+	// The response continues the prefix, so it starts the returned text
 	var respContent strings.Builder
+	respContent.WriteString(prefix)
 	var inThinking bool
 	var thinkingContent strings.Builder
 	var thinkingSignature strings.Builder
-	var toolCalls []anthropic.ToolUseBlock
-	var currentToolCall *anthropic.ToolUseBlock
-	var toolCallArgs strings.Builder
+	var redactedThinking []string
+	var toolCalls []common.StreamedToolCall
+	var toolCallAcc common.ToolCallAccumulator
+	var toolCallStartInput string
 
 	for stream.Next() {
 		event := stream.Current()
-		c.logger.Debug("stream event", "type", event.Type)
+		c.logger.DebugContext(ctx, "stream event", "type", event.Type)
 		// Handle different event types
 		switch event.Type {
 		case "message_start":
+			common.SetResponseID(ctx, event.Message.ID)
+			common.SetResponseModel(ctx, string(event.Message.Model))
 			// Check if this is a model that supports thinking
 			if supportsThinking(c.modelName) && callback != nil {
 				// Emit initial thinking event for models that support it
@@ -372,25 +482,30 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 				}
 			} else if event.ContentBlock.Type == "tool_use" {
 				// Start of a tool use block
-				currentToolCall = &anthropic.ToolUseBlock{
-					ID:   event.ContentBlock.ID,
-					Name: event.ContentBlock.Name,
+				if _, _, err := toolCallAcc.Add(common.ToolCallFragment{
+					Index: int(event.Index),
+					ID:    event.ContentBlock.ID,
+					Name:  event.ContentBlock.Name,
+				}); err != nil {
+					return chat.Message{}, err
 				}
-				toolCallArgs.Reset()
-				c.logger.Debug("tool use start", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
+				toolCallStartInput = ""
+				c.logger.DebugContext(ctx, "tool use start", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
 
 				// Don't emit tool call event yet - wait for arguments to be accumulated
 				if event.ContentBlock.Input != nil {
-					// Input is sometimes provided in the start event
+					// Input is sometimes provided in the start event, and is
+					// used if no deltas follow
 					inputBytes, err := json.Marshal(event.ContentBlock.Input)
 					if err == nil {
-						currentToolCall.Input = json.RawMessage(inputBytes)
-						c.logger.Debug("set tool input from start event", "input", string(inputBytes))
+						toolCallStartInput = string(inputBytes)
+						c.logger.DebugContext(ctx, "set tool input from start event", "input", string(inputBytes))
 					}
 				}
 			} else if event.ContentBlock.Type == "redacted_thinking" {
 				// Redacted thinking block (safety-flagged)
-				c.logger.Debug("redacted thinking block detected", "data", event.ContentBlock.Data)
+				c.logger.DebugContext(ctx, "redacted thinking block detected", "data", event.ContentBlock.Data)
+				redactedThinking = append(redactedThinking, event.ContentBlock.Data)
 				if callback != nil {
 					redactedEvent := chat.StreamEvent{
 						Type: chat.StreamEventTypeRedactedThinking,
@@ -404,7 +519,7 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 				}
 			} else if event.ContentBlock.Type == "server_tool_use" {
 				// Server-side tool invocation (e.g., web search)
-				c.logger.Debug("server tool use", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
+				c.logger.DebugContext(ctx, "server tool use", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
 				if callback != nil {
 					serverToolEvent := chat.StreamEvent{
 						Type: chat.StreamEventTypeServerToolUse,
@@ -422,7 +537,7 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 				}
 			} else if event.ContentBlock.Type == "web_search_tool_result" {
 				// Web search results from server-side search
-				c.logger.Debug("web search result", "tool_use_id", event.ContentBlock.ToolUseID, "content", event.ContentBlock.Content)
+				c.logger.DebugContext(ctx, "web search result", "tool_use_id", event.ContentBlock.ToolUseID, "content", event.ContentBlock.Content)
 				if callback != nil {
 					webSearchEvent := chat.StreamEvent{
 						Type: chat.StreamEventTypeWebSearchResult,
@@ -500,17 +615,26 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 				// Thinking block signature
 				signature := event.Delta.Signature
 				thinkingSignature.WriteString(signature)
-				c.logger.Debug("signature_delta", "signature", signature)
+				c.logger.DebugContext(ctx, "signature_delta", "signature", signature)
 			case "citations_delta":
 				// Citation updates
-				c.logger.Debug("citations_delta", "citation", event.Delta.Citation)
+				c.logger.DebugContext(ctx, "citations_delta", "citation", event.Delta.Citation)
 				// TODO: Handle citation updates
 			case "input_json_delta":
 				// Tool use input delta
-				if currentToolCall != nil {
-					if partialJSON := event.Delta.PartialJSON; partialJSON != "" {
-						c.logger.Debug("input_json_delta", "partial_json", partialJSON)
-						toolCallArgs.WriteString(partialJSON)
+				if partialJSON := event.Delta.PartialJSON; partialJSON != "" {
+					c.logger.DebugContext(ctx, "input_json_delta", "partial_json", partialJSON)
+					call, complete, err := toolCallAcc.Add(common.ToolCallFragment{Index: int(event.Index), Arguments: partialJSON})
+					if err != nil {
+						return chat.Message{}, err
+					}
+					if err := common.EmitToolCallDelta(callback, call.ID, call.Name, partialJSON); err != nil {
+						return chat.Message{}, err
+					}
+					if complete {
+						if err := common.EmitToolCall(callback, call); err != nil {
+							return chat.Message{}, err
+						}
 					}
 				}
 			default:
@@ -542,7 +666,7 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 						}
 					}
 				} else if event.Delta.Type != "" {
-					c.logger.Debug("unhandled delta type", "type", event.Delta.Type, "delta", event.Delta)
+					c.logger.DebugContext(ctx, "unhandled delta type", "type", event.Delta.Type, "delta", event.Delta)
 				}
 			}
 		case "content_block_stop":
@@ -562,35 +686,14 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 					}
 				}
 			}
-			// Finalize current tool call if we have one
-			if currentToolCall != nil {
-				// Prefer accumulated deltas over start event input
-				if toolCallArgs.Len() > 0 {
-					currentToolCall.Input = json.RawMessage(toolCallArgs.String())
-					c.logger.Debug("set tool input from deltas", "input", toolCallArgs.String())
-				}
-
-				// Now emit the tool call event with complete arguments
-				if callback != nil {
-					toolCallEvent := chat.StreamEvent{
-						Type: chat.StreamEventTypeToolCall,
-						ToolCalls: []chat.ToolCall{
-							{
-								ID:        currentToolCall.ID,
-								Name:      currentToolCall.Name,
-								Arguments: currentToolCall.Input,
-							},
-						},
-					}
-					if err := callback(toolCallEvent); err != nil {
-						return chat.Message{}, err
-					}
+			// Finalize the tool call if this block was one, emitting it now if
+			// its arguments weren't valid JSON as they streamed. Deltas are
+			// preferred over the start event's input.
+			if call, complete := toolCallAcc.Finish(int(event.Index), toolCallStartInput); complete {
+				c.logger.DebugContext(ctx, "finalizing tool call", "id", call.ID, "name", call.Name, "input", call.Arguments)
+				if err := common.EmitToolCall(callback, call); err != nil {
+					return chat.Message{}, err
 				}
-
-				c.logger.Debug("finalizing tool call", "id", currentToolCall.ID, "name", currentToolCall.Name, "input", string(currentToolCall.Input))
-				toolCalls = append(toolCalls, *currentToolCall)
-				currentToolCall = nil
-				toolCallArgs.Reset()
 			}
 		case "message_delta":
 			// Check for usage information in message delta
@@ -605,29 +708,43 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 				c.state.UpdateUsage(usage)
 
 				totalUsage, _ := c.state.TokenUsage()
-				c.logger.Debug("usage from message_delta", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens,
+				c.logger.DebugContext(ctx, "usage from message_delta", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens,
 					"cumulative_input", totalUsage.Cumulative.InputTokens, "cumulative_output", totalUsage.Cumulative.OutputTokens, "cumulative_total", totalUsage.Cumulative.TotalTokens)
 			}
 		case "message_stop":
 			// Message stream completed
-			c.logger.Debug("stream completed via message_stop")
+			c.logger.DebugContext(ctx, "stream completed via message_stop")
 		default:
 			// Log unhandled event types at debug level
-			c.logger.Debug("unhandled stream event type", "type", event.Type, "event", event)
+			c.logger.DebugContext(ctx, "unhandled stream event type", "type", event.Type, "event", event)
 		}
 	}
 
 	if err := stream.Err(); err != nil {
 		return chat.Message{}, fmt.Errorf("streaming error: %w", err)
 	}
+	toolCalls = toolCallAcc.Calls()
+
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundEnd, 0, common.RoundEndReason(len(toolCalls) > 0)); err != nil {
+		return chat.Message{}, err
+	}
 
 	// Handle tool calls with multiple rounds if needed
 	if len(toolCalls) > 0 {
-		c.logger.Debug("initial response has tool calls, entering tool call handler", "count", len(toolCalls), "initial_text", respContent.String())
-		return c.handleToolCallRounds(ctx, reqMsg, respContent.String(), thinkingContent.String(), thinkingSignature.String(), toolCalls, reqOpts, callback)
+		c.logger.DebugContext(ctx, "initial response has tool calls, entering tool call handler", "count", len(toolCalls), "initial_text", respContent.String())
+		resp := chat.Message{Role: chat.AssistantRole}
+		if respContent.Len() > 0 {
+			resp.AddText(respContent.String())
+		}
+		addThinking(&resp, thinkingContents(thinkingContent.String(), thinkingSignature.String(), redactedThinking))
+		for _, call := range toolCalls {
+			resp.AddToolCall(call.ToolCall())
+		}
+		// Record the user message with the reminder sent with the follow-ups
+		return c.handleToolCallRounds(ctx, common.WithPrependedSystemReminder(ctx, reqMsg), resp, reqOpts, callback)
 	}
 
-	c.logger.Debug("initial response has no tool calls, returning content", "content", respContent.String())
+	c.logger.DebugContext(ctx, "initial response has no tool calls, returning content", "content", respContent.String())
 
 	// Build response message, avoiding empty text content blocks
 	respMsg := chat.Message{Role: chat.AssistantRole}
@@ -636,9 +753,7 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 	}
 
 	// Add thinking content if present
-	if thinkingContent.Len() > 0 {
-		respMsg.AddThinking(thinkingContent.String(), thinkingSignature.String())
-	}
+	addThinking(&respMsg, thinkingContents(thinkingContent.String(), thinkingSignature.String(), redactedThinking))
 
 	// Update history
 	c.state.AppendMessages([]chat.Message{reqMsg, respMsg}, nil)
@@ -662,6 +777,32 @@ func (c *chatClient) MaxTokens() int {
 	return c.maxTokens
 }
 
+// ContextLimit returns the size of the model's context window
+func (c *chatClient) ContextLimit() int {
+	return c.contextLimit
+}
+
+// Model returns the name of the model
+func (c *chatClient) Model() string {
+	return c.modelName
+}
+
+// LastRequests implements chat.RequestReporter
+func (c *chatClient) LastRequests() []chat.RequestInfo {
+	return c.state.LastRequests()
+}
+
+// EstimateRequest implements chat.RequestEstimator
+func (c *chatClient) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	return common.EstimateRequest(ctx, c, msg, opts...)
+}
+
+// SetSystemPrompt replaces the system prompt for subsequent messages
+func (c *chatClient) SetSystemPrompt(ctx context.Context, prompt string) error {
+	c.state.SetSystemPrompt(prompt)
+	return nil
+}
+
 // RegisterTool registers a tool that can be called by the LLM
 func (c *chatClient) RegisterTool(tool chat.Tool) error {
 	return c.tools.Register(tool)
@@ -714,75 +855,86 @@ func (c *chatClient) mcpToClaudeTool(mcpDef chat.ToolDef) (anthropic.ToolUnionPa
 	}, nil
 }
 
-// handleToolCalls processes tool calls from the model and returns tool result content blocks
-func (c *chatClient) handleToolCalls(ctx context.Context, toolCalls []anthropic.ToolUseBlock, callback chat.StreamCallback) ([]anthropic.ContentBlockParamUnion, []chat.ToolResult, error) {
-	if len(toolCalls) == 0 {
-		return nil, nil, nil
+func claudeToolResultBlock(tr chat.ToolResult) anthropic.ContentBlockParamUnion {
+	content := tr.Content
+	isError := false
+	if tr.Error != "" {
+		isError = true
+		content = common.FormatToolResultError(tr)
 	}
+	if content == "" {
+		content = "{}"
+	}
+	block := anthropic.NewToolResultBlock(tr.ToolCallID, content, isError)
+	for _, img := range tr.Images {
+		block.OfToolResult.Content = append(block.OfToolResult.Content, anthropic.ToolResultBlockParamContentUnion{
+			OfImage: claudeImageBlock(img).OfImage,
+		})
+	}
+	return block
+}
 
-	var toolResults []anthropic.ContentBlockParamUnion
-	var chatResults []chat.ToolResult
-
-	for _, toolCall := range toolCalls {
-		argsStr := string(toolCall.Input)
-		result, err := c.tools.Execute(ctx, toolCall.Name, argsStr)
-		toolResult := common.BuildToolResult(toolCall.Name, toolCall.ID, result, err)
-
-		resultContent := toolResult.Content
-		if err != nil {
-			resultContent = common.FormatToolErrorJSON(err.Error())
-		}
-
-		if callback != nil {
-			toolResultEvent := chat.StreamEvent{
-				Type:        chat.StreamEventTypeToolResult,
-				ToolResults: []chat.ToolResult{toolResult},
-			}
-			if callbackErr := callback(toolResultEvent); callbackErr != nil {
-				return nil, nil, fmt.Errorf("callback error: %w", callbackErr)
-			}
-		}
-
-		if err != nil {
-			errorResult := anthropic.NewToolResultBlock(toolCall.ID, resultContent, true)
-			toolResults = append(toolResults, errorResult)
-			chatResults = append(chatResults, toolResult)
-			continue
-		}
-
-		c.logger.Debug("tool executed", "name", toolCall.Name, "args", argsStr, "result", result)
+// claudeImageBlock converts an image to a base64 image block.
+func claudeImageBlock(img chat.ImageContent) anthropic.ContentBlockParamUnion {
+	return anthropic.NewImageBlockBase64(img.MediaType, base64.StdEncoding.EncodeToString(img.Data))
+}
 
-		resultBlock := anthropic.NewToolResultBlock(toolCall.ID, resultContent, false)
-		toolResults = append(toolResults, resultBlock)
-		chatResults = append(chatResults, toolResult)
+// claudeThinkingBlock converts thinking content to a thinking or
+// redacted_thinking block. Claude rejects thinking blocks without a valid
+// signature, so thinking that lacks one or was produced by another provider
+// is dropped (ok is false). Thinking persisted before Provider was recorded
+// can only have come from Claude.
+func claudeThinkingBlock(t chat.ThinkingContent) (block anthropic.ContentBlockParamUnion, ok bool) {
+	if t.Provider != "" && t.Provider != providerName {
+		return anthropic.ContentBlockParamUnion{}, false
 	}
-
-	return toolResults, chatResults, nil
+	if t.RedactedData != "" {
+		return anthropic.NewRedactedThinkingBlock(t.RedactedData), true
+	}
+	if t.Signature == "" {
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	return anthropic.NewThinkingBlock(t.Signature, t.Text), true
 }
 
-func claudeToolUseToChat(tool anthropic.ToolUseBlock) chat.ToolCall {
-	var args json.RawMessage
-	if len(tool.Input) > 0 {
-		args = append(json.RawMessage(nil), tool.Input...)
+// thinkingContents collects the thinking from a Claude response: the
+// thinking block, if any, followed by any redacted_thinking blocks.
+func thinkingContents(text, signature string, redacted []string) []chat.ThinkingContent {
+	var thinking []chat.ThinkingContent
+	if text != "" {
+		thinking = append(thinking, chat.ThinkingContent{
+			Text:      text,
+			Signature: signature,
+			Provider:  providerName,
+		})
 	}
-	return chat.ToolCall{
-		ID:        tool.ID,
-		Name:      tool.Name,
-		Arguments: args,
+	for _, data := range redacted {
+		thinking = append(thinking, chat.ThinkingContent{
+			RedactedData: data,
+			Provider:     providerName,
+		})
 	}
+	return thinking
 }
 
-func claudeToolResultBlock(tr chat.ToolResult) anthropic.ContentBlockParamUnion {
-	content := tr.Content
-	isError := false
-	if tr.Error != "" {
-		isError = true
-		content = common.FormatToolErrorJSON(tr.Error)
+// addThinking appends thinking content to msg.
+func addThinking(msg *chat.Message, thinking []chat.ThinkingContent) {
+	for _, t := range thinking {
+		msg.Contents = append(msg.Contents, chat.Content{Thinking: &t})
 	}
-	if content == "" {
-		content = "{}"
+}
+
+// historyParam converts a message from the chat's history. System messages
+// are skipped, as Claude takes the system prompt separately.
+func historyParam(msg chat.Message) ([]anthropic.MessageParam, error) {
+	if msg.Role == "system" {
+		return nil, nil
+	}
+	param, err := messageParam(msg)
+	if err != nil {
+		return nil, err
 	}
-	return anthropic.NewToolResultBlock(tr.ToolCallID, content, isError)
+	return []anthropic.MessageParam{param}, nil
 }
 
 // messageParam converts a chat.Message to an anthropic.MessageParam.
@@ -799,13 +951,27 @@ func messageParam(msg chat.Message) (anthropic.MessageParam, error) {
 	}
 
 	var blocks []anthropic.ContentBlockParamUnion
+	var thinkingBlocks []anthropic.ContentBlockParamUnion
 
 	// Build content blocks from all contents
 	for _, content := range msg.Contents {
+		// Handle thinking content, which Claude only accepts in assistant messages
+		if content.Thinking != nil && msg.Role == chat.AssistantRole {
+			if block, ok := claudeThinkingBlock(*content.Thinking); ok {
+				thinkingBlocks = append(thinkingBlocks, block)
+			}
+		}
+
 		// Handle text content
 		if content.Text != "" {
 			blocks = append(blocks, anthropic.NewTextBlock(content.Text))
 		}
+		if content.SystemReminder != "" {
+			blocks = append(blocks, anthropic.NewTextBlock(content.SystemReminder))
+		}
+		if content.Image != nil {
+			blocks = append(blocks, claudeImageBlock(*content.Image))
+		}
 
 		// Handle tool call content
 		if content.ToolCall != nil {
@@ -822,6 +988,9 @@ func messageParam(msg chat.Message) (anthropic.MessageParam, error) {
 		}
 	}
 
+	// Claude requires thinking blocks to come before any other content
+	blocks = append(thinkingBlocks, blocks...)
+
 	// Check if we have any valid blocks
 	if len(blocks) == 0 {
 		return anthropic.MessageParam{}, fmt.Errorf("message has no valid content blocks")
@@ -842,255 +1011,244 @@ func messageParam(msg chat.Message) (anthropic.MessageParam, error) {
 	}
 }
 
-// handleToolCallRounds handles potentially multiple rounds of tool calls
-func (c *chatClient) handleToolCallRounds(ctx context.Context, initialMsg chat.Message, initialContent string, initialThinkingText string, initialThinkingSignature string, initialToolCalls []anthropic.ToolUseBlock, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
-	// Keep track of all content blocks for the conversation
-	var msgs []anthropic.MessageParam
+// handleToolCallRounds runs the tool calls in resp, the response to userMsg,
+// and the rounds of tool calls that follow.
+func (c *chatClient) handleToolCallRounds(ctx context.Context, userMsg, resp chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
+	// Convert the history before the loop records the user message
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
+	historyParams, err := c.history.Convert(history, historyParam)
+	if err != nil {
+		return chat.Message{}, fmt.Errorf("converting history message to param: %w", err)
+	}
 
-	// Build initial conversation with system prompt and history
-	// Snapshot history with minimal lock
-	systemPrompt, history := c.state.Snapshot()
+	loop := common.ToolLoop{
+		State:      c.state,
+		Tools:      c.tools,
+		Provider:   providerName,
+		Model:      c.modelName,
+		RepairArgs: c.repairToolArgs,
+		Logger:     c.logger,
+		Send: func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error) {
+			return c.streamToolRound(ctx, systemPrompt, historyParams, msgs, reqOpts, callback)
+		},
+	}
+	return loop.Run(ctx, callback, userMsg, resp)
+}
 
-	// Add history
-	for _, m := range history {
-		param, err := messageParam(m)
+// streamToolRound streams the response in a round of tool calls.
+// historyParams is the converted history, and turn the messages since.
+func (c *chatClient) streamToolRound(ctx context.Context, systemPrompt string, historyParams []anthropic.MessageParam, turn []chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, chat.TokenUsageDetails, error) {
+	msgs := slices.Clip(historyParams)
+	for _, msg := range turn {
+		param, err := messageParam(msg)
 		if err != nil {
-			return chat.Message{}, fmt.Errorf("converting history message to param: %w", err)
+			return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("converting %s message to param: %w", msg.Role, err)
 		}
 		msgs = append(msgs, param)
 	}
 
-	// Add the initial user message with system reminder prepended if present
-	userBlocks := []anthropic.ContentBlockParamUnion{}
-	chatInitialMsg := chat.Message{Role: chat.UserRole}
-	if reminder := getSystemReminderText(ctx); reminder != "" {
-		userBlocks = append(userBlocks, anthropic.NewTextBlock(reminder))
-		// Add system reminder to the message we'll persist
-		chatInitialMsg.Contents = append(chatInitialMsg.Contents, chat.Content{SystemReminder: reminder})
+	followUpParams := anthropic.MessageNewParams{
+		Messages:  msgs,
+		Model:     anthropic.Model(c.modelName),
+		MaxTokens: getMaxOutputTokens(c.logger, c.modelName),
 	}
-	userBlocks = append(userBlocks, anthropic.NewTextBlock(initialMsg.GetText()))
-	chatInitialMsg.Contents = append(chatInitialMsg.Contents, chat.Content{Text: initialMsg.GetText()})
-	msgs = append(msgs, anthropic.NewUserMessage(userBlocks...))
-
-	// Persist the user message WITH system reminder for complete audit trail
-	c.state.AppendMessages([]chat.Message{chatInitialMsg}, nil)
 
-	// Process tool calls in a loop until we get a final response
-	toolCalls := initialToolCalls
+	// Add system prompt if present
+	var systemBlocks []anthropic.TextBlockParam
+	if systemPrompt != "" {
+		systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
+			Text: systemPrompt,
+			Type: "text",
+		})
+	}
 
-	c.logger.Debug("starting tool call rounds", "initial_tool_count", len(initialToolCalls))
+	if len(systemBlocks) > 0 {
+		followUpParams.System = systemBlocks
+	}
 
-	for len(toolCalls) > 0 {
-		c.logger.Debug("tool execution round", "tool_count", len(toolCalls))
-		for i, tc := range toolCalls {
-			c.logger.Debug("tool call", "index", i+1, "name", tc.Name, "input", string(tc.Input))
-		}
-		// Execute tool calls
-		toolResults, chatToolResults, err := c.handleToolCalls(ctx, toolCalls, callback)
-		if err != nil {
-			return chat.Message{}, fmt.Errorf("failed to execute tool calls: %w", err)
-		}
+	if reqOpts.Temperature != nil {
+		followUpParams.Temperature = anthropic.Float(*reqOpts.Temperature)
+	}
 
-		var assistantContentBlocks []anthropic.ContentBlockParamUnion
-		if initialContent != "" {
-			assistantContentBlocks = append(assistantContentBlocks, anthropic.NewTextBlock(initialContent))
-		}
-		chatToolCalls := make([]chat.ToolCall, len(toolCalls))
-		for i, toolCall := range toolCalls {
-			toolUseBlock := anthropic.NewToolUseBlock(toolCall.ID, toolCall.Input, toolCall.Name)
-			assistantContentBlocks = append(assistantContentBlocks, toolUseBlock)
-			chatToolCalls[i] = claudeToolUseToChat(toolCall)
-		}
+	if reqOpts.MaxTokens > 0 {
+		followUpParams.MaxTokens = int64(reqOpts.MaxTokens)
+	}
 
-		assistantMsg := anthropic.NewAssistantMessage(assistantContentBlocks...)
-		msgs = append(msgs, assistantMsg)
+	if reqOpts.User != "" {
+		followUpParams.Metadata.UserID = anthropic.String(reqOpts.User)
+	}
 
-		// Build assistant message, avoiding empty text content blocks
-		chatAssistantMsg := chat.Message{Role: chat.AssistantRole}
-		if initialContent != "" {
-			chatAssistantMsg.AddText(initialContent)
-		}
-		if initialThinkingText != "" {
-			chatAssistantMsg.AddThinking(initialThinkingText, initialThinkingSignature)
-		}
-		for _, tc := range chatToolCalls {
-			chatAssistantMsg.AddToolCall(tc)
-		}
-		stateMessages := []chat.Message{chatAssistantMsg}
-		if len(chatToolResults) > 0 {
-			toolMsg := chat.Message{Role: chat.ToolRole}
-			for _, tr := range chatToolResults {
-				toolMsg.AddToolResult(tr)
-			}
-			// Add system reminder AFTER tool results (Claude's ordering requirement)
-			// This ensures the complete message is persisted for audit trail
-			if reminder := getSystemReminderText(ctx); reminder != "" {
-				toolMsg.Contents = append(toolMsg.Contents, chat.Content{SystemReminder: reminder})
-			}
-			stateMessages = append(stateMessages, toolMsg)
-		}
-		c.state.AppendMessages(stateMessages, nil)
-		initialContent = ""
-
-		// Only create user message if we have tool results
-		// Empty messages would cause "text content blocks must be non-empty" error
-		if len(toolResults) > 0 {
-			// Build message with tool results first, then system reminder
-			// Claude requires tool_result blocks to immediately follow tool_use blocks
-			resultBlocks := []anthropic.ContentBlockParamUnion{}
-			resultBlocks = append(resultBlocks, toolResults...)
-			if reminder := getSystemReminderText(ctx); reminder != "" {
-				resultBlocks = append(resultBlocks, anthropic.NewTextBlock(reminder))
+	// Add tools if registered (for follow-up after tool execution)
+	allTools := c.tools.ForRequest(reqOpts)
+	if len(allTools) > 0 {
+		tools := make([]anthropic.ToolUnionParam, 0, len(allTools))
+		for _, tool := range allTools {
+			toolParam, err := c.mcpToClaudeTool(tool)
+			if err != nil {
+				// Skip this tool on error
+				continue
 			}
-			userMsg := anthropic.NewUserMessage(resultBlocks...)
-			msgs = append(msgs, userMsg)
-		}
-
-		// Make another API call with tool results
-		followUpParams := anthropic.MessageNewParams{
-			Messages:  msgs,
-			Model:     anthropic.Model(c.modelName),
-			MaxTokens: getMaxOutputTokens(c.modelName),
+			tools = append(tools, toolParam)
 		}
+		followUpParams.Tools = tools
+	}
 
-		// Add system prompt if present
-		var systemBlocks []anthropic.TextBlockParam
-		if systemPrompt != "" {
-			systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
-				Text: systemPrompt,
-				Type: "text",
-			})
-		}
+	// Create a new stream for the follow-up request
+	followUpStream := c.anthropicClient.Messages.NewStreaming(ctx, followUpParams)
 
-		if len(systemBlocks) > 0 {
-			followUpParams.System = systemBlocks
-		}
+	// Process the follow-up stream
+	var respContent strings.Builder
+	var followUpThinkingContent strings.Builder
+	var followUpThinkingSignature strings.Builder
+	var followUpRedactedThinking []string
+	var usage chat.TokenUsageDetails
+	var toolCallAcc common.ToolCallAccumulator
+	var toolCallStartInput string
 
-		if reqOpts.Temperature != nil {
-			followUpParams.Temperature = anthropic.Float(*reqOpts.Temperature)
-		}
+	for followUpStream.Next() {
+		event := followUpStream.Current()
 
-		if reqOpts.MaxTokens > 0 {
-			followUpParams.MaxTokens = int64(reqOpts.MaxTokens)
-		}
-
-		// Add tools if registered (for follow-up after tool execution)
-		allTools := c.tools.GetAll()
-		if len(allTools) > 0 {
-			tools := make([]anthropic.ToolUnionParam, 0, len(allTools))
-			for _, tool := range allTools {
-				toolParam, err := c.mcpToClaudeTool(tool)
-				if err != nil {
-					// Skip this tool on error
-					continue
+		// Handle different event types similar to main streaming logic
+		switch event.Type {
+		case "message_start":
+			common.SetResponseID(ctx, event.Message.ID)
+			common.SetResponseModel(ctx, string(event.Message.Model))
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				// Start of a tool use block
+				if _, _, err := toolCallAcc.Add(common.ToolCallFragment{
+					Index: int(event.Index),
+					ID:    event.ContentBlock.ID,
+					Name:  event.ContentBlock.Name,
+				}); err != nil {
+					return chat.Message{}, chat.TokenUsageDetails{}, err
 				}
-				tools = append(tools, toolParam)
-			}
-			followUpParams.Tools = tools
-		}
+				toolCallStartInput = ""
+				c.logger.DebugContext(ctx, "follow-up tool use start", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
 
-		// Create a new stream for the follow-up request
-		followUpStream := c.anthropicClient.Messages.NewStreaming(ctx, followUpParams)
-
-		// Process the follow-up stream
-		var respContent strings.Builder
-		var followUpThinkingContent strings.Builder
-		var followUpThinkingSignature strings.Builder
-		// Preserve any initial content from before the tool calls
-		if initialContent != "" {
-			respContent.WriteString(initialContent)
-			initialContent = "" // Only use it once
-		}
-		toolCalls = nil // Reset for next round
-		var currentToolCall *anthropic.ToolUseBlock
-		var toolCallArgs strings.Builder
-
-		for followUpStream.Next() {
-			event := followUpStream.Current()
-
-			// Handle different event types similar to main streaming logic
-			switch event.Type {
-			case "content_block_start":
-				if event.ContentBlock.Type == "tool_use" {
-					// Start of a tool use block
-					currentToolCall = &anthropic.ToolUseBlock{
-						ID:   event.ContentBlock.ID,
-						Name: event.ContentBlock.Name,
+				// Don't emit tool call event yet - wait for arguments to be accumulated
+				if event.ContentBlock.Input != nil {
+					// Input is sometimes provided in the start event, and is
+					// used if no deltas follow
+					inputBytes, err := json.Marshal(event.ContentBlock.Input)
+					if err == nil {
+						toolCallStartInput = string(inputBytes)
+						c.logger.DebugContext(ctx, "follow-up set tool input from start event", "input", string(inputBytes))
 					}
-					toolCallArgs.Reset()
-					c.logger.Debug("follow-up tool use start", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
-
-					// Don't emit tool call event yet - wait for arguments to be accumulated
-					if event.ContentBlock.Input != nil {
-						// Input is sometimes provided in the start event
-						inputBytes, err := json.Marshal(event.ContentBlock.Input)
-						if err == nil {
-							currentToolCall.Input = json.RawMessage(inputBytes)
-							c.logger.Debug("follow-up set tool input from start event", "input", string(inputBytes))
-						}
+				}
+			} else if event.ContentBlock.Type == "thinking" {
+				// Thinking block in follow-up
+				followUpThinkingContent.Reset()
+				followUpThinkingSignature.Reset()
+				if callback != nil {
+					thinkingEvent := chat.StreamEvent{
+						Type:           chat.StreamEventTypeThinking,
+						ThinkingStatus: &chat.ThinkingStatus{},
 					}
-				} else if event.ContentBlock.Type == "thinking" {
-					// Thinking block in follow-up
-					followUpThinkingContent.Reset()
-					followUpThinkingSignature.Reset()
-					if callback != nil {
-						thinkingEvent := chat.StreamEvent{
-							Type:           chat.StreamEventTypeThinking,
-							ThinkingStatus: &chat.ThinkingStatus{},
-						}
-						if err := callback(thinkingEvent); err != nil {
-							return chat.Message{}, err
-						}
+					if err := callback(thinkingEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
 					}
-				} else if event.ContentBlock.Type == "redacted_thinking" {
-					// Redacted thinking block in follow-up
-					c.logger.Debug("follow-up redacted thinking block detected", "data", event.ContentBlock.Data)
-					if callback != nil {
-						redactedEvent := chat.StreamEvent{
-							Type: chat.StreamEventTypeRedactedThinking,
-							ThinkingStatus: &chat.ThinkingStatus{
-								RedactedData: event.ContentBlock.Data,
-							},
-						}
-						if err := callback(redactedEvent); err != nil {
-							return chat.Message{}, err
-						}
+				}
+			} else if event.ContentBlock.Type == "redacted_thinking" {
+				// Redacted thinking block in follow-up
+				c.logger.DebugContext(ctx, "follow-up redacted thinking block detected", "data", event.ContentBlock.Data)
+				followUpRedactedThinking = append(followUpRedactedThinking, event.ContentBlock.Data)
+				if callback != nil {
+					redactedEvent := chat.StreamEvent{
+						Type: chat.StreamEventTypeRedactedThinking,
+						ThinkingStatus: &chat.ThinkingStatus{
+							RedactedData: event.ContentBlock.Data,
+						},
 					}
-				} else if event.ContentBlock.Type == "server_tool_use" {
-					// Server-side tool invocation in follow-up
-					c.logger.Debug("follow-up server tool use", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
-					if callback != nil {
-						serverToolEvent := chat.StreamEvent{
-							Type: chat.StreamEventTypeServerToolUse,
-							ToolCalls: []chat.ToolCall{
-								{
-									ID:        event.ContentBlock.ID,
-									Name:      event.ContentBlock.Name,
-									Arguments: nil,
-								},
+					if err := callback(redactedEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+				}
+			} else if event.ContentBlock.Type == "server_tool_use" {
+				// Server-side tool invocation in follow-up
+				c.logger.DebugContext(ctx, "follow-up server tool use", "id", event.ContentBlock.ID, "name", event.ContentBlock.Name, "input", event.ContentBlock.Input)
+				if callback != nil {
+					serverToolEvent := chat.StreamEvent{
+						Type: chat.StreamEventTypeServerToolUse,
+						ToolCalls: []chat.ToolCall{
+							{
+								ID:        event.ContentBlock.ID,
+								Name:      event.ContentBlock.Name,
+								Arguments: nil,
 							},
-						}
-						if err := callback(serverToolEvent); err != nil {
-							return chat.Message{}, err
-						}
+						},
 					}
-				} else if event.ContentBlock.Type == "web_search_tool_result" {
-					// Web search results in follow-up
-					c.logger.Debug("follow-up web search result", "tool_use_id", event.ContentBlock.ToolUseID, "content", event.ContentBlock.Content)
-					if callback != nil {
-						webSearchEvent := chat.StreamEvent{
-							Type:    chat.StreamEventTypeWebSearchResult,
-							Content: "Web search results received in follow-up",
-						}
-						if err := callback(webSearchEvent); err != nil {
-							return chat.Message{}, err
+					if err := callback(serverToolEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+				}
+			} else if event.ContentBlock.Type == "web_search_tool_result" {
+				// Web search results in follow-up
+				c.logger.DebugContext(ctx, "follow-up web search result", "tool_use_id", event.ContentBlock.ToolUseID, "content", event.ContentBlock.Content)
+				if callback != nil {
+					webSearchEvent := chat.StreamEvent{
+						Type:    chat.StreamEventTypeWebSearchResult,
+						Content: "Web search results received in follow-up",
+					}
+					if err := callback(webSearchEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+				}
+			}
+		case "content_block_delta":
+			// Handle different delta types similar to main streaming
+			switch event.Delta.Type {
+			case "text_delta":
+				content := event.Delta.Text
+				respContent.WriteString(content)
+				if callback != nil {
+					streamEvent := chat.StreamEvent{
+						Type:    chat.StreamEventTypeContent,
+						Content: content,
+					}
+					if err := callback(streamEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+				}
+			case "thinking_delta":
+				// Direct thinking delta in follow-up
+				followUpThinkingContent.WriteString(event.Delta.Thinking)
+				if callback != nil {
+					thinkingEvent := chat.StreamEvent{
+						Type:           chat.StreamEventTypeThinking,
+						Content:        event.Delta.Thinking,
+						ThinkingStatus: &chat.ThinkingStatus{},
+					}
+					if err := callback(thinkingEvent); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+				}
+			case "signature_delta":
+				// Thinking block signature in follow-up
+				followUpThinkingSignature.WriteString(event.Delta.Signature)
+				c.logger.DebugContext(ctx, "follow-up got signature_delta", "signature", event.Delta.Signature)
+			case "citations_delta":
+				// Citation updates in follow-up
+				c.logger.DebugContext(ctx, "follow-up got citations_delta", "citation", event.Delta.Citation)
+			case "input_json_delta":
+				// Tool use input delta
+				if partialJSON := event.Delta.PartialJSON; partialJSON != "" {
+					call, complete, err := toolCallAcc.Add(common.ToolCallFragment{Index: int(event.Index), Arguments: partialJSON})
+					if err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+					if err := common.EmitToolCallDelta(callback, call.ID, call.Name, partialJSON); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+					if complete {
+						if err := common.EmitToolCall(callback, call); err != nil {
+							return chat.Message{}, chat.TokenUsageDetails{}, err
 						}
 					}
 				}
-			case "content_block_delta":
-				// Handle different delta types similar to main streaming
-				switch event.Delta.Type {
-				case "text_delta":
+			default:
+				// Handle backwards compatibility
+				if event.Delta.Text != "" && event.Delta.Type == "" {
 					content := event.Delta.Text
 					respContent.WriteString(content)
 					if callback != nil {
@@ -1099,143 +1257,54 @@ func (c *chatClient) handleToolCallRounds(ctx context.Context, initialMsg chat.M
 							Content: content,
 						}
 						if err := callback(streamEvent); err != nil {
-							return chat.Message{}, err
-						}
-					}
-				case "thinking_delta":
-					// Direct thinking delta in follow-up
-					followUpThinkingContent.WriteString(event.Delta.Thinking)
-					if callback != nil {
-						thinkingEvent := chat.StreamEvent{
-							Type:           chat.StreamEventTypeThinking,
-							Content:        event.Delta.Thinking,
-							ThinkingStatus: &chat.ThinkingStatus{},
-						}
-						if err := callback(thinkingEvent); err != nil {
-							return chat.Message{}, err
-						}
-					}
-				case "signature_delta":
-					// Thinking block signature in follow-up
-					followUpThinkingSignature.WriteString(event.Delta.Signature)
-					c.logger.Debug("follow-up got signature_delta", "signature", event.Delta.Signature)
-				case "citations_delta":
-					// Citation updates in follow-up
-					c.logger.Debug("follow-up got citations_delta", "citation", event.Delta.Citation)
-				case "input_json_delta":
-					// Tool use input delta
-					if currentToolCall != nil {
-						if partialJSON := event.Delta.PartialJSON; partialJSON != "" {
-							toolCallArgs.WriteString(partialJSON)
-						}
-					}
-				default:
-					// Handle backwards compatibility
-					if event.Delta.Text != "" && event.Delta.Type == "" {
-						content := event.Delta.Text
-						respContent.WriteString(content)
-						if callback != nil {
-							streamEvent := chat.StreamEvent{
-								Type:    chat.StreamEventTypeContent,
-								Content: content,
-							}
-							if err := callback(streamEvent); err != nil {
-								return chat.Message{}, err
-							}
+							return chat.Message{}, chat.TokenUsageDetails{}, err
 						}
-					} else if event.Delta.Type != "" {
-						c.logger.Debug("follow-up unhandled delta type", "type", event.Delta.Type, "delta", event.Delta)
 					}
+				} else if event.Delta.Type != "" {
+					c.logger.DebugContext(ctx, "follow-up unhandled delta type", "type", event.Delta.Type, "delta", event.Delta)
 				}
-			case "content_block_stop":
-				// Finalize current tool call if we have one
-				if currentToolCall != nil {
-					// Prefer accumulated deltas over start event input
-					if toolCallArgs.Len() > 0 {
-						currentToolCall.Input = json.RawMessage(toolCallArgs.String())
-						c.logger.Debug("follow-up set tool input from deltas", "input", toolCallArgs.String())
-					}
-
-					// Now emit the tool call event with complete arguments
-					if callback != nil {
-						toolCallEvent := chat.StreamEvent{
-							Type: chat.StreamEventTypeToolCall,
-							ToolCalls: []chat.ToolCall{
-								{
-									ID:        currentToolCall.ID,
-									Name:      currentToolCall.Name,
-									Arguments: currentToolCall.Input,
-								},
-							},
-						}
-						if err := callback(toolCallEvent); err != nil {
-							return chat.Message{}, err
-						}
-					}
-
-					c.logger.Debug("follow-up finalizing tool call", "id", currentToolCall.ID, "name", currentToolCall.Name, "input", string(currentToolCall.Input))
-					toolCalls = append(toolCalls, *currentToolCall)
-					currentToolCall = nil
-					toolCallArgs.Reset()
+			}
+		case "content_block_stop":
+			// Finalize the tool call if this block was one, emitting it now if
+			// its arguments weren't valid JSON as they streamed. Deltas are
+			// preferred over the start event's input.
+			if call, complete := toolCallAcc.Finish(int(event.Index), toolCallStartInput); complete {
+				c.logger.DebugContext(ctx, "follow-up finalizing tool call", "id", call.ID, "name", call.Name, "input", call.Arguments)
+				if err := common.EmitToolCall(callback, call); err != nil {
+					return chat.Message{}, chat.TokenUsageDetails{}, err
 				}
-			case "message_delta":
-				// Check for usage information in follow-up message delta
-				if event.Usage.InputTokens > 0 || event.Usage.OutputTokens > 0 {
-					usage := chat.TokenUsageDetails{
-						InputTokens:  int(event.Usage.InputTokens),
-						OutputTokens: int(event.Usage.OutputTokens),
-						TotalTokens:  int(event.Usage.InputTokens + event.Usage.OutputTokens),
-					}
-
-					// Update usage
-					c.state.UpdateUsage(usage)
-
-					totalUsage, _ := c.state.TokenUsage()
-					c.logger.Debug("follow-up usage from message_delta", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens,
-						"cumulative_input", totalUsage.Cumulative.InputTokens, "cumulative_output", totalUsage.Cumulative.OutputTokens, "cumulative_total", totalUsage.Cumulative.TotalTokens)
+			}
+		case "message_delta":
+			// Check for usage information in follow-up message delta
+			if event.Usage.InputTokens > 0 || event.Usage.OutputTokens > 0 {
+				usage = chat.TokenUsageDetails{
+					InputTokens:  int(event.Usage.InputTokens),
+					OutputTokens: int(event.Usage.OutputTokens),
+					TotalTokens:  int(event.Usage.InputTokens + event.Usage.OutputTokens),
 				}
-			case "message_stop":
-				// Follow-up message stream completed
-				c.logger.Debug("follow-up stream completed via message_stop")
-			default:
-				// Log unhandled event types at debug level
-				c.logger.Debug("follow-up unhandled stream event type", "type", event.Type, "event", event)
+				c.logger.DebugContext(ctx, "follow-up usage from message_delta", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
 			}
+		case "message_stop":
+			// Follow-up message stream completed
+			c.logger.DebugContext(ctx, "follow-up stream completed via message_stop")
+		default:
+			// Log unhandled event types at debug level
+			c.logger.DebugContext(ctx, "follow-up unhandled stream event type", "type", event.Type, "event", event)
 		}
+	}
 
-		if err := followUpStream.Err(); err != nil {
-			return chat.Message{}, fmt.Errorf("follow-up streaming error: %w", err)
-		}
-
-		// If we got more tool calls, continue the loop
-		if len(toolCalls) > 0 {
-			c.logger.Debug("got more tool calls, continuing", "count", len(toolCalls))
-			continue
-		}
-
-		c.logger.Debug("no more tool calls, got final response", "response", respContent.String())
-
-		// No more tool calls, we have the final response
-		// Build final message, avoiding empty text content blocks
-		finalMsg := chat.Message{Role: chat.AssistantRole}
-		if respContent.Len() > 0 {
-			finalMsg.AddText(respContent.String())
-		}
-
-		// Add thinking content if present from follow-up rounds
-		if followUpThinkingContent.Len() > 0 {
-			finalMsg.AddThinking(followUpThinkingContent.String(), followUpThinkingSignature.String())
-		}
-
-		c.logger.Debug("returning final response from tool handler", "content_length", len(finalMsg.GetText()))
-
-		// Update history with final assistant response (user message already persisted)
-		c.state.AppendMessages([]chat.Message{finalMsg}, nil)
-
-		return finalMsg, nil
+	if err := followUpStream.Err(); err != nil {
+		return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("follow-up streaming error: %w", err)
 	}
 
-	// This should never be reached since the loop continues until no tool calls
-	c.logger.Error("unexpected end of tool call processing", "initial_tool_count", len(initialToolCalls))
-	return chat.Message{}, fmt.Errorf("unexpected end of tool call processing")
+	// Build the response, avoiding empty text content blocks
+	resp := chat.Message{Role: chat.AssistantRole}
+	if respContent.Len() > 0 {
+		resp.AddText(respContent.String())
+	}
+	addThinking(&resp, thinkingContents(followUpThinkingContent.String(), followUpThinkingSignature.String(), followUpRedactedThinking))
+	for _, call := range toolCallAcc.Calls() {
+		resp.AddToolCall(call.ToolCall())
+	}
+	return resp, usage, nil
 }
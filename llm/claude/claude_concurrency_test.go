@@ -0,0 +1,64 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_ConcurrentMessagesAreSerialized(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > maxInFlight.Load() {
+			maxInFlight.Store(n)
+		}
+		<-release
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+	c := client.NewChat("System")
+
+	var wg sync.WaitGroup
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Message(context.Background(), chat.UserMessage(fmt.Sprintf("Message %d", i)))
+			assert.NoError(t, err)
+		}()
+	}
+
+	// While the first call is blocked in the server, a third caller that
+	// won't wait gets ErrBusy
+	require.Eventually(t, func() bool { return inFlight.Load() == 1 }, time.Second, time.Millisecond)
+	_, err = c.Message(context.Background(), chat.UserMessage("Impatient"), chat.WithQueueTimeout(10*time.Millisecond))
+	assert.ErrorIs(t, err, chat.ErrBusy)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxInFlight.Load())
+	_, history := c.History()
+	require.Len(t, history, 4)
+	assert.Equal(t, chat.UserRole, history[0].Role)
+	assert.Equal(t, chat.AssistantRole, history[1].Role)
+	assert.Equal(t, chat.UserRole, history[2].Role)
+	assert.Equal(t, chat.AssistantRole, history[3].Role)
+}
@@ -0,0 +1,56 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_DryRun(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("You are helpful.")
+	_, err = c.Message(context.Background(), chat.UserMessage("Hello"), chat.WithDryRun(), chat.WithMaxTokens(100))
+	var req *chat.DryRunRequest
+	require.True(t, errors.As(err, &req), "got %v", err)
+	assert.Zero(t, requests.Load())
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, server.URL+"/v1/messages", req.URL)
+
+	var body struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Stream    bool   `json:"stream"`
+		Messages  []struct {
+			Role string `json:"role"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(req.Body, &body))
+	assert.Equal(t, "claude-3-haiku", body.Model)
+	assert.Equal(t, 100, body.MaxTokens)
+	assert.True(t, body.Stream)
+	require.Len(t, body.Messages, 1)
+	assert.Equal(t, "user", body.Messages[0].Role)
+	assert.Contains(t, string(req.Body), "You are helpful.")
+
+	_, msgs := c.History()
+	assert.Empty(t, msgs)
+	assert.Empty(t, c.(chat.RequestReporter).LastRequests())
+}
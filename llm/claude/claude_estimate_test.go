@@ -0,0 +1,53 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_EstimateRequest(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("You are helpful.", chat.UserMessage("Earlier"), chat.AssistantMessage("Noted"))
+	estimator, ok := c.(chat.RequestEstimator)
+	require.True(t, ok)
+
+	small, err := estimator.EstimateRequest(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, small.Messages)
+	assert.Zero(t, small.ToolBytes)
+	assert.Positive(t, small.Bytes)
+	assert.Equal(t, (small.Bytes+3)/4, small.EstimatedTokens)
+
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "lookup",
+		jsonSchema: `{"name":"lookup","description":"Looks things up","inputSchema":{"type":"object","properties":{}}}`,
+	}))
+	large, err := estimator.EstimateRequest(context.Background(), chat.UserMessage(strings.Repeat("log line\n", 5_000)))
+	require.NoError(t, err)
+	assert.Equal(t, 3, large.Messages)
+	assert.Positive(t, large.ToolBytes)
+	assert.Greater(t, large.Bytes, small.Bytes+40_000)
+	assert.Greater(t, large.EstimatedTokens, 10_000)
+
+	assert.Zero(t, requests.Load())
+	_, msgs := c.History()
+	assert.Len(t, msgs, 2)
+}
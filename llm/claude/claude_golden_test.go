@@ -0,0 +1,21 @@
+package claude
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestClaude_RequestGolden(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient("https://api.anthropic.invalid", "test-key", WithModel("claude-sonnet-4-5"))
+	require.NoError(t, err)
+	llmtesting.TestRequestGolden(t, client, "testdata/request.golden.json", *update, chat.WithMaxTokens(1024))
+}
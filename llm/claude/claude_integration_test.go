@@ -49,40 +49,21 @@ func getAPIKey() string {
 	return os.Getenv("ANTHROPIC_API_KEY")
 }
 
-func TestClaudeIntegration_Streaming(t *testing.T) {
+func TestClaudeIntegration_Conformance(t *testing.T) {
 	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Use the test helper for streaming
-	llmtesting.TestStreaming(t, client)
-}
-
-func TestClaudeIntegration_ToolCalling(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
 
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Use the test helper for tool calling
-	llmtesting.TestToolsSummarizesFile(t, client)
-}
-
-func TestClaudeIntegration_ToolCallingWithContext(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
+	newClient := func(model string) llmtesting.ClientFactory {
+		return func(t *testing.T) chat.Client {
+			llmtesting.SkipIfNoAPIKey(t, provider)
+			client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(model))
+			require.NoError(t, err, "Failed to create Claude client")
+			return client
+		}
+	}
 
-	// Use the test helper for context-aware tool calling
-	llmtesting.TestWritesFile(t, client)
+	// The thinking tests need a thinking-capable model.
+	llmtesting.RunConformanceSuite(t, newClient(getTestModel()),
+		llmtesting.WithThinkingClient(newClient("claude-sonnet-4-5-20250929")))
 }
 
 func TestClaudeIntegration_TokenUsage(t *testing.T) {
@@ -122,33 +103,6 @@ func TestClaudeIntegration_TokenUsage(t *testing.T) {
 	t.Logf("Max tokens for model %s: %d", getTestModel(), maxTokens)
 }
 
-func TestClaudeIntegration_TokenUsageCumulative(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Use the test helper for cumulative token usage
-	llmtesting.TestTokenUsageCumulative(t, client)
-}
-
-func TestClaudeIntegration_ToolCallStreamEvents(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Use the test helper for tool call stream events
-	llmtesting.TestToolCallStreamEvents(t, client)
-
-	// Test both tool call and result events
-	llmtesting.TestToolCallAndResultStreamEvents(t, client)
-}
-
 func TestClaudeIntegration_ToolRegistration(t *testing.T) {
 	t.Parallel()
 	llmtesting.SkipIfNoAPIKey(t, provider)
@@ -290,29 +244,6 @@ func TestClaudeIntegration_SimpleToolCall(t *testing.T) {
 		toolCalled, toolInput, responseText)
 }
 
-func TestClaudeIntegration_ToolWithOptionalFields(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	llmtesting.TestToolWithOptionalFields(t, client)
-}
-
-func TestClaudeIntegration_SystemReminderWithToolCalls(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err, "Failed to create Claude client")
-	require.NotNil(t, client)
-
-	// Use the test helper for system reminder with tool calls
-	llmtesting.TestSystemReminderWithToolCalls(t, client)
-}
-
 func TestClaudeIntegration_MaxTokensByModel(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -335,52 +266,3 @@ func TestClaudeIntegration_MaxTokensByModel(t *testing.T) {
 		})
 	}
 }
-
-func TestClaudeIntegration_NoDuplicateMessages(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err, "Failed to create Claude client")
-	require.NotNil(t, client)
-
-	// Use the test helper for checking duplicate messages
-	llmtesting.TestNoDuplicateMessages(t, client)
-}
-
-func TestClaudeIntegration_MessagePersistenceAfterRestore(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel(getTestModel()))
-	require.NoError(t, err, "Failed to create Claude client")
-	require.NotNil(t, client)
-
-	llmtesting.TestMessagePersistenceAfterRestore(t, client)
-}
-
-func TestClaudeIntegration_ThinkingPreservedInHistory(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	// Use a thinking-capable model
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel("claude-sonnet-4-5-20250929"))
-	require.NoError(t, err, "Failed to create Claude client")
-	require.NotNil(t, client)
-
-	// Use the test helper for thinking preservation
-	llmtesting.TestThinkingPreservedInHistory(t, client)
-}
-
-func TestClaudeIntegration_ThinkingPreservedWithToolCalls(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	// Use a thinking-capable model
-	client, err := NewClient(AnthropicURL, getAPIKey(), WithModel("claude-sonnet-4-5-20250929"))
-	require.NoError(t, err, "Failed to create Claude client")
-	require.NotNil(t, client)
-
-	// Use the test helper for thinking preservation with tool calls
-	llmtesting.TestThinkingPreservedWithToolCalls(t, client)
-}
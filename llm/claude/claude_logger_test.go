@@ -0,0 +1,36 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_WithLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	// Debug logs reach the client's handler, whatever the global level
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"), WithLogger(handler))
+	require.NoError(t, err)
+
+	ctx := chat.WithTurnID(context.Background(), "turn-1")
+	_, err = client.NewChat("System").Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `level=DEBUG msg="stream event" provider=claude type=message_start turn_id=turn-1`)
+}
@@ -0,0 +1,51 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_Ping(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("X-Api-Key") != "good-key" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		assert.Equal(t, "/v1/models/claude-sonnet-4-5", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"claude-sonnet-4-5","type":"model","display_name":"Claude Sonnet 4.5","created_at":"2025-09-29T00:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "good-key", WithModel("claude-sonnet-4-5"))
+	require.NoError(t, err)
+	status, err := client.(chat.Pinger).Ping(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Healthy())
+	assert.Equal(t, "claude", status.Provider)
+	assert.Equal(t, "claude-sonnet-4-5", status.Model)
+
+	client, err = NewClient(server.URL, "bad-key", WithModel("claude-sonnet-4-5"))
+	require.NoError(t, err)
+	requests.Store(0)
+	status, err = client.(chat.Pinger).Ping(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, chat.HealthUnauthorized, status.State)
+	assert.Equal(t, http.StatusUnauthorized, status.StatusCode)
+	assert.Equal(t, int32(1), requests.Load(), "failed checks aren't retried")
+}
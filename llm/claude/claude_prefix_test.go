@@ -0,0 +1,82 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_WithAssistantPrefix(t *testing.T) {
+	type requestMessage struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	var requests [][]requestMessage
+	var systems []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Messages []requestMessage `json:"messages"`
+			System   []struct {
+				Text string `json:"text"`
+			} `json:"system"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		requests = append(requests, req.Messages)
+		var system string
+		for _, block := range req.System {
+			system += block.Text
+		}
+		systems = append(systems, system)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("You are helpful.")
+	ctx := context.Background()
+	var streamed string
+	resp, err := c.Message(ctx, chat.UserMessage("Hello"),
+		chat.WithAssistantPrefix("```json\n"),
+		chat.WithStreamingCb(func(event chat.StreamEvent) error {
+			if event.Type == chat.StreamEventTypeContent {
+				streamed += event.Content
+			}
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "```jsonDone", resp.GetText())
+	assert.Equal(t, "```jsonDone", streamed)
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0], 2)
+	last := requests[0][1]
+	assert.Equal(t, "assistant", last.Role)
+	require.Len(t, last.Content, 1)
+	assert.Equal(t, "```json", last.Content[0].Text)
+	assert.Equal(t, "You are helpful.", systems[0])
+
+	_, err = c.Message(ctx, chat.UserMessage("Hello again"))
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.Len(t, requests[1], 3)
+	assert.Equal(t, "user", requests[1][2].Role)
+	assert.Equal(t, "```jsonDone", requests[1][1].Content[0].Text)
+}
@@ -0,0 +1,46 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_WithRawEventTap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	var providers, types []string
+	var payloads [][]byte
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"),
+		WithRawEventTap(func(provider, eventType string, payload []byte) {
+			providers = append(providers, provider)
+			types = append(types, eventType)
+			payloads = append(payloads, payload)
+		}))
+	require.NoError(t, err)
+
+	resp, err := client.NewChat("System").Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+
+	assert.Equal(t, []string{
+		"message_start", "content_block_start", "content_block_delta",
+		"content_block_stop", "message_delta", "message_stop",
+	}, types)
+	for _, provider := range providers {
+		assert.Equal(t, "claude", provider)
+	}
+	require.Len(t, payloads, 6)
+	assert.JSONEq(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Done"}}`, string(payloads[2]))
+}
@@ -0,0 +1,85 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_LastRequests(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("request-id", fmt.Sprintf("req_%d", n))
+		if n == 1 {
+			w.Header().Set("retry-after-ms", "1")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(529)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("You are helpful.")
+	_, err = c.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	reporter, ok := c.(chat.RequestReporter)
+	require.True(t, ok)
+	got := reporter.LastRequests()
+	require.Len(t, got, 2)
+	assert.Equal(t, "req_1", got[0].RequestID)
+	assert.Equal(t, 529, got[0].StatusCode)
+	assert.Equal(t, "req_2", got[1].RequestID)
+	assert.Equal(t, "msg_2", got[1].ResponseID)
+	assert.Equal(t, http.StatusOK, got[1].StatusCode)
+	assert.Positive(t, got[1].Latency)
+	assert.Equal(t, "claude-3-haiku", c.(chat.ModelReporter).Model())
+}
+
+func TestClaude_ResponseModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseEvents(
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-20241022","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-5-haiku-latest"))
+	require.NoError(t, err)
+
+	// The snapshot that served the alias is reported on the response and
+	// its request, and isn't kept in the history
+	c := client.NewChat("You are helpful.")
+	resp, err := c.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "claude-3-5-haiku-20241022", resp.Model)
+
+	got := c.(chat.RequestReporter).LastRequests()
+	require.Len(t, got, 1)
+	assert.Equal(t, "claude-3-5-haiku-20241022", got[0].Model)
+
+	_, history := c.History()
+	require.Len(t, history, 2)
+	assert.Empty(t, history[1].Model)
+}
@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// interruptedStream streams part of a response, then fails as an overloaded
+// server does partway through.
+var interruptedStream = sseEvents(
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The answer is "}}`,
+	`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+)
+
+// continuedStream continues interruptedStream's text from where a prefill of
+// it, without its trailing space, leaves off.
+var continuedStream = sseEvents(
+	`{"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":14,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" 42."}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+	`{"type":"message_stop"}`,
+)
+
+func TestClaude_WithStreamResume(t *testing.T) {
+	type requestMessage struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	newServer := func(requests *[][]requestMessage) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var req struct {
+				Messages []requestMessage `json:"messages"`
+			}
+			require.NoError(t, json.Unmarshal(body, &req))
+			*requests = append(*requests, req.Messages)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			if len(*requests) == 1 {
+				fmt.Fprint(w, interruptedStream)
+			} else {
+				fmt.Fprint(w, continuedStream)
+			}
+		}))
+	}
+
+	t.Run("resumes from partial text", func(t *testing.T) {
+		var requests [][]requestMessage
+		server := newServer(&requests)
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+		require.NoError(t, err)
+		c := client.NewChat("You are helpful.")
+
+		var streamed string
+		var roundStarts int
+		resp, err := c.Message(context.Background(), chat.UserMessage("What is the answer?"),
+			chat.WithStreamResume(1),
+			chat.WithStreamingCb(func(event chat.StreamEvent) error {
+				switch event.Type {
+				case chat.StreamEventTypeContent:
+					streamed += event.Content
+				case chat.StreamEventTypeRoundStart:
+					roundStarts++
+				}
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		// The retry prefills the partial text, without its trailing space,
+		// and the callback sees each piece of text once
+		assert.Equal(t, "The answer is 42.", resp.GetText())
+		assert.Equal(t, "The answer is 42.", streamed)
+		assert.Equal(t, 1, roundStarts)
+		require.Len(t, requests, 2)
+		require.Len(t, requests[1], 2)
+		last := requests[1][1]
+		assert.Equal(t, "assistant", last.Role)
+		require.Len(t, last.Content, 1)
+		assert.Equal(t, "The answer is", last.Content[0].Text)
+
+		// Only the completed exchange is recorded
+		_, history := c.History()
+		require.Len(t, history, 2)
+		assert.Equal(t, "The answer is 42.", history[1].GetText())
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		var requests [][]requestMessage
+		server := newServer(&requests)
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+		require.NoError(t, err)
+		c := client.NewChat("You are helpful.")
+
+		_, err = c.Message(context.Background(), chat.UserMessage("What is the answer?"))
+		require.Error(t, err)
+		assert.Len(t, requests, 1)
+		_, history := c.History()
+		assert.Empty(t, history)
+	})
+}
@@ -0,0 +1,57 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_StreamCoalescing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseEvents(
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"One"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" two"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" three"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`,
+			`{"type":"message_stop"}`,
+		))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	var events []chat.StreamEvent
+	resp, err := client.NewChat("System").Message(context.Background(), chat.UserMessage("Count"),
+		chat.WithStreamingCb(func(event chat.StreamEvent) error {
+			events = append(events, event)
+			return nil
+		}),
+		chat.WithStreamCoalescing(time.Hour),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "One two three", resp.GetText())
+
+	// The first delta arrives immediately, and the rest together before the
+	// round ends
+	var contents []string
+	for _, event := range events {
+		if event.Type == chat.StreamEventTypeContent {
+			contents = append(contents, event.Content)
+		}
+	}
+	assert.Equal(t, []string{"One", " two three"}, contents)
+	assert.Equal(t, chat.StreamEventTypeRoundEnd, events[len(events)-1].Type)
+}
@@ -0,0 +1,52 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_SystemPromptOverride(t *testing.T) {
+	var systemPrompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			System []struct {
+				Text string `json:"text"`
+			} `json:"system"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		require.Len(t, req.System, 1)
+		systemPrompts = append(systemPrompts, req.System[0].Text)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("General assistant")
+	ctx := context.Background()
+	_, err = c.Message(ctx, chat.UserMessage("Extract the dates"), chat.WithSystemPromptOverride("Date extractor"))
+	require.NoError(t, err)
+	_, err = c.Message(ctx, chat.UserMessage("Thanks"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Date extractor", "General assistant"}, systemPrompts)
+
+	systemPrompt, msgs := c.History()
+	assert.Equal(t, "General assistant", systemPrompt)
+	assert.Len(t, msgs, 4)
+}
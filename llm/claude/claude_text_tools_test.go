@@ -0,0 +1,107 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_TextToolProtocol(t *testing.T) {
+	callText := "<tool_call>\n{\"name\": \"echo\", \"arguments\": {\"text\": \"hello\"}}\n</tool_call>"
+	callStream := sseEvents(
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":`+strconv.Quote(callText)+`}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+		`{"type":"message_stop"}`,
+	)
+
+	type request struct {
+		System []struct {
+			Text string `json:"text"`
+		} `json:"system"`
+		Tools    []json.RawMessage `json:"tools"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	var requests []request
+	var n atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req request
+		require.NoError(t, json.Unmarshal(body, &req))
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n.Add(1) == 1 {
+			fmt.Fprint(w, callStream)
+		} else {
+			fmt.Fprint(w, textStream)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+	c := client.NewChat("System")
+	var inputs []string
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "echo",
+		jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`,
+		callFn: func(ctx context.Context, input string) string {
+			inputs = append(inputs, input)
+			return input
+		},
+	}))
+
+	var toolResults []chat.ToolResult
+	resp, err := c.Message(context.Background(), chat.UserMessage("Echo hello"), chat.WithTextToolProtocol(), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		toolResults = append(toolResults, event.ToolResults...)
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+	assert.Equal(t, []string{`{"text": "hello"}`}, inputs)
+	require.Len(t, toolResults, 1)
+	assert.Equal(t, `{"text": "hello"}`, toolResults[0].Content)
+
+	// Tools are described in the system prompt rather than sent natively,
+	// and the results come back as a user message
+	require.Len(t, requests, 2)
+	for _, req := range requests {
+		assert.Empty(t, req.Tools)
+		require.Len(t, req.System, 1)
+		assert.Contains(t, req.System[0].Text, "System\n\nYou can call the tools")
+		assert.Contains(t, req.System[0].Text, "## echo")
+	}
+	second := requests[1].Messages
+	require.Len(t, second, 3)
+	assert.Equal(t, callText, second[1].Content[0].Text)
+	assert.Equal(t, "user", second[2].Role)
+	assert.Equal(t, "<tool_result name=\"echo\">\n{\"text\": \"hello\"}\n</tool_result>", second[2].Content[0].Text)
+
+	_, history := c.History()
+	require.Len(t, history, 4)
+	for _, msg := range history {
+		assert.False(t, msg.HasToolCalls())
+		assert.False(t, msg.HasToolResults())
+	}
+}
@@ -3,7 +3,11 @@ package claude
 import (
 	"testing"
 
+	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
 )
 
 func TestSupportsThinking(t *testing.T) {
@@ -82,3 +86,32 @@ func TestGenericSet(t *testing.T) {
 		assert.False(t, s.contains(4))
 	})
 }
+
+func TestThinkingContents(t *testing.T) {
+	thinking := thinkingContents("Let me think", "sig", []string{"redacted-1", "redacted-2"})
+	assert.Equal(t, []chat.ThinkingContent{
+		{Text: "Let me think", Signature: "sig", Provider: providerName},
+		{RedactedData: "redacted-1", Provider: providerName},
+		{RedactedData: "redacted-2", Provider: providerName},
+	}, thinking)
+
+	// Redacted-only responses still preserve their blocks
+	thinking = thinkingContents("", "", []string{"redacted"})
+	assert.Equal(t, []chat.ThinkingContent{
+		{RedactedData: "redacted", Provider: providerName},
+	}, thinking)
+
+	assert.Empty(t, thinkingContents("", "", nil))
+
+	// Round-trips back into request history in order
+	msg := chat.Message{Role: chat.AssistantRole}
+	addThinking(&msg, thinkingContents("Let me think", "sig", []string{"redacted"}))
+	msg.AddText("Answer")
+	param, err := messageParam(msg)
+	require.NoError(t, err)
+	assert.Equal(t, anthropic.NewAssistantMessage(
+		anthropic.NewThinkingBlock("sig", "Let me think"),
+		anthropic.NewRedactedThinkingBlock("redacted"),
+		anthropic.NewTextBlock("Answer"),
+	), param)
+}
@@ -0,0 +1,202 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/internal/common"
+)
+
+// sseEvents renders Anthropic streaming events in server-sent event format.
+func sseEvents(events ...string) string {
+	var b strings.Builder
+	for _, data := range events {
+		typ := data[len(`{"type":"`):]
+		typ = typ[:strings.IndexByte(typ, '"')]
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", typ, data)
+	}
+	return b.String()
+}
+
+var toolUseStream = sseEvents(
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"echo","input":{}}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"text\": "}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"hello\"}"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+	`{"type":"message_stop"}`,
+)
+
+var textStream = sseEvents(
+	`{"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","model":"claude-3-haiku","content":[],"stop_reason":null,"usage":{"input_tokens":20,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Done"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+	`{"type":"message_stop"}`,
+)
+
+func TestClaude_FineGrainedToolStreaming(t *testing.T) {
+	var requests atomic.Int32
+	var betas []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		betas = append(betas, r.Header.Get("anthropic-beta"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests.Add(1) == 1 {
+			fmt.Fprint(w, toolUseStream)
+		} else {
+			fmt.Fprint(w, textStream)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"), WithFineGrainedToolStreaming())
+	require.NoError(t, err)
+
+	c := client.NewChat("System")
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "echo",
+		jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`,
+		callFn: func(ctx context.Context, input string) string {
+			return input
+		},
+	}))
+
+	var deltas []chat.StreamEvent
+	var toolCalls []chat.ToolCall
+	resp, err := c.Message(context.Background(), chat.UserMessage("Echo hello"), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		switch event.Type {
+		case chat.StreamEventTypeToolCallDelta:
+			deltas = append(deltas, event)
+		case chat.StreamEventTypeToolCall:
+			toolCalls = append(toolCalls, event.ToolCalls...)
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+
+	require.Len(t, betas, 2)
+	for _, beta := range betas {
+		assert.Contains(t, beta, "fine-grained-tool-streaming-2025-05-14")
+		assert.Contains(t, beta, "interleaved-thinking-2025-05-14")
+	}
+
+	require.Len(t, deltas, 2)
+	var args strings.Builder
+	for _, d := range deltas {
+		require.Len(t, d.ToolCalls, 1)
+		assert.Equal(t, "toolu_1", d.ToolCalls[0].ID)
+		assert.Equal(t, "echo", d.ToolCalls[0].Name)
+		args.WriteString(d.Content)
+	}
+	assert.Equal(t, `{"text": "hello"}`, args.String())
+
+	require.Len(t, toolCalls, 1)
+	assert.JSONEq(t, `{"text": "hello"}`, string(toolCalls[0].Arguments))
+}
+
+func TestClaude_RoundEvents(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests.Add(1) == 1 {
+			fmt.Fprint(w, toolUseStream)
+		} else {
+			fmt.Fprint(w, textStream)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("System")
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "echo",
+		jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`,
+		callFn: func(ctx context.Context, input string) string {
+			return input
+		},
+	}))
+
+	var events []chat.StreamEvent
+	_, err = c.Message(context.Background(), chat.UserMessage("Echo hello"), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		switch event.Type {
+		case chat.StreamEventTypeRoundStart, chat.StreamEventTypeRoundEnd, chat.StreamEventTypeToolResult:
+			events = append(events, event)
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, events, 5)
+	assert.Equal(t, chat.StreamEventTypeRoundStart, events[0].Type)
+	assert.Equal(t, &chat.RoundStatus{Index: 0, Reason: chat.RoundReasonUserMessage}, events[0].Round)
+	assert.Equal(t, chat.StreamEventTypeRoundEnd, events[1].Type)
+	assert.Equal(t, &chat.RoundStatus{Index: 0, Reason: chat.RoundReasonToolCalls}, events[1].Round)
+	assert.Equal(t, chat.StreamEventTypeToolResult, events[2].Type)
+	assert.Equal(t, chat.StreamEventTypeRoundStart, events[3].Type)
+	assert.Equal(t, &chat.RoundStatus{Index: 1, Reason: chat.RoundReasonToolResults}, events[3].Round)
+	assert.Equal(t, chat.StreamEventTypeRoundEnd, events[4].Type)
+	assert.Equal(t, &chat.RoundStatus{Index: 1, Reason: chat.RoundReasonComplete}, events[4].Round)
+}
+
+func TestClaude_ToolArgumentRepair(t *testing.T) {
+	malformedStream := strings.Replace(toolUseStream, `\"hello\"}`, `\"hello\",}`, 1)
+
+	var requests atomic.Int32
+	var secondRequest []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests.Add(1) == 1 {
+			fmt.Fprint(w, malformedStream)
+		} else {
+			secondRequest, _ = io.ReadAll(r.Body)
+			fmt.Fprint(w, textStream)
+		}
+	}))
+	defer server.Close()
+
+	const model = "claude-3-haiku-repair-test"
+	client, err := NewClient(server.URL, "test-key", WithModel(model), WithToolArgumentRepair())
+	require.NoError(t, err)
+
+	c := client.NewChat("System")
+	var toolInput string
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "echo",
+		jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`,
+		callFn: func(ctx context.Context, input string) string {
+			toolInput = input
+			return input
+		},
+	}))
+
+	resp, err := c.Message(context.Background(), chat.UserMessage("Echo hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+	assert.Equal(t, `{"text": "hello"}`, toolInput)
+	assert.Contains(t, string(secondRequest), `"input":{"text":"hello"}`)
+
+	var stats common.ToolArgumentStats
+	for _, s := range common.ToolArgumentMetrics() {
+		if s.Provider == providerName && s.Model == model {
+			stats = s
+		}
+	}
+	assert.Equal(t, int64(1), stats.ToolCalls)
+	assert.Equal(t, int64(1), stats.Malformed)
+	assert.Equal(t, int64(1), stats.Repaired)
+}
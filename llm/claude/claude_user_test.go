@@ -0,0 +1,47 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_WithUser(t *testing.T) {
+	var userIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Metadata struct {
+				UserID string `json:"user_id"`
+			} `json:"metadata"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		userIDs = append(userIDs, req.Metadata.UserID)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+
+	c := client.NewChat("")
+	ctx := context.Background()
+	_, err = c.Message(ctx, chat.UserMessage("Hello"), chat.WithUser("user-1234"))
+	require.NoError(t, err)
+	_, err = c.Message(ctx, chat.UserMessage("Hello again"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"user-1234", ""}, userIDs)
+}
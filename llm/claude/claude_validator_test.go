@@ -0,0 +1,72 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestClaude_WithValidator(t *testing.T) {
+	var lastMessages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Messages []struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		last := req.Messages[len(req.Messages)-1]
+		lastMessages = append(lastMessages, last.Content[len(last.Content)-1].Text)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, textStream)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("claude-3-haiku"))
+	require.NoError(t, err)
+	c := client.NewChat("")
+
+	// The first response is rejected, and the model re-asked
+	calls := 0
+	errTooShort := errors.New("too short")
+	resp, err := c.Message(context.Background(), chat.UserMessage("Hello"), chat.WithValidator(func(msg chat.Message) error {
+		calls++
+		if calls == 1 {
+			return errTooShort
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+	require.Len(t, lastMessages, 2)
+	assert.Equal(t, "Hello", lastMessages[0])
+	assert.Contains(t, lastMessages[1], "too short")
+
+	_, history := c.History()
+	assert.Len(t, history, 4)
+
+	// Rejected responses are returned with a ValidationError once retries run out
+	resp, err = c.Message(context.Background(), chat.UserMessage("Again"),
+		chat.WithValidator(func(msg chat.Message) error { return errTooShort }),
+		chat.WithValidationRetries(0))
+	var verr *chat.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, 1, verr.Attempts)
+	assert.Equal(t, "Done", resp.GetText())
+	assert.Len(t, lastMessages, 3)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/internal/common"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
 )
 
 func TestMessageParam(t *testing.T) {
@@ -138,6 +140,25 @@ func TestMessageParam(t *testing.T) {
 				anthropic.NewToolResultBlock("tool_123", "The weather in Paris is sunny.", false),
 			),
 		},
+		{
+			name: "tool role message with system reminder",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "tool_123",
+							Content:    "The weather in Paris is sunny.",
+						},
+					},
+					{SystemReminder: "<system-reminder>Be brief.</system-reminder>"},
+				},
+			},
+			want: anthropic.NewUserMessage(
+				anthropic.NewToolResultBlock("tool_123", "The weather in Paris is sunny.", false),
+				anthropic.NewTextBlock("<system-reminder>Be brief.</system-reminder>"),
+			),
+		},
 		{
 			name: "tool role message with error result",
 			msg: chat.Message{
@@ -211,6 +232,45 @@ func TestMessageParam(t *testing.T) {
 			wantErr: true,
 			errMsg:  "message has no valid content blocks",
 		},
+		{
+			name: "user message with image",
+			msg: chat.Message{
+				Role: chat.UserRole,
+				Contents: []chat.Content{
+					{Text: "What is in this screenshot?"},
+					{Image: &chat.ImageContent{MediaType: "image/png", Data: []byte("png")}},
+				},
+			},
+			want: anthropic.NewUserMessage(
+				anthropic.NewTextBlock("What is in this screenshot?"),
+				anthropic.NewImageBlockBase64("image/png", "cG5n"),
+			),
+		},
+		{
+			name: "tool role message with image result",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "tool_123",
+							Content:    `{"mediaType":"image/png"}`,
+							Images:     []chat.ImageContent{{MediaType: "image/png", Data: []byte("png")}},
+						},
+					},
+				},
+			},
+			want: anthropic.NewUserMessage(anthropic.ContentBlockParamUnion{
+				OfToolResult: &anthropic.ToolResultBlockParam{
+					ToolUseID: "tool_123",
+					Content: []anthropic.ToolResultBlockParamContentUnion{
+						{OfText: &anthropic.TextBlockParam{Text: `{"mediaType":"image/png"}`}},
+						{OfImage: anthropic.NewImageBlockBase64("image/png", "cG5n").OfImage},
+					},
+					IsError: anthropic.Bool(false),
+				},
+			}),
+		},
 		{
 			name: "assistant message with tool result",
 			msg: chat.Message{
@@ -260,6 +320,48 @@ func TestMessageParam(t *testing.T) {
 				anthropic.NewToolUseBlock("tc2", json.RawMessage(`{}`), "tool2"),
 			),
 		},
+		{
+			name: "assistant message with thinking puts thinking first",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Text: "The answer is 4."},
+					{Thinking: &chat.ThinkingContent{Text: "2+2=4", Signature: "sig", Provider: "claude"}},
+				},
+			},
+			want: anthropic.NewAssistantMessage(
+				anthropic.NewThinkingBlock("sig", "2+2=4"),
+				anthropic.NewTextBlock("The answer is 4."),
+			),
+		},
+		{
+			name: "assistant message with redacted thinking",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Thinking: &chat.ThinkingContent{RedactedData: "encrypted"}},
+					{Text: "Done."},
+				},
+			},
+			want: anthropic.NewAssistantMessage(
+				anthropic.NewRedactedThinkingBlock("encrypted"),
+				anthropic.NewTextBlock("Done."),
+			),
+		},
+		{
+			name: "thinking from other providers is dropped",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Thinking: &chat.ThinkingContent{Text: "Gemini thought", Signature: "gemini-sig", Provider: "gemini"}},
+					{Thinking: &chat.ThinkingContent{Text: "Unsigned thought"}},
+					{Text: "Hello"},
+				},
+			},
+			want: anthropic.NewAssistantMessage(
+				anthropic.NewTextBlock("Hello"),
+			),
+		},
 		{
 			name: "tool result with empty content uses empty JSON",
 			msg: chat.Message{
@@ -296,3 +398,42 @@ func TestMessageParam(t *testing.T) {
 		})
 	}
 }
+
+// BenchmarkHistoryConversion measures converting history for every request
+// of a 200-turn session, with and without the conversion cache.
+func BenchmarkHistoryConversion(b *testing.B) {
+	const turns = 200
+	var turnMsgs [][]chat.Message
+	for i := range turns {
+		turnMsgs = append(turnMsgs, llmtesting.SessionTurn(i))
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				for _, msg := range history {
+					if _, err := historyParam(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var cache common.ConversionCache[anthropic.MessageParam]
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				if _, err := cache.Convert(history, historyParam); err != nil {
+					b.Fatal(err)
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+}
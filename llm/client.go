@@ -29,6 +29,12 @@ type Config struct {
 	// Values: -1=don't change (default), 0=Error, 1=Warn, 2=Info, 3=Debug
 	// Note: This is a global setting that affects all LLM providers in the process.
 	LogLevel int
+	// Logger, if non-nil, receives the client's logs instead of the library's
+	// global logger, at whatever level it enables; LogLevel doesn't apply to it.
+	Logger slog.Handler
+	// RawEventTap, if non-nil, receives every raw server-sent event the
+	// client streams (see claude.WithRawEventTap).
+	RawEventTap func(provider, eventType string, payload []byte)
 }
 
 // ModelProvider represents the different LLM providers
@@ -55,9 +61,18 @@ func NewClient(config *Config) (chat.Client, error) {
 		SetLogLevel(levels[config.LogLevel])
 	}
 
+	if factory, ok := registeredProvider(config.Provider); ok {
+		return factory(config)
+	}
+
 	provider := detectProvider(config.Model, config.Provider)
 	apiKey := config.APIKey
 
+	logger := logger
+	if config.Logger != nil {
+		logger = logging.New(config.Logger).With("component", "llm")
+	}
+
 	switch provider {
 	case ProviderOpenAI:
 		if apiKey == "" {
@@ -70,6 +85,12 @@ func NewClient(config *Config) (chat.Client, error) {
 		opts := []openai.Option{
 			openai.WithModel(config.Model),
 		}
+		if config.Logger != nil {
+			opts = append(opts, openai.WithLogger(config.Logger))
+		}
+		if config.RawEventTap != nil {
+			opts = append(opts, openai.WithRawEventTap(config.RawEventTap))
+		}
 
 		// Use Responses API for gpt-5, o1, and o3 models
 		if isResponsesModel(config.Model) {
@@ -98,6 +119,12 @@ func NewClient(config *Config) (chat.Client, error) {
 		opts := []claude.Option{
 			claude.WithModel(config.Model),
 		}
+		if config.Logger != nil {
+			opts = append(opts, claude.WithLogger(config.Logger))
+		}
+		if config.RawEventTap != nil {
+			opts = append(opts, claude.WithRawEventTap(config.RawEventTap))
+		}
 
 		if config.Headers != nil {
 			opts = append(opts, claude.WithHeaders(config.Headers))
@@ -124,6 +151,12 @@ func NewClient(config *Config) (chat.Client, error) {
 		opts := []gemini.Option{
 			gemini.WithModel(config.Model),
 		}
+		if config.Logger != nil {
+			opts = append(opts, gemini.WithLogger(config.Logger))
+		}
+		if config.RawEventTap != nil {
+			opts = append(opts, gemini.WithRawEventTap(config.RawEventTap))
+		}
 		if config.BaseURL != "" {
 			opts = append(opts, gemini.WithBaseURL(config.BaseURL))
 		}
@@ -139,6 +172,12 @@ func NewClient(config *Config) (chat.Client, error) {
 		opts := []openai.Option{
 			openai.WithModel(config.Model),
 		}
+		if config.Logger != nil {
+			opts = append(opts, openai.WithLogger(config.Logger))
+		}
+		if config.RawEventTap != nil {
+			opts = append(opts, openai.WithRawEventTap(config.RawEventTap))
+		}
 		if config.Headers != nil {
 			opts = append(opts, openai.WithHeaders(config.Headers))
 		}
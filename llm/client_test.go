@@ -1,10 +1,13 @@
 package llm
 
 import (
+	"bytes"
+	"log/slog"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsResponsesModel(t *testing.T) {
@@ -260,3 +263,18 @@ func TestNewClient(t *testing.T) {
 		})
 	}
 }
+
+func TestNewClient_Logger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	_, err := NewClient(&Config{
+		Model:    "claude-opus-4",
+		Provider: "Claude",
+		APIKey:   "test-claude-key",
+		LogLevel: -1,
+		Logger:   slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}),
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `level=INFO msg="using Claude client" component=llm model=claude-opus-4`)
+}
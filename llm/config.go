@@ -0,0 +1,314 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// FileConfig is configuration shared between agents, loaded from a YAML (or
+// JSON) file with LoadConfig. For example:
+//
+//	model: smart
+//	aliases:
+//	  smart:
+//	    model: claude-sonnet-4-5
+//	    fallback: [{model: gpt-5}]
+//	    options: {reasoning_effort: high}
+//	  local: {model: qwen3, provider: ollama}
+//	providers:
+//	  anthropic:
+//	    api_key_env: TEAM_ANTHROPIC_KEY
+//	  ollama:
+//	    base_url: http://gpu-box:11434/v1
+//	defaults:
+//	  temperature: 0.2
+//	  max_tokens: 4096
+//	tools:
+//	  allow: [read_file, read_dir]
+type FileConfig struct {
+	// Model is the model (or alias) to use when none is given.
+	Model string `yaml:"model"`
+	// Aliases maps short names to concrete models. Use Router to get their
+	// fallbacks and options as well.
+	Aliases map[string]ModelAlias `yaml:"aliases"`
+	// Providers configures endpoints and credentials, keyed by provider
+	// name: openai, anthropic, google, ollama, or one added with
+	// RegisterProvider.
+	Providers map[string]ProviderConfig `yaml:"providers"`
+	// Defaults are default request options.
+	Defaults DefaultOptions `yaml:"defaults"`
+	// Tools restricts which tools agents register.
+	Tools ToolPolicy `yaml:"tools"`
+}
+
+// ModelAlias names a concrete model, and optionally its provider.
+type ModelAlias struct {
+	Model    string `yaml:"model"`
+	Provider string `yaml:"provider"`
+	// Fallback lists models to try, in order, if Model fails.
+	Fallback []Target `yaml:"fallback"`
+	// Options are default request options when using this alias. They
+	// override the file's Defaults (see ModelDefaults); system_prompt isn't
+	// supported here.
+	Options DefaultOptions `yaml:"options"`
+}
+
+// ProviderConfig configures a provider's endpoint and credentials.
+type ProviderConfig struct {
+	BaseURL string `yaml:"base_url"`
+	// APIKeyEnv is the environment variable holding the API key, for when
+	// it isn't the provider's default (e.g. ANTHROPIC_API_KEY).
+	APIKeyEnv string            `yaml:"api_key_env"`
+	Headers   map[string]string `yaml:"headers"`
+}
+
+// DefaultOptions are request options applied unless overridden.
+type DefaultOptions struct {
+	Temperature     *float64 `yaml:"temperature"`
+	MaxTokens       int      `yaml:"max_tokens"`
+	ReasoningEffort string   `yaml:"reasoning_effort"`
+	SystemPrompt    string   `yaml:"system_prompt"`
+}
+
+// ToolPolicy restricts which tools may be registered. Names may be path.Match
+// patterns like "read_*".
+type ToolPolicy struct {
+	// Allow lists the permitted tools; if empty, all tools are permitted.
+	Allow []string `yaml:"allow"`
+	// Deny lists forbidden tools, and takes precedence over Allow.
+	Deny []string `yaml:"deny"`
+}
+
+// providerNames are the built-in provider names accepted in config files,
+// matching Config.Provider. Providers added with RegisterProvider are
+// accepted too.
+var providerNames = map[string]ModelProvider{
+	"openai":    ProviderOpenAI,
+	"anthropic": ProviderClaude,
+	"google":    ProviderGemini,
+	"ollama":    ProviderOllama,
+}
+
+// LoadConfig reads and validates a config file. Unknown fields are errors,
+// so typos don't silently fall back to defaults.
+func LoadConfig(filename string) (*FileConfig, error) {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("unsupported config format %q (want .yaml, .yml, or .json)", ext)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config FileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config %s: %w", filename, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	return &config, nil
+}
+
+func (c *FileConfig) validate() error {
+	for name, alias := range c.Aliases {
+		if alias.Model == "" {
+			return fmt.Errorf("alias %q: model is required", name)
+		}
+		for _, target := range alias.targets() {
+			if target.Model == "" {
+				return fmt.Errorf("alias %q: fallback model is required", name)
+			}
+			if target.Provider != "" && !knownProvider(target.Provider) {
+				return fmt.Errorf("alias %q: unknown provider %q", name, target.Provider)
+			}
+			if _, ok := c.Aliases[target.Model]; ok {
+				return fmt.Errorf("alias %q refers to alias %q; aliases must name concrete models", name, target.Model)
+			}
+		}
+		if alias.Options.SystemPrompt != "" {
+			return fmt.Errorf("alias %q: system_prompt isn't supported in alias options", name)
+		}
+		if err := alias.Options.validate(); err != nil {
+			return fmt.Errorf("alias %q: %w", name, err)
+		}
+	}
+	for name := range c.Providers {
+		if !knownProvider(name) {
+			return fmt.Errorf("unknown provider %q", name)
+		}
+	}
+	if err := c.Defaults.validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for _, pattern := range slices.Concat(c.Tools.Allow, c.Tools.Deny) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("tools: bad pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (d DefaultOptions) validate() error {
+	if t := d.Temperature; t != nil && (*t < 0 || *t > 2) {
+		return fmt.Errorf("temperature %v out of range [0, 2]", *t)
+	}
+	if d.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	return nil
+}
+
+// targets returns the alias's models in fallback order.
+func (a ModelAlias) targets() []Target {
+	return append([]Target{{Model: a.Model, Provider: a.Provider}}, a.Fallback...)
+}
+
+// Apply fills in the fields of config that aren't already set: it resolves
+// an alias in config.Model to its primary model (or uses the file's default model), then sets the
+// provider's endpoint, headers, and API key, and the default temperature,
+// max tokens, and system prompt (see ModelDefaults). Fields already set in config take
+// precedence, so flags or code can override the file.
+func (c *FileConfig) Apply(config *Config) error {
+	if config.Model == "" {
+		config.Model = c.Model
+	}
+	defaults := c.ModelDefaults(config.Model)
+	if alias, ok := c.Aliases[config.Model]; ok {
+		config.Model = alias.Model
+		if config.Provider == "" {
+			config.Provider = alias.Provider
+		}
+	}
+
+	if pc, ok := c.Providers[providerName(config)]; ok {
+		if config.BaseURL == "" {
+			config.BaseURL = pc.BaseURL
+		}
+		if len(pc.Headers) > 0 {
+			headers := maps.Clone(pc.Headers)
+			maps.Copy(headers, config.Headers)
+			config.Headers = headers
+		}
+		if config.APIKey == "" && pc.APIKeyEnv != "" {
+			config.APIKey = os.Getenv(pc.APIKeyEnv)
+			if config.APIKey == "" {
+				return fmt.Errorf("API key environment variable %s is not set", pc.APIKeyEnv)
+			}
+		}
+	}
+
+	if config.Temperature == 0 && defaults.Temperature != nil {
+		config.Temperature = *defaults.Temperature
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+	if config.SystemPrompt == "" {
+		config.SystemPrompt = defaults.SystemPrompt
+	}
+	return nil
+}
+
+// providerName returns the config file name of config's provider, or "" if
+// it can't be determined.
+func providerName(config *Config) string {
+	if _, ok := registeredProvider(config.Provider); ok {
+		return config.Provider
+	}
+	provider := detectProvider(config.Model, config.Provider)
+	for name, p := range providerNames {
+		if p == provider {
+			return name
+		}
+	}
+	return ""
+}
+
+// MessageOptions returns the file's default per-message options.
+func (c *FileConfig) MessageOptions() []chat.Option {
+	return c.Defaults.options()
+}
+
+// ModelDefaults returns the default options for model: the file's Defaults,
+// overridden by the options of the alias model names, if any.
+func (c *FileConfig) ModelDefaults(model string) DefaultOptions {
+	defaults := c.Defaults
+	alias, ok := c.Aliases[model]
+	if !ok {
+		return defaults
+	}
+	if alias.Options.Temperature != nil {
+		defaults.Temperature = alias.Options.Temperature
+	}
+	if alias.Options.MaxTokens > 0 {
+		defaults.MaxTokens = alias.Options.MaxTokens
+	}
+	if alias.Options.ReasoningEffort != "" {
+		defaults.ReasoningEffort = alias.Options.ReasoningEffort
+	}
+	return defaults
+}
+
+// Router returns a router with a route for each alias, whose targets use the
+// file's provider settings.
+func (c *FileConfig) Router() *Router {
+	r := NewRouter()
+	r.file = c
+	for name, alias := range c.Aliases {
+		r.SetRoute(name, Route{
+			Targets: alias.targets(),
+			Options: alias.Options.options(),
+		})
+	}
+	return r
+}
+
+func (d DefaultOptions) options() []chat.Option {
+	var opts []chat.Option
+	if d.Temperature != nil {
+		opts = append(opts, chat.WithTemperature(*d.Temperature))
+	}
+	if d.MaxTokens > 0 {
+		opts = append(opts, chat.WithMaxTokens(d.MaxTokens))
+	}
+	if d.ReasoningEffort != "" {
+		opts = append(opts, chat.WithReasoningEffort(d.ReasoningEffort))
+	}
+	return opts
+}
+
+// Allowed reports whether the policy permits the named tool.
+func (p ToolPolicy) Allowed(name string) bool {
+	for _, pattern := range p.Deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, []byte(contents), 0o644))
+	return filename
+}
+
+const testConfig = `
+model: smart
+aliases:
+  smart: {model: claude-sonnet-4-5}
+  local: {model: qwen3, provider: ollama}
+providers:
+  anthropic:
+    api_key_env: TEST_TEAM_ANTHROPIC_KEY
+    headers: {X-Team: agents}
+  ollama:
+    base_url: http://gpu-box:11434/v1
+defaults:
+  temperature: 0.2
+  max_tokens: 4096
+  reasoning_effort: low
+  system_prompt: You are terse.
+tools:
+  allow: [read_*, write_file]
+  deny: [read_secrets]
+`
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, "agents.yaml", testConfig))
+	require.NoError(t, err)
+
+	assert.Equal(t, "smart", config.Model)
+	assert.Equal(t, ModelAlias{Model: "qwen3", Provider: "ollama"}, config.Aliases["local"])
+	assert.Equal(t, "http://gpu-box:11434/v1", config.Providers["ollama"].BaseURL)
+	require.NotNil(t, config.Defaults.Temperature)
+	assert.Equal(t, 0.2, *config.Defaults.Temperature)
+
+	opts := chat.ApplyOptions(config.MessageOptions()...)
+	require.NotNil(t, opts.Temperature)
+	assert.Equal(t, 0.2, *opts.Temperature)
+	assert.Equal(t, 4096, opts.MaxTokens)
+	assert.Equal(t, "low", opts.ReasoningEffort)
+
+	// JSON is valid YAML
+	config, err = LoadConfig(writeConfig(t, "agents.json", `{"model": "gpt-5", "tools": {"deny": ["write_file"]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-5", config.Model)
+	assert.False(t, config.Tools.Allowed("write_file"))
+
+	config, err = LoadConfig(writeConfig(t, "empty.yml", ""))
+	require.NoError(t, err)
+	assert.Empty(t, config.Model)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		err      string
+	}{
+		{"format", "agents.toml", `model = "gpt-5"`, `unsupported config format ".toml"`},
+		{"unknown field", "a.yaml", "modle: gpt-5", "field modle not found"},
+		{"alias without model", "a.yaml", "aliases: {fast: {provider: openai}}", `alias "fast": model is required`},
+		{"alias provider", "a.yaml", "aliases: {fast: {model: x, provider: azure}}", `unknown provider "azure"`},
+		{"alias chain", "a.yaml", "aliases: {fast: {model: cheap}, cheap: {model: gpt-5-mini}}", `alias "fast" refers to alias "cheap"`},
+		{"provider", "a.yaml", "providers: {azure: {base_url: x}}", `unknown provider "azure"`},
+		{"temperature", "a.yaml", "defaults: {temperature: 3}", "temperature 3 out of range"},
+		{"pattern", "a.yaml", "tools: {allow: ['[']}", `bad pattern "["`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.file, tt.contents))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileConfigApply(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, "agents.yaml", testConfig))
+	require.NoError(t, err)
+
+	t.Setenv("TEST_TEAM_ANTHROPIC_KEY", "team-key")
+	c := &Config{}
+	require.NoError(t, config.Apply(c))
+	assert.Equal(t, &Config{
+		Model:        "claude-sonnet-4-5",
+		APIKey:       "team-key",
+		Headers:      map[string]string{"X-Team": "agents"},
+		Temperature:  0.2,
+		MaxTokens:    4096,
+		SystemPrompt: "You are terse.",
+	}, c)
+
+	// Explicit settings win over the file
+	c = &Config{Model: "local", BaseURL: "http://localhost:11434/v1", MaxTokens: 100}
+	require.NoError(t, config.Apply(c))
+	assert.Equal(t, "qwen3", c.Model)
+	assert.Equal(t, "ollama", c.Provider)
+	assert.Equal(t, "http://localhost:11434/v1", c.BaseURL)
+	assert.Equal(t, 100, c.MaxTokens)
+
+	c = &Config{Model: "claude-opus-4", APIKey: "mine", Headers: map[string]string{"X-Team": "me"}}
+	require.NoError(t, config.Apply(c))
+	assert.Equal(t, "mine", c.APIKey)
+	assert.Equal(t, map[string]string{"X-Team": "me"}, c.Headers)
+
+	t.Setenv("TEST_TEAM_ANTHROPIC_KEY", "")
+	err = config.Apply(&Config{})
+	assert.EqualError(t, err, "API key environment variable TEST_TEAM_ANTHROPIC_KEY is not set")
+}
+
+func TestToolPolicyAllowed(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, ToolPolicy{}.Allowed("anything"))
+
+	policy := ToolPolicy{Allow: []string{"read_*", "write_file"}, Deny: []string{"read_secrets"}}
+	assert.True(t, policy.Allowed("read_file"))
+	assert.True(t, policy.Allowed("write_file"))
+	assert.False(t, policy.Allowed("read_secrets"))
+	assert.False(t, policy.Allowed("delete_file"))
+}
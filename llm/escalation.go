@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Escalation makes a route answer with a cheap draft model, its first target,
+// and escalate to its remaining targets only when the draft's response
+// triggers one of the checks. The escalation targets fall back between each
+// other like an ordinary route's. Each message starts on the draft model
+// again, continuing the conversation so far.
+type Escalation struct {
+	// MinLength escalates draft responses with fewer characters of text.
+	MinLength int
+	// Refusals escalates draft responses that start like a refusal, such as
+	// "I can't help with that".
+	Refusals bool
+	// Validator escalates draft responses it returns an error for.
+	Validator func(chat.Message) error
+	// Tool registers a tool named "escalate" on the draft model, which it can
+	// call to hand a request it can't handle to the escalation targets.
+	Tool bool
+}
+
+// EscalationReason is why a draft response was escalated.
+type EscalationReason string
+
+const (
+	// EscalatedError means the draft model's request failed.
+	EscalatedError EscalationReason = "error"
+	// EscalatedLength means the draft response was shorter than MinLength.
+	EscalatedLength EscalationReason = "length"
+	// EscalatedRefusal means the draft response looked like a refusal.
+	EscalatedRefusal EscalationReason = "refusal"
+	// EscalatedValidator means Validator rejected the draft response.
+	EscalatedValidator EscalationReason = "validator"
+	// EscalatedTool means the draft model called the escalate tool.
+	EscalatedTool EscalationReason = "tool"
+)
+
+// EscalationStats counts a route's escalations.
+type EscalationStats struct {
+	// Requests is the number of messages sent to the draft model.
+	Requests int
+	// Escalations is the number of those that were escalated.
+	Escalations int
+	// Reasons counts the escalations by reason.
+	Reasons map[EscalationReason]int
+}
+
+// Rate returns the fraction of requests that were escalated.
+func (s EscalationStats) Rate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Escalations) / float64(s.Requests)
+}
+
+// escalationCounter accumulates a route's EscalationStats across its
+// clients.
+type escalationCounter struct {
+	mu    sync.Mutex
+	stats EscalationStats
+}
+
+func (c *escalationCounter) record(reason EscalationReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Requests++
+	if reason == "" {
+		return
+	}
+	c.stats.Escalations++
+	if c.stats.Reasons == nil {
+		c.stats.Reasons = make(map[EscalationReason]int)
+	}
+	c.stats.Reasons[reason]++
+}
+
+func (c *escalationCounter) snapshot() EscalationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Reasons = maps.Clone(stats.Reasons)
+	return stats
+}
+
+// refusalPrefixes are how draft responses that refuse a request usually
+// start, in lower case.
+var refusalPrefixes = []string{
+	"i can't",
+	"i cannot",
+	"i can’t",
+	"i'm unable",
+	"i am unable",
+	"i'm not able",
+	"i am not able",
+	"i'm sorry, but",
+	"sorry, but i",
+	"i apologize, but",
+}
+
+// check returns why resp should be escalated, or "" if it shouldn't be.
+func (e *Escalation) check(resp chat.Message) EscalationReason {
+	text := strings.TrimSpace(resp.GetText())
+	if e.MinLength > 0 && len(text) < e.MinLength {
+		return EscalatedLength
+	}
+	if e.Refusals {
+		lower := strings.ToLower(text)
+		for _, prefix := range refusalPrefixes {
+			if strings.HasPrefix(lower, prefix) {
+				return EscalatedRefusal
+			}
+		}
+	}
+	if e.Validator != nil {
+		if err := e.Validator(resp); err != nil {
+			return EscalatedValidator
+		}
+	}
+	return ""
+}
+
+// EscalateToolName is the name of the tool registered on draft models by
+// Escalation.Tool.
+const EscalateToolName = "escalate"
+
+// escalateTool records whether the draft model asked to escalate.
+type escalateTool struct {
+	called atomic.Bool
+}
+
+func (t *escalateTool) Name() string {
+	return EscalateToolName
+}
+
+func (t *escalateTool) Description() string {
+	return "Hand this request to a more capable model. Call this instead of answering when the request is beyond you, such as when it needs careful reasoning or specialized knowledge you lack."
+}
+
+func (t *escalateTool) MCPJsonSchema() string {
+	return `{"name":"escalate","description":"Hand this request to a more capable model.","inputSchema":{"type":"object","properties":{}}}`
+}
+
+func (t *escalateTool) Call(ctx context.Context, input string) string {
+	t.called.Store(true)
+	return `{"status":"escalated"}`
+}
+
+// draftMessage sends msg to a new chat on the draft model, continuing the
+// conversation so far, and keeps its response unless it must be escalated.
+func (c *routedChat) draftMessage(ctx context.Context, msg chat.Message, opts []chat.Option) (chat.Message, error) {
+	current, _ := c.current()
+	systemPrompt, history := current.History()
+
+	draft := c.newTargetChat(0, systemPrompt, history)
+	var tool *escalateTool
+	if c.client.escalation.Tool {
+		tool = &escalateTool{}
+		if err := draft.RegisterTool(tool); err != nil {
+			logger.WarnContext(ctx, "failed to register escalate tool on draft model", "route", c.client.route, "error", err)
+		}
+	}
+
+	resp, err := draft.Message(ctx, msg, opts...)
+	if err != nil && (ctx.Err() != nil || isDryRun(err)) {
+		return resp, err
+	}
+	var reason EscalationReason
+	switch {
+	case err != nil:
+		reason = EscalatedError
+	case tool != nil && tool.called.Load():
+		reason = EscalatedTool
+	default:
+		reason = c.client.escalation.check(resp)
+	}
+	c.client.escalations.record(reason)
+
+	if reason == "" {
+		c.adopt(current, draft, 0, nil)
+		return resp, nil
+	}
+
+	logger.DebugContext(ctx, "escalating draft response", "route", c.client.route, "reason", reason, "error", err)
+	var discarded []chat.TokenUsageDetails
+	if usage, err := draft.TokenUsage(); err == nil {
+		discarded = usageRounds(usage)
+	}
+	escalated, target := c.adopt(current, c.newTargetChat(1, systemPrompt, history), 1, discarded)
+	return c.fallbackMessage(ctx, msg, opts, escalated, target)
+}
+
+// adopt makes next, a chat on target, the conversation's chat if prev still
+// is. discarded is the usage of requests made for the last message by chats
+// that weren't adopted. It returns the conversation's chat and its target.
+func (c *routedChat) adopt(prev, next chat.Chat, target int, discarded []chat.TokenUsageDetails) (chat.Chat, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.chat != prev {
+		return c.chat, c.target
+	}
+	c.chat, c.target, c.discarded = next, target, discarded
+	return c.chat, c.target
+}
+
+// usageRounds returns the usage of each request in usage's last exchange.
+func usageRounds(usage chat.TokenUsage) []chat.TokenUsageDetails {
+	if len(usage.Rounds) == 0 && usage.LastMessage.TotalTokens > 0 {
+		return []chat.TokenUsageDetails{usage.LastMessage}
+	}
+	return usage.Rounds
+}
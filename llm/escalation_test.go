@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestRouterEscalation(t *testing.T) {
+	t.Parallel()
+
+	r, clients := stubRouter()
+	r.SetRoute("auto", Route{
+		Targets:    []Target{{Model: "I can't help with that"}, {Model: "expert"}},
+		Escalation: &Escalation{Refusals: true},
+	})
+	r.SetRoute("cheap", Route{
+		Targets:    []Target{{Model: "draft answer"}, {Model: "expert"}},
+		Escalation: &Escalation{Refusals: true, MinLength: 5},
+	})
+
+	// Draft responses that pass the checks are kept
+	client, err := r.NewClient(&Config{Model: "cheap"})
+	require.NoError(t, err)
+	c := client.NewChat("system")
+	resp, err := c.Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "draft answer", resp.GetText())
+	assert.Empty(t, clients["expert"].chats)
+	assert.Equal(t, EscalationStats{Requests: 1}, r.EscalationStats("cheap"))
+
+	// Refusals are escalated, and the next message starts on the draft
+	// model again with the expert's answer in its history
+	client, err = r.NewClient(&Config{Model: "auto"})
+	require.NoError(t, err)
+	c = client.NewChat("system", chat.UserMessage("earlier"))
+	require.NoError(t, c.RegisterTool(stubTool("search")))
+	resp, err = c.Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "expert", resp.GetText())
+
+	expert := clients["expert"].chats[0]
+	assert.Equal(t, []chat.Message{chat.UserMessage("earlier")}, expert.initial)
+	assert.Equal(t, []string{"search"}, expert.tools)
+	_, history := c.History()
+	assert.Len(t, history, 3)
+
+	_, err = c.Message(context.Background(), chat.UserMessage("again"))
+	require.NoError(t, err)
+	// Each message gets a new draft chat, after the one NewChat started with
+	drafts := clients["I can't help with that"].chats
+	require.Len(t, drafts, 3)
+	assert.Equal(t, history, drafts[2].initial)
+
+	stats := r.EscalationStats("auto")
+	assert.Equal(t, EscalationStats{
+		Requests:    2,
+		Escalations: 2,
+		Reasons:     map[EscalationReason]int{EscalatedRefusal: 2},
+	}, stats)
+	assert.Equal(t, 1.0, stats.Rate())
+	assert.Equal(t, 0.0, r.EscalationStats("unknown").Rate())
+}
+
+func TestRouterEscalationChecks(t *testing.T) {
+	t.Parallel()
+
+	errTooVague := errors.New("too vague")
+	for _, tt := range []struct {
+		name       string
+		draft      string
+		failing    []string
+		escalation Escalation
+		want       EscalationReason
+	}{
+		{name: "Error", draft: "draft", failing: []string{"draft"}, want: EscalatedError},
+		{name: "Length", draft: "ok", escalation: Escalation{MinLength: 3}, want: EscalatedLength},
+		{name: "Refusal", draft: "I'm sorry, but no", escalation: Escalation{Refusals: true}, want: EscalatedRefusal},
+		{name: "NotRefusal", draft: "Sure, I can't wait", escalation: Escalation{Refusals: true}},
+		{
+			name:  "Validator",
+			draft: "maybe",
+			escalation: Escalation{Validator: func(msg chat.Message) error {
+				if msg.GetText() == "maybe" {
+					return errTooVague
+				}
+				return nil
+			}},
+			want: EscalatedValidator,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, _ := stubRouter(tt.failing...)
+			r.SetRoute("auto", Route{
+				Targets:    []Target{{Model: tt.draft}, {Model: "expert"}},
+				Escalation: &tt.escalation,
+			})
+			client, err := r.NewClient(&Config{Model: "auto"})
+			require.NoError(t, err)
+			resp, err := client.NewChat("system").Message(context.Background(), chat.UserMessage("hi"))
+			require.NoError(t, err)
+
+			want := EscalationStats{Requests: 1}
+			if tt.want != "" {
+				assert.Equal(t, "expert", resp.GetText())
+				want.Escalations = 1
+				want.Reasons = map[EscalationReason]int{tt.want: 1}
+			} else {
+				assert.Equal(t, tt.draft, resp.GetText())
+			}
+			assert.Equal(t, want, r.EscalationStats("auto"))
+		})
+	}
+}
+
+// escalatingChat is a draft model that calls the escalate tool.
+type escalatingChat struct {
+	stubChat
+	escalate chat.Tool
+}
+
+func (c *escalatingChat) RegisterTool(tool chat.Tool) error {
+	if tool.Name() == EscalateToolName {
+		c.escalate = tool
+	}
+	return c.stubChat.RegisterTool(tool)
+}
+
+func (c *escalatingChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	if c.escalate != nil {
+		c.escalate.Call(ctx, "{}")
+	}
+	return c.stubChat.Message(ctx, msg, opts...)
+}
+
+func (c *escalatingChat) TokenUsage() (chat.TokenUsage, error) {
+	return chat.TokenUsage{LastMessage: chat.TokenUsageDetails{InputTokens: 5, OutputTokens: 2, TotalTokens: 7}}, nil
+}
+
+type escalatingClient struct{}
+
+func (escalatingClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	return &escalatingChat{stubChat: stubChat{model: "draft", initial: initialMsgs}}
+}
+
+func TestRouterEscalationTool(t *testing.T) {
+	t.Parallel()
+
+	r, _ := stubRouter()
+	stubNewClient := r.newClient
+	r.newClient = func(config *Config) (chat.Client, error) {
+		if config.Model == "draft" {
+			return escalatingClient{}, nil
+		}
+		return stubNewClient(config)
+	}
+	r.SetRoute("auto", Route{
+		Targets:    []Target{{Model: "draft"}, {Model: "expert"}},
+		Escalation: &Escalation{Tool: true},
+	})
+
+	client, err := r.NewClient(&Config{Model: "auto"})
+	require.NoError(t, err)
+	c := client.NewChat("system")
+	resp, err := c.Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "expert", resp.GetText())
+	assert.Equal(t, map[EscalationReason]int{EscalatedTool: 1}, r.EscalationStats("auto").Reasons)
+
+	// The draft's usage is reported with the expert's
+	usage, err := c.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, []chat.TokenUsageDetails{{InputTokens: 5, OutputTokens: 2, TotalTokens: 7}}, usage.Rounds)
+	assert.Equal(t, 7, usage.Cumulative.TotalTokens)
+	assert.Empty(t, c.ListTools())
+	assert.NoError(t, chat.ValidateToolDef(&escalateTool{}))
+}
+
+func TestRouterEscalationTargets(t *testing.T) {
+	t.Parallel()
+
+	r, _ := stubRouter()
+	r.SetRoute("single", Route{Targets: []Target{{Model: "draft"}}, Escalation: &Escalation{}})
+	r.SetRoute("no-draft", Route{Targets: []Target{{Model: "missing-key"}, {Model: "expert"}}, Escalation: &Escalation{MinLength: 100}})
+	r.SetRoute("no-expert", Route{Targets: []Target{{Model: "draft"}, {Model: "missing-key"}}, Escalation: &Escalation{}})
+
+	_, err := r.NewClient(&Config{Model: "single"})
+	assert.EqualError(t, err, `route "single" needs a draft target and at least one escalation target`)
+
+	// Without a usable draft model, every request goes to the expert
+	client, err := r.NewClient(&Config{Model: "no-draft"})
+	require.NoError(t, err)
+	resp, err := client.NewChat("system").Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "expert", resp.GetText())
+
+	_, err = r.NewClient(&Config{Model: "no-expert"})
+	assert.ErrorContains(t, err, `no usable targets for route "no-expert"`)
+}
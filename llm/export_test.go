@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// toolConversation is a turn where the assistant calls a tool and answers.
+var toolConversation = []chat.Message{
+	chat.UserMessage("What's the weather?"),
+	{Role: chat.AssistantRole, Contents: []chat.Content{
+		{ToolCall: &chat.ToolCall{ID: "call_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}},
+	}},
+	{Role: chat.ToolRole, Contents: []chat.Content{
+		{ToolResult: &chat.ToolResult{ToolCallID: "call_1", Name: "weather", Content: "sunny"}},
+	}},
+	chat.AssistantMessage("It's sunny."),
+}
+
+func TestExportMessages(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"anthropic", "google", "openai"}, chat.MessageExporters())
+
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{
+			provider: "openai",
+			want: `[
+				{"role": "user", "content": "What's the weather?"},
+				{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+				{"role": "tool", "tool_call_id": "call_1", "content": "sunny"},
+				{"role": "assistant", "content": "It's sunny."}
+			]`,
+		},
+		{
+			provider: "anthropic",
+			want: `[
+				{"role": "user", "content": [{"type": "text", "text": "What's the weather?"}]},
+				{"role": "assistant", "content": [{"type": "tool_use", "id": "call_1", "name": "weather", "input": {"city": "Paris"}}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "call_1", "is_error": false, "content": [{"type": "text", "text": "sunny"}]}]},
+				{"role": "assistant", "content": [{"type": "text", "text": "It's sunny."}]}
+			]`,
+		},
+		{
+			provider: "google",
+			want: `[
+				{"role": "user", "parts": [{"text": "What's the weather?"}]},
+				{"role": "model", "parts": [{"functionCall": {"id": "call_1", "name": "weather", "args": {"city": "Paris"}}}]},
+				{"role": "function", "parts": [{"functionResponse": {"id": "call_1", "name": "weather", "response": {"result": "sunny"}}}]},
+				{"role": "model", "parts": [{"text": "It's sunny."}]}
+			]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := chat.ExportMessages(tt.provider, toolConversation)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestExportMessagesError(t *testing.T) {
+	t.Parallel()
+
+	_, err := chat.ExportMessages("openai", []chat.Message{chat.UserMessage("hi"), {Role: chat.UserRole}})
+	assert.ErrorContains(t, err, "converting message 1: message has no contents")
+}
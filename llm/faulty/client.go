@@ -0,0 +1,80 @@
+package faulty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm"
+)
+
+// Client is a chat.Client whose requests go through a local proxy that
+// injects faults. It must be closed to stop the proxy.
+type Client struct {
+	chat.Client
+
+	transport *Transport
+	server    *http.Server
+}
+
+// NewClient returns a client for config whose requests go through a proxy
+// on the loopback interface injecting faults, one per request in order,
+// before being sent to config.BaseURL, which must be set. The client is
+// created by llm.NewClient, so the provider's SDK and stream handling,
+// including its retries, see the faults as they would from the network.
+func NewClient(config *llm.Config, faults ...Fault) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("the config's BaseURL must be set to the upstream API")
+	}
+	upstream, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base URL: %w", err)
+	}
+
+	transport := &Transport{Faults: faults}
+	target := &url.URL{Scheme: upstream.Scheme, Host: upstream.Host}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+		},
+		Transport: transport,
+		// Stream events through as they arrive
+		FlushInterval: -1,
+		// Injected disconnects aren't worth logging
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start proxy: %w", err)
+	}
+	server := &http.Server{Handler: proxy, ErrorLog: proxy.ErrorLog}
+	go server.Serve(ln)
+
+	proxied := *config
+	proxied.BaseURL = "http://" + ln.Addr().String() + strings.TrimSuffix(upstream.Path, "/")
+	client, err := llm.NewClient(&proxied)
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	return &Client{Client: client, transport: transport, server: server}, nil
+}
+
+// Requests returns the number of requests the client has made, including
+// those answered with injected rate limits.
+func (c *Client) Requests() int {
+	return c.transport.Requests()
+}
+
+// Close stops the proxy.
+func (c *Client) Close() error {
+	return c.server.Close()
+}
@@ -0,0 +1,249 @@
+// Package faulty injects failures into the HTTP traffic between a client and
+// an LLM provider — rate limits, dropped connections, malformed stream
+// chunks, and slow streams — to test retries, failover, and stall detection
+// end to end. Faults are scripted, one per request in order, so tests are
+// deterministic:
+//
+//	client, err := faulty.NewClient(&llm.Config{Model: "gpt-4o", BaseURL: server.URL},
+//		faulty.Fault{Kind: faulty.RateLimit, RetryAfter: 10 * time.Millisecond},
+//		faulty.Fault{Kind: faulty.Disconnect, AfterEvents: 2},
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+package faulty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is a kind of fault.
+type Kind int
+
+const (
+	// None passes the request through unchanged.
+	None Kind = iota
+	// RateLimit answers the request with 429 Too Many Requests, with
+	// Retry-After and retry-after-ms headers of Fault.RetryAfter, without
+	// sending it upstream.
+	RateLimit
+	// Disconnect drops the connection after Fault.AfterEvents server-sent
+	// events of the response.
+	Disconnect
+	// MalformedChunk truncates the data of the response's server-sent event
+	// after Fault.AfterEvents, so it isn't valid JSON.
+	MalformedChunk
+	// SlowStream delays each server-sent event of the response by
+	// Fault.Delay.
+	SlowStream
+)
+
+func (k Kind) String() string {
+	switch k {
+	case None:
+		return "none"
+	case RateLimit:
+		return "rate limit"
+	case Disconnect:
+		return "disconnect"
+	case MalformedChunk:
+		return "malformed chunk"
+	case SlowStream:
+		return "slow stream"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Fault is a failure injected into one request.
+type Fault struct {
+	Kind Kind
+	// RetryAfter is how long a RateLimit response asks the client to wait.
+	// If zero, the response has no Retry-After headers.
+	RetryAfter time.Duration
+	// AfterEvents is how many of the response's events a Disconnect or
+	// MalformedChunk fault passes through first.
+	AfterEvents int
+	// Delay is how long a SlowStream fault holds back each event.
+	Delay time.Duration
+}
+
+// Transport is an http.RoundTripper that injects Faults[i] into the i-th
+// request it sends with Base, or http.DefaultTransport if Base is nil.
+// Requests after the last fault pass through unchanged.
+type Transport struct {
+	Base   http.RoundTripper
+	Faults []Fault
+
+	mu       sync.Mutex
+	requests int
+}
+
+// Requests returns the number of requests the transport has handled,
+// including those answered with injected rate limits.
+func (t *Transport) Requests() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.requests
+}
+
+// nextFault returns the fault for the next request.
+func (t *Transport) nextFault() Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var f Fault
+	if t.requests < len(t.Faults) {
+		f = t.Faults[t.requests]
+	}
+	t.requests++
+	return f
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.nextFault()
+	if fault.Kind == RateLimit {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return rateLimited(req, fault.RetryAfter), nil
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || fault.Kind == None || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	// The body's length changes with its events
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Body = &faultyBody{
+		ctx:    req.Context(),
+		r:      bufio.NewReader(resp.Body),
+		closer: resp.Body,
+		fault:  fault,
+	}
+	return resp, nil
+}
+
+// rateLimited returns a 429 response to req.
+func rateLimited(req *http.Request, retryAfter time.Duration) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if retryAfter > 0 {
+		header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		header.Set("Retry-After-Ms", strconv.FormatInt(retryAfter.Milliseconds(), 10))
+	}
+	body := `{"error": {"type": "rate_limit_error", "code": 429, "message": "rate limit injected by faulty"}}`
+	return &http.Response{
+		Status:        "429 Too Many Requests",
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// faultyBody reads a server-sent event stream one event at a time,
+// injecting its fault.
+type faultyBody struct {
+	ctx    context.Context
+	r      *bufio.Reader
+	closer io.Closer
+	fault  Fault
+
+	events  int
+	pending []byte
+	err     error
+}
+
+func (b *faultyBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.next()
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// next reads the next event into pending, applying the fault to it.
+func (b *faultyBody) next() {
+	if b.fault.Kind == Disconnect && b.events == b.fault.AfterEvents {
+		b.err = io.ErrUnexpectedEOF
+		return
+	}
+	if b.fault.Kind == SlowStream && b.fault.Delay > 0 {
+		timer := time.NewTimer(b.fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-b.ctx.Done():
+			b.err = b.ctx.Err()
+			return
+		}
+	}
+
+	event, err := readEvent(b.r)
+	if b.fault.Kind == MalformedChunk && b.events == b.fault.AfterEvents {
+		event = truncateData(event)
+	}
+	b.events++
+	b.pending, b.err = event, err
+}
+
+// readEvent reads a server-sent event, up to and including the blank line
+// that ends it.
+func readEvent(r *bufio.Reader) ([]byte, error) {
+	var event []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		event = append(event, line...)
+		if err != nil {
+			return event, err
+		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 && len(event) > len(line) {
+			return event, nil
+		}
+	}
+}
+
+// truncateData cuts each data line of event in half.
+func truncateData(event []byte) []byte {
+	var out []byte
+	for _, line := range bytes.SplitAfter(event, []byte("\n")) {
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(data)
+			line = fmt.Appendf(nil, "data: %s\n", data[:len(data)/2])
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
+func (b *faultyBody) Close() error {
+	return b.closer.Close()
+}
@@ -0,0 +1,179 @@
+package faulty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm"
+)
+
+// upstream returns a server streaming "Hello there, friend." as a Chat
+// Completions response in four chunks, and the number of requests it got.
+func upstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hello", " there", ", friend", "."} {
+			fmt.Fprintf(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%q}}]}\n\n", text)
+		}
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newClient(t *testing.T, baseURL string, faults ...Fault) *Client {
+	t.Helper()
+
+	client, err := NewClient(&llm.Config{Provider: "openai", Model: "gpt-4o", APIKey: "test-key", BaseURL: baseURL + "/v1", LogLevel: -1}, faults...)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// message sends a message on a new chat, returning the response's text and
+// the text streamed.
+func message(client chat.Client, opts ...chat.Option) (string, string, error) {
+	var streamed strings.Builder
+	opts = append(opts, chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		if event.Type == chat.StreamEventTypeContent {
+			streamed.WriteString(event.Content)
+		}
+		return nil
+	}))
+	resp, err := client.NewChat("").Message(context.Background(), chat.UserMessage("Hi"), opts...)
+	return resp.GetText(), streamed.String(), err
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	server, upstreamRequests := upstream(t)
+	client := newClient(t, server.URL, Fault{Kind: RateLimit, RetryAfter: 10 * time.Millisecond})
+
+	// The SDK retries after the rate limit
+	text, _, err := message(client)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, friend.", text)
+	assert.Equal(t, 2, client.Requests())
+	assert.Equal(t, int32(1), upstreamRequests.Load())
+}
+
+func TestDisconnect(t *testing.T) {
+	t.Parallel()
+
+	server, _ := upstream(t)
+	client := newClient(t, server.URL, Fault{Kind: Disconnect, AfterEvents: 2})
+
+	_, streamed, err := message(client)
+	assert.Error(t, err)
+	assert.Equal(t, "Hello there", streamed)
+
+	// Later requests aren't faulted
+	text, _, err := message(client)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, friend.", text)
+}
+
+func TestMalformedChunk(t *testing.T) {
+	t.Parallel()
+
+	server, _ := upstream(t)
+	client := newClient(t, server.URL, Fault{Kind: MalformedChunk, AfterEvents: 1})
+
+	_, streamed, err := message(client)
+	assert.Error(t, err)
+	assert.Equal(t, "Hello", streamed)
+}
+
+func TestSlowStream(t *testing.T) {
+	t.Parallel()
+
+	server, _ := upstream(t)
+	client := newClient(t, server.URL, Fault{Kind: SlowStream, Delay: 50 * time.Millisecond})
+
+	var progress atomic.Int32
+	resp, err := client.NewChat("").Message(context.Background(), chat.UserMessage("Hi"),
+		chat.WithProgressEvents(10*time.Millisecond),
+		chat.WithStreamingCb(func(event chat.StreamEvent) error {
+			if event.Type == chat.StreamEventTypeProgress {
+				progress.Add(1)
+			}
+			return nil
+		}))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, friend.", resp.GetText())
+	assert.Positive(t, progress.Load(), "the stalled stream should send progress events")
+}
+
+// failoverTarget is the last client created for the "faulty-test"
+// provider, which disconnects from its first request.
+var (
+	registerFailoverTarget sync.Once
+	failoverTarget         atomic.Pointer[Client]
+)
+
+func TestFailover(t *testing.T) {
+	t.Parallel()
+
+	server, upstreamRequests := upstream(t)
+	registerFailoverTarget.Do(func() {
+		llm.RegisterProvider("faulty-test", func(config *llm.Config) (chat.Client, error) {
+			c := *config
+			c.Provider = "openai"
+			client, err := NewClient(&c, Fault{Kind: Disconnect, AfterEvents: 1})
+			if err != nil {
+				return nil, err
+			}
+			failoverTarget.Store(client)
+			return client, nil
+		})
+	})
+
+	router := llm.NewRouter()
+	router.SetRoute("smart", llm.Route{Targets: []llm.Target{
+		{Model: "gpt-4o", Provider: "faulty-test"},
+		{Model: "gpt-4o", Provider: "openai"},
+	}})
+	client, err := router.NewClient(&llm.Config{Model: "smart", APIKey: "test-key", BaseURL: server.URL + "/v1", LogLevel: -1})
+	require.NoError(t, err)
+	broken := failoverTarget.Load()
+	t.Cleanup(func() { broken.Close() })
+
+	// The route falls back to the second target when the first disconnects
+	text, _, err := message(client)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, friend.", text)
+	assert.Equal(t, 1, broken.Requests())
+	assert.Equal(t, int32(2), upstreamRequests.Load())
+}
+
+func TestNewClientRequiresBaseURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewClient(&llm.Config{Model: "gpt-4o", APIKey: "test-key"})
+	assert.Error(t, err)
+}
+
+func TestKindString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "malformed chunk", MalformedChunk.String())
+	assert.Equal(t, "Kind(9)", Kind(9).String())
+}
@@ -0,0 +1,59 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+var _ chat.ContextCacher = &client{}
+
+// CreateContextCache implements chat.ContextCacher, creating cached content
+// holding config's system prompt and tools. Gemini only caches contexts
+// above a minimum size, which depends on the model, and rejects smaller
+// ones.
+func (c *client) CreateContextCache(ctx context.Context, config chat.ContextCacheConfig) (*chat.ContextCache, error) {
+	cacheConfig := &genai.CreateCachedContentConfig{TTL: config.TTL}
+	if c.baseURL != "" {
+		cacheConfig.HTTPOptions = &genai.HTTPOptions{BaseURL: c.baseURL}
+	}
+	if config.SystemPrompt != "" {
+		cacheConfig.SystemInstruction = &genai.Content{
+			Parts: []*genai.Part{{Text: config.SystemPrompt}},
+		}
+	}
+	if len(config.Tools) > 0 {
+		functionDeclarations := make([]*genai.FunctionDeclaration, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			funcDecl, err := mcpToGeminiFunctionDeclaration(tool)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert tool: %w", err)
+			}
+			functionDeclarations = append(functionDeclarations, funcDecl)
+		}
+		cacheConfig.Tools = []*genai.Tool{{FunctionDeclarations: functionDeclarations}}
+	}
+
+	cached, err := c.genaiClient.Caches.Create(ctx, c.modelName, cacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating cached content: %w", err)
+	}
+	return &chat.ContextCache{
+		Provider:     providerName,
+		Name:         cached.Name,
+		SystemPrompt: config.SystemPrompt,
+		ExpireTime:   cached.ExpireTime,
+	}, nil
+}
+
+// cachedContentName returns the cached content name of the request's
+// context cache, if Gemini created it.
+func cachedContentName(opts chat.Options) (string, bool) {
+	if opts.ContextCache == nil || opts.ContextCache.Provider != providerName {
+		return "", false
+	}
+	return opts.ContextCache.Name, true
+}
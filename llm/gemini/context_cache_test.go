@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestGemini_ContextCache(t *testing.T) {
+	var cacheRequest, generateRequest map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.HasSuffix(r.URL.Path, "/cachedContents") {
+			require.NoError(t, json.Unmarshal(body, &cacheRequest))
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name":"cachedContents/abc","model":"models/gemini-2.5-flash","expireTime":"2026-01-01T00:00:00Z"}`)
+			return
+		}
+		require.NoError(t, json.Unmarshal(body, &generateRequest))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Done"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":2000,"candidatesTokenCount":1,"totalTokenCount":2001,"cachedContentTokenCount":1900}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL(server.URL))
+	require.NoError(t, err)
+	c := newEchoChat(t, client)
+
+	cacher, ok := client.(chat.ContextCacher)
+	require.True(t, ok)
+	cache, err := cacher.CreateContextCache(context.Background(), chat.ContextCacheConfig{
+		SystemPrompt: "A long corpus",
+		Tools:        []chat.ToolDef{&testTool{name: "echo", jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`}},
+		TTL:          time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &chat.ContextCache{
+		Provider:     "gemini",
+		Name:         "cachedContents/abc",
+		SystemPrompt: "A long corpus",
+		ExpireTime:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, cache)
+	assert.Equal(t, "3600s", cacheRequest["ttl"])
+	assert.Contains(t, fmt.Sprint(cacheRequest["systemInstruction"]), "A long corpus")
+	assert.Contains(t, fmt.Sprint(cacheRequest["tools"]), "echo")
+
+	// The request uses the cache in place of the system prompt and tools
+	_, err = c.Message(context.Background(), chat.UserMessage("Hello"), chat.WithContextCache(cache))
+	require.NoError(t, err)
+	assert.Equal(t, "cachedContents/abc", generateRequest["cachedContent"])
+	assert.NotContains(t, generateRequest, "tools")
+	assert.NotContains(t, fmt.Sprint(generateRequest["contents"]), "System")
+
+	usage, err := c.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 1900, usage.LastMessage.CachedTokens)
+}
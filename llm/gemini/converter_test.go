@@ -9,6 +9,8 @@ import (
 	"google.golang.org/genai"
 
 	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/internal/common"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
 )
 
 func TestMessageToGemini(t *testing.T) {
@@ -213,6 +215,89 @@ func TestMessageToGemini(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "user message with image",
+			msg: chat.Message{
+				Role: chat.UserRole,
+				Contents: []chat.Content{
+					{Text: "What is in this screenshot?"},
+					{Image: &chat.ImageContent{MediaType: "image/png", Data: []byte("png")}},
+				},
+			},
+			want: []*genai.Content{
+				{
+					Role: "user",
+					Parts: []*genai.Part{
+						{Text: "What is in this screenshot?"},
+						{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("png")}},
+					},
+				},
+			},
+		},
+		{
+			name: "tool role message with image result",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "call_123",
+							Name:       "ReadImage",
+							Content:    `{"mediaType":"image/png"}`,
+							Images:     []chat.ImageContent{{MediaType: "image/png", Data: []byte("png")}},
+						},
+					},
+				},
+			},
+			want: []*genai.Content{
+				{
+					Role: "function",
+					Parts: []*genai.Part{
+						{
+							FunctionResponse: &genai.FunctionResponse{
+								ID:       "call_123",
+								Name:     "ReadImage",
+								Response: map[string]any{"mediaType": "image/png"},
+							},
+						},
+						{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("png")}},
+					},
+				},
+			},
+		},
+		{
+			name: "tool role message with system reminder",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "tool_123",
+							Name:       "get_weather",
+							Content:    `{"temperature": "22C"}`,
+						},
+					},
+					{SystemReminder: "<system-reminder>Be brief.</system-reminder>"},
+				},
+			},
+			want: []*genai.Content{
+				{
+					Role: "function",
+					Parts: []*genai.Part{
+						{
+							FunctionResponse: &genai.FunctionResponse{
+								ID:   "tool_123",
+								Name: "get_weather",
+								Response: map[string]any{
+									"temperature": "22C",
+								},
+							},
+						},
+						{Text: "<system-reminder>Be brief.</system-reminder>"},
+					},
+				},
+			},
+		},
 		{
 			name: "tool role message with error result",
 			msg: chat.Message{
@@ -400,6 +485,16 @@ func TestMessageToGemini(t *testing.T) {
 			},
 			want: nil, // Empty assistant messages return nil
 		},
+		{
+			name: "assistant message with thinking from another provider drops it",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Thinking: &chat.ThinkingContent{Text: "Reasoning", Signature: "sig", Provider: "claude"}},
+				},
+			},
+			want: nil,
+		},
 		{
 			name: "tool role with no results returns error",
 			msg: chat.Message{
@@ -1222,6 +1317,149 @@ func TestJsonSchemaToGeminiSchema(t *testing.T) {
 				Enum:        []string{"pending", "active", "completed"},
 			},
 		},
+		{
+			name: "integer enum",
+			jsonSchema: map[string]interface{}{
+				"type":        "integer",
+				"description": "Priority",
+				"enum":        []interface{}{float64(3), float64(1), float64(2)},
+			},
+			want: &genai.Schema{
+				Type:        genai.TypeInteger,
+				Description: "Priority. Must be one of: 3, 1, 2.",
+				Minimum:     genai.Ptr(1.0),
+				Maximum:     genai.Ptr(3.0),
+			},
+		},
+		{
+			name: "nullable string enum",
+			jsonSchema: map[string]interface{}{
+				"type": "string",
+				"enum": []interface{}{"fast", "slow", nil},
+			},
+			want: &genai.Schema{
+				Type: genai.TypeString,
+				Enum: []string{"fast", "slow"},
+			},
+		},
+		{
+			name: "string const",
+			jsonSchema: map[string]interface{}{
+				"const": "event",
+			},
+			want: &genai.Schema{
+				Type: genai.TypeString,
+				Enum: []string{"event"},
+			},
+		},
+		{
+			name: "number const",
+			jsonSchema: map[string]interface{}{
+				"type":  "number",
+				"const": 0.5,
+			},
+			want: &genai.Schema{
+				Type:    genai.TypeNumber,
+				Minimum: genai.Ptr(0.5),
+				Maximum: genai.Ptr(0.5),
+			},
+		},
+		{
+			name: "boolean const",
+			jsonSchema: map[string]interface{}{
+				"type":  "boolean",
+				"const": true,
+			},
+			want: &genai.Schema{
+				Type:        genai.TypeBoolean,
+				Description: "Must be true.",
+			},
+		},
+		{
+			name: "nullable type union",
+			jsonSchema: map[string]interface{}{
+				"type":        []interface{}{"string", "null"},
+				"description": "An optional note",
+			},
+			want: &genai.Schema{
+				Type:        genai.TypeString,
+				Nullable:    genai.Ptr(true),
+				Description: "An optional note",
+			},
+		},
+		{
+			name: "multiple type union",
+			jsonSchema: map[string]interface{}{
+				"type": []interface{}{"string", "integer"},
+			},
+			want: &genai.Schema{
+				AnyOf: []*genai.Schema{
+					{Type: genai.TypeString},
+					{Type: genai.TypeInteger},
+				},
+			},
+		},
+		{
+			name: "nullable object with anyOf",
+			jsonSchema: map[string]interface{}{
+				"description": "Optional settings",
+				"anyOf": []interface{}{
+					map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"depth": map[string]interface{}{"type": "integer"},
+						},
+						"required": []interface{}{"depth"},
+					},
+					map[string]interface{}{"type": "null"},
+				},
+			},
+			want: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"depth": {Type: genai.TypeInteger},
+				},
+				Required:    []string{"depth"},
+				Nullable:    genai.Ptr(true),
+				Description: "Optional settings",
+			},
+		},
+		{
+			name: "anyOf alternatives",
+			jsonSchema: map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+			},
+			want: &genai.Schema{
+				AnyOf: []*genai.Schema{
+					{Type: genai.TypeString},
+					{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+				},
+			},
+		},
+		{
+			name: "format and limits",
+			jsonSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"when":  map[string]interface{}{"type": "string", "format": "date-time"},
+					"count": map[string]interface{}{"type": "integer", "format": "int32", "minimum": float64(1), "maximum": float64(10)},
+					"code":  map[string]interface{}{"type": "string", "minLength": float64(2), "maxLength": float64(8), "pattern": "^[A-Z]+$"},
+					"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "minItems": float64(1), "maxItems": float64(5)},
+				},
+			},
+			want: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"when":  {Type: genai.TypeString, Format: "date-time"},
+					"count": {Type: genai.TypeInteger, Format: "int32", Minimum: genai.Ptr(1.0), Maximum: genai.Ptr(10.0)},
+					"code":  {Type: genai.TypeString, MinLength: genai.Ptr[int64](2), MaxLength: genai.Ptr[int64](8), Pattern: "^[A-Z]+$"},
+					"tags":  {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}, MinItems: genai.Ptr[int64](1), MaxItems: genai.Ptr[int64](5)},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1241,3 +1479,42 @@ func TestJsonSchemaToGeminiSchema(t *testing.T) {
 		})
 	}
 }
+
+// BenchmarkHistoryConversion measures converting history for every request
+// of a 200-turn session, with and without the conversion cache.
+func BenchmarkHistoryConversion(b *testing.B) {
+	const turns = 200
+	var turnMsgs [][]chat.Message
+	for i := range turns {
+		turnMsgs = append(turnMsgs, llmtesting.SessionTurn(i))
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				for _, msg := range history {
+					if _, err := historyContent(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var cache common.ConversionCache[*genai.Content]
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				if _, err := cache.Convert(history, historyContent); err != nil {
+					b.Fatal(err)
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+}
@@ -2,13 +2,14 @@ package gemini
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 
 	"google.golang.org/genai"
 
@@ -23,22 +24,28 @@ import (
 //   - Debug: Stream events, tool calls, token updates, raw API data
 //   - Warn: Missing token usage, unknown models, fallback behavior
 //   - Error: Should never occur (indicates bugs)
-var logger = logging.Logger().With("provider", "gemini")
+var logger = logging.Logger().With("provider", providerName)
+
+// providerName identifies this provider in logs and in chat.ThinkingContent.
+const providerName = "gemini"
 
 type client struct {
-	genaiClient *genai.Client
-	modelName   string
-	baseURL     string
-	headers     map[string]string // Custom HTTP headers
-	logger      *slog.Logger
+	genaiClient    *genai.Client
+	modelName      string
+	baseURL        string
+	headers        map[string]string // Custom HTTP headers
+	thinkingBudget *int32            // nil leaves thinking at the model's default
+	ids            chat.IDGenerator  // nil numbers each chat's function calls separately
+	rawEventTap    common.RawEventTap
+	logger         *slog.Logger
 }
 
 var _ chat.Client = &client{}
 
-// generateFunctionCallID generates a unique ID for function calls
-func generateFunctionCallID() string {
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	return fmt.Sprintf("gemini_%d_%d", time.Now().Unix(), rng.Intn(1000000))
+func init() {
+	chat.RegisterMessageExporter("google", func(msgs []chat.Message) (json.RawMessage, error) {
+		return common.ExportMessages(msgs, historyContent)
+	})
 }
 
 type Option func(*client)
@@ -61,6 +68,106 @@ func WithHeaders(headers map[string]string) Option {
 	}
 }
 
+// WithThinkingBudget sets the number of tokens Gemini 2.5 models may spend
+// thinking, and requests thought summaries so they are streamed as thinking
+// events. A budget of 0 disables thinking and -1 lets the model decide.
+func WithThinkingBudget(tokens int) Option {
+	return func(c *client) {
+		budget := int32(tokens)
+		c.thinkingBudget = &budget
+	}
+}
+
+// WithIDGenerator sets the generator of IDs for function calls, which Gemini
+// usually returns without one, shared by all of the client's chats. By
+// default each chat numbers its calls within a random namespace, like
+// gemini_call_<namespace>_1, gemini_call_<namespace>_2, and so on, so IDs
+// don't collide across chats.
+func WithIDGenerator(ids chat.IDGenerator) Option {
+	return func(c *client) {
+		c.ids = ids
+	}
+}
+
+// thinkingConfig returns the thinking configuration for requests, if any.
+func (c *client) thinkingConfig() *genai.ThinkingConfig {
+	if c.thinkingBudget == nil {
+		return nil
+	}
+	budget := *c.thinkingBudget
+	return &genai.ThinkingConfig{
+		IncludeThoughts: budget != 0,
+		ThinkingBudget:  &budget,
+	}
+}
+
+// thinkingState tracks thought summaries and thought signatures across the
+// parts of a streamed response.
+type thinkingState struct {
+	text      strings.Builder
+	signature []byte
+	inThought bool
+}
+
+// observe records any thinking information in part, emitting thinking events
+// to callback. It reports whether part is a thought summary, which must not be
+// treated as response content.
+func (t *thinkingState) observe(part *genai.Part, callback chat.StreamCallback) (bool, error) {
+	// Gemini attaches a single signature to the first part that follows its thinking
+	if len(part.ThoughtSignature) > 0 && t.signature == nil {
+		t.signature = part.ThoughtSignature
+	}
+	if !part.Thought {
+		return false, t.finish(callback)
+	}
+
+	t.inThought = true
+	t.text.WriteString(part.Text)
+	if callback != nil && part.Text != "" {
+		event := chat.StreamEvent{
+			Type:           chat.StreamEventTypeThinking,
+			Content:        part.Text,
+			ThinkingStatus: &chat.ThinkingStatus{},
+		}
+		if err := callback(event); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// finish emits a thinking summary event if a thought summary was being streamed.
+func (t *thinkingState) finish(callback chat.StreamCallback) error {
+	if !t.inThought {
+		return nil
+	}
+	t.inThought = false
+	if callback == nil {
+		return nil
+	}
+	return callback(chat.StreamEvent{
+		Type: chat.StreamEventTypeThinkingSummary,
+		ThinkingStatus: &chat.ThinkingStatus{
+			Summary:   t.text.String(),
+			Signature: base64.StdEncoding.EncodeToString(t.signature),
+		},
+	})
+}
+
+// addTo appends the accumulated thinking, if any, to msg.
+func (t *thinkingState) addTo(msg *chat.Message) {
+	if t.text.Len() == 0 && t.signature == nil {
+		return
+	}
+	msg.Contents = append(msg.Contents, chat.Content{
+		Thinking: &chat.ThinkingContent{
+			Text:      t.text.String(),
+			Signature: base64.StdEncoding.EncodeToString(t.signature),
+			Provider:  providerName,
+		},
+	})
+}
+
 // BaseURL returns the base URL for testing purposes.
 // This is exported for integration testing only.
 func (c *client) BaseURL() string {
@@ -73,6 +180,41 @@ func (c *client) Headers() map[string]string {
 	return c.headers
 }
 
+var _ chat.Pinger = &client{}
+
+// Ping implements chat.Pinger by looking up the client's model, which
+// checks the API key and model without generating anything.
+func (c *client) Ping(ctx context.Context) (chat.HealthStatus, error) {
+	return common.Ping(ctx, providerName, c.modelName, func(ctx context.Context) error {
+		config := &genai.GetModelConfig{}
+		if c.baseURL != "" {
+			config.HTTPOptions = &genai.HTTPOptions{BaseURL: c.baseURL}
+		}
+		_, err := c.genaiClient.Models.Get(ctx, c.modelName, config)
+		return err
+	})
+}
+
+// WithLogger sends the client's logs to handler instead of the library's
+// global logger, so an application can route them into its own structured
+// logging, at a level independent of llm.SetLogLevel.
+func WithLogger(handler slog.Handler) Option {
+	return func(c *client) {
+		c.logger = logging.New(handler).With("provider", providerName)
+	}
+}
+
+// WithRawEventTap passes every raw server-sent event the client streams to
+// tap, with the provider's name and the event's type and data, so
+// applications can record or inspect the provider's behavior without
+// enabling debug logging. tap is called as each event is read, from the
+// goroutine streaming the response, and may keep the payload.
+func WithRawEventTap(tap func(provider, eventType string, payload []byte)) Option {
+	return func(c *client) {
+		c.rawEventTap = tap
+	}
+}
+
 // NewClient returns a chat client that can begin chat sessions with Google's Gemini API.
 func NewClient(apiKey string, opts ...Option) (chat.Client, error) {
 	c := &client{
@@ -93,9 +235,15 @@ func NewClient(apiKey string, opts ...Option) (chat.Client, error) {
 
 	ctx := context.Background()
 
+	transport := common.RequestTransport(nil)
+	if c.rawEventTap != nil {
+		transport = common.RawEventTransport(transport, providerName, c.rawEventTap)
+	}
+
 	// Build client config
 	config := &genai.ClientConfig{
-		APIKey: apiKey,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Transport: transport},
 	}
 
 	// Add custom headers if provided
@@ -117,83 +265,90 @@ func NewClient(apiKey string, opts ...Option) (chat.Client, error) {
 	return c, nil
 }
 
-// getSystemReminderText retrieves and executes system reminder function if present
-func getSystemReminderText(ctx context.Context) string {
-	if reminderFunc := chat.GetSystemReminder(ctx); reminderFunc != nil {
-		return reminderFunc()
-	}
-	return ""
-}
-
-// withPrependedSystemReminder returns a new message with system reminder prepended as first content block
-func withPrependedSystemReminder(ctx context.Context, msg chat.Message) chat.Message {
-	if reminderFunc := chat.GetSystemReminder(ctx); reminderFunc != nil {
-		if reminder := reminderFunc(); reminder != "" {
-			newContents := make([]chat.Content, 0, len(msg.Contents)+1)
-			newContents = append(newContents, chat.Content{SystemReminder: reminder})
-			newContents = append(newContents, msg.Contents...)
-			return chat.Message{Role: msg.Role, Contents: newContents}
-		}
-	}
-	return msg
-}
-
 // NewChat returns a chat instance.
 func (c client) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
 	// Determine max tokens based on model
 	maxTokens := getModelMaxTokens(c.modelName)
 
-	return &chatClient{
-		client:    c,
-		state:     common.NewState(systemPrompt, initialMsgs),
-		tools:     common.NewTools(),
-		maxTokens: maxTokens,
+	if c.ids == nil {
+		c.ids = newCallIDs(initialMsgs)
 	}
-}
 
-var modelLimits = []chat.ModelTokenLimits{
-	{Model: "gemini-2.5-pro", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 65536}},
-	{Model: "gemini-2.5-flash", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 65536}},
-	{Model: "gemini-2.5-flash-lite", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 65536}},
-	{Model: "gemini-2.0-flash", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 8192}},
-	{Model: "gemini-2.0-flash-lite", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 8192}},
-	{Model: "gemini-1.5-pro", TokenLimits: chat.TokenLimits{Context: 2097152, Output: 8192}},
-	{Model: "gemini-1.5-flash", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 8192}},
-	{Model: "gemini-1.5-flash-8b", TokenLimits: chat.TokenLimits{Context: 1048576, Output: 8192}},
+	return &chatClient{
+		client:       c,
+		state:        common.NewState(systemPrompt, initialMsgs),
+		tools:        common.NewTools(),
+		maxTokens:    maxTokens,
+		contextLimit: getModelContextLimit(c.modelName),
+	}
 }
 
 // getModelMaxTokens returns the maximum token limit for known models
 func getModelMaxTokens(model string) int {
-	modelLower := strings.ToLower(model)
-
-	for _, m := range modelLimits {
-		if strings.HasPrefix(modelLower, m.Model) {
-			return m.TokenLimits.Output
-		}
+	if m, ok := chat.LookupModel(model); ok {
+		return m.TokenLimits.Output
 	}
 
 	logger.Warn("unknown model, using conservative default output token limit", "model", model, "default_limit", 128000)
 	return 128000
 }
 
+// getModelContextLimit returns the context window size for known models, or 0
+func getModelContextLimit(model string) int {
+	m, _ := chat.LookupModel(model)
+	return m.TokenLimits.Context
+}
+
 type chatClient struct {
 	client
-	state     *common.State
-	tools     *common.Tools
-	maxTokens int
+	state        *common.State
+	history      common.ConversionCache[*genai.Content]
+	tools        *common.Tools
+	maxTokens    int
+	contextLimit int
 }
 
 func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
-	// Apply options to get callback if provided
-	appliedOpts := chat.ApplyOptions(opts...)
-	callback := appliedOpts.StreamingCb
 	reqOpts := chat.ApplyOptions(opts...)
+	ctx, finishDryRun := common.StartDryRun(ctx, reqOpts)
+	endTurn, err := c.state.BeginTurn(ctx, reqOpts.QueueTimeout)
+	if err != nil {
+		return chat.Message{}, finishDryRun(err)
+	}
+	defer endTurn()
+	ctx = c.state.TrackRequests(ctx)
+	common.StreamTurnID(ctx, &reqOpts)
+	stopProgress := common.StreamProgress(&reqOpts)
+	defer stopProgress()
+
+	resp, err := common.SendValidated(ctx, reqOpts, msg, func(ctx context.Context, msg chat.Message) (chat.Message, error) {
+		return common.SendTextTools(ctx, c.state, c.tools, reqOpts, msg, func(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+			return common.SendResumable(ctx, reqOpts, func(ctx context.Context, reqOpts chat.Options) (chat.Message, error) {
+				return c.message(ctx, msg, reqOpts)
+			})
+		})
+	})
+	if err == nil {
+		resp.Model = c.state.ResponseModel()
+	}
+	return resp, finishDryRun(err)
+}
+
+// message sends msg and handles any tool calls in the response. The caller
+// must hold the chat's turn.
+func (c *chatClient) message(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+	callback := reqOpts.StreamingCb
 
 	// Build content for all messages
 	var contents []*genai.Content
 
 	// Snapshot history with minimal lock
-	systemPrompt, history := c.state.Snapshot()
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
+	cachedContent, cached := cachedContentName(reqOpts)
+	if cached {
+		// The cache holds the system prompt
+		systemPrompt = common.RequestInstructions(reqOpts)
+	}
 
 	// Add system instruction as first content if present
 	if systemPrompt != "" {
@@ -211,18 +366,15 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 	}
 
 	// Add history messages using the new converter
-	for _, m := range history {
-		converted, err := messageToGemini(m)
-		if err != nil {
-			// Skip messages that can't be converted (e.g., system messages are handled separately)
-			continue
-		}
-		contents = append(contents, converted...)
+	historyContents, err := c.history.Convert(history, historyContent)
+	if err != nil {
+		return chat.Message{}, fmt.Errorf("converting history: %w", err)
 	}
+	contents = append(contents, historyContents...)
 
 	// Add current message with system reminder prepended if present
 	// This message (with system reminder) will be persisted for audit trail
-	msgWithReminder := withPrependedSystemReminder(ctx, msg)
+	msgWithReminder := common.WithPrependedSystemReminder(ctx, msg)
 	converted, err := messageToGemini(msgWithReminder)
 	if err != nil {
 		return chat.Message{}, fmt.Errorf("converting current message: %w", err)
@@ -248,13 +400,26 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		config.MaxOutputTokens = int32(reqOpts.MaxTokens)
 	}
 
-	// Add tools if registered
-	allTools := c.tools.GetAll()
-	if len(allTools) > 0 {
+	config.ThinkingConfig = c.thinkingConfig()
+
+	// Gemini rejects a JSON response MIME type alongside function calling
+	if reqOpts.JSONMode && len(c.tools.ForRequest(reqOpts)) == 0 {
+		config.ResponseMIMEType = "application/json"
+	}
+
+	if reqOpts.Candidates > 1 {
+		config.CandidateCount = int32(reqOpts.Candidates)
+	}
+
+	// Add tools if registered, unless the cache holds them
+	allTools := c.tools.ForRequest(reqOpts)
+	if cached {
+		config.CachedContent = cachedContent
+	} else if len(allTools) > 0 {
 		tools := make([]*genai.Tool, 0, 1)
 		functionDeclarations := make([]*genai.FunctionDeclaration, 0, len(allTools))
 		for _, tool := range allTools {
-			funcDecl, err := c.mcpToGeminiFunctionDeclaration(tool)
+			funcDecl, err := mcpToGeminiFunctionDeclaration(tool)
 			if err != nil {
 				return chat.Message{}, fmt.Errorf("failed to convert tool: %w", err)
 			}
@@ -267,12 +432,19 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		config.Tools = tools
 	}
 
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+		return chat.Message{}, err
+	}
+
 	// Stream content
-	c.logger.Debug("starting stream", "model", c.modelName, "has_tools", len(allTools) > 0)
+	c.logger.DebugContext(ctx, "starting stream", "model", c.modelName, "has_tools", len(allTools) > 0)
 	stream := c.genaiClient.Models.GenerateContentStream(ctx, c.modelName, contents, config)
 
 	var respContent strings.Builder
 	var functionCalls []*genai.FunctionCall
+	var thinking thinkingState
+	var usage chat.TokenUsageDetails
+	var candidates common.Candidates
 	chunkCount := 0
 	for chunk, err := range stream {
 		if err != nil {
@@ -281,13 +453,33 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 		if chunk == nil {
 			continue
 		}
+		common.SetResponseID(ctx, chunk.ResponseID)
+		common.SetResponseModel(ctx, chunk.ModelVersion)
 		chunkCount++
-		c.logger.Debug("chunk received", "chunk_num", chunkCount, "candidates", len(chunk.Candidates))
+		c.logger.DebugContext(ctx, "chunk received", "chunk_num", chunkCount, "candidates", len(chunk.Candidates))
 
 		// Extract text and function calls from chunk
 		for _, candidate := range chunk.Candidates {
+			// Extra candidates are collected without streaming them
+			if candidate.Index > 0 {
+				if candidate.Content != nil {
+					for _, part := range candidate.Content.Parts {
+						if !part.Thought {
+							candidates.Add(int(candidate.Index), part.Text)
+						}
+					}
+				}
+				continue
+			}
 			if candidate.Content != nil {
 				for _, part := range candidate.Content.Parts {
+					isThought, err := thinking.observe(part, callback)
+					if err != nil {
+						return chat.Message{}, err
+					}
+					if isThought {
+						continue
+					}
 					if part.Text != "" {
 						content := part.Text
 						respContent.WriteString(content)
@@ -308,13 +500,13 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 					if part.FunctionCall != nil {
 						// Generate ID if not present
 						if part.FunctionCall.ID == "" {
-							part.FunctionCall.ID = generateFunctionCallID()
+							part.FunctionCall.ID = c.ids.NewID()
 						}
 						functionCalls = append(functionCalls, part.FunctionCall)
 
 						// Log function call detection
 						argsJSON, _ := json.Marshal(part.FunctionCall.Args)
-						c.logger.Debug("function call detected", "id", part.FunctionCall.ID, "name", part.FunctionCall.Name, "args", string(argsJSON))
+						c.logger.DebugContext(ctx, "function call detected", "id", part.FunctionCall.ID, "name", part.FunctionCall.Name, "args", string(argsJSON))
 
 						// Emit tool call event
 						if callback != nil {
@@ -336,40 +528,52 @@ func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat
 					}
 				}
 			}
-			// Extract token usage if available
-			if chunk.UsageMetadata != nil {
-				usage := chat.TokenUsageDetails{
-					InputTokens:  int(chunk.UsageMetadata.PromptTokenCount),
-					OutputTokens: int(chunk.UsageMetadata.CandidatesTokenCount),
-					TotalTokens:  int(chunk.UsageMetadata.TotalTokenCount),
-					CachedTokens: int(chunk.UsageMetadata.CachedContentTokenCount),
-				}
+		}
+		// Extract token usage if available. Each chunk reports the usage
+		// so far, so only the last one is recorded once the stream ends.
+		if chunk.UsageMetadata != nil {
+			usage = geminiUsage(chunk.UsageMetadata)
+			c.logger.DebugContext(ctx, "usage metadata", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens, "cached", usage.CachedTokens)
+		}
+	}
 
-				// Update usage
-				c.state.UpdateUsage(usage)
+	if err := thinking.finish(callback); err != nil {
+		return chat.Message{}, err
+	}
 
-				// Log token usage
-				totalUsage, _ := c.state.TokenUsage()
-				c.logger.Debug("usage metadata", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens, "cached", usage.CachedTokens,
-					"cumulative_input", totalUsage.Cumulative.InputTokens, "cumulative_output", totalUsage.Cumulative.OutputTokens, "cumulative_total", totalUsage.Cumulative.TotalTokens)
-			}
-		}
+	c.state.UpdateUsage(usage)
+
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundEnd, 0, common.RoundEndReason(len(functionCalls) > 0)); err != nil {
+		return chat.Message{}, err
 	}
 
 	// Log stream completion
-	c.logger.Debug("stream completed", "has_function_calls", len(functionCalls) > 0, "content_length", respContent.Len())
+	c.logger.DebugContext(ctx, "stream completed", "has_function_calls", len(functionCalls) > 0, "content_length", respContent.Len())
 
 	// Handle tool calls with multiple rounds if needed
 	if len(functionCalls) > 0 {
-		return c.handleToolCallRounds(ctx, msgWithReminder, functionCalls, reqOpts, callback)
+		resp := chat.Message{Role: chat.AssistantRole}
+		if respContent.Len() > 0 {
+			resp.AddText(respContent.String())
+		}
+		// The thought signature is returned with the calls in the next round
+		thinking.addTo(&resp)
+		for _, fc := range functionCalls {
+			resp.AddToolCall(geminiFunctionCallToChat(fc))
+		}
+		return c.handleToolCallRounds(ctx, msgWithReminder, resp, reqOpts, callback)
 	}
 
 	respMsg := chat.AssistantMessage(respContent.String())
+	thinking.addTo(&respMsg)
 
 	// Update history
 	// Persist the message WITH system reminder for complete audit trail
 	c.state.AppendMessages([]chat.Message{msgWithReminder, respMsg}, nil)
 
+	// The history keeps only the first candidate
+	respMsg.Candidates = candidates.Messages()
+
 	return respMsg, nil
 }
 
@@ -387,6 +591,32 @@ func (c *chatClient) MaxTokens() int {
 	return c.maxTokens
 }
 
+// ContextLimit returns the size of the model's context window
+func (c *chatClient) ContextLimit() int {
+	return c.contextLimit
+}
+
+// Model returns the name of the model
+func (c *chatClient) Model() string {
+	return c.modelName
+}
+
+// LastRequests implements chat.RequestReporter
+func (c *chatClient) LastRequests() []chat.RequestInfo {
+	return c.state.LastRequests()
+}
+
+// EstimateRequest implements chat.RequestEstimator
+func (c *chatClient) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	return common.EstimateRequest(ctx, c, msg, opts...)
+}
+
+// SetSystemPrompt replaces the system prompt for subsequent messages
+func (c *chatClient) SetSystemPrompt(ctx context.Context, prompt string) error {
+	c.state.SetSystemPrompt(prompt)
+	return nil
+}
+
 // RegisterTool registers a tool that can be called by the LLM
 func (c *chatClient) RegisterTool(tool chat.Tool) error {
 	return c.tools.Register(tool)
@@ -404,51 +634,41 @@ func (c *chatClient) ListTools() []string {
 
 // jsonSchemaToGeminiSchema recursively converts a JSON Schema object to Gemini Schema format.
 // It handles all basic types, arrays with items, objects with properties and required fields,
-// and schema attributes like description and enum.
+// nullable types written as ["type", "null"] or with anyOf, and schema attributes like
+// description, enum, format, and numeric, length, and item count limits.
 func jsonSchemaToGeminiSchema(schemaMap map[string]interface{}) (*genai.Schema, error) {
+	if anyOf, ok := schemaMap["anyOf"].([]interface{}); ok {
+		return anyOfToGeminiSchema(schemaMap, anyOf)
+	}
+
 	schema := &genai.Schema{}
 
-	if typeStr, ok := schemaMap["type"].(string); ok {
-		switch typeStr {
-		case "string":
-			schema.Type = genai.TypeString
-		case "integer":
-			schema.Type = genai.TypeInteger
-		case "number":
-			schema.Type = genai.TypeNumber
-		case "boolean":
-			schema.Type = genai.TypeBoolean
-		case "array":
-			schema.Type = genai.TypeArray
-			if items, ok := schemaMap["items"].(map[string]interface{}); ok {
-				itemSchema, err := jsonSchemaToGeminiSchema(items)
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert array items schema: %w", err)
-				}
-				schema.Items = itemSchema
+	switch t := schemaMap["type"].(type) {
+	case string:
+		if err := setGeminiType(schema, t, schemaMap); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		// A union like ["string", "null"], as funcschema writes for pointers
+		var types []string
+		for _, name := range t {
+			if name == "null" {
+				schema.Nullable = genai.Ptr(true)
+			} else if name, ok := name.(string); ok {
+				types = append(types, name)
 			}
-		case "object":
-			schema.Type = genai.TypeObject
-			if props, ok := schemaMap["properties"].(map[string]interface{}); ok {
-				schema.Properties = make(map[string]*genai.Schema)
-				for propName, propValue := range props {
-					if propMap, ok := propValue.(map[string]interface{}); ok {
-						propSchema, err := jsonSchemaToGeminiSchema(propMap)
-						if err != nil {
-							return nil, fmt.Errorf("failed to convert property %q: %w", propName, err)
-						}
-						schema.Properties[propName] = propSchema
-					}
-				}
+		}
+		if len(types) == 1 {
+			if err := setGeminiType(schema, types[0], schemaMap); err != nil {
+				return nil, err
 			}
-			if required, ok := schemaMap["required"].([]interface{}); ok {
-				requiredFields := make([]string, 0, len(required))
-				for _, field := range required {
-					if fieldName, ok := field.(string); ok {
-						requiredFields = append(requiredFields, fieldName)
-					}
+		} else {
+			for _, name := range types {
+				variant := &genai.Schema{}
+				if err := setGeminiType(variant, name, schemaMap); err != nil {
+					return nil, err
 				}
-				schema.Required = requiredFields
+				schema.AnyOf = append(schema.AnyOf, variant)
 			}
 		}
 	}
@@ -456,22 +676,198 @@ func jsonSchemaToGeminiSchema(schemaMap map[string]interface{}) (*genai.Schema,
 	if desc, ok := schemaMap["description"].(string); ok {
 		schema.Description = desc
 	}
+	if format, ok := schemaMap["format"].(string); ok {
+		schema.Format = format
+	}
+	if pattern, ok := schemaMap["pattern"].(string); ok {
+		schema.Pattern = pattern
+	}
+	schema.Minimum = floatField(schemaMap, "minimum")
+	schema.Maximum = floatField(schemaMap, "maximum")
+	schema.MinLength = intField(schemaMap, "minLength")
+	schema.MaxLength = intField(schemaMap, "maxLength")
+	schema.MinItems = intField(schemaMap, "minItems")
+	schema.MaxItems = intField(schemaMap, "maxItems")
 
 	if enum, ok := schemaMap["enum"].([]interface{}); ok {
-		enumStrs := make([]string, 0, len(enum))
-		for _, e := range enum {
-			if eStr, ok := e.(string); ok {
-				enumStrs = append(enumStrs, eStr)
+		applyGeminiEnum(schema, enum)
+	}
+	if value, ok := schemaMap["const"]; ok && value != nil {
+		applyGeminiEnum(schema, []interface{}{value})
+	}
+
+	return schema, nil
+}
+
+// applyGeminiEnum restricts schema to values, from a JSON Schema enum or
+// const. Gemini only supports enums of strings, so numeric values are
+// bounded with a minimum and maximum and, if there are several, listed in
+// the description, as are booleans. A null value, allowed for nullable
+// fields, is dropped.
+func applyGeminiEnum(schema *genai.Schema, values []interface{}) {
+	var strs []string
+	var nums []float64
+	other := false
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			strs = append(strs, v)
+		case float64:
+			nums = append(nums, v)
+		case nil:
+		default:
+			other = true
+		}
+	}
+
+	switch {
+	case len(strs) > 0 && len(nums) == 0 && !other:
+		if schema.Type == "" {
+			schema.Type = genai.TypeString
+		}
+		schema.Enum = strs
+	case len(nums) > 0 && len(strs) == 0 && !other:
+		minimum, maximum := slices.Min(nums), slices.Max(nums)
+		schema.Minimum = &minimum
+		schema.Maximum = &maximum
+		if len(nums) > 1 {
+			allowed := make([]string, len(nums))
+			for i, n := range nums {
+				allowed[i] = strconv.FormatFloat(n, 'f', -1, 64)
 			}
+			appendGeminiDescription(schema, "Must be one of: "+strings.Join(allowed, ", ")+".")
+		}
+	default:
+		var allowed []string
+		for _, v := range values {
+			if v != nil {
+				allowed = append(allowed, fmt.Sprint(v))
+			}
+		}
+		if len(allowed) == 1 {
+			appendGeminiDescription(schema, "Must be "+allowed[0]+".")
+		} else if len(allowed) > 1 {
+			appendGeminiDescription(schema, "Must be one of: "+strings.Join(allowed, ", ")+".")
 		}
-		schema.Enum = enumStrs
 	}
+}
 
+// setGeminiType sets schema's type from a JSON Schema type name,
+// converting the items of arrays and the properties of objects described
+// by schemaMap.
+func setGeminiType(schema *genai.Schema, typeStr string, schemaMap map[string]interface{}) error {
+	switch typeStr {
+	case "string":
+		schema.Type = genai.TypeString
+	case "integer":
+		schema.Type = genai.TypeInteger
+	case "number":
+		schema.Type = genai.TypeNumber
+	case "boolean":
+		schema.Type = genai.TypeBoolean
+	case "null":
+		schema.Type = genai.TypeNULL
+	case "array":
+		schema.Type = genai.TypeArray
+		if items, ok := schemaMap["items"].(map[string]interface{}); ok {
+			itemSchema, err := jsonSchemaToGeminiSchema(items)
+			if err != nil {
+				return fmt.Errorf("failed to convert array items schema: %w", err)
+			}
+			schema.Items = itemSchema
+		}
+	case "object":
+		schema.Type = genai.TypeObject
+		if props, ok := schemaMap["properties"].(map[string]interface{}); ok {
+			schema.Properties = make(map[string]*genai.Schema)
+			for propName, propValue := range props {
+				if propMap, ok := propValue.(map[string]interface{}); ok {
+					propSchema, err := jsonSchemaToGeminiSchema(propMap)
+					if err != nil {
+						return fmt.Errorf("failed to convert property %q: %w", propName, err)
+					}
+					schema.Properties[propName] = propSchema
+				}
+			}
+		}
+		if required, ok := schemaMap["required"].([]interface{}); ok {
+			requiredFields := make([]string, 0, len(required))
+			for _, field := range required {
+				if fieldName, ok := field.(string); ok {
+					requiredFields = append(requiredFields, fieldName)
+				}
+			}
+			schema.Required = requiredFields
+		}
+	}
+	return nil
+}
+
+// anyOfToGeminiSchema converts a schema with anyOf. The common nullable
+// pattern, one schema or null, becomes that schema marked nullable; other
+// alternatives are kept as Gemini's anyOf.
+func anyOfToGeminiSchema(schemaMap map[string]interface{}, anyOf []interface{}) (*genai.Schema, error) {
+	nullable := false
+	var variants []*genai.Schema
+	for i, v := range anyOf {
+		variantMap, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if variantMap["type"] == "null" {
+			nullable = true
+			continue
+		}
+		variant, err := jsonSchemaToGeminiSchema(variantMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert anyOf schema %d: %w", i, err)
+		}
+		variants = append(variants, variant)
+	}
+
+	schema := &genai.Schema{AnyOf: variants}
+	if len(variants) == 1 {
+		schema = variants[0]
+	}
+	if nullable {
+		schema.Nullable = genai.Ptr(true)
+	}
+	if desc, ok := schemaMap["description"].(string); ok {
+		schema.Description = desc
+	}
 	return schema, nil
 }
 
+// floatField returns the number schemaMap[key], if it's set.
+func floatField(schemaMap map[string]interface{}, key string) *float64 {
+	if v, ok := schemaMap[key].(float64); ok {
+		return &v
+	}
+	return nil
+}
+
+// intField returns the number schemaMap[key] as an integer, if it's set.
+func intField(schemaMap map[string]interface{}, key string) *int64 {
+	if v, ok := schemaMap[key].(float64); ok {
+		n := int64(v)
+		return &n
+	}
+	return nil
+}
+
+func appendGeminiDescription(schema *genai.Schema, note string) {
+	if schema.Description == "" {
+		schema.Description = note
+		return
+	}
+	if !strings.HasSuffix(schema.Description, ".") {
+		schema.Description += "."
+	}
+	schema.Description += " " + note
+}
+
 // mcpToGeminiFunctionDeclaration converts an MCP tool definition to Gemini FunctionDeclaration format
-func (c *chatClient) mcpToGeminiFunctionDeclaration(mcpDef chat.ToolDef) (*genai.FunctionDeclaration, error) {
+func mcpToGeminiFunctionDeclaration(mcpDef chat.ToolDef) (*genai.FunctionDeclaration, error) {
 	// Parse the MCP JSON schema to extract the inputSchema
 	var mcp struct {
 		InputSchema json.RawMessage `json:"inputSchema"`
@@ -504,254 +900,207 @@ func (c *chatClient) mcpToGeminiFunctionDeclaration(mcpDef chat.ToolDef) (*genai
 	}, nil
 }
 
-// handleToolCallRounds handles potentially multiple rounds of tool calls
-func (c *chatClient) handleToolCallRounds(ctx context.Context, initialMsg chat.Message, initialFunctionCalls []*genai.FunctionCall, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
-	c.logger.Debug("starting tool call rounds", "initial_function_count", len(initialFunctionCalls))
-
-	// Keep track of all messages for the conversation
-	var msgs []*genai.Content
-	var systemPrompt string
-
-	// Build initial conversation with system prompt and history
-	// Snapshot history with minimal lock
-	systemPrompt, history := c.state.Snapshot()
-
+// handleToolCallRounds runs the tool calls in resp, the response to userMsg,
+// and the rounds of tool calls that follow.
+func (c *chatClient) handleToolCallRounds(ctx context.Context, userMsg, resp chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
+	// Convert the history before the loop records the user message
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
+	if _, cached := cachedContentName(reqOpts); cached {
+		systemPrompt = common.RequestInstructions(reqOpts)
+	}
+	var prefix []*genai.Content
 	if systemPrompt != "" {
-		msgs = append(msgs, &genai.Content{
+		prefix = append(prefix, &genai.Content{
 			Role: "user",
 			Parts: []*genai.Part{
 				{Text: systemPrompt},
 			},
 		})
 	}
-
-	// Add history messages using the new converter
-	for _, m := range history {
-		converted, err := messageToGemini(m)
-		if err != nil {
-			// Skip messages that can't be converted
-			continue
-		}
-		// Filter out nil results and empty contents
-		for _, content := range converted {
-			if content != nil && len(content.Parts) > 0 {
-				msgs = append(msgs, content)
-			}
-		}
-	}
-
-	// Add the initial user message using the converter
-	converted, err := messageToGemini(initialMsg)
+	historyContents, err := c.history.Convert(history, historyContent)
 	if err != nil {
-		return chat.Message{}, fmt.Errorf("converting initial message: %w", err)
+		return chat.Message{}, fmt.Errorf("converting history: %w", err)
 	}
-	msgs = append(msgs, converted...)
-
-	// Persist the user message before tool execution to maintain chronological ordering
-	c.state.AppendMessages([]chat.Message{initialMsg}, nil)
-
-	// Process tool calls in a loop until we get a final response
-	functionCalls := initialFunctionCalls
-
-	for len(functionCalls) > 0 {
-		c.logger.Debug("processing function calls", "count", len(functionCalls))
-		for i, fc := range functionCalls {
-			argsJSON, _ := json.Marshal(fc.Args)
-			c.logger.Debug("function call", "index", i+1, "id", fc.ID, "name", fc.Name, "args", string(argsJSON))
-		}
+	prefix = append(prefix, historyContents...)
+
+	loop := common.ToolLoop{
+		State:    c.state,
+		Tools:    c.tools,
+		Provider: providerName,
+		Model:    c.modelName,
+		Logger:   c.logger,
+		Send: func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error) {
+			return c.streamToolRound(ctx, prefix, msgs, reqOpts, callback)
+		},
+	}
+	return loop.Run(ctx, callback, userMsg, resp)
+}
 
-		// Execute tool calls
-		functionResults, _, err := c.handleFunctionCalls(ctx, functionCalls, callback)
+// streamToolRound streams the response in a round of tool calls. prefix is
+// the system prompt and history, and turn the messages since.
+func (c *chatClient) streamToolRound(ctx context.Context, prefix []*genai.Content, turn []chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, chat.TokenUsageDetails, error) {
+	msgs := slices.Clip(prefix)
+	for _, msg := range turn {
+		converted, err := messageToGemini(msg)
 		if err != nil {
-			return chat.Message{}, fmt.Errorf("failed to execute function calls: %w", err)
+			return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("converting %s message: %w", msg.Role, err)
 		}
+		msgs = append(msgs, converted...)
+	}
 
-		assistantParts := make([]*genai.Part, len(functionCalls))
-		chatToolCalls := make([]chat.ToolCall, len(functionCalls))
-		for i, fc := range functionCalls {
-			assistantParts[i] = &genai.Part{
-				FunctionCall: fc,
-			}
-			chatToolCalls[i] = geminiFunctionCallToChat(fc)
+	followUpConfig := &genai.GenerateContentConfig{}
+
+	// Apply base URL if configured
+	if c.baseURL != "" {
+		followUpConfig.HTTPOptions = &genai.HTTPOptions{
+			BaseURL: c.baseURL,
 		}
+	}
 
-		msgs = append(msgs, &genai.Content{
-			Role:  "model",
-			Parts: assistantParts,
-		})
+	if reqOpts.Temperature != nil {
+		temp := float32(*reqOpts.Temperature)
+		followUpConfig.Temperature = &temp
+	}
+	if reqOpts.MaxTokens > 0 {
+		followUpConfig.MaxOutputTokens = int32(reqOpts.MaxTokens)
+	}
 
-		// Note: We don't store intermediate tool-calling messages in conversation history
-		// The final conversation will only include the initial user message and final assistant response
+	followUpConfig.ThinkingConfig = c.thinkingConfig()
 
-		// Add function results to messages (only if we have actual results)
-		if len(functionResults) > 0 {
-			// Build parts with system reminder first, then function results
-			resultParts := []*genai.Part{}
-			if reminder := getSystemReminderText(ctx); reminder != "" {
-				resultParts = append(resultParts, &genai.Part{Text: reminder})
-			}
-			for _, fr := range functionResults {
-				resultParts = append(resultParts, &genai.Part{
-					FunctionResponse: fr,
-				})
+	// Add tools again for follow-up after tool execution, unless the cache
+	// holds them
+	allTools := c.tools.ForRequest(reqOpts)
+	if cachedContent, cached := cachedContentName(reqOpts); cached {
+		followUpConfig.CachedContent = cachedContent
+	} else if len(allTools) > 0 {
+		tools := make([]*genai.Tool, 0, 1)
+		functionDeclarations := make([]*genai.FunctionDeclaration, 0, len(allTools))
+		for _, tool := range allTools {
+			funcDecl, err := mcpToGeminiFunctionDeclaration(tool)
+			if err != nil {
+				// Skip this tool on error
+				continue
 			}
-
-			msgs = append(msgs, &genai.Content{
-				Role:  "user",
-				Parts: resultParts,
-			})
+			functionDeclarations = append(functionDeclarations, funcDecl)
 		}
+		// Create a single Tool with all function declarations
+		tools = append(tools, &genai.Tool{
+			FunctionDeclarations: functionDeclarations,
+		})
+		followUpConfig.Tools = tools
+	}
 
-		// Make another API call with tool results
-		followUpConfig := &genai.GenerateContentConfig{}
+	// Create a new stream for the follow-up request
+	followUpStream := c.genaiClient.Models.GenerateContentStream(ctx, c.modelName, msgs, followUpConfig)
 
-		// Apply base URL if configured
-		if c.baseURL != "" {
-			followUpConfig.HTTPOptions = &genai.HTTPOptions{
-				BaseURL: c.baseURL,
-			}
-		}
+	// Process the follow-up stream
+	var respContent strings.Builder
+	var thinking thinkingState
+	var usage chat.TokenUsageDetails
+	var functionCalls []*genai.FunctionCall
+	followUpChunkCount := 0
 
-		if reqOpts.Temperature != nil {
-			temp := float32(*reqOpts.Temperature)
-			followUpConfig.Temperature = &temp
-		}
-		if reqOpts.MaxTokens > 0 {
-			followUpConfig.MaxOutputTokens = int32(reqOpts.MaxTokens)
+	for chunk, err := range followUpStream {
+		if err != nil {
+			return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("follow-up streaming error: %w", err)
 		}
-
-		// Add tools again for follow-up after tool execution
-		allTools := c.tools.GetAll()
-		if len(allTools) > 0 {
-			tools := make([]*genai.Tool, 0, 1)
-			functionDeclarations := make([]*genai.FunctionDeclaration, 0, len(allTools))
-			for _, tool := range allTools {
-				funcDecl, err := c.mcpToGeminiFunctionDeclaration(tool)
-				if err != nil {
-					// Skip this tool on error
-					continue
-				}
-				functionDeclarations = append(functionDeclarations, funcDecl)
-			}
-			// Create a single Tool with all function declarations
-			tools = append(tools, &genai.Tool{
-				FunctionDeclarations: functionDeclarations,
-			})
-			followUpConfig.Tools = tools
+		if chunk == nil {
+			continue
 		}
+		common.SetResponseID(ctx, chunk.ResponseID)
+		common.SetResponseModel(ctx, chunk.ModelVersion)
+		followUpChunkCount++
+		c.logger.DebugContext(ctx, "follow-up chunk received", "chunk_num", followUpChunkCount, "candidates", len(chunk.Candidates))
 
-		// Create a new stream for the follow-up request
-		followUpStream := c.genaiClient.Models.GenerateContentStream(ctx, c.modelName, msgs, followUpConfig)
-
-		// Process the follow-up stream
-		var respContent strings.Builder
-		functionCalls = nil // Reset for next round
-		followUpChunkCount := 0
+		for _, candidate := range chunk.Candidates {
+			if candidate.Content != nil {
+				for _, part := range candidate.Content.Parts {
+					isThought, err := thinking.observe(part, callback)
+					if err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+					if isThought {
+						continue
+					}
 
-		for chunk, err := range followUpStream {
-			if err != nil {
-				return chat.Message{}, fmt.Errorf("follow-up streaming error: %w", err)
-			}
-			if chunk == nil {
-				continue
-			}
-			followUpChunkCount++
-			c.logger.Debug("follow-up chunk received", "chunk_num", followUpChunkCount, "candidates", len(chunk.Candidates))
+					// Check for function calls
+					if part.FunctionCall != nil {
+						// Generate ID if not present
+						if part.FunctionCall.ID == "" {
+							part.FunctionCall.ID = c.ids.NewID()
+						}
+						functionCalls = append(functionCalls, part.FunctionCall)
 
-			for _, candidate := range chunk.Candidates {
-				if candidate.Content != nil {
-					for _, part := range candidate.Content.Parts {
-						// Check for function calls
-						if part.FunctionCall != nil {
-							// Generate ID if not present
-							if part.FunctionCall.ID == "" {
-								part.FunctionCall.ID = generateFunctionCallID()
-							}
-							functionCalls = append(functionCalls, part.FunctionCall)
-
-							// Emit tool call event
-							if callback != nil {
-								// Convert arguments to JSON
-								argsJSON, _ := json.Marshal(part.FunctionCall.Args)
-								toolCallEvent := chat.StreamEvent{
-									Type: chat.StreamEventTypeToolCall,
-									ToolCalls: []chat.ToolCall{
-										{
-											ID:        part.FunctionCall.ID,
-											Name:      part.FunctionCall.Name,
-											Arguments: json.RawMessage(argsJSON),
-										},
+						// Emit tool call event
+						if callback != nil {
+							// Convert arguments to JSON
+							argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+							toolCallEvent := chat.StreamEvent{
+								Type: chat.StreamEventTypeToolCall,
+								ToolCalls: []chat.ToolCall{
+									{
+										ID:        part.FunctionCall.ID,
+										Name:      part.FunctionCall.Name,
+										Arguments: json.RawMessage(argsJSON),
 									},
-								}
-								if err := callback(toolCallEvent); err != nil {
-									return chat.Message{}, err
-								}
+								},
 							}
-						}
-
-						// Check for regular content
-						if part.Text != "" {
-							content := part.Text
-							respContent.WriteString(content)
-
-							// Call the callback with the content event
-							if callback != nil {
-								event := chat.StreamEvent{
-									Type:    chat.StreamEventTypeContent,
-									Content: content,
-								}
-								if err := callback(event); err != nil {
-									return chat.Message{}, err
-								}
+							if err := callback(toolCallEvent); err != nil {
+								return chat.Message{}, chat.TokenUsageDetails{}, err
 							}
 						}
 					}
-				}
-				// Extract token usage if available
-				if chunk.UsageMetadata != nil {
-					usage := chat.TokenUsageDetails{
-						InputTokens:  int(chunk.UsageMetadata.PromptTokenCount),
-						OutputTokens: int(chunk.UsageMetadata.CandidatesTokenCount),
-						TotalTokens:  int(chunk.UsageMetadata.TotalTokenCount),
-						CachedTokens: int(chunk.UsageMetadata.CachedContentTokenCount),
-					}
 
-					// Update usage
-					c.state.UpdateUsage(usage)
+					// Check for regular content
+					if part.Text != "" {
+						content := part.Text
+						respContent.WriteString(content)
 
-					// Log token usage
-					totalUsage, _ := c.state.TokenUsage()
-					c.logger.Debug("follow-up usage metadata", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens,
-						"cumulative_input", totalUsage.Cumulative.InputTokens, "cumulative_output", totalUsage.Cumulative.OutputTokens, "cumulative_total", totalUsage.Cumulative.TotalTokens)
+						// Call the callback with the content event
+						if callback != nil {
+							event := chat.StreamEvent{
+								Type:    chat.StreamEventTypeContent,
+								Content: content,
+							}
+							if err := callback(event); err != nil {
+								return chat.Message{}, chat.TokenUsageDetails{}, err
+							}
+						}
+					}
 				}
 			}
+			// Extract token usage if available
+			if chunk.UsageMetadata != nil {
+				usage = geminiUsage(chunk.UsageMetadata)
+				c.logger.DebugContext(ctx, "follow-up usage metadata", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
+			}
 		}
+	}
 
-		// If we got more function calls, continue the loop
-		if len(functionCalls) > 0 {
-			c.logger.Debug("got more function calls, continuing", "count", len(functionCalls))
-			continue
-		}
-
-		// No more function calls, we have the final response
-		c.logger.Debug("no more function calls, returning final response", "content_length", len(respContent.String()))
-
-		finalMsg := chat.AssistantMessage(respContent.String())
-
-		// Warn if final response is empty
-		if respContent.Len() == 0 {
-			c.logger.Warn("final response has no content")
-		}
-
-		// Update history with final assistant response (user message already persisted)
-		c.state.AppendMessages([]chat.Message{finalMsg}, nil)
+	if err := thinking.finish(callback); err != nil {
+		return chat.Message{}, chat.TokenUsageDetails{}, err
+	}
 
-		return finalMsg, nil
+	resp := chat.Message{Role: chat.AssistantRole}
+	if respContent.Len() > 0 {
+		resp.AddText(respContent.String())
 	}
+	// The thought signature is returned with the calls in the next round
+	thinking.addTo(&resp)
+	for _, fc := range functionCalls {
+		resp.AddToolCall(geminiFunctionCallToChat(fc))
+	}
+	return resp, usage, nil
+}
 
-	// This should never be reached since the loop continues until no function calls
-	c.logger.Error("unexpected end of function call processing", "initial_function_count", len(initialFunctionCalls))
-	return chat.Message{}, fmt.Errorf("unexpected end of function call processing")
+// geminiUsage converts Gemini usage metadata to token usage details.
+func geminiUsage(metadata *genai.GenerateContentResponseUsageMetadata) chat.TokenUsageDetails {
+	return chat.TokenUsageDetails{
+		InputTokens:  int(metadata.PromptTokenCount),
+		OutputTokens: int(metadata.CandidatesTokenCount),
+		TotalTokens:  int(metadata.TotalTokenCount),
+		CachedTokens: int(metadata.CachedContentTokenCount),
+	}
 }
 
 func geminiFunctionCallToChat(fc *genai.FunctionCall) chat.ToolCall {
@@ -768,89 +1117,21 @@ func geminiFunctionCallToChat(fc *genai.FunctionCall) chat.ToolCall {
 	}
 }
 
-// handleFunctionCalls processes function calls from the model and returns function results
-func (c *chatClient) handleFunctionCalls(ctx context.Context, functionCalls []*genai.FunctionCall, callback chat.StreamCallback) ([]*genai.FunctionResponse, []chat.ToolResult, error) {
-	if len(functionCalls) == 0 {
-		return nil, nil, nil
+// historyContent converts a message from the chat's history, skipping
+// messages that can't be converted (e.g., system messages, which are handled
+// separately) and empty contents.
+func historyContent(msg chat.Message) ([]*genai.Content, error) {
+	converted, err := messageToGemini(msg)
+	if err != nil {
+		return nil, nil
 	}
-
-	var functionResults []*genai.FunctionResponse
-	var chatResults []chat.ToolResult
-
-	for _, fc := range functionCalls {
-		argsJSON, err := json.Marshal(fc.Args)
-		if err != nil {
-			errorResponse := map[string]interface{}{
-				"error": fmt.Sprintf("Failed to marshal function arguments: %v", err),
-			}
-			functionResults = append(functionResults, &genai.FunctionResponse{
-				ID:       fc.ID,
-				Name:     fc.Name,
-				Response: errorResponse,
-			})
-			chatResults = append(chatResults, chat.ToolResult{
-				ToolCallID: fc.ID,
-				Name:       fc.Name,
-				Error:      fmt.Sprintf("Failed to marshal function arguments: %v", err),
-			})
-			continue
-		}
-
-		resultStr, err := c.tools.Execute(ctx, fc.Name, string(argsJSON))
-		toolResult := common.BuildToolResult(fc.Name, fc.ID, resultStr, err)
-
-		if err != nil {
-			c.logger.Debug("tool execution failed", "name", fc.Name, "args", string(argsJSON), "error", err.Error())
-		} else {
-			c.logger.Debug("tool executed successfully", "name", fc.Name, "args", string(argsJSON), "result", resultStr)
-		}
-
-		if callback != nil {
-			toolResultEvent := chat.StreamEvent{
-				Type:        chat.StreamEventTypeToolResult,
-				ToolResults: []chat.ToolResult{toolResult},
-			}
-			if callbackErr := callback(toolResultEvent); callbackErr != nil {
-				return nil, nil, fmt.Errorf("callback error: %w", callbackErr)
-			}
-		}
-
-		if err != nil {
-			errorResponse := map[string]interface{}{
-				"error": err.Error(),
-			}
-			functionResults = append(functionResults, &genai.FunctionResponse{
-				ID:       fc.ID,
-				Name:     fc.Name,
-				Response: errorResponse,
-			})
-			chatResults = append(chatResults, toolResult)
-			continue
-		}
-
-		resultForModel := toolResult.Content
-		var resultMap map[string]interface{}
-		// Handle empty results specially to ensure valid response structure
-		if resultForModel == "" {
-			resultMap = map[string]interface{}{
-				"result": "success", // Provide a non-empty result for empty tool responses
-			}
-		} else if err := json.Unmarshal([]byte(resultForModel), &resultMap); err != nil {
-			// If not valid JSON, wrap as string result
-			resultMap = map[string]interface{}{
-				"result": resultForModel,
-			}
+	var result []*genai.Content
+	for _, content := range converted {
+		if content != nil && len(content.Parts) > 0 {
+			result = append(result, content)
 		}
-
-		functionResults = append(functionResults, &genai.FunctionResponse{
-			ID:       fc.ID,
-			Name:     fc.Name,
-			Response: resultMap,
-		})
-		chatResults = append(chatResults, toolResult)
 	}
-
-	return functionResults, chatResults, nil
+	return result, nil
 }
 
 // messageToGemini converts a chat.Message to Gemini Content format.
@@ -872,12 +1153,17 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 	case chat.UserRole, "system":
 		// User and system messages
 		text := extractText(msg)
-		if text == "" {
+		images := imageParts(msg.GetImages())
+		if text == "" && len(images) == 0 {
 			return nil, fmt.Errorf("user/system message has no text content")
 		}
+		var parts []*genai.Part
+		if text != "" {
+			parts = append(parts, &genai.Part{Text: text})
+		}
 		return []*genai.Content{{
 			Role:  "user",
-			Parts: []*genai.Part{{Text: text}},
+			Parts: append(parts, images...),
 		}}, nil
 
 	case chat.AssistantRole:
@@ -901,15 +1187,11 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 				}
 			}
 
-			// Ensure ID is set
-			id := tc.ID
-			if id == "" {
-				id = generateFunctionCallID()
-			}
-
+			// Calls from other sources may have no ID, like Gemini's own,
+			// and are matched to their responses by name
 			parts = append(parts, &genai.Part{
 				FunctionCall: &genai.FunctionCall{
-					ID:   id,
+					ID:   tc.ID,
 					Name: tc.Name,
 					Args: args,
 				},
@@ -921,6 +1203,16 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 			return nil, nil
 		}
 
+		// Return Gemini's thought signature on the first function call, or on
+		// the first part when there are no function calls
+		if signature := thoughtSignature(msg); signature != nil {
+			signed := parts[0]
+			if len(toolCalls) > 0 {
+				signed = parts[len(parts)-len(toolCalls)]
+			}
+			signed.ThoughtSignature = signature
+		}
+
 		return []*genai.Content{{
 			Role:  "model",
 			Parts: parts,
@@ -940,7 +1232,7 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 			response := make(map[string]any)
 
 			if tr.Error != "" {
-				response["error"] = tr.Error
+				response = common.ToolResultErrorFields(tr)
 			} else if tr.Content != "" {
 				// Try to unmarshal as JSON first
 				if err := json.Unmarshal([]byte(tr.Content), &response); err != nil {
@@ -960,6 +1252,15 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 				},
 			})
 		}
+		// Images from tools follow the responses as inline data
+		for _, tr := range toolResults {
+			parts = append(parts, imageParts(tr.Images)...)
+		}
+		for _, content := range msg.Contents {
+			if content.SystemReminder != "" {
+				parts = append(parts, &genai.Part{Text: content.SystemReminder})
+			}
+		}
 
 		return []*genai.Content{{
 			Role:  "function",
@@ -979,6 +1280,35 @@ func messageToGemini(msg chat.Message) ([]*genai.Content, error) {
 	}
 }
 
+// imageParts converts images to inline data parts.
+func imageParts(images []chat.ImageContent) []*genai.Part {
+	var parts []*genai.Part
+	for _, img := range images {
+		parts = append(parts, &genai.Part{
+			InlineData: &genai.Blob{MIMEType: img.MediaType, Data: img.Data},
+		})
+	}
+	return parts
+}
+
+// thoughtSignature returns the thought signature Gemini attached to msg, if
+// any. Thinking from other providers is dropped, as Gemini can't verify it.
+func thoughtSignature(msg chat.Message) []byte {
+	for _, content := range msg.Contents {
+		t := content.Thinking
+		if t == nil || t.Provider != providerName || t.Signature == "" {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(t.Signature)
+		if err != nil {
+			logger.Warn("ignoring invalid thought signature", "error", err)
+			continue
+		}
+		return signature
+	}
+	return nil
+}
+
 // extractText concatenates all text content from a message.
 func extractText(msg chat.Message) string {
 	var text string
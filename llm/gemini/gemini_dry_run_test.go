@@ -0,0 +1,40 @@
+package gemini
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestGemini_DryRun(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL(server.URL))
+	require.NoError(t, err)
+
+	c := client.NewChat("You are helpful.")
+	req, err := chat.DryRun(context.Background(), c, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	assert.Zero(t, requests.Load())
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.True(t, strings.HasPrefix(req.URL, server.URL), req.URL)
+	assert.Contains(t, req.URL, "gemini-2.5-flash:streamGenerateContent")
+	assert.Contains(t, string(req.Body), "Hello")
+	assert.Contains(t, string(req.Body), "You are helpful.")
+
+	_, msgs := c.History()
+	assert.Empty(t, msgs)
+}
@@ -0,0 +1,21 @@
+package gemini
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestGemini_RequestGolden(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL("https://gemini.invalid"), WithThinkingBudget(512))
+	require.NoError(t, err)
+	llmtesting.TestRequestGolden(t, client, "testdata/request.golden.json", *update, chat.WithMaxTokens(1024))
+}
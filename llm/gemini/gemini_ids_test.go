@@ -0,0 +1,116 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const functionCallChunk = `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hello"}}}]},"finishReason":"STOP"}]}`
+
+const textChunk = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Done"}]},"finishReason":"STOP"}]}`
+
+// newFunctionCallServer returns a server that answers every odd request with
+// a call to the echo tool, without an ID, and every even one with "Done".
+func newFunctionCallServer(t *testing.T) *httptest.Server {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunk := textChunk
+		if requests.Add(1)%2 == 1 {
+			chunk = functionCallChunk
+		}
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newEchoChat(t *testing.T, client chat.Client) chat.Chat {
+	t.Helper()
+
+	c := client.NewChat("System")
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "echo",
+		jsonSchema: `{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}}`,
+		callFn: func(ctx context.Context, input string) string {
+			return input
+		},
+	}))
+	return c
+}
+
+// echoToolCallIDs sends a message to c that makes one call to the echo tool,
+// and returns the IDs of the tool call and its result.
+func echoToolCallIDs(t *testing.T, c chat.Chat) (string, string) {
+	t.Helper()
+
+	var toolCalls []chat.ToolCall
+	var toolResults []chat.ToolResult
+	resp, err := c.Message(context.Background(), chat.UserMessage("Echo hello"), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		toolCalls = append(toolCalls, event.ToolCalls...)
+		toolResults = append(toolResults, event.ToolResults...)
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Done", resp.GetText())
+
+	require.Len(t, toolCalls, 1)
+	require.Len(t, toolResults, 1)
+	return toolCalls[0].ID, toolResults[0].ToolCallID
+}
+
+func TestGemini_CallIDs(t *testing.T) {
+	server := newFunctionCallServer(t)
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL(server.URL))
+	require.NoError(t, err)
+
+	c := newEchoChat(t, client)
+	first, resultID := echoToolCallIDs(t, c)
+	assert.Regexp(t, `^gemini_call_[0-9a-f]{16}_1$`, first)
+	assert.Equal(t, first, resultID)
+	second, _ := echoToolCallIDs(t, c)
+	assert.Equal(t, strings.TrimSuffix(first, "1")+"2", second)
+
+	// Each chat numbers its calls in its own namespace, so concurrent
+	// chats don't produce the same IDs
+	other, _ := echoToolCallIDs(t, newEchoChat(t, client))
+	assert.Regexp(t, `^gemini_call_[0-9a-f]{16}_1$`, other)
+	assert.NotEqual(t, first, other)
+}
+
+func TestNewCallIDs(t *testing.T) {
+	history := chat.AssistantMessage("")
+	history.AddToolCall(chat.ToolCall{ID: "gemini_call_0123456789abcdef_7", Name: "echo"})
+	history.AddToolCall(chat.ToolCall{ID: "gemini_call_3", Name: "echo"})
+	history.AddToolCall(chat.ToolCall{ID: "toolu_12", Name: "echo"})
+
+	// Numbering continues after calls restored from history, in a new
+	// namespace
+	ids := newCallIDs([]chat.Message{history})
+	id := ids.NewID()
+	assert.Regexp(t, `^gemini_call_[0-9a-f]{16}_8$`, id)
+	assert.NotEqual(t, "gemini_call_0123456789abcdef_8", id)
+}
+
+func TestGemini_IDGenerator(t *testing.T) {
+	server := newFunctionCallServer(t)
+	var ids atomic.Int32
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL(server.URL), WithIDGenerator(chat.IDGeneratorFunc(func() string {
+		return fmt.Sprintf("call_%d", ids.Add(1))
+	})))
+	require.NoError(t, err)
+
+	callID, resultID := echoToolCallIDs(t, newEchoChat(t, client))
+	assert.Equal(t, "call_1", callID)
+	assert.Equal(t, callID, resultID)
+}
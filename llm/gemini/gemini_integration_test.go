@@ -10,6 +10,7 @@ import (
 
 	"github.com/bpowers/go-agent/chat"
 	llmtesting "github.com/bpowers/go-agent/llm/testing"
+	"github.com/bpowers/go-agent/persistence"
 )
 
 // testTool implements chat.Tool for testing
@@ -272,3 +273,13 @@ func TestGeminiIntegration_MessagePersistenceAfterRestore(t *testing.T) {
 
 	llmtesting.TestMessagePersistenceAfterRestore(t, client)
 }
+
+func TestGeminiIntegration_Compaction(t *testing.T) {
+	llmtesting.SkipIfNoAPIKey(t, provider)
+
+	client, err := NewClient(getAPIKey(), WithModel(getTestModel()))
+	require.NoError(t, err, "Failed to create Gemini client")
+	require.NotNil(t, client)
+
+	llmtesting.TestCompaction(t, client, persistence.NewMemoryStore())
+}
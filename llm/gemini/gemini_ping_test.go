@@ -0,0 +1,44 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestGemini_Ping(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/models/gemini-2.5-flash") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"code":503,"message":"The model is overloaded.","status":"UNAVAILABLE"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash"}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", WithModel("gemini-2.5-flash"), WithBaseURL(server.URL))
+	require.NoError(t, err)
+	status, err := client.(chat.Pinger).Ping(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Healthy())
+	assert.Equal(t, "gemini", status.Provider)
+
+	client, err = NewClient("test-key", WithModel("gemini-2.5-pro"), WithBaseURL(server.URL))
+	require.NoError(t, err)
+	status, err = client.(chat.Pinger).Ping(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, chat.HealthUnavailable, status.State)
+	assert.True(t, status.Retryable())
+}
@@ -0,0 +1,104 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestThinkingState(t *testing.T) {
+	var events []chat.StreamEvent
+	callback := func(event chat.StreamEvent) error {
+		events = append(events, event)
+		return nil
+	}
+
+	var thinking thinkingState
+	parts := []*genai.Part{
+		{Text: "Considering ", Thought: true},
+		{Text: "the question", Thought: true},
+		{Text: "The answer", ThoughtSignature: []byte("sig")},
+	}
+	var isThought []bool
+	for _, part := range parts {
+		thought, err := thinking.observe(part, callback)
+		require.NoError(t, err)
+		isThought = append(isThought, thought)
+	}
+	require.NoError(t, thinking.finish(callback))
+	assert.Equal(t, []bool{true, true, false}, isThought)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, chat.StreamEventTypeThinking, events[0].Type)
+	assert.Equal(t, "Considering ", events[0].Content)
+	assert.Equal(t, chat.StreamEventTypeThinkingSummary, events[2].Type)
+	assert.Equal(t, "Considering the question", events[2].ThinkingStatus.Summary)
+
+	msg := chat.AssistantMessage("The answer")
+	thinking.addTo(&msg)
+	require.Len(t, msg.Contents, 2)
+	assert.Equal(t, &chat.ThinkingContent{
+		Text:      "Considering the question",
+		Signature: base64.StdEncoding.EncodeToString([]byte("sig")),
+		Provider:  providerName,
+	}, msg.Contents[1].Thinking)
+}
+
+func TestThinkingConfig(t *testing.T) {
+	c := &client{}
+	assert.Nil(t, c.thinkingConfig())
+
+	WithThinkingBudget(1024)(c)
+	config := c.thinkingConfig()
+	require.NotNil(t, config)
+	assert.True(t, config.IncludeThoughts)
+	assert.Equal(t, int32(1024), *config.ThinkingBudget)
+
+	WithThinkingBudget(0)(c)
+	config = c.thinkingConfig()
+	assert.False(t, config.IncludeThoughts)
+	assert.Equal(t, int32(0), *config.ThinkingBudget)
+}
+
+func TestMessageToGeminiThoughtSignature(t *testing.T) {
+	signature := base64.StdEncoding.EncodeToString([]byte("sig"))
+
+	msg := chat.Message{Role: chat.AssistantRole}
+	msg.Contents = append(msg.Contents, chat.Content{
+		Thinking: &chat.ThinkingContent{Text: "Thought", Signature: signature, Provider: providerName},
+	})
+	msg.AddText("Let me check")
+	msg.AddToolCall(chat.ToolCall{ID: "call_1", Name: "lookup"})
+
+	got, err := messageToGemini(msg)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Parts, 2)
+	assert.Nil(t, got[0].Parts[0].ThoughtSignature)
+	assert.Equal(t, []byte("sig"), got[0].Parts[1].ThoughtSignature)
+	// Thought summaries are not sent back
+	assert.False(t, got[0].Parts[0].Thought)
+
+	// Without function calls the signature goes on the first part
+	msg = chat.Message{Role: chat.AssistantRole}
+	msg.Contents = append(msg.Contents, chat.Content{
+		Thinking: &chat.ThinkingContent{Text: "Thought", Signature: signature, Provider: providerName},
+	})
+	msg.AddText("Answer")
+	got, err = messageToGemini(msg)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), got[0].Parts[0].ThoughtSignature)
+
+	// Signatures from other providers are dropped
+	msg = chat.Message{Role: chat.AssistantRole}
+	msg.AddThinking("Thought", "claude-signature")
+	msg.AddText("Answer")
+	got, err = messageToGemini(msg)
+	require.NoError(t, err)
+	assert.Nil(t, got[0].Parts[0].ThoughtSignature)
+}
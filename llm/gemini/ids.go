@@ -0,0 +1,53 @@
+package gemini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// callIDPrefix starts the IDs given to function calls Gemini returns
+// without one.
+const callIDPrefix = "gemini_call_"
+
+// callIDs numbers a chat's function calls within a random namespace, so
+// IDs are unique across concurrent chats and sessions while still sorting
+// in call order within a chat.
+type callIDs struct {
+	namespace string
+	n         atomic.Int64
+}
+
+var _ chat.IDGenerator = (*callIDs)(nil)
+
+// newCallIDs returns IDs for a chat starting with history, in a new
+// namespace, numbering new calls after any calls in history that were
+// numbered by an earlier chat.
+func newCallIDs(history []chat.Message) *callIDs {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	ids := &callIDs{namespace: hex.EncodeToString(b[:])}
+	for _, msg := range history {
+		for _, tc := range msg.GetToolCalls() {
+			suffix, ok := strings.CutPrefix(tc.ID, callIDPrefix)
+			if !ok {
+				continue
+			}
+			suffix = suffix[strings.LastIndexByte(suffix, '_')+1:]
+			if n, err := strconv.ParseInt(suffix, 10, 64); err == nil && n > ids.n.Load() {
+				ids.n.Store(n)
+			}
+		}
+	}
+	return ids
+}
+
+// NewID implements chat.IDGenerator.
+func (ids *callIDs) NewID() string {
+	return fmt.Sprintf("%s%s_%d", callIDPrefix, ids.namespace, ids.n.Add(1))
+}
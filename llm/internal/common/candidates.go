@@ -0,0 +1,41 @@
+package common
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Candidates accumulates the streamed text of the extra candidates requested
+// with chat.WithCandidates. The first candidate (index 0) is handled by the
+// provider as its response; the rest are collected here.
+type Candidates struct {
+	text map[int]*strings.Builder
+}
+
+// Add appends streamed text to the candidate at index.
+func (c *Candidates) Add(index int, text string) {
+	if c.text == nil {
+		c.text = make(map[int]*strings.Builder)
+	}
+	b, ok := c.text[index]
+	if !ok {
+		b = &strings.Builder{}
+		c.text[index] = b
+	}
+	b.WriteString(text)
+}
+
+// Messages returns the candidates as assistant messages, ordered by index.
+func (c *Candidates) Messages() []chat.Message {
+	if len(c.text) == 0 {
+		return nil
+	}
+	msgs := make([]chat.Message, 0, len(c.text))
+	for _, index := range slices.Sorted(maps.Keys(c.text)) {
+		msgs = append(msgs, chat.AssistantMessage(c.text[index].String()))
+	}
+	return msgs
+}
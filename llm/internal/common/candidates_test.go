@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestCandidates(t *testing.T) {
+	t.Parallel()
+
+	var c Candidates
+	assert.Nil(t, c.Messages())
+
+	c.Add(2, "Hi")
+	c.Add(1, "Hel")
+	c.Add(1, "lo")
+	c.Add(2, " there")
+
+	assert.Equal(t, []chat.Message{
+		chat.AssistantMessage("Hello"),
+		chat.AssistantMessage("Hi there"),
+	}, c.Messages())
+}
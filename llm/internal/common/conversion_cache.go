@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ConversionCache caches the provider-format conversion of a chat's history,
+// so that each request only converts the messages added since the previous
+// one rather than the whole conversation. It relies on State's history being
+// append-only: the conversion of history[i] never changes, so converted
+// messages are cached by their index. A cache must only be used with the
+// history of a single State.
+type ConversionCache[T any] struct {
+	mu sync.Mutex
+
+	converted int // number of history messages converted
+	msgs      []T
+}
+
+// Convert returns the provider-format messages for history, calling convert
+// only for messages that aren't already cached. convert may return any
+// number of provider messages (including none) for a chat message. The
+// result must not be modified, but may be appended to.
+func (c *ConversionCache[T]) Convert(history []chat.Message, convert func(chat.Message) ([]T, error)) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(history) < c.converted {
+		// Not the history we cached; start over rather than return stale messages
+		c.converted = 0
+		c.msgs = nil
+	}
+
+	for i := c.converted; i < len(history); i++ {
+		converted, err := convert(history[i])
+		if err != nil {
+			return nil, fmt.Errorf("converting message %d: %w", i, err)
+		}
+		c.msgs = append(c.msgs, converted...)
+		c.converted = i + 1
+	}
+
+	return slices.Clip(c.msgs), nil
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestConversionCache(t *testing.T) {
+	var calls []string
+	convert := func(msg chat.Message) ([]string, error) {
+		calls = append(calls, msg.GetText())
+		if msg.Role == "system" {
+			return nil, nil
+		}
+		return []string{string(msg.Role) + ":" + msg.GetText()}, nil
+	}
+
+	var cache ConversionCache[string]
+	history := []chat.Message{chat.UserMessage("a"), chat.AssistantMessage("b")}
+	got, err := cache.Convert(history, convert)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:a", "assistant:b"}, got)
+
+	// Only new messages are converted
+	calls = nil
+	history = append(history, chat.Message{Role: "system", Contents: []chat.Content{{Text: "s"}}}, chat.UserMessage("c"))
+	got, err = cache.Convert(history, convert)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:a", "assistant:b", "user:c"}, got)
+	assert.Equal(t, []string{"s", "c"}, calls)
+
+	// Appending to the result doesn't affect the cache
+	_ = append(got, "extra")
+	calls = nil
+	got, err = cache.Convert(history, convert)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:a", "assistant:b", "user:c"}, got)
+	assert.Empty(t, calls)
+
+	// A shorter history can't share the cached prefix
+	got, err = cache.Convert(history[:1], convert)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:a"}, got)
+}
+
+func TestConversionCacheError(t *testing.T) {
+	errBad := errors.New("bad message")
+	convert := func(msg chat.Message) ([]string, error) {
+		if msg.GetText() == "bad" {
+			return nil, errBad
+		}
+		return []string{msg.GetText()}, nil
+	}
+
+	var cache ConversionCache[string]
+	_, err := cache.Convert([]chat.Message{chat.UserMessage("ok"), chat.UserMessage("bad")}, convert)
+	assert.ErrorIs(t, err, errBad)
+	assert.ErrorContains(t, err, "converting message 1")
+
+	// Messages converted before the error stay cached
+	got, err := cache.Convert([]chat.Message{chat.UserMessage("ok")}, convert)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ok"}, got)
+}
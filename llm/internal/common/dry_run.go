@@ -0,0 +1,76 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// errDryRun cancels the context of a dry run once its request is captured,
+// so the SDK gives up rather than retrying.
+var errDryRun = errors.New("dry run: request captured")
+
+type dryRunKey struct{}
+
+// dryRun holds the request captured during a dry run.
+type dryRun struct {
+	cancel context.CancelCauseFunc
+
+	mu  sync.Mutex
+	req *chat.DryRunRequest
+}
+
+func dryRunFrom(ctx context.Context) *dryRun {
+	d, _ := ctx.Value(dryRunKey{}).(*dryRun)
+	return d
+}
+
+// StartDryRun implements chat.WithDryRun. If opts.DryRun is set, it returns
+// a context under which the first request made through RequestMiddleware or
+// RequestTransport is captured instead of sent, and the context is
+// canceled. The returned function replaces the error of a send made with
+// that context with the captured *chat.DryRunRequest, if there is one.
+func StartDryRun(ctx context.Context, opts chat.Options) (context.Context, func(error) error) {
+	if !opts.DryRun {
+		return ctx, func(err error) error { return err }
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	d := &dryRun{cancel: cancel}
+	ctx = context.WithValue(ctx, dryRunKey{}, d)
+	return ctx, func(err error) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		cancel(nil)
+		if d.req != nil {
+			return d.req
+		}
+		return err
+	}
+}
+
+// capture records req, if it is the dry run's first request, and returns
+// the error to fail it with.
+func (d *dryRun) capture(req *http.Request) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.req == nil {
+		captured := &chat.DryRunRequest{Method: req.Method, URL: req.URL.String()}
+		if req.Body != nil && req.Body != http.NoBody {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			captured.Body = data
+		}
+		d.req = captured
+	}
+	d.cancel(errDryRun)
+	return errDryRun
+}
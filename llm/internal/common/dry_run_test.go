@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestStartDryRun(t *testing.T) {
+	t.Parallel()
+
+	s := NewState("", nil)
+	endTurn, err := s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+	s.requests.add(chat.RequestInfo{RequestID: "req_1"})
+	endTurn()
+
+	ctx, finish := StartDryRun(context.Background(), chat.Options{DryRun: true})
+	endTurn, err = s.BeginTurn(ctx, 0)
+	require.NoError(t, err)
+	ctx = s.TrackRequests(ctx)
+
+	var sent int
+	next := func(*http.Request) (*http.Response, error) {
+		sent++
+		return nil, errors.New("unexpected send")
+	}
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.example.com/v1/messages", strings.NewReader(`{"model":"m"}`))
+		require.NoError(t, err)
+		resp, err := RequestMiddleware(req, next)
+		assert.Nil(t, resp)
+		assert.Error(t, err)
+	}
+	assert.Zero(t, sent)
+	assert.Error(t, ctx.Err(), "the context is canceled once the request is captured")
+
+	err = finish(context.Canceled)
+	endTurn()
+	var dryRun *chat.DryRunRequest
+	require.True(t, errors.As(err, &dryRun))
+	assert.Equal(t, &chat.DryRunRequest{Method: http.MethodPost, URL: "https://api.example.com/v1/messages", Body: []byte(`{"model":"m"}`)}, dryRun)
+
+	// The previous turn's requests are kept
+	assert.Equal(t, []chat.RequestInfo{{RequestID: "req_1"}}, s.LastRequests())
+}
+
+func TestStartDryRun_Disabled(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dryCtx, finish := StartDryRun(ctx, chat.Options{})
+	assert.Equal(t, ctx, dryCtx)
+	errSend := errors.New("send failed")
+	assert.Equal(t, errSend, finish(errSend))
+	assert.NoError(t, finish(nil))
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/tokens"
+)
+
+// EstimateRequest implements chat.RequestEstimator for c, a provider chat,
+// by building its request for msg with chat.DryRun.
+func EstimateRequest(ctx context.Context, c chat.Chat, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	req, err := chat.DryRun(ctx, c, msg, opts...)
+	if err != nil {
+		return chat.RequestEstimate{}, err
+	}
+	var body struct {
+		Tools json.RawMessage `json:"tools"`
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		return chat.RequestEstimate{}, fmt.Errorf("parsing request body: %w", err)
+	}
+
+	_, history := c.History()
+	return chat.RequestEstimate{
+		Bytes:           len(req.Body),
+		Messages:        len(history) + 1,
+		ToolBytes:       len(body.Tools),
+		EstimatedTokens: tokens.FromChars(len(req.Body)),
+	}, nil
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ExportMessages converts msgs with convert, the same per-message conversion
+// a provider applies to its history, and returns the result as JSON. It is
+// used to implement chat.MessageExporter.
+func ExportMessages[T any](msgs []chat.Message, convert func(chat.Message) ([]T, error)) (json.RawMessage, error) {
+	result := make([]T, 0, len(msgs))
+	for i, msg := range msgs {
+		converted, err := convert(msg)
+		if err != nil {
+			return nil, fmt.Errorf("converting message %d: %w", i, err)
+		}
+		result = append(result, converted...)
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Ping implements chat.Pinger for a provider: it runs check, a request made
+// with the provider's SDK, and classifies the provider's health by the
+// HTTP status of the request's response. The SDK must be configured with
+// RequestMiddleware or RequestTransport, and shouldn't retry the request,
+// so that a failing check fails fast.
+func Ping(ctx context.Context, provider, model string, check func(ctx context.Context) error) (chat.HealthStatus, error) {
+	var log RequestLog
+	start := time.Now()
+	err := check(context.WithValue(ctx, requestLogKey{}, &log))
+
+	status := chat.HealthStatus{Provider: provider, Model: model, Latency: time.Since(start)}
+	if requests := log.Requests(); len(requests) > 0 {
+		status.StatusCode = requests[len(requests)-1].StatusCode
+	}
+	status.State = healthState(status.StatusCode, err)
+	if err != nil {
+		status.Error = err.Error()
+		return status, fmt.Errorf("%s health check failed (%s): %w", provider, status.State, err)
+	}
+	return status, nil
+}
+
+func healthState(statusCode int, err error) chat.HealthState {
+	switch {
+	case err == nil:
+		return chat.HealthOK
+	case statusCode == 0:
+		return chat.HealthUnreachable
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return chat.HealthUnauthorized
+	case statusCode == http.StatusNotFound:
+		return chat.HealthModelNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return chat.HealthRateLimited
+	case statusCode >= 500:
+		return chat.HealthUnavailable
+	default:
+		return chat.HealthFailed
+	}
+}
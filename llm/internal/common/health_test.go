@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: RequestTransport(nil)}
+	check := func(code int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/?code=%d", server.URL, code), nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+			return nil
+		}
+	}
+
+	tests := []struct {
+		code  int
+		state chat.HealthState
+	}{
+		{http.StatusOK, chat.HealthOK},
+		{http.StatusUnauthorized, chat.HealthUnauthorized},
+		{http.StatusForbidden, chat.HealthUnauthorized},
+		{http.StatusNotFound, chat.HealthModelNotFound},
+		{http.StatusTooManyRequests, chat.HealthRateLimited},
+		{529, chat.HealthUnavailable},
+		{http.StatusBadRequest, chat.HealthFailed},
+	}
+	for _, tt := range tests {
+		status, err := Ping(context.Background(), "test", "model-1", check(tt.code))
+		assert.Equal(t, tt.state, status.State, "status %d", tt.code)
+		assert.Equal(t, tt.code, status.StatusCode)
+		assert.Equal(t, "test", status.Provider)
+		assert.Equal(t, "model-1", status.Model)
+		assert.Positive(t, status.Latency)
+		if tt.state == chat.HealthOK {
+			assert.NoError(t, err)
+			assert.Empty(t, status.Error)
+		} else {
+			assert.ErrorContains(t, err, string(tt.state))
+			assert.NotEmpty(t, status.Error)
+		}
+	}
+
+	// No response at all
+	status, err := Ping(context.Background(), "test", "model-1", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, chat.HealthUnreachable, status.State)
+	assert.True(t, status.Retryable())
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RepairJSON fixes the mistakes models most commonly make when generating
+// tool arguments: trailing commas before a closing bracket, and unescaped
+// control characters (usually newlines) inside strings. It returns the
+// repaired JSON and whether it is now valid; anything else is left for the
+// tool's own input validation to report.
+func RepairJSON(s string) (string, bool) {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				b.WriteString(`\n`)
+				continue
+			case c == '\r':
+				b.WriteString(`\r`)
+				continue
+			case c == '\t':
+				b.WriteString(`\t`)
+				continue
+			case c < 0x20:
+				fmt.Fprintf(&b, `\u%04x`, c)
+				continue
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case ',':
+			rest := strings.TrimLeft(s[i+1:], " \t\r\n")
+			if rest == "" || rest[0] == '}' || rest[0] == ']' {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	repaired := b.String()
+	return repaired, json.Valid([]byte(repaired))
+}
+
+// ToolArgumentStats counts how often a provider and model produced tool
+// call arguments that weren't valid JSON.
+type ToolArgumentStats struct {
+	Provider string
+	Model    string
+	// ToolCalls is the number of tool calls whose arguments were checked.
+	ToolCalls int64
+	// Malformed is the number of calls whose arguments were invalid JSON.
+	Malformed int64
+	// Repaired is the number of malformed arguments that were successfully
+	// repaired before being passed to the tool.
+	Repaired int64
+}
+
+type toolArgumentKey struct {
+	provider, model string
+}
+
+var toolArgumentMetrics struct {
+	mu    sync.Mutex
+	stats map[toolArgumentKey]*ToolArgumentStats
+}
+
+// ToolArgumentMetrics returns the tool argument stats recorded so far in this
+// process, sorted by provider and model.
+func ToolArgumentMetrics() []ToolArgumentStats {
+	toolArgumentMetrics.mu.Lock()
+	defer toolArgumentMetrics.mu.Unlock()
+
+	result := make([]ToolArgumentStats, 0, len(toolArgumentMetrics.stats))
+	for _, stats := range toolArgumentMetrics.stats {
+		result = append(result, *stats)
+	}
+	slices.SortFunc(result, func(a, b ToolArgumentStats) int {
+		return cmp.Or(cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.Model, b.Model))
+	})
+	return result
+}
+
+func recordToolArguments(provider, model string, malformed, repaired bool) {
+	toolArgumentMetrics.mu.Lock()
+	defer toolArgumentMetrics.mu.Unlock()
+
+	if toolArgumentMetrics.stats == nil {
+		toolArgumentMetrics.stats = make(map[toolArgumentKey]*ToolArgumentStats)
+	}
+	key := toolArgumentKey{provider: provider, model: model}
+	stats, ok := toolArgumentMetrics.stats[key]
+	if !ok {
+		stats = &ToolArgumentStats{Provider: provider, Model: model}
+		toolArgumentMetrics.stats[key] = stats
+	}
+	stats.ToolCalls++
+	if malformed {
+		stats.Malformed++
+	}
+	if repaired {
+		stats.Repaired++
+	}
+}
+
+// PrepareToolArguments checks a tool call's arguments before they are handed
+// to the tool, recording the result in ToolArgumentMetrics. If the arguments
+// are malformed and repair is true, the repaired arguments are returned when
+// RepairJSON succeeds; otherwise args is returned unchanged.
+func PrepareToolArguments(provider, model, args string, repair bool) string {
+	if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+		recordToolArguments(provider, model, false, false)
+		return args
+	}
+	if repair {
+		if repaired, ok := RepairJSON(args); ok {
+			recordToolArguments(provider, model, true, true)
+			return repaired
+		}
+	}
+	recordToolArguments(provider, model, true, false)
+	return args
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"valid", `{"a": [1, 2]}`, `{"a": [1, 2]}`, true},
+		{"trailing comma in object", `{"a": 1,}`, `{"a": 1}`, true},
+		{"trailing comma in array", `{"a": [1, 2, ]}`, `{"a": [1, 2 ]}`, true},
+		{"trailing comma before newline", "{\"a\": 1,\n}", "{\"a\": 1\n}", true},
+		{"comma in string preserved", `{"a": "x,}",}`, `{"a": "x,}"}`, true},
+		{"unescaped newline", "{\"code\": \"line1\nline2\"}", `{"code": "line1\nline2"}`, true},
+		{"unescaped tab and control", "{\"a\": \"x\ty\x01\"}", `{"a": "x\ty\u0001"}`, true},
+		{"escaped quote", `{"a": "say \"hi\",",}`, `{"a": "say \"hi\","}`, true},
+		{"unrepairable", `{"a": }`, `{"a": }`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RepairJSON(tt.input)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func findToolArgumentStats(provider, model string) ToolArgumentStats {
+	for _, s := range ToolArgumentMetrics() {
+		if s.Provider == provider && s.Model == model {
+			return s
+		}
+	}
+	return ToolArgumentStats{}
+}
+
+func TestPrepareToolArguments(t *testing.T) {
+	const provider, model = "test-provider", "test-prepare-tool-arguments"
+
+	assert.Equal(t, `{"a": 1}`, PrepareToolArguments(provider, model, `{"a": 1}`, true))
+	assert.Equal(t, ``, PrepareToolArguments(provider, model, ``, true))
+	assert.Equal(t, `{"a": 1}`, PrepareToolArguments(provider, model, `{"a": 1,}`, true))
+	// Repair is opt-in, but malformed arguments are still counted
+	assert.Equal(t, `{"a": 1,}`, PrepareToolArguments(provider, model, `{"a": 1,}`, false))
+	assert.Equal(t, `{"a": }`, PrepareToolArguments(provider, model, `{"a": }`, true))
+
+	assert.Equal(t, ToolArgumentStats{
+		Provider:  provider,
+		Model:     model,
+		ToolCalls: 5,
+		Malformed: 3,
+		Repaired:  1,
+	}, findToolArgumentStats(provider, model))
+}
+
+// FuzzRepairJSON checks that RepairJSON leaves valid JSON alone, and that
+// what it reports as repaired is valid.
+func FuzzRepairJSON(f *testing.F) {
+	f.Add(`{"a": [1, 2]}`)
+	f.Add(`{"a": 1,}`)
+	f.Add("{\"code\": \"line1\nline2\"}")
+	f.Add(`{"a": "say \"hi\",",}`)
+	f.Add(`{"a": `)
+	f.Add(`"\`)
+	f.Add(`[,]`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		got, ok := RepairJSON(s)
+		if json.Valid([]byte(s)) {
+			assert.True(t, ok)
+			assert.Equal(t, s, got)
+		}
+		if ok {
+			assert.True(t, json.Valid([]byte(got)), "repaired %q to invalid %q", s, got)
+		}
+
+		// Arguments are only changed when the repair succeeds
+		args := PrepareToolArguments("fuzz", "fuzz", s, true)
+		if args != s {
+			assert.True(t, ok)
+			assert.Equal(t, got, args)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/tokens"
+)
+
+// StreamProgress replaces opts.StreamingCb with a callback that also sends
+// progress events while the stream is silent for opts.ProgressAfter (see
+// chat.WithProgressEvents). It does nothing if progress events weren't
+// requested. The returned function stops the progress events, and must be
+// called before the Message call returns.
+func StreamProgress(opts *chat.Options) (stop func()) {
+	if opts.ProgressAfter <= 0 || opts.StreamingCb == nil {
+		return func() {}
+	}
+
+	now := time.Now()
+	p := &progressStream{
+		callback: opts.StreamingCb,
+		after:    opts.ProgressAfter,
+		start:    now,
+		last:     now,
+		done:     make(chan struct{}),
+	}
+	opts.StreamingCb = p.handle
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.run()
+	}()
+	return func() {
+		close(p.done)
+		wg.Wait()
+	}
+}
+
+// progressStream passes stream events on to callback, sending progress
+// events from its own goroutine when none arrive for a while.
+type progressStream struct {
+	callback chat.StreamCallback
+	after    time.Duration
+	start    time.Time
+	done     chan struct{}
+
+	mu    sync.Mutex
+	last  time.Time
+	chars int
+	// err is the callback's error for a progress event, returned for the
+	// next stream event
+	err error
+}
+
+func (p *progressStream) handle(event chat.StreamEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.last = time.Now()
+	switch event.Type {
+	case chat.StreamEventTypeContent, chat.StreamEventTypeThinking, chat.StreamEventTypeToolCallDelta:
+		p.chars += len(event.Content)
+	}
+	return p.callback(event)
+}
+
+// run sends progress events until done is closed or the callback fails.
+func (p *progressStream) run() {
+	timer := time.NewTimer(p.after)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-timer.C:
+		}
+
+		wait, ok := p.tick()
+		if !ok {
+			return
+		}
+		timer.Reset(wait)
+	}
+}
+
+// tick sends a progress event if the stream has been silent long enough.
+// It returns how long to wait before the next tick, and false once the
+// stream is done or the callback failed.
+func (p *progressStream) tick() (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return 0, false
+	default:
+	}
+	now := time.Now()
+	idle := now.Sub(p.last)
+	if idle < p.after {
+		return p.after - idle, true
+	}
+	event := chat.StreamEvent{
+		Type: chat.StreamEventTypeProgress,
+		Progress: &chat.ProgressStatus{
+			Elapsed: now.Sub(p.start),
+			Idle:    idle,
+			Tokens:  tokens.FromChars(p.chars),
+		},
+	}
+	if err := p.callback(event); err != nil {
+		p.err = err
+		return 0, false
+	}
+	return p.after, true
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// eventRecorder is a streaming callback that records events, and checks
+// that it is never called concurrently.
+type eventRecorder struct {
+	t *testing.T
+
+	mu     sync.Mutex
+	events []chat.StreamEvent
+	busy   bool
+}
+
+func (r *eventRecorder) callback(event chat.StreamEvent) error {
+	r.mu.Lock()
+	assert.False(r.t, r.busy, "callback called concurrently")
+	r.busy = true
+	r.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.busy = false
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *eventRecorder) progress() []*chat.ProgressStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var progress []*chat.ProgressStatus
+	for _, e := range r.events {
+		if e.Type == chat.StreamEventTypeProgress {
+			progress = append(progress, e.Progress)
+		}
+	}
+	return progress
+}
+
+func TestStreamProgress(t *testing.T) {
+	t.Parallel()
+
+	const after = 50 * time.Millisecond
+	r := &eventRecorder{t: t}
+	opts := chat.ApplyOptions(chat.WithStreamingCb(r.callback), chat.WithProgressEvents(after))
+	stop := StreamProgress(&opts)
+
+	// Steady events keep progress events away
+	for range 10 {
+		require.NoError(t, opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: "abcd"}))
+		time.Sleep(after / 10)
+	}
+	assert.Empty(t, r.progress())
+
+	// A stall gets repeated progress events
+	time.Sleep(5 * after)
+	progress := r.progress()
+	require.GreaterOrEqual(t, len(progress), 2)
+	assert.Equal(t, 10, progress[0].Tokens)
+	assert.GreaterOrEqual(t, progress[0].Idle, after)
+	assert.Greater(t, progress[1].Idle, progress[0].Idle)
+	assert.Greater(t, progress[0].Elapsed, progress[0].Idle)
+
+	stop()
+	n := len(r.progress())
+	time.Sleep(2 * after)
+	assert.Len(t, r.progress(), n, "progress events after stop")
+}
+
+func TestStreamProgress_CallbackError(t *testing.T) {
+	t.Parallel()
+
+	errStop := errors.New("stop")
+	var mu sync.Mutex
+	var progressEvents int
+	opts := chat.ApplyOptions(chat.WithProgressEvents(time.Millisecond), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if event.Type == chat.StreamEventTypeProgress {
+			progressEvents++
+			return errStop
+		}
+		return nil
+	}))
+	stop := StreamProgress(&opts)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	err := opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: "late"})
+	assert.ErrorIs(t, err, errStop)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, progressEvents)
+}
+
+func TestStreamProgress_Disabled(t *testing.T) {
+	t.Parallel()
+
+	opts := chat.ApplyOptions(chat.WithProgressEvents(time.Millisecond))
+	StreamProgress(&opts)()
+	assert.Nil(t, opts.StreamingCb)
+
+	called := false
+	opts = chat.ApplyOptions(chat.WithStreamingCb(func(chat.StreamEvent) error {
+		called = true
+		return nil
+	}))
+	stop := StreamProgress(&opts)
+	require.NoError(t, opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent}))
+	stop()
+	assert.True(t, called)
+}
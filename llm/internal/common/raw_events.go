@@ -0,0 +1,116 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// RawEventTap receives each server-sent event a provider streams, as the
+// SDK reads it: the provider's name, the event's type ("" for providers
+// that don't name their events), and its data. It is called from the
+// goroutine streaming the response.
+type RawEventTap func(provider, eventType string, payload []byte)
+
+// RawEventMiddleware returns a middleware that passes the server-sent events
+// of streamed responses to tap as they are read. Its signature matches the
+// OpenAI and Anthropic SDKs' option.Middleware.
+func RawEventMiddleware(provider string, tap RawEventTap) func(*http.Request, func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		resp, err := next(req)
+		if resp == nil || resp.Body == nil {
+			return resp, err
+		}
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+			return resp, err
+		}
+		resp.Body = &tappedBody{ReadCloser: resp.Body, parser: sseParser{emit: func(eventType string, data []byte) {
+			tap(provider, eventType, data)
+		}}}
+		return resp, err
+	}
+}
+
+// RawEventTransport wraps base (http.DefaultTransport if nil) to pass
+// server-sent events to tap like RawEventMiddleware, for SDKs that take an
+// http.Client.
+func RawEventTransport(base http.RoundTripper, provider string, tap RawEventTap) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return rawEventTransport{base: base, middleware: RawEventMiddleware(provider, tap)}
+}
+
+type rawEventTransport struct {
+	base       http.RoundTripper
+	middleware func(*http.Request, func(*http.Request) (*http.Response, error)) (*http.Response, error)
+}
+
+func (t rawEventTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.middleware(req, t.base.RoundTrip)
+}
+
+// tappedBody feeds everything read from the response body to parser.
+type tappedBody struct {
+	io.ReadCloser
+	parser sseParser
+}
+
+func (b *tappedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.parser.write(p[:n])
+	return n, err
+}
+
+// sseParser splits a server-sent event stream into events, calling emit
+// with each event's type and data. Comments and fields other than event and
+// data are ignored, and an event cut off by the end of the stream is never
+// emitted.
+type sseParser struct {
+	emit func(eventType string, data []byte)
+
+	// line is an incomplete line carried over from the previous write
+	line      []byte
+	eventType string
+	data      []byte
+	hasData   bool
+}
+
+func (p *sseParser) write(b []byte) {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.line = append(p.line, b...)
+			return
+		}
+		p.line = append(p.line, b[:i]...)
+		b = b[i+1:]
+		p.field(bytes.TrimSuffix(p.line, []byte("\r")))
+		p.line = p.line[:0]
+	}
+}
+
+// field handles one complete line of the stream.
+func (p *sseParser) field(line []byte) {
+	if len(line) == 0 {
+		if p.hasData {
+			p.emit(p.eventType, p.data)
+		}
+		p.eventType, p.data, p.hasData = "", nil, false
+		return
+	}
+
+	name, value, _ := bytes.Cut(line, []byte(":"))
+	value = bytes.TrimPrefix(value, []byte(" "))
+	switch string(name) {
+	case "event":
+		p.eventType = string(value)
+	case "data":
+		if p.hasData {
+			p.data = append(p.data, '\n')
+		}
+		p.data = append(p.data, value...)
+		p.hasData = true
+	}
+}
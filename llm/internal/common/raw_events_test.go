@@ -0,0 +1,107 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rawEvent struct {
+	provider, eventType, payload string
+}
+
+func TestRawEventMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []rawEvent
+	}{
+		{
+			name:        "named events",
+			contentType: "text/event-stream",
+			body:        "event: ping\ndata: {}\n\nevent: delta\ndata: {\"text\":\"hi\"}\n\n",
+			want: []rawEvent{
+				{"test", "ping", "{}"},
+				{"test", "delta", `{"text":"hi"}`},
+			},
+		},
+		{
+			name:        "unnamed events with CRLF, comments, and multiline data",
+			contentType: "text/event-stream; charset=utf-8",
+			body:        ": keepalive\r\n\r\ndata: one\r\ndata: two\r\nid: 7\r\n\r\ndata:[DONE]\r\n\r\n",
+			want: []rawEvent{
+				{"test", "", "one\ntwo"},
+				{"test", "", "[DONE]"},
+			},
+		},
+		{
+			name:        "event cut off at the end of the stream",
+			contentType: "text/event-stream",
+			body:        "data: whole\n\ndata: partial",
+			want:        []rawEvent{{"test", "", "whole"}},
+		},
+		{
+			name:        "not a stream",
+			contentType: "application/json",
+			body:        "data: {}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []rawEvent
+			middleware := RawEventMiddleware("test", func(provider, eventType string, payload []byte) {
+				got = append(got, rawEvent{provider, eventType, string(payload)})
+			})
+			resp, err := middleware(&http.Request{}, func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					Header: http.Header{"Content-Type": {tt.contentType}},
+					// Read a byte at a time, so events and lines span reads
+					Body: io.NopCloser(iotest.OneByteReader(strings.NewReader(tt.body))),
+				}, nil
+			})
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRawEventTransport(t *testing.T) {
+	t.Parallel()
+
+	var got []rawEvent
+	transport := RawEventTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			Header: http.Header{"Content-Type": {"text/event-stream"}},
+			Body:   io.NopCloser(strings.NewReader("data: {}\n\n")),
+		}, nil
+	}), "test", func(provider, eventType string, payload []byte) {
+		got = append(got, rawEvent{provider, eventType, string(payload)})
+	})
+
+	resp, err := transport.RoundTrip(&http.Request{})
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, []rawEvent{{"test", "", "{}"}}, got)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
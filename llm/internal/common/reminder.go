@@ -0,0 +1,29 @@
+package common
+
+import (
+	"context"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// SystemReminder returns the text of the system reminder attached to ctx
+// with chat.WithSystemReminder, or "" if there is none.
+func SystemReminder(ctx context.Context) string {
+	if reminderFunc := chat.GetSystemReminder(ctx); reminderFunc != nil {
+		return reminderFunc()
+	}
+	return ""
+}
+
+// WithPrependedSystemReminder returns a copy of msg with the system reminder
+// attached to ctx, if any, as its first content.
+func WithPrependedSystemReminder(ctx context.Context, msg chat.Message) chat.Message {
+	reminder := SystemReminder(ctx)
+	if reminder == "" {
+		return msg
+	}
+	contents := make([]chat.Content, 0, len(msg.Contents)+1)
+	contents = append(contents, chat.Content{SystemReminder: reminder})
+	contents = append(contents, msg.Contents...)
+	return chat.Message{Role: msg.Role, Contents: contents}
+}
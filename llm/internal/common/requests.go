@@ -0,0 +1,160 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// requestIDHeaders are the response headers providers return their request
+// IDs in.
+var requestIDHeaders = []string{"x-request-id", "request-id"}
+
+// RequestLog records the HTTP requests made during a message exchange.
+// Providers attach it to the request context with State.TrackRequests, and
+// RequestMiddleware or RequestTransport adds each request made with that
+// context to it.
+type RequestLog struct {
+	mu       sync.Mutex
+	requests []chat.RequestInfo
+}
+
+type requestLogKey struct{}
+
+func requestLogFrom(ctx context.Context) *RequestLog {
+	log, _ := ctx.Value(requestLogKey{}).(*RequestLog)
+	return log
+}
+
+// Requests returns a copy of the recorded requests.
+func (l *RequestLog) Requests() []chat.RequestInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]chat.RequestInfo(nil), l.requests...)
+}
+
+func (l *RequestLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests = nil
+}
+
+// add records a new request, returning its index.
+func (l *RequestLog) add(info chat.RequestInfo) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests = append(l.requests, info)
+	return len(l.requests) - 1
+}
+
+func (l *RequestLog) update(i int, fn func(*chat.RequestInfo)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fn(&l.requests[i])
+}
+
+// SetResponseID sets the provider's response ID on the latest request made
+// with ctx, once it is known from the response body.
+func SetResponseID(ctx context.Context, id string) {
+	log := requestLogFrom(ctx)
+	if log == nil || id == "" {
+		return
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if n := len(log.requests); n > 0 {
+		log.requests[n-1].ResponseID = id
+	}
+}
+
+// SetResponseModel sets the model the provider reports serving the latest
+// request made with ctx, once it is known from the response body.
+func SetResponseModel(ctx context.Context, model string) {
+	log := requestLogFrom(ctx)
+	if log == nil || model == "" {
+		return
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if n := len(log.requests); n > 0 {
+		log.requests[n-1].Model = model
+	}
+}
+
+// RequestMiddleware records requests in the RequestLog of their context,
+// and captures the request of a dry run (see StartDryRun) instead of
+// sending it. Its signature matches the OpenAI and Anthropic SDKs' option.Middleware.
+func RequestMiddleware(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if d := dryRunFrom(req.Context()); d != nil {
+		return nil, d.capture(req)
+	}
+
+	log := requestLogFrom(req.Context())
+	if log == nil {
+		return next(req)
+	}
+
+	start := time.Now()
+	resp, err := next(req)
+	if resp == nil {
+		log.add(chat.RequestInfo{Latency: time.Since(start)})
+		return resp, err
+	}
+
+	info := chat.RequestInfo{StatusCode: resp.StatusCode, Latency: time.Since(start)}
+	for _, header := range requestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			info.RequestID = id
+			break
+		}
+	}
+	i := log.add(info)
+	if resp.Body != nil {
+		// Streamed responses are read long after the headers arrive
+		resp.Body = &timedBody{ReadCloser: resp.Body, done: func() {
+			log.update(i, func(info *chat.RequestInfo) { info.Latency = time.Since(start) })
+		}}
+	}
+	return resp, err
+}
+
+// timedBody calls done when the response body is closed.
+type timedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *timedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// RequestTransport wraps base (http.DefaultTransport if nil) to record
+// requests like RequestMiddleware, for SDKs that take an http.Client.
+func RequestTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return requestTransport{base: base}
+}
+
+type requestTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return RequestMiddleware(req, t.base.RoundTrip)
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.Header().Set("x-request-id", "req_fail")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("request-id", "req_ok")
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: RequestTransport(nil)}
+	get := func(ctx context.Context, path string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// Requests without a log aren't recorded
+	get(context.Background(), "/")
+
+	s := NewState("", nil)
+	endTurn, err := s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+	ctx := s.TrackRequests(context.Background())
+	get(ctx, "/fail")
+	get(ctx, "/")
+	SetResponseID(ctx, "msg_1")
+	SetResponseModel(ctx, "model-2025-01-01")
+	endTurn()
+
+	requests := s.LastRequests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "req_fail", requests[0].RequestID)
+	assert.Equal(t, http.StatusServiceUnavailable, requests[0].StatusCode)
+	assert.Empty(t, requests[0].ResponseID)
+	assert.Equal(t, "req_ok", requests[1].RequestID)
+	assert.Equal(t, http.StatusOK, requests[1].StatusCode)
+	assert.Equal(t, "msg_1", requests[1].ResponseID)
+	assert.Equal(t, "model-2025-01-01", requests[1].Model)
+	assert.Equal(t, "model-2025-01-01", s.ResponseModel())
+	assert.Positive(t, requests[1].Latency)
+
+	// Each turn starts a new log
+	endTurn, err = s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+	defer endTurn()
+	assert.Empty(t, s.LastRequests())
+	assert.Empty(t, s.ResponseModel())
+}
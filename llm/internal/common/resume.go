@@ -0,0 +1,112 @@
+package common
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// SendResumable calls send, resuming a response whose stream fails partway
+// through up to opts.StreamResumes times (see chat.WithStreamResume). Each
+// resumed attempt is sent opts with the text streamed so far as its
+// AssistantPrefix, so send must stream the response through
+// opts.StreamingCb, and must not change the chat's history when it fails.
+func SendResumable(ctx context.Context, opts chat.Options, send func(context.Context, chat.Options) (chat.Message, error)) (chat.Message, error) {
+	if opts.StreamResumes <= 0 {
+		return send(ctx, opts)
+	}
+
+	r := &resumeRecorder{callback: opts.StreamingCb}
+	attemptOpts := opts
+	attemptOpts.StreamingCb = r.handle
+	for attempt := 0; ; attempt++ {
+		resp, err := send(ctx, attemptOpts)
+		if err == nil || attempt >= opts.StreamResumes || !r.resumable() || ctx.Err() != nil {
+			return resp, err
+		}
+
+		r.resume()
+		attemptOpts.AssistantPrefix = r.repeat
+	}
+}
+
+// resumeRecorder passes stream events on to callback, recording the text
+// delivered so a failed stream can be resumed from it. A resumed response
+// begins by repeating that text, which isn't delivered again.
+type resumeRecorder struct {
+	callback chat.StreamCallback
+
+	// delivered is the text passed to callback across all attempts, and
+	// repeat is the part of it the current attempt has yet to repeat
+	delivered strings.Builder
+	repeat    string
+
+	resumed     bool
+	diverged    bool
+	usedTools   bool
+	callbackErr bool
+}
+
+func (r *resumeRecorder) handle(event chat.StreamEvent) error {
+	switch event.Type {
+	case chat.StreamEventTypeContent:
+		if !r.diverged {
+			content := event.Content
+			n := commonPrefixLen(r.repeat, content)
+			if n < min(len(r.repeat), len(content)) {
+				// The response didn't begin with the delivered text, as can
+				// happen when a provider is instructed to begin with it.
+				// The rest is delivered as is, but can't be resumed.
+				r.diverged = true
+			}
+			r.repeat = r.repeat[n:]
+			event.Content = content[n:]
+			r.delivered.WriteString(event.Content)
+		}
+		if event.Content == "" {
+			return nil
+		}
+	case chat.StreamEventTypeRoundStart:
+		// The resumed attempt continues the round already started
+		if r.resumed && event.Round != nil && event.Round.Index == 0 {
+			return nil
+		}
+	case chat.StreamEventTypeToolCall, chat.StreamEventTypeToolCallDelta, chat.StreamEventTypeToolResult,
+		chat.StreamEventTypeServerToolUse, chat.StreamEventTypeWebSearchResult:
+		r.usedTools = true
+	}
+
+	if r.callback == nil {
+		return nil
+	}
+	if err := r.callback(event); err != nil {
+		r.callbackErr = true
+		return err
+	}
+	return nil
+}
+
+// resumable reports whether the failed attempt can be resumed: text was
+// delivered, the attempt didn't diverge from it, and it neither called
+// tools, which may have changed the chat's history, nor failed because of
+// the callback.
+func (r *resumeRecorder) resumable() bool {
+	return r.delivered.Len() > 0 && !r.diverged && !r.usedTools && !r.callbackErr
+}
+
+// resume prepares for an attempt that continues from the delivered text.
+func (r *resumeRecorder) resume() {
+	r.repeat = r.delivered.String()
+	r.resumed = true
+}
+
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,211 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+var errStreamReset = errors.New("stream reset")
+
+// attempt is a scripted response: the events it streams, then its error.
+type attempt struct {
+	events []chat.StreamEvent
+	err    error
+}
+
+func content(text string) chat.StreamEvent {
+	return chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: text}
+}
+
+// scriptedSend streams the attempts in turn, like a provider that is
+// instructed to begin with the prefix, recording the prefix each was sent.
+func scriptedSend(prefixes *[]string, attempts ...attempt) func(context.Context, chat.Options) (chat.Message, error) {
+	return func(ctx context.Context, opts chat.Options) (chat.Message, error) {
+		*prefixes = append(*prefixes, opts.AssistantPrefix)
+		a := attempts[len(*prefixes)-1]
+		var text strings.Builder
+		if err := EmitRound(opts.StreamingCb, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+			return chat.Message{}, err
+		}
+		for _, event := range a.events {
+			text.WriteString(event.Content)
+			if err := opts.StreamingCb(event); err != nil {
+				return chat.Message{}, err
+			}
+		}
+		if a.err != nil {
+			return chat.Message{}, a.err
+		}
+		return chat.AssistantMessage(text.String()), nil
+	}
+}
+
+func TestSendResumable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		resumes  int
+		prefix   string
+		attempts []attempt
+		want     string
+		wantErr  error
+		prefixes []string
+		streamed string
+	}{
+		{
+			name: "disabled",
+			attempts: []attempt{
+				{events: []chat.StreamEvent{content("The answer")}, err: errStreamReset},
+			},
+			wantErr:  errStreamReset,
+			prefixes: []string{""},
+			streamed: "The answer",
+		},
+		{
+			name:    "resumes with partial text",
+			resumes: 1,
+			attempts: []attempt{
+				{events: []chat.StreamEvent{content("The "), content("answer")}, err: errStreamReset},
+				{events: []chat.StreamEvent{content("The ans"), content("wer is 42.")}},
+			},
+			want:     "The answer is 42.",
+			prefixes: []string{"", "The answer"},
+			streamed: "The answer is 42.",
+		},
+		{
+			name:    "resumed attempt fails before repeating the text",
+			resumes: 2,
+			prefix:  "The",
+			attempts: []attempt{
+				{events: []chat.StreamEvent{content("The answer")}, err: errStreamReset},
+				{events: []chat.StreamEvent{content("The")}, err: errStreamReset},
+				{events: []chat.StreamEvent{content("The answer is 42.")}},
+			},
+			want:     "The answer is 42.",
+			prefixes: []string{"The", "The answer", "The answer"},
+			streamed: "The answer is 42.",
+		},
+		{
+			name:    "retries exhausted",
+			resumes: 1,
+			attempts: []attempt{
+				{events: []chat.StreamEvent{content("The")}, err: errStreamReset},
+				{events: []chat.StreamEvent{content("The answer")}, err: errStreamReset},
+			},
+			wantErr:  errStreamReset,
+			prefixes: []string{"", "The"},
+			streamed: "The answer",
+		},
+		{
+			name:    "no text",
+			resumes: 1,
+			attempts: []attempt{
+				{err: errStreamReset},
+			},
+			wantErr:  errStreamReset,
+			prefixes: []string{""},
+		},
+		{
+			name:    "diverged",
+			resumes: 2,
+			attempts: []attempt{
+				{events: []chat.StreamEvent{content("The answer")}, err: errStreamReset},
+				{events: []chat.StreamEvent{content("The result")}, err: errStreamReset},
+			},
+			wantErr:  errStreamReset,
+			prefixes: []string{"", "The answer"},
+			streamed: "The answerresult",
+		},
+		{
+			name:    "tool calls",
+			resumes: 1,
+			attempts: []attempt{
+				{events: []chat.StreamEvent{
+					content("Let me check."),
+					{Type: chat.StreamEventTypeToolCall, ToolCalls: []chat.ToolCall{{ID: "call_1", Name: "lookup"}}},
+				}, err: errStreamReset},
+			},
+			wantErr:  errStreamReset,
+			prefixes: []string{""},
+			streamed: "Let me check.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var streamed strings.Builder
+			var roundStarts int
+			opts := chat.ApplyOptions(
+				chat.WithStreamResume(tt.resumes),
+				chat.WithAssistantPrefix(tt.prefix),
+				chat.WithStreamingCb(func(event chat.StreamEvent) error {
+					switch event.Type {
+					case chat.StreamEventTypeContent:
+						streamed.WriteString(event.Content)
+					case chat.StreamEventTypeRoundStart:
+						roundStarts++
+					}
+					return nil
+				}),
+			)
+
+			var prefixes []string
+			resp, err := SendResumable(context.Background(), opts, scriptedSend(&prefixes, tt.attempts...))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, resp.GetText())
+			}
+			assert.Equal(t, tt.prefixes, prefixes)
+			assert.Equal(t, tt.streamed, streamed.String())
+			assert.Equal(t, 1, roundStarts)
+		})
+	}
+}
+
+func TestSendResumable_NotResumed(t *testing.T) {
+	t.Parallel()
+
+	partial := attempt{events: []chat.StreamEvent{content("The answer")}, err: errStreamReset}
+
+	t.Run("callback error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		opts := chat.ApplyOptions(chat.WithStreamResume(1), chat.WithStreamingCb(func(chat.StreamEvent) error {
+			return errStop
+		}))
+		var prefixes []string
+		_, err := SendResumable(context.Background(), opts, scriptedSend(&prefixes, partial))
+		assert.ErrorIs(t, err, errStop)
+		assert.Len(t, prefixes, 1)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var prefixes []string
+		_, err := SendResumable(ctx, chat.ApplyOptions(chat.WithStreamResume(1)), scriptedSend(&prefixes, partial))
+		assert.ErrorIs(t, err, errStreamReset)
+		assert.Len(t, prefixes, 1)
+	})
+
+	t.Run("without a callback", func(t *testing.T) {
+		var prefixes []string
+		resp, err := SendResumable(context.Background(), chat.ApplyOptions(chat.WithStreamResume(1)), scriptedSend(&prefixes, partial,
+			attempt{events: []chat.StreamEvent{content("The answer is 42.")}},
+		))
+		require.NoError(t, err)
+		assert.Equal(t, "The answer is 42.", resp.GetText())
+		assert.Equal(t, []string{"", "The answer"}, prefixes)
+	})
+}
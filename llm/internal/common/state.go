@@ -1,7 +1,11 @@
 package common
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/bpowers/go-agent/chat"
 )
@@ -9,6 +13,11 @@ import (
 // State manages message history and token usage with thread-safe operations.
 // This simple struct extracts the common pattern all providers share.
 type State struct {
+	// turn is held (has a value) while a Message call is in progress, serializing
+	// calls on the same chat. It is a channel rather than a mutex so that waiting
+	// can be abandoned when the context is done or the queue timeout expires.
+	turn chan struct{}
+
 	mu sync.Mutex
 
 	systemPrompt string
@@ -16,6 +25,10 @@ type State struct {
 
 	lastMessageUsage chat.TokenUsageDetails
 	cumulativeUsage  chat.TokenUsageDetails
+	rounds           []chat.TokenUsageDetails
+
+	// requests records the HTTP requests made during the current turn
+	requests RequestLog
 }
 
 // NewState creates a new state manager.
@@ -23,24 +36,88 @@ func NewState(systemPrompt string, initialMessages []chat.Message) *State {
 	msgs := make([]chat.Message, len(initialMessages))
 	copy(msgs, initialMessages)
 	return &State{
+		turn:         make(chan struct{}, 1),
 		systemPrompt: systemPrompt,
 		messages:     msgs,
 	}
 }
 
-// Snapshot returns a copy of the system prompt and message history.
-// This allows streaming operations to work with a consistent view of the state
-// without holding locks during long-running operations.
+// Snapshot returns the system prompt and a read-only view of the message
+// history. This allows streaming operations to work with a consistent view of
+// the state without holding locks during long-running operations.
+//
+// Snapshots are O(1): history is append-only, so the view shares its backing
+// array with the state, and its capacity is clipped so that neither later
+// appends to the state nor appends to the snapshot are visible to the other.
+// Callers must not modify the returned messages; use History for a copy.
 func (s *State) Snapshot() (systemPrompt string, messages []chat.Message) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	systemPrompt = s.systemPrompt
-	messages = make([]chat.Message, len(s.messages))
-	copy(messages, s.messages)
+	return s.systemPrompt, slices.Clip(s.messages)
+}
+
+// JSONModeInstruction is added to the system prompt of requests made with
+// chat.WithJSONMode. Providers without a native JSON mode rely on it, and
+// OpenAI's JSON mode requires the prompt to mention JSON.
+const JSONModeInstruction = "Respond only with a single valid JSON object, without any other text or Markdown code fences."
+
+// AssistantPrefixInstruction returns the instruction added to the system
+// prompt of requests made with chat.WithAssistantPrefix, for providers that
+// can't prefill the assistant's turn.
+func AssistantPrefixInstruction(prefix string) string {
+	return "Begin your response with exactly the following text, then continue from it:\n\n" + prefix
+}
+
+// RequestSnapshot is like Snapshot, but applies per-request options: the
+// system prompt is replaced by the system prompt of opts.ContextCache, or
+// else by opts.SystemPromptOverride, if either is set, and the
+// RequestInstructions are appended to it. Providers that prefill natively
+// should clear opts.AssistantPrefix first.
+func (s *State) RequestSnapshot(opts chat.Options) (systemPrompt string, messages []chat.Message) {
+	systemPrompt, messages = s.Snapshot()
+	switch {
+	case opts.ContextCache != nil:
+		systemPrompt = opts.ContextCache.SystemPrompt
+	case opts.SystemPromptOverride != "":
+		systemPrompt = opts.SystemPromptOverride
+	}
+	if instructions := RequestInstructions(opts); instructions != "" {
+		systemPrompt = appendInstruction(systemPrompt, instructions)
+	}
 	return systemPrompt, messages
 }
 
+// RequestInstructions returns the instructions per-request options add to
+// the system prompt: JSONModeInstruction in JSON mode, and
+// AssistantPrefixInstruction when opts.AssistantPrefix is set. Providers
+// whose system prompt is in a context cache send them on their own.
+func RequestInstructions(opts chat.Options) string {
+	var instructions string
+	if opts.JSONMode {
+		instructions = appendInstruction(instructions, JSONModeInstruction)
+	}
+	if opts.AssistantPrefix != "" {
+		instructions = appendInstruction(instructions, AssistantPrefixInstruction(opts.AssistantPrefix))
+	}
+	return instructions
+}
+
+func appendInstruction(systemPrompt, instruction string) string {
+	if systemPrompt == "" {
+		return instruction
+	}
+	return systemPrompt + "\n\n" + instruction
+}
+
+// SetSystemPrompt replaces the system prompt used for subsequent requests.
+func (s *State) SetSystemPrompt(prompt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.systemPrompt = prompt
+}
+
 // AppendMessages adds messages to the history and optionally updates token usage.
 func (s *State) AppendMessages(msgs []chat.Message, usage *chat.TokenUsageDetails) {
 	s.mu.Lock()
@@ -48,13 +125,69 @@ func (s *State) AppendMessages(msgs []chat.Message, usage *chat.TokenUsageDetail
 
 	s.messages = append(s.messages, msgs...)
 
-	if usage != nil && usage.TotalTokens > 0 {
-		s.lastMessageUsage = *usage
-		s.cumulativeUsage.InputTokens += usage.InputTokens
-		s.cumulativeUsage.OutputTokens += usage.OutputTokens
-		s.cumulativeUsage.TotalTokens += usage.TotalTokens
-		s.cumulativeUsage.CachedTokens += usage.CachedTokens
+	if usage != nil {
+		s.updateUsageLocked(*usage)
+	}
+}
+
+// BeginTurn starts a new message exchange, clearing the per-round usage and
+// requests recorded for the previous one, unless ctx is a dry run's (see
+// StartDryRun), which records neither. Providers call it at the start of
+// Message, and must call the returned endTurn when Message returns.
+//
+// Only one turn may be in progress at a time: BeginTurn waits for the
+// previous turn to end, or until ctx is done or timeout (if positive)
+// expires. On timeout the error wraps chat.ErrBusy.
+func (s *State) BeginTurn(ctx context.Context, timeout time.Duration) (endTurn func(), err error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case s.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		return nil, fmt.Errorf("waited %s for the previous message: %w", timeout, chat.ErrBusy)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dryRunFrom(ctx) == nil {
+		s.rounds = nil
+		s.requests.reset()
 	}
+	return func() { <-s.turn }, nil
+}
+
+// TrackRequests returns a context under which the provider SDK's HTTP
+// requests are recorded for LastRequests, when the SDK is configured with
+// RequestMiddleware or RequestTransport.
+func (s *State) TrackRequests(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestLogKey{}, &s.requests)
+}
+
+// LastRequests returns the requests recorded during the current or most
+// recent turn.
+func (s *State) LastRequests() []chat.RequestInfo {
+	return s.requests.Requests()
+}
+
+// ResponseModel returns the model the provider reported serving the last
+// request of the current or most recent turn that reported one, for
+// chat.Message.Model.
+func (s *State) ResponseModel() string {
+	requests := s.requests.Requests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].Model != "" {
+			return requests[i].Model
+		}
+	}
+	return ""
 }
 
 // History returns the system prompt and a copy of the message history.
@@ -75,19 +208,28 @@ func (s *State) TokenUsage() (chat.TokenUsage, error) {
 	return chat.TokenUsage{
 		LastMessage: s.lastMessageUsage,
 		Cumulative:  s.cumulativeUsage,
+		Rounds:      append([]chat.TokenUsageDetails(nil), s.rounds...),
 	}, nil
 }
 
 // UpdateUsage updates only the token usage without adding messages.
+// It should be called once per request to the LLM, as each call is recorded
+// as a separate round of the current message exchange.
 func (s *State) UpdateUsage(usage chat.TokenUsageDetails) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateUsageLocked(usage)
+}
+
+// updateUsageLocked records the usage of a single request (mutex must be held).
+func (s *State) updateUsageLocked(usage chat.TokenUsageDetails) {
 	if usage.TotalTokens == 0 {
 		return
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	s.lastMessageUsage = usage
+	s.rounds = append(s.rounds, usage)
 	s.cumulativeUsage.InputTokens += usage.InputTokens
 	s.cumulativeUsage.OutputTokens += usage.OutputTokens
 	s.cumulativeUsage.TotalTokens += usage.TotalTokens
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -66,11 +69,53 @@ func TestState_Snapshot(t *testing.T) {
 	assert.Equal(t, "system prompt", systemPrompt)
 	assert.Len(t, msgs, 1)
 
-	// Modifying snapshot shouldn't affect state
-	msgs[0] = chat.UserMessage("Modified")
+	// Appending to the state shouldn't affect an existing snapshot
+	s.AppendMessages([]chat.Message{chat.AssistantMessage("Hi")}, nil)
+	assert.Len(t, msgs, 1)
 
-	_, originalMsgs := s.History()
-	assert.Equal(t, "Hello", originalMsgs[0].GetText())
+	// Appending to a snapshot shouldn't affect the state, or later snapshots
+	_, msgs2 := s.Snapshot()
+	msgs = append(msgs, chat.AssistantMessage("Modified"))
+	s.AppendMessages([]chat.Message{chat.UserMessage("Bye")}, nil)
+	_, msgs3 := s.Snapshot()
+	assert.Equal(t, "Modified", msgs[1].GetText())
+	assert.Len(t, msgs2, 2)
+	assert.Equal(t, "Hi", msgs2[1].GetText())
+	require.Len(t, msgs3, 3)
+	assert.Equal(t, "Hi", msgs3[1].GetText())
+	assert.Equal(t, "Bye", msgs3[2].GetText())
+}
+
+func BenchmarkState_Snapshot(b *testing.B) {
+	for _, n := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("messages=%d", n), func(b *testing.B) {
+			msgs := make([]chat.Message, n)
+			for i := range msgs {
+				msgs[i] = chat.UserMessage("message")
+			}
+			s := NewState("system", msgs)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				s.Snapshot()
+			}
+		})
+	}
+}
+
+// BenchmarkState_Turn models a turn of a long session: snapshot the history
+// for the request, then append the exchange.
+func BenchmarkState_Turn(b *testing.B) {
+	s := NewState("system", nil)
+	for range 1000 {
+		s.AppendMessages([]chat.Message{chat.UserMessage("question"), chat.AssistantMessage("answer")}, nil)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		s.Snapshot()
+		s.AppendMessages([]chat.Message{chat.UserMessage("question"), chat.AssistantMessage("answer")}, nil)
+	}
 }
 
 func TestState_AppendMessages(t *testing.T) {
@@ -165,6 +210,46 @@ func TestState_AppendMessages(t *testing.T) {
 	})
 }
 
+func TestState_SetSystemPrompt(t *testing.T) {
+	t.Parallel()
+
+	s := NewState("original", []chat.Message{chat.UserMessage("Hello")})
+	s.SetSystemPrompt("updated")
+
+	systemPrompt, msgs := s.Snapshot()
+	assert.Equal(t, "updated", systemPrompt)
+	assert.Len(t, msgs, 1)
+}
+
+func TestState_RequestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	s := NewState("original", []chat.Message{chat.UserMessage("Hello")})
+
+	systemPrompt, msgs := s.RequestSnapshot(chat.ApplyOptions(chat.WithSystemPromptOverride("override")))
+	assert.Equal(t, "override", systemPrompt)
+	assert.Len(t, msgs, 1)
+
+	systemPrompt, _ = s.RequestSnapshot(chat.ApplyOptions())
+	assert.Equal(t, "original", systemPrompt)
+
+	systemPrompt, _ = s.RequestSnapshot(chat.ApplyOptions(chat.WithJSONMode()))
+	assert.Equal(t, "original\n\n"+JSONModeInstruction, systemPrompt)
+
+	empty := NewState("", nil)
+	systemPrompt, _ = empty.RequestSnapshot(chat.ApplyOptions(chat.WithJSONMode()))
+	assert.Equal(t, JSONModeInstruction, systemPrompt)
+
+	systemPrompt, _ = s.RequestSnapshot(chat.ApplyOptions(chat.WithAssistantPrefix("{")))
+	assert.Equal(t, "original\n\n"+AssistantPrefixInstruction("{"), systemPrompt)
+
+	// A context cache's system prompt takes precedence over an override
+	cache := &chat.ContextCache{Provider: "test", Name: "cache", SystemPrompt: "cached"}
+	systemPrompt, _ = s.RequestSnapshot(chat.ApplyOptions(chat.WithContextCache(cache), chat.WithSystemPromptOverride("override"), chat.WithJSONMode()))
+	assert.Equal(t, "cached\n\n"+JSONModeInstruction, systemPrompt)
+	assert.Equal(t, JSONModeInstruction, RequestInstructions(chat.ApplyOptions(chat.WithContextCache(cache), chat.WithJSONMode())))
+}
+
 func TestState_UpdateUsage(t *testing.T) {
 	t.Parallel()
 
@@ -206,6 +291,72 @@ func TestState_UpdateUsage(t *testing.T) {
 	assert.Equal(t, 45, tokenUsage.Cumulative.TotalTokens)  // Unchanged
 }
 
+func TestState_Rounds(t *testing.T) {
+	t.Parallel()
+
+	s := NewState("system", nil)
+
+	endTurn, err := s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+	endTurn()
+	s.UpdateUsage(chat.TokenUsageDetails{InputTokens: 10, OutputTokens: 5, TotalTokens: 15})
+	s.UpdateUsage(chat.TokenUsageDetails{}) // ignored
+	s.AppendMessages([]chat.Message{chat.AssistantMessage("done")}, &chat.TokenUsageDetails{InputTokens: 20, OutputTokens: 5, TotalTokens: 25})
+
+	usage, err := s.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, []chat.TokenUsageDetails{
+		{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		{InputTokens: 20, OutputTokens: 5, TotalTokens: 25},
+	}, usage.Rounds)
+	assert.Equal(t, 25, usage.LastMessage.TotalTokens)
+	assert.Equal(t, 40, usage.Cumulative.TotalTokens)
+
+	// A new turn starts with no rounds
+	endTurn, err = s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+	endTurn()
+	usage, err = s.TokenUsage()
+	require.NoError(t, err)
+	assert.Empty(t, usage.Rounds)
+	assert.Equal(t, 40, usage.Cumulative.TotalTokens)
+}
+
+func TestState_BeginTurnSerializes(t *testing.T) {
+	t.Parallel()
+
+	s := NewState("system", nil)
+	endTurn, err := s.BeginTurn(context.Background(), 0)
+	require.NoError(t, err)
+
+	// A second turn can't start while the first is in progress
+	_, err = s.BeginTurn(context.Background(), 10*time.Millisecond)
+	assert.ErrorIs(t, err, chat.ErrBusy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.BeginTurn(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		endTurn, err := s.BeginTurn(context.Background(), 0)
+		if err == nil {
+			endTurn()
+		}
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("second turn started before the first ended")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	endTurn()
+	<-started
+}
+
 func TestState_Concurrency(t *testing.T) {
 	t.Parallel()
 
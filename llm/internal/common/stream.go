@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// StreamTurnID replaces opts.StreamingCb with a callback that sets each
+// event's TurnID to the turn ID attached to ctx (see chat.WithTurnID). It
+// does nothing if ctx has no turn ID or there is no callback.
+func StreamTurnID(ctx context.Context, opts *chat.Options) {
+	id, callback := chat.GetTurnID(ctx), opts.StreamingCb
+	if id == "" || callback == nil {
+		return
+	}
+	opts.StreamingCb = func(event chat.StreamEvent) error {
+		event.TurnID = id
+		return callback(event)
+	}
+}
+
+// EmitToolCallDelta sends a chat.StreamEventTypeToolCallDelta event for a
+// fragment of a tool call's arguments. It does nothing if callback is nil or
+// the fragment is empty.
+func EmitToolCallDelta(callback chat.StreamCallback, id, name, fragment string) error {
+	if callback == nil || fragment == "" {
+		return nil
+	}
+	return callback(chat.StreamEvent{
+		Type:      chat.StreamEventTypeToolCallDelta,
+		Content:   fragment,
+		ToolCalls: []chat.ToolCall{{ID: id, Name: name}},
+	})
+}
+
+// EmitToolCall sends a chat.StreamEventTypeToolCall event for a call
+// assembled from a stream. It does nothing if callback is nil.
+func EmitToolCall(callback chat.StreamCallback, call StreamedToolCall) error {
+	if callback == nil {
+		return nil
+	}
+	return callback(chat.StreamEvent{
+		Type:      chat.StreamEventTypeToolCall,
+		ToolCalls: []chat.ToolCall{call.ToolCall()},
+	})
+}
+
+// EmitRound sends a chat.StreamEventTypeRoundStart or chat.StreamEventTypeRoundEnd
+// event. It does nothing if callback is nil.
+func EmitRound(callback chat.StreamCallback, typ chat.StreamEventType, index int, reason chat.RoundReason) error {
+	if callback == nil {
+		return nil
+	}
+	return callback(chat.StreamEvent{
+		Type:  typ,
+		Round: &chat.RoundStatus{Index: index, Reason: reason},
+	})
+}
+
+// RoundEndReason returns the reason a round ended, based on whether the LLM
+// called tools in it.
+func RoundEndReason(calledTools bool) chat.RoundReason {
+	if calledTools {
+		return chat.RoundReasonToolCalls
+	}
+	return chat.RoundReasonComplete
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestStreamTurnID(t *testing.T) {
+	t.Parallel()
+
+	var got []chat.StreamEvent
+	opts := chat.ApplyOptions(chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		got = append(got, event)
+		return nil
+	}))
+
+	StreamTurnID(context.Background(), &opts)
+	require.NoError(t, opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: "a"}))
+
+	StreamTurnID(chat.WithTurnID(context.Background(), "turn-1"), &opts)
+	require.NoError(t, EmitRound(opts.StreamingCb, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage))
+	require.NoError(t, opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: "b"}))
+
+	require.Len(t, got, 3)
+	assert.Empty(t, got[0].TurnID)
+	assert.Equal(t, "turn-1", got[1].TurnID)
+	assert.Equal(t, "turn-1", got[2].TurnID)
+
+	// Without a callback there is nothing to wrap
+	opts = chat.ApplyOptions()
+	StreamTurnID(chat.WithTurnID(context.Background(), "turn-1"), &opts)
+	assert.Nil(t, opts.StreamingCb)
+}
+
+// BenchmarkStreamDispatch measures passing a response's content deltas to
+// the user's callback through the wrappers providers install: turn IDs,
+// coalescing, progress events, and stream resumption.
+func BenchmarkStreamDispatch(b *testing.B) {
+	const deltas = 1000
+	noop := func(chat.StreamEvent) error { return nil }
+	ctx := chat.WithTurnID(context.Background(), "turn-1")
+
+	for _, bc := range []struct {
+		name string
+		opts []chat.Option
+	}{
+		{name: "plain"},
+		{name: "coalesced", opts: []chat.Option{chat.WithStreamCoalescing(time.Millisecond)}},
+		{name: "progress", opts: []chat.Option{chat.WithProgressEvents(time.Minute)}},
+		{name: "resumable", opts: []chat.Option{chat.WithStreamResume(1)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				opts := chat.ApplyOptions(append([]chat.Option{chat.WithStreamingCb(noop)}, bc.opts...)...)
+				StreamTurnID(ctx, &opts)
+				stop := StreamProgress(&opts)
+				_, err := SendResumable(ctx, opts, func(ctx context.Context, opts chat.Options) (chat.Message, error) {
+					for range deltas {
+						if err := opts.StreamingCb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: "token "}); err != nil {
+							return chat.Message{}, err
+						}
+					}
+					return chat.Message{}, EmitRound(opts.StreamingCb, chat.StreamEventTypeRoundEnd, 0, chat.RoundReasonComplete)
+				})
+				stop()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const (
+	textToolCallOpen    = "<tool_call>"
+	textToolCallClose   = "</tool_call>"
+	textToolResultClose = "</tool_result>"
+)
+
+// TextToolInstructions returns the system prompt section that describes
+// tools to a model using chat.WithTextToolProtocol, and how to call them.
+func TextToolInstructions(tools []chat.Tool) string {
+	var b strings.Builder
+	b.WriteString("You can call the tools described below. To call a tool, write a block like this on its own lines, then end your response to wait for the result:\n\n")
+	b.WriteString(textToolCallOpen + "\n")
+	b.WriteString(`{"name": "ToolName", "arguments": {"argument": "value"}}` + "\n")
+	b.WriteString(textToolCallClose + "\n\n")
+	b.WriteString("The arguments must match the tool's input schema. To call several tools at once, write a block for each. ")
+	b.WriteString("The results are sent back to you in <tool_result> blocks, in the order of the calls; never write <tool_result> blocks yourself. ")
+	b.WriteString("When you don't need a tool, respond normally, without a <tool_call> block.\n\n")
+	b.WriteString("# Tools")
+	for _, tool := range tools {
+		var def struct {
+			InputSchema json.RawMessage `json:"inputSchema"`
+		}
+		_ = json.Unmarshal([]byte(tool.MCPJsonSchema()), &def)
+		fmt.Fprintf(&b, "\n\n## %s\n\n", tool.Name())
+		if description := tool.Description(); description != "" {
+			b.WriteString(description + "\n\n")
+		}
+		schema := string(def.InputSchema)
+		if schema == "" {
+			schema = `{"type": "object"}`
+		}
+		b.WriteString("Input schema: " + schema)
+	}
+	return b.String()
+}
+
+// textToolCall is a tool call written in a response's text.
+type textToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+	// err is why the call couldn't be parsed, if it couldn't
+	err error
+}
+
+// parseTextToolCalls returns the tool calls written in text, in order. A
+// block without a closing tag, as when the response was cut off, runs to
+// the end of the text.
+func parseTextToolCalls(text string) []textToolCall {
+	var calls []textToolCall
+	for {
+		_, rest, ok := strings.Cut(text, textToolCallOpen)
+		if !ok {
+			return calls
+		}
+		block, after, _ := strings.Cut(rest, textToolCallClose)
+		text = after
+		calls = append(calls, parseTextToolCall(block))
+	}
+}
+
+func parseTextToolCall(block string) textToolCall {
+	block = strings.TrimSpace(block)
+	// Models sometimes fence the JSON as they would in Markdown
+	if strings.HasPrefix(block, "```") {
+		block = strings.TrimPrefix(block, "```")
+		block = strings.TrimPrefix(block, "json")
+		block = strings.TrimSuffix(block, "```")
+	}
+
+	var call textToolCall
+	if err := json.Unmarshal([]byte(block), &call); err != nil {
+		return textToolCall{err: fmt.Errorf("the tool call isn't a valid JSON object: %w", err)}
+	}
+	if call.Name == "" {
+		return textToolCall{err: errors.New(`the tool call has no "name"`)}
+	}
+	if len(call.Arguments) == 0 || string(call.Arguments) == "null" {
+		call.Arguments = json.RawMessage("{}")
+	}
+	return call
+}
+
+// SendTextTools sends msg with send, running the tool calls the model
+// writes in its responses when opts.TextToolProtocol is set (see
+// chat.WithTextToolProtocol): it describes tools in the system prompt,
+// executes the calls in each response, and sends their results back as a
+// user message until a response calls no tools, which it returns. send
+// must append each exchange to the chat's history and must not send tools
+// natively (see Tools.ForRequest). Without the option, or without tools,
+// SendTextTools just calls send.
+func SendTextTools(ctx context.Context, state *State, tools *Tools, opts chat.Options, msg chat.Message, send func(context.Context, chat.Message, chat.Options) (chat.Message, error)) (chat.Message, error) {
+	all := tools.GetAll()
+	if !opts.TextToolProtocol || len(all) == 0 {
+		return send(ctx, msg, opts)
+	}
+	if opts.ContextCache != nil {
+		return chat.Message{}, errors.New("chat.WithTextToolProtocol can't be combined with chat.WithContextCache")
+	}
+
+	systemPrompt, _ := state.Snapshot()
+	if opts.SystemPromptOverride != "" {
+		systemPrompt = opts.SystemPromptOverride
+	}
+	sendOpts := opts
+	sendOpts.SystemPromptOverride = appendInstruction(systemPrompt, TextToolInstructions(all))
+	// Each send is one round to the provider; the rounds are numbered here
+	callback := opts.StreamingCb
+	if callback != nil {
+		sendOpts.StreamingCb = func(event chat.StreamEvent) error {
+			if event.Type == chat.StreamEventTypeRoundStart || event.Type == chat.StreamEventTypeRoundEnd {
+				return nil
+			}
+			return callback(event)
+		}
+	}
+
+	if err := EmitRound(callback, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+		return chat.Message{}, err
+	}
+	for round := 0; ; round++ {
+		resp, err := send(ctx, msg, sendOpts)
+		if err != nil {
+			return resp, err
+		}
+		calls := parseTextToolCalls(resp.GetText())
+		if err := EmitRound(callback, chat.StreamEventTypeRoundEnd, round, RoundEndReason(len(calls) > 0)); err != nil {
+			return chat.Message{}, err
+		}
+		if len(calls) == 0 {
+			return resp, nil
+		}
+
+		if msg, err = runTextToolCalls(ctx, tools, callback, round, calls); err != nil {
+			return chat.Message{}, err
+		}
+		if err := EmitRound(callback, chat.StreamEventTypeRoundStart, round+1, chat.RoundReasonToolResults); err != nil {
+			return chat.Message{}, err
+		}
+	}
+}
+
+// runTextToolCalls executes a round's calls, returning the user message
+// that reports their results to the model.
+func runTextToolCalls(ctx context.Context, tools *Tools, callback chat.StreamCallback, round int, calls []textToolCall) (chat.Message, error) {
+	var text strings.Builder
+	var images []chat.ImageContent
+	for i, call := range calls {
+		toolCall := chat.ToolCall{ID: fmt.Sprintf("text_call_%d_%d", round, i), Name: call.Name, Arguments: call.Arguments}
+		var result chat.ToolResult
+		if call.err != nil {
+			result = chat.ToolResult{ToolCallID: toolCall.ID, Error: call.err.Error()}
+		} else {
+			if callback != nil {
+				if err := callback(chat.StreamEvent{Type: chat.StreamEventTypeToolCall, ToolCalls: []chat.ToolCall{toolCall}}); err != nil {
+					return chat.Message{}, fmt.Errorf("callback error: %w", err)
+				}
+			}
+			output, callImages, err := tools.ExecuteWithImages(ctx, call.Name, string(call.Arguments))
+			result = BuildToolResult(call.Name, toolCall.ID, output, err)
+			result.Images = callImages
+			images = append(images, callImages...)
+		}
+		if callback != nil {
+			if err := callback(chat.StreamEvent{Type: chat.StreamEventTypeToolResult, ToolResults: []chat.ToolResult{result}}); err != nil {
+				return chat.Message{}, fmt.Errorf("callback error: %w", err)
+			}
+		}
+
+		content := result.Content
+		if result.Error != "" {
+			content = FormatToolResultError(result)
+		}
+		if text.Len() > 0 {
+			text.WriteString("\n\n")
+		}
+		fmt.Fprintf(&text, "<tool_result name=%q>\n%s\n%s", call.Name, content, textToolResultClose)
+	}
+
+	msg := chat.UserMessage(text.String())
+	for _, image := range images {
+		msg.AddImage(image)
+	}
+	return msg, nil
+}
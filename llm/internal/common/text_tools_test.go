@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestParseTextToolCalls(t *testing.T) {
+	t.Parallel()
+
+	calls := parseTextToolCalls("Let me look.\n<tool_call>\n{\"name\": \"lookup\", \"arguments\": {\"q\": \"a\"}}\n</tool_call>\n" +
+		"<tool_call>```json\n{\"name\": \"list\"}\n```</tool_call>\n" +
+		"<tool_call>{\"arguments\": {}}</tool_call>\n" +
+		"<tool_call>not json</tool_call>\n" +
+		"<tool_call>{\"name\": \"cut\", \"arguments\": {\"x\": 1}}")
+	require.Len(t, calls, 5)
+	assert.Equal(t, textToolCall{Name: "lookup", Arguments: json.RawMessage(`{"q": "a"}`)}, calls[0])
+	assert.Equal(t, textToolCall{Name: "list", Arguments: json.RawMessage(`{}`)}, calls[1])
+	assert.ErrorContains(t, calls[2].err, `no "name"`)
+	assert.ErrorContains(t, calls[3].err, "isn't a valid JSON object")
+	assert.Equal(t, "cut", calls[4].Name, "an unclosed block runs to the end")
+
+	assert.Empty(t, parseTextToolCalls("No tools needed."))
+}
+
+func TestTextToolInstructions(t *testing.T) {
+	t.Parallel()
+
+	instructions := TextToolInstructions([]chat.Tool{
+		mockTool{name: "lookup", description: "Look up a word", schema: `{"name":"lookup","inputSchema":{"type":"object","properties":{"q":{"type":"string"}}}}`},
+		mockTool{name: "list", schema: `{}`},
+	})
+	assert.Contains(t, instructions, "<tool_call>")
+	assert.Contains(t, instructions, "## lookup\n\nLook up a word\n\nInput schema: {\"type\":\"object\",\"properties\":{\"q\":{\"type\":\"string\"}}}")
+	assert.Contains(t, instructions, "## list\n\nInput schema: {\"type\": \"object\"}")
+}
+
+func TestSendTextTools(t *testing.T) {
+	t.Parallel()
+
+	tools := NewTools()
+	require.NoError(t, tools.Register(mockTool{
+		name:   "lookup",
+		schema: noArgsSchema,
+		handler: func(ctx context.Context, input string) string {
+			return `{"found": ` + input + `}`
+		},
+	}))
+	state := NewState("Be brief.", nil)
+	responses := []string{
+		"Checking.\n<tool_call>\n{\"name\": \"lookup\", \"arguments\": {\"q\": 1}}\n</tool_call>\n<tool_call>\n{\"name\": \"missing\"}\n</tool_call>",
+		"Found it.",
+	}
+
+	var sent []chat.Message
+	var sentOpts []chat.Options
+	send := func(ctx context.Context, msg chat.Message, opts chat.Options) (chat.Message, error) {
+		sent = append(sent, msg)
+		sentOpts = append(sentOpts, opts)
+		// Providers' own round events are replaced
+		require.NoError(t, EmitRound(opts.StreamingCb, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage))
+		resp := chat.AssistantMessage(responses[len(sent)-1])
+		state.AppendMessages([]chat.Message{msg, resp}, nil)
+		return resp, nil
+	}
+
+	var events []chat.StreamEvent
+	opts := chat.ApplyOptions(chat.WithTextToolProtocol(), chat.WithStreamingCb(func(event chat.StreamEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+	resp, err := SendTextTools(context.Background(), state, tools, opts, chat.UserMessage("Find 1"), send)
+	require.NoError(t, err)
+	assert.Equal(t, "Found it.", resp.GetText())
+
+	require.Len(t, sent, 2)
+	assert.True(t, strings.HasPrefix(sentOpts[0].SystemPromptOverride, "Be brief.\n\nYou can call the tools"))
+	assert.Contains(t, sentOpts[0].SystemPromptOverride, "## lookup")
+	assert.Equal(t, chat.UserRole, sent[1].Role)
+	results := sent[1].GetText()
+	assert.Contains(t, results, "<tool_result name=\"lookup\">\n{\"found\": {\"q\": 1}}\n</tool_result>")
+	assert.Contains(t, results, "<tool_result name=\"missing\">\n{\"error\":")
+	assert.Contains(t, results, ToolErrorCodeNotFound)
+
+	var types []chat.StreamEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []chat.StreamEventType{
+		chat.StreamEventTypeRoundStart,
+		chat.StreamEventTypeRoundEnd,
+		chat.StreamEventTypeToolCall,
+		chat.StreamEventTypeToolResult,
+		chat.StreamEventTypeToolCall,
+		chat.StreamEventTypeToolResult,
+		chat.StreamEventTypeRoundStart,
+		chat.StreamEventTypeRoundEnd,
+	}, types)
+	assert.Equal(t, chat.RoundReasonToolCalls, events[1].Round.Reason)
+	assert.Equal(t, 1, events[6].Round.Index)
+	assert.Equal(t, "lookup", events[2].ToolCalls[0].Name)
+
+	// Without the option, messages are just sent
+	sent = nil
+	_, err = SendTextTools(context.Background(), state, tools, chat.ApplyOptions(), chat.UserMessage("Again"), func(ctx context.Context, msg chat.Message, opts chat.Options) (chat.Message, error) {
+		sent = append(sent, msg)
+		return chat.AssistantMessage("<tool_call>{\"name\": \"lookup\"}</tool_call>"), nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, sent, 1)
+
+	_, err = SendTextTools(context.Background(), state, tools, chat.ApplyOptions(chat.WithTextToolProtocol(), chat.WithContextCache(&chat.ContextCache{})), chat.UserMessage("Again"), send)
+	assert.ErrorContains(t, err, "WithContextCache")
+}
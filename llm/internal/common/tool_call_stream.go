@@ -0,0 +1,147 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// MaxStreamedToolCalls bounds the index of a streamed tool call, so a
+// malformed chunk can't make a ToolCallAccumulator allocate without limit.
+const MaxStreamedToolCalls = 256
+
+// ToolCallFragment is one streamed piece of a tool call, as sent in the
+// chunks of OpenAI's Chat Completions API or Claude's input_json_delta
+// events. Fragments of the same call share an Index; the first usually
+// carries the ID and name, and later ones only Arguments.
+type ToolCallFragment struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// StreamedToolCall is a tool call assembled from fragments. Arguments is the
+// concatenated text the provider sent, which may not be valid JSON.
+type StreamedToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// ToolCall returns the call as a chat.ToolCall.
+func (c StreamedToolCall) ToolCall() chat.ToolCall {
+	var args json.RawMessage
+	if c.Arguments != "" {
+		args = json.RawMessage(c.Arguments)
+	}
+	return chat.ToolCall{ID: c.ID, Name: c.Name, Arguments: args}
+}
+
+type accumulatedToolCall struct {
+	StreamedToolCall
+	args    strings.Builder
+	emitted bool
+}
+
+// ToolCallAccumulator assembles tool calls from fragments that may interleave
+// across calls. The zero value is ready to use.
+type ToolCallAccumulator struct {
+	calls []*accumulatedToolCall
+}
+
+// Add merges f into the call at f.Index. A fragment with an ID starts the
+// call's arguments over. It returns the call and whether it just became
+// complete: it has an ID and name and its arguments are a complete JSON
+// value. Each call is reported complete at most once.
+func (a *ToolCallAccumulator) Add(f ToolCallFragment) (StreamedToolCall, bool, error) {
+	if f.Index < 0 || f.Index >= MaxStreamedToolCalls {
+		return StreamedToolCall{}, false, fmt.Errorf("tool call index %d out of range", f.Index)
+	}
+	for len(a.calls) <= f.Index {
+		a.calls = append(a.calls, &accumulatedToolCall{})
+	}
+
+	call := a.calls[f.Index]
+	if f.ID != "" {
+		call.ID = f.ID
+		call.args.Reset()
+	}
+	if f.Name != "" {
+		call.Name = f.Name
+	}
+	if f.Arguments != "" {
+		call.args.WriteString(f.Arguments)
+		call.Arguments = call.args.String()
+	}
+
+	if call.emitted || call.ID == "" || call.Name == "" || !isCompleteJSON(call.Arguments) {
+		return call.StreamedToolCall, false, nil
+	}
+	call.emitted = true
+	return call.StreamedToolCall, true, nil
+}
+
+// Finish ends the call at index, for providers that mark where each call's
+// fragments end, like Claude's content_block_stop. A call that received no
+// arguments gets defaultArgs. It returns the call and whether it is newly
+// complete; unlike Add, it doesn't require the arguments to be valid JSON,
+// leaving malformed arguments to PrepareToolArguments. Indexes that never
+// received a call, like text blocks, are never complete.
+func (a *ToolCallAccumulator) Finish(index int, defaultArgs string) (StreamedToolCall, bool) {
+	if index < 0 || index >= len(a.calls) {
+		return StreamedToolCall{}, false
+	}
+	call := a.calls[index]
+	if call.ID == "" && call.Name == "" {
+		return StreamedToolCall{}, false
+	}
+	if call.Arguments == "" {
+		call.Arguments = defaultArgs
+	}
+	if call.emitted {
+		return call.StreamedToolCall, false
+	}
+	call.emitted = true
+	return call.StreamedToolCall, true
+}
+
+// Calls returns the assembled calls in index order, skipping indexes that
+// never received an ID or name.
+func (a *ToolCallAccumulator) Calls() []StreamedToolCall {
+	var calls []StreamedToolCall
+	for _, c := range a.calls {
+		if c.ID == "" && c.Name == "" {
+			continue
+		}
+		calls = append(calls, c.StreamedToolCall)
+	}
+	return calls
+}
+
+// isCompleteJSON reports whether s is a complete, valid JSON value.
+func isCompleteJSON(s string) bool {
+	return strings.TrimSpace(s) != "" && json.Valid([]byte(s))
+}
+
+// StreamUsage converts the token counts in a streamed usage chunk to
+// chat.TokenUsageDetails. It returns false if the chunk carries no usage,
+// which some providers send on every chunk but the last. A missing total is
+// the sum of input and output, and negative counts are treated as zero.
+func StreamUsage(input, output, total int64) (chat.TokenUsageDetails, bool) {
+	input, output, total = max(input, 0), max(output, 0), max(total, 0)
+	if input == 0 {
+		return chat.TokenUsageDetails{}, false
+	}
+	if total == 0 {
+		total = input + min(output, math.MaxInt64-input)
+	}
+	return chat.TokenUsageDetails{
+		InputTokens:  int(input),
+		OutputTokens: int(output),
+		TotalTokens:  int(total),
+	}, true
+}
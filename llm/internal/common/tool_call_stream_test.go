@@ -0,0 +1,203 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestToolCallAccumulator(t *testing.T) {
+	t.Parallel()
+
+	var a ToolCallAccumulator
+	assert.Nil(t, a.Calls())
+
+	// Two calls whose fragments interleave
+	fragments := []ToolCallFragment{
+		{Index: 0, ID: "call_a", Name: "read_file", Arguments: `{"pa`},
+		{Index: 1, ID: "call_b", Name: "list_dir"},
+		{Index: 1, Arguments: `{"dir": "."`},
+		{Index: 0, Arguments: `th": "a.txt"}`},
+		{Index: 1, Arguments: `}`},
+		{Index: 1, Arguments: ` `},
+	}
+	var completed []StreamedToolCall
+	for _, f := range fragments {
+		call, complete, err := a.Add(f)
+		require.NoError(t, err)
+		if complete {
+			completed = append(completed, call)
+		}
+	}
+
+	want := []StreamedToolCall{
+		{ID: "call_a", Name: "read_file", Arguments: `{"path": "a.txt"}`},
+		{ID: "call_b", Name: "list_dir", Arguments: `{"dir": "."}`},
+	}
+	assert.Equal(t, want, completed)
+	want[1].Arguments += " "
+	assert.Equal(t, want, a.Calls())
+}
+
+func TestToolCallAccumulator_Malformed(t *testing.T) {
+	t.Parallel()
+
+	var a ToolCallAccumulator
+	for _, index := range []int{-1, MaxStreamedToolCalls} {
+		_, _, err := a.Add(ToolCallFragment{Index: index, ID: "call_x", Name: "x"})
+		assert.Error(t, err)
+	}
+
+	// A skipped index and a call cut off mid-arguments
+	_, complete, err := a.Add(ToolCallFragment{Index: 2, ID: "call_c", Name: "c", Arguments: `{"a": `})
+	require.NoError(t, err)
+	assert.False(t, complete)
+	assert.Equal(t, []StreamedToolCall{{ID: "call_c", Name: "c", Arguments: `{"a": `}}, a.Calls())
+
+	// Arguments without an ID aren't complete until the ID arrives, and
+	// a new ID starts the arguments over
+	_, complete, err = a.Add(ToolCallFragment{Index: 0, Name: "d", Arguments: `{}`})
+	require.NoError(t, err)
+	assert.False(t, complete)
+	call, complete, err := a.Add(ToolCallFragment{Index: 0, ID: "call_d", Arguments: `{"b": 1}`})
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, StreamedToolCall{ID: "call_d", Name: "d", Arguments: `{"b": 1}`}, call)
+}
+
+func TestToolCallAccumulator_Finish(t *testing.T) {
+	t.Parallel()
+
+	// Claude's content blocks: text, then tool calls with and without deltas
+	var a ToolCallAccumulator
+	_, ok := a.Finish(0, "")
+	assert.False(t, ok)
+
+	for _, f := range []ToolCallFragment{
+		{Index: 1, ID: "toolu_a", Name: "read_file"},
+		{Index: 1, Arguments: `{"path": `},
+		{Index: 2, ID: "toolu_b", Name: "list_dir"},
+	} {
+		_, complete, err := a.Add(f)
+		require.NoError(t, err)
+		assert.False(t, complete)
+	}
+
+	// Malformed arguments are complete once the block ends
+	call, ok := a.Finish(1, `{}`)
+	assert.True(t, ok)
+	assert.Equal(t, StreamedToolCall{ID: "toolu_a", Name: "read_file", Arguments: `{"path": `}, call)
+	_, ok = a.Finish(1, `{}`)
+	assert.False(t, ok)
+
+	call, ok = a.Finish(2, `{}`)
+	assert.True(t, ok)
+	assert.Equal(t, StreamedToolCall{ID: "toolu_b", Name: "list_dir", Arguments: `{}`}, call)
+
+	// Calls reported complete by Add aren't reported again
+	call, complete, err := a.Add(ToolCallFragment{Index: 3, ID: "toolu_c", Name: "c", Arguments: `{"x": 1}`})
+	require.NoError(t, err)
+	assert.True(t, complete)
+	_, ok = a.Finish(3, "")
+	assert.False(t, ok)
+
+	assert.Len(t, a.Calls(), 3)
+	assert.Equal(t, call, a.Calls()[2])
+}
+
+func TestStreamUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		input, output, total int64
+		want                 chat.TokenUsageDetails
+		ok                   bool
+	}{
+		{"complete", 10, 5, 15, chat.TokenUsageDetails{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}, true},
+		{"missing usage", 0, 0, 0, chat.TokenUsageDetails{}, false},
+		{"missing total", 10, 5, 0, chat.TokenUsageDetails{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}, true},
+		{"negative", 10, -5, -1, chat.TokenUsageDetails{InputTokens: 10, TotalTokens: 10}, true},
+		{"negative input", -10, 5, 15, chat.TokenUsageDetails{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := StreamUsage(tt.input, tt.output, tt.total)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+// FuzzToolCallAccumulator splits arguments into fragments spread across
+// interleaved calls, and checks that the calls reassemble exactly and are
+// reported complete at most once, only with valid JSON.
+func FuzzToolCallAccumulator(f *testing.F) {
+	f.Add(`{"path": "a.txt"}`, uint8(3), uint8(2))
+	f.Add(`{"a": [1, 2`, uint8(1), uint8(1))
+	f.Add(`{"s": "é\"}"}`, uint8(2), uint8(3))
+	f.Add(``, uint8(0), uint8(1))
+	f.Add(`not json`, uint8(5), uint8(4))
+
+	f.Fuzz(func(t *testing.T, args string, chunkSize, numCalls uint8) {
+		size := int(chunkSize%16) + 1
+		n := int(numCalls%4) + 1
+
+		var a ToolCallAccumulator
+		completions := make(map[int]int)
+		for i := range n {
+			call, complete, err := a.Add(ToolCallFragment{Index: i, ID: "call", Name: "tool"})
+			require.NoError(t, err)
+			if complete {
+				completions[i]++
+				assert.True(t, json.Valid([]byte(call.Arguments)))
+			}
+		}
+		for start := 0; start < len(args); start += size {
+			fragment := args[start:min(start+size, len(args))]
+			for i := range n {
+				call, complete, err := a.Add(ToolCallFragment{Index: i, Arguments: fragment})
+				require.NoError(t, err)
+				if complete {
+					completions[i]++
+					assert.True(t, json.Valid([]byte(call.Arguments)))
+				}
+			}
+		}
+
+		calls := a.Calls()
+		require.Len(t, calls, n)
+		for i, call := range calls {
+			assert.Equal(t, args, call.Arguments)
+			assert.LessOrEqual(t, completions[i], 1)
+			if strings.TrimSpace(args) != "" && json.Valid([]byte(args)) {
+				assert.Equal(t, 1, completions[i])
+			}
+		}
+	})
+}
+
+// FuzzStreamUsage checks that usage parsed from a chunk is never negative
+// and is only reported when the chunk has input tokens.
+func FuzzStreamUsage(f *testing.F) {
+	f.Add(int64(10), int64(5), int64(15))
+	f.Add(int64(0), int64(0), int64(0))
+	f.Add(int64(-1), int64(5), int64(0))
+
+	f.Fuzz(func(t *testing.T, input, output, total int64) {
+		usage, ok := StreamUsage(input, output, total)
+		if !ok {
+			assert.Zero(t, usage)
+			return
+		}
+		assert.Positive(t, usage.InputTokens)
+		assert.GreaterOrEqual(t, usage.OutputTokens, 0)
+		assert.Positive(t, usage.TotalTokens)
+	})
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ToolErrorCodeNotFound is the error code used when the model calls a tool
+// that isn't registered.
+const ToolErrorCodeNotFound = "tool_not_found"
+
+const (
+	retryableHint    = "Retrying with different arguments may succeed."
+	notRetryableHint = "Retrying with different arguments will not help."
+)
+
+// ToolError is a tool failure with a machine-readable code and a hint about
+// whether the model should retry with different arguments. Tools report one
+// by returning its JSON encoding, e.g.
+// {"error": "no such file", "errorCode": "not_found", "retryable": true}.
+// Results with an "error" but no "errorCode" are passed to the model as
+// ordinary tool output, as before.
+type ToolError struct {
+	Message   string `json:"error"`
+	Code      string `json:"errorCode"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+// ParseToolError returns the ToolError encoded in a tool's raw output, if any.
+func ParseToolError(raw string) (*ToolError, bool) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		return nil, false
+	}
+	var te ToolError
+	if err := json.Unmarshal([]byte(raw), &te); err != nil {
+		return nil, false
+	}
+	if te.Code == "" || te.Message == "" {
+		return nil, false
+	}
+	return &te, true
+}
+
+// ToolResultErrorFields returns the fields describing a failed tool result to
+// the model. Structured errors include their code, retryability, and a hint
+// about whether retrying is worthwhile.
+func ToolResultErrorFields(tr chat.ToolResult) map[string]any {
+	fields := map[string]any{"error": tr.Error}
+	if tr.ErrorCode == "" {
+		return fields
+	}
+	fields["errorCode"] = tr.ErrorCode
+	fields["retryable"] = tr.Retryable
+	if tr.Retryable {
+		fields["hint"] = retryableHint
+	} else {
+		fields["hint"] = notRetryableHint
+	}
+	return fields
+}
+
+// FormatToolResultError formats a failed tool result as a JSON string for the
+// model, including the error code and retry hint when present.
+func FormatToolResultError(tr chat.ToolResult) string {
+	if tr.ErrorCode == "" {
+		return FormatToolErrorJSON(tr.Error)
+	}
+	payload, err := json.Marshal(ToolResultErrorFields(tr))
+	if err != nil {
+		return fmt.Sprintf(`{"error": %q}`, tr.Error)
+	}
+	return string(payload)
+}
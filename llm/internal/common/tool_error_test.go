@@ -0,0 +1,72 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestParseToolError(t *testing.T) {
+	te, ok := ParseToolError(`{"error":"no such file","errorCode":"not_found","retryable":true}`)
+	require.True(t, ok)
+	assert.Equal(t, &ToolError{Message: "no such file", Code: "not_found", Retryable: true}, te)
+
+	// Plain error payloads and successful results aren't structured errors
+	for _, raw := range []string{
+		`{"error":"no such file"}`,
+		`{"errorCode":"not_found"}`,
+		`{"content":"hello","error":null}`,
+		`not json`,
+		``,
+	} {
+		_, ok := ParseToolError(raw)
+		assert.False(t, ok)
+	}
+}
+
+func TestToolsExecuteStructuredError(t *testing.T) {
+	tools := NewTools()
+	raw := `{"error":"path is a directory","errorCode":"invalid_argument","retryable":true}`
+	require.NoError(t, tools.Register(mockTool{
+		name:   "read",
+		schema: noArgsSchema,
+		handler: func(ctx context.Context, input string) string {
+			return raw
+		},
+	}))
+
+	result, err := tools.Execute(context.Background(), "read", "{}")
+	var te *ToolError
+	require.True(t, errors.As(err, &te))
+	assert.Equal(t, "invalid_argument", te.Code)
+	assert.True(t, te.Retryable)
+	assert.Equal(t, raw, result)
+
+	tr := BuildToolResult("read", "call-1", result, err)
+	assert.Equal(t, "path is a directory", tr.Error)
+	assert.Equal(t, "invalid_argument", tr.ErrorCode)
+	assert.True(t, tr.Retryable)
+	assert.Empty(t, tr.Content)
+
+	_, err = tools.Execute(context.Background(), "missing", "{}")
+	require.True(t, errors.As(err, &te))
+	assert.Equal(t, ToolErrorCodeNotFound, te.Code)
+	assert.False(t, te.Retryable)
+}
+
+func TestFormatToolResultError(t *testing.T) {
+	assert.JSONEq(t, `{"error":"boom"}`, FormatToolResultError(chat.ToolResult{Error: "boom"}))
+
+	assert.JSONEq(t,
+		`{"error":"date must be YYYY-MM-DD","errorCode":"invalid_argument","retryable":true,"hint":"Retrying with different arguments may succeed."}`,
+		FormatToolResultError(chat.ToolResult{Error: "date must be YYYY-MM-DD", ErrorCode: "invalid_argument", Retryable: true}))
+
+	assert.JSONEq(t,
+		`{"error":"permission denied","errorCode":"permission_denied","retryable":false,"hint":"Retrying with different arguments will not help."}`,
+		FormatToolResultError(chat.ToolResult{Error: "permission denied", ErrorCode: "permission_denied"}))
+}
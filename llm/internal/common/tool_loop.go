@@ -0,0 +1,137 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ToolLoop runs the rounds of tool calls that follow a response calling
+// tools, the same way for every provider: it executes each round's calls,
+// records the assistant and tool messages in the chat's history, and sends
+// the results back until a response calls no tools. Providers supply Send,
+// which converts the turn's messages to their API's format and streams the
+// next response.
+type ToolLoop struct {
+	State *State
+	Tools *Tools
+
+	// Provider and Model label the arguments recorded in
+	// ToolArgumentMetrics, and RepairArgs repairs malformed arguments with
+	// RepairJSON before the tools see them.
+	Provider   string
+	Model      string
+	RepairArgs bool
+
+	Logger *slog.Logger
+
+	// Send streams the response to the turn's messages so far: the user
+	// message, then each round's assistant and tool messages. They follow
+	// the history snapshot taken before the loop started. Send returns the
+	// assistant's response, with any tool calls, and its token usage.
+	Send func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error)
+}
+
+// Run records userMsg, then runs tool rounds starting from resp, the
+// assistant's response to it that called tools. userMsg is recorded as
+// given, so it should include any system reminder sent with it. The final
+// response is recorded in history and returned.
+func (l *ToolLoop) Run(ctx context.Context, callback chat.StreamCallback, userMsg, resp chat.Message) (chat.Message, error) {
+	l.State.AppendMessages([]chat.Message{userMsg}, nil)
+	msgs := []chat.Message{userMsg}
+
+	for round := 1; resp.HasToolCalls(); round++ {
+		l.Logger.DebugContext(ctx, "processing tool calls", "round", round, "count", len(resp.GetToolCalls()))
+
+		assistantMsg, results, err := l.execute(ctx, callback, resp)
+		if err != nil {
+			return chat.Message{}, fmt.Errorf("failed to execute tool calls: %w", err)
+		}
+		roundMsgs := []chat.Message{assistantMsg}
+		if len(results) > 0 {
+			toolMsg := chat.Message{Role: chat.ToolRole}
+			for _, tr := range results {
+				toolMsg.AddToolResult(tr)
+			}
+			// The reminder follows the results, as Claude requires tool
+			// results to immediately follow the calls
+			if reminder := SystemReminder(ctx); reminder != "" {
+				toolMsg.Contents = append(toolMsg.Contents, chat.Content{SystemReminder: reminder})
+			}
+			roundMsgs = append(roundMsgs, toolMsg)
+		}
+		l.State.AppendMessages(roundMsgs, nil)
+		msgs = append(msgs, roundMsgs...)
+
+		if err := EmitRound(callback, chat.StreamEventTypeRoundStart, round, chat.RoundReasonToolResults); err != nil {
+			return chat.Message{}, err
+		}
+		var usage chat.TokenUsageDetails
+		resp, usage, err = l.Send(ctx, msgs)
+		if err != nil {
+			return chat.Message{}, err
+		}
+		l.State.UpdateUsage(usage)
+		if err := EmitRound(callback, chat.StreamEventTypeRoundEnd, round, RoundEndReason(resp.HasToolCalls())); err != nil {
+			return chat.Message{}, err
+		}
+	}
+
+	if resp.GetText() == "" {
+		l.Logger.WarnContext(ctx, "final response after tool execution has empty content")
+	}
+	l.State.AppendMessages([]chat.Message{resp}, nil)
+	return resp, nil
+}
+
+// execute runs the tool calls in resp, sending a tool result event for
+// each. It returns resp with any repaired arguments in place of the
+// originals, so history records what the tools were called with.
+func (l *ToolLoop) execute(ctx context.Context, callback chat.StreamCallback, resp chat.Message) (chat.Message, []chat.ToolResult, error) {
+	msg := chat.Message{Role: resp.Role, Contents: make([]chat.Content, len(resp.Contents))}
+	copy(msg.Contents, resp.Contents)
+
+	var results []chat.ToolResult
+	for i, content := range msg.Contents {
+		if content.ToolCall == nil {
+			continue
+		}
+		call := *content.ToolCall
+
+		args := PrepareToolArguments(l.Provider, l.Model, string(call.Arguments), l.RepairArgs)
+		if args != string(call.Arguments) {
+			call.Arguments = json.RawMessage(args)
+			msg.Contents[i].ToolCall = &call
+		}
+		// Calls without arguments, like Gemini's to tools without
+		// parameters, are made with an empty object
+		if strings.TrimSpace(args) == "" {
+			args = "{}"
+		}
+
+		result, images, err := l.Tools.ExecuteWithImages(ctx, call.Name, args)
+		if err != nil {
+			l.Logger.DebugContext(ctx, "tool execution failed", "name", call.Name, "args", args, "error", err)
+		} else {
+			l.Logger.DebugContext(ctx, "tool executed", "name", call.Name, "args", args, "result", result)
+		}
+		toolResult := BuildToolResult(call.Name, call.ID, result, err)
+		toolResult.Images = images
+
+		if callback != nil {
+			event := chat.StreamEvent{
+				Type:        chat.StreamEventTypeToolResult,
+				ToolResults: []chat.ToolResult{toolResult},
+			}
+			if err := callback(event); err != nil {
+				return chat.Message{}, nil, fmt.Errorf("callback error: %w", err)
+			}
+		}
+		results = append(results, toolResult)
+	}
+	return msg, results, nil
+}
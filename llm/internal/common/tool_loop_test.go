@@ -0,0 +1,198 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func newTestToolLoop(t *testing.T, responses []chat.Message) (*ToolLoop, *[][]chat.Message, *[]string) {
+	t.Helper()
+
+	var inputs []string
+	tools := NewTools()
+	require.NoError(t, tools.Register(mockTool{
+		name:   "lookup",
+		schema: noArgsSchema,
+		handler: func(ctx context.Context, input string) string {
+			inputs = append(inputs, input)
+			return `{"found": true}`
+		},
+	}))
+
+	var sent [][]chat.Message
+	loop := &ToolLoop{
+		State:      NewState("system", nil),
+		Tools:      tools,
+		Provider:   "test",
+		Model:      t.Name(),
+		RepairArgs: true,
+		Logger:     slog.Default(),
+		Send: func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error) {
+			sent = append(sent, append([]chat.Message(nil), msgs...))
+			resp := responses[0]
+			responses = responses[1:]
+			return resp, chat.TokenUsageDetails{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}, nil
+		},
+	}
+	return loop, &sent, &inputs
+}
+
+func toolCallMessage(text string, calls ...chat.ToolCall) chat.Message {
+	msg := chat.Message{Role: chat.AssistantRole}
+	if text != "" {
+		msg.AddText(text)
+	}
+	for _, call := range calls {
+		msg.AddToolCall(call)
+	}
+	return msg
+}
+
+func TestToolLoop(t *testing.T) {
+	t.Parallel()
+
+	second := toolCallMessage("Looking again.", chat.ToolCall{ID: "call_2", Name: "lookup", Arguments: json.RawMessage(`{"q": "b"}`)})
+	final := chat.AssistantMessage("Found both.")
+	loop, sent, inputs := newTestToolLoop(t, []chat.Message{second, final})
+
+	var events []chat.StreamEvent
+	callback := func(event chat.StreamEvent) error {
+		events = append(events, event)
+		return nil
+	}
+	ctx := chat.WithSystemReminder(context.Background(), func() string { return "<reminder>" })
+
+	userMsg := chat.UserMessage("Look up a and b")
+	first := toolCallMessage("Looking.", chat.ToolCall{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{"q": "a"}`)})
+	resp, err := loop.Run(ctx, callback, userMsg, first)
+	require.NoError(t, err)
+	assert.Equal(t, final, resp)
+	assert.Equal(t, []string{`{"q": "a"}`, `{"q": "b"}`}, *inputs)
+
+	// The turn's messages are recorded in order, with the reminder after
+	// each round's results
+	toolMsg := func(id string) chat.Message {
+		msg := chat.Message{Role: chat.ToolRole}
+		msg.AddToolResult(chat.ToolResult{ToolCallID: id, Name: "lookup", Content: `{"found": true}`})
+		msg.Contents = append(msg.Contents, chat.Content{SystemReminder: "<reminder>"})
+		return msg
+	}
+	want := []chat.Message{userMsg, first, toolMsg("call_1"), second, toolMsg("call_2"), final}
+	_, history := loop.State.History()
+	assert.Equal(t, want, history)
+
+	// Each round is sent the turn so far
+	require.Len(t, *sent, 2)
+	assert.Equal(t, want[:3], (*sent)[0])
+	assert.Equal(t, want[:5], (*sent)[1])
+
+	usage, err := loop.State.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 30, usage.Cumulative.TotalTokens)
+
+	var types []chat.StreamEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []chat.StreamEventType{
+		chat.StreamEventTypeToolResult,
+		chat.StreamEventTypeRoundStart,
+		chat.StreamEventTypeRoundEnd,
+		chat.StreamEventTypeToolResult,
+		chat.StreamEventTypeRoundStart,
+		chat.StreamEventTypeRoundEnd,
+	}, types)
+	assert.Equal(t, chat.RoundReasonToolCalls, events[2].Round.Reason)
+	assert.Equal(t, chat.RoundReasonComplete, events[5].Round.Reason)
+}
+
+func TestToolLoop_Arguments(t *testing.T) {
+	t.Parallel()
+
+	final := chat.AssistantMessage("Done.")
+	loop, _, inputs := newTestToolLoop(t, []chat.Message{final})
+
+	first := toolCallMessage("",
+		chat.ToolCall{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{"q": "a",}`)},
+		chat.ToolCall{ID: "call_2", Name: "lookup"},
+	)
+	_, err := loop.Run(context.Background(), nil, chat.UserMessage("Look it up"), first)
+	require.NoError(t, err)
+
+	// Malformed arguments are repaired, and missing ones are an empty object
+	assert.Equal(t, []string{`{"q": "a"}`, `{}`}, *inputs)
+
+	// History records the repaired arguments, without changing the response
+	_, history := loop.State.History()
+	calls := history[1].GetToolCalls()
+	require.Len(t, calls, 2)
+	assert.JSONEq(t, `{"q": "a"}`, string(calls[0].Arguments))
+	assert.Nil(t, calls[1].Arguments)
+	assert.Equal(t, `{"q": "a",}`, string(first.GetToolCalls()[0].Arguments))
+}
+
+func TestToolLoop_Errors(t *testing.T) {
+	t.Parallel()
+
+	first := toolCallMessage("", chat.ToolCall{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{}`)})
+
+	t.Run("send", func(t *testing.T) {
+		loop, _, _ := newTestToolLoop(t, nil)
+		errSend := errors.New("connection reset")
+		loop.Send = func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error) {
+			return chat.Message{}, chat.TokenUsageDetails{}, errSend
+		}
+		_, err := loop.Run(context.Background(), nil, chat.UserMessage("Look it up"), first)
+		assert.ErrorIs(t, err, errSend)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		loop, sent, _ := newTestToolLoop(t, nil)
+		errStop := errors.New("stop")
+		_, err := loop.Run(context.Background(), func(chat.StreamEvent) error { return errStop }, chat.UserMessage("Look it up"), first)
+		assert.ErrorIs(t, err, errStop)
+		assert.Empty(t, *sent)
+	})
+}
+
+// imageTool is a chat.ImageTool returning a fixed image.
+type imageTool struct {
+	mockTool
+	image chat.ImageContent
+}
+
+func (m imageTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	return `{"mediaType": "image/png"}`, []chat.ImageContent{m.image}
+}
+
+func TestToolLoop_Images(t *testing.T) {
+	t.Parallel()
+
+	final := chat.AssistantMessage("It's a red square.")
+	loop, sent, _ := newTestToolLoop(t, []chat.Message{final})
+	img := chat.ImageContent{MediaType: "image/png", Data: []byte("png")}
+	require.NoError(t, loop.Tools.Register(imageTool{mockTool: mockTool{name: "screenshot", schema: noArgsSchema}, image: img}))
+
+	first := toolCallMessage("",
+		chat.ToolCall{ID: "call_1", Name: "screenshot", Arguments: json.RawMessage(`{}`)},
+		chat.ToolCall{ID: "call_2", Name: "lookup", Arguments: json.RawMessage(`{}`)},
+	)
+	_, err := loop.Run(context.Background(), nil, chat.UserMessage("What's on screen?"), first)
+	require.NoError(t, err)
+
+	// Images are sent with the results of the tools that returned them
+	require.Len(t, *sent, 1)
+	results := (*sent)[0][2].GetToolResults()
+	require.Len(t, results, 2)
+	assert.Equal(t, `{"mediaType": "image/png"}`, results[0].Content)
+	assert.Equal(t, []chat.ImageContent{img}, results[0].Images)
+	assert.Empty(t, results[1].Images)
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 
 	"github.com/bpowers/go-agent/chat"
@@ -28,6 +29,11 @@ func BuildToolResult(toolName, toolCallID, raw string, execErr error) chat.ToolR
 
 	if execErr != nil {
 		result.Error = execErr.Error()
+		var te *ToolError
+		if errors.As(execErr, &te) {
+			result.ErrorCode = te.Code
+			result.Retryable = te.Retryable
+		}
 		return result
 	}
 
@@ -25,12 +25,13 @@ func NewTools() *Tools {
 	}
 }
 
-// Register adds a tool to the registry.
+// Register adds a tool to the registry, after checking it with
+// chat.ValidateToolDef.
 func (t *Tools) Register(tool chat.Tool) error {
-	toolName := tool.Name()
-	if toolName == "" {
-		return fmt.Errorf("tool definition missing name")
+	if err := chat.ValidateToolDef(tool); err != nil {
+		return err
 	}
+	toolName := tool.Name()
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -92,6 +93,16 @@ func (t *Tools) List() []string {
 	return slices.Clone(t.order)
 }
 
+// ForRequest returns the tools to send natively in a request made with
+// opts: all of them, in registration order, unless opts.TextToolProtocol
+// describes them in the system prompt instead (see SendTextTools).
+func (t *Tools) ForRequest(opts chat.Options) []chat.Tool {
+	if opts.TextToolProtocol {
+		return nil
+	}
+	return t.GetAll()
+}
+
 // Count returns the number of registered tools.
 func (t *Tools) Count() int {
 	t.mu.RLock()
@@ -99,11 +110,31 @@ func (t *Tools) Count() int {
 	return len(t.tools)
 }
 
-// Execute runs a tool by name with the given context and input.
+// Execute runs a tool by name with the given context and input. If the tool
+// isn't registered or reports a structured failure, the returned error is a
+// *ToolError.
 func (t *Tools) Execute(ctx context.Context, name string, input string) (string, error) {
+	result, _, err := t.ExecuteWithImages(ctx, name, input)
+	return result, err
+}
+
+// ExecuteWithImages is like Execute, but also returns the images in the
+// result of a tool that implements chat.ImageTool. No images are returned
+// with an error.
+func (t *Tools) ExecuteWithImages(ctx context.Context, name string, input string) (string, []chat.ImageContent, error) {
 	tool, exists := t.Get(name)
 	if !exists {
-		return "", fmt.Errorf("tool %q not found", name)
+		return "", nil, &ToolError{Message: fmt.Sprintf("tool %q not found", name), Code: ToolErrorCodeNotFound}
+	}
+	var result string
+	var images []chat.ImageContent
+	if it, ok := tool.(chat.ImageTool); ok {
+		result, images = it.CallWithImages(ctx, input)
+	} else {
+		result = tool.Call(ctx, input)
+	}
+	if te, ok := ParseToolError(result); ok {
+		return result, nil, te
 	}
-	return tool.Call(ctx, input), nil
+	return result, images, nil
 }
@@ -18,7 +18,7 @@ func TestToolsPreservesRegistrationOrder(t *testing.T) {
 		tool := mockTool{
 			name:        name,
 			description: "test tool " + name,
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "result for " + nameCapture
 			},
@@ -49,7 +49,7 @@ func TestToolsOrderAfterDeregistration(t *testing.T) {
 		tool := mockTool{
 			name:        name,
 			description: "test tool " + name,
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "result for " + nameCapture
 			},
@@ -77,7 +77,7 @@ func TestToolsReregistrationUpdatesInPlace(t *testing.T) {
 		tool := mockTool{
 			name:        name,
 			description: "test tool " + name,
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "result v1 for " + nameCapture
 			},
@@ -90,7 +90,7 @@ func TestToolsReregistrationUpdatesInPlace(t *testing.T) {
 	newTool := mockTool{
 		name:        "beta",
 		description: "updated test tool beta",
-		schema:      `{"type": "object"}`,
+		schema:      `{"inputSchema": {"type": "object"}}`,
 		handler: func(ctx context.Context, input string) string {
 			return "result v2 for beta"
 		},
@@ -13,6 +13,9 @@ import (
 	"github.com/bpowers/go-agent/chat"
 )
 
+// noArgsSchema is the MCP JSON schema of a tool without arguments.
+const noArgsSchema = `{"inputSchema":{"type":"object"}}`
+
 // mockTool implements chat.ToolDef for testing
 type mockTool struct {
 	name        string
@@ -64,7 +67,7 @@ func TestTools_Register(t *testing.T) {
 		tool := mockTool{
 			name:        "test_tool",
 			description: "A test tool",
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "result: " + input
 			},
@@ -86,7 +89,7 @@ func TestTools_Register(t *testing.T) {
 		tool := mockTool{
 			name:        "",
 			description: "A test tool",
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 		}
 
 		err := tools.Register(tool)
@@ -102,7 +105,7 @@ func TestTools_Register(t *testing.T) {
 			tool := mockTool{
 				name:        fmt.Sprintf("tool_%d", i),
 				description: fmt.Sprintf("Tool %d", i),
-				schema:      `{"type": "object"}`,
+				schema:      `{"inputSchema": {"type": "object"}}`,
 			}
 
 			err := tools.Register(tool)
@@ -123,7 +126,7 @@ func TestTools_Register(t *testing.T) {
 		tool1 := mockTool{
 			name:        "tool",
 			description: "First version",
-			schema:      `{"v": 1}`,
+			schema:      `{"v": 1, "inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "v1"
 			},
@@ -136,7 +139,7 @@ func TestTools_Register(t *testing.T) {
 		tool2 := mockTool{
 			name:        "tool",
 			description: "Second version",
-			schema:      `{"v": 2}`,
+			schema:      `{"v": 2, "inputSchema": {"type": "object"}}`,
 			handler: func(ctx context.Context, input string) string {
 				return "v2"
 			},
@@ -163,7 +166,7 @@ func TestTools_Deregister(t *testing.T) {
 	tool := mockTool{
 		name:        "test_tool",
 		description: "A test tool",
-		schema:      `{"type": "object"}`,
+		schema:      `{"inputSchema": {"type": "object"}}`,
 	}
 
 	err := tools.Register(tool)
@@ -187,7 +190,7 @@ func TestTools_Get(t *testing.T) {
 	tool := mockTool{
 		name:        "test_tool",
 		description: "A test tool",
-		schema:      `{"type": "object"}`,
+		schema:      `{"inputSchema": {"type": "object"}}`,
 		handler: func(ctx context.Context, input string) string {
 			return "result: " + input
 		},
@@ -218,7 +221,7 @@ func TestTools_GetAll(t *testing.T) {
 		tool := mockTool{
 			name:        fmt.Sprintf("tool_%d", i),
 			description: fmt.Sprintf("Tool %d", i),
-			schema:      `{"type": "object"}`,
+			schema:      `{"inputSchema": {"type": "object"}}`,
 		}
 
 		err := tools.Register(tool)
@@ -257,7 +260,7 @@ func TestTools_List(t *testing.T) {
 		tool := mockTool{
 			name:        name,
 			description: "desc",
-			schema:      `{}`,
+			schema:      noArgsSchema,
 		}
 		err := tools.Register(tool)
 		require.NoError(t, err)
@@ -279,7 +282,7 @@ func TestTools_Count(t *testing.T) {
 		tool := mockTool{
 			name:        fmt.Sprintf("tool_%d", i),
 			description: "desc",
-			schema:      `{}`,
+			schema:      noArgsSchema,
 		}
 		err := tools.Register(tool)
 		require.NoError(t, err)
@@ -301,7 +304,7 @@ func TestTools_Execute(t *testing.T) {
 		tool := mockTool{
 			name:        "echo",
 			description: "Echoes input",
-			schema:      `{}`,
+			schema:      noArgsSchema,
 			handler: func(ctx context.Context, input string) string {
 				return "echo: " + input
 			},
@@ -332,7 +335,7 @@ func TestTools_Execute(t *testing.T) {
 		tool := mockTool{
 			name:        "context_aware",
 			description: "Uses context",
-			schema:      `{}`,
+			schema:      noArgsSchema,
 			handler: func(ctx context.Context, input string) string {
 				if ctx.Err() != nil {
 					return "cancelled"
@@ -378,7 +381,7 @@ func TestTools_Concurrency(t *testing.T) {
 				tool := mockTool{
 					name:        fmt.Sprintf("tool_%d", id),
 					description: fmt.Sprintf("Tool %d", id),
-					schema:      `{}`,
+					schema:      noArgsSchema,
 				}
 
 				err := tools.Register(tool)
@@ -402,7 +405,7 @@ func TestTools_Concurrency(t *testing.T) {
 			tool := mockTool{
 				name:        fmt.Sprintf("tool_%d", i),
 				description: "desc",
-				schema:      `{}`,
+				schema:      noArgsSchema,
 			}
 			err := tools.Register(tool)
 			require.NoError(t, err)
@@ -441,7 +444,7 @@ func TestTools_Concurrency(t *testing.T) {
 						tool := mockTool{
 							name:        fmt.Sprintf("dynamic_%d_%d", id, j),
 							description: "dynamic",
-							schema:      `{}`,
+							schema:      noArgsSchema,
 						}
 						tools.Register(tool)
 					} else if j%3 == 1 {
@@ -452,7 +455,7 @@ func TestTools_Concurrency(t *testing.T) {
 						tool := mockTool{
 							name:        fmt.Sprintf("tool_%d", j%10),
 							description: "updated",
-							schema:      `{}`,
+							schema:      noArgsSchema,
 						}
 						tools.Register(tool)
 					}
@@ -480,7 +483,7 @@ func TestTools_Concurrency(t *testing.T) {
 		tool := mockTool{
 			name:        "counter",
 			description: "Counts executions",
-			schema:      `{}`,
+			schema:      noArgsSchema,
 			handler: func(ctx context.Context, input string) string {
 				atomic.AddInt64(&counter, 1)
 				return "counted"
@@ -533,7 +536,7 @@ func TestTools_Concurrency(t *testing.T) {
 				tool := mockTool{
 					name:        "race_tool",
 					description: "racing",
-					schema:      `{}`,
+					schema:      noArgsSchema,
 				}
 				tools.Register(tool)
 			}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// SendValidated sends msg with send and checks the response with
+// opts.Validator. While the response is rejected and retries remain, it sends
+// the model a follow-up message describing the problem. send must append each
+// exchange to the chat's history, so the model sees its rejected responses.
+func SendValidated(ctx context.Context, opts chat.Options, msg chat.Message, send func(context.Context, chat.Message) (chat.Message, error)) (chat.Message, error) {
+	resp, err := send(ctx, msg)
+	if err != nil || opts.Validator == nil {
+		return resp, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		verr := opts.Validator(resp)
+		if verr == nil {
+			return resp, nil
+		}
+		if attempt > opts.ValidationRetries {
+			return resp, &chat.ValidationError{Attempts: attempt, Response: resp, Err: verr}
+		}
+
+		reask := chat.UserMessage(fmt.Sprintf("Your previous response was rejected: %v\n\nPlease respond again, fixing this problem.", verr))
+		if resp, err = send(ctx, reask); err != nil {
+			return resp, err
+		}
+	}
+}
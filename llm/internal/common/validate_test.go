@@ -0,0 +1,80 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+var errNoCitation = errors.New("must include a citation")
+
+func requireCitation(msg chat.Message) error {
+	if !strings.Contains(msg.GetText(), "[1]") {
+		return errNoCitation
+	}
+	return nil
+}
+
+// fakeSend returns the given responses in turn, recording what was sent.
+func fakeSend(sent *[]chat.Message, responses ...string) func(context.Context, chat.Message) (chat.Message, error) {
+	return func(ctx context.Context, msg chat.Message) (chat.Message, error) {
+		*sent = append(*sent, msg)
+		if len(*sent) > len(responses) {
+			return chat.Message{}, fmt.Errorf("unexpected message %d", len(*sent))
+		}
+		return chat.AssistantMessage(responses[len(*sent)-1]), nil
+	}
+}
+
+func TestSendValidated(t *testing.T) {
+	ctx := context.Background()
+	msg := chat.UserMessage("Who wrote Hamlet?")
+
+	t.Run("no validator", func(t *testing.T) {
+		var sent []chat.Message
+		resp, err := SendValidated(ctx, chat.ApplyOptions(), msg, fakeSend(&sent, "Shakespeare"))
+		require.NoError(t, err)
+		assert.Equal(t, "Shakespeare", resp.GetText())
+		assert.Len(t, sent, 1)
+	})
+
+	t.Run("re-asks until valid", func(t *testing.T) {
+		var sent []chat.Message
+		opts := chat.ApplyOptions(chat.WithValidator(requireCitation))
+		resp, err := SendValidated(ctx, opts, msg, fakeSend(&sent, "Shakespeare", "Shakespeare [1]"))
+		require.NoError(t, err)
+		assert.Equal(t, "Shakespeare [1]", resp.GetText())
+		require.Len(t, sent, 2)
+		assert.Equal(t, msg, sent[0])
+		assert.Equal(t, chat.UserRole, sent[1].Role)
+		assert.Contains(t, sent[1].GetText(), "must include a citation")
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		var sent []chat.Message
+		opts := chat.ApplyOptions(chat.WithValidator(requireCitation), chat.WithValidationRetries(1))
+		resp, err := SendValidated(ctx, opts, msg, fakeSend(&sent, "Shakespeare", "Still Shakespeare"))
+		var verr *chat.ValidationError
+		require.ErrorAs(t, err, &verr)
+		assert.ErrorIs(t, err, errNoCitation)
+		assert.Equal(t, 2, verr.Attempts)
+		assert.Equal(t, "Still Shakespeare", verr.Response.GetText())
+		assert.Equal(t, "Still Shakespeare", resp.GetText())
+		assert.Len(t, sent, 2)
+	})
+
+	t.Run("send errors are returned", func(t *testing.T) {
+		var sent []chat.Message
+		opts := chat.ApplyOptions(chat.WithValidator(requireCitation))
+		_, err := SendValidated(ctx, opts, msg, fakeSend(&sent, "Shakespeare"))
+		require.Error(t, err)
+		assert.NotErrorAs(t, err, new(*chat.ValidationError))
+	})
+}
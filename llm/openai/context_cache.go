@@ -0,0 +1,47 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/openai/openai-go"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/internal/common"
+)
+
+var _ chat.ContextCacher = &client{}
+
+// CreateContextCache implements chat.ContextCacher. OpenAI caches prompt
+// prefixes automatically, so nothing is sent: the cache's name is a prompt
+// cache key derived from the system prompt, which routes requests that
+// share it to servers likely to hold the prefix. config.TTL is ignored, as
+// OpenAI decides how long prefixes stay cached.
+func (c *client) CreateContextCache(ctx context.Context, config chat.ContextCacheConfig) (*chat.ContextCache, error) {
+	sum := sha256.Sum256([]byte(config.SystemPrompt))
+	return &chat.ContextCache{
+		Provider:     "openai",
+		Name:         "go-agent-" + hex.EncodeToString(sum[:16]),
+		SystemPrompt: config.SystemPrompt,
+	}, nil
+}
+
+// promptCacheKey returns the prompt cache key of the request's context
+// cache, if OpenAI created it.
+func promptCacheKey(opts chat.Options) (string, bool) {
+	if opts.ContextCache == nil || opts.ContextCache.Provider != "openai" {
+		return "", false
+	}
+	return opts.ContextCache.Name, true
+}
+
+// chunkUsage returns the token usage in a Chat Completions chunk, if it
+// carries any.
+func chunkUsage(chunk openai.ChatCompletionChunk) (chat.TokenUsageDetails, bool) {
+	usage, ok := common.StreamUsage(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens, chunk.Usage.TotalTokens)
+	if ok {
+		usage.CachedTokens = int(chunk.Usage.PromptTokensDetails.CachedTokens)
+	}
+	return usage, ok
+}
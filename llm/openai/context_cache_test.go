@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_ContextCache(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		PromptCacheKey string `json:"prompt_cache_key"`
+		Messages       []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req request
+		require.NoError(t, json.Unmarshal(body, &req))
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Done\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":2000,\"completion_tokens\":1,\"total_tokens\":2001,\"prompt_tokens_details\":{\"cached_tokens\":1920}}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(ChatCompletions))
+	require.NoError(t, err)
+
+	cacher, ok := client.(chat.ContextCacher)
+	require.True(t, ok)
+	cache, err := cacher.CreateContextCache(context.Background(), chat.ContextCacheConfig{SystemPrompt: "A long corpus"})
+	require.NoError(t, err)
+	assert.Equal(t, "openai", cache.Provider)
+	assert.NotEmpty(t, cache.Name)
+
+	// The same system prompt gets the same key
+	again, err := cacher.CreateContextCache(context.Background(), chat.ContextCacheConfig{SystemPrompt: "A long corpus"})
+	require.NoError(t, err)
+	assert.Equal(t, cache.Name, again.Name)
+
+	c := client.NewChat("Short prompt")
+	_, err = c.Message(context.Background(), chat.UserMessage("Hello"), chat.WithContextCache(cache))
+	require.NoError(t, err)
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, cache.Name, requests[0].PromptCacheKey)
+	require.NotEmpty(t, requests[0].Messages)
+	assert.Equal(t, "system", requests[0].Messages[0].Role)
+	assert.Equal(t, "A long corpus", requests[0].Messages[0].Content)
+
+	usage, err := c.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 1920, usage.LastMessage.CachedTokens)
+}
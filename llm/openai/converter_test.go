@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/llm/internal/common"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
 )
 
 func TestMessageToOpenAI(t *testing.T) {
@@ -165,6 +167,76 @@ func TestMessageToOpenAI(t *testing.T) {
 				assert.Equal(t, `{"temperature": 20, "condition": "sunny"}`, got[0].OfTool.Content.OfString.Value)
 			},
 		},
+		{
+			name: "tool role message with system reminder",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "call_123",
+							Name:       "get_weather",
+							Content:    `{"temperature": 20}`,
+						},
+					},
+					{SystemReminder: "<system-reminder>Be brief.</system-reminder>"},
+				},
+			},
+			wantCount: 2,
+			validate: func(t *testing.T, got []openai.ChatCompletionMessageParamUnion) {
+				require.NotNil(t, got[0].OfTool)
+				assert.Equal(t, "call_123", got[0].OfTool.ToolCallID)
+				require.NotNil(t, got[1].OfUser)
+				assert.Equal(t, "<system-reminder>Be brief.</system-reminder>", got[1].OfUser.Content.OfString.Value)
+			},
+		},
+		{
+			name: "user message with image",
+			msg: chat.Message{
+				Role: chat.UserRole,
+				Contents: []chat.Content{
+					{Text: "What is in this screenshot?"},
+					{Image: &chat.ImageContent{MediaType: "image/png", Data: []byte("png")}},
+				},
+			},
+			wantCount: 1,
+			validate: func(t *testing.T, got []openai.ChatCompletionMessageParamUnion) {
+				require.NotNil(t, got[0].OfUser)
+				parts := got[0].OfUser.Content.OfArrayOfContentParts
+				require.Len(t, parts, 2)
+				require.NotNil(t, parts[0].OfText)
+				assert.Equal(t, "What is in this screenshot?", parts[0].OfText.Text)
+				require.NotNil(t, parts[1].OfImageURL)
+				assert.Equal(t, "data:image/png;base64,cG5n", parts[1].OfImageURL.ImageURL.URL)
+			},
+		},
+		{
+			name: "tool role message with image result adds user message",
+			msg: chat.Message{
+				Role: chat.ToolRole,
+				Contents: []chat.Content{
+					{
+						ToolResult: &chat.ToolResult{
+							ToolCallID: "call_123",
+							Name:       "ReadImage",
+							Content:    `{"mediaType":"image/png"}`,
+							Images:     []chat.ImageContent{{MediaType: "image/png", Data: []byte("png")}},
+						},
+					},
+				},
+			},
+			wantCount: 2,
+			validate: func(t *testing.T, got []openai.ChatCompletionMessageParamUnion) {
+				require.NotNil(t, got[0].OfTool)
+				assert.Equal(t, `{"mediaType":"image/png"}`, got[0].OfTool.Content.OfString.Value)
+				require.NotNil(t, got[1].OfUser)
+				parts := got[1].OfUser.Content.OfArrayOfContentParts
+				require.Len(t, parts, 2)
+				assert.Contains(t, parts[0].OfText.Text, "ReadImage")
+				require.NotNil(t, parts[1].OfImageURL)
+				assert.Equal(t, "data:image/png;base64,cG5n", parts[1].OfImageURL.ImageURL.URL)
+			},
+		},
 		{
 			name: "tool role message with multiple results creates multiple messages",
 			msg: chat.Message{
@@ -301,6 +373,31 @@ func TestMessageToOpenAI(t *testing.T) {
 			wantErr: true,
 			errMsg:  "assistant message has no valid content",
 		},
+		{
+			name: "assistant message with only thinking is dropped",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Thinking: &chat.ThinkingContent{Text: "Reasoning", Signature: "sig", Provider: "claude"}},
+				},
+			},
+			wantCount: 0,
+		},
+		{
+			name: "assistant message thinking is ignored",
+			msg: chat.Message{
+				Role: chat.AssistantRole,
+				Contents: []chat.Content{
+					{Thinking: &chat.ThinkingContent{Text: "Reasoning", Signature: "sig", Provider: "claude"}},
+					{Text: "Answer"},
+				},
+			},
+			wantCount: 1,
+			validate: func(t *testing.T, got []openai.ChatCompletionMessageParamUnion) {
+				require.NotNil(t, got[0].OfAssistant)
+				assert.Equal(t, "Answer", got[0].OfAssistant.Content.OfString.Value)
+			},
+		},
 		{
 			name: "tool message without results returns error",
 			msg: chat.Message{
@@ -509,3 +606,54 @@ func TestOpenAIRoleMapping(t *testing.T) {
 	assert.Equal(t, "call_123", got[0].OfTool.ToolCallID)
 	assert.Equal(t, "result", got[0].OfTool.Content.OfString.Value)
 }
+
+// BenchmarkHistoryConversion measures converting history for every request
+// of a 200-turn session, with and without the conversion cache.
+func BenchmarkHistoryConversion(b *testing.B) {
+	const turns = 200
+	var turnMsgs [][]chat.Message
+	for i := range turns {
+		turnMsgs = append(turnMsgs, llmtesting.SessionTurn(i))
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				if _, err := messagesToOpenAI(history); err != nil {
+					b.Fatal(err)
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var cache common.ConversionCache[openai.ChatCompletionMessageParamUnion]
+			var history []chat.Message
+			for _, msgs := range turnMsgs {
+				if _, err := cache.Convert(history, messageToOpenAI); err != nil {
+					b.Fatal(err)
+				}
+				history = append(history, msgs...)
+			}
+		}
+	})
+}
+
+func TestResponsesContent(t *testing.T) {
+	content := responsesContent("hello", nil)
+	assert.Equal(t, "hello", content.OfString.Value)
+	assert.Empty(t, content.OfInputItemContentList)
+
+	content = responsesContent("what is this?", []chat.ImageContent{{MediaType: "image/png", Data: []byte("png")}})
+	assert.False(t, content.OfString.Valid())
+	require.Len(t, content.OfInputItemContentList, 2)
+	require.NotNil(t, content.OfInputItemContentList[0].OfInputText)
+	assert.Equal(t, "what is this?", content.OfInputItemContentList[0].OfInputText.Text)
+	require.NotNil(t, content.OfInputItemContentList[1].OfInputImage)
+	assert.Equal(t, "data:image/png;base64,cG5n", content.OfInputItemContentList[1].OfInputImage.ImageURL.Value)
+}
@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// DefaultEmbeddingModel is the embedding model used by NewEmbedder when
+// WithModel isn't given.
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+type embedder struct {
+	openaiClient openai.Client
+	model        string
+}
+
+var _ chat.Embedder = &embedder{}
+
+// NewEmbedder returns a chat.Embedder that embeds text with the OpenAI
+// embeddings endpoint. WithModel selects the embedding model and WithHeaders
+// sets custom headers; other options are ignored.
+func NewEmbedder(apiBase string, apiKey string, opts ...Option) (chat.Embedder, error) {
+	c := &client{modelName: DefaultEmbeddingModel}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.modelName == "" {
+		return nil, fmt.Errorf("WithModel requires a model name")
+	}
+
+	return &embedder{
+		openaiClient: openai.NewClient(c.requestOptions(apiBase, apiKey)...),
+		model:        c.modelName,
+	}, nil
+}
+
+// Embed implements chat.Embedder.
+func (e *embedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	resp, err := e.openaiClient.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		Model: openai.EmbeddingModel(e.model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+
+	embeddings := make([][]float64, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || int(data.Index) >= len(texts) {
+			return nil, fmt.Errorf("embedding response has out of range index %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("embedding response is missing input %d", i)
+		}
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedder(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Input []string `json:"input"`
+			Model string   `json:"model"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		assert.Equal(t, DefaultEmbeddingModel, req.Model)
+		assert.Equal(t, []string{"first", "second"}, req.Input)
+
+		// Results can arrive out of order; their index places them
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","model":"text-embedding-3-small","data":[`+
+			`{"object":"embedding","index":1,"embedding":[0,1]},`+
+			`{"object":"embedding","index":0,"embedding":[1,0]}],`+
+			`"usage":{"prompt_tokens":2,"total_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	embedder, err := NewEmbedder(server.URL, "test-key")
+	require.NoError(t, err)
+
+	embeddings, err := embedder.Embed(context.Background(), []string{"first", "second"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 0}, {0, 1}}, embeddings)
+
+	embeddings, err = embedder.Embed(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, embeddings)
+}
@@ -0,0 +1,78 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/openai/openai-go"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// DefaultModerationModel is the moderation model used by NewModerator when
+// WithModel isn't given.
+const DefaultModerationModel = "omni-moderation-latest"
+
+type moderator struct {
+	openaiClient openai.Client
+	model        string
+}
+
+var _ chat.Moderator = &moderator{}
+
+// NewModerator returns a chat.Moderator that screens text with the OpenAI
+// moderations endpoint. WithModel selects the moderation model and WithHeaders
+// sets custom headers; other options are ignored.
+func NewModerator(apiBase string, apiKey string, opts ...Option) (chat.Moderator, error) {
+	c := &client{modelName: DefaultModerationModel}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.modelName == "" {
+		return nil, fmt.Errorf("WithModel requires a model name")
+	}
+
+	return &moderator{
+		openaiClient: openai.NewClient(c.requestOptions(apiBase, apiKey)...),
+		model:        c.modelName,
+	}, nil
+}
+
+// Moderate implements chat.Moderator.
+func (m *moderator) Moderate(ctx context.Context, text string) (chat.ModerationResult, error) {
+	resp, err := m.openaiClient.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(text)},
+		Model: openai.ModerationModel(m.model),
+	})
+	if err != nil {
+		return chat.ModerationResult{}, fmt.Errorf("moderation request failed: %w", err)
+	}
+	if len(resp.Results) == 0 {
+		return chat.ModerationResult{}, fmt.Errorf("moderation response has no results")
+	}
+
+	// The SDK has a field per category; the raw JSON lets new categories
+	// through without an SDK update.
+	var raw struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	}
+	if err := json.Unmarshal([]byte(resp.Results[0].RawJSON()), &raw); err != nil {
+		return chat.ModerationResult{}, fmt.Errorf("decoding moderation result: %w", err)
+	}
+
+	result := chat.ModerationResult{
+		Flagged: raw.Flagged,
+		Scores:  raw.CategoryScores,
+	}
+	for category, flagged := range raw.Categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	slices.Sort(result.Categories)
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestModerator(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/moderations", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Input string `json:"input"`
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		assert.Equal(t, DefaultModerationModel, req.Model)
+		flagged := req.Input == "I will hurt you"
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":%t,`+
+			`"categories":{"harassment":%t,"violence":%t,"sexual":false},`+
+			`"category_applied_input_types":{},`+
+			`"category_scores":{"harassment":0.7,"violence":0.8,"sexual":0.01}}]}`, flagged, flagged, flagged)
+	}))
+	defer server.Close()
+
+	moderator, err := NewModerator(server.URL, "test-key")
+	require.NoError(t, err)
+
+	result, err := moderator.Moderate(context.Background(), "I will hurt you")
+	require.NoError(t, err)
+	assert.Equal(t, chat.ModerationResult{
+		Flagged:    true,
+		Categories: []string{"harassment", "violence"},
+		Scores:     map[string]float64{"harassment": 0.7, "violence": 0.8, "sexual": 0.01},
+	}, result)
+
+	result, err = moderator.Moderate(context.Background(), "Hello")
+	require.NoError(t, err)
+	assert.False(t, result.Flagged)
+	assert.Empty(t, result.Categories)
+}
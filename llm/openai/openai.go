@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/openai/openai-go"
@@ -52,30 +53,27 @@ const (
 	Responses
 )
 
-// set is a simple set type for tracking unique values
-type set[T comparable] map[T]struct{}
-
-func (s set[T]) Add(v T) {
-	s[v] = struct{}{}
-}
-
-func (s set[T]) Contains(v T) bool {
-	_, ok := s[v]
-	return ok
-}
-
 type client struct {
-	openaiClient openai.Client
-	modelName    string
-	api          API
-	apiSet       bool              // true if WithAPI was explicitly provided
-	baseURL      string            // Store base URL for testing
-	headers      map[string]string // Custom HTTP headers
-	logger       *slog.Logger
+	openaiClient   openai.Client
+	modelName      string
+	api            API
+	apiSet         bool              // true if WithAPI was explicitly provided
+	baseURL        string            // Store base URL for testing
+	headers        map[string]string // Custom HTTP headers
+	repairToolArgs bool              // Repair malformed tool call arguments
+	strictTools    bool              // Send tools with strict: true
+	rawEventTap    common.RawEventTap
+	logger         *slog.Logger
 }
 
 var _ chat.Client = &client{}
 
+func init() {
+	chat.RegisterMessageExporter("openai", func(msgs []chat.Message) (json.RawMessage, error) {
+		return common.ExportMessages(msgs, messageToOpenAI)
+	})
+}
+
 type Option func(*client)
 
 func WithModel(modelName string) Option {
@@ -97,6 +95,49 @@ func WithHeaders(headers map[string]string) Option {
 	}
 }
 
+// WithToolArgumentRepair enables a lenient repair pass over tool call
+// arguments that aren't valid JSON, fixing trailing commas and unescaped
+// newlines before the arguments are passed to the tool. How often arguments
+// were malformed is reported by llm.ToolArgumentMetrics either way.
+func WithToolArgumentRepair() Option {
+	return func(c *client) {
+		c.repairToolArgs = true
+	}
+}
+
+// WithStrictTools sends tools with strict: true, so the model's arguments
+// always match their input schemas. Strict mode accepts a subset of JSON
+// Schema, so schemas are adjusted to it: objects disallow additional
+// properties, and optional properties become required but nullable.
+// Schemas that can't be adjusted, such as those with map types or
+// unsupported keywords, are rejected by RegisterTool with an error
+// describing each problem.
+func WithStrictTools() Option {
+	return func(c *client) {
+		c.strictTools = true
+	}
+}
+
+// WithLogger sends the client's logs to handler instead of the library's
+// global logger, so an application can route them into its own structured
+// logging, at a level independent of llm.SetLogLevel.
+func WithLogger(handler slog.Handler) Option {
+	return func(c *client) {
+		c.logger = logging.New(handler).With("provider", "openai")
+	}
+}
+
+// WithRawEventTap passes every raw server-sent event the client streams to
+// tap, with the provider's name and the event's type and data, so
+// applications can record or inspect the provider's behavior without
+// enabling debug logging. tap is called as each event is read, from the
+// goroutine streaming the response, and may keep the payload.
+func WithRawEventTap(tap func(provider, eventType string, payload []byte)) Option {
+	return func(c *client) {
+		c.rawEventTap = tap
+	}
+}
+
 // NewClient returns a chat client that can begin chat sessions with an LLM service that speaks
 // the OpenAI chat completion API.
 func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, error) {
@@ -119,9 +160,36 @@ func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, erro
 		c.api = Responses
 	}
 
-	// Build OpenAI client options
+	c.openaiClient = openai.NewClient(c.requestOptions(apiBase, apiKey)...)
+
+	return c, nil
+}
+
+// jsonObjectFormat is the Chat Completions response format for chat.WithJSONMode.
+func jsonObjectFormat() openai.ChatCompletionNewParamsResponseFormatUnion {
+	return openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+	}
+}
+
+// predictionContent is the Chat Completions predicted output for chat.WithPrediction.
+func predictionContent(text string) openai.ChatCompletionPredictionContentParam {
+	return openai.ChatCompletionPredictionContentParam{
+		Content: openai.ChatCompletionPredictionContentContentUnionParam{
+			OfString: openai.String(text),
+		},
+	}
+}
+
+// requestOptions returns the OpenAI SDK options for the given endpoint and
+// the client's custom headers.
+func (c *client) requestOptions(apiBase string, apiKey string) []option.RequestOption {
 	clientOpts := []option.RequestOption{
 		option.WithBaseURL(apiBase),
+		option.WithMiddleware(common.RequestMiddleware),
+	}
+	if c.rawEventTap != nil {
+		clientOpts = append(clientOpts, option.WithMiddleware(common.RawEventMiddleware("openai", c.rawEventTap)))
 	}
 
 	if apiKey != "" {
@@ -133,9 +201,7 @@ func NewClient(apiBase string, apiKey string, opts ...Option) (chat.Client, erro
 		clientOpts = append(clientOpts, option.WithHeader(key, value))
 	}
 
-	c.openaiClient = openai.NewClient(clientOpts...)
-
-	return c, nil
+	return clientOpts
 }
 
 // BaseURL returns the base URL for testing purposes.
@@ -150,45 +216,35 @@ func (c *client) Headers() map[string]string {
 	return c.headers
 }
 
+var _ chat.Pinger = &client{}
+
+// Ping implements chat.Pinger by looking up the client's model, which
+// checks the endpoint, API key, and model without generating anything.
+func (c *client) Ping(ctx context.Context) (chat.HealthStatus, error) {
+	return common.Ping(ctx, "openai", c.modelName, func(ctx context.Context) error {
+		_, err := c.openaiClient.Models.Get(ctx, c.modelName, option.WithMaxRetries(0))
+		return err
+	})
+}
+
 // NewChat returns a chat instance.
 func (c client) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
 	// Determine max tokens based on model
 	maxTokens := getModelMaxTokens(c.modelName)
 
 	return &chatClient{
-		client:    c,
-		state:     common.NewState(systemPrompt, initialMsgs),
-		tools:     common.NewTools(),
-		maxTokens: maxTokens,
+		client:       c,
+		state:        common.NewState(systemPrompt, initialMsgs),
+		tools:        common.NewTools(),
+		maxTokens:    maxTokens,
+		contextLimit: getModelContextLimit(c.modelName),
 	}
 }
 
-// modelLimits are in a particular order, so that longer, more specific strings come first
-var modelLimits = []chat.ModelTokenLimits{
-	{Model: "gpt-5-mini", TokenLimits: chat.TokenLimits{Context: 400000, Output: 128000}},
-	{Model: "gpt-5-nano", TokenLimits: chat.TokenLimits{Context: 400000, Output: 128000}},
-	{Model: "gpt-5", TokenLimits: chat.TokenLimits{Context: 400000, Output: 128000}},
-	{Model: "gpt-4.5-preview", TokenLimits: chat.TokenLimits{Context: 128000, Output: 16384}},
-	{Model: "gpt-4.1-mini", TokenLimits: chat.TokenLimits{Context: 1000000, Output: 32768}},
-	{Model: "gpt-4.1", TokenLimits: chat.TokenLimits{Context: 1000000, Output: 32768}},
-	{Model: "gpt-4o-mini", TokenLimits: chat.TokenLimits{Context: 128000, Output: 16384}},
-	{Model: "gpt-4o", TokenLimits: chat.TokenLimits{Context: 128000, Output: 16384}},
-	{Model: "gpt-4-turbo", TokenLimits: chat.TokenLimits{Context: 128000, Output: 4096}},
-	{Model: "gpt-4", TokenLimits: chat.TokenLimits{Context: 8192, Output: 8192}},
-	{Model: "o4-mini", TokenLimits: chat.TokenLimits{Context: 200000, Output: 100000}},
-	{Model: "o3", TokenLimits: chat.TokenLimits{Context: 200000, Output: 100000}},
-	{Model: "o3-mini", TokenLimits: chat.TokenLimits{Context: 200000, Output: 100000}},
-	{Model: "gpt-3.5-turbo", TokenLimits: chat.TokenLimits{Context: 16385, Output: 4096}},
-}
-
 // getModelMaxTokens returns the maximum token limit for known models
 func getModelMaxTokens(model string) int {
-	modelLower := strings.ToLower(model)
-
-	for _, m := range modelLimits {
-		if strings.HasPrefix(modelLower, m.Model) {
-			return m.TokenLimits.Output
-		}
+	if m, ok := chat.LookupModel(model); ok {
+		return m.TokenLimits.Output
 	}
 
 	// Conservative default for unknown models instead of panic
@@ -196,6 +252,12 @@ func getModelMaxTokens(model string) int {
 	return 4096
 }
 
+// getModelContextLimit returns the context window size for known models, or 0
+func getModelContextLimit(model string) int {
+	m, _ := chat.LookupModel(model)
+	return m.TokenLimits.Context
+}
+
 // isNoTemperatureModel checks if a model doesn't support custom temperature
 func isNoTemperatureModel(model string) bool {
 	modelLower := strings.ToLower(model)
@@ -205,31 +267,13 @@ func isNoTemperatureModel(model string) bool {
 		strings.HasPrefix(modelLower, "o3")
 }
 
-// withPrependedSystemReminder returns a new message with system reminder prepended as first content block
-func withPrependedSystemReminder(ctx context.Context, msg chat.Message) chat.Message {
-	if reminderFunc := chat.GetSystemReminder(ctx); reminderFunc != nil {
-		if reminder := reminderFunc(); reminder != "" {
-			newContents := make([]chat.Content, 0, len(msg.Contents)+1)
-			newContents = append(newContents, chat.Content{SystemReminder: reminder})
-			newContents = append(newContents, msg.Contents...)
-			return chat.Message{Role: msg.Role, Contents: newContents}
-		}
-	}
-	return msg
-}
-
 type chatClient struct {
 	client
-	state     *common.State
-	tools     *common.Tools
-	maxTokens int
-}
-
-// snapshotState returns a copy of the system prompt and message history.
-// This allows streaming operations to work with a consistent view of the state
-// without holding locks during long-running operations.
-func (c *chatClient) snapshotState() (systemPrompt string, history []chat.Message) {
-	return c.state.Snapshot()
+	state        *common.State
+	history      common.ConversionCache[openai.ChatCompletionMessageParamUnion]
+	tools        *common.Tools
+	maxTokens    int
+	contextLimit int
 }
 
 // updateHistoryAndUsage appends messages to history and updates token usage.
@@ -239,25 +283,44 @@ func (c *chatClient) updateHistoryAndUsage(msgs []chat.Message, usage chat.Token
 }
 
 func (c *chatClient) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
-	// Apply options to get callback if provided
 	appliedOpts := chat.ApplyOptions(opts...)
-	callback := appliedOpts.StreamingCb
 
-	// Determine route to appropriate API based on model type and whether tools are registered
-	nTools := c.tools.Count()
-	// Note: The Responses API doesn't support tools yet, so we fall back to ChatCompletions when tools are registered
-	if c.api == Responses && nTools == 0 {
-		return c.messageStreamResponses(ctx, msg, callback, opts...)
+	ctx, finishDryRun := common.StartDryRun(ctx, appliedOpts)
+	endTurn, err := c.state.BeginTurn(ctx, appliedOpts.QueueTimeout)
+	if err != nil {
+		return chat.Message{}, finishDryRun(err)
 	}
-	return c.messageStreamChatCompletions(ctx, msg, callback, opts...)
+	defer endTurn()
+	ctx = c.state.TrackRequests(ctx)
+	common.StreamTurnID(ctx, &appliedOpts)
+	stopProgress := common.StreamProgress(&appliedOpts)
+	defer stopProgress()
+
+	resp, err := common.SendValidated(ctx, appliedOpts, msg, func(ctx context.Context, msg chat.Message) (chat.Message, error) {
+		return common.SendTextTools(ctx, c.state, c.tools, appliedOpts, msg, func(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+			return common.SendResumable(ctx, reqOpts, func(ctx context.Context, reqOpts chat.Options) (chat.Message, error) {
+				// Determine route to appropriate API based on model type and whether tools are sent
+				nTools := len(c.tools.ForRequest(reqOpts))
+				// Note: The Responses API doesn't support tools, multiple candidates, or predicted outputs yet, so we fall back to ChatCompletions for them
+				if c.api == Responses && nTools == 0 && reqOpts.Candidates <= 1 && reqOpts.Prediction == "" {
+					return c.messageStreamResponses(ctx, msg, reqOpts)
+				}
+				return c.messageStreamChatCompletions(ctx, msg, reqOpts)
+			})
+		})
+	})
+	if err == nil {
+		resp.Model = c.state.ResponseModel()
+	}
+	return resp, finishDryRun(err)
 }
 
 // messageStreamResponses uses the Responses API for reasoning models (gpt-5, o1, o3)
-func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Message, callback chat.StreamCallback, opts ...chat.Option) (chat.Message, error) {
-	reqOpts := chat.ApplyOptions(opts...)
+func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+	callback := reqOpts.StreamingCb
 
 	// Snapshot state without holding lock during streaming
-	systemPrompt, history := c.snapshotState()
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
 
 	// Build input items for Responses API
 	var inputItems []responses.ResponseInputItemUnionParam
@@ -286,21 +349,22 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 
 		// Extract text content directly from Contents array
 		text := extractText(m)
-		if text == "" {
-			continue // Skip messages without text content
+		images := m.GetImages()
+		if text == "" && len(images) == 0 {
+			continue // Skip messages without text or image content
 		}
 
 		inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
 			OfMessage: &responses.EasyInputMessageParam{
 				Role:    role,
-				Content: responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(text)},
+				Content: responsesContent(text, images),
 			},
 		})
 	}
 
 	// Add current message with system reminder prepended if present
 	// This message (with system reminder) will be persisted for audit trail
-	msgWithReminder := withPrependedSystemReminder(ctx, msg)
+	msgWithReminder := common.WithPrependedSystemReminder(ctx, msg)
 
 	var currentRole responses.EasyInputMessageRole
 	switch msgWithReminder.Role {
@@ -313,14 +377,15 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 	}
 
 	text := extractText(msgWithReminder)
-	if text == "" {
+	images := msgWithReminder.GetImages()
+	if text == "" && len(images) == 0 {
 		return chat.Message{}, fmt.Errorf("current message has no text content")
 	}
 
 	inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
 		OfMessage: &responses.EasyInputMessageParam{
 			Role:    currentRole,
-			Content: responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(text)},
+			Content: responsesContent(text, images),
 		},
 	})
 
@@ -341,7 +406,23 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 		params.MaxOutputTokens = param.NewOpt(int64(reqOpts.MaxTokens))
 	}
 
-	c.logger.Debug("starting stream", "api", "responses", "model", c.modelName)
+	if reqOpts.User != "" {
+		params.User = param.NewOpt(reqOpts.User)
+	}
+
+	if reqOpts.JSONMode {
+		params.Text.Format.OfJSONObject = &shared.ResponseFormatJSONObjectParam{}
+	}
+
+	if key, ok := promptCacheKey(reqOpts); ok {
+		params.PromptCacheKey = param.NewOpt(key)
+	}
+
+	c.logger.DebugContext(ctx, "starting stream", "api", "responses", "model", c.modelName)
+
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+		return chat.Message{}, err
+	}
 
 	// Create streaming response
 	stream := c.openaiClient.Responses.NewStreaming(ctx, params)
@@ -362,7 +443,7 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 		event := stream.Current()
 		eventCount++
 
-		c.logger.Debug("event received", "api", "responses", "event_num", eventCount, "type", event.Type)
+		c.logger.DebugContext(ctx, "event received", "api", "responses", "event_num", eventCount, "type", event.Type)
 
 		// Handle different event types
 		switch event.Type {
@@ -450,19 +531,22 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 					InputTokens:  int(event.Response.Usage.InputTokens),
 					OutputTokens: int(event.Response.Usage.OutputTokens),
 					TotalTokens:  int(event.Response.Usage.TotalTokens),
+					CachedTokens: int(event.Response.Usage.InputTokensDetails.CachedTokens),
 				}
 				lastUsage = usage
-				c.logger.Debug("usage from completed event", "api", "responses", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
+				c.logger.DebugContext(ctx, "usage from completed event", "api", "responses", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
 			}
-			c.logger.Debug("stream completed", "api", "responses")
+			c.logger.DebugContext(ctx, "stream completed", "api", "responses")
 
 		case "response.output_text.done":
 			// Text output is complete
-			c.logger.Debug("output text done", "api", "responses")
+			c.logger.DebugContext(ctx, "output text done", "api", "responses")
 
 		case "response.created", "response.in_progress":
 			// Status events - just log at debug level
-			c.logger.Debug("status event", "api", "responses", "type", event.Type)
+			common.SetResponseID(ctx, event.Response.ID)
+			common.SetResponseModel(ctx, string(event.Response.Model))
+			c.logger.DebugContext(ctx, "status event", "api", "responses", "type", event.Type)
 
 		case "response.output_item.added":
 			// Check if this is a function call item
@@ -471,19 +555,19 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 					ID:   event.Item.ID,
 					Name: event.Item.Name,
 				}
-				c.logger.Debug("tool call started", "api", "responses", "id", event.Item.ID, "name", event.Item.Name)
+				c.logger.DebugContext(ctx, "tool call started", "api", "responses", "id", event.Item.ID, "name", event.Item.Name)
 			} else {
 				// Non-function item added (reasoning, message, etc.)
-				c.logger.Debug("output item added", "api", "responses", "type", event.Item.Type)
+				c.logger.DebugContext(ctx, "output item added", "api", "responses", "type", event.Item.Type)
 			}
 
 		case "response.output_item.done", "response.content_part.added", "response.content_part.done":
 			// Informational events about content structure
-			c.logger.Debug("content structure event", "api", "responses", "type", event.Type)
+			c.logger.DebugContext(ctx, "content structure event", "api", "responses", "type", event.Type)
 
 		case "error":
 			// Handle error events
-			c.logger.Debug("error event received", "api", "responses")
+			c.logger.DebugContext(ctx, "error event received", "api", "responses")
 
 		default:
 			// Log unhandled event types at debug level
@@ -495,13 +579,17 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 		return chat.Message{}, fmt.Errorf("responses API streaming error: %w", err)
 	}
 
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundEnd, 0, chat.RoundReasonComplete); err != nil {
+		return chat.Message{}, err
+	}
+
 	// Note: Tool calls in Responses API would need different handling than ChatCompletions
 	// The Responses API handles tools differently - it doesn't use the multi-round pattern
 	// For now, we log if tools were detected but not fully implemented
 	if len(toolCalls) > 0 {
-		c.logger.Warn("tool calls detected but not yet fully implemented for Responses API", "api", "responses", "tool_count", len(toolCalls))
+		c.logger.WarnContext(ctx, "tool calls detected but not yet fully implemented for Responses API", "api", "responses", "tool_count", len(toolCalls))
 		for _, tc := range toolCalls {
-			c.logger.Debug("tool detected", "api", "responses", "name", tc.Name, "id", tc.ID)
+			c.logger.DebugContext(ctx, "tool detected", "api", "responses", "name", tc.Name, "id", tc.ID)
 		}
 		// For now, just include any tool call info in the response
 		// TODO: Implement proper tool handling for Responses API
@@ -517,11 +605,11 @@ func (c *chatClient) messageStreamResponses(ctx context.Context, msg chat.Messag
 }
 
 // messageStreamChatCompletions uses the standard Chat Completions API
-func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.Message, callback chat.StreamCallback, opts ...chat.Option) (chat.Message, error) {
-	reqOpts := chat.ApplyOptions(opts...)
+func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.Message, reqOpts chat.Options) (chat.Message, error) {
+	callback := reqOpts.StreamingCb
 
 	// Snapshot state without holding lock during streaming
-	systemPrompt, history := c.snapshotState()
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
 
 	// Build message list
 	var messages []openai.ChatCompletionMessageParamUnion
@@ -532,7 +620,7 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 	}
 
 	// Convert history messages using the new converter
-	historyMsgs, err := messagesToOpenAI(history)
+	historyMsgs, err := c.history.Convert(history, messageToOpenAI)
 	if err != nil {
 		return chat.Message{}, fmt.Errorf("converting history messages: %w", err)
 	}
@@ -540,7 +628,7 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 
 	// Convert current message using the new converter, prepending system reminder if present
 	// This message (with system reminder) will be persisted for audit trail
-	msgWithReminder := withPrependedSystemReminder(ctx, msg)
+	msgWithReminder := common.WithPrependedSystemReminder(ctx, msg)
 	currentMsgs, err := messageToOpenAI(msgWithReminder)
 	if err != nil {
 		return chat.Message{}, fmt.Errorf("converting current message: %w", err)
@@ -554,7 +642,7 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 	}
 
 	// Add tools if registered
-	allTools := c.tools.GetAll()
+	allTools := c.tools.ForRequest(reqOpts)
 	if len(allTools) > 0 {
 		tools := make([]openai.ChatCompletionToolParam, 0, len(allTools))
 		for _, tool := range allTools {
@@ -579,6 +667,30 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 		params.MaxCompletionTokens = openai.Int(int64(reqOpts.MaxTokens))
 	}
 
+	if reqOpts.User != "" {
+		params.User = openai.String(reqOpts.User)
+	}
+
+	if reqOpts.JSONMode {
+		params.ResponseFormat = jsonObjectFormat()
+	}
+
+	if key, ok := promptCacheKey(reqOpts); ok {
+		params.PromptCacheKey = openai.String(key)
+	}
+
+	if reqOpts.Candidates > 1 {
+		params.N = openai.Int(int64(reqOpts.Candidates))
+	}
+
+	if reqOpts.Prediction != "" {
+		if len(allTools) > 0 {
+			c.logger.WarnContext(ctx, "predicted outputs not supported with tools, ignoring prediction")
+		} else {
+			params.Prediction = predictionContent(reqOpts.Prediction)
+		}
+	}
+
 	if reqOpts.ResponseFormat != nil && reqOpts.ResponseFormat.Schema != nil {
 		// Response format configuration would go here if supported by the SDK
 		// Currently skipping as the exact API may differ
@@ -588,7 +700,7 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 	if reqOpts.ReasoningEffort != "" && c.api == Responses {
 		// Reasoning effort is supported through the Responses API
 		// It can be configured in the ResponseNewParams if needed
-		c.logger.Debug("reasoning effort set", "api", "responses", "effort", reqOpts.ReasoningEffort)
+		c.logger.DebugContext(ctx, "reasoning effort set", "api", "responses", "effort", reqOpts.ReasoningEffort)
 	}
 
 	// Add stream options to include usage information
@@ -596,6 +708,10 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 		IncludeUsage: param.NewOpt(true),
 	}
 
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundStart, 0, chat.RoundReasonUserMessage); err != nil {
+		return chat.Message{}, err
+	}
+
 	// Streaming implementation
 	stream := c.openaiClient.Chat.Completions.NewStreaming(ctx, params)
 
@@ -603,49 +719,50 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 	var thinkingContent strings.Builder
 	var inThinking bool
 	chunkCount := 0
-	var toolCalls []openai.ChatCompletionMessageToolCall
-	var toolCallArgs map[int]strings.Builder = make(map[int]strings.Builder)
-	toolCallEmitted := make(set[int])
+	var toolCalls []common.StreamedToolCall
+	var toolCallAcc common.ToolCallAccumulator
 	var lastUsage chat.TokenUsageDetails
+	var candidates common.Candidates
 
 	for stream.Next() {
 		chunk := stream.Current()
 		chunkCount++
+		common.SetResponseID(ctx, chunk.ID)
+		common.SetResponseModel(ctx, chunk.Model)
 
 		// Check for usage information (provided in the final chunk when stream_options.include_usage is true)
-		if chunk.JSON.Usage.Valid() && chunk.Usage.PromptTokens > 0 {
+		if usage, ok := chunkUsage(chunk); ok {
 			// This is the final usage chunk
-			usage := chat.TokenUsageDetails{
-				InputTokens:  int(chunk.Usage.PromptTokens),
-				OutputTokens: int(chunk.Usage.CompletionTokens),
-				TotalTokens:  int(chunk.Usage.TotalTokens),
-			}
 			lastUsage = usage
-			c.logger.Debug("usage chunk received", "api", "chat_completions", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
+			c.logger.DebugContext(ctx, "usage chunk received", "api", "chat_completions", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
 		}
 
 		// Debug logging for SSE responses
 		rawJSON := chunk.RawJSON()
-		c.logger.Debug("chunk received", "api", "chat_completions", "chunk_num", chunkCount, "model", c.modelName, "raw", string(rawJSON))
+		c.logger.DebugContext(ctx, "chunk received", "api", "chat_completions", "chunk_num", chunkCount, "model", c.modelName, "raw", string(rawJSON))
 
 		// Log structured information about the chunk
 		if len(chunk.Choices) > 0 {
 			choice := chunk.Choices[0]
-			c.logger.Debug("chunk choice", "api", "chat_completions", "chunk_num", chunkCount, "index", choice.Index, "finish_reason", choice.FinishReason, "role", choice.Delta.Role, "content", choice.Delta.Content)
+			c.logger.DebugContext(ctx, "chunk choice", "api", "chat_completions", "chunk_num", chunkCount, "index", choice.Index, "finish_reason", choice.FinishReason, "role", choice.Delta.Role, "content", choice.Delta.Content)
 
 			// Check for extra fields that might contain reasoning content
 			if len(choice.Delta.JSON.ExtraFields) > 0 {
 				extraFieldsJSON, _ := json.Marshal(choice.Delta.JSON.ExtraFields)
-				c.logger.Debug("delta extra fields", "api", "chat_completions", "chunk_num", chunkCount, "fields", string(extraFieldsJSON))
+				c.logger.DebugContext(ctx, "delta extra fields", "api", "chat_completions", "chunk_num", chunkCount, "fields", string(extraFieldsJSON))
 			}
 			if len(choice.JSON.ExtraFields) > 0 {
 				extraFieldsJSON, _ := json.Marshal(choice.JSON.ExtraFields)
-				c.logger.Debug("choice extra fields", "api", "chat_completions", "chunk_num", chunkCount, "fields", string(extraFieldsJSON))
+				c.logger.DebugContext(ctx, "choice extra fields", "api", "chat_completions", "chunk_num", chunkCount, "fields", string(extraFieldsJSON))
 			}
 		}
 
-		if len(chunk.Choices) > 0 {
-			choice := chunk.Choices[0]
+		for _, choice := range chunk.Choices {
+			// Extra candidates are collected without streaming them
+			if choice.Index > 0 {
+				candidates.Add(int(choice.Index), choice.Delta.Refusal+choice.Delta.Content)
+				continue
+			}
 
 			// Check for refusal content
 			if choice.Delta.Refusal != "" {
@@ -662,50 +779,28 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 					}
 				}
 
-				c.logger.Debug("refusal content", "api", "chat_completions", "content", refusalContent)
+				c.logger.DebugContext(ctx, "refusal content", "api", "chat_completions", "content", refusalContent)
 			}
 
 			// Check for tool calls
 			if len(choice.Delta.ToolCalls) > 0 {
 				for _, tc := range choice.Delta.ToolCalls {
-					// New tool call or continuation
-					idx := int(tc.Index)
-
-					// Ensure we have enough space in toolCalls slice
-					for len(toolCalls) <= idx {
-						toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCall{})
-					}
-
-					if tc.ID != "" {
-						// Starting a new tool call
-						toolCalls[idx].ID = tc.ID
-						toolCallArgs[idx] = strings.Builder{}
-					}
-
-					if tc.Function.Name != "" {
-						toolCalls[idx].Function.Name = tc.Function.Name
+					call, complete, err := toolCallAcc.Add(common.ToolCallFragment{
+						Index:     int(tc.Index),
+						ID:        tc.ID,
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					})
+					if err != nil {
+						return chat.Message{}, err
 					}
-					if tc.Function.Arguments != "" {
-						builder := toolCallArgs[idx]
-						builder.WriteString(tc.Function.Arguments)
-						toolCallArgs[idx] = builder
-						toolCalls[idx].Function.Arguments = builder.String()
+					if err := common.EmitToolCallDelta(callback, call.ID, call.Name, tc.Function.Arguments); err != nil {
+						return chat.Message{}, err
 					}
 
-					// Emit tool call event only once per index when arguments are valid JSON
-					if callback != nil && !toolCallEmitted.Contains(idx) && toolCalls[idx].ID != "" && toolCalls[idx].Function.Name != "" && isValidJSON(toolCalls[idx].Function.Arguments) {
-						toolCallEvent := chat.StreamEvent{
-							Type: chat.StreamEventTypeToolCall,
-							ToolCalls: []chat.ToolCall{
-								{
-									ID:        toolCalls[idx].ID,
-									Name:      toolCalls[idx].Function.Name,
-									Arguments: json.RawMessage(toolCalls[idx].Function.Arguments),
-								},
-							},
-						}
-						toolCallEmitted.Add(idx)
-						if err := callback(toolCallEvent); err != nil {
+					// Emit the tool call event once its arguments are valid JSON
+					if complete {
+						if err := common.EmitToolCall(callback, call); err != nil {
 							return chat.Message{}, err
 						}
 					}
@@ -750,28 +845,29 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 
 			// Check if stream is done
 			if choice.FinishReason != "" {
-				c.logger.Debug("stream finished", "api", "chat_completions", "reason", choice.FinishReason)
+				c.logger.DebugContext(ctx, "stream finished", "api", "chat_completions", "reason", choice.FinishReason)
 			}
 
 			// Log any unhandled extra fields
 			if len(choice.Delta.JSON.ExtraFields) > 0 {
 				for fieldName, field := range choice.Delta.JSON.ExtraFields {
 					if field.Valid() {
-						c.logger.Debug("unhandled extra field", "api", "chat_completions", "field", fieldName, "value", field.Raw())
+						c.logger.DebugContext(ctx, "unhandled extra field", "api", "chat_completions", "field", fieldName, "value", field.Raw())
 					}
 				}
 			}
 		}
 	}
 
-	c.logger.Debug("stream completed", "api", "chat_completions", "total_chunks", chunkCount)
+	c.logger.DebugContext(ctx, "stream completed", "api", "chat_completions", "total_chunks", chunkCount)
+	toolCalls = toolCallAcc.Calls()
 
 	if err := stream.Err(); err != nil {
 		// Check if the error is about unsupported temperature
 		errStr := err.Error()
 		if strings.Contains(errStr, "temperature") && strings.Contains(errStr, "does not support") && temperatureSet {
 			// Retry without temperature
-			c.logger.Info("retrying without temperature", "model", c.modelName, "reason", "temperature not supported")
+			c.logger.InfoContext(ctx, "retrying without temperature", "model", c.modelName, "reason", "temperature not supported")
 			// Create new params without temperature
 			paramsNoTemp := openai.ChatCompletionNewParams{
 				Messages: messages,
@@ -780,8 +876,19 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 			if reqOpts.MaxTokens > 0 {
 				paramsNoTemp.MaxCompletionTokens = openai.Int(int64(reqOpts.MaxTokens))
 			}
+			if reqOpts.User != "" {
+				paramsNoTemp.User = openai.String(reqOpts.User)
+			}
+			if reqOpts.JSONMode {
+				paramsNoTemp.ResponseFormat = jsonObjectFormat()
+			}
+			if reqOpts.Candidates > 1 {
+				paramsNoTemp.N = openai.Int(int64(reqOpts.Candidates))
+			}
+			paramsNoTemp.Prediction = params.Prediction
+			paramsNoTemp.PromptCacheKey = params.PromptCacheKey
 			// Add tools if registered (for retry)
-			allTools := c.tools.GetAll()
+			allTools := c.tools.ForRequest(reqOpts)
 			if len(allTools) > 0 {
 				tools := make([]openai.ChatCompletionToolParam, 0, len(allTools))
 				for _, tool := range allTools {
@@ -805,26 +912,27 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 			inThinking = false
 			chunkCount = 0
 			lastUsage = chat.TokenUsageDetails{}
+			candidates = common.Candidates{}
 
 			for stream.Next() {
 				chunk := stream.Current()
 				chunkCount++
+				common.SetResponseID(ctx, chunk.ID)
+				common.SetResponseModel(ctx, chunk.Model)
 
 				// Check for usage information in retry path
-				if chunk.JSON.Usage.Valid() && chunk.Usage.PromptTokens > 0 {
-					usage := chat.TokenUsageDetails{
-						InputTokens:  int(chunk.Usage.PromptTokens),
-						OutputTokens: int(chunk.Usage.CompletionTokens),
-						TotalTokens:  int(chunk.Usage.TotalTokens),
-					}
+				if usage, ok := chunkUsage(chunk); ok {
 					lastUsage = usage
-					c.logger.Debug("retry usage chunk received", "api", "chat_completions", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
+					c.logger.DebugContext(ctx, "retry usage chunk received", "api", "chat_completions", "input", usage.InputTokens, "output", usage.OutputTokens, "total", usage.TotalTokens)
 				}
 
-				c.logger.Debug("retry chunk received", "api", "chat_completions", "chunk_num", chunkCount, "model", c.modelName)
+				c.logger.DebugContext(ctx, "retry chunk received", "api", "chat_completions", "chunk_num", chunkCount, "model", c.modelName)
 
-				if len(chunk.Choices) > 0 {
-					choice := chunk.Choices[0]
+				for _, choice := range chunk.Choices {
+					if choice.Index > 0 {
+						candidates.Add(int(choice.Index), choice.Delta.Content)
+						continue
+					}
 
 					// Check for reasoning content in retry
 					reasoningFieldNames := []string{"reasoning_content", "reasoning", "thinking_content", "thinking"}
@@ -906,9 +1014,22 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 		}
 	}
 
+	if err := common.EmitRound(callback, chat.StreamEventTypeRoundEnd, 0, common.RoundEndReason(len(toolCalls) > 0)); err != nil {
+		return chat.Message{}, err
+	}
+
 	// Handle tool calls with multiple rounds if needed
 	if len(toolCalls) > 0 {
-		return c.handleToolCallRounds(ctx, msgWithReminder, respContent.String(), toolCalls, reqOpts, callback)
+		// Record this round's usage; the follow-up rounds record their own
+		c.state.UpdateUsage(lastUsage)
+		resp := chat.Message{Role: chat.AssistantRole}
+		if respContent.Len() > 0 {
+			resp.AddText(respContent.String())
+		}
+		for _, call := range toolCalls {
+			resp.AddToolCall(call.ToolCall())
+		}
+		return c.handleToolCallRounds(ctx, msgWithReminder, resp, reqOpts, callback)
 	}
 
 	respMsg := chat.AssistantMessage(respContent.String())
@@ -917,261 +1038,191 @@ func (c *chatClient) messageStreamChatCompletions(ctx context.Context, msg chat.
 	// Persist the message WITH system reminder for complete audit trail
 	c.updateHistoryAndUsage([]chat.Message{msgWithReminder, respMsg}, lastUsage)
 
+	// The history keeps only the first candidate
+	respMsg.Candidates = candidates.Messages()
+
 	// Update last usage
 	if lastUsage.TotalTokens == 0 {
-		c.logger.Warn("no token usage information received", "api", "chat_completions")
+		c.logger.WarnContext(ctx, "no token usage information received", "api", "chat_completions")
 	}
 
 	return respMsg, nil
 }
 
-// handleToolCallRounds handles potentially multiple rounds of tool calls
-func (c *chatClient) handleToolCallRounds(ctx context.Context, initialMsg chat.Message, initialContent string, initialToolCalls []openai.ChatCompletionMessageToolCall, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
-	// Keep track of all messages for the conversation
-	var msgs []openai.ChatCompletionMessageParamUnion
-
-	// Build conversation messages and update history
-	systemPrompt, history := c.state.Snapshot()
+// handleToolCallRounds runs the tool calls in resp, the response to userMsg,
+// and the rounds of tool calls that follow.
+func (c *chatClient) handleToolCallRounds(ctx context.Context, userMsg, resp chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, error) {
+	// Convert the history before the loop records the user message
+	systemPrompt, history := c.state.RequestSnapshot(reqOpts)
+	var prefix []openai.ChatCompletionMessageParamUnion
 	if systemPrompt != "" {
-		msgs = append(msgs, openai.SystemMessage(systemPrompt))
+		prefix = append(prefix, openai.SystemMessage(systemPrompt))
 	}
-	historyMsgs, err := messagesToOpenAI(history)
+	historyMsgs, err := c.history.Convert(history, messageToOpenAI)
 	if err != nil {
 		return chat.Message{}, fmt.Errorf("converting history messages: %w", err)
 	}
-	msgs = append(msgs, historyMsgs...)
-
-	// Add the initial user message to history
-	c.state.AppendMessages([]chat.Message{initialMsg}, nil)
-
-	// Convert initial message
-	initialMsgs, err := messageToOpenAI(initialMsg)
-	if err != nil {
-		return chat.Message{}, fmt.Errorf("converting initial message: %w", err)
+	prefix = append(prefix, historyMsgs...)
+
+	loop := common.ToolLoop{
+		State:      c.state,
+		Tools:      c.tools,
+		Provider:   "openai",
+		Model:      c.modelName,
+		RepairArgs: c.repairToolArgs,
+		Logger:     c.logger,
+		Send: func(ctx context.Context, msgs []chat.Message) (chat.Message, chat.TokenUsageDetails, error) {
+			return c.streamToolRound(ctx, prefix, msgs, reqOpts, callback)
+		},
 	}
-	msgs = append(msgs, initialMsgs...)
-
-	// Process tool calls in a loop until we get a final response
-	toolCalls := initialToolCalls
-	isFirstIteration := true
-
-	for len(toolCalls) > 0 {
-		c.logger.Debug("processing tool calls", "count", len(toolCalls))
+	return loop.Run(ctx, callback, userMsg, resp)
+}
 
-		// Execute tool calls
-		chatToolResults, err := c.handleToolCalls(ctx, toolCalls, callback)
+// streamToolRound streams the response in a round of tool calls. prefix is
+// the system prompt and history, and turn the messages since.
+func (c *chatClient) streamToolRound(ctx context.Context, prefix []openai.ChatCompletionMessageParamUnion, turn []chat.Message, reqOpts chat.Options, callback chat.StreamCallback) (chat.Message, chat.TokenUsageDetails, error) {
+	msgs := slices.Clip(prefix)
+	for _, msg := range turn {
+		converted, err := messageToOpenAI(msg)
 		if err != nil {
-			return chat.Message{}, fmt.Errorf("failed to execute tool calls: %w", err)
+			return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("converting %s message: %w", msg.Role, err)
 		}
+		msgs = append(msgs, converted...)
+	}
 
-		// Persist assistant tool call message and tool results in chat state
-		chatToolCalls := make([]chat.ToolCall, len(toolCalls))
-		for i, tc := range toolCalls {
-			chatToolCalls[i] = openaiToolCallToChat(tc)
-		}
-		assistantMsg := chat.Message{Role: chat.AssistantRole}
-		// Add initial text content to the first assistant message if present
-		if isFirstIteration && initialContent != "" {
-			assistantMsg.AddText(initialContent)
-		}
-		for _, tc := range chatToolCalls {
-			assistantMsg.AddToolCall(tc)
-		}
-		toolMessages := []chat.Message{assistantMsg}
-		if len(chatToolResults) > 0 {
-			toolMsg := chat.Message{Role: chat.ToolRole}
-			for _, tr := range chatToolResults {
-				toolMsg.AddToolResult(tr)
-			}
-			toolMessages = append(toolMessages, toolMsg)
-		}
-		c.state.AppendMessages(toolMessages, nil)
-
-		// Convert assistant message with tool calls using the new converter
-		assistantMsgs, err := messageToOpenAI(assistantMsg)
-		if err != nil {
-			return chat.Message{}, fmt.Errorf("converting assistant message with tool calls: %w", err)
-		}
-		msgs = append(msgs, assistantMsgs...)
-
-		// Add tool results to messages (only if non-empty to avoid potential API issues)
-		if len(chatToolResults) > 0 {
-			// Prepend system reminder before converting tool messages
-			toolMsg := toolMessages[len(toolMessages)-1]
-			toolMsgWithReminder := withPrependedSystemReminder(ctx, toolMsg)
-			toolResultMsgs, err := messageToOpenAI(toolMsgWithReminder)
+	followUpParams := openai.ChatCompletionNewParams{
+		Messages: msgs,
+		Model:    c.modelName,
+	}
+	if reqOpts.Temperature != nil {
+		followUpParams.Temperature = openai.Float(*reqOpts.Temperature)
+	}
+	if reqOpts.MaxTokens > 0 {
+		followUpParams.MaxCompletionTokens = openai.Int(int64(reqOpts.MaxTokens))
+	}
+	if reqOpts.User != "" {
+		followUpParams.User = openai.String(reqOpts.User)
+	}
+	if reqOpts.JSONMode {
+		followUpParams.ResponseFormat = jsonObjectFormat()
+	}
+	if key, ok := promptCacheKey(reqOpts); ok {
+		followUpParams.PromptCacheKey = openai.String(key)
+	}
+	// Add tools if registered (for follow-up after tool execution)
+	allTools := c.tools.ForRequest(reqOpts)
+	if len(allTools) > 0 {
+		tools := make([]openai.ChatCompletionToolParam, 0, len(allTools))
+		for _, tool := range allTools {
+			toolParam, err := c.mcpToOpenAITool(tool)
 			if err != nil {
-				return chat.Message{}, fmt.Errorf("converting tool result messages: %w", err)
+				// Skip this tool on error
+				continue
 			}
-			msgs = append(msgs, toolResultMsgs...)
-		}
-
-		// Make another API call with tool results
-		followUpParams := openai.ChatCompletionNewParams{
-			Messages: msgs,
-			Model:    c.modelName,
-		}
-		if reqOpts.Temperature != nil {
-			followUpParams.Temperature = openai.Float(*reqOpts.Temperature)
-		}
-		if reqOpts.MaxTokens > 0 {
-			followUpParams.MaxCompletionTokens = openai.Int(int64(reqOpts.MaxTokens))
-		}
-		// Add tools if registered (for follow-up after tool execution)
-		allTools := c.tools.GetAll()
-		if len(allTools) > 0 {
-			tools := make([]openai.ChatCompletionToolParam, 0, len(allTools))
-			for _, tool := range allTools {
-				toolParam, err := c.mcpToOpenAITool(tool)
-				if err != nil {
-					// Skip this tool on error
-					continue
-				}
-				tools = append(tools, toolParam)
-			}
-			followUpParams.Tools = tools
-		}
-		// Add stream options to include usage information
-		followUpParams.StreamOptions = openai.ChatCompletionStreamOptionsParam{
-			IncludeUsage: param.NewOpt(true),
+			tools = append(tools, toolParam)
 		}
+		followUpParams.Tools = tools
+	}
+	// Add stream options to include usage information
+	followUpParams.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: param.NewOpt(true),
+	}
 
-		// Create a new stream for the follow-up request
-		followUpStream := c.openaiClient.Chat.Completions.NewStreaming(ctx, followUpParams)
+	// Create a new stream for the follow-up request
+	followUpStream := c.openaiClient.Chat.Completions.NewStreaming(ctx, followUpParams)
 
-		// Process the follow-up stream
-		var respContent strings.Builder
-		toolCalls = nil // Reset for next round
-		var toolCallArgs map[int]strings.Builder = make(map[int]strings.Builder)
-		toolCallEmitted := make(set[int])
-		var lastUsage chat.TokenUsageDetails
+	// Process the follow-up stream
+	var respContent strings.Builder
+	var toolCallAcc common.ToolCallAccumulator
+	var lastUsage chat.TokenUsageDetails
 
-		for followUpStream.Next() {
-			chunk := followUpStream.Current()
+	for followUpStream.Next() {
+		chunk := followUpStream.Current()
+		common.SetResponseID(ctx, chunk.ID)
+		common.SetResponseModel(ctx, chunk.Model)
 
-			// Check for usage information
-			if chunk.JSON.Usage.Valid() && chunk.Usage.PromptTokens > 0 {
-				usage := chat.TokenUsageDetails{
-					InputTokens:  int(chunk.Usage.PromptTokens),
-					OutputTokens: int(chunk.Usage.CompletionTokens),
-					TotalTokens:  int(chunk.Usage.TotalTokens),
-				}
-				lastUsage = usage
-			}
+		// Check for usage information
+		if usage, ok := chunkUsage(chunk); ok {
+			lastUsage = usage
+		}
 
-			if len(chunk.Choices) > 0 {
-				choice := chunk.Choices[0]
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
 
-				// Check for refusal content in follow-up
-				if choice.Delta.Refusal != "" {
-					refusalContent := choice.Delta.Refusal
-					respContent.WriteString(refusalContent)
+			// Check for refusal content in follow-up
+			if choice.Delta.Refusal != "" {
+				refusalContent := choice.Delta.Refusal
+				respContent.WriteString(refusalContent)
 
-					if callback != nil {
-						event := chat.StreamEvent{
-							Type:    chat.StreamEventTypeContent,
-							Content: refusalContent,
-						}
-						if err := callback(event); err != nil {
-							return chat.Message{}, err
-						}
+				if callback != nil {
+					event := chat.StreamEvent{
+						Type:    chat.StreamEventTypeContent,
+						Content: refusalContent,
+					}
+					if err := callback(event); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
 					}
-
-					c.logger.Debug("follow-up refusal content", "content", refusalContent)
 				}
 
-				// Check for tool calls
-				if len(choice.Delta.ToolCalls) > 0 {
-					for _, tc := range choice.Delta.ToolCalls {
-						idx := int(tc.Index)
-
-						// Ensure we have enough space in toolCalls slice
-						for len(toolCalls) <= idx {
-							toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCall{})
-						}
+				c.logger.DebugContext(ctx, "follow-up refusal content", "content", refusalContent)
+			}
 
-						if tc.ID != "" {
-							// Starting a new tool call
-							toolCalls[idx].ID = tc.ID
-							toolCallArgs[idx] = strings.Builder{}
-						}
+			// Check for tool calls
+			if len(choice.Delta.ToolCalls) > 0 {
+				for _, tc := range choice.Delta.ToolCalls {
+					call, complete, err := toolCallAcc.Add(common.ToolCallFragment{
+						Index:     int(tc.Index),
+						ID:        tc.ID,
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					})
+					if err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
+					if err := common.EmitToolCallDelta(callback, call.ID, call.Name, tc.Function.Arguments); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
+					}
 
-						if tc.Function.Name != "" {
-							toolCalls[idx].Function.Name = tc.Function.Name
-						}
-						if tc.Function.Arguments != "" {
-							builder := toolCallArgs[idx]
-							builder.WriteString(tc.Function.Arguments)
-							toolCallArgs[idx] = builder
-							toolCalls[idx].Function.Arguments = builder.String()
-						}
-
-						// Emit tool call event only once per index when arguments are valid JSON
-						if callback != nil && !toolCallEmitted.Contains(idx) && toolCalls[idx].ID != "" && toolCalls[idx].Function.Name != "" && isValidJSON(toolCalls[idx].Function.Arguments) {
-							toolCallEvent := chat.StreamEvent{
-								Type: chat.StreamEventTypeToolCall,
-								ToolCalls: []chat.ToolCall{
-									{
-										ID:        toolCalls[idx].ID,
-										Name:      toolCalls[idx].Function.Name,
-										Arguments: json.RawMessage(toolCalls[idx].Function.Arguments),
-									},
-								},
-							}
-							toolCallEmitted.Add(idx)
-							if err := callback(toolCallEvent); err != nil {
-								return chat.Message{}, err
-							}
+					// Emit the tool call event once its arguments are valid JSON
+					if complete {
+						if err := common.EmitToolCall(callback, call); err != nil {
+							return chat.Message{}, chat.TokenUsageDetails{}, err
 						}
 					}
 				}
+			}
 
-				// Check for regular content
-				if choice.Delta.Content != "" {
-					content := choice.Delta.Content
-					respContent.WriteString(content)
+			// Check for regular content
+			if choice.Delta.Content != "" {
+				content := choice.Delta.Content
+				respContent.WriteString(content)
 
-					// Call the callback with the content event
-					if callback != nil {
-						event := chat.StreamEvent{
-							Type:    chat.StreamEventTypeContent,
-							Content: content,
-						}
-						if err := callback(event); err != nil {
-							return chat.Message{}, err
-						}
+				// Call the callback with the content event
+				if callback != nil {
+					event := chat.StreamEvent{
+						Type:    chat.StreamEventTypeContent,
+						Content: content,
+					}
+					if err := callback(event); err != nil {
+						return chat.Message{}, chat.TokenUsageDetails{}, err
 					}
 				}
 			}
 		}
+	}
 
-		if err := followUpStream.Err(); err != nil {
-			return chat.Message{}, fmt.Errorf("follow-up streaming error: %w", err)
-		}
-
-		// If we got more tool calls, continue the loop
-		if len(toolCalls) > 0 {
-			c.logger.Debug("got more tool calls", "count", len(toolCalls))
-			isFirstIteration = false
-			continue
-		}
-
-		// No more tool calls, we have the final response
-		finalMsg := chat.AssistantMessage(respContent.String())
-
-		// Log if content is empty
-		if finalMsg.GetText() == "" {
-			c.logger.Warn("final response after tool execution has empty content")
-		}
-
-		// Update history with the final response
-		c.state.AppendMessages([]chat.Message{finalMsg}, &lastUsage)
-
-		return finalMsg, nil
+	if err := followUpStream.Err(); err != nil {
+		return chat.Message{}, chat.TokenUsageDetails{}, fmt.Errorf("follow-up streaming error: %w", err)
 	}
 
-	// This should never be reached since the loop continues until no tool calls
-	return chat.Message{}, fmt.Errorf("unexpected end of tool call processing")
+	resp := chat.Message{Role: chat.AssistantRole}
+	if respContent.Len() > 0 {
+		resp.AddText(respContent.String())
+	}
+	for _, call := range toolCallAcc.Calls() {
+		resp.AddToolCall(call.ToolCall())
+	}
+	return resp, lastUsage, nil
 }
 
 // mcpToOpenAITool converts an MCP tool definition to OpenAI format
@@ -1195,53 +1246,21 @@ func (c *chatClient) mcpToOpenAITool(mcpDef chat.ToolDef) (openai.ChatCompletion
 		}
 	}
 
-	return openai.ChatCompletionToolParam{
-		Function: shared.FunctionDefinitionParam{
-			Name:        mcpDef.Name(),
-			Description: param.NewOpt(mcpDef.Description()),
-			Parameters:  parameters,
-		},
-	}, nil
-}
-
-// handleToolCalls processes tool calls from the model and returns tool results
-func (c *chatClient) handleToolCalls(ctx context.Context, toolCalls []openai.ChatCompletionMessageToolCall, callback chat.StreamCallback) ([]chat.ToolResult, error) {
-	if len(toolCalls) == 0 {
-		return nil, nil
+	function := shared.FunctionDefinitionParam{
+		Name:        mcpDef.Name(),
+		Description: param.NewOpt(mcpDef.Description()),
+		Parameters:  parameters,
 	}
-
-	var chatResults []chat.ToolResult
-
-	for _, toolCall := range toolCalls {
-		result, err := c.tools.Execute(ctx, toolCall.Function.Name, toolCall.Function.Arguments)
-		toolResult := common.BuildToolResult(toolCall.Function.Name, toolCall.ID, result, err)
-
-		if callback != nil {
-			toolResultEvent := chat.StreamEvent{
-				Type:        chat.StreamEventTypeToolResult,
-				ToolResults: []chat.ToolResult{toolResult},
-			}
-			if callbackErr := callback(toolResultEvent); callbackErr != nil {
-				return nil, fmt.Errorf("callback error: %w", callbackErr)
-			}
+	if c.strictTools {
+		strict, err := strictSchema(parameters)
+		if err != nil {
+			return openai.ChatCompletionToolParam{}, fmt.Errorf("tool %q isn't compatible with strict mode: %w", mcpDef.Name(), err)
 		}
-
-		chatResults = append(chatResults, toolResult)
+		function.Parameters = strict
+		function.Strict = param.NewOpt(true)
 	}
 
-	return chatResults, nil
-}
-
-func openaiToolCallToChat(tc openai.ChatCompletionMessageToolCall) chat.ToolCall {
-	var args json.RawMessage
-	if tc.Function.Arguments != "" {
-		args = json.RawMessage(tc.Function.Arguments)
-	}
-	return chat.ToolCall{
-		ID:        tc.ID,
-		Name:      tc.Function.Name,
-		Arguments: args,
-	}
+	return openai.ChatCompletionToolParam{Function: function}, nil
 }
 
 func (c *chatClient) History() (systemPrompt string, msgs []chat.Message) {
@@ -1258,8 +1277,41 @@ func (c *chatClient) MaxTokens() int {
 	return c.maxTokens
 }
 
-// RegisterTool registers a tool that can be called by the LLM
+// ContextLimit returns the size of the model's context window
+func (c *chatClient) ContextLimit() int {
+	return c.contextLimit
+}
+
+// Model returns the name of the model
+func (c *chatClient) Model() string {
+	return c.modelName
+}
+
+// LastRequests implements chat.RequestReporter
+func (c *chatClient) LastRequests() []chat.RequestInfo {
+	return c.state.LastRequests()
+}
+
+// EstimateRequest implements chat.RequestEstimator
+func (c *chatClient) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	return common.EstimateRequest(ctx, c, msg, opts...)
+}
+
+// SetSystemPrompt replaces the system prompt for subsequent messages
+func (c *chatClient) SetSystemPrompt(ctx context.Context, prompt string) error {
+	c.state.SetSystemPrompt(prompt)
+	return nil
+}
+
+// RegisterTool registers a tool that can be called by the LLM. With
+// WithStrictTools, tools whose schemas strict mode can't accept are
+// rejected.
 func (c *chatClient) RegisterTool(tool chat.Tool) error {
+	if c.strictTools {
+		if _, err := c.mcpToOpenAITool(tool); err != nil {
+			return err
+		}
+	}
 	return c.tools.Register(tool)
 }
 
@@ -1273,15 +1325,6 @@ func (c *chatClient) ListTools() []string {
 	return c.tools.List()
 }
 
-// isValidJSON returns true if s is a complete valid JSON value
-func isValidJSON(s string) bool {
-	if strings.TrimSpace(s) == "" {
-		return false
-	}
-	var v interface{}
-	return json.Unmarshal([]byte(s), &v) == nil
-}
-
 // messageToOpenAI converts a chat.Message to OpenAI message parameters.
 // This function handles all message types (User, Assistant, Tool) and content types
 // (text, tool calls, tool results) using the unified Contents array approach.
@@ -1298,8 +1341,12 @@ func messageToOpenAI(msg chat.Message) ([]openai.ChatCompletionMessageParamUnion
 
 	switch msg.Role {
 	case chat.UserRole:
-		// User messages can only contain text content
+		// User messages can only contain text and image content
 		text := extractText(msg)
+		images := msg.GetImages()
+		if len(images) > 0 {
+			return []openai.ChatCompletionMessageParamUnion{openai.UserMessage(imageContentParts(text, images))}, nil
+		}
 		if text == "" {
 			return nil, fmt.Errorf("user message has no text content")
 		}
@@ -1322,6 +1369,11 @@ func messageToOpenAI(msg chat.Message) ([]openai.ChatCompletionMessageParamUnion
 
 		// Validate that we have at least some content
 		if assistant.Content.OfString.Value == "" && len(assistant.ToolCalls) == 0 {
+			// Thinking from other providers can't be sent to OpenAI, so a
+			// message consisting only of thinking is dropped rather than failing
+			if hasThinking(msg) {
+				return nil, nil
+			}
 			return nil, fmt.Errorf("assistant message has no valid content")
 		}
 
@@ -1346,13 +1398,26 @@ func messageToOpenAI(msg chat.Message) ([]openai.ChatCompletionMessageParamUnion
 		for _, tr := range toolResults {
 			content := tr.Content
 			if tr.Error != "" {
-				content = common.FormatToolErrorJSON(tr.Error)
+				content = common.FormatToolResultError(tr)
 			}
 			if content == "" {
 				content = "{}"
 			}
 			msgs = append(msgs, openai.ToolMessage(content, tr.ToolCallID))
 		}
+		// Tool messages can only contain text, so images from tools follow
+		// the results as a user message
+		for _, tr := range toolResults {
+			if len(tr.Images) > 0 {
+				text := fmt.Sprintf("Images returned by the %s tool (call %s):", tr.Name, tr.ToolCallID)
+				msgs = append(msgs, openai.UserMessage(imageContentParts(text, tr.Images)))
+			}
+		}
+		// Tool messages only carry results, so a system reminder sent with
+		// them follows as a user message
+		if reminder := systemReminderText(msg); reminder != "" {
+			msgs = append(msgs, openai.UserMessage(reminder))
+		}
 		return msgs, nil
 
 	case "system":
@@ -1368,6 +1433,42 @@ func messageToOpenAI(msg chat.Message) ([]openai.ChatCompletionMessageParamUnion
 	}
 }
 
+// imageContentParts returns the content parts of a user message with text,
+// if any, followed by images.
+func imageContentParts(text string, images []chat.ImageContent) []openai.ChatCompletionContentPartUnionParam {
+	var parts []openai.ChatCompletionContentPartUnionParam
+	if text != "" {
+		parts = append(parts, openai.TextContentPart(text))
+	}
+	for _, img := range images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: img.DataURL(),
+		}))
+	}
+	return parts
+}
+
+// responsesContent returns the content of a Responses API input message:
+// text alone, or text followed by images.
+func responsesContent(text string, images []chat.ImageContent) responses.EasyInputMessageContentUnionParam {
+	if len(images) == 0 {
+		return responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(text)}
+	}
+	var parts responses.ResponseInputMessageContentListParam
+	if text != "" {
+		parts = append(parts, responses.ResponseInputContentParamOfInputText(text))
+	}
+	for _, img := range images {
+		parts = append(parts, responses.ResponseInputContentUnionParam{
+			OfInputImage: &responses.ResponseInputImageParam{
+				Detail:   responses.ResponseInputImageDetailAuto,
+				ImageURL: param.NewOpt(img.DataURL()),
+			},
+		})
+	}
+	return responses.EasyInputMessageContentUnionParam{OfInputItemContentList: parts}
+}
+
 // extractText concatenates all text content from a message, including system reminders.
 func extractText(msg chat.Message) string {
 	var text string
@@ -1389,6 +1490,27 @@ func extractText(msg chat.Message) string {
 	return text
 }
 
+// systemReminderText concatenates the system reminders in a message.
+func systemReminderText(msg chat.Message) string {
+	var reminders []string
+	for _, content := range msg.Contents {
+		if content.SystemReminder != "" {
+			reminders = append(reminders, content.SystemReminder)
+		}
+	}
+	return strings.Join(reminders, "\n")
+}
+
+// hasThinking reports whether a message contains thinking content.
+func hasThinking(msg chat.Message) bool {
+	for _, content := range msg.Contents {
+		if content.Thinking != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // extractToolCalls collects all tool calls from a message.
 func extractToolCalls(msg chat.Message) []chat.ToolCall {
 	var calls []chat.ToolCall
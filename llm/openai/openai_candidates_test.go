@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_WithCandidates(t *testing.T) {
+	t.Parallel()
+
+	for name, api := range map[string]API{"ChatCompletions": ChatCompletions, "Responses": Responses} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var paths []string
+			var ns []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				var req struct {
+					N int `json:"n"`
+				}
+				require.NoError(t, json.Unmarshal(body, &req))
+				paths = append(paths, r.URL.Path)
+				ns = append(ns, req.N)
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, delta := range []struct {
+					index   int
+					content string
+				}{{0, "Red"}, {1, "Blue"}, {0, " apple"}, {2, "Green"}, {1, " sky"}} {
+					fmt.Fprintf(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":%d,\"delta\":{\"content\":%q}}]}\n\n", delta.index, delta.content)
+				}
+				fmt.Fprint(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":6,\"total_tokens\":11}}\n\n")
+				fmt.Fprint(w, "data: [DONE]\n\n")
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(api))
+			require.NoError(t, err)
+
+			c := client.NewChat("")
+			var streamed string
+			resp, err := c.Message(context.Background(), chat.UserMessage("Name a color"),
+				chat.WithCandidates(3),
+				chat.WithStreamingCb(func(event chat.StreamEvent) error {
+					if event.Type == chat.StreamEventTypeContent {
+						streamed += event.Content
+					}
+					return nil
+				}),
+			)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"/chat/completions"}, paths)
+			assert.Equal(t, []int{3}, ns)
+			assert.Equal(t, "Red apple", resp.GetText())
+			assert.Equal(t, "Red apple", streamed)
+			require.Len(t, resp.Candidates, 2)
+			assert.Equal(t, "Blue sky", resp.Candidates[0].GetText())
+			assert.Equal(t, "Green", resp.Candidates[1].GetText())
+
+			_, history := c.History()
+			require.Len(t, history, 2)
+			assert.Equal(t, "Red apple", history[1].GetText())
+			assert.Empty(t, history[1].Candidates)
+		})
+	}
+}
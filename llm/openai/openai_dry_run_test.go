@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_DryRun(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		api  API
+		path string
+	}{
+		"ChatCompletions": {ChatCompletions, "/chat/completions"},
+		"Responses":       {Responses, "/responses"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(tc.api))
+			require.NoError(t, err)
+
+			c := client.NewChat("You are helpful.")
+			req, err := chat.DryRun(context.Background(), c, chat.UserMessage("Hello"), chat.WithTemperature(0.5))
+			require.NoError(t, err)
+			assert.Zero(t, requests.Load())
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, server.URL+tc.path, req.URL)
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(req.Body, &body))
+			assert.Equal(t, "gpt-4o", body["model"])
+			assert.Equal(t, 0.5, body["temperature"])
+			assert.Contains(t, string(req.Body), "Hello")
+			assert.Contains(t, string(req.Body), "You are helpful.")
+
+			// The history is unchanged
+			_, msgs := c.History()
+			assert.Empty(t, msgs)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	llmtesting "github.com/bpowers/go-agent/llm/testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestOpenAI_RequestGolden(t *testing.T) {
+	t.Parallel()
+
+	// Responses API chats with tools send Chat Completions requests, which
+	// its golden file records
+	for name, tc := range map[string]struct {
+		api   API
+		model string
+		opts  []chat.Option
+	}{
+		"ChatCompletions": {ChatCompletions, "gpt-4o", []chat.Option{chat.WithTemperature(0.2), chat.WithMaxTokens(1024)}},
+		"Responses":       {Responses, "gpt-5", []chat.Option{chat.WithReasoningEffort("low"), chat.WithMaxTokens(1024)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient("https://api.openai.invalid/v1", "test-key", WithModel(tc.model), WithAPI(tc.api))
+			require.NoError(t, err)
+			llmtesting.TestRequestGolden(t, client, "testdata/"+name+".golden.json", *update, tc.opts...)
+		})
+	}
+}
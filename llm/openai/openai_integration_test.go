@@ -46,77 +46,35 @@ func getAPIKey() string {
 	return os.Getenv("OPENAI_API_KEY")
 }
 
-func TestOpenAIIntegration_Streaming(t *testing.T) {
+func TestOpenAIIntegration_Conformance(t *testing.T) {
 	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for streaming
-			llmtesting.TestStreaming(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_ToolCalling(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for tool calling
-			llmtesting.TestToolsSummarizesFile(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_ToolCallingWithContext(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
 
 	tests := []struct {
 		name string
 		api  API
+		opts []llmtesting.ConformanceOption
 	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
+		{"ChatCompletions", ChatCompletions, nil},
+		{"Responses", Responses, []llmtesting.ConformanceOption{
+			llmtesting.WithSkippedTests("Responses API doesn't support tools yet",
+				"ToolCallStreamEvents",
+				"ToolCallAndResultStreamEvents",
+				"EmptyToolResultsHandling",
+				"ToolWithOptionalFields",
+				"SystemReminderWithToolCalls",
+			),
+		}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for context-aware tool calling
-			llmtesting.TestWritesFile(t, client)
+			llmtesting.RunConformanceSuite(t, func(t *testing.T) chat.Client {
+				llmtesting.SkipIfNoAPIKey(t, provider)
+				client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
+				require.NoError(t, err, "Failed to create OpenAI client")
+				return client
+			}, tt.opts...)
 		})
 	}
 }
@@ -171,59 +129,6 @@ func TestOpenAIIntegration_TokenUsage(t *testing.T) {
 	}
 }
 
-func TestOpenAIIntegration_TokenUsageCumulative(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for cumulative token usage
-			llmtesting.TestTokenUsageCumulative(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_ToolCallStreamEvents(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		// Note: Responses API doesn't support tools yet
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for tool call stream events
-			llmtesting.TestToolCallStreamEvents(t, client)
-
-			// Test both tool call and result events
-			llmtesting.TestToolCallAndResultStreamEvents(t, client)
-		})
-	}
-}
-
 func TestOpenAIIntegration_ToolRegistration(t *testing.T) {
 	t.Parallel()
 	llmtesting.SkipIfNoAPIKey(t, provider)
@@ -282,80 +187,6 @@ func TestOpenAIIntegration_ToolRegistration(t *testing.T) {
 	}
 }
 
-func TestOpenAIIntegration_EmptyToolResultsHandling(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		// Note: Responses API doesn't support tools
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for empty tool results handling
-			llmtesting.TestEmptyToolResultsHandling(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_ToolWithOptionalFields(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		// Note: Responses API doesn't support tools
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			llmtesting.TestToolWithOptionalFields(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_SystemReminderWithToolCalls(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		// Note: Responses API doesn't support tools
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for system reminder with tool calls
-			llmtesting.TestSystemReminderWithToolCalls(t, client)
-		})
-	}
-}
-
 func TestOpenAIIntegration_MaxTokensByModel(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -386,76 +217,3 @@ func TestOpenAIIntegration_MaxTokensByModel(t *testing.T) {
 		})
 	}
 }
-
-func TestOpenAIIntegration_NoDuplicateMessages(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			// Use the test helper for checking duplicate messages
-			llmtesting.TestNoDuplicateMessages(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_MessagePersistenceAfterRestore(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			llmtesting.TestMessagePersistenceAfterRestore(t, client)
-		})
-	}
-}
-
-func TestOpenAIIntegration_TextBeforeToolCallsPreserved(t *testing.T) {
-	t.Parallel()
-	llmtesting.SkipIfNoAPIKey(t, provider)
-
-	tests := []struct {
-		name string
-		api  API
-	}{
-		{"ChatCompletions", ChatCompletions},
-		{"Responses", Responses},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			client, err := NewClient(OpenAIURL, getAPIKey(), WithModel(getTestModel()), WithAPI(tt.api))
-			require.NoError(t, err, "Failed to create OpenAI client")
-			require.NotNil(t, client)
-
-			llmtesting.TestTextBeforeToolCallsPreserved(t, client)
-		})
-	}
-}
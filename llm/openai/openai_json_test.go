@@ -0,0 +1,76 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_WithJSONMode(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name   string
+		api    API
+		stream string
+		format func(body map[string]any) any
+	}{
+		{
+			name: "ChatCompletions",
+			api:  ChatCompletions,
+			stream: "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"{}\"},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: [DONE]\n\n",
+			format: func(body map[string]any) any {
+				return body["response_format"]
+			},
+		},
+		{
+			name: "Responses",
+			api:  Responses,
+			stream: "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"item_id\":\"m1\",\"output_index\":0,\"content_index\":0,\"delta\":\"{}\",\"sequence_number\":1}\n\n" +
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"sequence_number\":2,\"response\":{\"id\":\"r1\",\"object\":\"response\",\"created_at\":1,\"status\":\"completed\",\"model\":\"gpt-4o\",\"output\":[],\"usage\":{\"input_tokens\":5,\"output_tokens\":1,\"total_tokens\":6,\"input_tokens_details\":{\"cached_tokens\":0},\"output_tokens_details\":{\"reasoning_tokens\":0}}}}\n\n",
+			format: func(body map[string]any) any {
+				text, _ := body["text"].(map[string]any)
+				return text["format"]
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var formats []any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				var body map[string]any
+				require.NoError(t, json.Unmarshal(data, &body))
+				formats = append(formats, tt.format(body))
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.stream)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(tt.api))
+			require.NoError(t, err)
+
+			c := client.NewChat("")
+			ctx := context.Background()
+			_, err = c.Message(ctx, chat.UserMessage("Hello"), chat.WithJSONMode())
+			require.NoError(t, err)
+			_, err = c.Message(ctx, chat.UserMessage("Hello again"))
+			require.NoError(t, err)
+
+			assert.Equal(t, []any{map[string]any{"type": "json_object"}, nil}, formats)
+		})
+	}
+}
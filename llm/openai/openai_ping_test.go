@@ -0,0 +1,55 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_Ping(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/models/gpt-4o" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"The model does not exist","type":"invalid_request_error","code":"model_not_found"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"gpt-4o","object":"model","created":1715367049,"owned_by":"system"}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"))
+	require.NoError(t, err)
+	status, err := client.(chat.Pinger).Ping(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Healthy())
+	assert.Equal(t, "openai", status.Provider)
+	assert.Equal(t, http.StatusOK, status.StatusCode)
+
+	client, err = NewClient(server.URL, "test-key", WithModel("gpt-nonexistent"))
+	require.NoError(t, err)
+	requests.Store(0)
+	status, err = client.(chat.Pinger).Ping(context.Background())
+	assert.ErrorContains(t, err, "model_not_found")
+	assert.Equal(t, chat.HealthModelNotFound, status.State)
+	assert.False(t, status.Retryable())
+	assert.Equal(t, int32(1), requests.Load(), "failed checks aren't retried")
+
+	// Nothing listening
+	server.Close()
+	status, err = client.(chat.Pinger).Ping(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, chat.HealthUnreachable, status.State)
+}
@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_WithPrediction(t *testing.T) {
+	t.Parallel()
+
+	type prediction struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	}
+	newServer := func(paths *[]string, predictions *[]*prediction) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var req struct {
+				Prediction *prediction `json:"prediction"`
+			}
+			require.NoError(t, json.Unmarshal(body, &req))
+			*paths = append(*paths, r.URL.Path)
+			*predictions = append(*predictions, req.Prediction)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x := 2\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+	}
+
+	for name, api := range map[string]API{"ChatCompletions": ChatCompletions, "Responses": Responses} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var paths []string
+			var predictions []*prediction
+			server := newServer(&paths, &predictions)
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(api))
+			require.NoError(t, err)
+
+			resp, err := client.NewChat("").Message(context.Background(), chat.UserMessage("Change x to 2"),
+				chat.WithPrediction("x := 1"))
+			require.NoError(t, err)
+			assert.Equal(t, "x := 2", resp.GetText())
+
+			assert.Equal(t, []string{"/chat/completions"}, paths)
+			assert.Equal(t, []*prediction{{Type: "content", Content: "x := 1"}}, predictions)
+		})
+	}
+
+	t.Run("ignored with tools", func(t *testing.T) {
+		t.Parallel()
+
+		var paths []string
+		var predictions []*prediction
+		server := newServer(&paths, &predictions)
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"))
+		require.NoError(t, err)
+
+		c := client.NewChat("")
+		require.NoError(t, c.RegisterTool(&testTool{
+			name:       "lookup",
+			jsonSchema: `{"name":"lookup","description":"Looks things up","inputSchema":{"type":"object","properties":{}}}`,
+		}))
+		_, err = c.Message(context.Background(), chat.UserMessage("Change x to 2"), chat.WithPrediction("x := 1"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/chat/completions"}, paths)
+		assert.Equal(t, []*prediction{nil}, predictions)
+	})
+}
@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestOpenAI_WithUser(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name   string
+		api    API
+		stream string
+	}{
+		{
+			name: "ChatCompletions",
+			api:  ChatCompletions,
+			stream: "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Done\"},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: [DONE]\n\n",
+		},
+		{
+			name: "Responses",
+			api:  Responses,
+			stream: "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"item_id\":\"m1\",\"output_index\":0,\"content_index\":0,\"delta\":\"Done\",\"sequence_number\":1}\n\n" +
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"sequence_number\":2,\"response\":{\"id\":\"r1\",\"object\":\"response\",\"created_at\":1,\"status\":\"completed\",\"model\":\"gpt-4o\",\"output\":[],\"usage\":{\"input_tokens\":5,\"output_tokens\":1,\"total_tokens\":6,\"input_tokens_details\":{\"cached_tokens\":0},\"output_tokens_details\":{\"reasoning_tokens\":0}}}}\n\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var users []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				var req struct {
+					User string `json:"user"`
+				}
+				require.NoError(t, json.Unmarshal(body, &req))
+				users = append(users, req.User)
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.stream)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "test-key", WithModel("gpt-4o"), WithAPI(tt.api))
+			require.NoError(t, err)
+
+			c := client.NewChat("")
+			ctx := context.Background()
+			_, err = c.Message(ctx, chat.UserMessage("Hello"), chat.WithUser("user-1234"))
+			require.NoError(t, err)
+			_, err = c.Message(ctx, chat.UserMessage("Hello again"))
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"user-1234", ""}, users)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// strictUnsupported are the JSON Schema keywords OpenAI's strict mode
+// rejects.
+var strictUnsupported = []string{
+	"allOf", "not", "if", "then", "else", "dependentRequired", "dependentSchemas",
+	"patternProperties", "unevaluatedProperties", "propertyNames", "minProperties", "maxProperties",
+	"minLength", "maxLength",
+	"unevaluatedItems", "contains", "minContains", "maxContains", "uniqueItems",
+}
+
+// strictMaxDepth is how deeply strict mode allows schemas to nest.
+const strictMaxDepth = 10
+
+// strictSchema returns a copy of a tool's input schema adjusted to OpenAI's
+// strict mode: objects disallow additional properties, and optional
+// properties become required but nullable, so the model sends null to
+// leave them out. Definitions in $defs (or definitions) are adjusted the
+// same way, and optional references to them become nullable with anyOf.
+// Problems that can't be adjusted, such as map types or
+// unsupported keywords, are returned together, each with the path of the
+// schema that has it. A missing schema becomes an object without
+// properties.
+func strictSchema(s map[string]any) (map[string]any, error) {
+	if len(s) == 0 {
+		// A tool without arguments
+		return map[string]any{"type": "object", "properties": map[string]any{}, "required": []any{}, "additionalProperties": false}, nil
+	}
+	var problems []string
+	out := strictNode(s, "$", 1, &problems)
+	if !hasSchemaType(s, "object") {
+		problems = append([]string{"$: the input schema must be an object"}, problems...)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return out, nil
+}
+
+func strictNode(s map[string]any, path string, depth int, problems *[]string) map[string]any {
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	out := maps.Clone(s)
+	if depth > strictMaxDepth {
+		fail("schemas can't nest more than %d levels deep", strictMaxDepth)
+		return out
+	}
+	for _, keyword := range strictUnsupported {
+		if _, ok := s[keyword]; ok {
+			fail("%q isn't supported", keyword)
+		}
+	}
+
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		variants := make([]any, len(anyOf))
+		for i, v := range anyOf {
+			variants[i] = v
+			if variant, ok := v.(map[string]any); ok {
+				variants[i] = strictNode(variant, fmt.Sprintf("%s.anyOf[%d]", path, i), depth+1, problems)
+			}
+		}
+		out["anyOf"] = variants
+	} else if _, ok := s["type"]; !ok {
+		if _, ok := s["$ref"]; !ok {
+			fail("a schema without a type, which allows any value, isn't supported")
+		}
+	}
+
+	for _, keyword := range []string{"$defs", "definitions"} {
+		defs, ok := s[keyword].(map[string]any)
+		if !ok {
+			continue
+		}
+		outDefs := make(map[string]any, len(defs))
+		for _, name := range slices.Sorted(maps.Keys(defs)) {
+			def, ok := defs[name].(map[string]any)
+			if !ok {
+				fail("definition %q isn't a schema", name)
+				continue
+			}
+			outDefs[name] = strictNode(def, path+"."+keyword+"."+name, depth+1, problems)
+		}
+		out[keyword] = outDefs
+	}
+
+	if hasSchemaType(s, "object") {
+		switch additional := s["additionalProperties"].(type) {
+		case nil:
+			out["additionalProperties"] = false
+		case bool:
+			if additional {
+				fail("additionalProperties must be false; maps aren't supported")
+			}
+		default:
+			fail("additionalProperties must be false; maps aren't supported")
+		}
+
+		props, _ := s["properties"].(map[string]any)
+		required := make(map[string]bool)
+		var requiredNames []any
+		if names, ok := s["required"].([]any); ok {
+			for _, name := range names {
+				if name, ok := name.(string); ok && !required[name] {
+					required[name] = true
+					requiredNames = append(requiredNames, name)
+				}
+			}
+		}
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		outProps := make(map[string]any, len(props))
+		for _, name := range names {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				fail("property %q isn't a schema", name)
+				continue
+			}
+			converted := strictNode(prop, path+"."+name, depth+1, problems)
+			if !required[name] {
+				converted = nullable(converted)
+				requiredNames = append(requiredNames, name)
+			}
+			outProps[name] = converted
+		}
+		out["properties"] = outProps
+		if requiredNames == nil {
+			requiredNames = []any{}
+		}
+		out["required"] = requiredNames
+	}
+
+	if items, ok := s["items"].(map[string]any); ok {
+		out["items"] = strictNode(items, path+"[]", depth+1, problems)
+	}
+
+	return out
+}
+
+// nullable returns s, also allowing null.
+func nullable(s map[string]any) map[string]any {
+	switch t := s["type"].(type) {
+	case string:
+		if t != "null" {
+			s["type"] = []any{t, "null"}
+		}
+	case []any:
+		if !slices.Contains(t, any("null")) {
+			s["type"] = append(slices.Clip(t), "null")
+		}
+	default:
+		if ref, ok := s["$ref"]; ok {
+			// The referenced schema is shared, so null is allowed
+			// alongside the reference rather than in it
+			delete(s, "$ref")
+			s["anyOf"] = []any{map[string]any{"$ref": ref}, map[string]any{"type": "null"}}
+		} else if anyOf, ok := s["anyOf"].([]any); ok && !slices.ContainsFunc(anyOf, isNullSchema) {
+			s["anyOf"] = append(slices.Clip(anyOf), map[string]any{"type": "null"})
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, nil) {
+		s["enum"] = append(slices.Clip(enum), nil)
+	}
+	return s
+}
+
+func isNullSchema(v any) bool {
+	s, ok := v.(map[string]any)
+	return ok && s["type"] == "null"
+}
+
+// hasSchemaType reports whether s allows values of JSON type name.
+func hasSchemaType(s map[string]any, name string) bool {
+	switch t := s["type"].(type) {
+	case string:
+		return t == name
+	case []any:
+		return slices.Contains(t, any(name))
+	}
+	return false
+}
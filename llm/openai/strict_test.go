@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestStrictSchema(t *testing.T) {
+	t.Parallel()
+
+	parse := func(s string) map[string]any {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(s), &m))
+		return m
+	}
+
+	got, err := strictSchema(parse(`{
+		"type": "object",
+		"properties": {
+			"path": {"type": "string"},
+			"limit": {"type": "integer"},
+			"mode": {"type": "string", "enum": ["fast", "slow"]},
+			"note": {"type": ["string", "null"]},
+			"options": {"anyOf": [{"type": "object", "properties": {"depth": {"type": "integer"}}}, {"type": "null"}]},
+			"tags": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}}
+		},
+		"required": ["path", "tags"]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, parse(`{
+		"type": "object",
+		"properties": {
+			"path": {"type": "string"},
+			"limit": {"type": ["integer", "null"]},
+			"mode": {"type": ["string", "null"], "enum": ["fast", "slow", null]},
+			"note": {"type": ["string", "null"]},
+			"options": {"anyOf": [{"type": "object", "properties": {"depth": {"type": ["integer", "null"]}}, "required": ["depth"], "additionalProperties": false}, {"type": "null"}]},
+			"tags": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"], "additionalProperties": false}}
+		},
+		"required": ["path", "tags", "limit", "mode", "note", "options"],
+		"additionalProperties": false
+	}`), got)
+
+	got, err = strictSchema(nil)
+	require.NoError(t, err)
+	assert.Equal(t, parse(`{"type": "object", "properties": {}, "required": [], "additionalProperties": false}`), got)
+
+	_, err = strictSchema(parse(`{
+		"type": "object",
+		"properties": {
+			"labels": {"type": "object", "additionalProperties": true},
+			"code": {"type": "string", "maxLength": 8},
+			"raw": {}
+		}
+	}`))
+	require.Error(t, err)
+	assert.Equal(t, `$.code: "maxLength" isn't supported; `+
+		`$.labels: additionalProperties must be false; maps aren't supported; `+
+		`$.raw: a schema without a type, which allows any value, isn't supported`, err.Error())
+
+	_, err = strictSchema(parse(`{"type": "string"}`))
+	assert.ErrorContains(t, err, "$: the input schema must be an object")
+
+	// Definitions are adjusted, and optional references made nullable
+	got, err = strictSchema(parse(`{
+		"type": "object",
+		"properties": {
+			"from": {"$ref": "#/$defs/Point"},
+			"to": {"$ref": "#/$defs/Point", "description": "Where to go"}
+		},
+		"required": ["from"],
+		"$defs": {
+			"Point": {"type": "object", "properties": {"x": {"type": "number"}, "y": {"type": "number"}, "label": {"type": "string"}}, "required": ["x", "y"]}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, parse(`{
+		"type": "object",
+		"properties": {
+			"from": {"$ref": "#/$defs/Point"},
+			"to": {"anyOf": [{"$ref": "#/$defs/Point"}, {"type": "null"}], "description": "Where to go"}
+		},
+		"required": ["from", "to"],
+		"additionalProperties": false,
+		"$defs": {
+			"Point": {"type": "object", "properties": {"x": {"type": "number"}, "y": {"type": "number"}, "label": {"type": ["string", "null"]}}, "required": ["x", "y", "label"], "additionalProperties": false}
+		}
+	}`), got)
+
+	_, err = strictSchema(parse(`{"type": "object", "properties": {}, "definitions": {"Labels": {"type": "object", "additionalProperties": true}}}`))
+	assert.EqualError(t, err, "$.definitions.Labels: additionalProperties must be false; maps aren't supported")
+}
+
+func TestOpenAI_WithStrictTools(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient("http://localhost", "test-key", WithModel("gpt-4o"), WithStrictTools())
+	require.NoError(t, err)
+	c := client.NewChat("")
+
+	err = c.RegisterTool(&testTool{
+		name:       "label",
+		jsonSchema: `{"name":"label","inputSchema":{"type":"object","properties":{"labels":{"type":"object","additionalProperties":true}}}}`,
+	})
+	assert.ErrorContains(t, err, `tool "label" isn't compatible with strict mode: $.labels: additionalProperties must be false`)
+	assert.Empty(t, c.ListTools())
+
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:        "lookup",
+		description: "Looks things up",
+		jsonSchema:  `{"name":"lookup","inputSchema":{"type":"object","properties":{"q":{"type":"string"},"limit":{"type":"integer"}},"required":["q"]}}`,
+	}))
+	req, err := chat.DryRun(context.Background(), c, chat.UserMessage("Find it"))
+	require.NoError(t, err)
+
+	var body struct {
+		Tools []struct {
+			Function struct {
+				Name       string         `json:"name"`
+				Strict     bool           `json:"strict"`
+				Parameters map[string]any `json:"parameters"`
+			} `json:"function"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(req.Body, &body))
+	require.Len(t, body.Tools, 1)
+	fn := body.Tools[0].Function
+	assert.Equal(t, "lookup", fn.Name)
+	assert.True(t, fn.Strict)
+	assert.Equal(t, false, fn.Parameters["additionalProperties"])
+	assert.Equal(t, []any{"q", "limit"}, fn.Parameters["required"])
+
+	// Without the option, tools are sent as they're defined
+	client, err = NewClient("http://localhost", "test-key", WithModel("gpt-4o"))
+	require.NoError(t, err)
+	c = client.NewChat("")
+	require.NoError(t, c.RegisterTool(&testTool{
+		name:       "label",
+		jsonSchema: `{"name":"label","inputSchema":{"type":"object","properties":{"labels":{"type":"object","additionalProperties":true}}}}`,
+	}))
+	req, err = chat.DryRun(context.Background(), c, chat.UserMessage("Label it"))
+	require.NoError(t, err)
+	body.Tools = nil
+	require.NoError(t, json.Unmarshal(req.Body, &body))
+	require.Len(t, body.Tools, 1)
+	assert.False(t, body.Tools[0].Function.Strict)
+	assert.NotContains(t, string(req.Body), `"strict"`)
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ProviderFactory creates a client for a provider registered with
+// RegisterProvider. config.Provider is the name it was registered under.
+type ProviderFactory func(config *Config) (chat.Client, error)
+
+var (
+	providersMu sync.Mutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes NewClient use factory when Config.Provider is
+// name, and lets config files and Open URLs name the provider. It is meant
+// to be called from a provider package's init function or early in main;
+// registering the same name twice, or a built-in provider's name, panics,
+// as with database/sql drivers.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("llm: RegisterProvider factory for %q is nil", name))
+	}
+	if _, builtin := providerNames[name]; builtin || name == "" {
+		panic(fmt.Sprintf("llm: RegisterProvider called with reserved name %q", name))
+	}
+	if _, dup := providers[name]; dup {
+		panic(fmt.Sprintf("llm: RegisterProvider called twice for provider %q", name))
+	}
+	providers[name] = factory
+}
+
+// Providers returns the names of the built-in and registered providers.
+func Providers() []string {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	names := slices.Collect(maps.Keys(providers))
+	for name := range providerNames {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func registeredProvider(name string) (ProviderFactory, bool) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	factory, ok := providers[name]
+	return factory, ok
+}
+
+// knownProvider reports whether name is a built-in or registered provider.
+func knownProvider(name string) bool {
+	if _, ok := providerNames[name]; ok {
+		return true
+	}
+	_, ok := registeredProvider(name)
+	return ok
+}
+
+// Open creates a client from a URL whose scheme names the provider and
+// whose remainder is the model, so a client can be chosen with a single
+// config string:
+//
+//	anthropic://claude-sonnet-4-5
+//	ollama://llama3.1:8b?base_url=http://gpu-box:11434/v1
+//	openai://gpt-4o?temperature=0.2&max_tokens=4096
+//
+// See ParseURL for the parameters the URL may set. API keys aren't accepted
+// in the URL, so they don't end up in logs; they come from the provider's
+// usual environment variable.
+func Open(rawURL string) (chat.Client, error) {
+	config, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(config)
+}
+
+// ParseURL parses an Open URL into a Config. The query may set base_url,
+// temperature, and max_tokens; as with Config's fields, temperature and
+// max_tokens are for the caller to pass to Message as options, since
+// NewClient doesn't apply them.
+func ParseURL(rawURL string) (*Config, error) {
+	provider, rest, ok := strings.Cut(rawURL, "://")
+	if !ok || provider == "" {
+		return nil, fmt.Errorf("invalid model URL %q: want provider://model", rawURL)
+	}
+	if !knownProvider(provider) {
+		return nil, fmt.Errorf("invalid model URL %q: unknown provider %q (known: %s)", rawURL, provider, strings.Join(Providers(), ", "))
+	}
+	// url.Parse would reject model names like "llama3.1:8b" as hosts
+	model, rawQuery, _ := strings.Cut(rest, "?")
+	if model == "" {
+		return nil, fmt.Errorf("invalid model URL %q: model is required", rawURL)
+	}
+
+	config := &Config{Provider: provider, Model: model, LogLevel: -1}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model URL %q: %w", rawURL, err)
+	}
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "base_url":
+			config.BaseURL = value
+		case "temperature":
+			config.Temperature, err = strconv.ParseFloat(value, 64)
+		case "max_tokens":
+			config.MaxTokens, err = strconv.Atoi(value)
+		default:
+			return nil, fmt.Errorf("invalid model URL %q: unknown parameter %q", rawURL, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid model URL %q: bad %s: %w", rawURL, key, err)
+		}
+	}
+	return config, nil
+}
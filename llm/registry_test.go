@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	var got *Config
+	RegisterProvider("registrytest", func(config *Config) (chat.Client, error) {
+		got = config
+		return nil, nil
+	})
+
+	assert.Contains(t, Providers(), "registrytest")
+	assert.Contains(t, Providers(), "anthropic")
+
+	_, err := NewClient(&Config{Provider: "registrytest", Model: "m1", LogLevel: -1})
+	require.NoError(t, err)
+	assert.Equal(t, "m1", got.Model)
+
+	_, err = Open("registrytest://org/model:8b?base_url=http://localhost:8080/v1")
+	require.NoError(t, err)
+	assert.Equal(t, "registrytest", got.Provider)
+	assert.Equal(t, "org/model:8b", got.Model)
+	assert.Equal(t, "http://localhost:8080/v1", got.BaseURL)
+
+	// Config files accept registered providers
+	filename := writeConfig(t, "config.yaml", `
+providers:
+  registrytest:
+    base_url: http://registry.test/v1
+aliases:
+  fast:
+    model: small
+    provider: registrytest
+`)
+	fileConfig, err := LoadConfig(filename)
+	require.NoError(t, err)
+	config := &Config{Model: "fast"}
+	require.NoError(t, fileConfig.Apply(config))
+	assert.Equal(t, "http://registry.test/v1", config.BaseURL)
+
+	assert.Panics(t, func() {
+		RegisterProvider("registrytest", func(config *Config) (chat.Client, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterProvider("openai", func(config *Config) (chat.Client, error) { return nil, nil })
+	})
+	assert.Panics(t, func() { RegisterProvider("other", nil) })
+}
+
+func TestParseURL(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseURL("ollama://llama3.1:8b?temperature=0.2&max_tokens=4096")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Provider: "ollama", Model: "llama3.1:8b", Temperature: 0.2, MaxTokens: 4096, LogLevel: -1}, config)
+
+	for _, rawURL := range []string{
+		"claude-sonnet-4-5",
+		"://model",
+		"nope://model",
+		"anthropic://",
+		"anthropic://claude?api_key=secret",
+		"openai://gpt-4o?temperature=hot",
+		"openai://gpt-4o?max_tokens=%zz",
+	} {
+		_, err := ParseURL(rawURL)
+		assert.Error(t, err, rawURL)
+	}
+}
@@ -0,0 +1,367 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Target is a concrete model that a route can send requests to.
+type Target struct {
+	Model    string `yaml:"model"`
+	Provider string `yaml:"provider"`
+}
+
+// Route maps a logical model name, like "smart" or "cheap", to concrete
+// models.
+type Route struct {
+	// Targets are tried in order. If creating a client for a target fails
+	// (e.g. its API key isn't set) or a request to it fails, the
+	// conversation continues on the next target.
+	Targets []Target
+	// Options are default request options for the route. Options passed to
+	// Message are applied after them, so they take precedence.
+	Options []chat.Option
+	// Escalation, if set, makes the first target a draft model that answers
+	// unless its response needs escalating to the other targets.
+	Escalation *Escalation
+}
+
+// Router maps logical model names to routes, so application code can ask
+// for "smart" and operators can change what that means.
+type Router struct {
+	mu          sync.Mutex
+	routes      map[string]Route
+	escalations map[string]*escalationCounter
+
+	// file supplies provider endpoints and credentials, if the router was
+	// created from a config file
+	file *FileConfig
+	// newClient creates the client for a single target
+	newClient func(config *Config) (chat.Client, error)
+}
+
+// NewRouter returns a router with no routes.
+func NewRouter() *Router {
+	return &Router{
+		routes:      make(map[string]Route),
+		escalations: make(map[string]*escalationCounter),
+		newClient:   NewClient,
+	}
+}
+
+// SetRoute adds or replaces the route for name.
+func (r *Router) SetRoute(name string, route Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[name] = route
+}
+
+// Route returns the route for name, if there is one.
+func (r *Router) Route(name string) (Route, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	route, ok := r.routes[name]
+	return route, ok
+}
+
+// EscalationStats returns how often requests to the named route, across all
+// of its clients, have been escalated from its draft model.
+func (r *Router) EscalationStats(name string) EscalationStats {
+	return r.escalationCounter(name).snapshot()
+}
+
+func (r *Router) escalationCounter(name string) *escalationCounter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter, ok := r.escalations[name]
+	if !ok {
+		counter = &escalationCounter{}
+		r.escalations[name] = counter
+	}
+	return counter
+}
+
+// NewClient creates a client for config.Model. If it names a route, the
+// client sends requests to the route's targets in fallback order; each
+// target uses config with Model and Provider replaced, so leave APIKey and
+// BaseURL empty for routes that span providers and configure them per
+// provider in a config file instead. Other models get a plain client.
+func (r *Router) NewClient(config *Config) (chat.Client, error) {
+	route, ok := r.Route(config.Model)
+	if !ok {
+		return r.targetClient(*config)
+	}
+	if len(route.Targets) == 0 {
+		return nil, fmt.Errorf("route %q has no targets", config.Model)
+	}
+
+	rc := &routedClient{
+		route:   config.Model,
+		options: slices.Clip(route.Options),
+	}
+	targets := route.Targets
+	if route.Escalation != nil {
+		if len(targets) < 2 {
+			return nil, fmt.Errorf("route %q needs a draft target and at least one escalation target", config.Model)
+		}
+		c := *config
+		c.Model, c.Provider = targets[0].Model, targets[0].Provider
+		if draft, err := r.targetClient(c); err != nil {
+			logger.Warn("draft model unusable, sending every request to escalation targets", "route", config.Model, "model", c.Model, "error", err)
+		} else {
+			rc.clients = append(rc.clients, draft)
+			rc.escalation = route.Escalation
+			rc.escalations = r.escalationCounter(config.Model)
+		}
+		targets = targets[1:]
+	}
+	var errs []error
+	for _, target := range targets {
+		c := *config
+		c.Model, c.Provider = target.Model, target.Provider
+		client, err := r.targetClient(c)
+		if err != nil {
+			logger.Warn("skipping route target", "route", config.Model, "model", target.Model, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", target.Model, err))
+			continue
+		}
+		rc.clients = append(rc.clients, client)
+	}
+	if len(rc.clients) == 0 || (rc.escalation != nil && len(rc.clients) == 1) {
+		return nil, fmt.Errorf("no usable targets for route %q: %w", config.Model, errors.Join(errs...))
+	}
+	return rc, nil
+}
+
+func (r *Router) targetClient(config Config) (chat.Client, error) {
+	if r.file != nil {
+		if err := r.file.Apply(&config); err != nil {
+			return nil, err
+		}
+	}
+	return r.newClient(&config)
+}
+
+// routedClient creates chats that fall back between a route's targets.
+type routedClient struct {
+	route   string
+	clients []chat.Client
+	options []chat.Option
+	// escalation, if set, makes clients[0] the draft model
+	escalation  *Escalation
+	escalations *escalationCounter
+}
+
+func (c *routedClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	return &routedChat{
+		client: c,
+		chat:   c.clients[0].NewChat(systemPrompt, initialMsgs...),
+	}
+}
+
+// routedChat delegates to a chat on the current target, moving the
+// conversation to the next target when a request fails.
+type routedChat struct {
+	client *routedClient
+
+	mu sync.Mutex
+	// target is the index in client.clients of the current chat's client
+	target int
+	chat   chat.Chat
+	tools  []chat.Tool
+	// discarded is the usage of requests for the last message made by chats
+	// that were replaced, such as an escalated draft
+	discarded []chat.TokenUsageDetails
+}
+
+func (c *routedChat) current() (chat.Chat, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.chat, c.target
+}
+
+// Message sends msg to the current target. If the request fails for a reason
+// other than ctx being done or the chat being busy, the exchange is retried
+// from the start on the next target, which continues the conversation from
+// the history before msg. Token usage is reported by the current target, so
+// it restarts after a fallback. Routes with an Escalation send msg to the
+// draft model first.
+func (c *routedChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	opts = append(c.client.options, opts...)
+
+	if c.client.escalation != nil {
+		return c.draftMessage(ctx, msg, opts)
+	}
+	current, target := c.current()
+	return c.fallbackMessage(ctx, msg, opts, current, target)
+}
+
+// fallbackMessage sends msg to current, a chat on target, falling back to
+// later targets if it fails.
+func (c *routedChat) fallbackMessage(ctx context.Context, msg chat.Message, opts []chat.Option, current chat.Chat, target int) (chat.Message, error) {
+	for {
+		_, history := current.History()
+		resp, err := current.Message(ctx, msg, opts...)
+		if err == nil || ctx.Err() != nil || errors.Is(err, chat.ErrBusy) || isDryRun(err) || target+1 >= len(c.client.clients) {
+			return resp, err
+		}
+		logger.WarnContext(ctx, "route target failed, falling back", "route", c.client.route, "target", target, "error", err)
+		current, target = c.fallback(current, target, history)
+	}
+}
+
+// isDryRun reports whether err is the request of a chat.WithDryRun message,
+// which isn't a failure to fall back or escalate from.
+func isDryRun(err error) bool {
+	var req *chat.DryRunRequest
+	return errors.As(err, &req)
+}
+
+// fallback replaces the failed chat with one on the next target, seeded with
+// history. If another call already moved past failed, its chat is used.
+func (c *routedChat) fallback(failed chat.Chat, target int, history []chat.Message) (chat.Chat, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.chat != failed {
+		return c.chat, c.target
+	}
+
+	systemPrompt, _ := failed.History()
+	c.chat, c.target = c.newTargetChatLocked(target+1, systemPrompt, history), target+1
+	return c.chat, c.target
+}
+
+// newTargetChat returns a chat on target that continues the conversation
+// from history, with the chat's tools registered.
+func (c *routedChat) newTargetChat(target int, systemPrompt string, history []chat.Message) chat.Chat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.newTargetChatLocked(target, systemPrompt, history)
+}
+
+// newTargetChatLocked is newTargetChat for callers holding c.mu.
+func (c *routedChat) newTargetChatLocked(target int, systemPrompt string, history []chat.Message) chat.Chat {
+	next := c.client.clients[target].NewChat(systemPrompt, history...)
+	for _, tool := range c.tools {
+		if err := next.RegisterTool(tool); err != nil {
+			logger.Warn("failed to register tool on route target", "route", c.client.route, "target", target, "tool", tool.Name(), "error", err)
+		}
+	}
+	return next
+}
+
+func (c *routedChat) History() (string, []chat.Message) {
+	current, _ := c.current()
+	return current.History()
+}
+
+// TokenUsage reports the current target's usage, plus the usage of requests
+// for the last message made by replaced chats, such as an escalated draft.
+func (c *routedChat) TokenUsage() (chat.TokenUsage, error) {
+	c.mu.Lock()
+	current, discarded := c.chat, c.discarded
+	c.mu.Unlock()
+
+	usage, err := current.TokenUsage()
+	if err != nil || len(discarded) == 0 {
+		return usage, err
+	}
+	usage.Rounds = append(slices.Clone(discarded), usageRounds(usage)...)
+	for _, round := range discarded {
+		usage.Cumulative.InputTokens += round.InputTokens
+		usage.Cumulative.OutputTokens += round.OutputTokens
+		usage.Cumulative.TotalTokens += round.TotalTokens
+	}
+	return usage, nil
+}
+
+func (c *routedChat) MaxTokens() int {
+	current, _ := c.current()
+	return current.MaxTokens()
+}
+
+// EstimateRequest implements chat.RequestEstimator, estimating the request to
+// the current target.
+func (c *routedChat) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	current, _ := c.current()
+	estimator, ok := current.(chat.RequestEstimator)
+	if !ok {
+		return chat.RequestEstimate{}, fmt.Errorf("estimating requests isn't supported by %T", current)
+	}
+	return estimator.EstimateRequest(ctx, msg, append(c.client.options, opts...)...)
+}
+
+// Model implements chat.ModelReporter, reporting the current target's model.
+func (c *routedChat) Model() string {
+	current, _ := c.current()
+	if m, ok := current.(chat.ModelReporter); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+// LastRequests implements chat.RequestReporter, reporting the current
+// target's requests.
+func (c *routedChat) LastRequests() []chat.RequestInfo {
+	current, _ := c.current()
+	if r, ok := current.(chat.RequestReporter); ok {
+		return r.LastRequests()
+	}
+	return nil
+}
+
+// SetSystemPrompt implements chat.SystemPromptSetter, if the current
+// target does.
+func (c *routedChat) SetSystemPrompt(ctx context.Context, prompt string) error {
+	current, _ := c.current()
+	setter, ok := current.(chat.SystemPromptSetter)
+	if !ok {
+		return fmt.Errorf("setting the system prompt isn't supported by %T", current)
+	}
+	return setter.SetSystemPrompt(ctx, prompt)
+}
+
+func (c *routedChat) RegisterTool(tool chat.Tool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.chat.RegisterTool(tool); err != nil {
+		return err
+	}
+	c.tools = slices.DeleteFunc(c.tools, func(t chat.Tool) bool { return t.Name() == tool.Name() })
+	c.tools = append(c.tools, tool)
+	return nil
+}
+
+func (c *routedChat) DeregisterTool(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.chat.DeregisterTool(name)
+	c.tools = slices.DeleteFunc(c.tools, func(t chat.Tool) bool { return t.Name() == name })
+}
+
+func (c *routedChat) ListTools() []string {
+	c.mu.Lock()
+	current := c.chat
+	registered := slices.ContainsFunc(c.tools, func(t chat.Tool) bool { return t.Name() == EscalateToolName })
+	c.mu.Unlock()
+
+	names := current.ListTools()
+	if c.client.escalation != nil && c.client.escalation.Tool && !registered {
+		// Hide the escalate tool of a draft chat, which isn't the caller's
+		names = slices.DeleteFunc(names, func(name string) bool { return name == EscalateToolName })
+	}
+	return names
+}
@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// stubChat answers with its model name, or fails with err.
+type stubChat struct {
+	model   string
+	err     error
+	initial []chat.Message
+	history []chat.Message
+	tools   []string
+	opts    chat.Options
+}
+
+func (c *stubChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.opts = chat.ApplyOptions(opts...)
+	if c.opts.DryRun {
+		return chat.Message{}, &chat.DryRunRequest{URL: c.model}
+	}
+	if c.err != nil {
+		return chat.Message{}, c.err
+	}
+	reply := chat.AssistantMessage(c.model)
+	c.history = append(c.history, msg, reply)
+	return reply, nil
+}
+
+func (c *stubChat) History() (string, []chat.Message) {
+	return "system", append(append([]chat.Message(nil), c.initial...), c.history...)
+}
+func (c *stubChat) TokenUsage() (chat.TokenUsage, error)                     { return chat.TokenUsage{}, nil }
+func (c *stubChat) MaxTokens() int                                           { return 1000 }
+func (c *stubChat) SetSystemPrompt(ctx context.Context, prompt string) error { return nil }
+func (c *stubChat) RegisterTool(tool chat.Tool) error {
+	c.tools = append(c.tools, tool.Name())
+	return nil
+}
+func (c *stubChat) DeregisterTool(name string) {}
+func (c *stubChat) ListTools() []string        { return c.tools }
+func (c *stubChat) Model() string              { return c.model }
+
+type stubTool string
+
+func (t stubTool) Name() string                                  { return string(t) }
+func (t stubTool) Description() string                           { return "" }
+func (t stubTool) MCPJsonSchema() string                         { return `{"type":"object"}` }
+func (t stubTool) Call(ctx context.Context, input string) string { return "" }
+
+type stubClient struct {
+	model string
+	err   error
+	chats []*stubChat
+}
+
+func (c *stubClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	sc := &stubChat{model: c.model, err: c.err, initial: initialMsgs}
+	c.chats = append(c.chats, sc)
+	return sc
+}
+
+// stubRouter returns a router whose targets are stub clients, which fail if
+// their model is in failing.
+func stubRouter(failing ...string) (*Router, map[string]*stubClient) {
+	clients := make(map[string]*stubClient)
+	r := NewRouter()
+	r.newClient = func(config *Config) (chat.Client, error) {
+		if config.Model == "missing-key" {
+			return nil, errors.New("API key required")
+		}
+		c := &stubClient{model: config.Model}
+		for _, f := range failing {
+			if f == config.Model {
+				c.err = errors.New("overloaded")
+			}
+		}
+		clients[config.Model] = c
+		return c, nil
+	}
+	return r, clients
+}
+
+func TestRouterFallback(t *testing.T) {
+	t.Parallel()
+
+	r, clients := stubRouter("primary")
+	r.SetRoute("smart", Route{
+		Targets: []Target{{Model: "primary"}, {Model: "secondary"}},
+		Options: []chat.Option{chat.WithReasoningEffort("high"), chat.WithMaxTokens(100)},
+	})
+
+	client, err := r.NewClient(&Config{Model: "smart"})
+	require.NoError(t, err)
+	c := client.NewChat("system", chat.UserMessage("earlier"))
+	tool := stubTool("search")
+	require.NoError(t, c.RegisterTool(tool))
+
+	resp, err := c.Message(context.Background(), chat.UserMessage("hi"), chat.WithMaxTokens(50))
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", resp.GetText())
+
+	// The fallback continues the conversation with the same tools, and
+	// request options override the route's
+	secondary := clients["secondary"].chats[0]
+	assert.Equal(t, []chat.Message{chat.UserMessage("earlier")}, secondary.initial)
+	assert.Equal(t, []string{"search"}, secondary.tools)
+	assert.Equal(t, "high", secondary.opts.ReasoningEffort)
+	assert.Equal(t, 50, secondary.opts.MaxTokens)
+
+	_, history := c.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, []string{"search"}, c.ListTools())
+	assert.Equal(t, "secondary", c.(chat.ModelReporter).Model())
+
+	// Later messages stay on the fallback
+	_, err = c.Message(context.Background(), chat.UserMessage("again"))
+	require.NoError(t, err)
+	assert.Len(t, clients["primary"].chats, 1)
+	assert.Len(t, clients["secondary"].chats, 1)
+}
+
+func TestRouterAllTargetsFail(t *testing.T) {
+	t.Parallel()
+
+	r, _ := stubRouter("primary", "secondary")
+	r.SetRoute("smart", Route{Targets: []Target{{Model: "primary"}, {Model: "secondary"}}})
+
+	client, err := r.NewClient(&Config{Model: "smart"})
+	require.NoError(t, err)
+	_, err = client.NewChat("system").Message(context.Background(), chat.UserMessage("hi"))
+	assert.EqualError(t, err, "overloaded")
+}
+
+func TestRouterNoFallbackWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	r, clients := stubRouter("primary")
+	r.SetRoute("smart", Route{Targets: []Target{{Model: "primary"}, {Model: "secondary"}}})
+
+	client, err := r.NewClient(&Config{Model: "smart"})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.NewChat("system").Message(ctx, chat.UserMessage("hi"))
+	assert.EqualError(t, err, "overloaded")
+	assert.Empty(t, clients["secondary"].chats)
+}
+
+func TestRouterSkipsUnusableTargets(t *testing.T) {
+	t.Parallel()
+
+	r, _ := stubRouter()
+	r.SetRoute("cheap", Route{Targets: []Target{{Model: "missing-key"}, {Model: "backup"}}})
+	r.SetRoute("broken", Route{Targets: []Target{{Model: "missing-key"}}})
+	r.SetRoute("empty", Route{})
+
+	client, err := r.NewClient(&Config{Model: "cheap"})
+	require.NoError(t, err)
+	resp, err := client.NewChat("system").Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "backup", resp.GetText())
+
+	_, err = r.NewClient(&Config{Model: "broken"})
+	assert.ErrorContains(t, err, `no usable targets for route "broken": missing-key: API key required`)
+
+	_, err = r.NewClient(&Config{Model: "empty"})
+	assert.EqualError(t, err, `route "empty" has no targets`)
+
+	// Models without a route get a plain client
+	client, err = r.NewClient(&Config{Model: "gpt-5"})
+	require.NoError(t, err)
+	resp, err = client.NewChat("system").Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-5", resp.GetText())
+}
+
+func TestFileConfigRouter(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, "agents.yaml", `
+aliases:
+  smart:
+    model: claude-sonnet-4-5
+    fallback: [{model: qwen3, provider: ollama}]
+    options: {temperature: 0.7, reasoning_effort: high}
+providers:
+  ollama:
+    base_url: http://gpu-box:11434/v1
+defaults:
+  temperature: 0.2
+  max_tokens: 4096
+`))
+	require.NoError(t, err)
+
+	defaults := config.ModelDefaults("smart")
+	require.NotNil(t, defaults.Temperature)
+	assert.Equal(t, 0.7, *defaults.Temperature)
+	assert.Equal(t, 4096, defaults.MaxTokens)
+	assert.Equal(t, "high", defaults.ReasoningEffort)
+	assert.Equal(t, config.Defaults, config.ModelDefaults("gpt-5"))
+
+	r := config.Router()
+	route, ok := r.Route("smart")
+	require.True(t, ok)
+	assert.Equal(t, []Target{{Model: "claude-sonnet-4-5"}, {Model: "qwen3", Provider: "ollama"}}, route.Targets)
+	opts := chat.ApplyOptions(route.Options...)
+	assert.Equal(t, "high", opts.ReasoningEffort)
+
+	var configs []Config
+	r.newClient = func(c *Config) (chat.Client, error) {
+		configs = append(configs, *c)
+		return &stubClient{model: c.Model}, nil
+	}
+	_, err = r.NewClient(&Config{Model: "smart", APIKey: "key"})
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "claude-sonnet-4-5", configs[0].Model)
+	assert.Equal(t, "ollama", configs[1].Provider)
+	assert.Equal(t, "http://gpu-box:11434/v1", configs[1].BaseURL)
+
+	_, err = LoadConfig(writeConfig(t, "bad.yaml", "aliases: {smart: {model: x, fallback: [{provider: openai}]}}"))
+	assert.ErrorContains(t, err, `alias "smart": fallback model is required`)
+	_, err = LoadConfig(writeConfig(t, "bad.yaml", "aliases: {smart: {model: x, options: {system_prompt: hi}}}"))
+	assert.ErrorContains(t, err, "system_prompt isn't supported")
+}
+
+func TestRouterDryRunDoesNotFallBack(t *testing.T) {
+	t.Parallel()
+
+	r, _ := stubRouter()
+	r.SetRoute("smart", Route{Targets: []Target{{Model: "primary"}, {Model: "secondary"}}})
+
+	client, err := r.NewClient(&Config{Model: "smart"})
+	require.NoError(t, err)
+	c := client.NewChat("system")
+
+	req, err := chat.DryRun(context.Background(), c, chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "primary", req.URL)
+	assert.Equal(t, "primary", c.(chat.ModelReporter).Model())
+}
@@ -0,0 +1,26 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// SessionTurn returns the messages added to history by the i-th turn of a
+// tool-using session, for benchmarks of history handling: a question, a
+// tool call and its result, and the answer.
+func SessionTurn(i int) []chat.Message {
+	id := fmt.Sprintf("call_%d", i)
+	toolCall := chat.Message{Role: chat.AssistantRole}
+	toolCall.AddToolCall(chat.ToolCall{ID: id, Name: "read_file", Arguments: json.RawMessage(`{"path":"main.go"}`)})
+	toolResult := chat.Message{Role: chat.ToolRole}
+	toolResult.AddToolResult(chat.ToolResult{ToolCallID: id, Name: "read_file", Content: strings.Repeat("package main\n", 50)})
+	return []chat.Message{
+		chat.UserMessage(fmt.Sprintf("Question %d: what does main.go do?", i)),
+		toolCall,
+		toolResult,
+		chat.AssistantMessage("It prints a greeting."),
+	}
+}
@@ -0,0 +1,92 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// compactionFiller is a paragraph of background text, repeated to make the
+// long messages that fill a session's context window.
+const compactionFiller = `The warehouse inventory system tracks pallets from receiving through put-away, picking and shipping. Each pallet carries a barcode that is scanned at every hand-off, and the scans are reconciled nightly against the purchase orders and shipping manifests. Discrepancies are flagged for a cycle count the next morning, and counts that still disagree are escalated to the shift supervisor. `
+
+// compactionFillerRepeats is how many times the filler paragraph is repeated
+// in each long message, about 1500 tokens.
+const compactionFillerRepeats = 20
+
+// TestCompaction drives a session on client past its compaction threshold
+// with scripted long messages, then checks that compaction shrank the live
+// context, marked the older records as compacted with a summary in their
+// place, and kept a fact stated before compaction available to later turns.
+// Records are saved in store.
+func TestCompaction(t *testing.T, client chat.Client, store persistence.Store) {
+	ctx := context.Background()
+
+	session, err := agent.NewSession(client, "You are a helpful assistant. Keep your answers brief.", agent.WithStore(store))
+	require.NoError(t, err)
+
+	_, err = session.Message(ctx, chat.UserMessage("Please remember this for later: my project's codename is BLUE HERON. Reply with just OK."))
+	require.NoError(t, err)
+
+	// Compact once the context holds a few more long messages, rather than
+	// at 80% of a context window that may hold hundreds of thousands of
+	// tokens
+	metrics := session.Metrics()
+	require.Positive(t, metrics.LiveTokens)
+	require.Positive(t, metrics.MaxTokens)
+	threshold := float64(metrics.LiveTokens+3000) / float64(metrics.MaxTokens)
+	session.SetCompactionThreshold(threshold)
+
+	filler := strings.Repeat(compactionFiller, compactionFillerRepeats)
+	for i := 1; session.Metrics().PercentFull < threshold; i++ {
+		require.LessOrEqual(t, i, 10, "context never reached the compaction threshold")
+		msg := fmt.Sprintf("Here are warehouse notes, part %d. No need to summarize them; reply with just OK.\n\n%s", i, filler)
+		_, err := session.Message(ctx, chat.UserMessage(msg))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 0, session.Metrics().CompactionCount)
+	liveBefore := session.Metrics().LiveTokens
+
+	// The next message compacts the context before it is sent
+	resp, err := session.Message(ctx, chat.UserMessage("What is my project's codename?"))
+	require.NoError(t, err)
+	assert.Contains(t, strings.ToLower(resp.GetText()), "heron")
+
+	metrics = session.Metrics()
+	assert.Equal(t, 1, metrics.CompactionCount)
+	assert.Less(t, metrics.LiveTokens, liveBefore)
+
+	transcript, err := session.Transcript()
+	require.NoError(t, err)
+	var compacted, summaries int
+	for _, e := range transcript.Entries {
+		if e.Compacted {
+			compacted++
+			assert.False(t, e.Live)
+		}
+		if e.Summary {
+			summaries++
+			assert.True(t, e.Live)
+		}
+	}
+	assert.Equal(t, 1, summaries)
+	assert.Positive(t, compacted)
+	require.NotEmpty(t, transcript.Entries)
+	assert.Contains(t, transcript.Entries[0].GetText(), "BLUE HERON")
+	assert.True(t, transcript.Entries[0].Compacted)
+
+	// Compacted records stay in the store
+	records, err := store.GetAllRecords(session.SessionID())
+	require.NoError(t, err)
+	live, err := store.GetLiveRecords(session.SessionID())
+	require.NoError(t, err)
+	assert.Less(t, len(live), len(records))
+}
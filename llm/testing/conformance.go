@@ -0,0 +1,120 @@
+package testing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// ClientFactory returns the client for one test of the conformance suite.
+// It can skip the test, such as when an API key isn't set.
+type ClientFactory func(t *testing.T) chat.Client
+
+// ConformanceOption configures RunConformanceSuite.
+type ConformanceOption func(*conformanceConfig)
+
+type conformanceConfig struct {
+	thinking ClientFactory
+	// skip maps the names of tests to skip to the reason why
+	skip map[string]string
+}
+
+// WithThinkingClient runs the suite's thinking tests with clients from
+// factory, which should use a model with thinking enabled. Without it,
+// the thinking tests are skipped.
+func WithThinkingClient(factory ClientFactory) ConformanceOption {
+	return func(c *conformanceConfig) {
+		c.thinking = factory
+	}
+}
+
+// WithSkippedTests skips the suite's tests with the given names, such as
+// "ToolCallStreamEvents", for clients known not to support what they test,
+// reporting reason. Naming a test that isn't in the suite fails it.
+func WithSkippedTests(reason string, names ...string) ConformanceOption {
+	return func(c *conformanceConfig) {
+		if c.skip == nil {
+			c.skip = make(map[string]string)
+		}
+		for _, name := range names {
+			c.skip[name] = reason
+		}
+	}
+}
+
+// conformanceTest is one of the tests RunConformanceSuite runs.
+type conformanceTest struct {
+	name     string
+	thinking bool
+	run      func(t *testing.T, client chat.Client)
+}
+
+// RunConformanceSuite runs the behavioral tests every provider built on this
+// package must pass against clients from factory, each as a parallel
+// subtest of t: streaming, tool calling, token usage, persistence and
+// compaction through sessions, and thinking. Tests of configuration, like
+// TestBaseURLConfiguration, take provider-specific expectations and aren't
+// included.
+//
+// The tests call the provider's API, so factory should skip them when
+// credentials aren't available:
+//
+//	func TestConformance(t *testing.T) {
+//		llmtesting.RunConformanceSuite(t, func(t *testing.T) chat.Client {
+//			llmtesting.SkipIfNoAPIKey(t, "openai")
+//			client, err := openai.NewClient(openai.OpenAIURL, os.Getenv("OPENAI_API_KEY"), openai.WithModel("gpt-4o-mini"))
+//			require.NoError(t, err)
+//			return client
+//		})
+//	}
+func RunConformanceSuite(t *testing.T, factory ClientFactory, opts ...ConformanceOption) {
+	var config conformanceConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	tests := []conformanceTest{
+		{name: "Streaming", run: func(t *testing.T, client chat.Client) { TestStreaming(t, client) }},
+		{name: "TokenUsageCumulative", run: func(t *testing.T, client chat.Client) { TestTokenUsageCumulative(t, client) }},
+		{name: "ToolCallStreamEvents", run: func(t *testing.T, client chat.Client) { TestToolCallStreamEvents(t, client) }},
+		{name: "ToolCallAndResultStreamEvents", run: func(t *testing.T, client chat.Client) { TestToolCallAndResultStreamEvents(t, client) }},
+		{name: "EmptyToolResultsHandling", run: func(t *testing.T, client chat.Client) { TestEmptyToolResultsHandling(t, client) }},
+		{name: "SystemReminderWithToolCalls", run: func(t *testing.T, client chat.Client) { TestSystemReminderWithToolCalls(t, client) }},
+		{name: "TextBeforeToolCallsPreserved", run: TestTextBeforeToolCallsPreserved},
+		{name: "ToolWithOptionalFields", run: func(t *testing.T, client chat.Client) { TestToolWithOptionalFields(t, client) }},
+		{name: "ToolsSummarizesFile", run: func(t *testing.T, client chat.Client) { TestToolsSummarizesFile(t, client) }},
+		{name: "WritesFile", run: func(t *testing.T, client chat.Client) { TestWritesFile(t, client) }},
+		{name: "NoDuplicateMessages", run: TestNoDuplicateMessages},
+		{name: "MessagePersistenceAfterRestore", run: TestMessagePersistenceAfterRestore},
+		{name: "Compaction", run: func(t *testing.T, client chat.Client) { TestCompaction(t, client, persistence.NewMemoryStore()) }},
+		{name: "ThinkingPreservedInHistory", thinking: true, run: TestThinkingPreservedInHistory},
+		{name: "ThinkingPreservedWithToolCalls", thinking: true, run: TestThinkingPreservedWithToolCalls},
+	}
+
+	for name := range config.skip {
+		if !slices.ContainsFunc(tests, func(tt conformanceTest) bool { return tt.name == name }) {
+			t.Fatalf("WithSkippedTests: no conformance test named %q", name)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if reason, ok := config.skip[tt.name]; ok {
+				t.Skip(reason)
+			}
+
+			newClient := factory
+			if tt.thinking {
+				if config.thinking == nil {
+					t.Skip("no thinking client configured; see WithThinkingClient")
+				}
+				newClient = config.thinking
+			}
+			tt.run(t, newClient(t))
+		})
+	}
+}
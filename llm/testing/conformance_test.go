@@ -0,0 +1,147 @@
+package testing
+
+import (
+	"context"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// factoryRecorder is a ClientFactory that records which suite tests asked
+// for a client and then skips them, as a provider's factory does when its
+// API key isn't set.
+type factoryRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *factoryRecorder) factory(t *testing.T) chat.Client {
+	r.mu.Lock()
+	r.names = append(r.names, path.Base(t.Name()))
+	r.mu.Unlock()
+	t.Skip("recorded")
+	return nil
+}
+
+func (r *factoryRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := append([]string(nil), r.names...)
+	sort.Strings(names)
+	return names
+}
+
+// streamingClient is a fake chat.Client whose chats answer every message
+// with the same text, streamed a word at a time, which is enough for
+// TestStreaming.
+type streamingClient struct{}
+
+func (streamingClient) NewChat(string, ...chat.Message) chat.Chat {
+	return &streamingChat{}
+}
+
+type streamingChat struct {
+	mu   sync.Mutex
+	msgs []chat.Message
+}
+
+const streamingReply = "System dynamics models a strategy as stocks and flows.\n\n" +
+	"Feedback loops drive the behavior of the model over time.\n\n" +
+	"Simulating the model tests a strategy before committing to it."
+
+func (c *streamingChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cb := chat.ApplyOptions(opts...).StreamingCb; cb != nil {
+		for _, word := range strings.SplitAfter(streamingReply, " ") {
+			if err := cb(chat.StreamEvent{Type: chat.StreamEventTypeContent, Content: word}); err != nil {
+				return chat.Message{}, err
+			}
+		}
+	}
+	reply := chat.AssistantMessage(streamingReply)
+	c.msgs = append(c.msgs, msg, reply)
+	return reply, nil
+}
+
+func (c *streamingChat) History() (string, []chat.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return "", append([]chat.Message(nil), c.msgs...)
+}
+
+func (c *streamingChat) TokenUsage() (chat.TokenUsage, error) { return chat.TokenUsage{}, nil }
+func (c *streamingChat) MaxTokens() int                       { return 0 }
+func (c *streamingChat) RegisterTool(chat.Tool) error         { return nil }
+func (c *streamingChat) DeregisterTool(string)                {}
+func (c *streamingChat) ListTools() []string                  { return nil }
+
+func TestRunConformanceSuite(t *testing.T) {
+	nonThinking := []string{
+		"Compaction",
+		"EmptyToolResultsHandling",
+		"MessagePersistenceAfterRestore",
+		"NoDuplicateMessages",
+		"Streaming",
+		"SystemReminderWithToolCalls",
+		"TextBeforeToolCallsPreserved",
+		"TokenUsageCumulative",
+		"ToolCallAndResultStreamEvents",
+		"ToolCallStreamEvents",
+		"ToolWithOptionalFields",
+		"ToolsSummarizesFile",
+		"WritesFile",
+	}
+	thinking := []string{
+		"ThinkingPreservedInHistory",
+		"ThinkingPreservedWithToolCalls",
+	}
+
+	t.Run("WithoutThinkingClient", func(t *testing.T) {
+		var base factoryRecorder
+		// The suite's subtests are parallel, so they finish only when this
+		// enclosing subtest does.
+		t.Run("suite", func(t *testing.T) {
+			RunConformanceSuite(t, base.factory)
+		})
+		assert.Equal(t, nonThinking, base.recorded())
+	})
+
+	t.Run("WithThinkingClient", func(t *testing.T) {
+		var base, thinker factoryRecorder
+		t.Run("suite", func(t *testing.T) {
+			RunConformanceSuite(t, base.factory, WithThinkingClient(thinker.factory))
+		})
+		assert.Equal(t, nonThinking, base.recorded())
+		assert.Equal(t, thinking, thinker.recorded())
+	})
+	t.Run("WithSkippedTests", func(t *testing.T) {
+		var base factoryRecorder
+		t.Run("suite", func(t *testing.T) {
+			RunConformanceSuite(t, base.factory, WithSkippedTests("unsupported", "Streaming", "WritesFile"))
+		})
+		want := slices.DeleteFunc(slices.Clone(nonThinking), func(name string) bool {
+			return name == "Streaming" || name == "WritesFile"
+		})
+		assert.Equal(t, want, base.recorded())
+	})
+}
+
+func TestRunConformanceSuite_FakeClient(t *testing.T) {
+	// Only Streaming can pass against a fake; the other tests check what a
+	// real model does with tools and sessions.
+	RunConformanceSuite(t, func(t *testing.T) chat.Client {
+		if path.Base(t.Name()) != "Streaming" {
+			t.Skip("needs a real model")
+		}
+		return streamingClient{}
+	})
+}
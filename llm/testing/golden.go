@@ -0,0 +1,86 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// goldenTool is the tool registered for golden requests. Its schema is
+// written out rather than generated, so generator changes don't show up as
+// wire changes.
+type goldenTool struct{}
+
+func (goldenTool) Name() string        { return "get_weather" }
+func (goldenTool) Description() string { return "Gets the current weather in a city" }
+func (goldenTool) MCPJsonSchema() string {
+	return `{"name": "get_weather", "description": "Gets the current weather in a city", "inputSchema": {"type": "object", "properties": {"city": {"type": "string", "description": "The city's name"}, "units": {"type": "string", "enum": ["celsius", "fahrenheit"]}}, "required": ["city"], "additionalProperties": false}}`
+}
+func (goldenTool) Call(ctx context.Context, input string) string { return `{"conditions": "rain"}` }
+
+// CanonicalConversation returns the conversation golden requests are built
+// for: a system prompt, and a history with a system reminder, thinking
+// from Claude and Gemini, a tool call and its result, ahead of the
+// message sent. Each provider is expected to send back only its own
+// thinking.
+func CanonicalConversation() (systemPrompt string, history []chat.Message, msg chat.Message) {
+	first := chat.UserMessage("What's the weather in Paris?")
+	first.Contents = append(first.Contents, chat.Content{SystemReminder: "<system-reminder>The user is in Europe.</system-reminder>"})
+
+	call := chat.Message{Role: chat.AssistantRole, Contents: []chat.Content{
+		{Thinking: &chat.ThinkingContent{Text: "I should look up the weather.", Signature: "c2lnbmF0dXJl", Provider: "claude"}},
+		{Thinking: &chat.ThinkingContent{Text: "Check the weather tool.", Signature: "dGhvdWdodA==", Provider: "gemini"}},
+		{Text: "Let me check."},
+	}}
+	call.AddToolCall(chat.ToolCall{ID: "call_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris","units":"celsius"}`)})
+	result := chat.Message{Role: chat.ToolRole}
+	result.AddToolResult(chat.ToolResult{ToolCallID: "call_1", Name: "get_weather", Content: `{"conditions": "rain"}`})
+
+	return "You are a helpful assistant.",
+		[]chat.Message{first, call, result, chat.AssistantMessage("It's raining in Paris.")},
+		chat.UserMessage("Should I bring an umbrella tomorrow?")
+}
+
+// TestRequestGolden builds client's request for the canonical conversation
+// with a dry run, given opts and a system reminder in the context (which
+// some providers only send after tool calls), and compares its body,
+// indented, to the golden file at path. If update is set, it writes the
+// file instead. Providers keep their golden files in testdata and set
+// update with an -update flag, so changes to how requests are built show
+// up in review:
+//
+//	go test ./llm/claude -run Golden -update
+func TestRequestGolden(t *testing.T, client chat.Client, path string, update bool, opts ...chat.Option) {
+	t.Helper()
+
+	systemPrompt, history, msg := CanonicalConversation()
+	c := client.NewChat(systemPrompt, history...)
+	require.NoError(t, c.RegisterTool(goldenTool{}))
+
+	ctx := chat.WithSystemReminder(context.Background(), func() string {
+		return "<system-reminder>Keep answers short.</system-reminder>"
+	})
+	req, err := chat.DryRun(ctx, c, msg, opts...)
+	require.NoError(t, err)
+
+	var got bytes.Buffer
+	require.NoError(t, json.Indent(&got, req.Body, "", "  "))
+	got.WriteByte('\n')
+
+	if update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got.Bytes(), 0o644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run the test with -update to create it")
+	assert.Equal(t, string(want), got.String(), "the request body changed; if that's intended, run the test with -update")
+}
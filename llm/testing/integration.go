@@ -284,6 +284,7 @@ func TestToolCallStreamEvents(t testing.TB, client chat.Client) {
 	// Track events during streaming
 	var toolCallEvents []chat.StreamEvent
 	var contentEvents []chat.StreamEvent
+	var roundEvents []chat.StreamEvent
 	streamedContent := strings.Builder{}
 
 	_, err = chatSession.Message(
@@ -297,6 +298,8 @@ func TestToolCallStreamEvents(t testing.TB, client chat.Client) {
 			case chat.StreamEventTypeContent:
 				contentEvents = append(contentEvents, event)
 				streamedContent.WriteString(event.Content)
+			case chat.StreamEventTypeRoundStart, chat.StreamEventTypeRoundEnd:
+				roundEvents = append(roundEvents, event)
 			}
 			return nil
 		}),
@@ -305,6 +308,23 @@ func TestToolCallStreamEvents(t testing.TB, client chat.Client) {
 		t.Fatalf("Failed to get streaming response: %v", err)
 	}
 
+	// Rounds should be reported as matched start/end pairs, ending with a complete round
+	if len(roundEvents) < 4 || len(roundEvents)%2 != 0 {
+		t.Errorf("Expected start and end events for at least 2 rounds, got %d events", len(roundEvents))
+	}
+	for i, event := range roundEvents {
+		wantType := chat.StreamEventTypeRoundStart
+		if i%2 == 1 {
+			wantType = chat.StreamEventTypeRoundEnd
+		}
+		if event.Type != wantType || event.Round == nil || event.Round.Index != i/2 {
+			t.Errorf("Unexpected round event %d: %+v", i, event)
+		}
+	}
+	if n := len(roundEvents); n > 0 && roundEvents[n-1].Round != nil && roundEvents[n-1].Round.Reason != chat.RoundReasonComplete {
+		t.Errorf("Expected last round to end with reason %q, got %q", chat.RoundReasonComplete, roundEvents[n-1].Round.Reason)
+	}
+
 	// Verify tool was actually called
 	if !toolCalled {
 		t.Error("Expected tool to be called, but it wasn't")
@@ -338,6 +358,26 @@ func TestToolCallStreamEvents(t testing.TB, client chat.Client) {
 		t.Error("Expected non-empty streamed content")
 	}
 
+	// Every request made while handling the tool call should have its usage recorded
+	usage, err := chatSession.TokenUsage()
+	if err != nil {
+		t.Fatalf("Failed to get token usage: %v", err)
+	}
+	if len(usage.Rounds) < 2 {
+		t.Errorf("Expected usage for at least 2 rounds (tool call and follow-up), got %d", len(usage.Rounds))
+	}
+	roundsTotal := 0
+	for i, round := range usage.Rounds {
+		if round.InputTokens <= 0 || round.OutputTokens <= 0 {
+			t.Errorf("Expected positive input and output tokens for round %d, got %+v", i, round)
+		}
+		roundsTotal += round.TotalTokens
+	}
+	// This was the first message, so the rounds account for all usage so far
+	if roundsTotal != usage.Cumulative.TotalTokens {
+		t.Errorf("Expected rounds to sum to cumulative total tokens %d, got %d", usage.Cumulative.TotalTokens, roundsTotal)
+	}
+
 	t.Logf("Tool call streaming test passed: received %d tool call events and %d content events",
 		len(toolCallEvents), len(contentEvents))
 }
@@ -1082,17 +1122,14 @@ func TestMessagePersistenceAfterRestore(t *testing.T, client chat.Client) {
 		}
 		assert.Equal(t, 1, userRecordCount1, "First user message should appear exactly once")
 
-		// Restore session
-		restoredSession, err := agent.NewSession(client, systemPrompt,
-			agent.WithStore(store),
-			agent.WithRestoreSession(sessionID))
-		require.NoError(t, err)
-
-		// Re-register tool on restored session
+		// Restore session, with its tool
 		calcTool.callFn = func(ctx context.Context, input string) string {
 			return `{"result": 84}`
 		}
-		err = restoredSession.RegisterTool(calcTool)
+		restoredSession, err := agent.NewSession(client, systemPrompt,
+			agent.WithStore(store),
+			agent.WithRestoreSession(sessionID),
+			agent.WithTools(calcTool))
 		require.NoError(t, err)
 
 		// Send another message
@@ -0,0 +1,30 @@
+package llm
+
+import "github.com/bpowers/go-agent/llm/internal/common"
+
+// ToolArgumentStats counts how often a provider and model produced tool call
+// arguments that weren't valid JSON.
+type ToolArgumentStats struct {
+	Provider string
+	Model    string
+	// ToolCalls is the number of tool calls whose arguments were checked.
+	ToolCalls int64
+	// Malformed is the number of calls whose arguments were invalid JSON.
+	Malformed int64
+	// Repaired is the number of malformed arguments that were repaired before
+	// being passed to the tool. Repair is opt-in per client, e.g. with
+	// claude.WithToolArgumentRepair.
+	Repaired int64
+}
+
+// ToolArgumentMetrics returns per-provider and per-model counts of malformed
+// and repaired tool call arguments, accumulated over the life of the process.
+// Gemini decodes tool arguments in its SDK, so it isn't included.
+func ToolArgumentMetrics() []ToolArgumentStats {
+	stats := common.ToolArgumentMetrics()
+	result := make([]ToolArgumentStats, len(stats))
+	for i, s := range stats {
+		result[i] = ToolArgumentStats(s)
+	}
+	return result
+}
@@ -0,0 +1,411 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"weak"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+var (
+	// ErrSessionNotFound is returned by Manager.Session when the session
+	// doesn't exist or belongs to another user.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTooManySessions is returned by Manager when a user already has the
+	// maximum number of active sessions.
+	ErrTooManySessions = errors.New("too many active sessions")
+	// ErrQuotaExceeded is returned by a Manager's sessions when their user
+	// has used up their quota for the current window.
+	ErrQuotaExceeded = errors.New("usage quota exceeded")
+)
+
+// ClientFactory creates the chat client for a user's session, so that
+// different users can use different models or credentials.
+type ClientFactory func(userID string) (chat.Client, error)
+
+// ManagerOption configures a Manager.
+type ManagerOption func(*Manager)
+
+// WithMaxSessionsPerUser limits how many sessions a user can have active
+// (cached in memory) at once. The default of 0 means no limit.
+func WithMaxSessionsPerUser(n int) ManagerOption {
+	return func(m *Manager) {
+		m.maxPerUser = n
+	}
+}
+
+// WithIdleTimeout evicts sessions that haven't been used for d. Evicted
+// sessions remain in the store and are restored when next requested. The
+// default of 0 means sessions stay active until Close is called.
+func WithIdleTimeout(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.idleTimeout = d
+	}
+}
+
+// WithClaimUnownedSessions lets users open sessions stored without an
+// owner, such as those created outside the Manager or before their store
+// tracked owners. The first user to open one becomes its owner. By default
+// they aren't found, as anyone who knows or guesses their IDs could take
+// them over.
+func WithClaimUnownedSessions() ManagerOption {
+	return func(m *Manager) {
+		m.claimUnowned = true
+	}
+}
+
+// Quota limits the tokens and cost a user can use across all their sessions
+// within a sliding time window. Zero limits are not enforced.
+type Quota struct {
+	Window    time.Duration
+	MaxTokens int
+	MaxCost   float64
+}
+
+// WithQuota enforces q for every user: once a user's usage within the last
+// q.Window reaches a limit, their messages fail with ErrQuotaExceeded. Costs
+// are only recorded if a CostFunc is set with WithSessionOptions(WithCostFunc(...)).
+func WithQuota(q Quota) ManagerOption {
+	return func(m *Manager) {
+		m.quota = q
+	}
+}
+
+// WithSessionOptions sets options for every session the manager creates,
+// such as WithSummarizer or WithDefaultOptions. The manager's store, session
+// IDs, and users take precedence over WithStore, WithRestoreSession, and
+// WithOwner. Sessions send their user's ID to the provider with chat.WithUser,
+// unless a Message call passes a different one.
+func WithSessionOptions(opts ...SessionOption) ManagerOption {
+	return func(m *Manager) {
+		m.sessionOpts = append(m.sessionOpts, opts...)
+	}
+}
+
+// Manager creates, caches, and expires the Sessions of a multi-user server,
+// so HTTP handlers don't each manage session lifecycle and locking. Sessions
+// it returns serialize their Message calls, so concurrent requests for the
+// same session take turns, and a session evicted or closed while a caller
+// still holds it is handed out again rather than restored twice. Sessions
+// are owned by the user that created them,
+// and their usage counts against that user's quota.
+type Manager struct {
+	store        persistence.Store
+	newClient    ClientFactory
+	systemPrompt string
+	sessionOpts  []SessionOption
+	maxPerUser   int
+	idleTimeout  time.Duration
+	quota        Quota
+	claimUnowned bool
+	now          func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*managedSession
+	// inactive are sessions evicted or closed that callers may still hold
+	inactive map[string]weak.Pointer[managedSession]
+}
+
+// NewManager returns a Manager that stores sessions in store and creates
+// their clients with newClient. New sessions start with systemPrompt.
+func NewManager(store persistence.Store, newClient ClientFactory, systemPrompt string, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		store:        store,
+		newClient:    newClient,
+		systemPrompt: systemPrompt,
+		now:          time.Now,
+		sessions:     make(map[string]*managedSession),
+		inactive:     make(map[string]weak.Pointer[managedSession]),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// NewSession creates a session for userID.
+func (m *Manager) NewSession(userID string) (Session, error) {
+	if err := m.checkLimit(userID); err != nil {
+		return nil, err
+	}
+	return m.open(userID, "")
+}
+
+// Session returns the session with the given ID, restoring it from the store
+// if it isn't active. It returns ErrSessionNotFound if the session belongs to
+// a different user, or has no owner and WithClaimUnownedSessions isn't set.
+func (m *Manager) Session(userID, sessionID string) (Session, error) {
+	m.mu.Lock()
+	m.evictIdleLocked()
+	if ms, err := m.lookupLocked(userID, sessionID); ms != nil || err != nil {
+		m.mu.Unlock()
+		return ms, err
+	}
+	m.mu.Unlock()
+
+	records, err := m.store.GetAllRecords(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	owner, err := m.store.GetOwner(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session owner: %w", err)
+	}
+	if owner != userID && (owner != "" || !m.claimUnowned) {
+		return nil, ErrSessionNotFound
+	}
+	if err := m.checkLimit(userID); err != nil {
+		return nil, err
+	}
+	return m.open(userID, sessionID)
+}
+
+// checkLimit returns ErrTooManySessions if userID is at the session limit.
+func (m *Manager) checkLimit(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.evictIdleLocked()
+	if m.maxPerUser > 0 && m.userSessionsLocked(userID) >= m.maxPerUser {
+		return ErrTooManySessions
+	}
+	return nil
+}
+
+// open creates (or, with a sessionID, restores) a session and makes it
+// active. Creating the client and loading the session happen without the
+// lock held, so one slow store or client doesn't block other users.
+func (m *Manager) open(userID, sessionID string) (Session, error) {
+	client, err := m.newClient(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	opts := append(m.sessionOpts[:len(m.sessionOpts):len(m.sessionOpts)], WithStore(m.store), WithOwner(userID), WithDefaultOptions(chat.WithUser(userID)),
+		// Checked once a message has its turn, so usage from earlier
+		// messages counts
+		withTurnCheck(func() error { return m.checkQuota(userID) }))
+	if sessionID != "" {
+		opts = append(opts, WithRestoreSession(sessionID))
+	}
+	session, err := NewSession(client, m.systemPrompt, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another request may have restored the same session meanwhile
+	if ms, err := m.lookupLocked(userID, session.SessionID()); ms != nil || err != nil {
+		return ms, err
+	}
+	if m.maxPerUser > 0 && m.userSessionsLocked(userID) >= m.maxPerUser {
+		return nil, ErrTooManySessions
+	}
+
+	ms := &managedSession{
+		Session:  session,
+		manager:  m,
+		userID:   userID,
+		lastUsed: m.now(),
+	}
+	m.sessions[session.SessionID()] = ms
+	return ms, nil
+}
+
+// lookupLocked returns userID's session with the given ID if it's active,
+// or reactivates it if it was deactivated while a caller still holds it, so
+// there is never more than one Session for an ID (m.mu must be held). It
+// returns nil and no error if the session has to be restored from the store.
+func (m *Manager) lookupLocked(userID, sessionID string) (*managedSession, error) {
+	ms, ok := m.sessions[sessionID]
+	if !ok {
+		ms = m.inactive[sessionID].Value()
+		if ms == nil {
+			delete(m.inactive, sessionID)
+			return nil, nil
+		}
+		if ms.userID != userID {
+			return nil, ErrSessionNotFound
+		}
+		if m.maxPerUser > 0 && m.userSessionsLocked(userID) >= m.maxPerUser {
+			return nil, ErrTooManySessions
+		}
+		delete(m.inactive, sessionID)
+		m.sessions[sessionID] = ms
+	}
+	if ms.userID != userID {
+		return nil, ErrSessionNotFound
+	}
+	ms.lastUsed = m.now()
+	return ms, nil
+}
+
+// deactivateLocked drops the manager's reference to ms, keeping a weak one
+// until callers are done with it (m.mu must be held).
+func (m *Manager) deactivateLocked(sessionID string, ms *managedSession) {
+	delete(m.sessions, sessionID)
+	wp := weak.Make(ms)
+	m.inactive[sessionID] = wp
+	runtime.AddCleanup(ms, m.forget, inactiveSession{sessionID, wp})
+}
+
+// inactiveSession identifies a deactivated session for forget.
+type inactiveSession struct {
+	id string
+	wp weak.Pointer[managedSession]
+}
+
+// forget removes a deactivated session once nothing holds it.
+func (m *Manager) forget(s inactiveSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inactive[s.id] == s.wp {
+		delete(m.inactive, s.id)
+	}
+}
+
+// Close deactivates the session with the given ID. It remains in the store.
+func (m *Manager) Close(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ms, ok := m.sessions[sessionID]; ok {
+		m.deactivateLocked(sessionID, ms)
+	}
+}
+
+// Usage returns userID's usage within the quota window, or over all time if
+// no quota window is set.
+func (m *Manager) Usage(userID string) (persistence.UsageTotals, error) {
+	var start time.Time
+	if m.quota.Window > 0 {
+		start = m.now().Add(-m.quota.Window)
+	}
+	return m.store.GetUsage(userID, start, time.Time{})
+}
+
+// checkQuota returns ErrQuotaExceeded if userID has reached a quota limit.
+func (m *Manager) checkQuota(userID string) error {
+	if m.quota.MaxTokens <= 0 && m.quota.MaxCost <= 0 {
+		return nil
+	}
+	usage, err := m.Usage(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load usage: %w", err)
+	}
+	if m.quota.MaxTokens > 0 && usage.TotalTokens() >= m.quota.MaxTokens {
+		return ErrQuotaExceeded
+	}
+	if m.quota.MaxCost > 0 && usage.Cost >= m.quota.MaxCost {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// ActiveSessions returns the number of active sessions for userID.
+func (m *Manager) ActiveSessions(userID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.evictIdleLocked()
+	return m.userSessionsLocked(userID)
+}
+
+// EvictIdle deactivates sessions idle for longer than the idle timeout, and
+// returns how many were evicted. Idle sessions are also evicted whenever the
+// manager is asked for a session, so calling EvictIdle periodically is only
+// needed to free memory on quiet servers.
+func (m *Manager) EvictIdle() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.evictIdleLocked()
+}
+
+// evictIdleLocked deactivates idle sessions (m.mu must be held). Sessions
+// with a message in progress are never evicted.
+func (m *Manager) evictIdleLocked() int {
+	if m.idleTimeout <= 0 {
+		return 0
+	}
+	evicted := 0
+	now := m.now()
+	for id, ms := range m.sessions {
+		if ms.inFlight == 0 && now.Sub(ms.lastUsed) >= m.idleTimeout {
+			m.deactivateLocked(id, ms)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// userSessionsLocked counts userID's active sessions (m.mu must be held).
+func (m *Manager) userSessionsLocked(userID string) int {
+	n := 0
+	for _, ms := range m.sessions {
+		if ms.userID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+// managedSession is a Session handed out by a Manager. It tracks use for
+// idle eviction; the Session serializes messages and checks the user's
+// quota once each has its turn.
+type managedSession struct {
+	Session
+	manager *Manager
+	userID  string
+
+	// lastUsed and inFlight are guarded by manager.mu
+	lastUsed time.Time
+	inFlight int
+}
+
+// inUse marks the session as in use until the returned done is called.
+func (ms *managedSession) inUse() (done func()) {
+	ms.markUsed(1)
+	return func() { ms.markUsed(-1) }
+}
+
+func (ms *managedSession) markUsed(delta int) {
+	ms.manager.mu.Lock()
+	defer ms.manager.mu.Unlock()
+
+	ms.inFlight += delta
+	ms.lastUsed = ms.manager.now()
+}
+
+func (ms *managedSession) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	done := ms.inUse()
+	defer done()
+
+	return ms.Session.Message(ctx, msg, opts...)
+}
+
+func (ms *managedSession) bestOf(ctx context.Context, msg chat.Message, n int, score Scorer, opts ...chat.Option) (chat.Message, error) {
+	done := ms.inUse()
+	defer done()
+
+	return BestOf(ctx, ms.Session, msg, n, score, opts...)
+}
+
+func (ms *managedSession) AmendLastUserMessage(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	done := ms.inUse()
+	defer done()
+
+	return ms.Session.AmendLastUserMessage(ctx, msg, opts...)
+}
@@ -0,0 +1,328 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func newTestManager(opts ...ManagerOption) (*Manager, *[]string) {
+	var users []string
+	m := NewManager(persistence.NewMemoryStore(), func(userID string) (chat.Client, error) {
+		users = append(users, userID)
+		return &mockClient{}, nil
+	}, "You are a helpful assistant", opts...)
+	return m, &users
+}
+
+func TestManagerSessions(t *testing.T) {
+	m, users := newTestManager()
+	ctx := context.Background()
+
+	session, err := m.NewSession("alice")
+	require.NoError(t, err)
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	// Active sessions are returned as-is
+	same, err := m.Session("alice", session.SessionID())
+	require.NoError(t, err)
+	assert.Same(t, session, same)
+	assert.Equal(t, []string{"alice"}, *users)
+
+	// Other users can't see it
+	_, err = m.Session("bob", session.SessionID())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	_, err = m.Session("alice", "missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	// Closed sessions still held are handed out again
+	sessionID := session.SessionID()
+	m.Close(sessionID)
+	assert.Equal(t, 0, m.ActiveSessions("alice"))
+	reopened, err := m.Session("alice", sessionID)
+	require.NoError(t, err)
+	assert.Same(t, session, reopened)
+	assert.Equal(t, 1, m.ActiveSessions("alice"))
+
+	// Once they aren't, they're restored from the store
+	m.Close(sessionID)
+	session, same, reopened = nil, nil, nil
+	runtime.GC()
+	restored, err := m.Session("alice", sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, sessionID, restored.SessionID())
+	assert.Len(t, restored.LiveRecords(), 3)
+	assert.Equal(t, "alice", restored.LiveRecords()[1].User)
+	assert.Equal(t, []string{"alice", "alice"}, *users)
+}
+
+func TestManagerMaxSessionsPerUser(t *testing.T) {
+	m, _ := newTestManager(WithMaxSessionsPerUser(2))
+
+	first, err := m.NewSession("alice")
+	require.NoError(t, err)
+	_, err = m.NewSession("alice")
+	require.NoError(t, err)
+	_, err = m.NewSession("alice")
+	assert.ErrorIs(t, err, ErrTooManySessions)
+
+	// The limit is per user
+	_, err = m.NewSession("bob")
+	require.NoError(t, err)
+
+	m.Close(first.SessionID())
+	_, err = m.NewSession("alice")
+	require.NoError(t, err)
+
+	// Restoring a session counts against the limit too
+	_, err = m.Session("alice", first.SessionID())
+	assert.ErrorIs(t, err, ErrTooManySessions)
+}
+
+func TestManagerIdleEviction(t *testing.T) {
+	m, _ := newTestManager(WithIdleTimeout(time.Minute))
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	idle, err := m.NewSession("alice")
+	require.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	busy, err := m.NewSession("alice")
+	require.NoError(t, err)
+
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, 1, m.EvictIdle())
+	assert.Equal(t, 1, m.ActiveSessions("alice"))
+
+	// Using a session keeps it active
+	_, err = busy.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, 0, m.EvictIdle())
+
+	// Evicted sessions are reactivated on demand, as the same session if
+	// it's still held, so its messages still take turns
+	restored, err := m.Session("alice", idle.SessionID())
+	require.NoError(t, err)
+	assert.Same(t, idle, restored)
+	assert.Equal(t, 2, m.ActiveSessions("alice"))
+}
+
+func TestManagerEvictionWhileHeld(t *testing.T) {
+	client := &blockingClient{release: make(chan struct{})}
+	m := NewManager(persistence.NewMemoryStore(), func(string) (chat.Client, error) {
+		return client, nil
+	}, "", WithIdleTimeout(time.Nanosecond))
+
+	held, err := m.NewSession("alice")
+	require.NoError(t, err)
+	require.Equal(t, 1, m.EvictIdle())
+
+	again, err := m.Session("alice", held.SessionID())
+	require.NoError(t, err)
+	require.Same(t, held, again)
+
+	var wg sync.WaitGroup
+	for _, s := range []Session{held, again} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Message(context.Background(), chat.UserMessage("Hello"))
+			assert.NoError(t, err)
+		}()
+	}
+	require.Eventually(t, func() bool { return client.maxActive() == 1 }, time.Second, time.Millisecond)
+	close(client.release)
+	wg.Wait()
+	assert.Equal(t, 1, client.maxActive())
+}
+
+// blockingChat blocks in Message until release is closed, recording the
+// maximum number of concurrent calls.
+type blockingChat struct {
+	mockChat
+	release chan struct{}
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (c *blockingChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.mu.Lock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.mu.Unlock()
+
+	<-c.release
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	return chat.AssistantMessage("done"), nil
+}
+
+type blockingClient struct {
+	release chan struct{}
+
+	mu    sync.Mutex
+	chats []*blockingChat
+}
+
+func (c *blockingClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bc := &blockingChat{release: c.release}
+	bc.systemPrompt = systemPrompt
+	bc.messages = append([]chat.Message{}, initialMsgs...)
+	bc.tools = make(map[string]func(context.Context, string) string)
+	c.chats = append(c.chats, bc)
+	return bc
+}
+
+func (c *blockingClient) maxActive() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, bc := range c.chats {
+		bc.mu.Lock()
+		n = max(n, bc.maxActive)
+		bc.mu.Unlock()
+	}
+	return n
+}
+
+func TestManagerSerializesMessages(t *testing.T) {
+	client := &blockingClient{release: make(chan struct{})}
+	m := NewManager(persistence.NewMemoryStore(), func(string) (chat.Client, error) {
+		return client, nil
+	}, "", WithIdleTimeout(time.Nanosecond))
+
+	session, err := m.NewSession("alice")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := session.Message(context.Background(), chat.UserMessage("Hello"))
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Sessions with messages in progress aren't evicted, however short the
+	// idle timeout
+	require.Eventually(t, func() bool { return client.maxActive() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, m.EvictIdle())
+
+	// A waiting message gives up when its context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(client.release)
+	wg.Wait()
+	assert.Equal(t, 1, client.maxActive())
+	assert.Equal(t, 1, m.EvictIdle())
+}
+
+func TestManagerOwnership(t *testing.T) {
+	m, _ := newTestManager()
+
+	session, err := m.NewSession("alice")
+	require.NoError(t, err)
+	m.Close(session.SessionID())
+
+	// Ownership is persisted, so it holds for sessions restored from the store
+	_, err = m.Session("bob", session.SessionID())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	restored, err := m.Session("alice", session.SessionID())
+	require.NoError(t, err)
+	assert.Equal(t, session.SessionID(), restored.SessionID())
+
+	// Sessions stored without an owner aren't anyone's
+	legacy, err := NewSession(&mockClient{}, "You are a helpful assistant", WithStore(m.store))
+	require.NoError(t, err)
+	_, err = m.Session("bob", legacy.SessionID())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestManagerClaimUnownedSessions(t *testing.T) {
+	m, _ := newTestManager(WithClaimUnownedSessions())
+
+	// Sessions stored without an owner are claimed by the first user
+	legacy, err := NewSession(&mockClient{}, "You are a helpful assistant", WithStore(m.store))
+	require.NoError(t, err)
+	_, err = m.Session("bob", legacy.SessionID())
+	require.NoError(t, err)
+	owner, err := m.store.GetOwner(legacy.SessionID())
+	require.NoError(t, err)
+	assert.Equal(t, "bob", owner)
+	_, err = m.Session("alice", legacy.SessionID())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestManagerQuota(t *testing.T) {
+	m, _ := newTestManager(WithQuota(Quota{Window: time.Hour}), WithSessionOptions(
+		WithCostFunc(func(usage chat.TokenUsageDetails) float64 {
+			return float64(usage.TotalTokens) / 100
+		}),
+	))
+	ctx := context.Background()
+
+	first, err := m.NewSession("alice")
+	require.NoError(t, err)
+	second, err := m.NewSession("alice")
+	require.NoError(t, err)
+	other, err := m.NewSession("bob")
+	require.NoError(t, err)
+
+	_, err = first.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	totals, err := m.Usage("alice")
+	require.NoError(t, err)
+	require.Positive(t, totals.TotalTokens())
+	assert.InDelta(t, float64(totals.TotalTokens())/100, totals.Cost, 1e-9)
+
+	// Usage is shared across a user's sessions
+	m.quota.MaxTokens = totals.TotalTokens() + 1
+	_, err = second.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	_, err = first.Message(ctx, chat.UserMessage("Hello"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// Other users have their own quota
+	_, err = other.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	// Usage ages out of the window
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = first.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+}
+
+func TestManagerClientError(t *testing.T) {
+	errNoKey := errors.New("no API key")
+	m := NewManager(persistence.NewMemoryStore(), func(string) (chat.Client, error) {
+		return nil, errNoKey
+	}, "")
+
+	_, err := m.NewSession("alice")
+	assert.ErrorIs(t, err, errNoKey)
+	assert.Equal(t, 0, m.ActiveSessions("alice"))
+}
@@ -78,10 +78,12 @@ func (r *Registry) Definitions() []ToolDefinition {
 
 func toolDefinition(tool chat.Tool) (ToolDefinition, error) {
 	var schema struct {
-		Name         string          `json:"name"`
-		Description  string          `json:"description"`
-		InputSchema  json.RawMessage `json:"inputSchema"`
-		OutputSchema json.RawMessage `json:"outputSchema"`
+		Name         string                `json:"name"`
+		Title        string                `json:"title"`
+		Description  string                `json:"description"`
+		InputSchema  json.RawMessage       `json:"inputSchema"`
+		OutputSchema json.RawMessage       `json:"outputSchema"`
+		Annotations  *chat.ToolAnnotations `json:"annotations"`
 	}
 
 	if err := json.Unmarshal([]byte(tool.MCPJsonSchema()), &schema); err != nil {
@@ -96,8 +98,10 @@ func toolDefinition(tool chat.Tool) (ToolDefinition, error) {
 
 	return ToolDefinition{
 		Name:         schema.Name,
+		Title:        schema.Title,
 		Description:  schema.Description,
 		InputSchema:  schema.InputSchema,
 		OutputSchema: schema.OutputSchema,
+		Annotations:  schema.Annotations,
 	}, nil
 }
@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,6 +25,25 @@ func TestRegistryRegisterList(t *testing.T) {
 	assert.NotEmpty(t, definitions[0].OutputSchema)
 }
 
+func TestRegistryAnnotations(t *testing.T) {
+	registry := NewRegistry()
+	tool := &stubTool{
+		name:   "DeleteFile",
+		schema: `{"name":"DeleteFile","title":"Delete file","inputSchema":{"type":"object"},"annotations":{"destructiveHint":true}}`,
+	}
+	require.NoError(t, registry.Register(tool))
+
+	definitions := registry.Definitions()
+	require.Len(t, definitions, 1)
+	assert.Equal(t, "Delete file", definitions[0].Title)
+	require.NotNil(t, definitions[0].Annotations)
+	assert.True(t, definitions[0].Annotations.Destructive())
+
+	data, err := json.Marshal(definitions[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"DeleteFile","title":"Delete file","inputSchema":{"type":"object"},"annotations":{"destructiveHint":true}}`, string(data))
+}
+
 func TestRegistryRegisterInvalidSchema(t *testing.T) {
 	registry := NewRegistry()
 	tool := &stubTool{
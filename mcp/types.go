@@ -41,7 +41,11 @@
 //   - notifications/initialized: Client ready notification (no response)
 package mcp
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/bpowers/go-agent/chat"
+)
 
 // ProtocolVersion is the MCP protocol version supported by this server.
 const ProtocolVersion = "2025-11-25"
@@ -84,10 +88,12 @@ type Implementation struct {
 // ToolDefinition describes a tool's interface as returned by tools/list.
 // InputSchema is required and must be a valid JSON Schema object.
 type ToolDefinition struct {
-	Name         string          `json:"name"`
-	Description  string          `json:"description,omitzero"`
-	InputSchema  json.RawMessage `json:"inputSchema"`
-	OutputSchema json.RawMessage `json:"outputSchema,omitzero"`
+	Name         string                `json:"name"`
+	Title        string                `json:"title,omitzero"`
+	Description  string                `json:"description,omitzero"`
+	InputSchema  json.RawMessage       `json:"inputSchema"`
+	OutputSchema json.RawMessage       `json:"outputSchema,omitzero"`
+	Annotations  *chat.ToolAnnotations `json:"annotations,omitzero"`
 }
 
 // ToolCapabilities describes the server's tool-related capabilities.
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ModerationAction is what a session does with content its moderator flags.
+type ModerationAction int
+
+const (
+	// ModerationBlock fails the Message call with a *ModerationError.
+	// Flagged user input is never sent to the LLM, and flagged responses are
+	// neither returned nor saved to the session.
+	ModerationBlock ModerationAction = iota
+	// ModerationFlag lets flagged content through, logging a warning and
+	// calling Moderation.OnFlagged.
+	ModerationFlag
+	// ModerationAnnotate lets flagged content through like ModerationFlag,
+	// and also saves the moderation result on the content's record (see
+	// persistence.Record.Moderation) for later review.
+	ModerationAnnotate
+)
+
+// ModerationStage identifies the content a moderation result is about.
+type ModerationStage string
+
+const (
+	// ModerationInput is the user's message, screened before it is sent.
+	ModerationInput ModerationStage = "input"
+	// ModerationOutput is the LLM's response, screened before it is returned.
+	ModerationOutput ModerationStage = "output"
+)
+
+// Moderation configures screening of a session's messages.
+type Moderation struct {
+	// Moderator screens the text of each user message and response, such as
+	// one returned by openai.NewModerator.
+	Moderator chat.Moderator
+	// Action is what to do with flagged content.
+	Action ModerationAction
+	// OnFlagged, if set, is called for all flagged content, whatever the
+	// action.
+	OnFlagged func(ctx context.Context, stage ModerationStage, result chat.ModerationResult)
+}
+
+// ModerationError is returned by Message when ModerationBlock blocks flagged
+// content.
+type ModerationError struct {
+	Stage  ModerationStage
+	Result chat.ModerationResult
+}
+
+func (e *ModerationError) Error() string {
+	if len(e.Result.Categories) == 0 {
+		return fmt.Sprintf("%s blocked by moderation", e.Stage)
+	}
+	return fmt.Sprintf("%s blocked by moderation: %s", e.Stage, strings.Join(e.Result.Categories, ", "))
+}
+
+// WithModeration screens the text of user messages before they are sent, and
+// of the LLM's final response before it is returned, with m.Moderator. Tool
+// calls and results aren't screened. Streamed responses reach the streaming
+// callback before they can be screened, so servers that stream should also
+// withhold or retract output when Message returns a *ModerationError.
+//
+// If the moderator fails, Message fails when the action is ModerationBlock,
+// and otherwise logs a warning and carries on unscreened.
+func WithModeration(m Moderation) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.moderation = &m
+	}
+}
+
+// moderate screens msg's text. It returns a *ModerationError if the content
+// should be blocked, and the moderation result if it should be saved on the
+// content's record.
+func (s *session) moderate(ctx context.Context, stage ModerationStage, msg chat.Message) (*chat.ModerationResult, error) {
+	m := s.moderation
+	if m == nil || m.Moderator == nil {
+		return nil, nil
+	}
+	text := msg.GetText()
+	if text == "" {
+		return nil, nil
+	}
+
+	result, err := m.Moderator.Moderate(ctx, text)
+	if err != nil {
+		if m.Action == ModerationBlock {
+			return nil, fmt.Errorf("%s moderation failed: %w", stage, err)
+		}
+		logger.WarnContext(ctx, "moderation failed", "stage", stage, "error", err)
+		return nil, nil
+	}
+	if !result.Flagged {
+		return nil, nil
+	}
+
+	if m.OnFlagged != nil {
+		m.OnFlagged(ctx, stage, result)
+	}
+	switch m.Action {
+	case ModerationBlock:
+		return nil, &ModerationError{Stage: stage, Result: result}
+	case ModerationAnnotate:
+		logger.WarnContext(ctx, "content flagged by moderation", "session", s.sessionID, "stage", stage, "categories", result.Categories)
+		return &result, nil
+	default:
+		logger.WarnContext(ctx, "content flagged by moderation", "session", s.sessionID, "stage", stage, "categories", result.Categories)
+		return nil, nil
+	}
+}
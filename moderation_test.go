@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// keywordModerator flags text containing "forbidden"; mockChat's responses
+// echo the user's message, so it flags both input and output.
+var keywordModerator = chat.ModeratorFunc(func(ctx context.Context, text string) (chat.ModerationResult, error) {
+	if strings.Contains(text, "forbidden") {
+		return chat.ModerationResult{Flagged: true, Categories: []string{"violence"}, Scores: map[string]float64{"violence": 0.9}}, nil
+	}
+	return chat.ModerationResult{Scores: map[string]float64{"violence": 0.01}}, nil
+})
+
+func messageCalls(client *mockClient) int {
+	n := 0
+	for _, c := range client.chats {
+		n += c.messageCalls
+	}
+	return n
+}
+
+func TestModerationBlock(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "", WithModeration(Moderation{Moderator: keywordModerator, Action: ModerationBlock}))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	assert.Len(t, session.LiveRecords(), 2)
+
+	// Flagged input is never sent
+	_, err = session.Message(ctx, chat.UserMessage("Something forbidden"))
+	var modErr *ModerationError
+	require.ErrorAs(t, err, &modErr)
+	assert.Equal(t, ModerationInput, modErr.Stage)
+	assert.Equal(t, []string{"violence"}, modErr.Result.Categories)
+	assert.Equal(t, "input blocked by moderation: violence", err.Error())
+	assert.Equal(t, 1, messageCalls(client))
+	assert.Len(t, session.LiveRecords(), 2)
+
+	// Flagged output is neither returned nor saved
+	outputOnly := chat.ModeratorFunc(func(ctx context.Context, text string) (chat.ModerationResult, error) {
+		return chat.ModerationResult{Flagged: strings.HasPrefix(text, "Response to:")}, nil
+	})
+	session, err = NewSession(client, "", WithModeration(Moderation{Moderator: outputOnly, Action: ModerationBlock}))
+	require.NoError(t, err)
+	response, err := session.Message(ctx, chat.UserMessage("Hello"))
+	require.ErrorAs(t, err, &modErr)
+	assert.Equal(t, ModerationOutput, modErr.Stage)
+	assert.Empty(t, response.Contents)
+	assert.Empty(t, session.LiveRecords())
+}
+
+func TestModerationFlagAndAnnotate(t *testing.T) {
+	for _, action := range []ModerationAction{ModerationFlag, ModerationAnnotate} {
+		client := &mockClient{}
+		var flagged []ModerationStage
+		session, err := NewSession(client, "", WithModeration(Moderation{
+			Moderator: keywordModerator,
+			Action:    action,
+			OnFlagged: func(ctx context.Context, stage ModerationStage, result chat.ModerationResult) {
+				assert.True(t, result.Flagged)
+				flagged = append(flagged, stage)
+			},
+		}))
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		_, err = session.Message(ctx, chat.UserMessage("Hello"))
+		require.NoError(t, err)
+		response, err := session.Message(ctx, chat.UserMessage("Something forbidden"))
+		require.NoError(t, err)
+		assert.Contains(t, response.GetText(), "forbidden")
+		assert.Equal(t, []ModerationStage{ModerationInput, ModerationOutput}, flagged)
+
+		records := session.LiveRecords()
+		require.Len(t, records, 4)
+		assert.Nil(t, records[0].Moderation)
+		assert.Nil(t, records[1].Moderation)
+		if action == ModerationFlag {
+			assert.Nil(t, records[2].Moderation)
+			assert.Nil(t, records[3].Moderation)
+		} else {
+			require.NotNil(t, records[2].Moderation)
+			assert.Equal(t, []string{"violence"}, records[2].Moderation.Categories)
+			require.NotNil(t, records[3].Moderation)
+			assert.Equal(t, 0.9, records[3].Moderation.Scores["violence"])
+		}
+	}
+}
+
+func TestModerationFailure(t *testing.T) {
+	errUnavailable := errors.New("moderation unavailable")
+	failing := chat.ModeratorFunc(func(ctx context.Context, text string) (chat.ModerationResult, error) {
+		return chat.ModerationResult{}, errUnavailable
+	})
+	ctx := context.Background()
+
+	// Blocking sessions fail closed
+	client := &mockClient{}
+	session, err := NewSession(client, "", WithModeration(Moderation{Moderator: failing, Action: ModerationBlock}))
+	require.NoError(t, err)
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Equal(t, 0, messageCalls(client))
+
+	// Others carry on unscreened
+	session, err = NewSession(client, "", WithModeration(Moderation{Moderator: failing, Action: ModerationFlag}))
+	require.NoError(t, err)
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+}
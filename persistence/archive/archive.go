@@ -0,0 +1,305 @@
+// Package archive moves inactive sessions out of a persistence.Store into
+// object storage, one compressed JSON bundle per session, and restores them
+// on demand, keeping the hot database small.
+//
+// Object storage is accessed through the Bucket interface, which S3, GCS
+// and similar clients satisfy with a few lines of glue:
+//
+//	type s3Bucket struct {
+//		client *s3.Client
+//		name   string
+//	}
+//
+//	func (b s3Bucket) Put(ctx context.Context, key string, data []byte) error {
+//		_, err := b.client.PutObject(ctx, &s3.PutObjectInput{Bucket: &b.name, Key: &key, Body: bytes.NewReader(data)})
+//		return err
+//	}
+//
+// Get must return an error wrapping fs.ErrNotExist for missing keys. Dir is
+// a Bucket backed by a local directory.
+//
+// Bundles include the session's artifacts (see
+// persistence.Store.SaveArtifact), which are restored under their original
+// IDs, so tool results truncated before a session was archived can still be
+// read in full after it is restored.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Bucket is object storage that session bundles are archived to.
+type Bucket interface {
+	// Put stores data under key, replacing any existing object.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the object stored under key, or an error wrapping
+	// fs.ErrNotExist if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Dir is a Bucket that stores objects as files in a local directory, which
+// is created if needed.
+type Dir string
+
+func (d Dir) path(key string) string {
+	return filepath.Join(string(d), url.PathEscape(key))
+}
+
+// Put implements Bucket.
+func (d Dir) Put(ctx context.Context, key string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	// Write then rename, so a crash doesn't leave a partial bundle
+	tmp := d.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path(key))
+}
+
+// Get implements Bucket.
+func (d Dir) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(d.path(key))
+}
+
+// bundleVersion is the version of the Bundle format written by Archive.
+// Version 2 added Artifacts.
+const bundleVersion = 2
+
+// Bundle is an archived session, stored as gzipped JSON.
+type Bundle struct {
+	Version    int                        `json:"version"`
+	SessionID  string                     `json:"sessionId"`
+	ArchivedAt time.Time                  `json:"archivedAt"`
+	Owner      string                     `json:"owner,omitzero"`
+	Metrics    persistence.SessionMetrics `json:"metrics"`
+	// Records are all of the session's records, live and dead, in order.
+	Records   []persistence.Record   `json:"records"`
+	Artifacts []persistence.Artifact `json:"artifacts,omitzero"`
+}
+
+// Archiver archives sessions from a Store to a Bucket and restores them.
+type Archiver struct {
+	store  persistence.Store
+	bucket Bucket
+	prefix string
+
+	// mu guards sessions, which holds the lock for each session the
+	// archiver has archived or Store has read
+	mu       sync.Mutex
+	sessions map[string]*sessionState
+}
+
+// sessionState serializes archiving and restoring a session.
+type sessionState struct {
+	mu sync.Mutex
+	// checked is set once Store has restored the session or found it not
+	// to be archived
+	checked bool
+}
+
+// session returns the state for sessionID, creating it if needed.
+func (a *Archiver) session(sessionID string) *sessionState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.sessions[sessionID]
+	if !ok {
+		st = &sessionState{}
+		a.sessions[sessionID] = st
+	}
+	return st
+}
+
+// Option configures an Archiver.
+type Option func(*Archiver)
+
+// WithPrefix puts bundles under prefix in the bucket, such as "sessions/".
+func WithPrefix(prefix string) Option {
+	return func(a *Archiver) {
+		a.prefix = prefix
+	}
+}
+
+// New returns an Archiver that archives sessions from store to bucket.
+func New(store persistence.Store, bucket Bucket, opts ...Option) *Archiver {
+	a := &Archiver{store: store, bucket: bucket, sessions: make(map[string]*sessionState)}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Key returns the bucket key a session's bundle is stored under.
+func (a *Archiver) Key(sessionID string) string {
+	return a.prefix + sessionID + ".json.gz"
+}
+
+// Archive snapshots a session to the bucket, then deletes it from the store.
+// Usage recorded for the session is kept in the store, so archiving doesn't
+// reset quotas. The session must not be in use while it is archived.
+func (a *Archiver) Archive(ctx context.Context, sessionID string) error {
+	st := a.session(sessionID)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	records, err := a.store.GetAllRecords(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load records: %w", err)
+	}
+	metrics, err := a.store.LoadMetrics(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load metrics: %w", err)
+	}
+	owner, err := a.store.GetOwner(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load owner: %w", err)
+	}
+	artifacts, err := a.store.ListArtifacts(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load artifacts: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(Bundle{
+		Version:    bundleVersion,
+		SessionID:  sessionID,
+		ArchivedAt: time.Now(),
+		Owner:      owner,
+		Metrics:    metrics,
+		Records:    records,
+		Artifacts:  artifacts,
+	}); err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress bundle: %w", err)
+	}
+
+	if err := a.bucket.Put(ctx, a.Key(sessionID), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to upload bundle: %w", err)
+	}
+	if err := a.store.DeleteSession(sessionID); err != nil {
+		return fmt.Errorf("failed to delete archived session: %w", err)
+	}
+
+	st.checked = false
+	return nil
+}
+
+// ArchiveInactive archives every session in the store whose last record is
+// older than before, returning the IDs of the sessions it archived. It stops
+// at the first error.
+func (a *Archiver) ArchiveInactive(ctx context.Context, before time.Time) ([]string, error) {
+	sessions, err := a.store.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var archived []string
+	for _, id := range sessions {
+		if err := ctx.Err(); err != nil {
+			return archived, err
+		}
+		records, err := a.store.GetAllRecords(id)
+		if err != nil {
+			return archived, fmt.Errorf("failed to load records for %s: %w", id, err)
+		}
+		if len(records) == 0 || !records[len(records)-1].Timestamp.Before(before) {
+			continue
+		}
+		if err := a.Archive(ctx, id); err != nil {
+			return archived, fmt.Errorf("failed to archive %s: %w", id, err)
+		}
+		archived = append(archived, id)
+	}
+	return archived, nil
+}
+
+// Load returns a session's archived bundle. The error wraps fs.ErrNotExist
+// if the session isn't archived.
+func (a *Archiver) Load(ctx context.Context, sessionID string) (Bundle, error) {
+	data, err := a.bucket.Get(ctx, a.Key(sessionID))
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	var b Bundle
+	if err := json.NewDecoder(zr).Decode(&b); err != nil {
+		return Bundle{}, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if b.Version > bundleVersion {
+		return Bundle{}, fmt.Errorf("bundle version %d is newer than supported version %d", b.Version, bundleVersion)
+	}
+	return b, nil
+}
+
+// Restore copies an archived session back into the store, reporting
+// whether it did. Sessions that already have records in the store, or that
+// aren't archived, are left alone. Restored records get new IDs, while
+// artifacts keep theirs. The bundle is left in the bucket; archiving the
+// session again replaces it.
+func (a *Archiver) Restore(ctx context.Context, sessionID string) (bool, error) {
+	st := a.session(sessionID)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return a.restoreLocked(ctx, sessionID)
+}
+
+// restoreLocked implements Restore (the session's lock must be held).
+func (a *Archiver) restoreLocked(ctx context.Context, sessionID string) (bool, error) {
+	existing, err := a.store.GetAllRecords(sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load records: %w", err)
+	}
+	if len(existing) > 0 {
+		return false, nil
+	}
+
+	b, err := a.Load(ctx, sessionID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if b.Owner != "" {
+		if err := a.store.SetOwner(sessionID, b.Owner); err != nil {
+			return false, fmt.Errorf("failed to restore owner: %w", err)
+		}
+	}
+	if err := a.store.SaveMetrics(sessionID, b.Metrics); err != nil {
+		return false, fmt.Errorf("failed to restore metrics: %w", err)
+	}
+	for _, r := range b.Records {
+		r.ID = 0
+		if _, err := a.store.AddRecord(sessionID, r); err != nil {
+			return false, fmt.Errorf("failed to restore record: %w", err)
+		}
+	}
+	for _, artifact := range b.Artifacts {
+		if err := a.store.RestoreArtifact(sessionID, artifact); err != nil {
+			return false, fmt.Errorf("failed to restore artifact: %w", err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,193 @@
+package archive
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func addSession(t *testing.T, store persistence.Store, sessionID string, at time.Time) {
+	t.Helper()
+
+	require.NoError(t, store.SetOwner(sessionID, "alice"))
+	require.NoError(t, store.SaveMetrics(sessionID, persistence.SessionMetrics{CumulativeTokens: 42, CompactionThreshold: 0.8}))
+	for i, r := range []persistence.Record{
+		{Role: chat.UserRole, Contents: []chat.Content{{Text: "Hello"}}, Live: false, Timestamp: at},
+		{Role: chat.AssistantRole, Contents: []chat.Content{{Text: "Hi"}}, Live: true, OutputTokens: 3, Timestamp: at},
+	} {
+		r.Timestamp = r.Timestamp.Add(time.Duration(i) * time.Millisecond)
+		_, err := store.AddRecord(sessionID, r)
+		require.NoError(t, err)
+	}
+	_, err := store.SaveArtifact(sessionID, "full tool result")
+	require.NoError(t, err)
+}
+
+func TestArchiveRestore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := persistence.NewMemoryStore()
+	bucket := Dir(t.TempDir())
+	a := New(store, bucket, WithPrefix("sessions/"))
+
+	addSession(t, store, "s1", time.Now())
+	want, err := store.GetAllRecords("s1")
+	require.NoError(t, err)
+
+	require.NoError(t, a.Archive(ctx, "s1"))
+	records, err := store.GetAllRecords("s1")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	b, err := a.Load(ctx, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, "s1", b.SessionID)
+	assert.Equal(t, "alice", b.Owner)
+	assert.Len(t, b.Records, 2)
+	assert.Len(t, b.Artifacts, 1)
+
+	restored, err := a.Restore(ctx, "s1")
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	records, err = store.GetAllRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	for i := range records {
+		assert.Equal(t, want[i].GetText(), records[i].GetText())
+		assert.Equal(t, want[i].Live, records[i].Live)
+		assert.True(t, want[i].Timestamp.Equal(records[i].Timestamp))
+	}
+	metrics, err := store.LoadMetrics("s1")
+	require.NoError(t, err)
+	assert.Equal(t, 42, metrics.CumulativeTokens)
+	owner, err := store.GetOwner("s1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+	// Artifacts keep their IDs, so handles in the records still work
+	content, err := store.GetArtifact("s1", b.Artifacts[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "full tool result", content)
+
+	// Sessions already in the store, or never archived, are left alone
+	restored, err = a.Restore(ctx, "s1")
+	require.NoError(t, err)
+	assert.False(t, restored)
+	restored, err = a.Restore(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	_, err = a.Load(ctx, "unknown")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestArchiveInactive(t *testing.T) {
+	t.Parallel()
+
+	store := persistence.NewMemoryStore()
+	a := New(store, Dir(t.TempDir()))
+
+	now := time.Now()
+	addSession(t, store, "old", now.Add(-48*time.Hour))
+	addSession(t, store, "recent", now)
+
+	archived, err := a.ArchiveInactive(context.Background(), now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, archived)
+
+	sessions, err := store.ListSessions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"recent"}, sessions)
+}
+
+func TestArchiveLazyStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := persistence.NewMemoryStore()
+	a := New(store, Dir(t.TempDir()))
+	lazy := a.Store()
+
+	addSession(t, store, "s1", time.Now())
+	require.NoError(t, a.Archive(ctx, "s1"))
+
+	// Reading an archived session restores it
+	metrics, err := lazy.LoadMetrics("s1")
+	require.NoError(t, err)
+	assert.Equal(t, 42, metrics.CumulativeTokens)
+	records, err := lazy.GetLiveRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Hi", records[0].GetText())
+
+	// Reading an artifact restores the session too
+	require.NoError(t, a.Archive(ctx, "s1"))
+	content, err := lazy.GetArtifact("s1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "full tool result", content)
+
+	// Archiving again means the next read restores it again
+	require.NoError(t, a.Archive(ctx, "s1"))
+	records, err = lazy.GetAllRecords("s1")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// New sessions start empty
+	records, err = lazy.GetAllRecords("new")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+// blockingBucket blocks Get for key until release is closed.
+type blockingBucket struct {
+	Bucket
+	key     string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	if key == b.key {
+		close(b.started)
+		<-b.release
+	}
+	return b.Bucket.Get(ctx, key)
+}
+
+func TestArchiveLazyStoreLocksPerSession(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := persistence.NewMemoryStore()
+	bucket := &blockingBucket{Bucket: Dir(t.TempDir()), key: "slow.json.gz", started: make(chan struct{}), release: make(chan struct{})}
+	a := New(store, bucket)
+	lazy := a.Store()
+
+	addSession(t, store, "slow", time.Now())
+	require.NoError(t, a.Archive(ctx, "slow"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		records, err := lazy.GetAllRecords("slow")
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+	}()
+	<-bucket.started
+
+	// Other sessions aren't held up by the slow download
+	records, err := lazy.GetAllRecords("other")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	close(bucket.release)
+	<-done
+}
@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"context"
+	"time"
+
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// restoreTimeout limits how long a Store read waits for a session to be
+// restored, as Store methods don't take a context.
+const restoreTimeout = 30 * time.Second
+
+// Store returns a persistence.Store that restores archived sessions into
+// the archiver's store the first time they are read, so agent.NewSession
+// with agent.WithRestoreSession can resume archived sessions transparently.
+// The first read of each session checks the bucket once; sessions that
+// aren't archived, like new ones, aren't checked again.
+func (a *Archiver) Store() persistence.Store {
+	return &lazyStore{Store: a.store, archiver: a}
+}
+
+type lazyStore struct {
+	persistence.Store
+	archiver *Archiver
+}
+
+// ensure restores sessionID if it is archived and hasn't been checked since
+// it was last archived. Only reads of the same session wait for the
+// bucket.
+func (s *lazyStore) ensure(sessionID string) error {
+	st := s.archiver.session(sessionID)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.checked {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+	defer cancel()
+	if _, err := s.archiver.restoreLocked(ctx, sessionID); err != nil {
+		return err
+	}
+	st.checked = true
+	return nil
+}
+
+func (s *lazyStore) GetRecord(sessionID string, id int64) (persistence.Record, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return persistence.Record{}, err
+	}
+	return s.Store.GetRecord(sessionID, id)
+}
+
+func (s *lazyStore) GetAllRecords(sessionID string) ([]persistence.Record, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return nil, err
+	}
+	return s.Store.GetAllRecords(sessionID)
+}
+
+func (s *lazyStore) GetLiveRecords(sessionID string) ([]persistence.Record, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return nil, err
+	}
+	return s.Store.GetLiveRecords(sessionID)
+}
+
+func (s *lazyStore) LoadMetrics(sessionID string) (persistence.SessionMetrics, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return persistence.SessionMetrics{}, err
+	}
+	return s.Store.LoadMetrics(sessionID)
+}
+
+func (s *lazyStore) GetOwner(sessionID string) (string, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return "", err
+	}
+	return s.Store.GetOwner(sessionID)
+}
+
+func (s *lazyStore) GetArtifact(sessionID string, id int64) (string, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return "", err
+	}
+	return s.Store.GetArtifact(sessionID, id)
+}
+
+func (s *lazyStore) ListArtifacts(sessionID string) ([]persistence.Artifact, error) {
+	if err := s.ensure(sessionID); err != nil {
+		return nil, err
+	}
+	return s.Store.ListArtifacts(sessionID)
+}
+
+func (s *lazyStore) AddRecord(sessionID string, record persistence.Record) (int64, error) {
+	// Restore first, so new records follow the archived ones
+	if err := s.ensure(sessionID); err != nil {
+		return 0, err
+	}
+	return s.Store.AddRecord(sessionID, record)
+}
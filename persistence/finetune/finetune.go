@@ -0,0 +1,247 @@
+// Package finetune converts sessions from a persistence.Store into
+// fine-tuning datasets, so production transcripts can become training data.
+package finetune
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	_ "github.com/bpowers/go-agent/llm/claude" // registers the anthropic message exporter
+	_ "github.com/bpowers/go-agent/llm/openai" // registers the openai message exporter
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Format is a fine-tuning dataset format.
+type Format string
+
+const (
+	// FormatOpenAI is OpenAI's chat fine-tuning format: one
+	// {"messages": [...]} object per line, with messages as sent to the
+	// Chat Completions API.
+	FormatOpenAI Format = "openai"
+	// FormatAnthropic is one {"system": "...", "messages": [...]} object
+	// per line, with messages as sent to the Messages API.
+	FormatAnthropic Format = "anthropic"
+)
+
+// summaryPrefix starts the assistant record that replaces compacted turns
+// in an agent.Session.
+const summaryPrefix = "[Previous conversation summary]"
+
+// Options selects and filters the sessions to export.
+type Options struct {
+	// Format is the dataset format; the default is FormatOpenAI.
+	Format Format
+	// SessionIDs are the sessions to export. If empty, all sessions in the
+	// store are exported.
+	SessionIDs []string
+	// OnlySuccessful skips sessions with failed or pending records or
+	// tool errors, or that don't end with an assistant response.
+	OnlySuccessful bool
+	// StripTools removes tool calls and results, keeping only the
+	// conversation's text.
+	StripTools bool
+	// Anonymize redacts email addresses, IP addresses, phone numbers, and
+	// API keys (see Anonymize).
+	Anonymize bool
+}
+
+// Stats describes an export.
+type Stats struct {
+	// Written is the number of sessions written as examples.
+	Written int
+	// Skipped maps a reason to the number of sessions skipped for it.
+	Skipped map[string]int
+}
+
+// Write writes the sessions selected by opts from store to w as JSONL, one
+// example per session. Sessions are exported from their live records;
+// sessions that have been compacted are skipped, since their early turns
+// have been replaced by a summary.
+func Write(w io.Writer, store persistence.Store, opts Options) (Stats, error) {
+	stats := Stats{Skipped: make(map[string]int)}
+	switch opts.Format {
+	case "":
+		opts.Format = FormatOpenAI
+	case FormatOpenAI, FormatAnthropic:
+	default:
+		return stats, fmt.Errorf("unknown format %q (want openai or anthropic)", opts.Format)
+	}
+
+	ids := opts.SessionIDs
+	if len(ids) == 0 {
+		var err error
+		if ids, err = store.ListSessions(); err != nil {
+			return stats, fmt.Errorf("failed to list sessions: %w", err)
+		}
+	}
+
+	for _, id := range ids {
+		records, err := store.GetLiveRecords(id)
+		if err != nil {
+			return stats, fmt.Errorf("failed to load session %s: %w", id, err)
+		}
+		example, reason, err := Example(records, opts)
+		if err != nil {
+			return stats, fmt.Errorf("session %s: %w", id, err)
+		}
+		if reason != "" {
+			stats.Skipped[reason]++
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", example); err != nil {
+			return stats, err
+		}
+		stats.Written++
+	}
+	return stats, nil
+}
+
+// Example converts a session's records to a single example in opts.Format.
+// If the session should be skipped, it returns the reason instead.
+func Example(records []persistence.Record, opts Options) (example json.RawMessage, skip string, err error) {
+	var systemPrompt string
+	var msgs []chat.Message
+	for _, r := range records {
+		if opts.OnlySuccessful && r.Status != persistence.RecordStatusSuccess {
+			return nil, "unsuccessful", nil
+		}
+		if r.Role == chat.AssistantRole && strings.HasPrefix(r.GetText(), summaryPrefix) {
+			return nil, "compacted", nil
+		}
+		if r.Role == "system" {
+			if systemPrompt != "" {
+				systemPrompt += "\n\n"
+			}
+			systemPrompt += r.GetText()
+			continue
+		}
+
+		var contents []chat.Content
+		for _, c := range r.Contents {
+			if opts.OnlySuccessful && c.ToolResult != nil && c.ToolResult.Error != "" {
+				return nil, "tool error", nil
+			}
+			// Thinking signatures are only meaningful to the model that
+			// produced them
+			if c.Thinking != nil || (opts.StripTools && (c.ToolCall != nil || c.ToolResult != nil)) {
+				continue
+			}
+			contents = append(contents, c)
+		}
+		if len(contents) == 0 {
+			continue
+		}
+
+		// Merge messages left adjacent by stripping tool turns
+		if n := len(msgs); n > 0 && opts.StripTools && msgs[n-1].Role == r.Role {
+			msgs[n-1].Contents = append(msgs[n-1].Contents, contents...)
+			continue
+		}
+		msgs = append(msgs, chat.Message{Role: r.Role, Contents: contents})
+	}
+
+	if len(msgs) == 0 {
+		return nil, "empty", nil
+	}
+	if opts.OnlySuccessful && (msgs[len(msgs)-1].Role != chat.AssistantRole || msgs[len(msgs)-1].GetText() == "") {
+		return nil, "incomplete", nil
+	}
+	if opts.StripTools {
+		msgs = mergeText(msgs)
+	}
+	if opts.Anonymize {
+		systemPrompt = Anonymize(systemPrompt)
+		for i := range msgs {
+			msgs[i] = anonymizeMessage(msgs[i])
+		}
+	}
+
+	switch opts.Format {
+	case FormatAnthropic:
+		exported, err := chat.ExportMessages("anthropic", msgs)
+		if err != nil {
+			return nil, "", err
+		}
+		example, err = json.Marshal(struct {
+			System   string          `json:"system,omitzero"`
+			Messages json.RawMessage `json:"messages"`
+		}{systemPrompt, exported})
+		return example, "", err
+	default:
+		if systemPrompt != "" {
+			msgs = append([]chat.Message{chat.SystemMessage(systemPrompt)}, msgs...)
+		}
+		exported, err := chat.ExportMessages("openai", msgs)
+		if err != nil {
+			return nil, "", err
+		}
+		example, err = json.Marshal(struct {
+			Messages json.RawMessage `json:"messages"`
+		}{exported})
+		return example, "", err
+	}
+}
+
+// mergeText joins each message's text contents into one paragraph each, for
+// messages that were merged after stripping tool turns.
+func mergeText(msgs []chat.Message) []chat.Message {
+	for i, msg := range msgs {
+		var texts []string
+		for _, c := range msg.Contents {
+			if text := strings.TrimSpace(c.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		msgs[i].Contents = []chat.Content{{Text: strings.Join(texts, "\n\n")}}
+	}
+	return msgs
+}
+
+// redactions are applied in order by Anonymize; secrets come first so their
+// contents aren't partially matched by the other patterns.
+var redactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{20,}`), "[SECRET]"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+	{regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`), "[PHONE]"},
+}
+
+// Anonymize replaces email addresses, IPv4 addresses, North American style
+// phone numbers, and common API key formats in s with placeholders like
+// [EMAIL]. It is a best-effort filter, not a guarantee that s contains no
+// personal data.
+func Anonymize(s string) string {
+	for _, r := range redactions {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+func anonymizeMessage(msg chat.Message) chat.Message {
+	contents := make([]chat.Content, len(msg.Contents))
+	for i, c := range msg.Contents {
+		c.Text = Anonymize(c.Text)
+		if c.ToolCall != nil {
+			tc := *c.ToolCall
+			// Placeholders contain no quotes, so the arguments stay valid JSON
+			tc.Arguments = json.RawMessage(Anonymize(string(tc.Arguments)))
+			c.ToolCall = &tc
+		}
+		if c.ToolResult != nil {
+			tr := *c.ToolResult
+			tr.Content = Anonymize(tr.Content)
+			tr.Error = Anonymize(tr.Error)
+			c.ToolResult = &tr
+		}
+		contents[i] = c
+	}
+	return chat.Message{Role: msg.Role, Contents: contents}
+}
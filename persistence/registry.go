@@ -0,0 +1,63 @@
+package persistence
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// StoreFactory opens a store from a URL with the scheme it was registered
+// under.
+type StoreFactory func(u *url.URL) (Store, error)
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]StoreFactory{
+		"memory": func(u *url.URL) (Store, error) { return NewMemoryStore(), nil },
+	}
+)
+
+// RegisterStore makes Open use factory for URLs with scheme, such as
+// "sqlite" or "postgres". Store packages register themselves when imported;
+// registering the same scheme twice panics, as with database/sql drivers.
+func RegisterStore(scheme string, factory StoreFactory) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("persistence: RegisterStore factory for %q is nil", scheme))
+	}
+	if _, dup := stores[scheme]; dup {
+		panic(fmt.Sprintf("persistence: RegisterStore called twice for scheme %q", scheme))
+	}
+	stores[scheme] = factory
+}
+
+// Stores returns the URL schemes Open supports.
+func Stores() []string {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	return slices.Sorted(maps.Keys(stores))
+}
+
+// Open opens the store a URL names, so applications can choose a store
+// with a config string like "sqlite:///var/lib/agent/chat.db". "memory://"
+// is a new MemoryStore; other schemes are handled by the store package that
+// registered them, which must be imported.
+func Open(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+	storesMu.Lock()
+	factory, ok := stores[u.Scheme]
+	storesMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no store for scheme %q in %q (is its package imported? known: %s)", u.Scheme, rawURL, strings.Join(Stores(), ", "))
+	}
+	return factory(u)
+}
@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	store, err := Open("memory://")
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryStore{}, store)
+
+	var got *url.URL
+	RegisterStore("registrytest", func(u *url.URL) (Store, error) {
+		got = u
+		return NewMemoryStore(), nil
+	})
+	assert.Contains(t, Stores(), "registrytest")
+
+	_, err = Open("registrytest://db.internal:5432/agent?sslmode=disable")
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal:5432", got.Host)
+	assert.Equal(t, "/agent", got.Path)
+	assert.Equal(t, "disable", got.Query().Get("sslmode"))
+
+	_, err = Open("redis://localhost:6379")
+	assert.ErrorContains(t, err, `no store for scheme "redis"`)
+	_, err = Open("::")
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		RegisterStore("memory", func(u *url.URL) (Store, error) { return nil, nil })
+	})
+	assert.Panics(t, func() { RegisterStore("other", nil) })
+}
@@ -0,0 +1,120 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotVersion is the version of the snapshot format written by SaveTo.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version  int                        `json:"version"`
+	Sessions map[string]sessionSnapshot `json:"sessions"`
+	Usage    []Usage                    `json:"usage,omitzero"`
+}
+
+type sessionSnapshot struct {
+	Records   []Record       `json:"records"`
+	NextID    int64          `json:"nextID"`
+	Metrics   SessionMetrics `json:"metrics"`
+	Artifacts []string       `json:"artifacts,omitzero"`
+	Owner     string         `json:"owner,omitzero"`
+}
+
+// SaveTo writes the store's sessions, artifacts and usage to path as JSON,
+// replacing the file atomically, so tests and small tools can keep state
+// between runs without a database.
+func (m *MemoryStore) SaveTo(path string) error {
+	data, err := json.Marshal(m.snapshot())
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	// Write then rename, so a crash doesn't leave a partial snapshot
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+func (m *MemoryStore) snapshot() snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := snapshot{
+		Version:  snapshotVersion,
+		Sessions: make(map[string]sessionSnapshot, len(m.sessions)),
+		Usage:    append([]Usage(nil), m.usage...),
+	}
+	for id, sess := range m.sessions {
+		records := make([]Record, len(sess.records))
+		for i, r := range sess.records {
+			records[i] = cloneRecord(r)
+		}
+		snap.Sessions[id] = sessionSnapshot{
+			Records:   records,
+			NextID:    sess.nextID,
+			Metrics:   sess.metrics,
+			Artifacts: append([]string(nil), sess.artifacts...),
+			Owner:     sess.owner,
+		}
+	}
+	return snap
+}
+
+// LoadFrom replaces the store's contents with a snapshot written by SaveTo.
+// The error wraps fs.ErrNotExist if path doesn't exist, so callers can start
+// empty on first run.
+func (m *MemoryStore) LoadFrom(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version > snapshotVersion {
+		return fmt.Errorf("snapshot version %d is newer than supported version %d", snap.Version, snapshotVersion)
+	}
+
+	sessions := make(map[string]*sessionData, len(snap.Sessions))
+	for id, s := range snap.Sessions {
+		sess := &sessionData{
+			records:   s.Records,
+			nextID:    s.NextID,
+			metrics:   s.Metrics,
+			artifacts: s.Artifacts,
+			owner:     s.Owner,
+		}
+		if sess.records == nil {
+			sess.records = make([]Record, 0)
+		}
+		// Never reuse an ID, even if the snapshot was edited by hand
+		for _, r := range sess.records {
+			sess.nextID = max(sess.nextID, r.ID+1)
+		}
+		sessions[id] = sess
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = sessions
+	m.usage = snap.Usage
+	return nil
+}
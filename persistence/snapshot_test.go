@@ -0,0 +1,85 @@
+package persistence
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestMemoryStoreSnapshot(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "store.json")
+	now := time.Now().UTC()
+
+	store := NewMemoryStore()
+	require.NoError(t, store.SetOwner("s1", "alice"))
+	require.NoError(t, store.SaveMetrics("s1", SessionMetrics{CompactionCount: 2, CumulativeTokens: 42}))
+	_, err := store.AddRecord("s1", Record{Role: chat.UserRole, Contents: []chat.Content{{Text: "Hello"}}, Timestamp: now})
+	require.NoError(t, err)
+	_, err = store.AddRecord("s1", Record{
+		Role: chat.AssistantRole,
+		Contents: []chat.Content{{ToolCall: &chat.ToolCall{
+			ID:        "call_1",
+			Name:      "search",
+			Arguments: json.RawMessage(`{"q":"go"}`),
+		}}},
+		Live:      true,
+		Pinned:    true,
+		Timestamp: now,
+	})
+	require.NoError(t, err)
+	artifactID, err := store.SaveArtifact("s1", "full tool output")
+	require.NoError(t, err)
+	require.NoError(t, store.AddUsage(Usage{Owner: "alice", SessionID: "s1", InputTokens: 10, Timestamp: now}))
+
+	require.NoError(t, store.SaveTo(path))
+
+	loaded := NewMemoryStore()
+	require.NoError(t, loaded.LoadFrom(path))
+
+	want, err := store.GetAllRecords("s1")
+	require.NoError(t, err)
+	got, err := loaded.GetAllRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.Equal(t, want[i].Contents, got[i].Contents)
+		assert.Equal(t, want[i].Live, got[i].Live)
+		assert.Equal(t, want[i].Pinned, got[i].Pinned)
+		assert.True(t, want[i].Timestamp.Equal(got[i].Timestamp))
+	}
+
+	metrics, err := loaded.LoadMetrics("s1")
+	require.NoError(t, err)
+	assert.Equal(t, 42, metrics.CumulativeTokens)
+	owner, err := loaded.GetOwner("s1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+	artifact, err := loaded.GetArtifact("s1", artifactID)
+	require.NoError(t, err)
+	assert.Equal(t, "full tool output", artifact)
+	usage, err := loaded.GetUsage("alice", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, usage.InputTokens)
+
+	// IDs continue where the saved store left off
+	id, err := loaded.AddRecord("s1", Record{Role: chat.UserRole, Timestamp: now})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+func TestMemoryStoreLoadFromMissing(t *testing.T) {
+	t.Parallel()
+
+	err := NewMemoryStore().LoadFrom(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
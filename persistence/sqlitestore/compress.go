@@ -0,0 +1,170 @@
+package sqlitestore
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultCompressMinSize is the smallest record contents compressed by
+// WithCompression(0); below it, zstd's overhead outweighs the savings.
+const defaultCompressMinSize = 1024
+
+// zstdMagic starts every zstd frame. Record contents are JSON, which never
+// starts with it, so compressed and plain contents can share a column.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// The encoder and decoder are shared, as EncodeAll and DecodeAll are safe
+// for concurrent use.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Option configures a SQLiteStore.
+type Option func(*SQLiteStore)
+
+// WithCompression compresses the contents of records of at least minSize
+// bytes (1 KiB if minSize is 0 or less) with zstd as they are written, which
+// shrinks databases holding large tool results several-fold. Compressed
+// contents are decompressed transparently when read, including by stores
+// opened without this option, and existing records can be compressed with
+// CompressRecords.
+func WithCompression(minSize int) Option {
+	return func(s *SQLiteStore) {
+		if minSize <= 0 {
+			minSize = defaultCompressMinSize
+		}
+		s.compressMinSize = minSize
+	}
+}
+
+// contentsValue returns the value to store for a record's encoded contents:
+// the JSON text, or a zstd-compressed BLOB if it is large enough to
+// compress.
+func (s *SQLiteStore) contentsValue(contentsJSON string) any {
+	if s.compressMinSize == 0 || len(contentsJSON) < s.compressMinSize {
+		return contentsJSON
+	}
+	return compressPayload(contentsJSON)
+}
+
+func compressPayload(src string) []byte {
+	return zstdEncoder.EncodeAll([]byte(src), nil)
+}
+
+// decompressPayload returns src decompressed if it is zstd-compressed, and
+// src otherwise.
+func decompressPayload(src string) (string, error) {
+	if len(src) < len(zstdMagic) || src[:len(zstdMagic)] != string(zstdMagic) {
+		return src, nil
+	}
+	data, err := zstdDecoder.DecodeAll([]byte(src), nil)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// CompressionStats describes how much space compression saves in a store.
+type CompressionStats struct {
+	// Records is the number of records in the store.
+	Records int
+	// Compressed is the number of those whose contents are compressed.
+	Compressed int
+	// StoredBytes is the size of all records' contents as stored.
+	StoredBytes int64
+	// UncompressedBytes is the size they would be without compression.
+	UncompressedBytes int64
+}
+
+// Savings returns the fraction of space saved by compression.
+func (c CompressionStats) Savings() float64 {
+	if c.UncompressedBytes == 0 {
+		return 0
+	}
+	return 1 - float64(c.StoredBytes)/float64(c.UncompressedBytes)
+}
+
+// CompressionStats reports the space taken by record contents, across all
+// sessions, with and without compression.
+func (s *SQLiteStore) CompressionStats() (CompressionStats, error) {
+	rows, err := s.db.Query(`SELECT contents FROM records`)
+	if err != nil {
+		return CompressionStats{}, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+
+	var stats CompressionStats
+	for rows.Next() {
+		var contents string
+		if err := rows.Scan(&contents); err != nil {
+			return CompressionStats{}, fmt.Errorf("scan record: %w", err)
+		}
+		plain, err := decompressPayload(contents)
+		if err != nil {
+			return CompressionStats{}, fmt.Errorf("decompress contents: %w", err)
+		}
+		stats.Records++
+		if plain != contents {
+			stats.Compressed++
+		}
+		stats.StoredBytes += int64(len(contents))
+		stats.UncompressedBytes += int64(len(plain))
+	}
+	if err := rows.Err(); err != nil {
+		return CompressionStats{}, fmt.Errorf("iterate records: %w", err)
+	}
+	return stats, nil
+}
+
+// CompressRecords compresses the contents of existing records of at least
+// minSize bytes (1 KiB if minSize is 0 or less), for migrating databases
+// written without WithCompression. It returns the number of records it
+// compressed. The file only shrinks once SQLite reuses or vacuums the freed
+// pages.
+func (s *SQLiteStore) CompressRecords(minSize int) (int, error) {
+	if minSize <= 0 {
+		minSize = defaultCompressMinSize
+	}
+
+	// Compressed contents are stored as BLOBs, so only text is left to do
+	rows, err := s.db.Query(`SELECT id, contents FROM records WHERE typeof(contents) = 'text' AND length(CAST(contents AS BLOB)) >= ?`, minSize)
+	if err != nil {
+		return 0, fmt.Errorf("query records: %w", err)
+	}
+	type pending struct {
+		id   int64
+		data []byte
+	}
+	var updates []pending
+	for rows.Next() {
+		var id int64
+		var contents string
+		if err := rows.Scan(&id, &contents); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan record: %w", err)
+		}
+		updates = append(updates, pending{id: id, data: compressPayload(contents)})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("iterate records: %w", err)
+	}
+	rows.Close()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, u := range updates {
+		if _, err := tx.Exec(`UPDATE records SET contents = ? WHERE id = ?`, u.data, u.id); err != nil {
+			return 0, fmt.Errorf("update record %d: %w", u.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(updates), nil
+}
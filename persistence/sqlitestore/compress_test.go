@@ -0,0 +1,122 @@
+package sqlitestore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func textRecord(text string) persistence.Record {
+	return persistence.Record{
+		Role:      chat.ToolRole,
+		Contents:  []chat.Content{{Text: text}},
+		Live:      true,
+		Timestamp: time.Now(),
+	}
+}
+
+func TestSQLiteStoreCompression(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	large := strings.Repeat("tool output line\n", 500)
+
+	// Records written before compression was enabled stay readable
+	plain, err := New(dbPath)
+	require.NoError(t, err)
+	_, err = plain.AddRecord("s1", textRecord(large))
+	require.NoError(t, err)
+	require.NoError(t, plain.Close())
+
+	store, err := New(dbPath, WithCompression(0))
+	require.NoError(t, err)
+	defer store.Close()
+
+	smallID, err := store.AddRecord("s1", textRecord("small"))
+	require.NoError(t, err)
+	largeID, err := store.AddRecord("s1", textRecord(large))
+	require.NoError(t, err)
+
+	records, err := store.GetAllRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, large, records[0].GetText())
+	assert.Equal(t, "small", records[1].GetText())
+	assert.Equal(t, large, records[2].GetText())
+
+	record, err := store.GetRecord("s1", largeID)
+	require.NoError(t, err)
+	assert.Equal(t, large, record.GetText())
+
+	// Updates are compressed too
+	require.NoError(t, store.UpdateRecord("s1", smallID, textRecord(large)))
+	record, err = store.GetRecord("s1", smallID)
+	require.NoError(t, err)
+	assert.Equal(t, large, record.GetText())
+
+	stats, err := store.CompressionStats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Records)
+	assert.Equal(t, 2, stats.Compressed)
+	assert.Less(t, stats.StoredBytes, stats.UncompressedBytes)
+}
+
+func TestSQLiteStoreCompressRecords(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	large := strings.Repeat("tool output line\n", 500)
+	_, err = store.AddRecord("s1", textRecord(large))
+	require.NoError(t, err)
+	_, err = store.AddRecord("s1", textRecord("small"))
+	require.NoError(t, err)
+
+	stats, err := store.CompressionStats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Compressed)
+	assert.Zero(t, stats.Savings())
+
+	n, err := store.CompressRecords(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Already compressed records are skipped
+	n, err = store.CompressRecords(0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	stats, err = store.CompressionStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Records)
+	assert.Equal(t, 1, stats.Compressed)
+	assert.Greater(t, stats.Savings(), 0.5)
+
+	records, err := store.GetAllRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, large, records[0].GetText())
+	assert.Equal(t, "small", records[1].GetText())
+}
+
+func TestCompressPayload(t *testing.T) {
+	large := strings.Repeat("tool output line\n", 500)
+
+	data := compressPayload(large)
+	assert.Equal(t, zstdMagic, data[:len(zstdMagic)])
+	assert.Less(t, len(data), len(large))
+
+	plain, err := decompressPayload(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, large, plain)
+
+	// Uncompressed contents are returned as they are
+	plain, err = decompressPayload(`[{"text":"hi"}]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"text":"hi"}]`, plain)
+}
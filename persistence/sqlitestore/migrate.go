@@ -0,0 +1,202 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Schema changes ship as numbered SQL files in migrations, named like
+// 0002_add_tags.sql. Versions start at 1 and have no gaps. Databases are
+// upgraded to the latest version as they are opened, each migration in its
+// own transaction, and the applied version is kept in PRAGMA user_version.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a versioned change to the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	sql     string
+}
+
+var loadMigrations = sync.OnceValues(func() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	// ReadDir sorts by name, and versions are zero-padded
+	var migrations []Migration
+	for _, e := range entries {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be VERSION_NAME.sql", e.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", e.Name(), err)
+		}
+		if version != len(migrations)+1 {
+			return nil, fmt.Errorf("migration %s: expected version %d", e.Name(), len(migrations)+1)
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, sql: string(data)})
+	}
+	return migrations, nil
+})
+
+// Pending opens the database at dbPath without changing it, and returns its
+// schema version and the migrations that opening it with New would apply.
+func Pending(dbPath string) (int, []Migration, error) {
+	db, err := sql.Open("sqlite", withBusyTimeout(dbPath))
+	if err != nil {
+		return 0, nil, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, nil, fmt.Errorf("load migrations: %w", err)
+	}
+	version, err := schemaVersion(db)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version > len(migrations) {
+		return version, nil, errNewerSchema(version, len(migrations))
+	}
+	return version, migrations[version:], nil
+}
+
+// rowQuerier is a *sql.DB or *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func schemaVersion(db rowQuerier) (int, error) {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	return version, nil
+}
+
+func errNewerSchema(version, latest int) error {
+	return fmt.Errorf("database schema version %d is newer than supported version %d", version, latest)
+}
+
+// migrate upgrades the schema to the latest version.
+func (s *SQLiteStore) migrate() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+	version, err := schemaVersion(s.db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		// Opened by an older build than the one that last wrote it
+		return errNewerSchema(version, len(migrations))
+	}
+	if version == 0 {
+		if err := s.upgradeLegacySchema(); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations[version:] {
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) applyMigration(m Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Another process may have applied it since we checked
+	version, err := schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if version >= m.Version {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.Version)); err != nil {
+		return fmt.Errorf("set schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// upgradeLegacySchema adds the columns added to the records table before
+// migrations were introduced to databases that predate them, so the
+// baseline migration applies cleanly.
+func (s *SQLiteStore) upgradeLegacySchema() error {
+	var n int
+	if err := s.db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'records'`).Scan(&n); err != nil {
+		return fmt.Errorf("query tables: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	for _, c := range []struct{ column, definition string }{
+		{"user_id", `TEXT NOT NULL DEFAULT ''`},
+		{"moderation", `TEXT NOT NULL DEFAULT ''`},
+		{"pinned", `BOOLEAN NOT NULL DEFAULT 0`},
+		{"options", `TEXT NOT NULL DEFAULT ''`},
+		{"requests", `TEXT NOT NULL DEFAULT ''`},
+	} {
+		if err := s.addColumnIfMissing("records", c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table created by an older version of
+// the schema.
+func (s *SQLiteStore) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return fmt.Errorf("query columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("scan column: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate columns: %w", err)
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
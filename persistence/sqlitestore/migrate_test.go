@@ -0,0 +1,53 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, "baseline", migrations[0].Name)
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	version, pending, err := Pending(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.Equal(t, migrations, pending)
+
+	store, err := New(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	version, pending, err = Pending(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+	assert.Empty(t, pending)
+
+	// Reopening an up-to-date database changes nothing
+	store, err = New(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestMigrationsRejectNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`PRAGMA user_version = 1000`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = New(dbPath)
+	assert.ErrorContains(t, err, "newer than supported")
+	_, _, err = Pending(dbPath)
+	assert.ErrorContains(t, err, "newer than supported")
+}
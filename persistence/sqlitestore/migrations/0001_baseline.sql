@@ -0,0 +1,60 @@
+-- The schema as of the introduction of migrations. Databases created
+-- before then are brought up to it by upgradeLegacySchema first, so every
+-- statement here must be idempotent.
+CREATE TABLE IF NOT EXISTS records (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id    TEXT NOT NULL,
+    role          TEXT NOT NULL,
+    contents      TEXT NOT NULL,
+    live          BOOLEAN NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'success',
+    input_tokens  INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    timestamp     DATETIME NOT NULL,
+    user_id       TEXT NOT NULL DEFAULT '',
+    moderation    TEXT NOT NULL DEFAULT '',
+    pinned        BOOLEAN NOT NULL DEFAULT 0,
+    options       TEXT NOT NULL DEFAULT '',
+    requests      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_records_session ON records(session_id);
+CREATE INDEX IF NOT EXISTS idx_records_live ON records(session_id, live);
+CREATE INDEX IF NOT EXISTS idx_records_timestamp ON records(session_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS artifacts (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id    TEXT NOT NULL,
+    content       TEXT NOT NULL,
+    timestamp     DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_artifacts_session ON artifacts(session_id);
+
+CREATE TABLE IF NOT EXISTS metrics (
+    session_id            TEXT PRIMARY KEY,
+    compaction_count      INTEGER NOT NULL DEFAULT 0,
+    last_compaction       DATETIME,
+    cumulative_tokens     INTEGER NOT NULL DEFAULT 0,
+    compaction_threshold  REAL NOT NULL DEFAULT 0.8,
+    data                  TEXT
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    session_id    TEXT PRIMARY KEY,
+    owner         TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_owner ON sessions(owner);
+
+CREATE TABLE IF NOT EXISTS usage (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner         TEXT NOT NULL,
+    session_id    TEXT NOT NULL,
+    input_tokens  INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cost          REAL NOT NULL DEFAULT 0,
+    timestamp     DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_owner ON usage(owner, timestamp);
@@ -0,0 +1,2 @@
+-- The ID of the turn each record was saved in (see chat.WithTurnID).
+ALTER TABLE records ADD COLUMN turn_id TEXT NOT NULL DEFAULT '';
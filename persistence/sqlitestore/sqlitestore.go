@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -16,55 +19,77 @@ import (
 // SQLiteStore implements persistence.Store using SQLite.
 type SQLiteStore struct {
 	db *sql.DB
+	// compressMinSize is the size above which record contents are
+	// compressed, or 0 if they aren't
+	compressMinSize int
+}
+
+func init() {
+	persistence.RegisterStore("sqlite", openURL)
+}
+
+// openURL opens the store a persistence.Open URL names:
+// "sqlite:///abs/path.db", "sqlite://relative.db", or "sqlite://:memory:".
+// A compress parameter enables WithCompression with its value as the
+// minimum size ("compress=0" for the default), and other parameters are
+// passed to the driver, such as "_pragma=journal_mode(WAL)".
+func openURL(u *url.URL) (persistence.Store, error) {
+	path := u.Host + u.Path
+	if path == "" {
+		return nil, fmt.Errorf("invalid store URL %q: database path is required", u.Redacted())
+	}
+
+	var opts []Option
+	query := u.Query()
+	if query.Has("compress") {
+		minSize, err := strconv.Atoi(query.Get("compress"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid store URL %q: bad compress: %w", u.Redacted(), err)
+		}
+		opts = append(opts, WithCompression(minSize))
+		query.Del("compress")
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return New(path, opts...)
 }
 
 // New creates a new SQLite-based store at the given path.
 // Use ":memory:" for an in-memory database.
-func New(dbPath string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite", dbPath)
+func New(dbPath string, opts ...Option) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", withBusyTimeout(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
 
 	store := &SQLiteStore{db: db}
-	if err := store.initSchema(); err != nil {
+	for _, opt := range opts {
+		opt(store)
+	}
+	if err := store.migrate(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("init schema: %w", err)
+		return nil, fmt.Errorf("migrate schema: %w", err)
 	}
 
 	return store, nil
 }
 
-// initSchema creates the necessary tables if they don't exist.
-func (s *SQLiteStore) initSchema() error {
-	const schema = `
-CREATE TABLE IF NOT EXISTS records (
-    id            INTEGER PRIMARY KEY AUTOINCREMENT,
-    session_id    TEXT NOT NULL,
-    role          TEXT NOT NULL,
-    contents      TEXT NOT NULL,
-    live          BOOLEAN NOT NULL,
-    status        TEXT NOT NULL DEFAULT 'success',
-    input_tokens  INTEGER NOT NULL DEFAULT 0,
-    output_tokens INTEGER NOT NULL DEFAULT 0,
-    timestamp     DATETIME NOT NULL
-);
-
-CREATE INDEX IF NOT EXISTS idx_records_session ON records(session_id);
-CREATE INDEX IF NOT EXISTS idx_records_live ON records(session_id, live);
-CREATE INDEX IF NOT EXISTS idx_records_timestamp ON records(session_id, timestamp);
-
-CREATE TABLE IF NOT EXISTS metrics (
-    session_id            TEXT PRIMARY KEY,
-    compaction_count      INTEGER NOT NULL DEFAULT 0,
-    last_compaction       DATETIME,
-    cumulative_tokens     INTEGER NOT NULL DEFAULT 0,
-    compaction_threshold  REAL NOT NULL DEFAULT 0.8,
-    data                  TEXT
-);
-`
-	_, err := s.db.Exec(schema)
-	return err
+// busyTimeout is how long a connection waits for a lock held by another
+// connection, such as a writer in another process, before failing with
+// SQLITE_BUSY.
+const busyTimeout = 5 * time.Second
+
+// withBusyTimeout adds busyTimeout to dsn, unless it sets its own.
+func withBusyTimeout(dsn string) string {
+	if strings.Contains(dsn, "busy_timeout") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)", dsn, sep, busyTimeout.Milliseconds())
 }
 
 func encodeContents(contents []chat.Content) (string, error) {
@@ -78,7 +103,52 @@ func encodeContents(contents []chat.Content) (string, error) {
 	return string(data), nil
 }
 
+// encodeOptional encodes an optional record field as JSON, or as "" if it
+// is unset.
+func encodeOptional[T any](v *T) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeOptional[T any](src string, dest **T) error {
+	if src == "" {
+		*dest = nil
+		return nil
+	}
+	*dest = new(T)
+	return json.Unmarshal([]byte(src), *dest)
+}
+
+func encodeRequests(requests []chat.RequestInfo) (string, error) {
+	if len(requests) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(requests)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeRequests(src string, dest *[]chat.RequestInfo) error {
+	if src == "" {
+		*dest = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(src), dest)
+}
+
 func decodeContents(src string, dest *[]chat.Content) error {
+	src, err := decompressPayload(src)
+	if err != nil {
+		return fmt.Errorf("decompress contents: %w", err)
+	}
 	if src == "" || src == "[]" {
 		*dest = nil
 		return nil
@@ -97,10 +167,23 @@ func (s *SQLiteStore) AddRecord(sessionID string, record persistence.Record) (in
 	if err != nil {
 		return 0, fmt.Errorf("encode contents: %w", err)
 	}
+	contents := s.contentsValue(contentsJSON)
+	moderationJSON, err := encodeOptional(record.Moderation)
+	if err != nil {
+		return 0, fmt.Errorf("encode moderation: %w", err)
+	}
+	optionsJSON, err := encodeOptional(record.Options)
+	if err != nil {
+		return 0, fmt.Errorf("encode options: %w", err)
+	}
+	requestsJSON, err := encodeRequests(record.Requests)
+	if err != nil {
+		return 0, fmt.Errorf("encode requests: %w", err)
+	}
 
 	result, err := s.db.Exec(
-		`INSERT INTO records (session_id, role, contents, live, status, input_tokens, output_tokens, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		sessionID, string(record.Role), contentsJSON, record.Live, string(record.Status), record.InputTokens, record.OutputTokens, record.Timestamp,
+		`INSERT INTO records (session_id, role, contents, live, status, input_tokens, output_tokens, timestamp, user_id, moderation, pinned, options, requests, turn_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, string(record.Role), contents, record.Live, string(record.Status), record.InputTokens, record.OutputTokens, record.Timestamp, record.User, moderationJSON, record.Pinned, optionsJSON, requestsJSON, record.TurnID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("insert record: %w", err)
@@ -120,10 +203,11 @@ func (s *SQLiteStore) GetRecord(sessionID string, id int64) (persistence.Record,
 	var roleStr string
 	var statusStr string
 	var contentsJSON string
+	var moderationJSON, optionsJSON, requestsJSON string
 	err := s.db.QueryRow(
-		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp FROM records WHERE session_id = ? AND id = ?`,
+		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp, user_id, moderation, pinned, options, requests, turn_id FROM records WHERE session_id = ? AND id = ?`,
 		sessionID, id,
-	).Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp)
+	).Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp, &r.User, &moderationJSON, &r.Pinned, &optionsJSON, &requestsJSON, &r.TurnID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return persistence.Record{}, fmt.Errorf("record not found: %d", id)
@@ -135,13 +219,22 @@ func (s *SQLiteStore) GetRecord(sessionID string, id int64) (persistence.Record,
 	if err := decodeContents(contentsJSON, &r.Contents); err != nil {
 		return persistence.Record{}, fmt.Errorf("decode contents: %w", err)
 	}
+	if err := decodeOptional(moderationJSON, &r.Moderation); err != nil {
+		return persistence.Record{}, fmt.Errorf("decode moderation: %w", err)
+	}
+	if err := decodeOptional(optionsJSON, &r.Options); err != nil {
+		return persistence.Record{}, fmt.Errorf("decode options: %w", err)
+	}
+	if err := decodeRequests(requestsJSON, &r.Requests); err != nil {
+		return persistence.Record{}, fmt.Errorf("decode requests: %w", err)
+	}
 	return r, nil
 }
 
 // GetAllRecords implements persistence.Store.
 func (s *SQLiteStore) GetAllRecords(sessionID string) ([]persistence.Record, error) {
 	rows, err := s.db.Query(
-		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp FROM records WHERE session_id = ? ORDER BY timestamp, id`,
+		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp, user_id, moderation, pinned, options, requests, turn_id FROM records WHERE session_id = ? ORDER BY timestamp, id`,
 		sessionID,
 	)
 	if err != nil {
@@ -155,7 +248,8 @@ func (s *SQLiteStore) GetAllRecords(sessionID string) ([]persistence.Record, err
 		var roleStr string
 		var statusStr string
 		var contentsJSON string
-		if err := rows.Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp); err != nil {
+		var moderationJSON, optionsJSON, requestsJSON string
+		if err := rows.Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp, &r.User, &moderationJSON, &r.Pinned, &optionsJSON, &requestsJSON, &r.TurnID); err != nil {
 			return nil, fmt.Errorf("scan record: %w", err)
 		}
 		r.Role = chat.Role(roleStr)
@@ -163,6 +257,15 @@ func (s *SQLiteStore) GetAllRecords(sessionID string) ([]persistence.Record, err
 		if err := decodeContents(contentsJSON, &r.Contents); err != nil {
 			return nil, fmt.Errorf("decode contents: %w", err)
 		}
+		if err := decodeOptional(moderationJSON, &r.Moderation); err != nil {
+			return nil, fmt.Errorf("decode moderation: %w", err)
+		}
+		if err := decodeOptional(optionsJSON, &r.Options); err != nil {
+			return nil, fmt.Errorf("decode options: %w", err)
+		}
+		if err := decodeRequests(requestsJSON, &r.Requests); err != nil {
+			return nil, fmt.Errorf("decode requests: %w", err)
+		}
 		records = append(records, r)
 	}
 
@@ -176,7 +279,7 @@ func (s *SQLiteStore) GetAllRecords(sessionID string) ([]persistence.Record, err
 // GetLiveRecords implements persistence.Store.
 func (s *SQLiteStore) GetLiveRecords(sessionID string) ([]persistence.Record, error) {
 	rows, err := s.db.Query(
-		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp FROM records WHERE session_id = ? AND live = 1 ORDER BY timestamp, id`,
+		`SELECT id, role, contents, live, status, input_tokens, output_tokens, timestamp, user_id, moderation, pinned, options, requests, turn_id FROM records WHERE session_id = ? AND live = 1 ORDER BY timestamp, id`,
 		sessionID,
 	)
 	if err != nil {
@@ -190,7 +293,8 @@ func (s *SQLiteStore) GetLiveRecords(sessionID string) ([]persistence.Record, er
 		var roleStr string
 		var statusStr string
 		var contentsJSON string
-		if err := rows.Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp); err != nil {
+		var moderationJSON, optionsJSON, requestsJSON string
+		if err := rows.Scan(&r.ID, &roleStr, &contentsJSON, &r.Live, &statusStr, &r.InputTokens, &r.OutputTokens, &r.Timestamp, &r.User, &moderationJSON, &r.Pinned, &optionsJSON, &requestsJSON, &r.TurnID); err != nil {
 			return nil, fmt.Errorf("scan record: %w", err)
 		}
 		r.Role = chat.Role(roleStr)
@@ -198,6 +302,15 @@ func (s *SQLiteStore) GetLiveRecords(sessionID string) ([]persistence.Record, er
 		if err := decodeContents(contentsJSON, &r.Contents); err != nil {
 			return nil, fmt.Errorf("decode contents: %w", err)
 		}
+		if err := decodeOptional(moderationJSON, &r.Moderation); err != nil {
+			return nil, fmt.Errorf("decode moderation: %w", err)
+		}
+		if err := decodeOptional(optionsJSON, &r.Options); err != nil {
+			return nil, fmt.Errorf("decode options: %w", err)
+		}
+		if err := decodeRequests(requestsJSON, &r.Requests); err != nil {
+			return nil, fmt.Errorf("decode requests: %w", err)
+		}
 		records = append(records, r)
 	}
 
@@ -214,9 +327,22 @@ func (s *SQLiteStore) UpdateRecord(sessionID string, id int64, record persistenc
 	if err != nil {
 		return fmt.Errorf("encode contents: %w", err)
 	}
+	contents := s.contentsValue(contentsJSON)
+	moderationJSON, err := encodeOptional(record.Moderation)
+	if err != nil {
+		return fmt.Errorf("encode moderation: %w", err)
+	}
+	optionsJSON, err := encodeOptional(record.Options)
+	if err != nil {
+		return fmt.Errorf("encode options: %w", err)
+	}
+	requestsJSON, err := encodeRequests(record.Requests)
+	if err != nil {
+		return fmt.Errorf("encode requests: %w", err)
+	}
 	_, err = s.db.Exec(
-		`UPDATE records SET role = ?, contents = ?, live = ?, status = ?, input_tokens = ?, output_tokens = ?, timestamp = ? WHERE session_id = ? AND id = ?`,
-		string(record.Role), contentsJSON, record.Live, string(record.Status), record.InputTokens, record.OutputTokens, record.Timestamp, sessionID, id,
+		`UPDATE records SET role = ?, contents = ?, live = ?, status = ?, input_tokens = ?, output_tokens = ?, timestamp = ?, user_id = ?, moderation = ?, pinned = ?, options = ?, requests = ?, turn_id = ? WHERE session_id = ? AND id = ?`,
+		string(record.Role), contents, record.Live, string(record.Status), record.InputTokens, record.OutputTokens, record.Timestamp, record.User, moderationJSON, record.Pinned, optionsJSON, requestsJSON, record.TurnID, sessionID, id,
 	)
 	if err != nil {
 		return fmt.Errorf("update record: %w", err)
@@ -251,6 +377,83 @@ func (s *SQLiteStore) DeleteRecord(sessionID string, id int64) error {
 	return nil
 }
 
+// DeleteRecords implements persistence.Store.
+func (s *SQLiteStore) DeleteRecords(sessionID string, ids ...int64) error {
+	return s.ExecInTransaction(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(`DELETE FROM records WHERE session_id = ? AND id = ?`, sessionID, id); err != nil {
+				return fmt.Errorf("delete record %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveArtifact implements persistence.Store.
+func (s *SQLiteStore) SaveArtifact(sessionID string, content string) (int64, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO artifacts (session_id, content, timestamp) VALUES (?, ?, ?)`,
+		sessionID, content, time.Now(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert artifact: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetArtifact implements persistence.Store.
+func (s *SQLiteStore) GetArtifact(sessionID string, id int64) (string, error) {
+	var content string
+	err := s.db.QueryRow(`SELECT content FROM artifacts WHERE session_id = ? AND id = ?`, sessionID, id).Scan(&content)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("artifact not found: %d", id)
+		}
+		return "", fmt.Errorf("query artifact: %w", err)
+	}
+	return content, nil
+}
+
+// ListArtifacts implements persistence.Store.
+func (s *SQLiteStore) ListArtifacts(sessionID string) ([]persistence.Artifact, error) {
+	rows, err := s.db.Query(`SELECT id, content FROM artifacts WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("query artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []persistence.Artifact
+	for rows.Next() {
+		var a persistence.Artifact
+		if err := rows.Scan(&a.ID, &a.Content); err != nil {
+			return nil, fmt.Errorf("scan artifact: %w", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate artifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+// RestoreArtifact implements persistence.Store.
+func (s *SQLiteStore) RestoreArtifact(sessionID string, artifact persistence.Artifact) error {
+	_, err := s.db.Exec(
+		`INSERT INTO artifacts (id, session_id, content, timestamp) VALUES (?, ?, ?, ?)`,
+		artifact.ID, sessionID, artifact.Content, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert artifact %d: %w", artifact.ID, err)
+	}
+	return nil
+}
+
 // Clear implements persistence.Store.
 func (s *SQLiteStore) Clear(sessionID string) error {
 	_, err := s.db.Exec(`DELETE FROM records WHERE session_id = ?`, sessionID)
@@ -258,6 +461,11 @@ func (s *SQLiteStore) Clear(sessionID string) error {
 		return fmt.Errorf("clear records: %w", err)
 	}
 
+	_, err = s.db.Exec(`DELETE FROM artifacts WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return fmt.Errorf("clear artifacts: %w", err)
+	}
+
 	// Reset metrics for this session
 	_, err = s.db.Exec(`DELETE FROM metrics WHERE session_id = ?`, sessionID)
 	if err != nil {
@@ -396,10 +604,74 @@ func (s *SQLiteStore) DeleteSession(sessionID string) error {
 		return fmt.Errorf("delete records: %w", err)
 	}
 
+	// Delete artifacts
+	if _, err := tx.Exec(`DELETE FROM artifacts WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("delete artifacts: %w", err)
+	}
+
 	// Delete metrics
 	if _, err := tx.Exec(`DELETE FROM metrics WHERE session_id = ?`, sessionID); err != nil {
 		return fmt.Errorf("delete metrics: %w", err)
 	}
 
+	// Delete owner, but keep usage so quotas still count it
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("delete owner: %w", err)
+	}
+
 	return tx.Commit()
 }
+
+// SetOwner implements persistence.Store.
+func (s *SQLiteStore) SetOwner(sessionID string, owner string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO sessions (session_id, owner) VALUES (?, ?)
+		ON CONFLICT(session_id) DO UPDATE SET owner = excluded.owner`,
+		sessionID, owner,
+	)
+	if err != nil {
+		return fmt.Errorf("set owner: %w", err)
+	}
+	return nil
+}
+
+// GetOwner implements persistence.Store.
+func (s *SQLiteStore) GetOwner(sessionID string) (string, error) {
+	var owner string
+	err := s.db.QueryRow(`SELECT owner FROM sessions WHERE session_id = ?`, sessionID).Scan(&owner)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("query owner: %w", err)
+	}
+	return owner, nil
+}
+
+// AddUsage implements persistence.Store.
+func (s *SQLiteStore) AddUsage(usage persistence.Usage) error {
+	_, err := s.db.Exec(
+		`INSERT INTO usage (owner, session_id, input_tokens, output_tokens, cost, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+		usage.Owner, usage.SessionID, usage.InputTokens, usage.OutputTokens, usage.Cost, usage.Timestamp.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert usage: %w", err)
+	}
+	return nil
+}
+
+// GetUsage implements persistence.Store.
+func (s *SQLiteStore) GetUsage(owner string, start, end time.Time) (persistence.UsageTotals, error) {
+	query := `SELECT COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost), 0) FROM usage WHERE owner = ? AND timestamp >= ?`
+	args := []any{owner, start.UTC()}
+	if !end.IsZero() {
+		query += ` AND timestamp < ?`
+		args = append(args, end.UTC())
+	}
+
+	var totals persistence.UsageTotals
+	if err := s.db.QueryRow(query, args...).Scan(&totals.InputTokens, &totals.OutputTokens, &totals.Cost); err != nil {
+		return totals, fmt.Errorf("query usage: %w", err)
+	}
+	return totals, nil
+}
@@ -1,8 +1,10 @@
 package sqlitestore
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -190,6 +192,70 @@ func TestSQLiteStoreDelete(t *testing.T) {
 	assert.Equal(t, int64(3), records[1].ID)
 }
 
+func TestSQLiteStoreDeleteRecords(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	sessionID := "test-session"
+
+	for i := 0; i < 5; i++ {
+		_, err := store.AddRecord(sessionID, persistence.Record{
+			Role:      chat.UserRole,
+			Contents:  []chat.Content{{Text: "Message"}},
+			Live:      true,
+			Status:    persistence.RecordStatusSuccess,
+			Timestamp: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+
+	// Unknown IDs are ignored
+	err = store.DeleteRecords(sessionID, 2, 4, 99)
+	require.NoError(t, err)
+
+	records, err := store.GetAllRecords(sessionID)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, int64(3), records[1].ID)
+	assert.Equal(t, int64(5), records[2].ID)
+
+	// Other sessions are untouched
+	_, err = store.AddRecord("other-session", persistence.Record{Role: chat.UserRole, Live: true})
+	require.NoError(t, err)
+	err = store.DeleteRecords(sessionID, 1)
+	require.NoError(t, err)
+	other, err := store.GetAllRecords("other-session")
+	require.NoError(t, err)
+	assert.Len(t, other, 1)
+}
+
+func TestSQLiteStoreThinkingRoundTrip(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	sessionID := "test-session"
+	contents := []chat.Content{
+		{Thinking: &chat.ThinkingContent{Text: "Reasoning", Signature: "sig", Provider: "claude"}},
+		{Thinking: &chat.ThinkingContent{RedactedData: "encrypted", Provider: "claude"}},
+		{Text: "Answer"},
+	}
+	id, err := store.AddRecord(sessionID, persistence.Record{
+		Role:      chat.AssistantRole,
+		Contents:  contents,
+		Live:      true,
+		Status:    persistence.RecordStatusSuccess,
+		Timestamp: time.Now(),
+	})
+	require.NoError(t, err)
+
+	record, err := store.GetRecord(sessionID, id)
+	require.NoError(t, err)
+	assert.Equal(t, contents, record.Contents)
+}
+
 func TestSQLiteStoreClear(t *testing.T) {
 	store, err := New(":memory:")
 	require.NoError(t, err)
@@ -448,3 +514,294 @@ func TestSQLiteStoreMultipleSessions(t *testing.T) {
 	assert.Len(t, sessions, 1)
 	assert.Equal(t, session2, sessions[0])
 }
+
+func TestSQLiteStoreArtifacts(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	id, err := store.SaveArtifact("session1", "large tool output")
+	require.NoError(t, err)
+
+	content, err := store.GetArtifact("session1", id)
+	require.NoError(t, err)
+	assert.Equal(t, "large tool output", content)
+
+	// Artifacts are scoped to their session
+	_, err = store.GetArtifact("session2", id)
+	assert.Error(t, err)
+
+	artifacts, err := store.ListArtifacts("session1")
+	require.NoError(t, err)
+	assert.Equal(t, []persistence.Artifact{{ID: id, Content: "large tool output"}}, artifacts)
+
+	require.NoError(t, store.Clear("session1"))
+	_, err = store.GetArtifact("session1", id)
+	assert.Error(t, err)
+
+	// Restored artifacts keep their IDs
+	require.NoError(t, store.RestoreArtifact("session1", artifacts[0]))
+	content, err = store.GetArtifact("session1", id)
+	require.NoError(t, err)
+	assert.Equal(t, "large tool output", content)
+	assert.Error(t, store.RestoreArtifact("session1", artifacts[0]))
+}
+
+func TestSQLiteStoreOwnersAndUsage(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	owner, err := store.GetOwner("session-1")
+	require.NoError(t, err)
+	assert.Empty(t, owner)
+
+	require.NoError(t, store.SetOwner("session-1", "alice"))
+	require.NoError(t, store.SetOwner("session-2", "bob"))
+	owner, err = store.GetOwner("session-1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, u := range []persistence.Usage{
+		{Owner: "alice", SessionID: "session-1", InputTokens: 100, OutputTokens: 10, Cost: 0.5, Timestamp: start.Add(-time.Hour)},
+		{Owner: "alice", SessionID: "session-1", InputTokens: 200, OutputTokens: 20, Cost: 1, Timestamp: start},
+		{Owner: "alice", SessionID: "session-1", InputTokens: 300, OutputTokens: 30, Cost: 1.5, Timestamp: start.Add(90 * time.Minute)},
+		{Owner: "bob", SessionID: "session-2", InputTokens: 1000, OutputTokens: 100, Timestamp: start},
+	} {
+		require.NoError(t, store.AddUsage(u))
+	}
+
+	totals, err := store.GetUsage("alice", start, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, persistence.UsageTotals{InputTokens: 500, OutputTokens: 50, Cost: 2.5}, totals)
+	assert.Equal(t, 550, totals.TotalTokens())
+
+	totals, err = store.GetUsage("alice", start.Add(-time.Hour), start.Add(90*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, persistence.UsageTotals{InputTokens: 300, OutputTokens: 30, Cost: 1.5}, totals)
+
+	totals, err = store.GetUsage("carol", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, totals)
+
+	// Deleting a session forgets its owner but keeps its usage
+	require.NoError(t, store.DeleteSession("session-1"))
+	owner, err = store.GetOwner("session-1")
+	require.NoError(t, err)
+	assert.Empty(t, owner)
+	totals, err = store.GetUsage("alice", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 660, totals.TotalTokens())
+}
+
+func TestSQLiteStoreRecordUser(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A records table from before user_id was added
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE records (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id    TEXT NOT NULL,
+    role          TEXT NOT NULL,
+    contents      TEXT NOT NULL,
+    live          BOOLEAN NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'success',
+    input_tokens  INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    timestamp     DATETIME NOT NULL
+);
+INSERT INTO records (session_id, role, contents, live, timestamp) VALUES ('test-session', 'user', '[{"text":"Old"}]', 1, '2025-01-01 00:00:00');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store, err := New(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	id, err := store.AddRecord("test-session", persistence.Record{
+		Role:      chat.UserRole,
+		Contents:  []chat.Content{{Text: "New"}},
+		Live:      true,
+		Timestamp: time.Now(),
+		User:      "user-1234",
+	})
+	require.NoError(t, err)
+
+	records, err := store.GetAllRecords("test-session")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Empty(t, records[0].User)
+	assert.Equal(t, "user-1234", records[1].User)
+
+	record, err := store.GetRecord("test-session", id)
+	require.NoError(t, err)
+	record.User = "user-5678"
+	require.NoError(t, store.UpdateRecord("test-session", id, record))
+	record, err = store.GetRecord("test-session", id)
+	require.NoError(t, err)
+	assert.Equal(t, "user-5678", record.User)
+}
+
+func TestSQLiteStoreRecordModeration(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	moderation := &chat.ModerationResult{Flagged: true, Categories: []string{"violence"}, Scores: map[string]float64{"violence": 0.9}}
+	id, err := store.AddRecord("test-session", persistence.Record{
+		Role:       chat.UserRole,
+		Contents:   []chat.Content{{Text: "Flagged"}},
+		Live:       true,
+		Timestamp:  time.Now(),
+		Moderation: moderation,
+	})
+	require.NoError(t, err)
+	_, err = store.AddRecord("test-session", persistence.Record{
+		Role:      chat.AssistantRole,
+		Contents:  []chat.Content{{Text: "Fine"}},
+		Live:      true,
+		Timestamp: time.Now(),
+	})
+	require.NoError(t, err)
+
+	records, err := store.GetLiveRecords("test-session")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, moderation, records[0].Moderation)
+	assert.Nil(t, records[1].Moderation)
+
+	record, err := store.GetRecord("test-session", id)
+	require.NoError(t, err)
+	record.Moderation = nil
+	require.NoError(t, store.UpdateRecord("test-session", id, record))
+	record, err = store.GetRecord("test-session", id)
+	require.NoError(t, err)
+	assert.Nil(t, record.Moderation)
+}
+
+func TestSQLiteStoreRecordMetadata(t *testing.T) {
+	store, err := New(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	id, err := store.AddRecord("test-session", persistence.Record{
+		Role:      chat.UserRole,
+		Contents:  []chat.Content{{Text: "Remember this"}},
+		Live:      true,
+		Pinned:    true,
+		Timestamp: time.Now(),
+		Options:   &persistence.RequestOptions{Model: "test-model", MaxTokens: 100},
+		Requests:  []chat.RequestInfo{{RequestID: "req_1", StatusCode: 529, Latency: time.Second}},
+		TurnID:    "turn-1",
+	})
+	require.NoError(t, err)
+
+	records, err := store.GetLiveRecords("test-session")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Pinned)
+	assert.Equal(t, &persistence.RequestOptions{Model: "test-model", MaxTokens: 100}, records[0].Options)
+	assert.Equal(t, []chat.RequestInfo{{RequestID: "req_1", StatusCode: 529, Latency: time.Second}}, records[0].Requests)
+	assert.Equal(t, "turn-1", records[0].TurnID)
+
+	record, err := store.GetRecord("test-session", id)
+	require.NoError(t, err)
+	record.Pinned = false
+	record.Options = nil
+	record.Requests = nil
+	record.TurnID = "turn-2"
+	require.NoError(t, store.UpdateRecord("test-session", id, record))
+	records, err = store.GetAllRecords("test-session")
+	require.NoError(t, err)
+	assert.False(t, records[0].Pinned)
+	assert.Nil(t, records[0].Options)
+	assert.Nil(t, records[0].Requests)
+	assert.Equal(t, "turn-2", records[0].TurnID)
+}
+
+func TestOpenURL(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "chat.db")
+	store, err := persistence.Open("sqlite://" + dbPath + "?compress=0")
+	require.NoError(t, err)
+	require.IsType(t, &SQLiteStore{}, store)
+	assert.Equal(t, defaultCompressMinSize, store.(*SQLiteStore).compressMinSize)
+	require.NoError(t, store.Close())
+	_, err = os.Stat(dbPath)
+	require.NoError(t, err)
+
+	store, err = persistence.Open("sqlite://:memory:")
+	require.NoError(t, err)
+	assert.Equal(t, 0, store.(*SQLiteStore).compressMinSize)
+	require.NoError(t, store.Close())
+
+	_, err = persistence.Open("sqlite://")
+	assert.ErrorContains(t, err, "database path is required")
+	_, err = persistence.Open("sqlite://chat.db?compress=yes")
+	assert.ErrorContains(t, err, "bad compress")
+}
+
+// benchRecord is an assistant record the size of a typical answer.
+func benchRecord() persistence.Record {
+	return persistence.Record{
+		Role:      chat.AssistantRole,
+		Contents:  []chat.Content{{Text: strings.Repeat("The quick brown fox jumps over the lazy dog. ", 40)}},
+		Live:      true,
+		Status:    persistence.RecordStatusSuccess,
+		Timestamp: time.Now(),
+	}
+}
+
+func BenchmarkAddRecord(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "plain"},
+		{name: "compressed", opts: []Option{WithCompression(0)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			store, err := New(filepath.Join(b.TempDir(), "chat.db"), bc.opts...)
+			require.NoError(b, err)
+			defer store.Close()
+			record := benchRecord()
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := store.AddRecord("session", record); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetLiveRecords measures restoring a 1000-record session.
+func BenchmarkGetLiveRecords(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "plain"},
+		{name: "compressed", opts: []Option{WithCompression(0)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			store, err := New(filepath.Join(b.TempDir(), "chat.db"), bc.opts...)
+			require.NoError(b, err)
+			defer store.Close()
+			record := benchRecord()
+			for range 1000 {
+				_, err := store.AddRecord("session", record)
+				require.NoError(b, err)
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := store.GetLiveRecords("session"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
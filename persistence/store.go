@@ -3,6 +3,8 @@ package persistence
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -28,6 +30,68 @@ type Record struct {
 	InputTokens  int            `json:"inputTokens"`
 	OutputTokens int            `json:"outputTokens"`
 	Timestamp    time.Time      `json:"timestamp"`
+	// User is the end user the exchange was made on behalf of (see chat.WithUser).
+	User string `json:"user,omitzero"`
+	// Moderation is the result of screening a flagged record's text, saved
+	// by sessions using agent.ModerationAnnotate.
+	Moderation *chat.ModerationResult `json:"moderation,omitzero"`
+	// Pinned records are kept in the context window by context policies
+	// that honor pins, such as agent.SlidingWindow (see Session.PinRecord).
+	Pinned bool `json:"pinned,omitzero"`
+	// Options are the request options the exchange was made with, saved on
+	// the user's message record so the turn can be reproduced or analyzed
+	// later.
+	Options *RequestOptions `json:"options,omitzero"`
+	// Requests are the HTTP requests to the LLM provider that produced an
+	// assistant record, including failed attempts that were retried, when
+	// the chat reports them (see chat.RequestReporter). A failed exchange
+	// is saved as a dead user record with status RecordStatusFailed, holding
+	// the exchange's requests.
+	Requests []chat.RequestInfo `json:"requests,omitzero"`
+	// TurnID is the ID of the turn the record was saved in (see
+	// chat.WithTurnID), shared by all the records of an exchange.
+	TurnID string `json:"turnID,omitzero"`
+}
+
+// RequestOptions are the persisted subset of the chat.Options a message was
+// sent with. Options that can't be serialized, like callbacks and
+// validators, aren't kept.
+type RequestOptions struct {
+	// Model is the model the request was sent to, if the chat reports it
+	// (see chat.ModelReporter).
+	Model                string           `json:"model,omitzero"`
+	Temperature          *float64         `json:"temperature,omitzero"`
+	MaxTokens            int              `json:"maxTokens,omitzero"`
+	ReasoningEffort      string           `json:"reasoningEffort,omitzero"`
+	ResponseFormat       *chat.JsonSchema `json:"responseFormat,omitzero"`
+	JSONMode             bool             `json:"jsonMode,omitzero"`
+	AssistantPrefix      string           `json:"assistantPrefix,omitzero"`
+	Candidates           int              `json:"candidates,omitzero"`
+	Prediction           string           `json:"prediction,omitzero"`
+	SystemPromptOverride string           `json:"systemPromptOverride,omitzero"`
+	// ValidationRetries is only set for requests with a validator.
+	ValidationRetries int `json:"validationRetries,omitzero"`
+}
+
+// NewRequestOptions returns the persisted form of opts, for a request sent
+// to model.
+func NewRequestOptions(model string, opts chat.Options) *RequestOptions {
+	ro := &RequestOptions{
+		Model:                model,
+		Temperature:          opts.Temperature,
+		MaxTokens:            opts.MaxTokens,
+		ReasoningEffort:      opts.ReasoningEffort,
+		ResponseFormat:       opts.ResponseFormat,
+		JSONMode:             opts.JSONMode,
+		AssistantPrefix:      opts.AssistantPrefix,
+		Candidates:           opts.Candidates,
+		Prediction:           opts.Prediction,
+		SystemPromptOverride: opts.SystemPromptOverride,
+	}
+	if opts.Validator != nil {
+		ro.ValidationRetries = opts.ValidationRetries
+	}
+	return ro
 }
 
 // GetText concatenates all text content blocks into a single string.
@@ -145,7 +209,27 @@ type Store interface {
 	// DeleteRecord removes a record by ID.
 	DeleteRecord(sessionID string, id int64) error
 
-	// Clear removes all records for a session.
+	// DeleteRecords permanently removes the records with the given IDs.
+	// Unknown IDs are ignored.
+	DeleteRecords(sessionID string, ids ...int64) error
+
+	// SaveArtifact stores content kept out of the context window, such as an
+	// oversized tool result, and returns its ID.
+	SaveArtifact(sessionID string, content string) (int64, error)
+
+	// GetArtifact retrieves an artifact by ID.
+	GetArtifact(sessionID string, id int64) (string, error)
+
+	// ListArtifacts returns a session's artifacts in the order they were
+	// saved.
+	ListArtifacts(sessionID string) ([]Artifact, error)
+
+	// RestoreArtifact stores an artifact under the ID it was listed with,
+	// such as when restoring an archived session, so handles to it in the
+	// session's records still work. It fails if the ID is in use.
+	RestoreArtifact(sessionID string, artifact Artifact) error
+
+	// Clear removes all records and artifacts for a session.
 	Clear(sessionID string) error
 
 	// Close closes the store and releases resources.
@@ -160,8 +244,53 @@ type Store interface {
 	// ListSessions returns all session IDs in the store.
 	ListSessions() ([]string, error)
 
-	// DeleteSession removes all data for a session.
+	// DeleteSession removes all data for a session. Usage recorded for the
+	// session is kept, so deleting sessions doesn't reset quotas.
 	DeleteSession(sessionID string) error
+
+	// SetOwner records the user that owns a session.
+	SetOwner(sessionID string, owner string) error
+
+	// GetOwner returns the user that owns a session, or "" if it has no
+	// recorded owner.
+	GetOwner(sessionID string) (string, error)
+
+	// AddUsage records the token usage and cost of an exchange.
+	AddUsage(usage Usage) error
+
+	// GetUsage totals the usage recorded for owner from start up to, but not
+	// including, end. A zero end means no upper bound.
+	GetUsage(owner string, start, end time.Time) (UsageTotals, error)
+}
+
+// Artifact is content kept out of the context window, such as an oversized
+// tool result.
+type Artifact struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+// Usage is the token usage and cost of one exchange with the LLM, attributed
+// to the owner of the session for quota accounting.
+type Usage struct {
+	Owner        string    `json:"owner"`
+	SessionID    string    `json:"sessionID"`
+	InputTokens  int       `json:"inputTokens"`
+	OutputTokens int       `json:"outputTokens"`
+	Cost         float64   `json:"cost,omitzero"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// UsageTotals sums the usage recorded for an owner over a time window.
+type UsageTotals struct {
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Cost         float64 `json:"cost,omitzero"`
+}
+
+// TotalTokens returns the sum of input and output tokens.
+func (u UsageTotals) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
 }
 
 // SessionMetrics represents session statistics that can be persisted.
@@ -170,13 +299,19 @@ type SessionMetrics struct {
 	LastCompaction      time.Time `json:"lastCompaction"`
 	CumulativeTokens    int       `json:"cumulativeTokens"`
 	CompactionThreshold float64   `json:"compactionThreshold"`
+	// ParentSessionID is set when this session was branched from another session.
+	ParentSessionID string `json:"parentSessionID,omitzero"`
+	// ParentRecordID is the record in the parent session this branch was created at.
+	ParentRecordID int64 `json:"parentRecordID,omitzero"`
 }
 
 // sessionData holds data for a single session
 type sessionData struct {
-	records []Record
-	nextID  int64
-	metrics SessionMetrics
+	records   []Record
+	nextID    int64
+	metrics   SessionMetrics
+	artifacts []string // artifact IDs are 1-based indexes
+	owner     string
 }
 
 func cloneContent(c chat.Content) chat.Content {
@@ -193,17 +328,47 @@ func cloneContent(c chat.Content) chat.Content {
 	}
 	if c.ToolResult != nil {
 		tr := *c.ToolResult
+		if tr.Images != nil {
+			tr.Images = make([]chat.ImageContent, len(c.ToolResult.Images))
+			for i, img := range c.ToolResult.Images {
+				tr.Images[i] = cloneImage(img)
+			}
+		}
 		clone.ToolResult = &tr
 	}
 	if c.Thinking != nil {
 		thinking := *c.Thinking
 		clone.Thinking = &thinking
 	}
+	if c.Image != nil {
+		img := cloneImage(*c.Image)
+		clone.Image = &img
+	}
 	return clone
 }
 
+func cloneImage(img chat.ImageContent) chat.ImageContent {
+	img.Data = slices.Clone(img.Data)
+	return img
+}
+
 func cloneRecord(r Record) Record {
 	clone := r
+	if r.Moderation != nil {
+		moderation := *r.Moderation
+		moderation.Categories = slices.Clone(r.Moderation.Categories)
+		moderation.Scores = maps.Clone(r.Moderation.Scores)
+		clone.Moderation = &moderation
+	}
+	if r.Options != nil {
+		options := *r.Options
+		if r.Options.Temperature != nil {
+			temperature := *r.Options.Temperature
+			options.Temperature = &temperature
+		}
+		clone.Options = &options
+	}
+	clone.Requests = slices.Clone(r.Requests)
 	if len(r.Contents) > 0 {
 		clone.Contents = make([]chat.Content, len(r.Contents))
 		for i, c := range r.Contents {
@@ -217,6 +382,7 @@ func cloneRecord(r Record) Record {
 type MemoryStore struct {
 	mu       sync.Mutex
 	sessions map[string]*sessionData
+	usage    []Usage
 }
 
 // NewMemoryStore creates a new in-memory store.
@@ -258,6 +424,58 @@ func (m *MemoryStore) GetRecord(sessionID string, id int64) (Record, error) {
 	return Record{}, fmt.Errorf("record not found: %d", id)
 }
 
+// SaveArtifact stores an artifact and returns its ID.
+func (m *MemoryStore) SaveArtifact(sessionID string, content string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess := m.getOrCreateSessionLocked(sessionID)
+	sess.artifacts = append(sess.artifacts, content)
+	return int64(len(sess.artifacts)), nil
+}
+
+// GetArtifact retrieves an artifact by ID.
+func (m *MemoryStore) GetArtifact(sessionID string, id int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[sessionID]
+	if !ok || id < 1 || id > int64(len(sess.artifacts)) {
+		return "", fmt.Errorf("artifact not found: %d", id)
+	}
+	return sess.artifacts[id-1], nil
+}
+
+// ListArtifacts returns a session's artifacts in the order they were saved.
+func (m *MemoryStore) ListArtifacts(sessionID string) ([]Artifact, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	artifacts := make([]Artifact, len(sess.artifacts))
+	for i, content := range sess.artifacts {
+		artifacts[i] = Artifact{ID: int64(i + 1), Content: content}
+	}
+	return artifacts, nil
+}
+
+// RestoreArtifact stores an artifact under its ID. MemoryStore numbers a
+// session's artifacts from 1, so they must be restored in order.
+func (m *MemoryStore) RestoreArtifact(sessionID string, artifact Artifact) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess := m.getOrCreateSessionLocked(sessionID)
+	if next := int64(len(sess.artifacts)) + 1; artifact.ID != next {
+		return fmt.Errorf("can't restore artifact %d: the next artifact ID is %d", artifact.ID, next)
+	}
+	sess.artifacts = append(sess.artifacts, artifact.Content)
+	return nil
+}
+
 // getOrCreateSessionLocked gets or creates a session (mutex must be held)
 func (m *MemoryStore) getOrCreateSessionLocked(sessionID string) *sessionData {
 	if sess, ok := m.sessions[sessionID]; ok {
@@ -360,6 +578,27 @@ func (m *MemoryStore) DeleteRecord(sessionID string, id int64) error {
 	return nil
 }
 
+// DeleteRecords permanently removes all records with the given IDs from the store.
+func (m *MemoryStore) DeleteRecords(sessionID string, ids ...int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	toDelete := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		toDelete[id] = true
+	}
+
+	sess := m.getOrCreateSessionLocked(sessionID)
+	kept := sess.records[:0]
+	for _, r := range sess.records {
+		if !toDelete[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	sess.records = kept
+	return nil
+}
+
 // Clear removes all records for a session.
 func (m *MemoryStore) Clear(sessionID string) error {
 	m.mu.Lock()
@@ -369,6 +608,7 @@ func (m *MemoryStore) Clear(sessionID string) error {
 		sess.records = sess.records[:0]
 		sess.nextID = 1
 		sess.metrics = SessionMetrics{}
+		sess.artifacts = nil
 	}
 	return nil
 }
@@ -418,3 +658,49 @@ func (m *MemoryStore) DeleteSession(sessionID string) error {
 	delete(m.sessions, sessionID)
 	return nil
 }
+
+// SetOwner records the user that owns a session.
+func (m *MemoryStore) SetOwner(sessionID string, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.getOrCreateSessionLocked(sessionID).owner = owner
+	return nil
+}
+
+// GetOwner returns the user that owns a session.
+func (m *MemoryStore) GetOwner(sessionID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sess, ok := m.sessions[sessionID]; ok {
+		return sess.owner, nil
+	}
+	return "", nil
+}
+
+// AddUsage records the token usage and cost of an exchange.
+func (m *MemoryStore) AddUsage(usage Usage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage = append(m.usage, usage)
+	return nil
+}
+
+// GetUsage totals the usage recorded for owner in the window [start, end).
+func (m *MemoryStore) GetUsage(owner string, start, end time.Time) (UsageTotals, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var totals UsageTotals
+	for _, u := range m.usage {
+		if u.Owner != owner || u.Timestamp.Before(start) || (!end.IsZero() && !u.Timestamp.Before(end)) {
+			continue
+		}
+		totals.InputTokens += u.InputTokens
+		totals.OutputTokens += u.OutputTokens
+		totals.Cost += u.Cost
+	}
+	return totals, nil
+}
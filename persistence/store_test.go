@@ -0,0 +1,66 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestMemoryStoreImageRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	user := chat.UserMessage("What's in this picture?")
+	user.AddImage(chat.ImageContent{MediaType: "image/png", Data: png})
+	result := chat.Message{Role: chat.ToolRole}
+	result.AddToolResult(chat.ToolResult{
+		ToolCallID: "call_1",
+		Name:       "read_image",
+		Content:    `{"path": "cat.png"}`,
+		Images:     []chat.ImageContent{{MediaType: "image/png", Data: png}},
+	})
+
+	store := NewMemoryStore()
+	for _, msg := range []chat.Message{user, result} {
+		_, err := store.AddRecord("s1", Record{Role: msg.Role, Contents: msg.Contents, Live: true})
+		require.NoError(t, err)
+	}
+	// The store keeps its own copies
+	png[0] = 0
+
+	records, err := store.GetLiveRecords("s1")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	want := []byte("\x89PNG\r\n\x1a\n")
+	require.NotNil(t, records[0].Contents[1].Image)
+	assert.Equal(t, "image/png", records[0].Contents[1].Image.MediaType)
+	assert.Equal(t, want, records[0].Contents[1].Image.Data)
+	require.NotNil(t, records[1].Contents[0].ToolResult)
+	require.Len(t, records[1].Contents[0].ToolResult.Images, 1)
+	assert.Equal(t, want, records[1].Contents[0].ToolResult.Images[0].Data)
+
+	// Nor do readers share them
+	records[0].Contents[1].Image.Data[0] = 0
+	records[1].Contents[0].ToolResult.Images[0].Data[0] = 0
+	records, err = store.GetLiveRecords("s1")
+	require.NoError(t, err)
+	assert.Equal(t, want, records[0].Contents[1].Image.Data)
+	assert.Equal(t, want, records[1].Contents[0].ToolResult.Images[0].Data)
+}
+
+func TestMemoryStoreGetArtifactUnknownSession(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	_, err := store.GetArtifact("missing", 1)
+	assert.ErrorContains(t, err, "artifact not found")
+
+	// Reading doesn't create the session
+	sessions, err := store.ListSessions()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const (
+	// ToolDecisionDeny means a PolicyEvaluator stopped the tool call.
+	ToolDecisionDeny ToolDecision = "deny"
+	// ToolDecisionModify means a PolicyEvaluator rewrote the tool call's
+	// arguments before it ran.
+	ToolDecisionModify ToolDecision = "modify"
+)
+
+// policyDeniedCode is the error code a denied tool call reports to the
+// model.
+const policyDeniedCode = "policy_denied"
+
+// PolicyDecision is a PolicyEvaluator's verdict on a tool call.
+type PolicyDecision struct {
+	// Action is ToolDecisionAllow, ToolDecisionDeny, or ToolDecisionModify.
+	// The zero value allows the call.
+	Action ToolDecision
+	// Reason explains a denial or modification. A denial's reason is sent
+	// to the model as the tool's error.
+	Reason string
+	// Arguments replace the call's arguments when Action is
+	// ToolDecisionModify.
+	Arguments json.RawMessage
+}
+
+// PolicyEvaluator decides whether a tool call may run, before the tool
+// sees it, so security policy can live outside tool handlers. The call's
+// ID may be empty, as tools aren't told the IDs of their calls.
+// Implementations must be safe for concurrent use.
+type PolicyEvaluator interface {
+	EvaluateToolCall(ctx context.Context, call chat.ToolCall) (PolicyDecision, error)
+}
+
+// PolicyEvaluatorFunc adapts a function to a PolicyEvaluator.
+type PolicyEvaluatorFunc func(ctx context.Context, call chat.ToolCall) (PolicyDecision, error)
+
+// EvaluateToolCall implements PolicyEvaluator.
+func (f PolicyEvaluatorFunc) EvaluateToolCall(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+	return f(ctx, call)
+}
+
+// WithPolicyEvaluator checks every call to the session's tools with
+// evaluators, in order, before the tool runs. A denial stops the call and
+// reports the reason to the model as a non-retryable error; a modification
+// passes the rewritten arguments to the evaluators that follow and then the
+// tool. An evaluator that returns an error denies the call. Decisions are
+// recorded by WithToolAuditor.
+func WithPolicyEvaluator(evaluators ...PolicyEvaluator) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.policyEvaluators = append(opts.policyEvaluators, evaluators...)
+	}
+}
+
+// decisionKey is the context key under which auditedTool passes a pointer
+// for guardedTool to record its decision in.
+type decisionKey struct{}
+
+// guardedTool checks each call of the tool it wraps with policy evaluators.
+type guardedTool struct {
+	chat.Tool
+	evaluators  []PolicyEvaluator
+	annotations chat.ToolAnnotations
+}
+
+// evaluate runs the evaluators over a call, returning the input to call
+// the tool with, or the result to return instead if the call is denied.
+func (t *guardedTool) evaluate(ctx context.Context, input string) (string, bool) {
+	decision := ToolDecisionAllow
+	ctx = context.WithValue(ctx, annotationsKey{}, t.annotations)
+	for _, evaluator := range t.evaluators {
+		d, err := evaluator.EvaluateToolCall(ctx, chat.ToolCall{Name: t.Name(), Arguments: json.RawMessage(input)})
+		if err != nil {
+			logger.WarnContext(ctx, "tool policy evaluation failed", "tool", t.Name(), "error", err)
+			d = PolicyDecision{Action: ToolDecisionDeny, Reason: "policy evaluation failed"}
+		}
+		switch d.Action {
+		case ToolDecisionDeny:
+			recordDecision(ctx, ToolDecisionDeny)
+			logger.InfoContext(ctx, "tool call denied by policy", "tool", t.Name(), "reason", d.Reason)
+			return deniedResult(t.Name(), d.Reason), false
+		case ToolDecisionModify:
+			decision = ToolDecisionModify
+			input = string(d.Arguments)
+		}
+	}
+	recordDecision(ctx, decision)
+	return input, true
+}
+
+func (t *guardedTool) Call(ctx context.Context, input string) string {
+	input, ok := t.evaluate(ctx, input)
+	if !ok {
+		return input
+	}
+	return t.Tool.Call(ctx, input)
+}
+
+// CallWithImages checks the call like Call, passing the wrapped tool's
+// images through.
+func (t *guardedTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	it, ok := t.Tool.(chat.ImageTool)
+	if !ok {
+		return t.Call(ctx, input), nil
+	}
+	input, ok = t.evaluate(ctx, input)
+	if !ok {
+		return input, nil
+	}
+	return it.CallWithImages(ctx, input)
+}
+
+func recordDecision(ctx context.Context, decision ToolDecision) {
+	if p, ok := ctx.Value(decisionKey{}).(*ToolDecision); ok {
+		*p = decision
+	}
+}
+
+// deniedResult is the structured tool error reported for a denied call.
+func deniedResult(tool, reason string) string {
+	if reason == "" {
+		reason = "denied by policy"
+	}
+	result, _ := json.Marshal(map[string]any{
+		"error":     fmt.Sprintf("%s call not permitted: %s", tool, reason),
+		"errorCode": policyDeniedCode,
+		"retryable": false,
+	})
+	return string(result)
+}
+
+// PolicyRules is a PolicyEvaluator for simple rules on tool arguments:
+// paths, such as those fstools take, must fall under permitted prefixes,
+// and URLs, such as those a web fetching tool takes, must be on permitted
+// domains. Paths are cleaned before they are checked, and calls with
+// paths that weren't clean are modified to use the cleaned ones.
+type PolicyRules struct {
+	// Tools lists the tools the rules apply to, as path.Match patterns. If
+	// empty, the rules apply to every tool.
+	Tools []string
+	// PathArgs names the top-level string arguments holding paths. If
+	// empty, "path" and "fileName" are checked.
+	PathArgs []string
+	// PathPrefixes lists the directories paths must be in, relative to the
+	// workspace root, such as "src" or "docs/public". If empty, paths
+	// aren't restricted.
+	PathPrefixes []string
+	// URLArgs names the top-level string arguments holding URLs. If empty,
+	// "url" is checked.
+	URLArgs []string
+	// AllowedDomains lists the hosts URLs may point to. "*.example.com"
+	// matches example.com's subdomains but not example.com itself. If
+	// empty, URLs aren't restricted.
+	AllowedDomains []string
+}
+
+var (
+	defaultPathArgs = []string{"path", "fileName"}
+	defaultURLArgs  = []string{"url"}
+)
+
+// EvaluateToolCall implements PolicyEvaluator.
+func (r PolicyRules) EvaluateToolCall(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+	if len(r.Tools) > 0 && !matchAny(r.Tools, call.Name) {
+		return PolicyDecision{Action: ToolDecisionAllow}, nil
+	}
+	var args map[string]any
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		// Let the tool report malformed arguments
+		return PolicyDecision{Action: ToolDecisionAllow}, nil
+	}
+
+	modified := false
+	if len(r.PathPrefixes) > 0 {
+		for _, name := range orDefault(r.PathArgs, defaultPathArgs) {
+			p, ok := args[name].(string)
+			if !ok {
+				continue
+			}
+			cleaned := cleanPath(p)
+			if !r.pathAllowed(cleaned) {
+				return PolicyDecision{Action: ToolDecisionDeny, Reason: fmt.Sprintf("path %q is outside the permitted directories", p)}, nil
+			}
+			if cleaned != p {
+				args[name] = cleaned
+				modified = true
+			}
+		}
+	}
+	if len(r.AllowedDomains) > 0 {
+		for _, name := range orDefault(r.URLArgs, defaultURLArgs) {
+			raw, ok := args[name].(string)
+			if !ok {
+				continue
+			}
+			u, err := url.Parse(raw)
+			if err != nil || !r.domainAllowed(u.Hostname()) {
+				return PolicyDecision{Action: ToolDecisionDeny, Reason: fmt.Sprintf("URL %q is not on a permitted domain", raw)}, nil
+			}
+		}
+	}
+
+	if !modified {
+		return PolicyDecision{Action: ToolDecisionAllow}, nil
+	}
+	rewritten, err := json.Marshal(args)
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("failed to marshal rewritten arguments: %w", err)
+	}
+	return PolicyDecision{Action: ToolDecisionModify, Reason: "cleaned paths", Arguments: rewritten}, nil
+}
+
+// cleanPath cleans p as fstools do, relative to the workspace root.
+func cleanPath(p string) string {
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (r PolicyRules) pathAllowed(p string) bool {
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return false
+	}
+	for _, prefix := range r.PathPrefixes {
+		prefix = cleanPath(prefix)
+		if prefix == "." || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PolicyRules) domainAllowed(host string) bool {
+	host = strings.ToLower(host)
+	if host == "" {
+		return false
+	}
+	return slices.ContainsFunc(r.AllowedDomains, func(domain string) bool {
+		domain = strings.ToLower(domain)
+		if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+			return strings.HasSuffix(host, "."+suffix)
+		}
+		return host == domain
+	})
+}
+
+// orDefault returns s, or def if s is empty.
+func orDefault(s, def []string) []string {
+	if len(s) == 0 {
+		return def
+	}
+	return s
+}
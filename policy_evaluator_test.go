@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestPolicyRules(t *testing.T) {
+	t.Parallel()
+
+	rules := PolicyRules{
+		Tools:          []string{"Read*", "Write*", "fetch"},
+		PathPrefixes:   []string{"src", "/docs/public"},
+		AllowedDomains: []string{"example.com", "*.golang.org"},
+	}
+
+	tests := []struct {
+		name   string
+		tool   string
+		args   string
+		action ToolDecision
+		want   string // rewritten arguments, for ToolDecisionModify
+	}{
+		{name: "path under prefix", tool: "ReadFile", args: `{"fileName": "src/main.go"}`, action: ToolDecisionAllow},
+		{name: "prefix itself", tool: "ReadDir", args: `{"path": "docs/public"}`, action: ToolDecisionAllow},
+		{name: "path outside prefixes", tool: "ReadFile", args: `{"fileName": "secrets/key.pem"}`, action: ToolDecisionDeny},
+		{name: "prefix of a name", tool: "ReadFile", args: `{"fileName": "srcs/main.go"}`, action: ToolDecisionDeny},
+		{name: "escaping the root", tool: "ReadFile", args: `{"fileName": "src/../../etc/passwd"}`, action: ToolDecisionDeny},
+		{name: "unclean path", tool: "WriteFile", args: `{"fileName": "/src/./gen/../main.go", "content": "x"}`, action: ToolDecisionModify, want: `{"content": "x", "fileName": "src/main.go"}`},
+		{name: "unclean path escaping a prefix", tool: "ReadFile", args: `{"fileName": "src/../secrets"}`, action: ToolDecisionDeny},
+		{name: "allowed domain", tool: "fetch", args: `{"url": "https://example.com/a"}`, action: ToolDecisionAllow},
+		{name: "allowed subdomain", tool: "fetch", args: `{"url": "https://pkg.go.golang.org/x"}`, action: ToolDecisionAllow},
+		{name: "wildcard excludes the domain itself", tool: "fetch", args: `{"url": "https://golang.org"}`, action: ToolDecisionDeny},
+		{name: "other domain", tool: "fetch", args: `{"url": "https://evil.example.net/"}`, action: ToolDecisionDeny},
+		{name: "lookalike domain", tool: "fetch", args: `{"url": "https://notexample.com/"}`, action: ToolDecisionDeny},
+		{name: "relative URL", tool: "fetch", args: `{"url": "/etc/passwd"}`, action: ToolDecisionDeny},
+		{name: "tool outside the rules", tool: "run_command", args: `{"path": "/etc"}`, action: ToolDecisionAllow},
+		{name: "malformed arguments are left to the tool", tool: "ReadFile", args: `{"fileName": `, action: ToolDecisionAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d, err := rules.EvaluateToolCall(context.Background(), chat.ToolCall{Name: tt.tool, Arguments: json.RawMessage(tt.args)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.action, d.Action)
+			if tt.action == ToolDecisionDeny {
+				assert.NotEmpty(t, d.Reason)
+			}
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, string(d.Arguments))
+			}
+		})
+	}
+}
+
+func TestSessionPolicyEvaluator(t *testing.T) {
+	var log strings.Builder
+	var inputs []string
+	client := &mockClient{}
+	failing := PolicyEvaluatorFunc(func(ctx context.Context, call chat.ToolCall) (PolicyDecision, error) {
+		if strings.Contains(string(call.Arguments), "boom") {
+			return PolicyDecision{}, errors.New("policy server unavailable")
+		}
+		return PolicyDecision{}, nil
+	})
+	session, err := NewSession(client, "System",
+		WithPolicyEvaluator(PolicyRules{PathPrefixes: []string{"src"}}, failing),
+		WithToolAuditor(NewJSONAuditor(&log)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, session.RegisterTool(&mockTool{
+		name:   "ReadFile",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			inputs = append(inputs, input)
+			return `{"content": "package main"}`
+		},
+	}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+	call := client.chats[len(client.chats)-1].tools["ReadFile"]
+
+	assert.Equal(t, `{"content": "package main"}`, call(context.Background(), `{"fileName": "src/main.go"}`))
+
+	// Denied calls never reach the tool, and tell the model why
+	var denied map[string]any
+	require.NoError(t, json.Unmarshal([]byte(call(context.Background(), `{"fileName": "etc/passwd"}`)), &denied))
+	assert.Equal(t, "policy_denied", denied["errorCode"])
+	assert.Equal(t, false, denied["retryable"])
+	assert.Contains(t, denied["error"], "etc/passwd")
+
+	// Modified arguments are what the tool sees
+	call(context.Background(), `{"fileName": "./src/util.go"}`)
+
+	// Evaluators that fail deny the call
+	require.NoError(t, json.Unmarshal([]byte(call(context.Background(), `{"fileName": "src/boom.go"}`)), &denied))
+	assert.Equal(t, "policy_denied", denied["errorCode"])
+
+	assert.Equal(t, []string{`{"fileName": "src/main.go"}`, `{"fileName":"src/util.go"}`}, inputs)
+
+	var decisions []ToolDecision
+	for _, entry := range readAuditEntries(t, log.String()) {
+		decisions = append(decisions, entry.Decision)
+	}
+	assert.Equal(t, []ToolDecision{ToolDecisionAllow, ToolDecisionDeny, ToolDecisionModify, ToolDecisionDeny}, decisions)
+}
@@ -0,0 +1,236 @@
+package remotetool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ErrClosed is returned for calls made on, or interrupted by, a closed
+// connection.
+var ErrClosed = errors.New("remotetool: connection closed")
+
+// Client calls tools served by a Server. It is safe for concurrent use.
+type Client struct {
+	conn io.ReadWriteCloser
+
+	encMu sync.Mutex
+	enc   *json.Encoder
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingCall
+	// err is why the connection ended, once it has
+	err  error
+	done chan struct{}
+}
+
+// pendingCall is a request awaiting its response.
+type pendingCall struct {
+	ctx  context.Context
+	resp chan message
+}
+
+// NewClient returns a client that talks to a Server over conn, which it
+// takes ownership of.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		pending: make(map[int64]*pendingCall),
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c
+}
+
+// Dial connects to a Server at address, as with net.Dialer.DialContext.
+func Dial(ctx context.Context, network, address string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tool server: %w", err)
+	}
+	return NewClient(conn), nil
+}
+
+// Close closes the connection. Calls still running fail with ErrClosed,
+// and the server cancels them.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// read dispatches the messages read from the connection until it fails.
+func (c *Client) read() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(nil, maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			c.fail(fmt.Errorf("failed to parse message: %w", err))
+			return
+		}
+		if msg.Method == "progress" {
+			var params progressParams
+			if json.Unmarshal(msg.Params, &params) != nil {
+				continue
+			}
+			c.mu.Lock()
+			p, ok := c.pending[params.ID]
+			c.mu.Unlock()
+			if ok {
+				ReportProgress(p.ctx, params.Message)
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		p, ok := c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		if ok {
+			p.resp <- msg
+		}
+	}
+	err := scanner.Err()
+	if err == nil || errors.Is(err, net.ErrClosed) {
+		err = ErrClosed
+	}
+	c.fail(err)
+}
+
+// fail ends the connection with err, failing any pending calls.
+func (c *Client) fail(err error) {
+	c.conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+// call sends a request and waits for its response, decoding its result
+// into result. If ctx is done first, the server is told to cancel it.
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	p := &pendingCall{ctx: ctx, resp: make(chan message, 1)}
+	c.pending[id] = p
+	c.mu.Unlock()
+
+	if err := c.write(message{JSONRPC: "2.0", ID: id, Method: method, Params: data}); err != nil {
+		c.forget(id)
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	select {
+	case msg := <-p.resp:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("failed to parse result: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		cancel, _ := json.Marshal(cancelParams{ID: id})
+		c.write(message{JSONRPC: "2.0", Method: "cancel", Params: cancel})
+		return ctx.Err()
+	case <-c.done:
+		c.forget(id)
+		return c.err
+	}
+}
+
+func (c *Client) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *Client) write(msg message) error {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	return c.enc.Encode(msg)
+}
+
+// Tools returns the tools the server serves, which call the server when
+// they are called. Their failures, such as a lost connection, are
+// reported to the model as retryable tool errors.
+func (c *Client) Tools(ctx context.Context) ([]chat.Tool, error) {
+	var result listResult
+	if err := c.call(ctx, "tools/list", struct{}{}, &result); err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	tools := make([]chat.Tool, 0, len(result.Tools))
+	for _, schema := range result.Tools {
+		var def struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+		if err := json.Unmarshal(schema, &def); err != nil || def.Name == "" {
+			return nil, fmt.Errorf("server sent an invalid tool schema: %s", schema)
+		}
+		tools = append(tools, &remoteTool{
+			client:      c,
+			name:        def.Name,
+			description: def.Description,
+			schema:      string(schema),
+		})
+	}
+	return tools, nil
+}
+
+// remoteTool is a tool served by the other end of a Client's connection.
+type remoteTool struct {
+	client      *Client
+	name        string
+	description string
+	schema      string
+}
+
+func (t *remoteTool) Name() string          { return t.name }
+func (t *remoteTool) Description() string   { return t.description }
+func (t *remoteTool) MCPJsonSchema() string { return t.schema }
+
+func (t *remoteTool) Call(ctx context.Context, input string) string {
+	output, _ := t.CallWithImages(ctx, input)
+	return output
+}
+
+// CallWithImages calls the tool on the server, passing back any images it
+// returns.
+func (t *remoteTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	var result callResult
+	if err := t.client.call(ctx, "tools/call", callParams{Name: t.name, Input: input}, &result); err != nil {
+		output, _ := json.Marshal(map[string]any{
+			"error":     fmt.Sprintf("%s failed on its remote host: %s", t.name, err),
+			"errorCode": ErrorCode,
+			"retryable": true,
+		})
+		return string(output), nil
+	}
+	return result.Output, result.Images
+}
@@ -0,0 +1,126 @@
+// Package remotetool runs tools on a different host than the agent, such
+// as tools that must run inside a customer's network, over a small
+// JSON-RPC 2.0 protocol.
+//
+// The host with the tools runs a Server:
+//
+//	server := remotetool.NewServer(fstools.ReadFileTool, fstools.ReadDirTool)
+//	l, err := net.Listen("tcp", ":7300")
+//	...
+//	err = server.Serve(ctx, l)
+//
+// and the agent connects to it and registers the tools it serves:
+//
+//	client, err := remotetool.Dial(ctx, "tcp", "tools.internal:7300")
+//	...
+//	tools, err := client.Tools(ctx)
+//	...
+//	session, err := agent.NewSession(llmClient, prompt, agent.WithTools(tools...))
+//
+// The protocol has no authentication or encryption of its own, so
+// connections should be made over a network that provides them, for
+// example with crypto/tls.
+//
+// # Protocol
+//
+// Each message is a JSON-RPC 2.0 object on its own line. Requests, sent
+// by the client, have integer IDs:
+//
+//   - tools/list takes no params and returns {"tools": [...]}, holding
+//     each tool's MCPJsonSchema.
+//   - tools/call takes {"name", "input"}, where input is the tool's JSON
+//     arguments as a string, and returns {"output", "images"}.
+//
+// Calls run concurrently, and their responses may arrive in any order.
+// Notifications have no ID:
+//
+//   - progress, from the server, has params {"id", "message"}, reporting
+//     on the running call with that ID.
+//   - cancel, from the client, has params {"id"}, and cancels the
+//     context of the running call with that ID.
+package remotetool
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/internal/logging"
+)
+
+var logger = logging.Logger().With("component", "remotetool")
+
+// ErrorCode is the error code reported to the model when a remote tool
+// call fails, for example because the connection was lost.
+const ErrorCode = "remote_tool_failed"
+
+const (
+	errMethodNotFound = -32601
+	errInvalidParams  = -32602
+	errInternal       = -32603
+)
+
+// message is any message of the protocol: a request, response, or
+// notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id,omitzero"`
+	Method  string          `json:"method,omitzero"`
+	Params  json.RawMessage `json:"params,omitzero"`
+	Result  json.RawMessage `json:"result,omitzero"`
+	Error   *rpcError       `json:"error,omitzero"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type listResult struct {
+	Tools []json.RawMessage `json:"tools"`
+}
+
+type callParams struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+}
+
+type callResult struct {
+	Output string              `json:"output"`
+	Images []chat.ImageContent `json:"images,omitzero"`
+}
+
+type progressParams struct {
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+}
+
+type cancelParams struct {
+	ID int64 `json:"id"`
+}
+
+// progressKey is the context key for the function ReportProgress calls.
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls report progress to fn.
+// On the server, a call's context reports progress to its client; on the
+// client, progress reported by the server for a remote tool call is passed
+// to the fn of the context the call was made with. fn is called from the
+// connection's reader, so it should return quickly.
+func WithProgress(ctx context.Context, fn func(message string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress reports progress on a long-running tool call, such as
+// "scanned 1200 of 5000 files", to the function set with WithProgress, if
+// there is one. Tools served by a Server call it to keep the agent
+// informed.
+func ReportProgress(ctx context.Context, message string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(string)); ok && fn != nil {
+		fn(message)
+	}
+}
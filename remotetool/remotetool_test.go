@@ -0,0 +1,207 @@
+package remotetool
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+type testTool struct {
+	name   string
+	callFn func(ctx context.Context, input string) string
+}
+
+func (t *testTool) Name() string        { return t.name }
+func (t *testTool) Description() string { return "A " + t.name + " tool" }
+func (t *testTool) MCPJsonSchema() string {
+	return `{"name":"` + t.name + `","description":"A ` + t.name + ` tool","inputSchema":{"type":"object"}}`
+}
+
+func (t *testTool) Call(ctx context.Context, input string) string {
+	return t.callFn(ctx, input)
+}
+
+type imageTool struct {
+	testTool
+	image chat.ImageContent
+}
+
+func (t *imageTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	return `{"ok": true}`, []chat.ImageContent{t.image}
+}
+
+// connect serves tools over an in-memory connection, returning a client
+// for them.
+func connect(t *testing.T, tools ...chat.Tool) *Client {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- NewServer(tools...).ServeConn(context.Background(), serverConn) }()
+	client := NewClient(clientConn)
+	t.Cleanup(func() {
+		client.Close()
+		assert.NoError(t, <-served)
+	})
+	return client
+}
+
+func toolsByName(t *testing.T, client *Client) map[string]chat.Tool {
+	t.Helper()
+	tools, err := client.Tools(context.Background())
+	require.NoError(t, err)
+	byName := make(map[string]chat.Tool)
+	for _, tool := range tools {
+		byName[tool.Name()] = tool
+	}
+	return byName
+}
+
+func decodeError(t *testing.T, output string) map[string]any {
+	t.Helper()
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	return result
+}
+
+func TestRemoteTools(t *testing.T) {
+	t.Parallel()
+
+	img := chat.ImageContent{MediaType: "image/png", Data: []byte("png")}
+	client := connect(t,
+		&testTool{name: "echo", callFn: func(ctx context.Context, input string) string { return input }},
+		&imageTool{testTool: testTool{name: "screenshot"}, image: img},
+		&testTool{name: "panics", callFn: func(ctx context.Context, input string) string { panic("boom") }},
+	)
+
+	tools, err := client.Tools(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tools, 3)
+	echo := tools[0]
+	assert.Equal(t, "echo", echo.Name())
+	assert.Equal(t, "A echo tool", echo.Description())
+	assert.JSONEq(t, `{"name":"echo","description":"A echo tool","inputSchema":{"type":"object"}}`, echo.MCPJsonSchema())
+
+	// Concurrent calls each get their own result
+	var wg sync.WaitGroup
+	for _, input := range []string{`{"a": 1}`, `{"b": 2}`, `{"c": 3}`} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, input, echo.Call(context.Background(), input))
+		}()
+	}
+	wg.Wait()
+
+	output, images := tools[1].(chat.ImageTool).CallWithImages(context.Background(), "{}")
+	assert.Equal(t, `{"ok": true}`, output)
+	assert.Equal(t, []chat.ImageContent{img}, images)
+
+	failed := decodeError(t, tools[2].Call(context.Background(), "{}"))
+	assert.Equal(t, ErrorCode, failed["errorCode"])
+	assert.Contains(t, failed["error"], "tool panicked: boom")
+}
+
+func TestRemoteToolProgress(t *testing.T) {
+	t.Parallel()
+
+	client := connect(t, &testTool{name: "scan", callFn: func(ctx context.Context, input string) string {
+		ReportProgress(ctx, "1 of 2")
+		ReportProgress(ctx, "2 of 2")
+		return `{"done": true}`
+	}})
+
+	var progress []string
+	ctx := WithProgress(context.Background(), func(message string) {
+		progress = append(progress, message)
+	})
+	assert.Equal(t, `{"done": true}`, toolsByName(t, client)["scan"].Call(ctx, "{}"))
+	assert.Equal(t, []string{"1 of 2", "2 of 2"}, progress)
+}
+
+func TestRemoteToolCancel(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	client := connect(t, &testTool{name: "slow", callFn: func(ctx context.Context, input string) string {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return `{}`
+	}})
+	slow := toolsByName(t, client)["slow"]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	failed := decodeError(t, slow.Call(ctx, "{}"))
+	assert.Contains(t, failed["error"], "context canceled")
+
+	// The server cancels the call's context too
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the remote call wasn't canceled")
+	}
+}
+
+func TestRemoteToolConnectionLost(t *testing.T) {
+	t.Parallel()
+
+	serverConn, clientConn := net.Pipe()
+	started := make(chan struct{})
+	server := NewServer(&testTool{name: "hang", callFn: func(ctx context.Context, input string) string {
+		close(started)
+		<-ctx.Done()
+		return `{}`
+	}})
+	served := make(chan error, 1)
+	go func() { served <- server.ServeConn(context.Background(), serverConn) }()
+
+	client := NewClient(clientConn)
+	defer client.Close()
+	hang := toolsByName(t, client)["hang"]
+
+	go func() {
+		<-started
+		serverConn.Close()
+	}()
+	failed := decodeError(t, hang.Call(context.Background(), "{}"))
+	assert.Equal(t, ErrorCode, failed["errorCode"])
+	assert.Equal(t, true, failed["retryable"])
+	assert.Contains(t, failed["error"], ErrClosed.Error())
+	<-served
+
+	_, err := client.Tools(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(&testTool{name: "echo", callFn: func(ctx context.Context, input string) string { return input }}).Serve(ctx, l)
+	}()
+
+	client, err := Dial(context.Background(), "tcp", l.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, `{"x": 1}`, toolsByName(t, client)["echo"].Call(context.Background(), `{"x": 1}`))
+	require.NoError(t, client.Close())
+
+	cancel()
+	assert.ErrorIs(t, <-served, context.Canceled)
+}
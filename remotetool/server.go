@@ -0,0 +1,214 @@
+package remotetool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// maxMessageSize is the largest message either side reads.
+const maxMessageSize = 64 << 20
+
+// Server serves tools to remote clients. It is safe for concurrent use,
+// and serves any number of connections at once.
+type Server struct {
+	tools []chat.Tool
+}
+
+// NewServer returns a server for tools.
+func NewServer(tools ...chat.Tool) *Server {
+	return &Server{tools: tools}
+}
+
+// Serve accepts connections on l and serves each in its own goroutine,
+// until ctx is done or accepting fails. It closes l before returning.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ServeConn(ctx, conn); err != nil {
+				logger.WarnContext(ctx, "remote tool connection failed", "remote", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
+
+// ServeConn serves requests read from conn until the client closes it or
+// ctx is done, then cancels any calls still running, waits for them, and
+// closes conn. Tools are called with a context derived from ctx, so
+// context values such as the workspace can be set on it.
+func (s *Server) ServeConn(ctx context.Context, conn io.ReadWriteCloser) error {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &serverConn{
+		server:  s,
+		enc:     json.NewEncoder(conn),
+		running: make(map[int64]context.CancelFunc),
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		stop()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return fmt.Errorf("failed to parse message: %w", err)
+		}
+		c.handle(ctx, msg)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+	return nil
+}
+
+// serverConn is the state of one connection a Server is serving.
+type serverConn struct {
+	server *Server
+	wg     sync.WaitGroup
+
+	encMu sync.Mutex
+	enc   *json.Encoder
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+}
+
+func (c *serverConn) handle(ctx context.Context, msg message) {
+	switch {
+	case msg.Method == "cancel":
+		var params cancelParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return
+		}
+		c.mu.Lock()
+		if cancel, ok := c.running[params.ID]; ok {
+			cancel()
+		}
+		c.mu.Unlock()
+	case msg.ID == 0:
+		// Other notifications aren't part of the protocol
+	case msg.Method == "tools/list":
+		var result listResult
+		for _, tool := range c.server.tools {
+			result.Tools = append(result.Tools, json.RawMessage(tool.MCPJsonSchema()))
+		}
+		c.respond(msg.ID, result, nil)
+	case msg.Method == "tools/call":
+		var params callParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.respond(msg.ID, nil, &rpcError{Code: errInvalidParams, Message: err.Error()})
+			return
+		}
+		tool := c.server.tool(params.Name)
+		if tool == nil {
+			c.respond(msg.ID, nil, &rpcError{Code: errInvalidParams, Message: fmt.Sprintf("tool %q isn't served", params.Name)})
+			return
+		}
+
+		callCtx, cancel := context.WithCancel(ctx)
+		callCtx = WithProgress(callCtx, func(message string) {
+			c.notify("progress", progressParams{ID: msg.ID, Message: message})
+		})
+		c.mu.Lock()
+		c.running[msg.ID] = cancel
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer func() {
+				c.mu.Lock()
+				delete(c.running, msg.ID)
+				c.mu.Unlock()
+				cancel()
+			}()
+			result, err := call(callCtx, tool, params.Input)
+			c.respond(msg.ID, result, err)
+		}()
+	default:
+		c.respond(msg.ID, nil, &rpcError{Code: errMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)})
+	}
+}
+
+// call runs a tool, reporting a panic as an error.
+func call(ctx context.Context, tool chat.Tool, input string) (result callResult, err *rpcError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &rpcError{Code: errInternal, Message: fmt.Sprintf("tool panicked: %v", r)}
+		}
+	}()
+	if it, ok := tool.(chat.ImageTool); ok {
+		result.Output, result.Images = it.CallWithImages(ctx, input)
+	} else {
+		result.Output = tool.Call(ctx, input)
+	}
+	return result, nil
+}
+
+func (s *Server) tool(name string) chat.Tool {
+	for _, tool := range s.tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+func (c *serverConn) respond(id int64, result any, rpcErr *rpcError) {
+	msg := message{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &rpcError{Code: errInternal, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	c.write(msg)
+}
+
+func (c *serverConn) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	c.write(message{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+// write sends msg, dropping it if the connection has failed: the read
+// loop notices and ends the connection.
+func (c *serverConn) write(msg message) {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	c.enc.Encode(msg)
+}
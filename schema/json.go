@@ -5,9 +5,13 @@ const URL = "http://json-schema.org/draft-07/schema#"
 type Type string
 
 const (
-	String Type = "string"
-	Array  Type = "array"
-	Object Type = "object"
+	String  Type = "string"
+	Integer Type = "integer"
+	Number  Type = "number"
+	Boolean Type = "boolean"
+	Array   Type = "array"
+	Object  Type = "object"
+	Null    Type = "null"
 )
 
 // JSON is a way to describe a JSON Schema
@@ -16,7 +20,8 @@ type JSON struct {
 	Description          string           `json:"description,omitzero"`
 	Properties           map[string]*JSON `json:"properties,omitzero"`
 	Items                *JSON            `json:"items,omitzero"`
-	Enum                 []string         `json:"enum,omitzero"`
+	Enum                 []any            `json:"enum,omitzero"`  // Allowed values, of any JSON type
+	Const                any              `json:"const,omitzero"` // The only allowed value
 	Required             []string         `json:"required,omitzero"`
 	AdditionalProperties *bool            `json:"additionalProperties,omitzero"`
 	Schema               string           `json:"$schema,omitzero"`
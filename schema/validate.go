@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ValidationError describes where and how a value fails to match a schema.
+type ValidationError struct {
+	// Path locates the value in the document, like "$.items[2].name".
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate reports whether data, a JSON document, matches the schema. It
+// checks types, enum and const values, required and additional properties,
+// array items, and allOf/anyOf/oneOf; other keywords are ignored. A
+// mismatch is returned as a *ValidationError.
+func (s *JSON) Validate(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return s.ValidateValue(v)
+}
+
+// ValidateValue is like Validate, for a value decoded from JSON into an
+// any by encoding/json.
+func (s *JSON) ValidateValue(v any) error {
+	return s.validate("$", v)
+}
+
+func (s *JSON) validate(path string, v any) error {
+	if s == nil {
+		return nil
+	}
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if types := s.typeNames(); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(v, t) }) {
+		return fail("expected %s, got %s", strings.Join(types, " or "), typeOf(v))
+	}
+	if s.Const != nil && !equalValues(s.Const, v) {
+		return fail("must be %s", formatValue(s.Const))
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equalValues(e, v) }) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = formatValue(e)
+		}
+		return fail("%s is not one of %s", formatValue(v), strings.Join(allowed, ", "))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fail("unexpected property %q", name)
+				}
+				continue
+			}
+			if err := prop.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		if err := sub.validate(path, v); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		if !slices.ContainsFunc(s.AnyOf, func(sub *JSON) bool { return sub.validate(path, v) == nil }) {
+			return fail("doesn't match any allowed schema")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if sub.validate(path, v) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fail("must match exactly one allowed schema, matches %d", matches)
+		}
+	}
+	return nil
+}
+
+// typeNames returns the names of the types s allows, or nil if it allows
+// any.
+func (s *JSON) typeNames() []string {
+	switch t := s.Type.(type) {
+	case Type:
+		return []string{string(t)}
+	case string:
+		return []string{t}
+	case []Type:
+		names := make([]string, len(t))
+		for i, name := range t {
+			names[i] = string(name)
+		}
+		return names
+	case []any:
+		var names []string
+		for _, name := range t {
+			switch name := name.(type) {
+			case Type:
+				names = append(names, string(name))
+			case string:
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case string(String):
+		_, ok := v.(string)
+		return ok
+	case string(Integer):
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case string(Number):
+		_, ok := v.(float64)
+		return ok
+	case string(Boolean):
+		_, ok := v.(bool)
+		return ok
+	case string(Array):
+		_, ok := v.([]any)
+		return ok
+	case string(Object):
+		_, ok := v.(map[string]any)
+		return ok
+	case string(Null):
+		return v == nil
+	}
+	// Unknown types aren't checked
+	return true
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case string:
+		return string(String)
+	case float64:
+		return string(Number)
+	case bool:
+		return string(Boolean)
+	case []any:
+		return string(Array)
+	case map[string]any:
+		return string(Object)
+	case nil:
+		return string(Null)
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equalValues reports whether a schema value, which may be any Go value
+// that encodes to JSON, equals v, a decoded JSON value.
+func equalValues(want, v any) bool {
+	data, err := json.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalized, v)
+}
+
+func formatValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	s := &JSON{
+		Type: Object,
+		Properties: map[string]*JSON{
+			"level":    {Type: String, Enum: []any{"info", "warning", "error"}},
+			"priority": {Type: Integer, Enum: []any{1, 2, 3}},
+			"ratio":    {Type: Number, Enum: []any{0.5, 1.5}},
+			"kind":     {Const: "event"},
+			"version":  {Type: []any{"integer", "null"}, Const: 2},
+			"tags":     {Type: Array, Items: &JSON{Type: String}},
+			"note":     {AnyOf: []*JSON{{Type: String}, {Type: Null}}},
+		},
+		Required:             []string{"level", "kind"},
+		AdditionalProperties: boolPtr(false),
+	}
+
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{name: "valid", doc: `{"level": "info", "priority": 2, "ratio": 1.5, "kind": "event", "version": 2, "tags": ["a"], "note": null}`},
+		{name: "string enum", doc: `{"level": "debug", "kind": "event"}`, wantErr: `$.level: "debug" is not one of "info", "warning", "error"`},
+		{name: "integer enum", doc: `{"level": "info", "kind": "event", "priority": 4}`, wantErr: "$.priority: 4 is not one of 1, 2, 3"},
+		{name: "integer type", doc: `{"level": "info", "kind": "event", "priority": 1.5}`, wantErr: "$.priority: expected integer, got number"},
+		{name: "number enum", doc: `{"level": "info", "kind": "event", "ratio": 1}`, wantErr: "$.ratio: 1 is not one of 0.5, 1.5"},
+		{name: "const", doc: `{"level": "info", "kind": "other"}`, wantErr: `$.kind: must be "event"`},
+		{name: "numeric const", doc: `{"level": "info", "kind": "event", "version": 3}`, wantErr: "$.version: must be 2"},
+		{name: "required", doc: `{"kind": "event"}`, wantErr: `$: missing required property "level"`},
+		{name: "additional", doc: `{"level": "info", "kind": "event", "extra": true}`, wantErr: `$: unexpected property "extra"`},
+		{name: "items", doc: `{"level": "info", "kind": "event", "tags": ["a", 1]}`, wantErr: "$.tags[1]: expected string, got number"},
+		{name: "anyOf", doc: `{"level": "info", "kind": "event", "note": 1}`, wantErr: "$.note: doesn't match any allowed schema"},
+		{name: "not an object", doc: `[]`, wantErr: "$: expected object, got array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := s.Validate([]byte(tt.doc))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	assert.ErrorContains(t, s.Validate([]byte("{")), "invalid JSON")
+	assert.NoError(t, (*JSON)(nil).Validate([]byte(`"anything"`)))
+}
+
+func TestValidateOneOf(t *testing.T) {
+	t.Parallel()
+
+	s := &JSON{OneOf: []*JSON{{Type: Integer}, {Type: Number}}}
+	assert.NoError(t, s.Validate([]byte("1.5")))
+	assert.EqualError(t, s.Validate([]byte("1")), "$: must match exactly one allowed schema, matches 2")
+}
+
+func TestEnumConstRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	s := &JSON{
+		Type: Object,
+		Properties: map[string]*JSON{
+			"priority": {Type: Integer, Enum: []any{1, 2}},
+			"zero":     {Type: Integer, Const: 0},
+		},
+	}
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"priority": {"type": "integer", "enum": [1, 2]}, "zero": {"type": "integer", "const": 0}}}`, string(data))
+
+	var decoded JSON
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.NoError(t, decoded.Validate([]byte(`{"priority": 2, "zero": 0}`)))
+	assert.Error(t, decoded.Validate([]byte(`{"zero": 1}`)))
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,69 @@
+#!/bin/bash
+# Runs the benchmarks in the given packages on BASE (default main) and on
+# the working tree, compares them with benchstat, and fails if any
+# benchmark's time or allocations per op grew by more than THRESHOLD
+# percent (default 10) by benchstat's significance test.
+#
+# COUNT sets the runs per benchmark (default 10), BENCH the -bench pattern,
+# and BENCHSTAT the benchstat command.
+set -euo pipefail
+
+BASE=${BASE:-main}
+COUNT=${COUNT:-10}
+THRESHOLD=${THRESHOLD:-10}
+BENCH=${BENCH:-.}
+GO=${GO:-go}
+BENCHSTAT=${BENCHSTAT:-$GO run golang.org/x/perf/cmd/benchstat@latest}
+
+if [ $# -eq 0 ]; then
+    echo "usage: $0 package..." >&2
+    exit 2
+fi
+
+root=$(git rev-parse --show-toplevel)
+out=$(mktemp -d)
+cleanup() {
+    git -C "$root" worktree remove --force "$out/base" >/dev/null 2>&1 || true
+    rm -rf "$out"
+}
+trap cleanup EXIT
+
+git -C "$root" worktree add --quiet --detach "$out/base" "$BASE"
+
+# bench runs the benchmarks in dir, skipping packages it doesn't have
+bench() {
+    local dir=$1
+    local pkgs=()
+    for pkg in "${@:2}"; do
+        if [ -d "$dir/$pkg" ]; then
+            pkgs+=("$pkg")
+        fi
+    done
+    (cd "$dir" && $GO test -run '^$' -bench "$BENCH" -benchmem -count "$COUNT" "${pkgs[@]}")
+}
+
+echo "Benchmarking $BASE..." >&2
+bench "$out/base" "$@" >"$out/base.txt"
+echo "Benchmarking the working tree..." >&2
+bench "$root" "$@" >"$out/new.txt"
+
+$BENCHSTAT "$BASE=$out/base.txt" "working tree=$out/new.txt"
+
+# In benchstat's CSV, each unit's table starts with a header row ending in
+# "vs base,P", and each benchmark's row has its change second to last,
+# or "~" if it isn't significant
+$BENCHSTAT -format csv "$out/base.txt" "$out/new.txt" 2>/dev/null | awk -F, -v threshold="$THRESHOLD" '
+    $NF == "P" { unit = $2; next }
+    (unit == "sec/op" || unit == "allocs/op") && $1 != "geomean" && $(NF-1) ~ /^\+[0-9.]+%$/ {
+        change = substr($(NF-1), 2) + 0
+        if (change > threshold) {
+            printf "%s: %s up %s%%, over the %s%% budget\n", $1, unit, change, threshold
+            failed = 1
+        }
+    }
+    END { exit failed }
+' || {
+    echo "Performance budget exceeded." >&2
+    exit 1
+}
+echo "All benchmarks are within the ${THRESHOLD}% budget." >&2
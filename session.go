@@ -4,13 +4,16 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/bpowers/go-agent/chat"
 	"github.com/bpowers/go-agent/internal/logging"
 	"github.com/bpowers/go-agent/persistence"
+	"github.com/bpowers/go-agent/toolproc"
 )
 
 var logger = logging.Logger().With("component", "session")
@@ -36,6 +39,12 @@ type Session interface {
 	// SessionID returns the unique identifier for this session.
 	SessionID() string
 
+	// SetSystemPrompt replaces the system prompt, taking effect on the next
+	// call to Message. The old prompt is kept, no longer live, in
+	// TotalRecords. Rolling the conversation back with ResumeFrom or
+	// AmendLastUserMessage doesn't undo the change.
+	SetSystemPrompt(ctx context.Context, prompt string) error
+
 	// LiveRecords returns all records marked as live (in active context window).
 	LiveRecords() []persistence.Record
 
@@ -45,6 +54,36 @@ type Session interface {
 	// CompactNow manually triggers context compaction.
 	CompactNow() error
 
+	// Transcript returns the whole conversation, including records no
+	// longer in the context window, for building message lists in UIs.
+	Transcript() (Transcript, error)
+
+	// CheckpointAt branches the conversation at the given live record,
+	// copying the live history up to and including that record into a new
+	// session in the same store. It returns the new session's ID, which can
+	// be opened with WithRestoreSession. The current session is unchanged.
+	// It fails with an error wrapping chat.ErrBusy while a message is in
+	// progress.
+	CheckpointAt(recordID int64) (string, error)
+
+	// ResumeFrom rolls the session back so that the given live record is the
+	// last one in the active context window. Later records are marked dead
+	// rather than deleted, so the abandoned turns remain available in
+	// TotalRecords for auditing. It fails with an error wrapping
+	// chat.ErrBusy while a message is in progress.
+	ResumeFrom(recordID int64) error
+
+	// PinRecord sets whether the given live record is pinned. Pinned
+	// records are kept by context policies that honor pins, such as a
+	// SlidingWindow with AlwaysKeepPinned.
+	PinRecord(recordID int64, pinned bool) error
+
+	// AmendLastUserMessage replaces the most recent user message with msg and
+	// regenerates the response. The original user record and everything after
+	// it are permanently deleted from the store, so typos or accidentally
+	// pasted secrets are removed from both the live context and storage.
+	AmendLastUserMessage(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error)
+
 	// SetCompactionThreshold sets the threshold for automatic compaction (0.0-1.0).
 	// A value of 0.8 means compact when 80% of the context window is used.
 	// A value of 0.0 means never compact automatically.
@@ -52,6 +91,19 @@ type Session interface {
 
 	// Metrics returns usage statistics for the session.
 	Metrics() SessionMetrics
+
+	// Analytics breaks the session's token use down turn by turn, with
+	// latency percentiles and how often each tool was called.
+	Analytics() Analytics
+
+	// ToolAnnotations returns the annotations of the registered tool with
+	// the given name, for UIs to show, such as which tools are destructive.
+	ToolAnnotations(name string) (chat.ToolAnnotations, bool)
+
+	// Marshal returns the session's state that isn't in its store, which
+	// UnmarshalSession turns back into a Session without reading the
+	// conversation, so servers can rebuild a session for each request.
+	Marshal() ([]byte, error)
 }
 
 // SessionMetrics provides usage statistics for the session.
@@ -73,9 +125,40 @@ type sessionOptions struct {
 	sessionID       string
 	store           persistence.Store
 	initialMessages []chat.Message
+	examples        []chat.Message
 	summarizer      Summarizer
+	defaultOptions  []chat.Option
+	owner           string
+	costFunc        CostFunc
+	moderation      *Moderation
+	compression     *PromptCompression
+	dedup           *HistoryDedup
+	contextPolicy   Compactor
+	clock           chat.Clock
+	ids             chat.IDGenerator
+	language        string
+	toolPolicies    []ToolPolicy
+	toolAuditor     ToolAuditor
+
+	policyEvaluators []PolicyEvaluator
+	toolApprover     PolicyEvaluator
+	approvalRule     ApprovalRule
+	outputValidation *ToolOutputValidation
+	toolRunner       *toolproc.Runner
+	tools            []chat.Tool
+	toolProvider     ToolProvider
+	turnCheck        func() error
+	// state is set by UnmarshalSession, and stands in for reading the
+	// session's metrics and records from the store
+	state *sessionState
+
+	maxToolResultSize int
 }
 
+// CostFunc returns the cost of one request to the LLM, in whatever currency
+// unit the caller accounts in.
+type CostFunc func(usage chat.TokenUsageDetails) float64
+
 // WithRestoreSession restores a session with the given ID.
 // This allows resuming a previous conversation by loading its history
 // and state from the configured persistence store.
@@ -101,6 +184,17 @@ func WithInitialMessages(msgs ...chat.Message) SessionOption {
 	}
 }
 
+// WithExamples sends msgs, such as few-shot examples from the fewshot
+// package, ahead of the conversation in every request. Unlike
+// WithInitialMessages, they aren't stored, so they're never compacted and
+// don't appear in History or Transcript, and they must be passed again
+// when the session is restored. They count towards the context window.
+func WithExamples(msgs ...chat.Message) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.examples = append(opts.examples, msgs...)
+	}
+}
+
 // WithSummarizer sets a custom summarizer for context compaction.
 // If not provided, a default LLM-based summarizer is used.
 func WithSummarizer(summarizer Summarizer) SessionOption {
@@ -109,6 +203,100 @@ func WithSummarizer(summarizer Summarizer) SessionOption {
 	}
 }
 
+// WithLanguage sets the language, such as "French" or "Japanese", that
+// the session's generated text is written in: compaction passes it to the
+// Summarizer with ContextWithLanguage, so summaries are written in the same
+// language as the conversation they stand in for. Fixed markers, like the
+// one that starts a summary record, stay in English so transcripts can
+// recognize them.
+func WithLanguage(language string) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.language = language
+	}
+}
+
+// WithMaxToolResultSize limits tool results to the given number of bytes.
+// Larger results are saved in full as artifacts in the session's store, and the
+// LLM sees a truncated result along with a handle it can pass to the built-in
+// read_artifact tool to read the rest. The default of 0 means no limit.
+func WithMaxToolResultSize(bytes int) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.maxToolResultSize = bytes
+	}
+}
+
+// WithToolIsolation runs calls to the session's registered tools in
+// subprocesses started by runner, so a tool that crashes or hangs can't
+// take the session's process with it. The built-in read_artifact tool
+// still runs in process, as do policy evaluation and auditing.
+func WithToolIsolation(runner *toolproc.Runner) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.toolRunner = runner
+	}
+}
+
+// WithTools registers tools on the session as it is created, as if by
+// RegisterTool. As tools aren't persisted, passing them here alongside
+// WithRestoreSession is the way to be sure a restored session has the same
+// tools as the one it resumes.
+func WithTools(tools ...chat.Tool) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.tools = append(opts.tools, tools...)
+	}
+}
+
+// WithDefaultOptions sets chat options applied to every Message call, such as
+// chat.WithTemperature or chat.WithMaxTokens. Options passed to Message are
+// applied after them, so they take precedence.
+func WithDefaultOptions(opts ...chat.Option) SessionOption {
+	return func(o *sessionOptions) {
+		o.defaultOptions = append(o.defaultOptions, opts...)
+	}
+}
+
+// WithOwner records owner as the user the session belongs to, and records the
+// session's token usage against owner in the store, so quotas can be enforced
+// across all of a user's sessions (see persistence.Store.GetUsage). Restoring
+// a session that belongs to a different owner fails.
+func WithOwner(owner string) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.owner = owner
+	}
+}
+
+// WithCostFunc sets the function used to price the usage recorded for the
+// session's owner. Without it, only tokens are recorded.
+func WithCostFunc(fn CostFunc) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.costFunc = fn
+	}
+}
+
+// withTurnCheck has messages call check once they have their turn, and
+// fail with its error if it returns one. Manager uses it to enforce quotas.
+func withTurnCheck(check func() error) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.turnCheck = check
+	}
+}
+
+// WithClock sets the clock used to timestamp the session's records and
+// usage, so tests can be deterministic. The default is the system clock.
+func WithClock(clock chat.Clock) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.clock = clock
+	}
+}
+
+// WithIDGenerator sets the generator of IDs for new sessions, including
+// checkpoints created with CheckpointAt. The default generates random
+// 128-bit IDs.
+func WithIDGenerator(ids chat.IDGenerator) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.ids = ids
+	}
+}
+
 // NewSession creates a new Session with the given client, system prompt, and options.
 // Returns an error if the session store cannot be accessed (e.g., database locked or corrupted).
 func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption) (Session, error) {
@@ -120,9 +308,25 @@ func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption)
 		}
 	}
 
+	for _, p := range options.toolPolicies {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tool policy: %w", err)
+		}
+	}
+
+	if options.clock == nil {
+		options.clock = chat.ClockFunc(time.Now)
+	}
+	if options.ids == nil {
+		options.ids = chat.IDGeneratorFunc(generateSessionID)
+	}
+	if options.approvalRule == nil {
+		options.approvalRule = DestructiveToolsNeedApproval
+	}
+
 	// Generate session ID if not provided
 	if options.sessionID == "" {
-		options.sessionID = generateSessionID()
+		options.sessionID = options.ids.NewID()
 	}
 
 	// Default to memory store if not specified
@@ -136,27 +340,55 @@ func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption)
 		// configured for a cheaper model if desired
 		options.summarizer = NewSummarizer(client)
 	}
+	if options.contextPolicy == nil {
+		options.contextPolicy = summaryCompactor{summarizer: options.summarizer}
+	}
 
-	// Load existing metrics if available - propagate errors to prevent silent failures
-	metrics, err := options.store.LoadMetrics(options.sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load session metrics: %w", err)
+	var metrics persistence.SessionMetrics
+	var existingRecords []persistence.Record
+	if options.state != nil {
+		metrics = options.state.Metrics
+	} else {
+		// Load existing metrics if available - propagate errors to prevent silent failures
+		var err error
+		metrics, err = options.store.LoadMetrics(options.sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session metrics: %w", err)
+		}
+
+		// Check if we have existing records in the store - propagate errors
+		existingRecords, err = options.store.GetAllRecords(options.sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session records: %w", err)
+		}
 	}
+	hasExistingRecords := len(existingRecords) > 0 || options.state != nil
 
-	// Check if we have existing records in the store - propagate errors
-	existingRecords, err := options.store.GetAllRecords(options.sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load session records: %w", err)
+	// An unmarshaled session's owner was checked when it was marshaled
+	if options.owner != "" && (options.state == nil || options.owner != options.state.Owner) {
+		owner, err := options.store.GetOwner(options.sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session owner: %w", err)
+		}
+		if owner != "" && owner != options.owner {
+			return nil, fmt.Errorf("session %s belongs to another owner", options.sessionID)
+		}
+		if owner == "" {
+			if err := options.store.SetOwner(options.sessionID, options.owner); err != nil {
+				return nil, fmt.Errorf("failed to set session owner: %w", err)
+			}
+		}
 	}
-	hasExistingRecords := len(existingRecords) > 0
 
 	// If we have existing records, use the system prompt from the store
 	// Otherwise, use the provided system prompt
 	actualSystemPrompt := systemPrompt
-	if hasExistingRecords {
+	if options.state != nil {
+		actualSystemPrompt = options.state.SystemPrompt
+	} else if hasExistingRecords {
 		// Find the system prompt from existing records
 		for _, r := range existingRecords {
-			if r.Role == "system" {
+			if r.Role == "system" && r.Live {
 				actualSystemPrompt = r.GetText()
 				break
 			}
@@ -179,7 +411,7 @@ func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption)
 				Status:       persistence.RecordStatusSuccess,
 				InputTokens:  0, // System prompt tokens counted with first message
 				OutputTokens: 0,
-				Timestamp:    time.Now(),
+				Timestamp:    options.clock.Now(),
 			}); err != nil {
 				return nil, fmt.Errorf("failed to add system prompt record: %w", err)
 			}
@@ -193,7 +425,7 @@ func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption)
 				Status:       persistence.RecordStatusSuccess,
 				InputTokens:  0, // Initial messages' tokens counted with first query
 				OutputTokens: 0,
-				Timestamp:    time.Now(),
+				Timestamp:    options.clock.Now(),
 			}); err != nil {
 				return nil, fmt.Errorf("failed to add initial message record: %w", err)
 			}
@@ -208,19 +440,123 @@ func NewSession(client chat.Client, systemPrompt string, opts ...SessionOption)
 		compactionThreshold = 0.8
 	}
 
-	return &session{
+	var dedup *deduplicator
+	if options.dedup != nil {
+		dedup = newDeduplicator(*options.dedup)
+	}
+
+	s := &session{
 		sessionID:           options.sessionID,
 		chat:                baseChat,
 		client:              client,
 		systemPrompt:        actualSystemPrompt,
 		store:               options.store,
 		summarizer:          options.summarizer,
+		compactor:           options.contextPolicy,
 		compactionThreshold: compactionThreshold,
 		compactionCount:     metrics.CompactionCount,
 		lastCompaction:      metrics.LastCompaction,
 		cumulativeTokens:    metrics.CumulativeTokens,
+		parentSessionID:     metrics.ParentSessionID,
+		parentRecordID:      metrics.ParentRecordID,
+		maxToolResultSize:   options.maxToolResultSize,
+		defaultOptions:      slices.Clip(options.defaultOptions),
+		owner:               options.owner,
+		costFunc:            options.costFunc,
+		moderation:          options.moderation,
+		compression:         options.compression,
+		dedup:               dedup,
+		clock:               options.clock,
+		ids:                 options.ids,
+		language:            options.language,
+		toolPolicies:        slices.Clip(options.toolPolicies),
+		toolAuditor:         options.toolAuditor,
+		policyEvaluators:    slices.Clip(options.policyEvaluators),
+		toolApprover:        options.toolApprover,
+		approvalRule:        options.approvalRule,
+		outputValidation:    options.outputValidation,
+		examples:            slices.Clip(options.examples),
+		toolRunner:          options.toolRunner,
+		toolProvider:        options.toolProvider,
 		tools:               make(map[string]registeredTool),
-	}, nil
+		turn:                make(chan struct{}, 1),
+		turnCheck:           options.turnCheck,
+	}
+	if options.state != nil {
+		s.contextTokens = options.state.ContextTokens
+		s.contextRecordID = options.state.ContextRecordID
+	}
+	tools := options.tools
+	if options.toolProvider != nil {
+		tools = append(slices.Clip(tools), options.toolProvider.Tools()...)
+	}
+	for _, tool := range tools {
+		if err := s.RegisterTool(tool); err != nil {
+			return nil, fmt.Errorf("failed to register tool %s: %w", tool.Name(), err)
+		}
+	}
+	return s, nil
+}
+
+// CloneSession creates a new session on newClient that continues the
+// conversation held in src's live context window, so a conversation started
+// with one provider can be carried on with another. Thinking blocks are
+// dropped, as their signatures are only meaningful to the provider that
+// produced them. Tools registered on src are registered on the clone, unless
+// the clone's options supply a tool of the same name, and src's
+// ToolProvider is consulted for the clone's tools unless opts set another.
+//
+// The clone gets a fresh session ID unless one is given with
+// WithRestoreSession, and uses an in-memory store unless WithStore is given.
+func CloneSession(src Session, newClient chat.Client, opts ...SessionOption) (Session, error) {
+	var systemPrompt string
+	var msgs []chat.Message
+	for _, r := range src.LiveRecords() {
+		if r.Role == "system" {
+			if systemPrompt == "" {
+				systemPrompt = r.GetText()
+			} else {
+				systemPrompt += "\n\n" + r.GetText()
+			}
+			continue
+		}
+
+		contents := make([]chat.Content, 0, len(r.Contents))
+		for _, c := range r.Contents {
+			if c.Thinking == nil {
+				contents = append(contents, c)
+			}
+		}
+		if len(contents) == 0 {
+			continue
+		}
+		msgs = append(msgs, chat.Message{Role: r.Role, Contents: contents})
+	}
+
+	srcSession, _ := src.(*session)
+	if srcSession != nil && srcSession.toolProvider != nil {
+		// Options given for the clone come after, so they take precedence
+		opts = append([]SessionOption{WithToolProvider(srcSession.toolProvider)}, opts...)
+	}
+	clone, err := NewSession(newClient, systemPrompt, append(opts, WithInitialMessages(msgs...))...)
+	if err != nil {
+		return nil, err
+	}
+
+	if srcSession != nil {
+		cloneTools := clone.ListTools()
+		for _, tool := range srcSession.registeredTools() {
+			if slices.Contains(cloneTools, tool.Name()) {
+				// The clone's own tools, from its options, take precedence
+				continue
+			}
+			if err := clone.RegisterTool(tool); err != nil {
+				return nil, fmt.Errorf("failed to register tool %s: %w", tool.Name(), err)
+			}
+		}
+	}
+
+	return clone, nil
 }
 
 // session is the implementation of Session with pluggable storage.
@@ -231,6 +567,39 @@ type session struct {
 	systemPrompt string
 	store        persistence.Store
 	summarizer   Summarizer
+	compactor    Compactor
+	// defaultOptions are applied to every Message call, before its options
+	defaultOptions []chat.Option
+	// owner is charged for the session's usage, if set
+	owner       string
+	costFunc    CostFunc
+	moderation  *Moderation
+	compression *PromptCompression
+	dedup       *deduplicator
+	clock       chat.Clock
+	ids         chat.IDGenerator
+	// language is the language summaries are written in, if set
+	language     string
+	toolPolicies []ToolPolicy
+	toolAuditor  ToolAuditor
+	// policyEvaluators check tool calls before they run
+	policyEvaluators []PolicyEvaluator
+	// toolApprover approves the calls approvalRule says need it, if set
+	toolApprover PolicyEvaluator
+	approvalRule ApprovalRule
+	// outputValidation checks tool results against output schemas, if set
+	outputValidation *ToolOutputValidation
+	// toolRunner runs registered tools in subprocesses, if set
+	toolRunner *toolproc.Runner
+	// toolProvider supplied the session's tools, if set, and is passed on
+	// to clones
+	toolProvider ToolProvider
+
+	// turn is held (has a value) while a message is in progress,
+	// serializing Message, AmendLastUserMessage and BestOf calls
+	turn chan struct{}
+	// turnCheck, if set, is called once a message has its turn
+	turnCheck func() error
 
 	mu                  sync.Mutex
 	compactionThreshold float64
@@ -239,6 +608,21 @@ type session struct {
 	cumulativeTokens    int
 	lastUsage           chat.TokenUsageDetails
 
+	// contextTokens is the provider-reported size of the context window at
+	// the end of the last exchange, which ended with record contextRecordID.
+	// It is only trusted while that record is still the last live record.
+	contextTokens   int
+	contextRecordID int64
+
+	// parentSessionID and parentRecordID are where the session was
+	// branched from with CheckpointAt, if it was
+	parentSessionID string
+	parentRecordID  int64
+
+	maxToolResultSize int
+	// examples are sent ahead of the history in every request
+	examples []chat.Message
+
 	// Tool tracking - use single mutex for simplicity as per CLAUDE.md
 	tools           map[string]registeredTool
 	lastUserMessage chat.Message
@@ -256,23 +640,113 @@ func (s *session) SessionID() string {
 
 // Message implements chat.Chat
 func (s *session) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	endTurn, err := s.beginTurn(ctx, opts)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	defer endTurn()
+
+	return s.message(ctx, msg, opts...)
+}
+
+// beginTurn waits for any in-progress message to finish, or until ctx is
+// done or the queue timeout set by opts or the session's default options
+// expires, in which case the error wraps chat.ErrBusy, and then runs the
+// session's turn check. The caller must call the returned endTurn when its
+// message is done.
+func (s *session) beginTurn(ctx context.Context, opts []chat.Option) (endTurn func(), err error) {
+	var expired <-chan time.Time
+	if timeout := chat.ApplyOptions(append(s.defaultOptions, opts...)...).QueueTimeout; timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case s.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		return nil, fmt.Errorf("waited for the previous message: %w", chat.ErrBusy)
+	}
+	if s.turnCheck != nil {
+		if err := s.turnCheck(); err != nil {
+			<-s.turn
+			return nil, err
+		}
+	}
+	return func() { <-s.turn }, nil
+}
+
+// tryTurn takes the turn if no message is in progress, or returns an error
+// wrapping chat.ErrBusy. The caller must call the returned endTurn when
+// it's done.
+func (s *session) tryTurn() (endTurn func(), err error) {
+	select {
+	case s.turn <- struct{}{}:
+		return func() { <-s.turn }, nil
+	default:
+		return nil, fmt.Errorf("a message is in progress: %w", chat.ErrBusy)
+	}
+}
+
+// message sends msg and records the exchange; the caller must hold the
+// turn.
+func (s *session) message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	ctx = s.withTurnID(ctx)
+	inputModeration, err := s.moderate(ctx, ModerationInput, msg)
+	if err != nil {
+		return chat.Message{}, err
+	}
+
 	// Add user message and check compaction
 	tempChat, err := s.prepareForMessage(ctx, msg)
 	if err != nil {
 		return chat.Message{}, err
 	}
 
-	// Send message
+	// Send message, with the session's default options overridden by opts
+	opts = append(s.defaultOptions, opts...)
+	reqOpts := chat.ApplyOptions(opts...)
 	response, err := tempChat.Message(ctx, msg, opts...)
+	var dryRun *chat.DryRunRequest
+	if errors.As(err, &dryRun) {
+		// The message wasn't sent, so there's nothing to record
+		return response, err
+	}
 	if err != nil {
+		s.trackFailure(ctx, tempChat, msg, exchange{
+			user:            reqOpts.User,
+			options:         requestOptions(tempChat, reqOpts),
+			inputModeration: inputModeration,
+		})
 		return response, err
 	}
 
+	outputModeration, err := s.moderate(ctx, ModerationOutput, response)
+	if err != nil {
+		return chat.Message{}, err
+	}
+
 	// Track response
-	s.trackResponse(tempChat, response)
+	s.trackResponse(ctx, tempChat, response, exchange{
+		user:             reqOpts.User,
+		options:          requestOptions(tempChat, reqOpts),
+		inputModeration:  inputModeration,
+		outputModeration: outputModeration,
+	})
 	return response, nil
 }
 
+// withTurnID returns ctx with a new turn ID from the session's ID generator,
+// unless it already has one.
+func (s *session) withTurnID(ctx context.Context) context.Context {
+	if chat.GetTurnID(ctx) != "" {
+		return ctx
+	}
+	return chat.WithTurnID(ctx, s.ids.NewID())
+}
+
 // prepareForMessage checks for compaction and returns a prepared chat with history from the store.
 // This method expects the mutex is NOT held and will handle locking internally.
 func (s *session) prepareForMessage(ctx context.Context, msg chat.Message) (chat.Chat, error) {
@@ -294,46 +768,182 @@ func (s *session) prepareForMessage(ctx context.Context, msg chat.Message) (chat
 	// Build the message history from live records AFTER any compaction
 	// This ensures the request uses the compacted history, not the pre-compaction state
 	systemPrompt, msgs := s.buildChatHistoryLocked()
-	s.lastHistoryLen = len(msgs)
+	s.lastHistoryLen = len(s.examples) + len(msgs)
+	return s.newChatLocked(ctx, systemPrompt, msgs)
+}
+
+// newChatLocked returns a chat for the next request, holding the examples
+// and then msgs after deduplication and compression, with the session's
+// tools registered (mutex must be held).
+func (s *session) newChatLocked(ctx context.Context, systemPrompt string, msgs []chat.Message) (chat.Chat, error) {
+	if s.dedup != nil {
+		deduped, err := s.dedup.dedup(ctx, msgs)
+		if err != nil {
+			logger.WarnContext(ctx, "history deduplication failed, sending history unchanged", "error", err)
+		} else {
+			msgs = deduped
+		}
+	}
+	if c := s.compression; c != nil && s.percentFullLocked() >= c.Threshold {
+		msgs = compressHistory(msgs, c.KeepLast, c.Ratio)
+	}
 
 	// Create chat with history from store
-	tempChat := s.client.NewChat(systemPrompt, msgs...)
+	tempChat := s.client.NewChat(systemPrompt, slices.Concat(s.examples, msgs)...)
 
-	// Re-register tools
-	for _, rt := range s.tools {
-		if err := tempChat.RegisterTool(rt.tool); err != nil {
+	// Re-register tools, leaving out those the tool policies forbid for
+	// the chat's model
+	var model string
+	if m, ok := tempChat.(chat.ModelReporter); ok {
+		model = m.Model()
+	}
+	var registered int
+	var filtered []string
+	for name, rt := range s.tools {
+		if !toolAllowed(s.toolPolicies, model, name) {
+			filtered = append(filtered, name)
+			continue
+		}
+		if err := tempChat.RegisterTool(s.wrapToolLocked(rt.tool)); err != nil {
 			return nil, fmt.Errorf("failed to re-register tool %s: %w", rt.tool.Name(), err)
 		}
+		registered++
+	}
+	if len(filtered) > 0 {
+		slices.Sort(filtered)
+		logger.InfoContext(ctx, "tools filtered by policy", "model", model, "tools", filtered)
+	}
+	if s.maxToolResultSize > 0 && registered > 0 && toolAllowed(s.toolPolicies, model, ReadArtifactToolName) {
+		if err := tempChat.RegisterTool(s.checkToolLocked(&readArtifactTool{store: s.store, sessionID: s.sessionID, limit: s.maxToolResultSize})); err != nil {
+			return nil, fmt.Errorf("failed to register %s tool: %w", ReadArtifactToolName, err)
+		}
 	}
 
 	return tempChat, nil
 }
 
+// EstimateRequest implements chat.RequestEstimator, if the client's chats
+// do. The estimate is of the history as it stands, before any compaction
+// the next message would trigger.
+func (s *session) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	tempChat, err := s.estimateChat(ctx)
+	if err != nil {
+		return chat.RequestEstimate{}, err
+	}
+	estimator, ok := tempChat.(chat.RequestEstimator)
+	if !ok {
+		return chat.RequestEstimate{}, fmt.Errorf("estimating requests isn't supported by %T", tempChat)
+	}
+	return estimator.EstimateRequest(ctx, msg, append(s.defaultOptions, opts...)...)
+}
+
+// estimateChat returns a chat holding the history the next request would
+// be sent with, for EstimateRequest.
+func (s *session) estimateChat(ctx context.Context) (chat.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	systemPrompt, msgs := s.buildChatHistoryLocked()
+	return s.newChatLocked(ctx, systemPrompt, msgs)
+}
+
+// wrapToolLocked wraps tool to run in a subprocess, validate its results,
+// check and audit its calls, and enforce the maximum tool result size, if
+// configured (mutex must be held).
+func (s *session) wrapToolLocked(tool chat.Tool) chat.Tool {
+	if s.toolRunner != nil {
+		tool = s.toolRunner.Isolate(tool)
+	}
+	if s.outputValidation != nil {
+		if out := outputSchema(tool); out != nil {
+			tool = &validatedTool{Tool: tool, schema: out, repair: s.outputValidation.Repair}
+		}
+	}
+	tool = s.checkToolLocked(tool)
+	if s.maxToolResultSize <= 0 {
+		return tool
+	}
+	return &truncatingTool{Tool: tool, store: s.store, sessionID: s.sessionID, limit: s.maxToolResultSize}
+}
+
+// checkToolLocked wraps tool to check its calls with the session's policy
+// evaluators, and its approver if the tool needs approval, and report them
+// to its tool auditor, if configured (mutex must be held). The auditor
+// sees the evaluators' decisions.
+func (s *session) checkToolLocked(tool chat.Tool) chat.Tool {
+	annotations := chat.ToolAnnotationsOf(tool)
+	evaluators := s.policyEvaluators
+	if s.approvalRule(tool.Name(), annotations) {
+		approver := s.toolApprover
+		if approver == nil {
+			approver = noApprover{}
+		}
+		evaluators = append(slices.Clip(evaluators), approver)
+	}
+	if len(evaluators) > 0 {
+		tool = &guardedTool{Tool: tool, evaluators: evaluators, annotations: annotations}
+	}
+	if s.toolAuditor != nil {
+		tool = &auditedTool{Tool: tool, auditor: s.toolAuditor, clock: s.clock, sessionID: s.sessionID, owner: s.owner}
+	}
+	return tool
+}
+
+// exchange describes a Message call's request, for trackResponse.
+type exchange struct {
+	// user is the end user the exchange is attributed to, if any
+	user string
+	// options are the request options, saved on the user's message record
+	options *persistence.RequestOptions
+	// inputModeration and outputModeration are saved on the user's message
+	// and the final response records, if set
+	inputModeration  *chat.ModerationResult
+	outputModeration *chat.ModerationResult
+}
+
+// requestOptions returns the persisted form of the options a message was
+// sent to c with.
+func requestOptions(c chat.Chat, opts chat.Options) *persistence.RequestOptions {
+	var model string
+	if m, ok := c.(chat.ModelReporter); ok {
+		model = m.Model()
+	}
+	return persistence.NewRequestOptions(model, opts)
+}
+
 // trackResponse records the response and updates metrics with actual token counts.
 // This method expects the mutex is NOT held and will handle locking internally.
-func (s *session) trackResponse(tempChat chat.Chat, response chat.Message) {
+func (s *session) trackResponse(ctx context.Context, tempChat chat.Chat, response chat.Message, ex exchange) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Get actual token usage from the LLM
 	usage, err := tempChat.TokenUsage()
 	if err != nil {
-		logger.Warn("failed to get token usage from LLM", "error", err)
+		logger.WarnContext(ctx, "failed to get token usage from LLM", "error", err)
 	}
 	s.lastUsage = usage.LastMessage
 
 	// Log if we're missing expected token values
 	if usage.LastMessage.InputTokens == 0 {
-		logger.Warn("LLM returned 0 input tokens for message")
+		logger.WarnContext(ctx, "LLM returned 0 input tokens for message")
 	}
 	if usage.LastMessage.OutputTokens == 0 {
-		logger.Warn("LLM returned 0 output tokens for response")
+		logger.WarnContext(ctx, "LLM returned 0 output tokens for response")
 	}
 	if usage.LastMessage.TotalTokens == 0 {
-		logger.Warn("LLM returned 0 total tokens for exchange")
+		logger.WarnContext(ctx, "LLM returned 0 total tokens for exchange")
 	}
 
-	s.cumulativeTokens += usage.LastMessage.TotalTokens
+	// Each request to the LLM during this exchange counts towards the total
+	rounds := usage.Rounds
+	if len(rounds) == 0 && usage.LastMessage.TotalTokens > 0 {
+		rounds = []chat.TokenUsageDetails{usage.LastMessage}
+	}
+	for _, round := range rounds {
+		s.cumulativeTokens += round.TotalTokens
+	}
+	s.addUsageLocked(rounds)
 
 	// Get new messages from chat history (includes user message and response)
 	_, history := tempChat.History()
@@ -343,36 +953,189 @@ func (s *session) trackResponse(tempChat chat.Chat, response chat.Message) {
 	newMessages := history[s.lastHistoryLen:]
 
 	// Persist all new messages with correct token counts
-	now := time.Now()
+	now := s.clock.Now()
+	records := make([]persistence.Record, len(newMessages))
 	for i, m := range newMessages {
-		rec := persistence.Record{
+		records[i] = persistence.Record{
 			Role:      m.Role,
 			Contents:  append([]chat.Content(nil), m.Contents...),
 			Live:      true,
 			Status:    persistence.RecordStatusSuccess,
 			Timestamp: now.Add(time.Millisecond * time.Duration(i)),
+			User:      ex.user,
+			TurnID:    chat.GetTurnID(ctx),
 		}
-
-		// Assign input tokens to user messages
-		if m.Role == chat.UserRole {
-			rec.InputTokens = usage.LastMessage.InputTokens
-		}
-
-		// Assign output tokens to the final assistant message in the exchange
-		if m.Role == chat.AssistantRole && i == len(newMessages)-1 {
-			rec.OutputTokens = usage.LastMessage.OutputTokens
+	}
+	assignRoundTokens(records, rounds)
+	assignRequests(records, lastRequests(tempChat))
+	if len(records) > 0 && records[0].Role == chat.UserRole {
+		records[0].Moderation = ex.inputModeration
+		records[0].Options = ex.options
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Role == chat.AssistantRole {
+			records[i].Moderation = ex.outputModeration
+			break
 		}
+	}
 
-		if _, err := s.store.AddRecord(s.sessionID, rec); err != nil {
-			logger.Warn("failed to add record", "role", rec.Role, "error", err)
+	var lastID int64
+	for _, rec := range records {
+		id, err := s.store.AddRecord(s.sessionID, rec)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to add record", "role", rec.Role, "error", err)
+			continue
 		}
+		lastID = id
 	}
 	s.lastHistoryLen = len(history)
 
+	s.contextTokens = 0
+	if len(rounds) > 0 && lastID != 0 {
+		last := rounds[len(rounds)-1]
+		s.contextTokens = last.InputTokens + last.OutputTokens
+		s.contextRecordID = lastID
+	}
+
 	// Save metrics
 	s.saveMetricsLocked()
 }
 
+// trackFailure saves msg as a dead, failed record after tempChat failed to
+// respond to it, with the requests it made, so failed turns can be looked
+// into later. The mutex must NOT be held.
+func (s *session) trackFailure(ctx context.Context, tempChat chat.Chat, msg chat.Message, ex exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.store.AddRecord(s.sessionID, persistence.Record{
+		Role:       chat.UserRole,
+		Contents:   append([]chat.Content(nil), msg.Contents...),
+		Live:       false,
+		Status:     persistence.RecordStatusFailed,
+		Timestamp:  s.clock.Now(),
+		User:       ex.user,
+		Moderation: ex.inputModeration,
+		Options:    ex.options,
+		Requests:   lastRequests(tempChat),
+		TurnID:     chat.GetTurnID(ctx),
+	}); err != nil {
+		logger.WarnContext(ctx, "failed to add failed record", "error", err)
+	}
+}
+
+// lastRequests returns the requests c made for the last message, if it
+// reports them.
+func lastRequests(c chat.Chat) []chat.RequestInfo {
+	if r, ok := c.(chat.RequestReporter); ok {
+		return r.LastRequests()
+	}
+	return nil
+}
+
+// assignRequests saves the requests made during an exchange on the
+// assistant records they produced. Each successful request ends a round
+// that produced the next assistant record; failed attempts before it are
+// saved with it. Like rounds in assignRoundTokens, requests are matched to
+// assistant records from the end, and any extra earlier requests are saved
+// on the first assistant record.
+func assignRequests(records []persistence.Record, requests []chat.RequestInfo) {
+	if len(requests) == 0 {
+		return
+	}
+
+	var assistants []int
+	for i, r := range records {
+		if r.Role == chat.AssistantRole {
+			assistants = append(assistants, i)
+		}
+	}
+	if len(assistants) == 0 {
+		return
+	}
+
+	var groups [][]chat.RequestInfo
+	start := 0
+	for i, req := range requests {
+		if req.StatusCode >= 200 && req.StatusCode < 300 {
+			groups = append(groups, requests[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(requests) {
+		groups = append(groups, requests[start:])
+	}
+
+	// Match groups to assistant records from the end
+	g := len(groups) - 1
+	for a := len(assistants) - 1; a >= 0 && g >= 0; a, g = a-1, g-1 {
+		records[assistants[a]].Requests = slices.Clone(groups[g])
+	}
+	first := &records[assistants[0]]
+	for ; g >= 0; g-- {
+		first.Requests = append(slices.Clone(groups[g]), first.Requests...)
+	}
+}
+
+// addUsageLocked records the usage of an exchange's rounds against the
+// session's owner (mutex must be held).
+func (s *session) addUsageLocked(rounds []chat.TokenUsageDetails) {
+	if s.owner == "" || len(rounds) == 0 {
+		return
+	}
+	usage := persistence.Usage{
+		Owner:     s.owner,
+		SessionID: s.sessionID,
+		Timestamp: s.clock.Now(),
+	}
+	for _, round := range rounds {
+		usage.InputTokens += round.InputTokens
+		usage.OutputTokens += round.OutputTokens
+		if s.costFunc != nil {
+			usage.Cost += s.costFunc(round)
+		}
+	}
+	if err := s.store.AddUsage(usage); err != nil {
+		logger.Warn("failed to record usage", "owner", s.owner, "error", err)
+	}
+}
+
+// assignRoundTokens distributes the token usage of an exchange across the
+// records it produced, so that the tokens of the exchange's records add up to
+// the size of the context window at its end. Assistant records are matched to rounds from the
+// end, as providers may not keep intermediate tool call messages in history.
+// The user's message gets the first round's input tokens, and tool results
+// get the growth in input tokens between the rounds on either side of them.
+func assignRoundTokens(records []persistence.Record, rounds []chat.TokenUsageDetails) {
+	if len(rounds) == 0 {
+		return
+	}
+
+	assistants := 0
+	for _, r := range records {
+		if r.Role == chat.AssistantRole {
+			assistants++
+		}
+	}
+
+	round := len(rounds) - assistants - 1 // the round before the next assistant record
+	for i := range records {
+		rec := &records[i]
+		switch {
+		case rec.Role == chat.AssistantRole:
+			round++
+			if round >= 0 {
+				rec.OutputTokens = rounds[round].OutputTokens
+			}
+		case i == 0:
+			rec.InputTokens = rounds[0].InputTokens
+		case round >= 0 && round+1 < len(rounds):
+			prev, next := rounds[round], rounds[round+1]
+			rec.InputTokens = max(0, next.InputTokens-prev.InputTokens-prev.OutputTokens)
+		}
+	}
+}
+
 // History implements chat.Chat
 func (s *session) History() (systemPrompt string, msgs []chat.Message) {
 	s.mu.Lock()
@@ -408,6 +1171,51 @@ func (s *session) MaxTokens() int {
 	return s.chat.MaxTokens()
 }
 
+// SetSystemPrompt implements Session. The live system records are marked
+// dead and replaced with a record for the new prompt, so the history of
+// prompt changes is kept in TotalRecords.
+func (s *session) SetSystemPrompt(ctx context.Context, prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	liveRecords, err := s.store.GetLiveRecords(s.sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load live records: %w", err)
+	}
+	for _, r := range liveRecords {
+		if r.Role == "system" {
+			if err := s.store.MarkRecordDead(s.sessionID, r.ID); err != nil {
+				return fmt.Errorf("failed to retire system prompt record: %w", err)
+			}
+		}
+	}
+
+	if prompt != "" {
+		if _, err := s.store.AddRecord(s.sessionID, persistence.Record{
+			Role: "system",
+			Contents: []chat.Content{
+				{Text: prompt},
+			},
+			Live:      true,
+			Status:    persistence.RecordStatusSuccess,
+			Timestamp: s.clock.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to add system prompt record: %w", err)
+		}
+	}
+
+	s.systemPrompt = prompt
+	return nil
+}
+
+// ContextLimit implements chat.ContextLimiter
+func (s *session) ContextLimit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.contextLimitLocked()
+}
+
 // RegisterTool implements chat.Chat
 func (s *session) RegisterTool(tool chat.Tool) error {
 	s.mu.Lock()
@@ -446,6 +1254,29 @@ func (s *session) ListTools() []string {
 	return names
 }
 
+// ToolAnnotations implements Session.
+func (s *session) ToolAnnotations(name string) (chat.ToolAnnotations, bool) {
+	s.mu.Lock()
+	rt, ok := s.tools[name]
+	s.mu.Unlock()
+	if !ok {
+		return chat.ToolAnnotations{}, false
+	}
+	return chat.ToolAnnotationsOf(rt.tool), true
+}
+
+// registeredTools returns the tools registered with this session.
+func (s *session) registeredTools() []chat.Tool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tools := make([]chat.Tool, 0, len(s.tools))
+	for _, rt := range s.tools {
+		tools = append(tools, rt.tool)
+	}
+	return tools
+}
+
 // LiveRecords returns all records marked as live (in active context window).
 func (s *session) LiveRecords() []persistence.Record {
 	s.mu.Lock()
@@ -475,66 +1306,223 @@ func (s *session) CompactNow() error {
 	return s.compactNowLocked(ctx)
 }
 
+// summaryPrefix starts the text of the records compaction adds to stand in
+// for the records it drops.
+const summaryPrefix = "[Previous conversation summary]\n"
+
 // compactNowLocked performs compaction with the mutex already held.
 func (s *session) compactNowLocked(ctx context.Context) error {
-	// Find live records to compact
-	liveRecords, _ := s.store.GetLiveRecords(s.sessionID)
+	ctx = ContextWithLanguage(ctx, s.language)
+	liveRecords, err := s.store.GetLiveRecords(s.sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load live records: %w", err)
+	}
 
-	if len(liveRecords) < 3 { // Need at least a few messages to summarize
+	compaction, err := s.compactor.Compact(ctx, liveRecords)
+	if err != nil {
+		return err
+	}
+	if len(compaction.Drop) == 0 && compaction.Summary == "" {
 		return nil
 	}
 
-	// Keep last 2 messages, summarize the rest (but never touch system prompts)
-	// Find non-system records to potentially compact
-	var nonSystemRecordsToSummarize []persistence.Record
-	for i := 0; i < len(liveRecords)-2; i++ {
-		// Never include system prompt records in compaction - they must always stay live
-		if liveRecords[i].Role != "system" {
-			nonSystemRecordsToSummarize = append(nonSystemRecordsToSummarize, liveRecords[i])
+	for _, id := range compaction.Drop {
+		s.store.MarkRecordDead(s.sessionID, id)
+	}
+
+	if compaction.Summary != "" {
+		// Add summary as assistant message with tag (safer than system message)
+		summaryText := summaryPrefix + compaction.Summary
+		s.store.AddRecord(s.sessionID, persistence.Record{
+			Role: "assistant",
+			Contents: []chat.Content{
+				{Text: summaryText},
+			},
+			Live:         true,
+			Status:       persistence.RecordStatusSuccess,
+			InputTokens:  0, // Summary tokens will be counted with next message
+			OutputTokens: 0,
+			Timestamp:    s.clock.Now(),
+		})
+	}
+
+	// Update compaction metrics
+	s.compactionCount++
+	s.lastCompaction = s.clock.Now()
+	s.saveMetricsLocked()
+
+	return nil
+}
+
+// CheckpointAt implements Session.
+func (s *session) CheckpointAt(recordID int64) (string, error) {
+	// A message in progress would add to the history being copied
+	endTurn, err := s.tryTurn()
+	if err != nil {
+		return "", err
+	}
+	defer endTurn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	liveRecords, err := s.store.GetLiveRecords(s.sessionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load live records: %w", err)
+	}
+	idx, err := findLiveRecord(liveRecords, recordID)
+	if err != nil {
+		return "", err
+	}
+
+	branchID := s.ids.NewID()
+	if s.owner != "" {
+		if err := s.store.SetOwner(branchID, s.owner); err != nil {
+			return "", fmt.Errorf("failed to set branch owner: %w", err)
+		}
+	}
+	for _, r := range liveRecords[:idx+1] {
+		id := r.ID
+		r.ID = 0
+		if _, err := s.store.AddRecord(branchID, r); err != nil {
+			return "", fmt.Errorf("failed to copy record %d to branch: %w", id, err)
 		}
 	}
 
-	// If there are no non-system records to summarize, nothing to do
-	if len(nonSystemRecordsToSummarize) == 0 {
-		return nil
+	if err := s.store.SaveMetrics(branchID, persistence.SessionMetrics{
+		CumulativeTokens:    s.cumulativeTokens,
+		CompactionCount:     s.compactionCount,
+		LastCompaction:      s.lastCompaction,
+		CompactionThreshold: s.compactionThreshold,
+		ParentSessionID:     s.sessionID,
+		ParentRecordID:      recordID,
+	}); err != nil {
+		return "", fmt.Errorf("failed to save branch metrics: %w", err)
+	}
+
+	return branchID, nil
+}
+
+// ResumeFrom implements Session.
+func (s *session) ResumeFrom(recordID int64) error {
+	// A message in progress would append its records to the rolled back
+	// history
+	endTurn, err := s.tryTurn()
+	if err != nil {
+		return err
 	}
+	defer endTurn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Use the configured summarizer with context from the request
-	summary, err := s.summarizer.Summarize(ctx, nonSystemRecordsToSummarize)
+	liveRecords, err := s.store.GetLiveRecords(s.sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load live records: %w", err)
+	}
+	idx, err := findLiveRecord(liveRecords, recordID)
 	if err != nil {
-		return fmt.Errorf("summarization failed: %w", err)
+		return err
 	}
 
-	// Mark old records as dead (except last 2 and system records)
-	for i, r := range liveRecords {
-		// Never mark system records as dead - they contain the essential system prompt
-		if i < len(liveRecords)-2 && r.Role != "system" {
-			s.store.MarkRecordDead(s.sessionID, r.ID)
+	for _, r := range liveRecords[idx+1:] {
+		// The system prompt may have been replaced after the record, and
+		// isn't part of the conversation being rolled back
+		if r.Role == "system" {
+			continue
+		}
+		if err := s.store.MarkRecordDead(s.sessionID, r.ID); err != nil {
+			return fmt.Errorf("failed to mark record %d dead: %w", r.ID, err)
 		}
 	}
 
-	// Add summary as assistant message with tag (safer than system message)
-	summaryText := fmt.Sprintf("[Previous conversation summary]\n%s", summary)
-	s.store.AddRecord(s.sessionID, persistence.Record{
-		Role: "assistant",
-		Contents: []chat.Content{
-			{Text: summaryText},
-		},
-		Live:         true,
-		Status:       persistence.RecordStatusSuccess,
-		InputTokens:  0, // Summary tokens will be counted with next message
-		OutputTokens: 0,
-		Timestamp:    time.Now(),
-	})
+	return nil
+}
 
-	// Update compaction metrics
-	s.compactionCount++
-	s.lastCompaction = time.Now()
-	s.saveMetricsLocked()
+// PinRecord implements Session.
+func (s *session) PinRecord(recordID int64, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.store.GetRecord(s.sessionID, recordID)
+	if err != nil {
+		return fmt.Errorf("failed to load record %d: %w", recordID, err)
+	}
+	if !record.Live {
+		return fmt.Errorf("record %d is not in the live context window", recordID)
+	}
+	record.Pinned = pinned
+	if err := s.store.UpdateRecord(s.sessionID, recordID, record); err != nil {
+		return fmt.Errorf("failed to update record %d: %w", recordID, err)
+	}
+	return nil
+}
+
+// AmendLastUserMessage implements Session.
+func (s *session) AmendLastUserMessage(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	endTurn, err := s.beginTurn(ctx, opts)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	defer endTurn()
+
+	if err := s.deleteLastUserTurn(); err != nil {
+		return chat.Message{}, err
+	}
+
+	// message rebuilds the provider history from the amended store
+	return s.message(ctx, msg, opts...)
+}
+
+// deleteLastUserTurn deletes the last user message record, along with every
+// live record after it, from the store.
+func (s *session) deleteLastUserTurn() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	liveRecords, err := s.store.GetLiveRecords(s.sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load live records: %w", err)
+	}
+
+	idx := -1
+	for i := len(liveRecords) - 1; i >= 0; i-- {
+		// Tool results are sent with the user role by some providers, but
+		// they aren't something the user typed.
+		if liveRecords[i].Role == chat.UserRole && !liveRecords[i].HasToolResults() {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("no user message in the live context window")
+	}
+
+	ids := make([]int64, 0, len(liveRecords)-idx)
+	for _, r := range liveRecords[idx:] {
+		// Keep a system prompt set after the user's message
+		if r.Role == "system" {
+			continue
+		}
+		ids = append(ids, r.ID)
+	}
+	if err := s.store.DeleteRecords(s.sessionID, ids...); err != nil {
+		return fmt.Errorf("failed to delete records: %w", err)
+	}
 
 	return nil
 }
 
+// findLiveRecord returns the index of the record with the given ID in records.
+func findLiveRecord(records []persistence.Record, id int64) (int, error) {
+	for i, r := range records {
+		if r.ID == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("record %d is not in the live context window", id)
+}
+
 // SetCompactionThreshold sets the threshold for automatic compaction (0.0-1.0).
 func (s *session) SetCompactionThreshold(threshold float64) {
 	s.mu.Lock()
@@ -559,12 +1547,8 @@ func (s *session) Metrics() SessionMetrics {
 	liveRecords, _ := s.store.GetLiveRecords(s.sessionID)
 	allRecords, _ := s.store.GetAllRecords(s.sessionID)
 
-	// Query max tokens dynamically from current chat
-	maxTokens := s.chat.MaxTokens()
-	percentFull := 0.0
-	if maxTokens > 0 {
-		percentFull = float64(liveTokens) / float64(maxTokens)
-	}
+	maxTokens := s.contextLimitLocked()
+	percentFull := s.percentFullLocked()
 
 	return SessionMetrics{
 		CumulativeTokens: s.cumulativeTokens,
@@ -586,26 +1570,46 @@ func (s *session) shouldCompactLocked() bool {
 	if s.compactionThreshold == 0.0 {
 		return false
 	}
+	if s.contextLimitLocked() <= 0 {
+		return false
+	}
+	return s.percentFullLocked() >= s.compactionThreshold
+}
 
-	liveTokens := s.calculateLiveTokensLocked()
-	// Query max tokens dynamically from current chat
-	maxTokens := s.chat.MaxTokens()
+// percentFullLocked returns the fraction of the context window the live
+// records fill, or 0 if its size is unknown (mutex must be held).
+func (s *session) percentFullLocked() float64 {
+	maxTokens := s.contextLimitLocked()
 	if maxTokens <= 0 {
-		return false
+		return 0
 	}
-	percentFull := float64(liveTokens) / float64(maxTokens)
-	return percentFull >= s.compactionThreshold
+	return float64(s.calculateLiveTokensLocked()) / float64(maxTokens)
+}
+
+// contextLimitLocked returns the size of the current model's context window,
+// falling back to MaxTokens for chats that don't report it (mutex must be held).
+func (s *session) contextLimitLocked() int {
+	if cl, ok := s.chat.(chat.ContextLimiter); ok {
+		if limit := cl.ContextLimit(); limit > 0 {
+			return limit
+		}
+	}
+	return s.chat.MaxTokens()
 }
 
 // calculateLiveTokensLocked calculates live token count (mutex must be held).
+// The provider-reported usage of the last exchange is exact, but once the live
+// records change without a new exchange (compaction, rollback, restoring a
+// session) the count is re-estimated from the live records.
 func (s *session) calculateLiveTokensLocked() int {
 	records, _ := s.store.GetLiveRecords(s.sessionID)
-	total := 0
-	for _, r := range records {
-		// Count both input and output tokens
-		total += r.InputTokens + r.OutputTokens
+	if len(records) == 0 {
+		return 0
 	}
-	return total
+	if s.contextTokens > 0 && records[len(records)-1].ID == s.contextRecordID {
+		return s.contextTokens
+	}
+	return estimateRecordTokens(records)
 }
 
 // buildChatHistoryLocked builds the chat history (mutex must be held).
@@ -651,10 +1655,17 @@ func (s *session) buildChatHistoryLocked() (string, []chat.Message) {
 
 // saveMetricsLocked saves metrics to store (mutex must be held).
 func (s *session) saveMetricsLocked() {
-	s.store.SaveMetrics(s.sessionID, persistence.SessionMetrics{
+	s.store.SaveMetrics(s.sessionID, s.metricsLocked())
+}
+
+// metricsLocked returns the session's persisted metrics.
+func (s *session) metricsLocked() persistence.SessionMetrics {
+	return persistence.SessionMetrics{
 		CompactionCount:     s.compactionCount,
 		LastCompaction:      s.lastCompaction,
 		CumulativeTokens:    s.cumulativeTokens,
 		CompactionThreshold: s.compactionThreshold,
-	})
+		ParentSessionID:     s.parentSessionID,
+		ParentRecordID:      s.parentRecordID,
+	}
 }
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestSessionAmendLastUserMessage(t *testing.T) {
+	store := persistence.NewMemoryStore()
+	client := &mockClient{}
+	session, err := NewSession(client, "System", WithStore(store))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = session.Message(ctx, chat.UserMessage("First message"))
+	require.NoError(t, err)
+	_, err = session.Message(ctx, chat.UserMessage("My password is hunter2"))
+	require.NoError(t, err)
+	require.Len(t, session.LiveRecords(), 5)
+
+	response, err := session.AmendLastUserMessage(ctx, chat.UserMessage("My password is [redacted]"))
+	require.NoError(t, err)
+	assert.Contains(t, response.GetText(), "[redacted]")
+
+	// The original turn is gone from storage, not just marked dead
+	all := session.TotalRecords()
+	require.Len(t, all, 5)
+	for _, r := range all {
+		assert.NotContains(t, r.GetText(), "hunter2")
+	}
+
+	_, msgs := session.History()
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "First message", msgs[0].GetText())
+	assert.Equal(t, "My password is [redacted]", msgs[2].GetText())
+}
+
+func TestSessionAmendLastUserMessageEmpty(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	_, err = session.AmendLastUserMessage(context.Background(), chat.UserMessage("Hello"))
+	assert.Error(t, err)
+	assert.Len(t, session.LiveRecords(), 1)
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestSessionMaxToolResultSize(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "You are a helpful assistant", WithMaxToolResultSize(10))
+	require.NoError(t, err)
+
+	output := strings.Repeat("0123456789", 3)
+	require.NoError(t, session.RegisterTool(&mockTool{
+		name:   "big",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			return output
+		},
+	}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+
+	// read_artifact is only visible to the LLM, not to session users
+	assert.Equal(t, []string{"big"}, session.ListTools())
+
+	tempChat := client.chats[len(client.chats)-1]
+	require.Contains(t, tempChat.tools, "big")
+	require.Contains(t, tempChat.tools, ReadArtifactToolName)
+
+	result := tempChat.tools["big"](context.Background(), "{}")
+	assert.True(t, strings.HasPrefix(result, "0123456789\n\n[Output truncated: showing 10 of 30 bytes."))
+	assert.Contains(t, result, `{"handle": 1, "offset": 10}`)
+
+	var out readArtifactOutput
+	var content strings.Builder
+	content.WriteString("0123456789")
+	offset := 10
+	for offset != 0 {
+		input, err := json.Marshal(readArtifactInput{Handle: 1, Offset: offset})
+		require.NoError(t, err)
+		out = readArtifactOutput{}
+		require.NoError(t, json.Unmarshal([]byte(tempChat.tools[ReadArtifactToolName](context.Background(), string(input))), &out))
+		require.Nil(t, out.Error)
+		assert.Equal(t, 30, out.TotalSize)
+		content.WriteString(out.Content)
+		offset = out.NextOffset
+	}
+	assert.Equal(t, output, content.String())
+}
+
+func TestSessionToolResultsUnlimitedByDefault(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "You are a helpful assistant")
+	require.NoError(t, err)
+
+	output := strings.Repeat("x", 100000)
+	require.NoError(t, session.RegisterTool(&mockTool{
+		name:   "big",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			return output
+		},
+	}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+
+	tempChat := client.chats[len(client.chats)-1]
+	assert.NotContains(t, tempChat.tools, ReadArtifactToolName)
+	assert.Equal(t, output, tempChat.tools["big"](context.Background(), "{}"))
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "abc", truncateUTF8("abc", 5))
+	assert.Equal(t, "ab", truncateUTF8("abc", 2))
+	// "é" is two bytes, and shouldn't be split
+	assert.Equal(t, "a", truncateUTF8("aé", 2))
+	assert.Equal(t, "aé", truncateUTF8("aé", 3))
+}
+
+func TestReadArtifactErrors(t *testing.T) {
+	store := persistence.NewMemoryStore()
+	id, err := store.SaveArtifact("s", "hello")
+	require.NoError(t, err)
+
+	tool := &readArtifactTool{store: store, sessionID: "s", limit: 100}
+	require.NoError(t, chat.ValidateToolDef(tool))
+	for _, input := range []string{
+		`not json`,
+		`{"handle": 99, "offset": 0}`,
+		`{"handle": 1, "offset": 6}`,
+	} {
+		var out readArtifactOutput
+		require.NoError(t, json.Unmarshal([]byte(tool.Call(context.Background(), input)), &out))
+		assert.NotNil(t, out.Error)
+		assert.Empty(t, out.Content)
+	}
+
+	// artifacts are scoped to their session
+	other := &readArtifactTool{store: store, sessionID: "other", limit: 100}
+	var out readArtifactOutput
+	require.NoError(t, json.Unmarshal([]byte(other.Call(context.Background(), `{"handle": 1, "offset": 0}`)), &out))
+	assert.NotNil(t, out.Error)
+
+	out = readArtifactOutput{}
+	require.NoError(t, json.Unmarshal([]byte(tool.Call(context.Background(), `{"handle": 1, "offset": 0}`)), &out))
+	assert.Equal(t, int64(1), id)
+	assert.Nil(t, out.Error)
+	assert.Equal(t, "hello", out.Content)
+	assert.Equal(t, 0, out.NextOffset)
+}
+
+// mockImageTool is a chat.ImageTool returning output and an image.
+type mockImageTool struct {
+	mockTool
+	output string
+	image  chat.ImageContent
+}
+
+func (m *mockImageTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	return m.output, []chat.ImageContent{m.image}
+}
+
+func TestTruncatingToolPassesImages(t *testing.T) {
+	img := chat.ImageContent{MediaType: "image/png", Data: []byte("png")}
+	tool := &truncatingTool{
+		Tool:      &mockImageTool{mockTool: mockTool{name: "screenshot"}, output: strings.Repeat("x", 30), image: img},
+		store:     persistence.NewMemoryStore(),
+		sessionID: "session",
+		limit:     10,
+	}
+
+	output, images := tool.CallWithImages(context.Background(), "{}")
+	assert.True(t, strings.HasPrefix(output, "xxxxxxxxxx\n\n[Output truncated: showing 10 of 30 bytes."))
+	assert.Equal(t, []chat.ImageContent{img}, images)
+
+	// Tools without images are called as usual
+	plain := &truncatingTool{
+		Tool: &mockTool{name: "plain", callFn: func(ctx context.Context, input string) string {
+			return "short"
+		}},
+		store: persistence.NewMemoryStore(),
+		limit: 10,
+	}
+	output, images = plain.CallWithImages(context.Background(), "{}")
+	assert.Equal(t, "short", output)
+	assert.Empty(t, images)
+}
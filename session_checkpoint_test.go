@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestSessionResumeFrom(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+	}
+
+	records := session.LiveRecords()
+	require.Len(t, records, 7) // System + 3*(user+assistant)
+
+	// Roll back to the end of the first exchange
+	checkpoint := records[2]
+	require.Equal(t, chat.AssistantRole, checkpoint.Role)
+	require.NoError(t, session.ResumeFrom(checkpoint.ID))
+
+	live := session.LiveRecords()
+	require.Len(t, live, 3)
+	assert.Equal(t, checkpoint.ID, live[len(live)-1].ID)
+
+	// Abandoned turns are kept for auditing
+	assert.Len(t, session.TotalRecords(), 7)
+
+	// The next message continues from the checkpoint
+	_, err = session.Message(ctx, chat.UserMessage("A different direction"))
+	require.NoError(t, err)
+
+	_, msgs := session.History()
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "Message 0", msgs[0].GetText())
+	assert.Equal(t, "A different direction", msgs[2].GetText())
+}
+
+func TestSessionResumeFromUnknownRecord(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	err = session.ResumeFrom(12345)
+	assert.Error(t, err)
+	assert.Len(t, session.LiveRecords(), 3)
+}
+
+func TestSessionRollbackDuringMessage(t *testing.T) {
+	client := &blockingClient{release: make(chan struct{})}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+	records := session.LiveRecords()
+	require.Len(t, records, 1)
+
+	done := make(chan error)
+	go func() {
+		_, err := session.Message(context.Background(), chat.UserMessage("Hello"))
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return client.maxActive() == 1 }, time.Second, time.Millisecond)
+
+	// The history can't change under a message in progress
+	assert.ErrorIs(t, session.ResumeFrom(records[0].ID), chat.ErrBusy)
+	_, err = session.CheckpointAt(records[0].ID)
+	assert.ErrorIs(t, err, chat.ErrBusy)
+
+	close(client.release)
+	require.NoError(t, <-done)
+	require.NoError(t, session.ResumeFrom(records[0].ID))
+	_, err = session.CheckpointAt(records[0].ID)
+	require.NoError(t, err)
+}
+
+func TestSessionCheckpointAt(t *testing.T) {
+	store := persistence.NewMemoryStore()
+	client := &mockClient{}
+	session, err := NewSession(client, "System", WithStore(store))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+	}
+
+	records := session.LiveRecords()
+	require.Len(t, records, 5)
+
+	branchID, err := session.CheckpointAt(records[2].ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, session.SessionID(), branchID)
+
+	// The original session is unchanged
+	assert.Len(t, session.LiveRecords(), 5)
+
+	metrics, err := store.LoadMetrics(branchID)
+	require.NoError(t, err)
+	assert.Equal(t, session.SessionID(), metrics.ParentSessionID)
+	assert.Equal(t, records[2].ID, metrics.ParentRecordID)
+
+	branch, err := NewSession(client, "ignored", WithStore(store), WithRestoreSession(branchID))
+	require.NoError(t, err)
+
+	branchRecords := branch.LiveRecords()
+	require.Len(t, branchRecords, 3)
+	assert.Equal(t, "System", branchRecords[0].GetText())
+	assert.Equal(t, "Message 0", branchRecords[1].GetText())
+
+	_, err = branch.Message(ctx, chat.UserMessage("Branch message"))
+	require.NoError(t, err)
+	assert.Len(t, branch.LiveRecords(), 5)
+	assert.Len(t, session.LiveRecords(), 5)
+
+	// Saving the branch's metrics keeps its link to the parent
+	metrics, err = store.LoadMetrics(branchID)
+	require.NoError(t, err)
+	assert.Equal(t, session.SessionID(), metrics.ParentSessionID)
+	assert.Equal(t, records[2].ID, metrics.ParentRecordID)
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestCloneSession(t *testing.T) {
+	srcClient := &mockClient{}
+	src, err := NewSession(srcClient, "System prompt")
+	require.NoError(t, err)
+
+	tool := &mockTool{
+		name:        "echo",
+		description: "Echoes input",
+		schema:      `{"type":"object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			return input
+		},
+	}
+	require.NoError(t, src.RegisterTool(tool))
+
+	ctx := context.Background()
+	_, err = src.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	_, err = src.Message(ctx, chat.UserMessage("Think about it"))
+	require.NoError(t, err)
+
+	dstClient := &mockClient{}
+	dst, err := CloneSession(src, dstClient)
+	require.NoError(t, err)
+	assert.NotEqual(t, src.SessionID(), dst.SessionID())
+	assert.Equal(t, []string{"echo"}, dst.ListTools())
+
+	systemPrompt, msgs := dst.History()
+	assert.Equal(t, "System prompt", systemPrompt)
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "Hello", msgs[0].GetText())
+	assert.Equal(t, "Think about it", msgs[2].GetText())
+
+	_, err = dst.Message(ctx, chat.UserMessage("Continue"))
+	require.NoError(t, err)
+	assert.Len(t, dst.LiveRecords(), 7)
+	assert.Len(t, src.LiveRecords(), 5)
+}
+
+func TestCloneSessionDropsThinking(t *testing.T) {
+	assistant := chat.Message{Role: chat.AssistantRole}
+	assistant.AddThinking("Reasoning", "provider-signature")
+	assistant.AddText("Answer")
+
+	thinkingOnly := chat.Message{Role: chat.AssistantRole}
+	thinkingOnly.AddThinking("More reasoning", "provider-signature")
+
+	src, err := NewSession(&mockClient{}, "System", WithInitialMessages(
+		chat.UserMessage("Question"),
+		assistant,
+		thinkingOnly,
+	))
+	require.NoError(t, err)
+
+	dst, err := CloneSession(src, &mockClient{})
+	require.NoError(t, err)
+
+	_, msgs := dst.History()
+	require.Len(t, msgs, 2)
+	require.Len(t, msgs[1].Contents, 1)
+	assert.Equal(t, "Answer", msgs[1].Contents[0].Text)
+	for _, r := range dst.LiveRecords() {
+		assert.False(t, r.HasThinking())
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestSessionExamples(t *testing.T) {
+	t.Parallel()
+
+	examples := []chat.Message{
+		chat.UserMessage("Example question"),
+		chat.AssistantMessage("Example answer"),
+	}
+	client := &mockClient{}
+	session, err := NewSession(client, "System",
+		WithExamples(examples...),
+		WithContextPolicy(SlidingWindow{KeepSystem: true, KeepLastNTurns: 1}))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := range 3 {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+
+		// Every request starts with the examples
+		sent := client.chats[len(client.chats)-1].messages
+		require.Len(t, sent, len(examples)+2*(i+1))
+		assert.Equal(t, examples, sent[:len(examples)])
+		assert.Equal(t, fmt.Sprintf("Message %d", i), sent[len(sent)-2].GetText())
+	}
+
+	// The examples aren't stored or part of the history
+	records := session.LiveRecords()
+	require.Len(t, records, 7)
+	for _, rec := range records {
+		assert.NotContains(t, rec.GetText(), "Example")
+	}
+	_, history := session.History()
+	assert.Equal(t, "Message 0", history[0].GetText())
+
+	// and compaction leaves them alone
+	require.NoError(t, session.CompactNow())
+	require.Less(t, len(session.LiveRecords()), len(records))
+	_, err = session.Message(ctx, chat.UserMessage("After compaction"))
+	require.NoError(t, err)
+	sent := client.chats[len(client.chats)-1].messages
+	assert.Equal(t, examples, sent[:len(examples)])
+	assert.Equal(t, "After compaction", sent[len(sent)-2].GetText())
+	assert.Equal(t, "After compaction", session.LiveRecords()[len(session.LiveRecords())-2].GetText())
+}
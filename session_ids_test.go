@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestSessionClockAndIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	clock := chat.ClockFunc(func() time.Time { return now })
+	var n int
+	ids := chat.IDGeneratorFunc(func() string {
+		n++
+		return fmt.Sprintf("session-%d", n)
+	})
+
+	session, err := NewSession(&mockClient{}, "System", WithClock(clock), WithIDGenerator(ids))
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", session.SessionID())
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	records := session.TotalRecords()
+	require.Len(t, records, 3)
+	assert.Equal(t, now, records[0].Timestamp)
+	// Records in an exchange are spaced a millisecond apart to keep their order
+	assert.Equal(t, now, records[1].Timestamp)
+	assert.Equal(t, now.Add(time.Millisecond), records[2].Timestamp)
+	// The exchange's records share the turn ID generated for it
+	assert.Empty(t, records[0].TurnID)
+	assert.Equal(t, "session-2", records[1].TurnID)
+	assert.Equal(t, "session-2", records[2].TurnID)
+
+	branchID, err := session.CheckpointAt(records[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "session-3", branchID)
+}
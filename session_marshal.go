@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// sessionStateVersion is the version of the sessionState format Marshal
+// writes; UnmarshalSession rejects others.
+const sessionStateVersion = 1
+
+// sessionState is the state Marshal captures: what NewSession would
+// otherwise read from the store, and the options that are plain data.
+type sessionState struct {
+	Version           int                        `json:"version"`
+	SessionID         string                     `json:"sessionID"`
+	SystemPrompt      string                     `json:"systemPrompt,omitzero"`
+	Owner             string                     `json:"owner,omitzero"`
+	Language          string                     `json:"language,omitzero"`
+	MaxToolResultSize int                        `json:"maxToolResultSize,omitzero"`
+	Metrics           persistence.SessionMetrics `json:"metrics"`
+	// ContextTokens and ContextRecordID are the cached size of the
+	// context window, so it needn't be estimated again
+	ContextTokens   int   `json:"contextTokens,omitzero"`
+	ContextRecordID int64 `json:"contextRecordID,omitzero"`
+}
+
+// Marshal implements Session.
+func (s *session) Marshal() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(sessionState{
+		Version:           sessionStateVersion,
+		SessionID:         s.sessionID,
+		SystemPrompt:      s.systemPrompt,
+		Owner:             s.owner,
+		Language:          s.language,
+		MaxToolResultSize: s.maxToolResultSize,
+		Metrics:           s.metricsLocked(),
+		ContextTokens:     s.contextTokens,
+		ContextRecordID:   s.contextRecordID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	}
+	return data, nil
+}
+
+// UnmarshalSession rebuilds a session from data returned by Session.Marshal,
+// using the store the session was created with. Unlike NewSession with
+// WithRestoreSession, it doesn't read the conversation or metrics from the
+// store, so it is cheap enough to call for every request a web server
+// handles instead of keeping sessions in memory.
+//
+// Only options that are plain data (WithOwner, WithLanguage, and
+// WithMaxToolResultSize) are captured; others, like tools, summarizers,
+// policies, and default chat options, must be passed in opts as they were
+// to NewSession, and opts take precedence over the captured ones. The store
+// and session ID can't be changed. data goes stale once the session handles
+// another message, so it should be marshaled again after each one.
+func UnmarshalSession(client chat.Client, store persistence.Store, data []byte, opts ...SessionOption) (Session, error) {
+	var state sessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	if state.Version != sessionStateVersion {
+		return nil, fmt.Errorf("unsupported session state version %d", state.Version)
+	}
+	if state.SessionID == "" {
+		return nil, errors.New("session state has no session ID")
+	}
+
+	all := []SessionOption{
+		WithOwner(state.Owner),
+		WithLanguage(state.Language),
+		WithMaxToolResultSize(state.MaxToolResultSize),
+	}
+	all = append(all, opts...)
+	all = append(all, func(o *sessionOptions) {
+		o.store = store
+		o.sessionID = state.SessionID
+		o.state = &state
+	})
+	return NewSession(client, state.SystemPrompt, all...)
+}
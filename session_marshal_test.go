@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// readCountingStore counts the reads NewSession makes to restore a session.
+type readCountingStore struct {
+	*persistence.MemoryStore
+	reads int
+}
+
+func (s *readCountingStore) GetAllRecords(sessionID string) ([]persistence.Record, error) {
+	s.reads++
+	return s.MemoryStore.GetAllRecords(sessionID)
+}
+
+func (s *readCountingStore) LoadMetrics(sessionID string) (persistence.SessionMetrics, error) {
+	s.reads++
+	return s.MemoryStore.LoadMetrics(sessionID)
+}
+
+func TestSessionMarshal(t *testing.T) {
+	client := &mockClient{}
+	store := &readCountingStore{MemoryStore: persistence.NewMemoryStore()}
+	src, err := NewSession(client, "System", WithStore(store), WithOwner("alice"), WithLanguage("French"))
+	require.NoError(t, err)
+	src.SetCompactionThreshold(0.5)
+	_, err = src.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	data, err := src.Marshal()
+	require.NoError(t, err)
+
+	store.reads = 0
+	tool := &mockTool{name: "test_tool", schema: `{"type": "object"}`}
+	restored, err := UnmarshalSession(client, store, data, WithTools(tool))
+	require.NoError(t, err)
+	assert.Zero(t, store.reads, "unmarshaling shouldn't read the conversation")
+
+	assert.Equal(t, src.SessionID(), restored.SessionID())
+	assert.Equal(t, src.Metrics(), restored.Metrics())
+	assert.Equal(t, []string{"test_tool"}, restored.ListTools())
+	r := restored.(*session)
+	assert.Equal(t, "System", r.systemPrompt)
+	assert.Equal(t, "alice", r.owner)
+	assert.Equal(t, "French", r.language)
+	assert.Equal(t, 0.5, r.compactionThreshold)
+
+	// The conversation carries on where it left off
+	_, err = restored.Message(context.Background(), chat.UserMessage("Again"))
+	require.NoError(t, err)
+	var texts []string
+	for _, record := range restored.LiveRecords() {
+		texts = append(texts, record.GetText())
+	}
+	assert.Equal(t, []string{"System", "Hello", "Response to: Hello", "Again", "Response to: Again"}, texts)
+	assert.Greater(t, restored.Metrics().CumulativeTokens, src.Metrics().CumulativeTokens)
+}
+
+func TestUnmarshalSessionErrors(t *testing.T) {
+	t.Parallel()
+
+	store := persistence.NewMemoryStore()
+	for _, data := range []string{
+		`not json`,
+		`{"version": 99, "sessionID": "abc"}`,
+		`{"version": 1}`,
+	} {
+		_, err := UnmarshalSession(&mockClient{}, store, []byte(data))
+		assert.Error(t, err, data)
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// requestsChat reports requests like a provider, and fails with err if set.
+type requestsChat struct {
+	mockChat
+	requests []chat.RequestInfo
+	err      error
+}
+
+func (c *requestsChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	if c.err != nil {
+		return chat.Message{}, c.err
+	}
+	return c.mockChat.Message(ctx, msg, opts...)
+}
+
+func (c *requestsChat) LastRequests() []chat.RequestInfo {
+	return c.requests
+}
+
+type requestsClient struct {
+	requests []chat.RequestInfo
+	err      error
+}
+
+func (c *requestsClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	rc := &requestsChat{requests: c.requests, err: c.err}
+	rc.systemPrompt = systemPrompt
+	rc.messages = append([]chat.Message{}, initialMsgs...)
+	return rc
+}
+
+func TestAssignRequests(t *testing.T) {
+	t.Parallel()
+
+	ok := func(id string) chat.RequestInfo { return chat.RequestInfo{RequestID: id, StatusCode: 200} }
+	overloaded := chat.RequestInfo{RequestID: "retried", StatusCode: 529}
+
+	records := []persistence.Record{
+		{Role: chat.UserRole},
+		{Role: chat.AssistantRole},
+		{Role: chat.ToolRole},
+		{Role: chat.AssistantRole},
+	}
+	assignRequests(records, []chat.RequestInfo{ok("1"), overloaded, ok("2")})
+	assert.Nil(t, records[0].Requests)
+	assert.Equal(t, []chat.RequestInfo{ok("1")}, records[1].Requests)
+	assert.Nil(t, records[2].Requests)
+	assert.Equal(t, []chat.RequestInfo{overloaded, ok("2")}, records[3].Requests)
+
+	// Extra requests are saved on the first assistant record
+	records = []persistence.Record{{Role: chat.UserRole}, {Role: chat.AssistantRole}}
+	assignRequests(records, []chat.RequestInfo{ok("1"), ok("2")})
+	assert.Equal(t, []chat.RequestInfo{ok("1"), ok("2")}, records[1].Requests)
+}
+
+func TestSessionRecordsRequests(t *testing.T) {
+	t.Parallel()
+
+	requests := []chat.RequestInfo{{RequestID: "req_1", ResponseID: "msg_1", StatusCode: 200, Latency: time.Second}}
+	session, err := NewSession(&requestsClient{requests: requests}, "System")
+	require.NoError(t, err)
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	records := session.LiveRecords()
+	require.Len(t, records, 3)
+	assert.Nil(t, records[1].Requests)
+	assert.Equal(t, requests, records[2].Requests)
+}
+
+func TestSessionRecordsFailedExchange(t *testing.T) {
+	t.Parallel()
+
+	errOverloaded := errors.New("overloaded")
+	requests := []chat.RequestInfo{{RequestID: "req_1", StatusCode: 529, Latency: time.Second}}
+	session, err := NewSession(&requestsClient{requests: requests, err: errOverloaded}, "System")
+	require.NoError(t, err)
+
+	ctx := chat.WithTurnID(context.Background(), "turn-1")
+	_, err = session.Message(ctx, chat.UserMessage("Hello"), chat.WithMaxTokens(10))
+	require.ErrorIs(t, err, errOverloaded)
+
+	// The failed exchange is kept out of the context window
+	assert.Len(t, session.LiveRecords(), 1)
+	records := session.TotalRecords()
+	require.Len(t, records, 2)
+	failed := records[1]
+	assert.Equal(t, "Hello", failed.GetText())
+	assert.False(t, failed.Live)
+	assert.Equal(t, persistence.RecordStatusFailed, failed.Status)
+	assert.Equal(t, requests, failed.Requests)
+	assert.Equal(t, 10, failed.Options.MaxTokens)
+	assert.Equal(t, "turn-1", failed.TurnID)
+}
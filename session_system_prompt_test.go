@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestSessionSetSystemPrompt(t *testing.T) {
+	store := persistence.NewMemoryStore()
+	client := &mockClient{}
+	session, err := NewSession(client, "Onboarding prompt", WithStore(store))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	require.NoError(t, session.SetSystemPrompt(ctx, "Working prompt"))
+
+	_, err = session.Message(ctx, chat.UserMessage("Next"))
+	require.NoError(t, err)
+	assert.Equal(t, "Working prompt", client.chats[len(client.chats)-1].systemPrompt)
+
+	systemPrompt, msgs := session.History()
+	assert.Equal(t, "Working prompt", systemPrompt)
+	assert.Len(t, msgs, 4)
+
+	// The old prompt is kept, but no longer live
+	var prompts []persistence.Record
+	for _, r := range session.TotalRecords() {
+		if r.Role == "system" {
+			prompts = append(prompts, r)
+		}
+	}
+	require.Len(t, prompts, 2)
+	assert.Equal(t, "Onboarding prompt", prompts[0].GetText())
+	assert.False(t, prompts[0].Live)
+	assert.Equal(t, "Working prompt", prompts[1].GetText())
+	assert.True(t, prompts[1].Live)
+
+	// A restored session picks up the new prompt
+	restored, err := NewSession(client, "ignored", WithStore(store), WithRestoreSession(session.SessionID()))
+	require.NoError(t, err)
+	systemPrompt, _ = restored.History()
+	assert.Equal(t, "Working prompt", systemPrompt)
+}
+
+func TestSessionSetSystemPromptSurvivesRollback(t *testing.T) {
+	ctx := context.Background()
+	newSession := func(t *testing.T) Session {
+		session, err := NewSession(&mockClient{}, "Onboarding prompt")
+		require.NoError(t, err)
+		_, err = session.Message(ctx, chat.UserMessage("Hello"))
+		require.NoError(t, err)
+		_, err = session.Message(ctx, chat.UserMessage("Next"))
+		require.NoError(t, err)
+		require.NoError(t, session.SetSystemPrompt(ctx, "Working prompt"))
+		return session
+	}
+
+	t.Run("ResumeFrom", func(t *testing.T) {
+		session := newSession(t)
+		// The old prompt is dead, so the first exchange ends at index 1
+		require.NoError(t, session.ResumeFrom(session.LiveRecords()[1].ID))
+
+		systemPrompt, msgs := session.History()
+		assert.Equal(t, "Working prompt", systemPrompt)
+		assert.Len(t, msgs, 2)
+	})
+
+	t.Run("AmendLastUserMessage", func(t *testing.T) {
+		session := newSession(t)
+		_, err := session.AmendLastUserMessage(ctx, chat.UserMessage("Amended"))
+		require.NoError(t, err)
+
+		systemPrompt, msgs := session.History()
+		assert.Equal(t, "Working prompt", systemPrompt)
+		require.Len(t, msgs, 4)
+		assert.Equal(t, "Amended", msgs[2].GetText())
+	})
+}
@@ -87,6 +87,13 @@ func (m *mockSystemReminderChat) MaxTokens() int {
 	return 4096
 }
 
+func (m *mockSystemReminderChat) SetSystemPrompt(ctx context.Context, prompt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.systemPrompt = prompt
+	return nil
+}
+
 func (m *mockSystemReminderChat) RegisterTool(tool chat.Tool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
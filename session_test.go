@@ -27,17 +27,23 @@ type mockChat struct {
 	messages     []chat.Message
 	tools        map[string]func(context.Context, string) string
 	maxTokens    int
+	contextLimit int
 	tokenUsage   chat.TokenUsage
 
 	// Track calls for assertions
 	messageCalls       int
 	messageStreamCalls int
+	lastOptions        chat.Options
 }
 
 func (m *mockChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
 	m.messageCalls++
 	appliedOpts := chat.ApplyOptions(opts...)
+	m.lastOptions = appliedOpts
 	callback := appliedOpts.StreamingCb
+	if appliedOpts.DryRun {
+		return chat.Message{}, &chat.DryRunRequest{Method: "POST", URL: "mock://messages"}
+	}
 
 	// Simple mock response
 	response := chat.AssistantMessage(fmt.Sprintf("Response to: %s", msg.GetText()))
@@ -59,11 +65,17 @@ func (m *mockChat) Message(ctx context.Context, msg chat.Message, opts ...chat.O
 		})
 	}
 
+	// Like a real LLM, the input is the whole conversation so far
+	inputTokens := estimateTokens(m.systemPrompt)
+	for _, prev := range m.messages {
+		inputTokens += estimateTokens(prev.GetText())
+	}
+	inputTokens += estimateTokens(msg.GetText())
+
 	m.messages = append(m.messages, msg)
 	m.messages = append(m.messages, response)
 
 	// Update token usage - new format with LastMessage and Cumulative
-	inputTokens := estimateTokens(msg.GetText())
 	outputTokens := estimateTokens(response.GetText())
 
 	m.tokenUsage.LastMessage = chat.TokenUsageDetails{
@@ -94,6 +106,28 @@ func (m *mockChat) MaxTokens() int {
 	return m.maxTokens
 }
 
+func (m *mockChat) SetSystemPrompt(ctx context.Context, prompt string) error {
+	m.systemPrompt = prompt
+	return nil
+}
+
+func (m *mockChat) ContextLimit() int {
+	return m.contextLimit
+}
+
+func (m *mockChat) Model() string {
+	return "mock-model"
+}
+
+func (m *mockChat) EstimateRequest(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.RequestEstimate, error) {
+	m.lastOptions = chat.ApplyOptions(opts...)
+	bytes := len(m.systemPrompt) + len(msg.GetText())
+	for _, msg := range m.messages {
+		bytes += len(msg.GetText())
+	}
+	return chat.RequestEstimate{Bytes: bytes, Messages: len(m.messages) + 1}, nil
+}
+
 func (m *mockChat) RegisterTool(tool chat.Tool) error {
 	if m.tools == nil {
 		m.tools = make(map[string]func(context.Context, string) string)
@@ -116,7 +150,8 @@ func (m *mockChat) ListTools() []string {
 
 // mockClient implements chat.Client for testing
 type mockClient struct {
-	chats []*mockChat
+	chats        []*mockChat
+	contextLimit int
 }
 
 func (c *mockClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
@@ -124,6 +159,7 @@ func (c *mockClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) c
 		systemPrompt: systemPrompt,
 		messages:     append([]chat.Message{}, initialMsgs...),
 		maxTokens:    4096,
+		contextLimit: c.contextLimit,
 		tools:        make(map[string]func(context.Context, string) string),
 	}
 	c.chats = append(c.chats, chat)
@@ -305,6 +341,35 @@ func TestSessionTools(t *testing.T) {
 	assert.NotContains(t, tools, "test_tool")
 }
 
+func TestSessionWithTools(t *testing.T) {
+	client := &mockClient{}
+	store := persistence.NewMemoryStore()
+	tool := &mockTool{
+		name:   "test_tool",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, args string) string {
+			return "Tool result"
+		},
+	}
+
+	session, err := NewSession(client, "You are a helpful assistant", WithStore(store), WithTools(tool))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test_tool"}, session.ListTools())
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+
+	// A restored session gets its tools without registering them again
+	restored, err := NewSession(client, "You are a helpful assistant",
+		WithStore(store), WithRestoreSession(session.SessionID()), WithTools(tool))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test_tool"}, restored.ListTools())
+	_, err = restored.Message(context.Background(), chat.UserMessage("Again"))
+	require.NoError(t, err)
+	call := client.chats[len(client.chats)-1].tools["test_tool"]
+	require.NotNil(t, call)
+	assert.Equal(t, "Tool result", call(context.Background(), "{}"))
+}
+
 func TestSessionMetrics(t *testing.T) {
 	client := &mockClient{}
 	session, err := NewSession(client, "System")
@@ -325,6 +390,49 @@ func TestSessionMetrics(t *testing.T) {
 	assert.Less(t, metrics.PercentFull, 1.0)
 }
 
+func TestSessionMetricsContextLimit(t *testing.T) {
+	client := &mockClient{contextLimit: 200000}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hello"))
+	require.NoError(t, err)
+
+	metrics := session.Metrics()
+	assert.Equal(t, 200000, metrics.MaxTokens)
+	assert.InDelta(t, float64(metrics.LiveTokens)/200000, metrics.PercentFull, 1e-9)
+	assert.Equal(t, 4096, session.MaxTokens())
+}
+
+func TestSessionLiveTokensAfterCompaction(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System", WithSummarizer(NewSimpleSummarizer(1, 0)))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, err := session.Message(ctx, chat.UserMessage(strings.Repeat("Long message ", 50)))
+		require.NoError(t, err)
+	}
+
+	// Before compaction, the last exchange's reported usage is the context size
+	usage := client.chats[len(client.chats)-1].tokenUsage.LastMessage
+	before := session.Metrics().LiveTokens
+	assert.Equal(t, usage.InputTokens+usage.OutputTokens, before)
+
+	require.NoError(t, session.CompactNow())
+
+	after := session.Metrics().LiveTokens
+	assert.Equal(t, estimateRecordTokens(session.LiveRecords()), after)
+	assert.Less(t, after, before)
+
+	// The next exchange reports the compacted context size again
+	_, err = session.Message(ctx, chat.UserMessage("Short"))
+	require.NoError(t, err)
+	usage = client.chats[len(client.chats)-1].tokenUsage.LastMessage
+	assert.Equal(t, usage.InputTokens+usage.OutputTokens, session.Metrics().LiveTokens)
+}
+
 func TestSessionCompaction(t *testing.T) {
 	client := &mockClient{}
 	session, err := NewSession(client, "System prompt")
@@ -392,6 +500,76 @@ func TestManualCompaction(t *testing.T) {
 	assert.True(t, foundSummary, "Should have a summary record")
 }
 
+func TestSessionDryRun(t *testing.T) {
+	session, err := NewSession(&mockClient{}, "System")
+	require.NoError(t, err)
+	before := len(session.TotalRecords())
+
+	req, err := chat.DryRun(context.Background(), session, chat.UserMessage("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "mock://messages", req.URL)
+
+	// Nothing is recorded for a message that wasn't sent
+	assert.Len(t, session.TotalRecords(), before)
+}
+
+func TestSessionEstimateRequest(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System", WithDefaultOptions(chat.WithMaxTokens(100)))
+	require.NoError(t, err)
+	_, err = session.Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+	records := len(session.TotalRecords())
+
+	estimator, ok := session.(chat.RequestEstimator)
+	require.True(t, ok)
+	estimate, err := estimator.EstimateRequest(context.Background(), chat.UserMessage("hello"), chat.WithTemperature(0.5))
+	require.NoError(t, err)
+	assert.Equal(t, 3, estimate.Messages)
+	assert.Equal(t, len("System")+len("hi")+len("Response to: hi")+len("hello"), estimate.Bytes)
+
+	// The estimate uses the session's default options, and records nothing
+	lastChat := client.chats[len(client.chats)-1]
+	assert.Equal(t, 100, lastChat.lastOptions.MaxTokens)
+	require.NotNil(t, lastChat.lastOptions.Temperature)
+	assert.Equal(t, 0.5, *lastChat.lastOptions.Temperature)
+	assert.Len(t, session.TotalRecords(), records)
+}
+
+// languageSummarizer records the language in the contexts it is called with.
+type languageSummarizer struct {
+	languages []string
+}
+
+func (s *languageSummarizer) Summarize(ctx context.Context, records []persistence.Record) (string, error) {
+	s.languages = append(s.languages, LanguageFromContext(ctx))
+	return "résumé", nil
+}
+
+func (s *languageSummarizer) SetPrompt(prompt string) {}
+
+func TestSessionLanguage(t *testing.T) {
+	summarizer := &languageSummarizer{}
+	session, err := NewSession(&mockClient{}, "System", WithSummarizer(summarizer), WithLanguage("French"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, session.CompactNow())
+
+	assert.Equal(t, []string{"French"}, summarizer.languages)
+	var summary string
+	for _, r := range session.LiveRecords() {
+		if strings.HasPrefix(r.GetText(), summaryPrefix) {
+			summary = r.GetText()
+		}
+	}
+	assert.Equal(t, summaryPrefix+"résumé", summary)
+}
+
 func TestSessionTokenTracking(t *testing.T) {
 	client := &mockClient{}
 	session, err := NewSession(client, "System")
@@ -434,6 +612,61 @@ func TestSessionTokenTracking(t *testing.T) {
 	assert.True(t, foundUserMsg, "Should find the user message in records")
 }
 
+func TestAssignRoundTokens(t *testing.T) {
+	rounds := []chat.TokenUsageDetails{
+		{InputTokens: 100, OutputTokens: 20, TotalTokens: 120},
+		{InputTokens: 150, OutputTokens: 10, TotalTokens: 160},
+	}
+
+	t.Run("tool round", func(t *testing.T) {
+		records := []persistence.Record{
+			{Role: chat.UserRole},
+			{Role: chat.AssistantRole},
+			{Role: chat.ToolRole},
+			{Role: chat.AssistantRole},
+		}
+		assignRoundTokens(records, rounds)
+
+		assert.Equal(t, 100, records[0].InputTokens)
+		assert.Equal(t, 20, records[1].OutputTokens)
+		assert.Equal(t, 30, records[2].InputTokens) // 150 - (100 + 20)
+		assert.Equal(t, 10, records[3].OutputTokens)
+
+		// The records add up to the context size after the last round
+		total := 0
+		for _, r := range records {
+			total += r.InputTokens + r.OutputTokens
+		}
+		assert.Equal(t, 160, total)
+	})
+
+	t.Run("intermediate messages not kept", func(t *testing.T) {
+		records := []persistence.Record{
+			{Role: chat.UserRole},
+			{Role: chat.AssistantRole},
+		}
+		assignRoundTokens(records, rounds)
+
+		assert.Equal(t, 100, records[0].InputTokens)
+		assert.Equal(t, 10, records[1].OutputTokens)
+	})
+
+	t.Run("single round for several assistant messages", func(t *testing.T) {
+		records := []persistence.Record{
+			{Role: chat.UserRole},
+			{Role: chat.AssistantRole},
+			{Role: chat.ToolRole},
+			{Role: chat.AssistantRole},
+		}
+		assignRoundTokens(records, rounds[:1])
+
+		assert.Equal(t, 100, records[0].InputTokens)
+		assert.Equal(t, 0, records[1].OutputTokens)
+		assert.Equal(t, 0, records[2].InputTokens)
+		assert.Equal(t, 20, records[3].OutputTokens)
+	})
+}
+
 func TestSessionRecordTimestamps(t *testing.T) {
 	client := &mockClient{}
 	session, err := NewSession(client, "System")
@@ -854,3 +1087,66 @@ func TestBuildHistoryFiltersEmptyMessagesAfterSystemReminderRemoval(t *testing.T
 		assert.True(t, hasNonEmptyContent, "Each message should have at least one non-empty content block")
 	}
 }
+
+func TestSessionDefaultOptions(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "", WithDefaultOptions(chat.WithTemperature(0.2), chat.WithMaxTokens(2000)))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	opts := client.chats[len(client.chats)-1].lastOptions
+	require.NotNil(t, opts.Temperature)
+	assert.Equal(t, 0.2, *opts.Temperature)
+	assert.Equal(t, 2000, opts.MaxTokens)
+
+	// Per-call options override the defaults
+	_, err = session.Message(ctx, chat.UserMessage("Again"), chat.WithMaxTokens(100), chat.WithReasoningEffort("low"))
+	require.NoError(t, err)
+	opts = client.chats[len(client.chats)-1].lastOptions
+	require.NotNil(t, opts.Temperature)
+	assert.Equal(t, 0.2, *opts.Temperature)
+	assert.Equal(t, 100, opts.MaxTokens)
+	assert.Equal(t, "low", opts.ReasoningEffort)
+}
+
+func TestSessionRecordsUser(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "You are a helpful assistant", WithDefaultOptions(chat.WithUser("alice")))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = session.Message(ctx, chat.UserMessage("Hello"))
+	require.NoError(t, err)
+	_, err = session.Message(ctx, chat.UserMessage("Again"), chat.WithUser("bob"))
+	require.NoError(t, err)
+	assert.Equal(t, "bob", client.chats[len(client.chats)-1].lastOptions.User)
+
+	var users []string
+	for _, r := range session.LiveRecords() {
+		users = append(users, r.User)
+	}
+	assert.Equal(t, []string{"", "alice", "alice", "bob", "bob"}, users)
+}
+
+func TestSessionRecordsRequestOptions(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "You are a helpful assistant", WithDefaultOptions(chat.WithMaxTokens(100)))
+	require.NoError(t, err)
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hello"),
+		chat.WithTemperature(0.2), chat.WithJSONMode(), chat.WithStreamingCb(func(chat.StreamEvent) error { return nil }))
+	require.NoError(t, err)
+
+	records := session.LiveRecords()
+	require.Len(t, records, 3)
+	temperature := 0.2
+	assert.Equal(t, &persistence.RequestOptions{
+		Model:       "mock-model",
+		Temperature: &temperature,
+		MaxTokens:   100,
+		JSONMode:    true,
+	}, records[1].Options)
+	assert.Nil(t, records[2].Options)
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/toolproc"
+)
+
+func TestSessionToolIsolation(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("needs a shell to stand in for the tool subprocess")
+	}
+
+	client := &mockClient{}
+	// The subprocess answers every call the same way
+	runner := &toolproc.Runner{Path: sh, Args: []string{"-c", `echo '{"output": "from subprocess"}'`}}
+	session, err := NewSession(client, "System", WithToolIsolation(runner))
+	require.NoError(t, err)
+
+	inProcess := false
+	require.NoError(t, session.RegisterTool(&mockTool{
+		name:   "echo",
+		schema: `{"type": "object"}`,
+		callFn: func(ctx context.Context, input string) string {
+			inProcess = true
+			return input
+		},
+	}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+	tempChat := client.chats[len(client.chats)-1]
+
+	assert.Equal(t, "from subprocess", tempChat.tools["echo"](context.Background(), "{}"))
+	assert.False(t, inProcess)
+}
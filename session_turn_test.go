@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// gatedClient returns chats whose Message calls signal started, then wait
+// for gate to be closed.
+type gatedClient struct {
+	mockClient
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (c *gatedClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
+	return &gatedChat{Chat: c.mockClient.NewChat(systemPrompt, initialMsgs...), client: c}
+}
+
+type gatedChat struct {
+	chat.Chat
+	client *gatedClient
+}
+
+func (c *gatedChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	c.client.started <- struct{}{}
+	<-c.client.gate
+	return c.Chat.Message(ctx, msg, opts...)
+}
+
+func TestSessionSerializesMessages(t *testing.T) {
+	client := &gatedClient{started: make(chan struct{}, 2), gate: make(chan struct{})}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	send := func(text string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := session.Message(ctx, chat.UserMessage(text))
+			assert.NoError(t, err)
+		}()
+	}
+
+	send("First")
+	<-client.started
+	send("Second")
+
+	// The second message waits for the first to finish
+	select {
+	case <-client.started:
+		t.Fatal("second message started before the first finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(client.gate)
+	wg.Wait()
+
+	// Both exchanges are recorded
+	assert.Len(t, session.LiveRecords(), 5)
+}
+
+func TestSessionQueueTimeout(t *testing.T) {
+	client := &gatedClient{started: make(chan struct{}, 2), gate: make(chan struct{})}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := session.Message(ctx, chat.UserMessage("First"))
+		assert.NoError(t, err)
+	}()
+	<-client.started
+
+	_, err = session.Message(ctx, chat.UserMessage("Second"), chat.WithQueueTimeout(10*time.Millisecond))
+	require.ErrorIs(t, err, chat.ErrBusy)
+
+	_, err = session.AmendLastUserMessage(ctx, chat.UserMessage("Amended"), chat.WithQueueTimeout(10*time.Millisecond))
+	require.ErrorIs(t, err, chat.ErrBusy)
+
+	close(client.gate)
+	<-done
+	assert.Len(t, session.LiveRecords(), 3)
+}
@@ -21,6 +21,29 @@ type Summarizer interface {
 	SetPrompt(prompt string)
 }
 
+// languageKey is the context key for the language of generated text
+type languageKey struct{}
+
+// ContextWithLanguage attaches language, such as "French" or "Japanese", to
+// the context as the language that text generated for a session, like
+// compaction summaries, should be written in. Sessions created with
+// WithLanguage attach it to the contexts they pass to their Compactor, and
+// the summarizers returned by NewSummarizer follow it; custom Summarizers
+// can read it with LanguageFromContext.
+func ContextWithLanguage(ctx context.Context, language string) context.Context {
+	if language == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// LanguageFromContext returns the language attached to the context by
+// ContextWithLanguage, or "" if there is none.
+func LanguageFromContext(ctx context.Context) string {
+	language, _ := ctx.Value(languageKey{}).(string)
+	return language
+}
+
 // llmSummarizer uses an LLM to create intelligent conversation summaries.
 type llmSummarizer struct {
 	client chat.Client // client configured with the model to use for summarization
@@ -55,7 +78,11 @@ func (s *llmSummarizer) Summarize(ctx context.Context, records []persistence.Rec
 	}
 
 	// Create summarization request
-	summaryPrompt := fmt.Sprintf("%s\n\nConversation to summarize:\n%s", s.prompt, conversation.String())
+	prompt := s.prompt
+	if language := LanguageFromContext(ctx); language != "" {
+		prompt += fmt.Sprintf("\n\nWrite the summary in %s.", language)
+	}
+	summaryPrompt := fmt.Sprintf("%s\n\nConversation to summarize:\n%s", prompt, conversation.String())
 
 	// Create a chat session with the summarization model
 	summaryChat := s.client.NewChat("You are an assistant tasked with summarizing conversations.")
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/bpowers/go-agent/chat"
 	"github.com/bpowers/go-agent/persistence"
@@ -64,21 +65,26 @@ func TestSimpleSummarizerEmptyRecords(t *testing.T) {
 // mockSummarizerClient for testing LLMSummarizer
 type mockSummarizerClient struct {
 	response string
+	// prompts records the messages sent to the chats
+	prompts []string
 }
 
 func (m *mockSummarizerClient) NewChat(systemPrompt string, initialMsgs ...chat.Message) chat.Chat {
 	return &mockSummarizerChat{
+		client:       m,
 		systemPrompt: systemPrompt,
 		response:     m.response,
 	}
 }
 
 type mockSummarizerChat struct {
+	client       *mockSummarizerClient
 	systemPrompt string
 	response     string
 }
 
 func (m *mockSummarizerChat) Message(ctx context.Context, msg chat.Message, opts ...chat.Option) (chat.Message, error) {
+	m.client.prompts = append(m.client.prompts, msg.GetText())
 	return chat.AssistantMessage(m.response), nil
 }
 
@@ -94,6 +100,11 @@ func (m *mockSummarizerChat) MaxTokens() int {
 	return 4096
 }
 
+func (m *mockSummarizerChat) SetSystemPrompt(ctx context.Context, prompt string) error {
+	m.systemPrompt = prompt
+	return nil
+}
+
 func (m *mockSummarizerChat) RegisterTool(tool chat.Tool) error {
 	return nil
 }
@@ -140,6 +151,24 @@ func TestLLMSummarizerCustomPrompt(t *testing.T) {
 	assert.Equal(t, "Brief summary", summary)
 }
 
+func TestLLMSummarizerLanguage(t *testing.T) {
+	mockClient := &mockSummarizerClient{response: "Résumé"}
+	summarizer := NewSummarizer(mockClient)
+	records := []persistence.Record{
+		{Role: chat.UserRole, Contents: []chat.Content{{Text: "Bonjour"}}},
+	}
+
+	_, err := summarizer.Summarize(context.Background(), records)
+	require.NoError(t, err)
+	_, err = summarizer.Summarize(ContextWithLanguage(context.Background(), "French"), records)
+	require.NoError(t, err)
+
+	require.Len(t, mockClient.prompts, 2)
+	assert.NotContains(t, mockClient.prompts[0], "Write the summary in")
+	assert.Contains(t, mockClient.prompts[1], "Write the summary in French.")
+	assert.Contains(t, mockClient.prompts[1], "Bonjour")
+}
+
 func TestLLMSummarizerEmptyRecords(t *testing.T) {
 	mockClient := &mockSummarizerClient{
 		response: "",
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"github.com/bpowers/go-agent/internal/tokens"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// messageOverheadTokens approximates the per-message framing (role markers,
+// separators) that providers add around each message.
+const messageOverheadTokens = 4
+
+// estimateRecordTokens estimates the number of tokens the records take up in the
+// context window. It is used when provider-reported usage does not describe
+// the current context, such as right after compaction. System reminders are
+// skipped, as they are not replayed to the LLM.
+func estimateRecordTokens(records []persistence.Record) int {
+	total := 0
+	for _, r := range records {
+		chars := 0
+		for _, c := range r.Contents {
+			chars += len(c.Text)
+			if c.ToolCall != nil {
+				chars += len(c.ToolCall.Name) + len(c.ToolCall.Arguments)
+			}
+			if c.ToolResult != nil {
+				chars += len(c.ToolResult.Content) + len(c.ToolResult.Error)
+			}
+			if c.Thinking != nil {
+				chars += len(c.Thinking.Text) + len(c.Thinking.RedactedData)
+			}
+		}
+		total += messageOverheadTokens + tokens.FromChars(chars)
+	}
+	return total
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/schema"
+)
+
+// invalidOutputCode is the error code reported to the model for a tool
+// result that doesn't match the tool's output schema.
+const invalidOutputCode = "invalid_tool_output"
+
+// ToolOutputValidation configures WithToolOutputValidation.
+type ToolOutputValidation struct {
+	// Repair, if set, is asked to fix results that don't match their
+	// schema. A small, fast model is usually enough. Results it can't fix
+	// are reported as errors.
+	Repair chat.Client
+}
+
+// WithToolOutputValidation checks the results of tools whose MCP JSON
+// schemas have an outputSchema against it before the model sees them. A
+// result that doesn't match is replaced with a structured tool error
+// saying how, unless validation.Repair fixes it, so the model doesn't act
+// on malformed data. Results reporting an error, with a top-level "error"
+// string, are passed through unchecked.
+func WithToolOutputValidation(validation ToolOutputValidation) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.outputValidation = &validation
+	}
+}
+
+// outputSchema returns the output schema in tool's MCP JSON schema, or nil
+// if it doesn't have one.
+func outputSchema(tool chat.ToolDef) *schema.JSON {
+	var mcp struct {
+		OutputSchema *schema.JSON `json:"outputSchema"`
+	}
+	if err := json.Unmarshal([]byte(tool.MCPJsonSchema()), &mcp); err != nil {
+		return nil
+	}
+	return mcp.OutputSchema
+}
+
+// validatedTool checks the results of the tool it wraps against its output
+// schema.
+type validatedTool struct {
+	chat.Tool
+	schema *schema.JSON
+	repair chat.Client
+}
+
+func (t *validatedTool) Call(ctx context.Context, input string) string {
+	return t.check(ctx, t.Tool.Call(ctx, input))
+}
+
+// CallWithImages checks the result like Call, passing the wrapped tool's
+// images through.
+func (t *validatedTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	it, ok := t.Tool.(chat.ImageTool)
+	if !ok {
+		return t.Call(ctx, input), nil
+	}
+	result, images := it.CallWithImages(ctx, input)
+	return t.check(ctx, result), images
+}
+
+// check returns result if it matches the tool's output schema, and
+// otherwise its repair or an error.
+func (t *validatedTool) check(ctx context.Context, result string) string {
+	verr := t.schema.Validate([]byte(result))
+	if verr == nil || isErrorResult(result) {
+		return result
+	}
+
+	if t.repair != nil {
+		repaired, err := repairOutput(ctx, t.repair, t.schema, result, verr)
+		if err == nil {
+			logger.InfoContext(ctx, "repaired tool result", "tool", t.Name(), "problem", verr)
+			return repaired
+		}
+		logger.WarnContext(ctx, "failed to repair tool result", "tool", t.Name(), "error", err)
+	}
+	logger.WarnContext(ctx, "tool result doesn't match its output schema", "tool", t.Name(), "problem", verr)
+
+	out, _ := json.Marshal(map[string]any{
+		"error":     fmt.Sprintf("%s returned a result that doesn't match its output schema: %s", t.Name(), verr),
+		"errorCode": invalidOutputCode,
+		"retryable": false,
+	})
+	return string(out)
+}
+
+// isErrorResult reports whether a tool result reports an error.
+func isErrorResult(result string) bool {
+	var r struct {
+		Error *string `json:"error"`
+	}
+	return json.Unmarshal([]byte(result), &r) == nil && r.Error != nil && *r.Error != ""
+}
+
+const repairPrompt = `You fix JSON documents that don't match their JSON schema. Change as little as possible: convert values to the right types, rename or remove misplaced properties, and add missing ones from what's in the document. Never invent data the document doesn't contain. Reply with only the fixed JSON document.`
+
+// repairOutput asks client to fix result so it matches s, returning the
+// fixed result if it does.
+func repairOutput(ctx context.Context, client chat.Client, s *schema.JSON, result string, problem error) (string, error) {
+	schemaJSON, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal output schema: %w", err)
+	}
+	msg := fmt.Sprintf("Schema:\n%s\n\nDocument:\n%s\n\nProblem: %s", schemaJSON, result, problem)
+	resp, err := client.NewChat(repairPrompt).Message(ctx, chat.UserMessage(msg), chat.WithJSONMode())
+	if err != nil {
+		return "", fmt.Errorf("repair failed: %w", err)
+	}
+
+	repaired := strings.TrimSpace(resp.GetText())
+	if fenced, ok := strings.CutPrefix(repaired, "```"); ok {
+		fenced = strings.TrimPrefix(fenced, "json")
+		repaired = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(fenced), "```"))
+	}
+	if err := s.Validate([]byte(repaired)); err != nil {
+		return "", fmt.Errorf("the repaired result doesn't match either: %w", err)
+	}
+	return repaired, nil
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+const lookupSchema = `{"name": "Lookup", "inputSchema": {"type": "object"}, "outputSchema": {"type": "object", "properties": {"count": {"type": "integer"}, "error": {"type": ["string", "null"]}}, "required": ["count"], "additionalProperties": false}}`
+
+// lookupTools returns the Lookup tool, as the model sees it in a session
+// created with opts, which returns its input as its result, and a tool
+// without an output schema that does the same.
+func lookupTools(t *testing.T, opts ...SessionOption) map[string]func(context.Context, string) string {
+	t.Helper()
+
+	client := &mockClient{}
+	session, err := NewSession(client, "System", opts...)
+	require.NoError(t, err)
+	echo := func(ctx context.Context, input string) string { return input }
+	require.NoError(t, session.RegisterTool(&mockTool{name: "Lookup", schema: lookupSchema, callFn: echo}))
+	require.NoError(t, session.RegisterTool(&mockTool{name: "Echo", schema: `{"name": "Echo", "inputSchema": {"type": "object"}}`, callFn: echo}))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+	return client.chats[len(client.chats)-1].tools
+}
+
+func TestToolOutputValidation(t *testing.T) {
+	t.Parallel()
+
+	tools := lookupTools(t, WithToolOutputValidation(ToolOutputValidation{}))
+	lookup := tools["Lookup"]
+
+	assert.Equal(t, `{"count": 3}`, lookup(context.Background(), `{"count": 3}`))
+	assert.Equal(t, `{"error": "not found"}`, lookup(context.Background(), `{"error": "not found"}`), "errors aren't checked")
+	assert.Equal(t, `{"count": "3"}`, tools["Echo"](context.Background(), `{"count": "3"}`), "tools without output schemas aren't checked")
+
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lookup(context.Background(), `{"count": "3"}`)), &result))
+	assert.Equal(t, invalidOutputCode, result["errorCode"])
+	assert.Equal(t, false, result["retryable"])
+	assert.Equal(t, "Lookup returned a result that doesn't match its output schema: $.count: expected integer, got string", result["error"])
+
+	require.NoError(t, json.Unmarshal([]byte(lookup(context.Background(), `not json`)), &result))
+	assert.Equal(t, invalidOutputCode, result["errorCode"])
+
+	// Without the option, results aren't checked
+	tools = lookupTools(t)
+	assert.Equal(t, `{"count": "3"}`, tools["Lookup"](context.Background(), `{"count": "3"}`))
+}
+
+func TestToolOutputRepair(t *testing.T) {
+	t.Parallel()
+
+	repairer := &scriptedClient{replies: []string{"```json\n{\"count\": 3}\n```", `{"count": "three"}`}}
+	lookup := lookupTools(t, WithToolOutputValidation(ToolOutputValidation{Repair: repairer}))["Lookup"]
+
+	assert.Equal(t, `{"count": 3}`, lookup(context.Background(), `{"count": "3"}`))
+	require.Len(t, repairer.chats, 1)
+	sent := repairer.chats[0].messages[0].GetText()
+	assert.Contains(t, sent, `{"count": "3"}`)
+	assert.Contains(t, sent, "$.count: expected integer, got string")
+	assert.True(t, repairer.chats[0].lastOptions.JSONMode)
+
+	// Repairs that still don't match are reported as errors
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lookup(context.Background(), `{"count": "3"}`)), &result))
+	assert.Equal(t, invalidOutputCode, result["errorCode"])
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// ToolPolicy restricts the tools offered to some models, such as keeping
+// tools that write files or spend money away from cheap or untrusted
+// models. Models, Allow, and Deny hold path.Match patterns like "gpt-4o-*"
+// or "read_*".
+type ToolPolicy struct {
+	// Models lists the models the policy applies to, by the names their
+	// chats report with chat.ModelReporter. If empty, the policy applies to
+	// every model, including chats that don't report one.
+	Models []string
+	// Allow lists the permitted tools; if empty, all tools are permitted.
+	Allow []string
+	// Deny lists forbidden tools, and takes precedence over Allow.
+	Deny []string
+}
+
+// WithToolPolicy restricts the registered tools offered to the LLM in each
+// request by policies. Every policy that applies to the request's model
+// must permit a tool for it to be offered; the tools filtered out are
+// logged. NewSession returns an error if a pattern is malformed.
+func WithToolPolicy(policies ...ToolPolicy) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.toolPolicies = append(opts.toolPolicies, policies...)
+	}
+}
+
+func (p ToolPolicy) validate() error {
+	for _, pattern := range slices.Concat(p.Models, p.Allow, p.Deny) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the policy applies to model, which is "" if
+// the chat doesn't report its model.
+func (p ToolPolicy) appliesTo(model string) bool {
+	if len(p.Models) == 0 {
+		return true
+	}
+	return matchAny(p.Models, model)
+}
+
+// allowed reports whether the policy permits the named tool.
+func (p ToolPolicy) allowed(name string) bool {
+	if matchAny(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchAny(p.Allow, name)
+}
+
+// toolAllowed reports whether every policy that applies to model permits
+// the named tool.
+func toolAllowed(policies []ToolPolicy, model, name string) bool {
+	for _, p := range policies {
+		if p.appliesTo(model) && !p.allowed(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
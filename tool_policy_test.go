@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestToolAllowed(t *testing.T) {
+	policies := []ToolPolicy{
+		{Models: []string{"cheap-*"}, Allow: []string{"read_*"}},
+		{Deny: []string{"delete_*"}},
+	}
+
+	assert.True(t, toolAllowed(policies, "cheap-mini", "read_file"))
+	assert.False(t, toolAllowed(policies, "cheap-mini", "write_file"))
+	assert.False(t, toolAllowed(policies, "cheap-mini", "delete_file"))
+	assert.True(t, toolAllowed(policies, "big-model", "write_file"))
+	assert.False(t, toolAllowed(policies, "big-model", "delete_file"))
+	// A chat that doesn't report its model gets only the unscoped policies
+	assert.True(t, toolAllowed(policies, "", "write_file"))
+	assert.True(t, toolAllowed(nil, "cheap-mini", "anything"))
+}
+
+func TestSessionToolPolicy(t *testing.T) {
+	newTool := func(name string) chat.Tool {
+		return &mockTool{name: name, schema: `{"type":"object"}`, callFn: func(context.Context, string) string { return "" }}
+	}
+
+	client := &mockClient{}
+	session, err := NewSession(client, "System",
+		WithMaxToolResultSize(1000),
+		WithToolPolicy(
+			ToolPolicy{Models: []string{"mock-*"}, Deny: []string{"write_*"}},
+			ToolPolicy{Models: []string{"other-model"}, Deny: []string{"read_*"}},
+		))
+	require.NoError(t, err)
+	require.NoError(t, session.RegisterTool(newTool("read_file")))
+	require.NoError(t, session.RegisterTool(newTool("write_file")))
+
+	_, err = session.Message(context.Background(), chat.UserMessage("hi"))
+	require.NoError(t, err)
+
+	offered := client.chats[len(client.chats)-1].ListTools()
+	assert.ElementsMatch(t, []string{"read_file", ReadArtifactToolName}, offered)
+	// The session still lists every registered tool
+	assert.ElementsMatch(t, []string{"read_file", "write_file"}, session.ListTools())
+}
+
+func TestSessionToolPolicyInvalid(t *testing.T) {
+	_, err := NewSession(&mockClient{}, "System", WithToolPolicy(ToolPolicy{Allow: []string{"["}}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid tool policy")
+}
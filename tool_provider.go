@@ -0,0 +1,30 @@
+package agent
+
+import "github.com/bpowers/go-agent/chat"
+
+// ToolProvider supplies a session's tools. Unlike tools registered with
+// RegisterTool, which last only as long as the Session value, a provider is
+// consulted every time a session is created from the same options: when it
+// is restored with WithRestoreSession, opened from a CheckpointAt branch,
+// or cloned with CloneSession, so every copy of a conversation has the
+// same tools without its caller having to register them.
+type ToolProvider interface {
+	Tools() []chat.Tool
+}
+
+// ToolProviderFunc adapts a function to a ToolProvider.
+type ToolProviderFunc func() []chat.Tool
+
+// Tools implements ToolProvider.
+func (f ToolProviderFunc) Tools() []chat.Tool {
+	return f()
+}
+
+// WithToolProvider registers provider's tools on the session as it is
+// created, after any given with WithTools. CloneSession passes the
+// provider on to the clone unless the clone's options set their own.
+func WithToolProvider(provider ToolProvider) SessionOption {
+	return func(opts *sessionOptions) {
+		opts.toolProvider = provider
+	}
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+func TestToolProvider(t *testing.T) {
+	// Each call hands out fresh tools whose results say which call made them
+	calls := 0
+	provider := ToolProviderFunc(func() []chat.Tool {
+		calls++
+		n := calls
+		return []chat.Tool{&mockTool{
+			name:   "lookup",
+			schema: `{"type": "object"}`,
+			callFn: func(ctx context.Context, input string) string { return fmt.Sprintf("provided %d", n) },
+		}}
+	})
+	extra := &mockTool{name: "extra", schema: `{"type": "object"}`}
+
+	client := &mockClient{}
+	store := persistence.NewMemoryStore()
+	opts := []SessionOption{WithStore(store), WithToolProvider(provider), WithTools(extra)}
+	session, err := NewSession(client, "System", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.ElementsMatch(t, []string{"lookup", "extra"}, session.ListTools())
+
+	_, err = session.Message(context.Background(), chat.UserMessage("Hi"))
+	require.NoError(t, err)
+	records := session.LiveRecords()
+
+	// Restoring consults the provider again
+	restored, err := NewSession(client, "System", append(slices.Clip(opts), WithRestoreSession(session.SessionID()))...)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.ElementsMatch(t, []string{"lookup", "extra"}, restored.ListTools())
+
+	// So does opening a branch
+	branchID, err := session.CheckpointAt(records[len(records)-1].ID)
+	require.NoError(t, err)
+	branch, err := NewSession(client, "System", append(slices.Clip(opts), WithRestoreSession(branchID))...)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.ElementsMatch(t, []string{"lookup", "extra"}, branch.ListTools())
+
+	// Clones get the provider's tools, not the source's copies
+	cloneClient := &mockClient{}
+	clone, err := CloneSession(session, cloneClient)
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+	assert.ElementsMatch(t, []string{"lookup", "extra"}, clone.ListTools())
+	_, err = clone.Message(context.Background(), chat.UserMessage("Again"))
+	require.NoError(t, err)
+	lookup := cloneClient.chats[len(cloneClient.chats)-1].tools["lookup"]
+	require.NotNil(t, lookup)
+	assert.Equal(t, "provided 4", lookup(context.Background(), "{}"))
+
+	// Unless the clone is given its own provider
+	_, err = CloneSession(session, &mockClient{}, WithToolProvider(ToolProviderFunc(func() []chat.Tool { return nil })))
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package toolproc
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setLimits fails if any limits are set, as they aren't supported here.
+func setLimits(l Limits) error {
+	if l != (Limits{}) {
+		return fmt.Errorf("resource limits aren't supported on %s", runtime.GOOS)
+	}
+	return nil
+}
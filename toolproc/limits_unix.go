@@ -0,0 +1,33 @@
+//go:build linux || darwin
+
+package toolproc
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// setLimits applies l to the current process.
+func setLimits(l Limits) error {
+	seconds := uint64((l.CPUTime + time.Second - 1) / time.Second)
+	for _, limit := range []struct {
+		resource int
+		name     string
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, "CPU time", seconds},
+		{syscall.RLIMIT_AS, "memory", l.Memory},
+		{syscall.RLIMIT_NOFILE, "open files", l.OpenFiles},
+		{syscall.RLIMIT_FSIZE, "file size", l.FileSize},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+		rlimit := syscall.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+			return fmt.Errorf("failed to limit %s: %w", limit.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+// Package toolproc runs tools in subprocesses, so a tool that crashes,
+// hangs, or exhausts memory takes down only its own process rather than the
+// agent.
+//
+// Each call starts a new process, by default the agent's own executable,
+// and passes it the call as JSON over stdin. The process recognizes that it
+// was started to run a tool when it calls Serve, which programs do at the
+// start of main with the tools they run in subprocesses:
+//
+//	func main() {
+//		ctx := fstools.WithFS(context.Background(), os.DirFS("."))
+//		toolproc.Serve(ctx, fstools.ReadFileTool, fstools.ReadDirTool)
+//		...
+//	}
+//
+// The subprocess gets a restricted environment, without the agent's API
+// keys, and can be given resource limits.
+package toolproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// envVar is set in the environment of tool subprocesses, holding their
+// Limits as JSON.
+const envVar = "GO_AGENT_TOOLPROC"
+
+// ErrorCode is the error code reported to the model when a tool's
+// subprocess fails.
+const ErrorCode = "tool_process_failed"
+
+// defaultMaxOutput is the most bytes read from a subprocess by default.
+const defaultMaxOutput = 16 << 20
+
+// Limits are resource limits a tool subprocess sets on itself before it
+// runs the tool. Zero fields aren't limited. Limits are only supported on
+// Linux and macOS; elsewhere, subprocesses given limits refuse to run.
+type Limits struct {
+	// CPUTime limits the CPU time the process may use, in whole seconds.
+	CPUTime time.Duration `json:"cpuTime,omitzero"`
+	// Memory limits the process's address space, in bytes. Go programs
+	// reserve address space beyond what they use, so leave room for the
+	// runtime.
+	Memory uint64 `json:"memory,omitzero"`
+	// OpenFiles limits the number of files the process may have open.
+	OpenFiles uint64 `json:"openFiles,omitzero"`
+	// FileSize limits the size of files the process writes, in bytes.
+	FileSize uint64 `json:"fileSize,omitzero"`
+}
+
+// Runner runs tool calls in subprocesses. The zero value runs them in the
+// current executable with no environment, limits, or timeout.
+type Runner struct {
+	// Path is the program to run, by default the current executable. It
+	// must call Serve with the tools it is asked to run.
+	Path string
+	// Args are the program's arguments.
+	Args []string
+	// Env is the subprocess's environment, to which the variable Serve
+	// looks for is added. The agent's own environment isn't passed on.
+	Env []string
+	// Dir is the subprocess's working directory, by default the agent's.
+	Dir string
+	// Timeout limits how long each call may run before its process is
+	// killed. Zero leaves calls limited only by their context.
+	Timeout time.Duration
+	// Limits are the subprocess's resource limits.
+	Limits Limits
+	// MaxOutput limits how many bytes of output are read from the
+	// subprocess, by default 16 MiB.
+	MaxOutput int64
+}
+
+// request is a tool call sent to a subprocess.
+type request struct {
+	Tool  string `json:"tool"`
+	Input string `json:"input"`
+}
+
+// response is a subprocess's result for a tool call.
+type response struct {
+	Output string              `json:"output"`
+	Images []chat.ImageContent `json:"images,omitzero"`
+	Error  string              `json:"error,omitzero"`
+}
+
+// Call runs the named tool in a subprocess, returning its result or an
+// error if the subprocess failed.
+func (r *Runner) Call(ctx context.Context, tool, input string) (string, []chat.ImageContent, error) {
+	path := r.Path
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to find executable: %w", err)
+		}
+		path = exe
+	}
+	limits, err := json.Marshal(r.Limits)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal limits: %w", err)
+	}
+	req, err := json.Marshal(request{Tool: tool, Input: input})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	maxOutput := r.MaxOutput
+	if maxOutput <= 0 {
+		maxOutput = defaultMaxOutput
+	}
+
+	cmd := exec.CommandContext(ctx, path, r.Args...)
+	cmd.Env = append(append([]string(nil), r.Env...), envVar+"="+string(limits))
+	cmd.Dir = r.Dir
+	cmd.Stdin = bytes.NewReader(req)
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: 4 << 10}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait on pipes held open by the tool's own children
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); errors.Is(ctxErr, context.DeadlineExceeded) && r.Timeout > 0 {
+			return "", nil, fmt.Errorf("timed out after %s", r.Timeout)
+		} else if ctxErr != nil {
+			return "", nil, ctxErr
+		}
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return "", nil, fmt.Errorf("%w: %s", err, failureLine(msg))
+		}
+		return "", nil, err
+	}
+	if stdout.truncated {
+		return "", nil, fmt.Errorf("output exceeded %d bytes", maxOutput)
+	}
+
+	var resp response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return "", nil, fmt.Errorf("failed to parse subprocess output: %w", err)
+	}
+	if resp.Error != "" {
+		return "", nil, errors.New(resp.Error)
+	}
+	return resp.Output, resp.Images, nil
+}
+
+// Isolate returns a tool that runs each call of tool in a subprocess. The
+// subprocess must serve a tool with the same name. Failures of the
+// subprocess are reported to the model as non-retryable tool errors.
+func (r *Runner) Isolate(tool chat.Tool) chat.Tool {
+	return &isolatedTool{Tool: tool, runner: r}
+}
+
+// isolatedTool runs the tool it wraps in subprocesses.
+type isolatedTool struct {
+	chat.Tool
+	runner *Runner
+}
+
+func (t *isolatedTool) Call(ctx context.Context, input string) string {
+	output, _ := t.CallWithImages(ctx, input)
+	return output
+}
+
+// CallWithImages runs the call in a subprocess, passing back any images
+// the tool returns.
+func (t *isolatedTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	output, images, err := t.runner.Call(ctx, t.Name(), input)
+	if err != nil {
+		result, _ := json.Marshal(map[string]any{
+			"error":     fmt.Sprintf("%s failed in its subprocess: %s", t.Name(), err),
+			"errorCode": ErrorCode,
+			"retryable": false,
+		})
+		return string(result), nil
+	}
+	return output, images
+}
+
+// Serve runs a tool call and exits if the process was started by a Runner,
+// and otherwise returns immediately. Tools are called with ctx, as context
+// values like the workspace don't cross into the subprocess.
+func Serve(ctx context.Context, tools ...chat.Tool) {
+	limits, ok := os.LookupEnv(envVar)
+	if !ok {
+		return
+	}
+	if err := serve(ctx, limits, os.Stdin, os.Stdout, tools); err != nil {
+		fmt.Fprintf(os.Stderr, "toolproc: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// serve applies limits and runs the tool call read from r, writing the
+// response to w.
+func serve(ctx context.Context, limits string, r io.Reader, w io.Writer, tools []chat.Tool) error {
+	var l Limits
+	if err := json.Unmarshal([]byte(limits), &l); err != nil {
+		return fmt.Errorf("failed to parse limits: %w", err)
+	}
+	if err := setLimits(l); err != nil {
+		return fmt.Errorf("failed to set limits: %w", err)
+	}
+
+	var req request
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+
+	var resp response
+	var tool chat.Tool
+	for _, t := range tools {
+		if t.Name() == req.Tool {
+			tool = t
+			break
+		}
+	}
+	if tool == nil {
+		resp.Error = fmt.Sprintf("tool %q isn't served by this process", req.Tool)
+	} else if it, ok := tool.(chat.ImageTool); ok {
+		resp.Output, resp.Images = it.CallWithImages(ctx, req.Input)
+	} else {
+		resp.Output = tool.Call(ctx, req.Input)
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards
+// the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+		b.truncated = true
+		b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// failureLine returns the line of a subprocess's stderr that best
+// describes its failure: a crashed Go program's panic or fatal error, or
+// otherwise the last line.
+func failureLine(stderr []byte) string {
+	lines := bytes.Split(stderr, []byte("\n"))
+	for _, line := range lines {
+		if bytes.HasPrefix(line, []byte("panic:")) || bytes.HasPrefix(line, []byte("fatal error:")) {
+			return string(line)
+		}
+	}
+	return string(lines[len(lines)-1])
+}
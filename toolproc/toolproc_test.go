@@ -0,0 +1,176 @@
+package toolproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// testTool is a chat.Tool whose calls run fn.
+type testTool struct {
+	name string
+	fn   func(ctx context.Context, input string) string
+}
+
+func (t testTool) Name() string          { return t.name }
+func (t testTool) Description() string   { return t.name }
+func (t testTool) MCPJsonSchema() string { return `{"name":"` + t.name + `"}` }
+func (t testTool) Call(ctx context.Context, input string) string {
+	return t.fn(ctx, input)
+}
+
+// imageTestTool returns an image with its result.
+type imageTestTool struct {
+	testTool
+}
+
+func (t imageTestTool) CallWithImages(ctx context.Context, input string) (string, []chat.ImageContent) {
+	return `{"ok": true}`, []chat.ImageContent{{MediaType: "image/png", Data: []byte(input)}}
+}
+
+type ctxKey struct{}
+
+// served are the tools the test binary serves when a Runner starts it.
+var served = []chat.Tool{
+	testTool{"echo", func(ctx context.Context, input string) string {
+		return `{"echo": ` + input + `, "ctx": "` + ctx.Value(ctxKey{}).(string) + `"}`
+	}},
+	testTool{"env", func(ctx context.Context, input string) string {
+		return strings.Join(os.Environ(), "\n")
+	}},
+	testTool{"exit", func(ctx context.Context, input string) string {
+		os.Exit(3)
+		return ""
+	}},
+	testTool{"panic", func(ctx context.Context, input string) string {
+		panic("tool is broken")
+	}},
+	testTool{"hang", func(ctx context.Context, input string) string {
+		select {}
+	}},
+	testTool{"alloc", func(ctx context.Context, input string) string {
+		data := make([]byte, 2<<30)
+		for i := range data {
+			data[i] = 1
+		}
+		return "allocated"
+	}},
+	testTool{"spew", func(ctx context.Context, input string) string {
+		return strings.Repeat("x", 1<<20)
+	}},
+	imageTestTool{testTool{name: "image"}},
+}
+
+func TestMain(m *testing.M) {
+	Serve(context.WithValue(context.Background(), ctxKey{}, "served"), served...)
+	os.Exit(m.Run())
+}
+
+func TestRunner(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "secret")
+	runner := &Runner{Env: []string{"FOO=bar"}, Timeout: 5 * time.Second}
+	ctx := context.Background()
+
+	output, images, err := runner.Call(ctx, "echo", `{"a": 1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"echo": {"a": 1}, "ctx": "served"}`, output)
+	assert.Empty(t, images)
+
+	// The subprocess gets only the environment it is given
+	output, _, err = runner.Call(ctx, "env", "{}")
+	require.NoError(t, err)
+	assert.Contains(t, output, "FOO=bar")
+	assert.NotContains(t, output, "secret")
+
+	_, images, err = runner.Call(ctx, "image", "png")
+	require.NoError(t, err)
+	assert.Equal(t, []chat.ImageContent{{MediaType: "image/png", Data: []byte("png")}}, images)
+
+	_, _, err = runner.Call(ctx, "missing", "{}")
+	assert.ErrorContains(t, err, `tool "missing" isn't served`)
+}
+
+func TestRunnerFailures(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("exit", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := (&Runner{}).Call(ctx, "exit", "{}")
+		assert.ErrorContains(t, err, "exit status 3")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := (&Runner{}).Call(ctx, "panic", "{}")
+		assert.ErrorContains(t, err, "panic: tool is broken")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+		start := time.Now()
+		_, _, err := (&Runner{Timeout: 200 * time.Millisecond}).Call(ctx, "hang", "{}")
+		assert.ErrorContains(t, err, "timed out after 200ms")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		_, _, err := (&Runner{}).Call(ctx, "hang", "{}")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("memory limit", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := (&Runner{Limits: Limits{Memory: 1 << 30}}).Call(ctx, "alloc", "{}")
+		assert.ErrorContains(t, err, "out of memory")
+	})
+
+	t.Run("output limit", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := (&Runner{MaxOutput: 1 << 10}).Call(ctx, "spew", "{}")
+		assert.ErrorContains(t, err, "output exceeded 1024 bytes")
+	})
+}
+
+func TestIsolate(t *testing.T) {
+	t.Parallel()
+	runner := &Runner{}
+	ctx := context.Background()
+
+	// The wrapped tool's own implementation isn't called
+	tool := runner.Isolate(testTool{"echo", func(ctx context.Context, input string) string {
+		return "in process"
+	}})
+	assert.Equal(t, "echo", tool.Name())
+	assert.JSONEq(t, `{"echo": {}, "ctx": "served"}`, tool.Call(ctx, "{}"))
+
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(runner.Isolate(served[2]).Call(ctx, "{}")), &result))
+	assert.Equal(t, ErrorCode, result["errorCode"])
+	assert.Equal(t, false, result["retryable"])
+	assert.Contains(t, result["error"], "exit failed in its subprocess")
+
+	output, images := runner.Isolate(served[len(served)-1]).(chat.ImageTool).CallWithImages(ctx, "png")
+	assert.JSONEq(t, `{"ok": true}`, output)
+	assert.Len(t, images, 1)
+}
+
+func TestServeRejectsBadInput(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	assert.ErrorContains(t, serve(context.Background(), "{}", strings.NewReader("not json"), &out, served), "failed to read request")
+	assert.ErrorContains(t, serve(context.Background(), "nope", strings.NewReader("{}"), &out, served), "failed to parse limits")
+	assert.Empty(t, out.String())
+}
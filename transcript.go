@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bpowers/go-agent/chat"
+	"github.com/bpowers/go-agent/persistence"
+)
+
+// Transcript is a session's whole conversation, in order, with the record
+// IDs that Session methods like PinRecord, ResumeFrom and CheckpointAt
+// take, so UIs can offer edit, delete and pin actions on each message.
+type Transcript struct {
+	SessionID string `json:"sessionId"`
+	// SystemPrompt is the session's current system prompt. System records
+	// aren't included in Entries.
+	SystemPrompt string            `json:"systemPrompt"`
+	Entries      []TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is a record in a Transcript, with its ID, timestamp,
+// token usage and Live flag.
+type TranscriptEntry struct {
+	persistence.Record
+	// Compacted is set on records that are no longer in the context window,
+	// having been compacted or rolled back with ResumeFrom. Failed
+	// exchanges are never live, but aren't marked compacted.
+	Compacted bool `json:"compacted,omitzero"`
+	// Summary is set on records added by compaction to summarize the
+	// records it dropped.
+	Summary bool `json:"summary,omitzero"`
+}
+
+// Transcript implements Session.
+func (s *session) Transcript() (Transcript, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.store.GetAllRecords(s.sessionID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("failed to load records: %w", err)
+	}
+
+	t := Transcript{SessionID: s.sessionID, SystemPrompt: s.systemPrompt}
+	for _, r := range records {
+		if r.Role == "system" {
+			continue
+		}
+		t.Entries = append(t.Entries, TranscriptEntry{
+			Record:    r,
+			Compacted: !r.Live && r.Status != persistence.RecordStatusFailed,
+			Summary:   r.Role == chat.AssistantRole && strings.HasPrefix(r.GetText(), summaryPrefix),
+		})
+	}
+	return t, nil
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+func TestSessionTranscript(t *testing.T) {
+	client := &mockClient{}
+	session, err := NewSession(client, "System")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := range 3 {
+		_, err := session.Message(ctx, chat.UserMessage(fmt.Sprintf("Message %d", i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, session.CompactNow())
+
+	transcript, err := session.Transcript()
+	require.NoError(t, err)
+	assert.Equal(t, session.SessionID(), transcript.SessionID)
+	assert.Equal(t, "System", transcript.SystemPrompt)
+
+	// Compacted records are kept, followed by the summary standing in for them
+	var roles []chat.Role
+	var compacted, summaries []bool
+	for _, e := range transcript.Entries {
+		roles = append(roles, e.Role)
+		compacted = append(compacted, e.Compacted)
+		summaries = append(summaries, e.Summary)
+		assert.NotZero(t, e.ID)
+		assert.NotZero(t, e.Timestamp)
+		assert.Equal(t, !e.Compacted, e.Live)
+	}
+	assert.Equal(t, []chat.Role{
+		chat.UserRole, chat.AssistantRole, chat.UserRole, chat.AssistantRole,
+		chat.UserRole, chat.AssistantRole, chat.AssistantRole,
+	}, roles)
+	assert.Equal(t, []bool{true, true, true, true, false, false, false}, compacted)
+	assert.Equal(t, []bool{false, false, false, false, false, false, true}, summaries)
+	assert.Equal(t, "Message 0", transcript.Entries[0].GetText())
+	assert.Positive(t, transcript.Entries[5].OutputTokens)
+
+	// Entry IDs can be passed to Session methods
+	require.NoError(t, session.PinRecord(transcript.Entries[4].ID, true))
+	transcript, err = session.Transcript()
+	require.NoError(t, err)
+	assert.True(t, transcript.Entries[4].Pinned)
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Approvals asks a client to approve tool calls. It is an
+// agent.PolicyEvaluator, so it can be given to a session with
+// agent.WithToolApprover, to approve calls to destructive tools, or
+// agent.WithPolicyEvaluator; each call it evaluates is sent to the client
+// as an approval request, with the tool's annotations, and waits for
+// Resolve to be called with the client's response.
+type Approvals struct {
+	sender Sender
+	// Needed reports whether a call needs approval, and why. If nil,
+	// every call does.
+	Needed func(call chat.ToolCall) (needed bool, reason string)
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan Approval
+}
+
+// NewApprovals returns an evaluator that sends approval requests with s.
+func NewApprovals(s Sender) *Approvals {
+	return &Approvals{sender: s, pending: make(map[string]chan Approval)}
+}
+
+// EvaluateToolCall implements agent.PolicyEvaluator. It denies the call
+// if the request can't be sent, or if ctx is done before the client
+// answers.
+func (a *Approvals) EvaluateToolCall(ctx context.Context, call chat.ToolCall) (agent.PolicyDecision, error) {
+	reason := ""
+	if a.Needed != nil {
+		var needed bool
+		if needed, reason = a.Needed(call); !needed {
+			return agent.PolicyDecision{Action: agent.ToolDecisionAllow}, nil
+		}
+	}
+
+	a.mu.Lock()
+	a.nextID++
+	id := strconv.Itoa(a.nextID)
+	answer := make(chan Approval, 1)
+	a.pending[id] = answer
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, id)
+		a.mu.Unlock()
+	}()
+
+	request := &Approval{ID: id, ToolCall: &call, Reason: reason}
+	if annotations, ok := agent.ToolAnnotationsFromContext(ctx); ok {
+		request.Annotations = &annotations
+	}
+	if err := a.sender.Send(ctx, Frame{Type: FrameApprovalRequest, Approval: request}); err != nil {
+		return agent.PolicyDecision{}, fmt.Errorf("failed to request approval: %w", err)
+	}
+	select {
+	case approval := <-answer:
+		if !approval.Approved {
+			reason := approval.Reason
+			if reason == "" {
+				reason = "the user didn't approve the call"
+			}
+			return agent.PolicyDecision{Action: agent.ToolDecisionDeny, Reason: reason}, nil
+		}
+		return agent.PolicyDecision{Action: agent.ToolDecisionAllow}, nil
+	case <-ctx.Done():
+		return agent.PolicyDecision{}, ctx.Err()
+	}
+}
+
+// Resolve passes on the client's answer to an approval request. It fails
+// if no request with the answer's ID is waiting, such as when it was
+// already answered.
+func (a *Approvals) Resolve(approval Approval) error {
+	a.mu.Lock()
+	answer, ok := a.pending[approval.ID]
+	delete(a.pending, approval.ID)
+	a.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no pending approval request %q", approval.ID)
+	}
+	answer <- approval
+	return nil
+}
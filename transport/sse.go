@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SSE sends frames as server-sent events on an HTTP response.
+type SSE struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewSSE starts an event stream on w, writing its headers. It fails if w
+// can't be flushed, as the events would be buffered until the handler
+// returned.
+func NewSSE(w http.ResponseWriter) (*SSE, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer doesn't support flushing")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &SSE{w: w, flusher: flusher}, nil
+}
+
+// Send implements Sender.
+func (s *SSE) Send(ctx context.Context, frame Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("failed to marshal frame: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", frame.Type, data); err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// ReadSSE reads the frames of an event stream written by SSE, such as the
+// body of a response, calling fn with each until r ends or fn returns an
+// error. Comments and events without data are skipped.
+func ReadSSE(r io.Reader, fn func(Frame) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if data.Len() == 0 {
+				continue
+			}
+			var frame Frame
+			if err := json.Unmarshal(data.Bytes(), &frame); err != nil {
+				return fmt.Errorf("failed to parse event: %w", err)
+			}
+			data.Reset()
+			if err := fn(frame); err != nil {
+				return err
+			}
+			continue
+		}
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(value, []byte(" ")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil
+}
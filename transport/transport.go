@@ -0,0 +1,121 @@
+// Package transport carries a session's stream events to browser frontends
+// and other clients over WebSocket or server-sent events (SSE), in one JSON
+// wire format for events, tool approvals, and errors.
+//
+// # Wire format
+//
+// Every message is a JSON Frame, whose type says which of its fields is
+// set:
+//
+//	{"type": "event", "event": {"type": "content", "content": "Hel"}}
+//	{"type": "approval_request", "approval": {"id": "3", "toolCall": {"name": "WriteFile", "arguments": {...}}, "reason": "..."}}
+//	{"type": "approval_response", "approval": {"id": "3", "approved": true}}
+//	{"type": "error", "error": {"message": "...", "code": "busy", "retryable": true}}
+//
+// Events are chat.StreamEvents, in their own JSON encoding. Approval
+// requests ask the user whether a tool call may run, and clients answer
+// each with an approval response carrying the same ID; every other frame
+// is sent by the server. An error frame ends the turn it belongs to.
+//
+// Over WebSocket, each frame is one text message. Over SSE, each frame is
+// one event whose name is the frame's type and whose data is the frame:
+//
+//	event: event
+//	data: {"type": "event", "event": {"type": "content", "content": "Hel"}}
+//
+// SSE only carries frames from the server, so SSE clients send approval
+// responses another way, such as an HTTP POST that the server passes to
+// Approvals.Resolve.
+package transport
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// FrameType identifies what a Frame carries.
+type FrameType string
+
+const (
+	// FrameEvent carries a stream event, in Event.
+	FrameEvent FrameType = "event"
+	// FrameApprovalRequest asks the client to approve a tool call, in
+	// Approval.
+	FrameApprovalRequest FrameType = "approval_request"
+	// FrameApprovalResponse is the client's answer to an approval
+	// request, in Approval.
+	FrameApprovalResponse FrameType = "approval_response"
+	// FrameError reports an error that ended a turn, in Error.
+	FrameError FrameType = "error"
+)
+
+// Frame is one message of the wire format.
+type Frame struct {
+	Type     FrameType         `json:"type"`
+	Event    *chat.StreamEvent `json:"event,omitzero"`
+	Approval *Approval         `json:"approval,omitzero"`
+	Error    *Error            `json:"error,omitzero"`
+}
+
+// Approval is a request to approve a tool call, or the answer to one.
+type Approval struct {
+	// ID matches a response to its request.
+	ID string `json:"id"`
+	// ToolCall is the call to approve, in requests.
+	ToolCall *chat.ToolCall `json:"toolCall,omitzero"`
+	// Annotations are the annotations of the tool called, in requests,
+	// for showing whether the call is destructive.
+	Annotations *chat.ToolAnnotations `json:"annotations,omitzero"`
+	// Approved is whether the call may run, in responses.
+	Approved bool `json:"approved,omitzero"`
+	// Reason explains why approval is needed, in requests, or why it was
+	// refused, in responses.
+	Reason string `json:"reason,omitzero"`
+}
+
+// Error is an error reported to the client.
+type Error struct {
+	Message string `json:"message"`
+	// Code classifies the error: "busy" if the session was handling
+	// another message, "canceled" if the turn was canceled or timed out,
+	// and "internal" otherwise.
+	Code string `json:"code"`
+	// Retryable is whether sending the message again may succeed.
+	Retryable bool `json:"retryable,omitzero"`
+}
+
+// Sender sends frames to a client. Implementations must be safe for
+// concurrent use.
+type Sender interface {
+	Send(ctx context.Context, frame Frame) error
+}
+
+// EventFrame returns the frame carrying event.
+func EventFrame(event chat.StreamEvent) Frame {
+	return Frame{Type: FrameEvent, Event: &event}
+}
+
+// ErrorFrame returns the frame reporting err.
+func ErrorFrame(err error) Frame {
+	e := &Error{Message: err.Error(), Code: "internal"}
+	switch {
+	case errors.Is(err, chat.ErrBusy):
+		e.Code = "busy"
+		e.Retryable = true
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		e.Code = "canceled"
+		e.Retryable = true
+	}
+	return Frame{Type: FrameError, Error: e}
+}
+
+// StreamCallback returns a callback, to pass to Message with
+// chat.WithStreamingCb, that sends each event to s. A failed send stops
+// the stream.
+func StreamCallback(ctx context.Context, s Sender) chat.StreamCallback {
+	return func(event chat.StreamEvent) error {
+		return s.Send(ctx, EventFrame(event))
+	}
+}
@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agent "github.com/bpowers/go-agent"
+	"github.com/bpowers/go-agent/chat"
+)
+
+var testEvents = []chat.StreamEvent{
+	{Type: chat.StreamEventTypeRoundStart, Round: &chat.RoundStatus{Index: 0, Reason: chat.RoundReasonUserMessage}},
+	{Type: chat.StreamEventTypeContent, Content: "Hel", TurnID: "turn-1"},
+	{Type: chat.StreamEventTypeToolCall, ToolCalls: []chat.ToolCall{{ID: "call_1", Name: "ReadFile", Arguments: json.RawMessage(`{"fileName":"a.go"}`)}}},
+	{Type: chat.StreamEventTypeDone, FinishReason: "stop"},
+}
+
+func TestErrorFrame(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err       error
+		code      string
+		retryable bool
+	}{
+		{err: fmt.Errorf("waited: %w", chat.ErrBusy), code: "busy", retryable: true},
+		{err: context.DeadlineExceeded, code: "canceled", retryable: true},
+		{err: errors.New("bad request"), code: "internal"},
+	}
+	for _, tt := range tests {
+		frame := ErrorFrame(tt.err)
+		assert.Equal(t, FrameError, frame.Type)
+		assert.Equal(t, &Error{Message: tt.err.Error(), Code: tt.code, Retryable: tt.retryable}, frame.Error)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	sse, err := NewSSE(rec)
+	require.NoError(t, err)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	ctx := context.Background()
+	callback := StreamCallback(ctx, sse)
+	for _, event := range testEvents {
+		require.NoError(t, callback(event))
+	}
+	require.NoError(t, sse.Send(ctx, ErrorFrame(chat.ErrBusy)))
+
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "event: event\ndata: {\"type\":\"event\",\"event\":{\"type\":\"round_start\""))
+
+	var frames []Frame
+	require.NoError(t, ReadSSE(strings.NewReader(": comment\n\n"+rec.Body.String()), func(frame Frame) error {
+		frames = append(frames, frame)
+		return nil
+	}))
+	require.Len(t, frames, len(testEvents)+1)
+	for i, event := range testEvents {
+		assert.Equal(t, EventFrame(event), frames[i])
+	}
+	assert.Equal(t, "busy", frames[len(testEvents)].Error.Code)
+}
+
+func TestWebSocketApprovals(t *testing.T) {
+	t.Parallel()
+
+	decisions := make(chan agent.PolicyDecision, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := AcceptWebSocket(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ws.Close()
+		ctx := r.Context()
+
+		approvals := NewApprovals(ws)
+		approvals.Needed = func(call chat.ToolCall) (bool, string) {
+			return call.Name == "WriteFile", "writes files"
+		}
+		go func() {
+			for {
+				frame, err := ws.Receive(ctx)
+				if err != nil {
+					return
+				}
+				if frame.Type == FrameApprovalResponse {
+					assert.NoError(t, approvals.Resolve(*frame.Approval))
+				}
+			}
+		}()
+
+		for _, event := range testEvents[:2] {
+			assert.NoError(t, StreamCallback(ctx, ws)(event))
+		}
+		for _, name := range []string{"ReadFile", "WriteFile", "WriteFile"} {
+			d, err := approvals.EvaluateToolCall(ctx, chat.ToolCall{Name: name, Arguments: json.RawMessage(`{}`)})
+			assert.NoError(t, err)
+			decisions <- d
+		}
+		close(decisions)
+		assert.NoError(t, ws.Send(ctx, ErrorFrame(errors.New("done"))))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	ws, err := DialWebSocket(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	var events []chat.StreamEvent
+	var requests []Approval
+	for {
+		frame, err := ws.Receive(ctx)
+		require.NoError(t, err)
+		if frame.Type == FrameError {
+			assert.Equal(t, "done", frame.Error.Message)
+			break
+		}
+		switch frame.Type {
+		case FrameEvent:
+			events = append(events, *frame.Event)
+		case FrameApprovalRequest:
+			requests = append(requests, *frame.Approval)
+			// Approve the first write, and refuse the second
+			answer := Approval{ID: frame.Approval.ID, Approved: len(requests) == 1}
+			if !answer.Approved {
+				answer.Reason = "not today"
+			}
+			require.NoError(t, ws.Send(ctx, Frame{Type: FrameApprovalResponse, Approval: &answer}))
+		}
+	}
+
+	assert.Equal(t, testEvents[:2], events)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "WriteFile", requests[0].ToolCall.Name)
+	assert.Equal(t, "writes files", requests[0].Reason)
+	assert.NotEqual(t, requests[0].ID, requests[1].ID)
+
+	var got []agent.PolicyDecision
+	for d := range decisions {
+		got = append(got, d)
+	}
+	assert.Equal(t, []agent.PolicyDecision{
+		{Action: agent.ToolDecisionAllow},
+		{Action: agent.ToolDecisionAllow},
+		{Action: agent.ToolDecisionDeny, Reason: "not today"},
+	}, got)
+}
+
+// signalingSender signals each frame sent to it.
+type signalingSender struct {
+	sent chan struct{}
+}
+
+func (s *signalingSender) Send(ctx context.Context, frame Frame) error {
+	s.sent <- struct{}{}
+	return nil
+}
+
+func TestApprovals(t *testing.T) {
+	t.Parallel()
+
+	sender := &signalingSender{sent: make(chan struct{}, 1)}
+	approvals := NewApprovals(sender)
+	assert.Error(t, approvals.Resolve(Approval{ID: "1", Approved: true}))
+
+	// A call still waiting when its context ends is denied
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-sender.sent
+		cancel()
+	}()
+	_, err := approvals.EvaluateToolCall(ctx, chat.ToolCall{Name: "WriteFile"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Error(t, approvals.Resolve(Approval{ID: "1", Approved: true}), "abandoned requests can't be resolved")
+
+	// Refusals without a reason get one
+	go func() {
+		<-sender.sent
+		assert.NoError(t, approvals.Resolve(Approval{ID: "2"}))
+	}()
+	d, err := approvals.EvaluateToolCall(context.Background(), chat.ToolCall{Name: "WriteFile"})
+	require.NoError(t, err)
+	assert.Equal(t, agent.ToolDecisionDeny, d.Action)
+	assert.NotEmpty(t, d.Reason)
+}
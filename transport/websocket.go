@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// WebSocket sends and receives frames over a WebSocket connection. It is
+// safe for one goroutine to receive while others send.
+type WebSocket struct {
+	conn *websocket.Conn
+}
+
+// AcceptWebSocket accepts a WebSocket connection from a client, as
+// websocket.Accept does with opts, which may be nil.
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request, opts *websocket.AcceptOptions) (*WebSocket, error) {
+	conn, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept WebSocket: %w", err)
+	}
+	return &WebSocket{conn: conn}, nil
+}
+
+// DialWebSocket connects to a server that accepted the connection with
+// AcceptWebSocket, as websocket.Dial does with opts, which may be nil.
+func DialWebSocket(ctx context.Context, url string, opts *websocket.DialOptions) (*WebSocket, error) {
+	conn, _, err := websocket.Dial(ctx, url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial WebSocket: %w", err)
+	}
+	return &WebSocket{conn: conn}, nil
+}
+
+// Send implements Sender.
+func (ws *WebSocket) Send(ctx context.Context, frame Frame) error {
+	if err := wsjson.Write(ctx, ws.conn, frame); err != nil {
+		return fmt.Errorf("failed to send frame: %w", err)
+	}
+	return nil
+}
+
+// Receive returns the next frame from the other end.
+func (ws *WebSocket) Receive(ctx context.Context) (Frame, error) {
+	var frame Frame
+	if err := wsjson.Read(ctx, ws.conn, &frame); err != nil {
+		return Frame{}, fmt.Errorf("failed to receive frame: %w", err)
+	}
+	return frame, nil
+}
+
+// Close closes the connection normally.
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close(websocket.StatusNormalClosure, "")
+}
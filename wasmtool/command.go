@@ -0,0 +1,51 @@
+package wasmtool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// CommandRuntime runs modules with a WASI runtime's command line, which is
+// given the path of the module followed by the arguments after the program
+// name, such as "wazero run module.wasm call".
+type CommandRuntime struct {
+	// Path is the runtime's executable, such as "wazero" or "wasmtime".
+	Path string
+	// Args come before the module's path, such as "run".
+	Args []string
+}
+
+// Run implements Runtime. The module is written to a temporary file for
+// the runtime to load, and run with an empty environment.
+func (r CommandRuntime) Run(ctx context.Context, module []byte, args []string, stdin []byte) ([]byte, error) {
+	f, err := os.CreateTemp("", "tool-*.wasm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create module file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(module); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write module file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write module file: %w", err)
+	}
+
+	cmdArgs := append(append(append([]string(nil), r.Args...), f.Name()), args[1:]...)
+	cmd := exec.CommandContext(ctx, r.Path, cmdArgs...)
+	cmd.Env = []string{}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
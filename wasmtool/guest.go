@@ -0,0 +1,41 @@
+package wasmtool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// Main serves tool as a tool module and exits. Call it from the main
+// function of a program built with GOOS=wasip1 GOARCH=wasm.
+func Main(tool chat.Tool) {
+	if err := serve(context.Background(), os.Args, os.Stdin, os.Stdout, tool); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// serve handles one run of a tool module.
+func serve(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, tool chat.Tool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s describe|call", args[0])
+	}
+	switch args[1] {
+	case "describe":
+		_, err := io.WriteString(stdout, tool.MCPJsonSchema())
+		return err
+	case "call":
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		_, err = io.WriteString(stdout, tool.Call(ctx, string(input)))
+		return err
+	default:
+		return fmt.Errorf("unknown command %q (want describe or call)", args[1])
+	}
+}
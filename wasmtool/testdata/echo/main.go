@@ -0,0 +1,25 @@
+// Command echo is a tool module for the wazero runtime tests, built with
+// GOOS=wasip1 GOARCH=wasm.
+package main
+
+import (
+	"context"
+
+	"github.com/bpowers/go-agent/wasmtool"
+)
+
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echoes its input" }
+func (echoTool) MCPJsonSchema() string {
+	return `{"name":"echo","description":"Echoes its input","inputSchema":{"type":"object"}}`
+}
+
+func (echoTool) Call(ctx context.Context, input string) string {
+	return `{"echo": ` + input + `}`
+}
+
+func main() {
+	wasmtool.Main(echoTool{})
+}
@@ -0,0 +1,100 @@
+// Package wasmtool loads tools implemented as WebAssembly modules, a
+// portable plugin format that lets applications run third-party tools
+// without linking their code or trusting them with the host process.
+//
+// A tool module is a WASI command. Run with the argument "describe", it
+// writes the tool's MCP JSON schema (see chat.Tool.MCPJsonSchema) to
+// stdout. Run with "call", it reads the call's JSON input from stdin and
+// writes the result to stdout. Go tools can be built as modules with
+// GOOS=wasip1 GOARCH=wasm and a main function that calls Main.
+//
+// Modules run on a Runtime. WazeroRuntime runs them in process with
+// wazero, sandboxed from the host's files, environment, and network:
+//
+//	rt, err := wasmtool.NewWazeroRuntime(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer rt.Close(ctx)
+//	tool, err := wasmtool.Load(ctx, rt, module)
+//	if err != nil {
+//		return err
+//	}
+//	err = session.RegisterTool(tool)
+//
+// CommandRuntime runs them with a WASI runtime's command line instead,
+// such as "wasmtime".
+package wasmtool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ErrorCode is the error code reported to the model when a tool module
+// fails.
+const ErrorCode = "wasm_tool_failed"
+
+// Runtime runs WASI command modules.
+type Runtime interface {
+	// Run runs module with args, starting with the program name, passing
+	// it stdin and returning what it writes to stdout. Modules that exit
+	// with a nonzero status return an error.
+	Run(ctx context.Context, module []byte, args []string, stdin []byte) ([]byte, error)
+}
+
+// Load returns the tool implemented by module, which runs on rt.
+func Load(ctx context.Context, rt Runtime, module []byte) (chat.Tool, error) {
+	schema, err := rt.Run(ctx, module, []string{"tool", "describe"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe tool: %w", err)
+	}
+	var desc struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(schema, &desc); err != nil {
+		return nil, fmt.Errorf("failed to parse tool schema: %w", err)
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("tool schema has no name")
+	}
+	return &tool{
+		rt:          rt,
+		module:      module,
+		name:        desc.Name,
+		description: desc.Description,
+		schema:      string(schema),
+	}, nil
+}
+
+// tool is a chat.Tool implemented by a WASM module.
+type tool struct {
+	rt          Runtime
+	module      []byte
+	name        string
+	description string
+	schema      string
+}
+
+func (t *tool) Name() string          { return t.name }
+func (t *tool) Description() string   { return t.description }
+func (t *tool) MCPJsonSchema() string { return t.schema }
+
+// Call runs the module with the call's input. Failures are reported to the
+// model as non-retryable tool errors.
+func (t *tool) Call(ctx context.Context, input string) string {
+	output, err := t.rt.Run(ctx, t.module, []string{"tool", "call"}, []byte(input))
+	if err != nil {
+		result, _ := json.Marshal(map[string]any{
+			"error":     fmt.Sprintf("%s failed: %s", t.name, err),
+			"errorCode": ErrorCode,
+			"retryable": false,
+		})
+		return string(result)
+	}
+	return string(output)
+}
@@ -0,0 +1,121 @@
+package wasmtool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// echoTool is the tool the test modules implement.
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echoes its input" }
+func (echoTool) MCPJsonSchema() string {
+	return `{"name":"echo","description":"Echoes its input","inputSchema":{"type":"object"}}`
+}
+
+func (echoTool) Call(ctx context.Context, input string) string {
+	return `{"echo": ` + input + `}`
+}
+
+// guestRuntime runs the guest side of the protocol in process, standing
+// in for a WASM runtime running a module built with Main.
+type guestRuntime struct {
+	tool chat.Tool
+}
+
+func (r guestRuntime) Run(ctx context.Context, module []byte, args []string, stdin []byte) ([]byte, error) {
+	var stdout bytes.Buffer
+	if err := serve(ctx, args, bytes.NewReader(stdin), &stdout, r.tool); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// failingRuntime fails every run.
+type failingRuntime struct{}
+
+func (failingRuntime) Run(context.Context, []byte, []string, []byte) ([]byte, error) {
+	return nil, errors.New("exit status 1: unreachable")
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tool, err := Load(ctx, guestRuntime{echoTool{}}, []byte("\x00asm"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo", tool.Name())
+	assert.Equal(t, "Echoes its input", tool.Description())
+	assert.Equal(t, echoTool{}.MCPJsonSchema(), tool.MCPJsonSchema())
+	assert.JSONEq(t, `{"echo": {"a": 1}}`, tool.Call(ctx, `{"a": 1}`))
+
+	_, err = Load(ctx, failingRuntime{}, nil)
+	assert.ErrorContains(t, err, "failed to describe tool")
+}
+
+func TestLoadBadSchema(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, err := Load(ctx, guestRuntime{badSchemaTool{`not json`}}, nil)
+	assert.ErrorContains(t, err, "failed to parse tool schema")
+	_, err = Load(ctx, guestRuntime{badSchemaTool{`{"description": "nameless"}`}}, nil)
+	assert.ErrorContains(t, err, "no name")
+}
+
+type badSchemaTool struct {
+	schema string
+}
+
+func (t badSchemaTool) Name() string                                  { return "" }
+func (t badSchemaTool) Description() string                           { return "" }
+func (t badSchemaTool) MCPJsonSchema() string                         { return t.schema }
+func (t badSchemaTool) Call(ctx context.Context, input string) string { return "" }
+
+func TestCallFailure(t *testing.T) {
+	t.Parallel()
+	tool := &tool{rt: failingRuntime{}, name: "echo"}
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(tool.Call(context.Background(), "{}")), &result))
+	assert.Equal(t, ErrorCode, result["errorCode"])
+	assert.Equal(t, false, result["retryable"])
+	assert.Contains(t, result["error"], "echo failed: exit status 1: unreachable")
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var out bytes.Buffer
+	assert.ErrorContains(t, serve(ctx, []string{"tool"}, strings.NewReader(""), &out, echoTool{}), "usage")
+	assert.ErrorContains(t, serve(ctx, []string{"tool", "run"}, strings.NewReader(""), &out, echoTool{}), "unknown command")
+	assert.Empty(t, out.String())
+}
+
+func TestCommandRuntime(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("needs a shell to stand in for a WASI runtime")
+	}
+
+	// The stand-in runtime checks it was given the module, then echoes the
+	// command and its input
+	rt := CommandRuntime{Path: sh, Args: []string{"-c", `test "$(cat "$1")" = module || exit 3; echo "$2"; cat; echo "$FOO"`, "sh"}}
+	t.Setenv("FOO", "leaked")
+	output, err := rt.Run(context.Background(), []byte("module"), []string{"tool", "call"}, []byte("input\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "call\ninput\n\n", string(output))
+
+	_, err = rt.Run(context.Background(), []byte("other"), []string{"tool", "call"}, nil)
+	assert.ErrorContains(t, err, "exit status 3")
+}
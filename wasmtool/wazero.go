@@ -0,0 +1,97 @@
+package wasmtool
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WazeroRuntime runs modules in process with wazero. Modules get their
+// arguments, stdin, stdout, stderr, clocks, and random numbers, and nothing
+// else: no files, environment variables, or network. Each run is a fresh
+// instance, so runs don't share state and can be concurrent. Modules are
+// compiled once, on their first run, and kept until the runtime is closed.
+type WazeroRuntime struct {
+	runtime wazero.Runtime
+
+	mu       sync.Mutex
+	compiled map[[sha256.Size]byte]wazero.CompiledModule
+}
+
+// NewWazeroRuntime returns a runtime that compiles modules to native code
+// where wazero supports it, and interprets them elsewhere. Runs stop when
+// their context is done. It must be closed to free the compiled modules.
+func NewWazeroRuntime(ctx context.Context) (*WazeroRuntime, error) {
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	return &WazeroRuntime{
+		runtime:  r,
+		compiled: make(map[[sha256.Size]byte]wazero.CompiledModule),
+	}, nil
+}
+
+// Run implements Runtime.
+func (r *WazeroRuntime) Run(ctx context.Context, module []byte, args []string, stdin []byte) ([]byte, error) {
+	compiled, err := r.compile(ctx, module)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := wazero.NewModuleConfig().
+		// Unnamed, so instances of the same module can run at once
+		WithName("").
+		WithArgs(args...).
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(&stdout).
+		WithStderr(&stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	mod, err := r.runtime.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	// Modules that return from _start rather than exit are still open
+	if mod != nil {
+		_ = mod.Close(ctx)
+	}
+	return stdout.Bytes(), nil
+}
+
+// compile returns module compiled, compiling it on its first run.
+func (r *WazeroRuntime) compile(ctx context.Context, module []byte) (wazero.CompiledModule, error) {
+	key := sha256.Sum256(module)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if compiled, ok := r.compiled[key]; ok {
+		return compiled, nil
+	}
+	compiled, err := r.runtime.CompileModule(ctx, module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile module: %w", err)
+	}
+	r.compiled[key] = compiled
+	return compiled, nil
+}
+
+// Close frees the runtime's compiled modules, stopping any runs in
+// progress.
+func (r *WazeroRuntime) Close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
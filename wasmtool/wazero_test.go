@@ -0,0 +1,74 @@
+package wasmtool
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildEchoModule builds testdata/echo, a module serving echoTool with
+// Main.
+func buildEchoModule(t *testing.T) []byte {
+	t.Helper()
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("needs the go command to build a tool module")
+	}
+
+	path := filepath.Join(t.TempDir(), "echo.wasm")
+	cmd := exec.Command(gobin, "build", "-o", path, "./testdata/echo")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "failed to build module: %s", output)
+	module, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return module
+}
+
+func TestWazeroRuntime(t *testing.T) {
+	module := buildEchoModule(t)
+	ctx := context.Background()
+	rt, err := NewWazeroRuntime(ctx)
+	require.NoError(t, err)
+	defer rt.Close(ctx)
+
+	tool, err := Load(ctx, rt, module)
+	require.NoError(t, err)
+	assert.Equal(t, "echo", tool.Name())
+	assert.Equal(t, "Echoes its input", tool.Description())
+	assert.Equal(t, echoTool{}.MCPJsonSchema(), tool.MCPJsonSchema())
+
+	// Each call is a fresh instance of the compiled module, so calls can
+	// run at once
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.JSONEq(t, `{"echo": {"a": 1}}`, tool.Call(ctx, `{"a": 1}`))
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, rt.compiled, 1)
+
+	// Modules that exit with a nonzero status fail with what they wrote to
+	// stderr
+	_, err = rt.Run(ctx, module, []string{"tool", "run"}, nil)
+	assert.ErrorContains(t, err, "exit_code(1)")
+	assert.ErrorContains(t, err, `unknown command "run"`)
+
+	_, err = rt.Run(ctx, []byte("not wasm"), []string{"tool", "describe"}, nil)
+	assert.ErrorContains(t, err, "failed to compile module")
+
+	// Runs stop when their context is done
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = rt.Run(canceled, module, []string{"tool", "describe"}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
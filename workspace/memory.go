@@ -0,0 +1,13 @@
+package workspace
+
+import (
+	"github.com/psanford/memfs"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// NewMemory returns an empty workspace held in memory, for tests and for
+// agents whose files needn't outlive them.
+func NewMemory() chat.Workspace {
+	return FromFS(memfs.New())
+}
@@ -0,0 +1,78 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// NewRoot returns a workspace in a local directory, which can't be escaped
+// through ".." or symlinks. Writes aren't atomic, as os.Root can't rename
+// files before Go 1.25.
+func NewRoot(root *os.Root) chat.Workspace {
+	return rootWorkspace{root: root, fsys: root.FS()}
+}
+
+type rootWorkspace struct {
+	root *os.Root
+	fsys fs.FS
+}
+
+func (w rootWorkspace) Open(ctx context.Context, name string) (fs.File, error) {
+	return w.fsys.Open(name)
+}
+
+func (w rootWorkspace) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(w.fsys, name)
+}
+
+func (w rootWorkspace) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	return fs.Stat(w.fsys, name)
+}
+
+func (w rootWorkspace) WriteFile(ctx context.Context, name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := w.mkdirAll(dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	f, err := w.root.OpenFile(filepath.FromSlash(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mkdirAll creates dir and any missing parents.
+func (w rootWorkspace) mkdirAll(dir string) error {
+	if dir == "." {
+		return nil
+	}
+	if info, err := w.root.Stat(filepath.FromSlash(dir)); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if err := w.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	if err := w.root.Mkdir(filepath.FromSlash(dir), 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
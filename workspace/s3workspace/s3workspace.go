@@ -0,0 +1,209 @@
+// Package s3workspace provides a chat.Workspace backed by an S3 bucket.
+package s3workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// New returns a workspace keeping its files in bucket, with keys under
+// prefix. S3 has no directories, so the workspace's directories are the
+// slash-separated prefixes of its keys. Writes are atomic, as S3 replaces
+// objects whole.
+func New(client *s3.Client, bucket, prefix string) chat.Workspace {
+	return &workspace{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+type workspace struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// key returns the object key for a workspace path.
+func (w *workspace) key(name string) string {
+	if name == "." {
+		return w.prefix
+	}
+	if w.prefix == "" {
+		return name
+	}
+	return w.prefix + "/" + name
+}
+
+// dirPrefix returns the key prefix of the objects in a workspace directory.
+func (w *workspace) dirPrefix(name string) string {
+	if key := w.key(name); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+func (w *workspace) Open(ctx context.Context, name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	out, err := w.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(w.key(name)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &object{
+		ReadCloser: out.Body,
+		info: fileInfo{
+			name:    path.Base(name),
+			size:    aws.ToInt64(out.ContentLength),
+			modTime: aws.ToTime(out.LastModified),
+		},
+	}, nil
+}
+
+func (w *workspace) WriteFile(ctx context.Context, name string, data []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	_, err := w.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(w.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", w.key(name), err)
+	}
+	return nil
+}
+
+func (w *workspace) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := w.dirPrefix(name)
+	var entries []fs.DirEntry
+	pages := s3.NewListObjectsV2Paginator(w.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(w.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, p := range page.CommonPrefixes {
+			dir := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(p.Prefix), prefix), "/")
+			entries = append(entries, fs.FileInfoToDirEntry(fileInfo{name: dir, dir: true}))
+		}
+		for _, obj := range page.Contents {
+			file := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if file == "" {
+				// A marker for the directory itself
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(fileInfo{
+				name:    file,
+				size:    aws.ToInt64(obj.Size),
+				modTime: aws.ToTime(obj.LastModified),
+			}))
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func (w *workspace) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fileInfo{name: ".", dir: true}, nil
+	}
+	out, err := w.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(w.key(name)),
+	})
+	if err == nil {
+		return fileInfo{
+			name:    path.Base(name),
+			size:    aws.ToInt64(out.ContentLength),
+			modTime: aws.ToTime(out.LastModified),
+		}, nil
+	}
+	if !isNotFound(err) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	// Names that prefix other keys are directories
+	list, err := w.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(w.bucket),
+		Prefix:  aws.String(w.dirPrefix(name)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if len(list.Contents) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+func isNotFound(err error) bool {
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotFound
+}
+
+// object is an open S3 object.
+type object struct {
+	io.ReadCloser
+	info fileInfo
+}
+
+func (o *object) Stat() (fs.FileInfo, error) {
+	return o.info, nil
+}
+
+// fileInfo describes an object or a directory.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.dir }
+func (fi fileInfo) Sys() any           { return nil }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
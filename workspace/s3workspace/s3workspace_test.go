@@ -0,0 +1,200 @@
+package s3workspace
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves the parts of the S3 API the workspace uses, for one bucket
+// addressed path-style.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+var modTime = time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+type listResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Name           string
+	Prefix         string
+	KeyCount       int
+	IsTruncated    bool
+	Contents       []listObject
+	CommonPrefixes []listPrefix
+}
+
+type listObject struct {
+	Key          string
+	Size         int64
+	LastModified string
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"), r.URL.Query().Get("max-keys"))
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, delimiter, maxKeys string) {
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := listResult{Name: f.bucket, Prefix: prefix}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(rest, delimiter); i >= 0 {
+				p := prefix + rest[:i+1]
+				if !seen[p] {
+					seen[p] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{p})
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, listObject{
+			Key:          key,
+			Size:         int64(len(f.objects[key])),
+			LastModified: modTime.Format(time.RFC3339),
+		})
+	}
+	if n, err := strconv.Atoi(maxKeys); err == nil && len(result.Contents) > n {
+		result.Contents = result.Contents[:n]
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func newTestClient(t *testing.T, f *fakeS3) *s3.Client {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(srv.URL),
+		UsePathStyle:               true,
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Parallel()
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{"other/file.txt": []byte("outside")}}
+	ws := New(newTestClient(t, fake), "bucket", "/agents/a1/")
+	ctx := context.Background()
+
+	require.NoError(t, ws.WriteFile(ctx, "top.txt", []byte("top")))
+	require.NoError(t, ws.WriteFile(ctx, "a/b/nested.txt", []byte("nested")))
+	assert.Equal(t, []byte("nested"), fake.objects["agents/a1/a/b/nested.txt"])
+
+	f, err := ws.Open(ctx, "a/b/nested.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "nested", string(data))
+	info, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), info.Size())
+
+	info, err = ws.Stat(ctx, "top.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "top.txt", info.Name())
+	assert.Equal(t, int64(3), info.Size())
+	assert.True(t, modTime.Equal(info.ModTime()))
+
+	// Directories are the prefixes of keys
+	info, err = ws.Stat(ctx, "a/b")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	entries, err := ws.ReadDir(ctx, ".")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "a", entries[0].Name())
+	assert.True(t, entries[0].IsDir())
+	assert.Equal(t, "top.txt", entries[1].Name())
+	assert.False(t, entries[1].IsDir())
+
+	entries, err = ws.ReadDir(ctx, "a/b")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "nested.txt", entries[0].Name())
+
+	// Missing files and directories, including those outside the prefix
+	_, err = ws.Open(ctx, "missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ws.Stat(ctx, "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ws.ReadDir(ctx, "other")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ws.Open(ctx, "../other/file.txt")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
@@ -0,0 +1,96 @@
+// Package workspace provides chat.Workspace implementations backed by a
+// local directory, memory, or any fs.FS.
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"path"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// ErrReadOnly is returned when writing to a workspace that can't be
+// written to.
+var ErrReadOnly = errors.New("read-only workspace")
+
+// FromFS returns a workspace backed by fsys. It can be written to if fsys
+// has a WriteFile(name string, data []byte, perm fs.FileMode) error method,
+// as github.com/psanford/memfs.FS does, and creates parent directories if
+// it has a matching MkdirAll method. Writes are atomic if it also has a
+// Rename(oldpath, newpath string) error method.
+func FromFS(fsys fs.FS) chat.Workspace {
+	return fsWorkspace{fsys}
+}
+
+type fsWorkspace struct {
+	fsys fs.FS
+}
+
+func (w fsWorkspace) Open(ctx context.Context, name string) (fs.File, error) {
+	return w.fsys.Open(name)
+}
+
+func (w fsWorkspace) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(w.fsys, name)
+}
+
+func (w fsWorkspace) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	return fs.Stat(w.fsys, name)
+}
+
+func (w fsWorkspace) WriteFile(ctx context.Context, name string, data []byte) error {
+	type writer interface {
+		WriteFile(name string, data []byte, perm fs.FileMode) error
+	}
+	f, ok := w.fsys.(writer)
+	if !ok {
+		return ErrReadOnly
+	}
+
+	dir := path.Dir(name)
+	if dir != "." {
+		type mkdirAller interface {
+			MkdirAll(path string, perm fs.FileMode) error
+		}
+		if m, ok := w.fsys.(mkdirAller); ok {
+			if err := m.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", dir, err)
+			}
+		}
+	}
+
+	// Write to a temporary file renamed over the original, if possible, so
+	// readers never see a partly written file
+	type renamer interface {
+		Rename(oldpath, newpath string) error
+	}
+	r, ok := w.fsys.(renamer)
+	if !ok {
+		return f.WriteFile(name, data, 0o644)
+	}
+	tmpName := tempName(name)
+	if err := f.WriteFile(tmpName, data, 0o644); err != nil {
+		return err
+	}
+	if err := r.Rename(tmpName, name); err != nil {
+		type remover interface {
+			Remove(name string) error
+		}
+		if rm, ok := w.fsys.(remover); ok {
+			_ = rm.Remove(tmpName)
+		}
+		return err
+	}
+	return nil
+}
+
+// tempName returns a name for a temporary file to write name's contents
+// to before renaming it into place, in the same directory so the rename
+// doesn't cross filesystems.
+func tempName(name string) string {
+	return path.Join(path.Dir(name), fmt.Sprintf(".%s.tmp-%d", path.Base(name), rand.Int64()))
+}
@@ -0,0 +1,105 @@
+package workspace
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/psanford/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bpowers/go-agent/chat"
+)
+
+// testWorkspace checks the behavior every writable workspace shares.
+func testWorkspace(t *testing.T, ws chat.Workspace) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, ws.WriteFile(ctx, "top.txt", []byte("top")))
+	require.NoError(t, ws.WriteFile(ctx, "a/b/nested.txt", []byte("nested")))
+	// Writing again replaces the file
+	require.NoError(t, ws.WriteFile(ctx, "a/b/nested.txt", []byte("replaced")))
+
+	f, err := ws.Open(ctx, "a/b/nested.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "replaced", string(data))
+
+	info, err := ws.Stat(ctx, "a/b/nested.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "nested.txt", info.Name())
+	assert.Equal(t, int64(len("replaced")), info.Size())
+	assert.False(t, info.IsDir())
+
+	info, err = ws.Stat(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	entries, err := ws.ReadDir(ctx, ".")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"a", "top.txt"}, names)
+	assert.True(t, entries[0].IsDir())
+
+	_, err = ws.Open(ctx, "missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ws.Stat(ctx, "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestFromFS(t *testing.T) {
+	t.Parallel()
+	testWorkspace(t, FromFS(memfs.New()))
+
+	// Filesystems without a WriteFile method are read-only
+	ws := FromFS(fstest.MapFS{"a.txt": {Data: []byte("a")}})
+	assert.ErrorIs(t, ws.WriteFile(context.Background(), "b.txt", nil), ErrReadOnly)
+	info, err := ws.Stat(context.Background(), "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size())
+}
+
+func TestNewMemory(t *testing.T) {
+	t.Parallel()
+	testWorkspace(t, NewMemory())
+
+	// Each workspace has its own files
+	_, err := NewMemory().Stat(context.Background(), "top.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestNewRoot(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { root.Close() })
+	ws := NewRoot(root)
+	testWorkspace(t, ws)
+
+	data, err := os.ReadFile(filepath.Join(dir, "a", "b", "nested.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", string(data))
+
+	// The root can't be escaped
+	ctx := context.Background()
+	assert.Error(t, ws.WriteFile(ctx, "../escape.txt", []byte("x")))
+	require.NoError(t, os.Symlink(os.TempDir(), filepath.Join(dir, "link")))
+	assert.Error(t, ws.WriteFile(ctx, "link/escape.txt", []byte("x")))
+	_, err = os.Stat(filepath.Join(os.TempDir(), "escape.txt"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// A file can't stand in for a directory
+	assert.Error(t, ws.WriteFile(ctx, "top.txt/child.txt", []byte("x")))
+}